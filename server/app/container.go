@@ -1,10 +1,10 @@
 package app
 
 import (
-	"context"
 	"log"
 	"ridewave/config"
 	"ridewave/db"
+	"ridewave/repository"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/redis/go-redis/v9"
@@ -15,6 +15,7 @@ type App struct {
 	Config config.Config
 	DB     *pgxpool.Pool
 	Redis  *redis.Client
+	Repos  repository.Repos
 }
 
 // Instance is the global singleton for the app container
@@ -32,15 +33,21 @@ func Initialize() {
 	db.InitRedis()
 
 	// 4. Create Container
-	Instance = &App{
-		Config: config.Envs,
-		DB:     db.Pool,
-		Redis:  db.RedisClient,
-	}
+	Instance = New(config.Envs, db.Pool, db.RedisClient)
 
 	log.Println("✅ RideWave App Container initialized successfully")
 }
 
+// New builds a container around already-open connections, wiring the Postgres repositories.
+func New(cfg config.Config, pool *pgxpool.Pool, rdb *redis.Client) *App {
+	return &App{
+		Config: cfg,
+		DB:     pool,
+		Redis:  rdb,
+		Repos:  repository.NewPostgres(pool),
+	}
+}
+
 // Close gracefully shuts down all resources
 func (a *App) Close() {
 	if a.DB != nil {
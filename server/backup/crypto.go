@@ -0,0 +1,130 @@
+package backup
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// Encrypted files are a magic header followed by AES-256-GCM frames:
+//
+//	flag (1) | nonce (12) | length (4) | ciphertext
+//
+// Each frame seals up to chunkSize bytes with the frame index and the final flag as
+// additional data, so reordered, dropped or truncated frames fail to decrypt.
+
+var magic = []byte("RWBK1\n")
+
+const chunkSize = 1 << 20
+
+var ErrCorrupt = errors.New("backup: encrypted file is corrupt or truncated")
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	if len(key) != 32 {
+		return nil, errors.New("backup: encryption key must be 32 bytes")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func frameAAD(index uint64, final byte) []byte {
+	aad := make([]byte, 9)
+	binary.BigEndian.PutUint64(aad, index)
+	aad[8] = final
+	return aad
+}
+
+// Encrypt streams src into dst as encrypted frames.
+func Encrypt(dst io.Writer, src io.Reader, key []byte) error {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+	if _, err := dst.Write(magic); err != nil {
+		return err
+	}
+
+	br := bufio.NewReaderSize(src, chunkSize)
+	buf := make([]byte, chunkSize)
+	for i := uint64(0); ; i++ {
+		n, err := io.ReadFull(br, buf)
+		var final byte
+		switch {
+		case err == io.EOF || err == io.ErrUnexpectedEOF:
+			final = 1
+		case err != nil:
+			return err
+		default:
+			if _, perr := br.Peek(1); perr == io.EOF {
+				final = 1
+			}
+		}
+
+		nonce := make([]byte, gcm.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return err
+		}
+		sealed := gcm.Seal(nil, nonce, buf[:n], frameAAD(i, final))
+
+		header := make([]byte, 1+len(nonce)+4)
+		header[0] = final
+		copy(header[1:], nonce)
+		binary.BigEndian.PutUint32(header[1+len(nonce):], uint32(len(sealed)))
+		if _, err := dst.Write(header); err != nil {
+			return err
+		}
+		if _, err := dst.Write(sealed); err != nil {
+			return err
+		}
+		if final == 1 {
+			return nil
+		}
+	}
+}
+
+// Decrypt streams encrypted frames from src into dst, failing on any tampering or truncation.
+func Decrypt(dst io.Writer, src io.Reader, key []byte) error {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+	head := make([]byte, len(magic))
+	if _, err := io.ReadFull(src, head); err != nil || string(head) != string(magic) {
+		return ErrCorrupt
+	}
+
+	header := make([]byte, 1+gcm.NonceSize()+4)
+	for i := uint64(0); ; i++ {
+		if _, err := io.ReadFull(src, header); err != nil {
+			return ErrCorrupt
+		}
+		final := header[0]
+		nonce := header[1 : 1+gcm.NonceSize()]
+		size := binary.BigEndian.Uint32(header[1+gcm.NonceSize():])
+		if size > chunkSize+uint32(gcm.Overhead()) {
+			return ErrCorrupt
+		}
+
+		sealed := make([]byte, size)
+		if _, err := io.ReadFull(src, sealed); err != nil {
+			return ErrCorrupt
+		}
+		plain, err := gcm.Open(nil, nonce, sealed, frameAAD(i, final))
+		if err != nil {
+			return ErrCorrupt
+		}
+		if _, err := dst.Write(plain); err != nil {
+			return err
+		}
+		if final == 1 {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package backup
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"ridewave/db"
+)
+
+// Run is the outcome of one backup job, as recorded in backup_runs.
+type Run struct {
+	Kind       string    `json:"kind"`
+	Status     string    `json:"status"` // success | failed
+	Manifest   *Manifest `json:"manifest,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	StartedAt  time.Time `json:"startedAt"`
+	FinishedAt time.Time `json:"finishedAt"`
+}
+
+// RunAll backs up Postgres and Redis one after the other and records each run.
+func RunAll(ctx context.Context, cfg *Config, rdb *redis.Client) []Run {
+	runs := []Run{RunPostgres(ctx, cfg)}
+	if rdb != nil {
+		runs = append(runs, RunRedis(ctx, cfg, rdb))
+	}
+	return runs
+}
+
+// RunPostgres takes and records a single Postgres backup.
+func RunPostgres(ctx context.Context, cfg *Config) Run {
+	return runOne(ctx, cfg, KindPostgres, func() (*Manifest, error) { return BackupPostgres(ctx, cfg) })
+}
+
+// RunRedis takes and records a single Redis backup.
+func RunRedis(ctx context.Context, cfg *Config, rdb *redis.Client) Run {
+	return runOne(ctx, cfg, KindRedis, func() (*Manifest, error) { return BackupRedis(ctx, cfg, rdb) })
+}
+
+func runOne(ctx context.Context, cfg *Config, kind string, fn func() (*Manifest, error)) Run {
+	run := Run{Kind: kind, StartedAt: time.Now()}
+	m, err := fn()
+	run.FinishedAt = time.Now()
+	if err != nil {
+		run.Status, run.Error = "failed", err.Error()
+	} else {
+		run.Status, run.Manifest = "success", m
+	}
+	record(ctx, cfg, run)
+	return run
+}
+
+// record stores the run for the admin status endpoint; it is best-effort so a backup
+// taken while the database is down still ships.
+func record(ctx context.Context, cfg *Config, run Run) {
+	if db.Pool == nil {
+		return
+	}
+	var object, sha string
+	var size int64
+	if run.Manifest != nil {
+		object, sha, size = run.Manifest.Object, run.Manifest.SHA256, run.Manifest.EncryptedSize
+	}
+	db.Pool.Exec(ctx,
+		`INSERT INTO backup_runs (kind, region, status, object, "sizeBytes", sha256, error, "startedAt", "finishedAt")
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		run.Kind, cfg.Region, run.Status, object, size, sha, run.Error, run.StartedAt, run.FinishedAt)
+}
@@ -0,0 +1,186 @@
+// Package chaos injects artificial latency and failures into Redis, Postgres and
+// outbound HTTP calls so fallbacks can be exercised in staging. It is inert unless
+// CHAOS_ENABLED=true and an admin has switched a fault on.
+package chaos
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/redis/go-redis/v9"
+)
+
+// Fault targets.
+const (
+	TargetRedis    = "redis"
+	TargetPostgres = "postgres"
+	TargetExternal = "external"
+)
+
+var Targets = []string{TargetRedis, TargetPostgres, TargetExternal}
+
+// ErrInjected is returned by calls failed on purpose.
+var ErrInjected = errors.New("chaos: injected fault")
+
+// Fault describes what happens to a share of calls against one target.
+type Fault struct {
+	Enabled      bool `json:"enabled"`
+	DelayMs      int  `json:"delayMs"`      // latency added to affected calls
+	DelayPercent int  `json:"delayPercent"` // 0-100 share of calls delayed
+	FailPercent  int  `json:"failPercent"`  // 0-100 share of calls failed with ErrInjected
+}
+
+// State is the full fault configuration of this instance.
+type State struct {
+	Faults    map[string]Fault `json:"faults"`
+	ExpiresAt *time.Time       `json:"expiresAt"` // faults switch themselves off at this time
+	UpdatedBy string           `json:"updatedBy"`
+	UpdatedAt *time.Time       `json:"updatedAt"`
+}
+
+var (
+	mu    sync.RWMutex
+	state = State{Faults: map[string]Fault{}}
+)
+
+// Allowed reports whether fault injection may be used on this deployment (CHAOS_ENABLED=true).
+func Allowed() bool {
+	return os.Getenv("CHAOS_ENABLED") == "true"
+}
+
+// Get returns a copy of the current configuration.
+func Get() State {
+	mu.RLock()
+	defer mu.RUnlock()
+	out := state
+	out.Faults = map[string]Fault{}
+	for k, v := range state.Faults {
+		out.Faults[k] = v
+	}
+	return out
+}
+
+// Set replaces the fault for a target until expiresAt.
+func Set(target string, f Fault, expiresAt time.Time, by string) {
+	mu.Lock()
+	defer mu.Unlock()
+	now := time.Now()
+	state.Faults[target] = f
+	state.ExpiresAt = &expiresAt
+	state.UpdatedBy = by
+	state.UpdatedAt = &now
+}
+
+// Reset switches every fault off.
+func Reset(by string) {
+	mu.Lock()
+	defer mu.Unlock()
+	now := time.Now()
+	state = State{Faults: map[string]Fault{}, UpdatedBy: by, UpdatedAt: &now}
+}
+
+func active(target string) (Fault, bool) {
+	if !Allowed() {
+		return Fault{}, false
+	}
+	mu.RLock()
+	defer mu.RUnlock()
+	if state.ExpiresAt != nil && time.Now().After(*state.ExpiresAt) {
+		return Fault{}, false
+	}
+	f, ok := state.Faults[target]
+	return f, ok && f.Enabled
+}
+
+// inject applies the target's fault to one call: it may sleep, and may return ErrInjected.
+func inject(ctx context.Context, target string) error {
+	f, ok := active(target)
+	if !ok {
+		return nil
+	}
+	if f.DelayMs > 0 && rand.Intn(100) < f.DelayPercent {
+		select {
+		case <-time.After(time.Duration(f.DelayMs) * time.Millisecond):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if rand.Intn(100) < f.FailPercent {
+		return ErrInjected
+	}
+	return nil
+}
+
+// ══════════════════════════════════════════════════
+// Hooks
+// ══════════════════════════════════════════════════
+
+// RedisHook is a go-redis hook that faults commands and pipelines.
+type RedisHook struct{}
+
+func (RedisHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return next(ctx, network, addr)
+	}
+}
+
+func (RedisHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		if err := inject(ctx, TargetRedis); err != nil {
+			cmd.SetErr(err)
+			return err
+		}
+		return next(ctx, cmd)
+	}
+}
+
+func (RedisHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		if err := inject(ctx, TargetRedis); err != nil {
+			for _, cmd := range cmds {
+				cmd.SetErr(err)
+			}
+			return err
+		}
+		return next(ctx, cmds)
+	}
+}
+
+// PgTracer is a pgx query tracer that faults queries. A failed query gets an already
+// cancelled context, so pgx returns the error before anything reaches the server.
+type PgTracer struct{}
+
+func (PgTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, _ pgx.TraceQueryStartData) context.Context {
+	if err := inject(ctx, TargetPostgres); err != nil {
+		ctx, cancel := context.WithCancelCause(ctx)
+		cancel(err)
+		return ctx
+	}
+	return ctx
+}
+
+func (PgTracer) TraceQueryEnd(context.Context, *pgx.Conn, pgx.TraceQueryEndData) {}
+
+// Transport wraps an http.RoundTripper and faults outbound API calls (maps, SMS, push, payments).
+type Transport struct {
+	Base http.RoundTripper
+}
+
+func (t Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := inject(req.Context(), TargetExternal); err != nil {
+		return nil, err
+	}
+	return t.Base.RoundTrip(req)
+}
+
+// Install wraps the default HTTP transport so every outbound client is covered.
+func Install() {
+	http.DefaultTransport = Transport{Base: http.DefaultTransport}
+}
@@ -0,0 +1,184 @@
+// Command backup takes, lists and restores encrypted RideWave backups.
+//
+//	go run ./cmd/backup run [--only postgres|redis]
+//	go run ./cmd/backup list [--kind postgres|redis]
+//	go run ./cmd/backup restore --kind postgres|redis [--object KEY | --at RFC3339] [--verify-only] [--yes]
+//
+// Configuration comes from the same environment as the server (see backup.LoadConfig).
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"ridewave/backup"
+	"ridewave/db"
+	"ridewave/utils"
+)
+
+func main() {
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found")
+	}
+	utils.InitLogger()
+
+	if len(os.Args) < 2 {
+		usage()
+	}
+	cfg, err := backup.LoadConfig()
+	if err != nil {
+		log.Fatalf("Backup configuration: %v", err)
+	}
+
+	ctx := context.Background()
+	switch os.Args[1] {
+	case "run":
+		runCmd(ctx, cfg, os.Args[2:])
+	case "list":
+		listCmd(ctx, cfg, os.Args[2:])
+	case "restore":
+		restoreCmd(ctx, cfg, os.Args[2:])
+	default:
+		usage()
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, `usage:
+  backup run [--only postgres|redis]
+  backup list [--kind postgres|redis]
+  backup restore --kind postgres|redis [--object KEY | --at RFC3339] [--verify-only] [--yes]`)
+	os.Exit(2)
+}
+
+func runCmd(ctx context.Context, cfg *backup.Config, args []string) {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	only := fs.String("only", "", "back up only postgres or redis")
+	fs.Parse(args)
+
+	db.Connect()
+	defer db.Close()
+
+	var runs []backup.Run
+	switch *only {
+	case "":
+		db.InitRedis()
+		runs = backup.RunAll(ctx, cfg, db.RedisClient)
+	case backup.KindPostgres:
+		runs = []backup.Run{backup.RunPostgres(ctx, cfg)}
+	case backup.KindRedis:
+		db.InitRedis()
+		runs = []backup.Run{backup.RunRedis(ctx, cfg, db.RedisClient)}
+	default:
+		log.Fatalf("--only must be postgres or redis")
+	}
+
+	failed := false
+	for _, run := range runs {
+		if run.Status != "success" {
+			failed = true
+			fmt.Printf("%-8s FAILED  %s\n", run.Kind, run.Error)
+			continue
+		}
+		fmt.Printf("%-8s ok      %s (%d bytes, sha256 %s)\n", run.Kind, run.Manifest.Object, run.Manifest.EncryptedSize, run.Manifest.SHA256)
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func listCmd(ctx context.Context, cfg *backup.Config, args []string) {
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	kind := fs.String("kind", "", "only list postgres or redis backups")
+	fs.Parse(args)
+
+	keys, err := cfg.Storage.List(ctx, cfg.Prefix+"/"+cfg.Region+"/"+*kind)
+	if err != nil {
+		log.Fatalf("List backups: %v", err)
+	}
+	for _, key := range keys {
+		if strings.HasSuffix(key, ".enc") {
+			fmt.Println(key)
+		}
+	}
+}
+
+func restoreCmd(ctx context.Context, cfg *backup.Config, args []string) {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	kind := fs.String("kind", "", "postgres or redis")
+	object := fs.String("object", "", "exact backup object key to restore")
+	at := fs.String("at", "", "restore the newest backup taken at or before this RFC3339 time (default: now)")
+	verifyOnly := fs.Bool("verify-only", false, "download, decrypt and verify without restoring")
+	yes := fs.Bool("yes", false, "confirm overwriting the live datastore")
+	fs.Parse(args)
+
+	if *kind != backup.KindPostgres && *kind != backup.KindRedis {
+		log.Fatalf("--kind must be postgres or redis")
+	}
+
+	if *object == "" {
+		target := time.Now()
+		if *at != "" {
+			t, err := time.Parse(time.RFC3339, *at)
+			if err != nil {
+				log.Fatalf("--at must be RFC3339, e.g. 2026-10-16T09:00:00Z")
+			}
+			target = t
+		}
+		key, err := backup.Latest(ctx, cfg, *kind, target)
+		if err != nil {
+			log.Fatalf("Find backup: %v", err)
+		}
+		*object = key
+	}
+
+	fmt.Printf("Fetching %s\n", *object)
+	m, path, err := backup.Fetch(ctx, cfg, *object)
+	if err != nil {
+		log.Fatalf("Verify backup: %v", err)
+	}
+	defer os.Remove(path)
+	if m.Kind != *kind {
+		log.Fatalf("Object is a %s backup, not %s", m.Kind, *kind)
+	}
+	fmt.Printf("Checksums OK: taken %s in region %s, %d bytes (sha256 %s)\n",
+		m.CreatedAt.Format(time.RFC3339), m.Region, m.Size, m.SHA256)
+
+	if *kind == backup.KindPostgres {
+		if err := backup.VerifyPostgresArchive(ctx, path); err != nil {
+			log.Fatalf("Verify archive: %v", err)
+		}
+		fmt.Println("Archive readable by pg_restore")
+	}
+
+	if *verifyOnly {
+		return
+	}
+	if !*yes {
+		log.Fatalf("Refusing to overwrite the %s datastore without --yes", *kind)
+	}
+	if m.Region != cfg.Region {
+		log.Fatalf("Backup belongs to region %s but this environment is region %s", m.Region, cfg.Region)
+	}
+
+	switch *kind {
+	case backup.KindPostgres:
+		if err := backup.RestorePostgres(ctx, cfg.DatabaseURL, path); err != nil {
+			log.Fatalf("Restore failed: %v", err)
+		}
+		fmt.Println("Postgres restored")
+	case backup.KindRedis:
+		db.InitRedis()
+		n, err := backup.RestoreRedis(ctx, db.RedisClient, path)
+		if err != nil {
+			log.Fatalf("Restore failed after %d keys: %v", n, err)
+		}
+		fmt.Printf("Redis restored (%d keys)\n", n)
+	}
+}
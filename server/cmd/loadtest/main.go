@@ -0,0 +1,493 @@
+// Command loadtest drives a running server with the accounts cmd/seed creates and reports latency
+// per step, so a regression in dispatch or admin analytics shows up before it ships.
+//
+//	go run ./cmd/loadtest [--base-url http://localhost:8000] [--workers 20] [--admin-workers 2]
+//	                      [--duration 1m | --iterations N] [--vehicle-type Car]
+//	                      [--max-error-rate 0.01] [--max-p95 800ms]
+//
+// Each ride worker is one seeded rider and one seeded driver looping estimate → create → accept →
+// start → complete. Admin workers poll the dashboard, daily analytics and ride list. Sessions are
+// issued straight from Redis and the trip OTP read from Postgres, so the tool needs the server's
+// environment; the ride is handed to the worker's driver in Postgres the way taking a dispatch
+// offer would. Estimates plan real routes, so the server needs its maps key. Raise the server's
+// RATE_LIMIT_RIDE_CREATE_PER_MINUTE and RATE_LIMIT_RIDE_ESTIMATE_PER_MINUTE for long runs.
+//
+// Exits 1 when the error rate or any step's p95 is over its limit.
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/joho/godotenv"
+	"ridewave/db"
+	"ridewave/utils"
+)
+
+// Matches cmd/seed: riders are +9190000NNNNN and drivers +9190001NNNNN.
+const (
+	userPhonePrefix   = "+9190000"
+	driverPhonePrefix = "+9190001"
+)
+
+func main() {
+	baseURL := flag.String("base-url", "http://localhost:8000", "server to test")
+	workers := flag.Int("workers", 20, "concurrent ride loops, one rider and driver each")
+	adminWorkers := flag.Int("admin-workers", 2, "concurrent admin analytics loops")
+	duration := flag.Duration("duration", time.Minute, "how long to run")
+	iterations := flag.Int("iterations", 0, "stop after this many ride loops in total instead (0: run for --duration)")
+	vehicleType := flag.String("vehicle-type", "Car", "vehicle type to book; workers use seeded drivers of this type")
+	lat := flag.Float64("lat", 12.9716, "latitude of the area trips start and end in")
+	lng := flag.Float64("lng", 77.5946, "longitude of the area trips start and end in")
+	radiusKm := flag.Float64("radius-km", 8, "radius around lat,lng for trips")
+	think := flag.Duration("think", 0, "pause between a worker's loops")
+	adminEmail := flag.String("admin-email", "", "admin to poll analytics as (default: the first active superadmin)")
+	maxErrorRate := flag.Float64("max-error-rate", 0.01, "fail when more than this share of requests fail")
+	maxP95 := flag.Duration("max-p95", 0, "fail when any step's p95 is slower (0: no limit)")
+	flag.Parse()
+
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found")
+	}
+	utils.InitLogger()
+	db.Connect()
+	defer db.Close()
+	db.InitRedis()
+
+	// The seeded accounts are the default tenant's
+	ctx, stop := signal.NotifyContext(db.WithTenant(context.Background(), db.DefaultTenant), os.Interrupt)
+	defer stop()
+	if *iterations == 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *duration)
+		defer cancel()
+	}
+
+	riders, err := seededAccounts(ctx, `SELECT id FROM "user" WHERE phone_number LIKE $1 || '%' AND status='active' ORDER BY phone_number LIMIT $2`,
+		userPhonePrefix, *workers)
+	if err != nil {
+		log.Fatalf("Load seeded riders: %v", err)
+	}
+	drivers, err := seededAccounts(ctx, `SELECT id FROM driver WHERE phone_number LIKE $1 || '%' AND status='active' AND vehicle_type=$3
+		ORDER BY phone_number LIMIT $2`, driverPhonePrefix, *workers, *vehicleType)
+	if err != nil {
+		log.Fatalf("Load seeded drivers: %v", err)
+	}
+	if len(riders) < *workers || len(drivers) < *workers {
+		log.Fatalf("%d workers need as many seeded riders and %s drivers; found %d and %d (run cmd/seed)",
+			*workers, *vehicleType, len(riders), len(drivers))
+	}
+	// Seeded drivers are marked online, but a driver who went offline since would refuse the ride
+	if _, err := db.Pool.Exec(ctx, `UPDATE driver SET "isOnline"=TRUE WHERE id=ANY($1)`, drivers); err != nil {
+		log.Fatalf("Bring drivers online: %v", err)
+	}
+
+	api := &client{base: strings.TrimRight(*baseURL, "/") + utils.APIPrefix(utils.APIv1), apiKey: os.Getenv("API_KEY"),
+		http: &http.Client{Timeout: 30 * time.Second}}
+	var token string
+	if *adminWorkers > 0 {
+		if token, err = adminToken(ctx, *adminEmail); err != nil {
+			log.Fatalf("Admin token: %v", err)
+		}
+	}
+	rec := newRecorder()
+	var remaining atomic.Int64
+	remaining.Store(int64(*iterations))
+	var completed atomic.Int64
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < *workers; i++ {
+		w := &rideWorker{api: api, rec: rec, riderID: riders[i], driverID: drivers[i], vehicleType: *vehicleType,
+			area: area{lat: *lat, lng: *lng, radiusKm: *radiusKm, rng: rand.New(rand.NewPCG(uint64(i), uint64(time.Now().UnixNano())))}}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				if *iterations > 0 && remaining.Add(-1) < 0 {
+					return
+				}
+				if w.ride(ctx) == nil {
+					completed.Add(1)
+				}
+				sleep(ctx, *think)
+			}
+		}()
+	}
+
+	adminCtx, stopAdmin := context.WithCancel(ctx)
+	var adminWG sync.WaitGroup
+	if *adminWorkers > 0 {
+		for i := 0; i < *adminWorkers; i++ {
+			adminWG.Add(1)
+			go func() {
+				defer adminWG.Done()
+				for adminCtx.Err() == nil {
+					pollAnalytics(adminCtx, api, rec, token)
+					sleep(adminCtx, *think)
+				}
+			}()
+		}
+	}
+
+	wg.Wait()
+	stopAdmin()
+	adminWG.Wait()
+	elapsed := time.Since(start)
+
+	fmt.Printf("\n%d rides completed in %s (%.2f rides/s)\n\n", completed.Load(), elapsed.Round(time.Millisecond),
+		float64(completed.Load())/elapsed.Seconds())
+	if failures := rec.report(os.Stdout, *maxErrorRate, *maxP95); len(failures) > 0 {
+		for _, f := range failures {
+			fmt.Println("FAIL", f)
+		}
+		os.Exit(1)
+	}
+}
+
+func seededAccounts(ctx context.Context, query string, args ...any) ([]string, error) {
+	rows, err := db.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowTo[string])
+}
+
+func adminToken(ctx context.Context, email string) (string, error) {
+	var id string
+	err := db.Pool.QueryRow(ctx,
+		`SELECT id FROM admin_accounts WHERE "isActive" AND (email=$1 OR ($1='' AND role='superadmin')) ORDER BY "createdAt" LIMIT 1`,
+		email).Scan(&id)
+	if err != nil {
+		return "", fmt.Errorf("no active admin to poll as: %w", err)
+	}
+	token, _, err := utils.GenerateAdminToken(id)
+	return token, err
+}
+
+func sleep(ctx context.Context, d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	select {
+	case <-ctx.Done():
+	case <-time.After(d):
+	}
+}
+
+// ══════════════════════════════════════════════════
+// Scenarios
+// ══════════════════════════════════════════════════
+
+type rideWorker struct {
+	api         *client
+	rec         *recorder
+	riderID     string
+	driverID    string
+	vehicleType string
+	area        area
+
+	riderToken, driverToken string
+	issuedAt                time.Time
+}
+
+// sessions logs the worker's rider and driver in, again once their access tokens are half used.
+func (w *rideWorker) sessions(ctx context.Context) error {
+	if w.riderToken != "" && time.Since(w.issuedAt) < utils.AccessTokenTTL()/2 {
+		return nil
+	}
+	rider, err := utils.IssueSession(ctx, utils.SessionUser, w.riderID)
+	if err != nil {
+		return err
+	}
+	driver, err := utils.IssueSession(ctx, utils.SessionDriver, w.driverID)
+	if err != nil {
+		return err
+	}
+	w.riderToken, w.driverToken, w.issuedAt = rider.AccessToken, driver.AccessToken, time.Now()
+	return nil
+}
+
+// ride books and completes one trip. A trip that fails part way is cancelled so the rider and
+// driver are free for the next loop.
+func (w *rideWorker) ride(ctx context.Context) error {
+	if err := w.sessions(ctx); err != nil {
+		w.rec.fail("session", err)
+		return err
+	}
+	originLat, originLng := w.area.point()
+	destLat, destLng := w.area.point()
+
+	var estimate struct {
+		RouteID string `json:"routeId"`
+	}
+	err := w.rec.time("estimate", func() error {
+		return w.api.do(ctx, http.MethodPost, "/user/ride/estimate", w.riderToken, nil, map[string]any{
+			"origin":      fmt.Sprintf("%.6f,%.6f", originLat, originLng),
+			"destination": fmt.Sprintf("%.6f,%.6f", destLat, destLng),
+			"vehicleType": w.vehicleType,
+		}, &estimate)
+	})
+	if err != nil {
+		return err
+	}
+
+	var created struct {
+		RideID string `json:"rideId"`
+	}
+	err = w.rec.time("create", func() error {
+		return w.api.do(ctx, http.MethodPost, "/user/ride/create", w.riderToken,
+			map[string]string{"Idempotency-Key": uuid.New().String()},
+			map[string]any{"routeId": estimate.RouteID, "vehicleType": w.vehicleType, "paymentMode": "cash"}, &created)
+	})
+	if err != nil {
+		return err
+	}
+
+	err = w.rec.time("accept", func() error {
+		tag, err := db.Pool.Exec(ctx, `UPDATE rides SET "driverId"=$2, "updatedAt"=NOW()
+			WHERE id=$1 AND status='Requested' AND "driverId" IS NULL`, created.RideID, w.driverID)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return errors.New("ride was no longer open to take")
+		}
+		return w.api.do(ctx, http.MethodPut, "/driver/ride/status", w.driverToken, nil,
+			map[string]any{"rideId": created.RideID, "rideStatus": "Accepted"}, nil)
+	})
+	if err == nil {
+		err = w.rec.time("start", func() error {
+			var otp string
+			if err := db.Pool.QueryRow(ctx, `SELECT COALESCE(otp, '') FROM rides WHERE id=$1`, created.RideID).Scan(&otp); err != nil {
+				return err
+			}
+			return w.api.do(ctx, http.MethodPut, "/driver/ride/start-with-otp", w.driverToken, nil,
+				map[string]any{"rideId": created.RideID, "otp": otp}, nil)
+		})
+	}
+	if err == nil {
+		err = w.rec.time("complete", func() error {
+			return w.api.do(ctx, http.MethodPut, "/driver/ride/status", w.driverToken, nil,
+				map[string]any{"rideId": created.RideID, "rideStatus": "Completed"}, nil)
+		})
+	}
+	if err != nil && created.RideID != "" {
+		// Not timed: cleanup isn't part of the scenario
+		w.api.do(context.WithoutCancel(ctx), http.MethodPost, "/user/ride/cancel", w.riderToken, nil,
+			map[string]any{"rideId": created.RideID, "cancelReason": "load test cleanup"}, nil)
+	}
+	return err
+}
+
+// pollAnalytics makes the requests an admin dashboard makes on load.
+func pollAnalytics(ctx context.Context, api *client, rec *recorder, token string) {
+	for _, step := range []struct{ name, path string }{
+		{"admin dashboard", "/admin/dashboard"},
+		{"admin analytics", "/admin/analytics/daily"},
+		{"admin rides", "/admin/rides?limit=50"},
+	} {
+		if rec.time(step.name, func() error {
+			return api.do(ctx, http.MethodGet, step.path, token, nil, nil, nil)
+		}) != nil && ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// ══════════════════════════════════════════════════
+// HTTP client
+// ══════════════════════════════════════════════════
+
+type client struct {
+	base   string
+	apiKey string
+	http   *http.Client
+}
+
+// do sends a request and decodes the response's data into out (if not nil). Non-2xx responses
+// are errors carrying the status, code and message.
+func (c *client) do(ctx context.Context, method, path, token string, headers map[string]string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(raw)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.base+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	if c.apiKey != "" {
+		req.Header.Set("x-api-key", c.apiKey)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var envelope struct {
+		Message string          `json:"message"`
+		Code    string          `json:"code"`
+		Data    json.RawMessage `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("%s %s: %d, unreadable body: %w", method, path, resp.StatusCode, err)
+	}
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%d %s %s", resp.StatusCode, envelope.Code, envelope.Message)
+	}
+	if out != nil && len(envelope.Data) > 0 {
+		return json.Unmarshal(envelope.Data, out)
+	}
+	return nil
+}
+
+// ══════════════════════════════════════════════════
+// Results
+// ══════════════════════════════════════════════════
+
+type stepStats struct {
+	durations []time.Duration
+	errors    int
+	lastError string
+}
+
+// recorder collects every step's latencies and failures, in the order steps first ran.
+type recorder struct {
+	mu    sync.Mutex
+	steps map[string]*stepStats
+	order []string
+}
+
+func newRecorder() *recorder {
+	return &recorder{steps: map[string]*stepStats{}}
+}
+
+func (r *recorder) step(name string) *stepStats {
+	s, ok := r.steps[name]
+	if !ok {
+		s = &stepStats{}
+		r.steps[name] = s
+		r.order = append(r.order, name)
+	}
+	return s
+}
+
+// time runs fn as one request of a step. Failures count towards the error rate but not latency;
+// requests cut off by the end of the run don't count at all.
+func (r *recorder) time(name string, fn func() error) error {
+	start := time.Now()
+	err := fn()
+	elapsed := time.Since(start)
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := r.step(name)
+	if err != nil {
+		s.errors++
+		s.lastError = err.Error()
+		return err
+	}
+	s.durations = append(s.durations, elapsed)
+	return nil
+}
+
+func (r *recorder) fail(name string, err error) {
+	r.time(name, func() error { return err })
+}
+
+// report prints a table of the steps and returns the limits they broke.
+func (r *recorder) report(w io.Writer, maxErrorRate float64, maxP95 time.Duration) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "step\tok\terrors\tp50\tp95\tp99\tmax\t")
+	var failures []string
+	total, failed := 0, 0
+	for _, name := range r.order {
+		s := r.steps[name]
+		slices.Sort(s.durations)
+		p95 := percentile(s.durations, 95)
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%s\t%s\t%s\t%s\t\n", name, len(s.durations), s.errors,
+			percentile(s.durations, 50), p95, percentile(s.durations, 99), percentile(s.durations, 100))
+		total += len(s.durations) + s.errors
+		failed += s.errors
+		if maxP95 > 0 && p95 > maxP95 {
+			failures = append(failures, fmt.Sprintf("%s p95 %s is over %s", name, p95, maxP95))
+		}
+	}
+	tw.Flush()
+
+	for _, name := range r.order {
+		if s := r.steps[name]; s.lastError != "" {
+			fmt.Fprintf(w, "\n%s, last error: %s", name, s.lastError)
+		}
+	}
+	fmt.Fprintln(w)
+	if total > 0 && float64(failed)/float64(total) > maxErrorRate {
+		failures = append(failures, fmt.Sprintf("error rate %.2f%% is over %.2f%%",
+			100*float64(failed)/float64(total), 100*maxErrorRate))
+	}
+	if total == 0 {
+		failures = append(failures, "no requests were made")
+	}
+	return failures
+}
+
+// percentile of sorted durations, rounded for display.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	return sorted[max(i, 0)].Round(100 * time.Microsecond)
+}
+
+// area picks uniformly distributed points within radiusKm of a centre.
+type area struct {
+	lat, lng, radiusKm float64
+	rng                *rand.Rand
+}
+
+func (a *area) point() (float64, float64) {
+	r := a.radiusKm * math.Sqrt(a.rng.Float64())
+	theta := 2 * math.Pi * a.rng.Float64()
+	dLat := r * math.Cos(theta) / 111.32
+	dLng := r * math.Sin(theta) / (111.32 * math.Cos(a.lat*math.Pi/180))
+	return a.lat + dLat, a.lng + dLng
+}
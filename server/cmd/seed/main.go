@@ -0,0 +1,244 @@
+// Command seed fills a development or staging database with fake riders, drivers and ride
+// history, and puts the drivers on the Redis geo index so dispatch finds them.
+//
+//	go run ./cmd/seed [--users 5000] [--drivers 1000] [--rides 20000] [--days 30]
+//	                  [--lat 12.9716 --lng 77.5946 --radius-km 8] [--geo-only] [--force]
+//
+// Seeded accounts have phone numbers starting with seedPhonePrefix, which is how cmd/loadtest
+// finds them. Re-running adds rides but never duplicates accounts. Driver positions expire from
+// Redis after an hour like real ones, so run with --geo-only to bring them back before a load test.
+// Configuration comes from the same environment as the server.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"math/rand/v2"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/joho/godotenv"
+	"github.com/redis/go-redis/v9"
+	"ridewave/db"
+	"ridewave/stores"
+	"ridewave/utils"
+)
+
+// Seeded riders are +9190000NNNNN and drivers +9190001NNNNN: valid Indian mobile numbers in a
+// block no real account will have.
+const (
+	seedPhonePrefix   = "+919000"
+	userPhonePrefix   = seedPhonePrefix + "0"
+	driverPhonePrefix = seedPhonePrefix + "1"
+	maxSeedAccounts   = 100000
+)
+
+var vehicleTypes = []string{"Car", "Auto", "Sedan", "SUV", "Motorcycle"}
+
+var places = []string{
+	"MG Road", "Indiranagar", "Koramangala", "Whitefield", "Electronic City", "HSR Layout",
+	"Jayanagar", "Hebbal", "Yelahanka", "Marathahalli", "Airport Terminal 1", "Majestic",
+}
+
+func main() {
+	users := flag.Int("users", 5000, "riders to create")
+	drivers := flag.Int("drivers", 1000, "drivers to create, online and active")
+	rides := flag.Int("rides", 20000, "past rides to create between seeded riders and drivers")
+	days := flag.Int("days", 30, "spread past rides over this many days")
+	lat := flag.Float64("lat", 12.9716, "latitude of the area to seed")
+	lng := flag.Float64("lng", 77.5946, "longitude of the area to seed")
+	radiusKm := flag.Float64("radius-km", 8, "radius around lat,lng for drivers and trips")
+	geoOnly := flag.Bool("geo-only", false, "only put already seeded drivers back on the geo index")
+	randSeed := flag.Uint64("rand-seed", uint64(time.Now().UnixNano()), "seed for the generated data")
+	force := flag.Bool("force", false, "allow seeding when the environment looks like production")
+	flag.Parse()
+
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found")
+	}
+	utils.InitLogger()
+
+	if (os.Getenv("GIN_MODE") == "release" || os.Getenv("NODE_ENV") == "production") && !*force {
+		log.Fatalf("Refusing to seed what looks like production (GIN_MODE/NODE_ENV); pass --force if it isn't")
+	}
+	if *users > maxSeedAccounts || *drivers > maxSeedAccounts {
+		log.Fatalf("--users and --drivers can be at most %d", maxSeedAccounts)
+	}
+
+	db.Connect()
+	defer db.Close()
+	db.InitRedis()
+
+	// Seeded accounts belong to the default tenant
+	ctx := db.WithTenant(context.Background(), db.DefaultTenant)
+	area := area{lat: *lat, lng: *lng, radiusKm: *radiusKm, rng: rand.New(rand.NewPCG(*randSeed, *randSeed>>1))}
+
+	if !*geoOnly {
+		if err := seedUsers(ctx, *users); err != nil {
+			log.Fatalf("Seed riders: %v", err)
+		}
+		if err := seedDrivers(ctx, *drivers); err != nil {
+			log.Fatalf("Seed drivers: %v", err)
+		}
+	}
+	userIDs, err := seededIDs(ctx, `"user"`, userPhonePrefix)
+	if err != nil {
+		log.Fatalf("Load seeded riders: %v", err)
+	}
+	driverIDs, err := seededIDs(ctx, "driver", driverPhonePrefix)
+	if err != nil {
+		log.Fatalf("Load seeded drivers: %v", err)
+	}
+	fmt.Printf("riders   %d\ndrivers  %d\n", len(userIDs), len(driverIDs))
+
+	placed, err := placeDrivers(ctx, &area, driverIDs)
+	if err != nil {
+		log.Fatalf("Place drivers: %v", err)
+	}
+	fmt.Printf("geo      %d drivers around %.4f,%.4f\n", placed, *lat, *lng)
+
+	if *geoOnly || *rides == 0 {
+		return
+	}
+	if len(userIDs) == 0 || len(driverIDs) == 0 {
+		log.Fatalf("Rides need seeded riders and drivers")
+	}
+	created, err := seedRides(ctx, &area, *rides, *days, userIDs, driverIDs)
+	if err != nil {
+		log.Fatalf("Seed rides: %v", err)
+	}
+	fmt.Printf("rides    %d over %d days\n", created, *days)
+}
+
+func seedUsers(ctx context.Context, n int) error {
+	batch := &pgx.Batch{}
+	for i := 0; i < n; i++ {
+		batch.Queue(`INSERT INTO "user" (name, phone_number, email, ratings, "totalRides")
+			VALUES ($1, $2, $3, 4.5, 0) ON CONFLICT DO NOTHING`,
+			fmt.Sprintf("Seed Rider %d", i+1), fmt.Sprintf("%s%05d", userPhonePrefix, i),
+			fmt.Sprintf("seed-rider-%d@seed.ridewave.test", i+1))
+	}
+	return sendBatch(ctx, batch)
+}
+
+func seedDrivers(ctx context.Context, n int) error {
+	batch := &pgx.Batch{}
+	for i := 0; i < n; i++ {
+		batch.Queue(`INSERT INTO driver (name, country, phone_number, email, vehicle_type, registration_number,
+				registration_date, driving_license, rate, ratings, status, "isOnline")
+			VALUES ($1, 'India', $2, $3, $4, $5, '2022-01-01', $6, '0', 4.6, 'active', TRUE) ON CONFLICT DO NOTHING`,
+			fmt.Sprintf("Seed Driver %d", i+1), fmt.Sprintf("%s%05d", driverPhonePrefix, i),
+			fmt.Sprintf("seed-driver-%d@seed.ridewave.test", i+1), vehicleTypes[i%len(vehicleTypes)],
+			fmt.Sprintf("SEED%06d", i), fmt.Sprintf("SEED-DL-%06d", i))
+	}
+	return sendBatch(ctx, batch)
+}
+
+func sendBatch(ctx context.Context, batch *pgx.Batch) error {
+	results := db.Pool.SendBatch(ctx, batch)
+	defer results.Close()
+	for i := 0; i < batch.Len(); i++ {
+		if _, err := results.Exec(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func seededIDs(ctx context.Context, table, prefix string) ([]string, error) {
+	rows, err := db.Pool.Query(ctx, `SELECT id FROM `+table+` WHERE phone_number LIKE $1 || '%' ORDER BY phone_number`, prefix)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowTo[string])
+}
+
+// placeDrivers puts every driver at a random point in the area, as their app would on going online.
+func placeDrivers(ctx context.Context, a *area, driverIDs []string) (int, error) {
+	pipe := db.RedisClient.Pipeline()
+	for _, id := range driverIDs {
+		lat, lng := a.point()
+		pipe.GeoAdd(ctx, stores.DriverGeoKey, &redis.GeoLocation{Name: id, Latitude: lat, Longitude: lng})
+		val, _ := json.Marshal(stores.DriverLocation{Latitude: lat, Longitude: lng, DriverID: id})
+		pipe.Set(ctx, stores.DriverDataKeyPrefix+id, val, time.Hour)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return len(driverIDs), nil
+}
+
+// seedRides copies in past rides, mostly completed and paid and about one in ten cancelled, at
+// daytime hours so analytics and heatmaps have shape.
+func seedRides(ctx context.Context, a *area, n, days int, userIDs, driverIDs []string) (int64, error) {
+	now := time.Now()
+	rows := make([][]any, 0, n)
+	for i := 0; i < n; i++ {
+		originLat, originLng := a.point()
+		destLat, destLng := a.point()
+		meters := int(utils.CalculateDistance(originLat, originLng, destLat, destLng)*1000*1.3) + 500
+		seconds := meters/6 + 120
+		driver := a.rng.IntN(len(driverIDs))
+		vehicle := vehicleTypes[driver%len(vehicleTypes)] // seedDrivers' assignment
+		fare := math.Round(50 + float64(meters)/1000*12 + float64(seconds)/60*2)
+
+		day := now.AddDate(0, 0, -a.rng.IntN(days+1))
+		hour := 7 + a.rng.IntN(16)
+		createdAt := time.Date(day.Year(), day.Month(), day.Day(), hour, a.rng.IntN(60), a.rng.IntN(60), 0, day.Location())
+		if createdAt.After(now) {
+			createdAt = now.Add(-time.Duration(a.rng.IntN(3600)) * time.Second)
+		}
+		acceptedAt := createdAt.Add(time.Duration(20+a.rng.IntN(100)) * time.Second)
+
+		status, paymentStatus := "Completed", "Paid"
+		var startedAt, completedAt, cancelledAt *time.Time
+		if a.rng.IntN(10) == 0 {
+			status, paymentStatus = "Cancelled", "Pending"
+			t := acceptedAt.Add(time.Duration(a.rng.IntN(300)) * time.Second)
+			cancelledAt = &t
+		} else {
+			s := acceptedAt.Add(time.Duration(120+a.rng.IntN(480)) * time.Second)
+			e := s.Add(time.Duration(seconds) * time.Second)
+			startedAt, completedAt = &s, &e
+		}
+		updatedAt := acceptedAt
+		if completedAt != nil {
+			updatedAt = *completedAt
+		} else if cancelledAt != nil {
+			updatedAt = *cancelledAt
+		}
+
+		rows = append(rows, []any{
+			uuid.New().String(), userIDs[a.rng.IntN(len(userIDs))], driverIDs[driver], fare,
+			places[a.rng.IntN(len(places))], places[a.rng.IntN(len(places))], fmt.Sprintf("%d", meters), seconds, meters,
+			vehicle, status, "cash", paymentStatus, originLat, originLng, destLat, destLng,
+			acceptedAt, startedAt, completedAt, cancelledAt, createdAt, updatedAt,
+		})
+	}
+	return db.Pool.CopyFrom(ctx, pgx.Identifier{"rides"}, []string{
+		"id", "userId", "driverId", "charge", "currentLocationName", "destinationLocationName", "distance",
+		"estimatedDuration", "estimatedDistance", "vehicleType", "status", "paymentMode", "paymentStatus",
+		"originLat", "originLng", "destinationLat", "destinationLng",
+		"acceptedAt", "startedAt", "completedAt", "cancelledAt", "createdAt", "updatedAt",
+	}, pgx.CopyFromRows(rows))
+}
+
+// area picks uniformly distributed points within radiusKm of a centre.
+type area struct {
+	lat, lng, radiusKm float64
+	rng                *rand.Rand
+}
+
+func (a *area) point() (float64, float64) {
+	r := a.radiusKm * math.Sqrt(a.rng.Float64())
+	theta := 2 * math.Pi * a.rng.Float64()
+	dLat := r * math.Cos(theta) / 111.32
+	dLng := r * math.Sin(theta) / (111.32 * math.Cos(a.lat*math.Pi/180))
+	return a.lat + dLat, a.lng + dLng
+}
@@ -14,7 +14,6 @@ type Config struct {
 	TwilioSID    string
 	TwilioAuth   string
 	TwilioVerify string
-	AdminSecret  string
 	JWTSecret    string
 }
 
@@ -31,7 +30,6 @@ func LoadAndValidate() {
 		TwilioSID:    getReq("TWILIO_ACCOUNT_SID"),
 		TwilioAuth:   getReq("TWILIO_AUTH_TOKEN"),
 		TwilioVerify: getReq("TWILIO_VERIFY_SERVICE_SID"),
-		AdminSecret:  getReq("ADMIN_SECRET"),
 		JWTSecret:    getReq("JWT_SECRET"),
 	}
 }
@@ -0,0 +1,47 @@
+package db
+
+import (
+	"context"
+	"regexp"
+	"strings"
+)
+
+var (
+	createTablePattern = regexp.MustCompile(`(?i)CREATE TABLE IF NOT EXISTS ("?\w+"?)`)
+	addColumnPattern   = regexp.MustCompile(`(?i)ALTER TABLE ("?\w+"?) ADD COLUMN IF NOT EXISTS ("?\w+"?)`)
+)
+
+// PendingMigrations lists what a migration run would still change: tables and columns from
+// migrationSQL that don't exist yet, and schema change phases not yet applied.
+func PendingMigrations(ctx context.Context) ([]string, error) {
+	var pending []string
+
+	for _, m := range createTablePattern.FindAllStringSubmatch(migrationSQL, -1) {
+		var exists bool
+		if err := Pool.QueryRow(ctx, `SELECT to_regclass($1) IS NOT NULL`, m[1]).Scan(&exists); err != nil {
+			return nil, err
+		}
+		if !exists {
+			pending = append(pending, "table "+m[1])
+		}
+	}
+
+	for _, m := range addColumnPattern.FindAllStringSubmatch(migrationSQL, -1) {
+		table, column := strings.Trim(m[1], `"`), strings.Trim(m[2], `"`)
+		var exists bool
+		err := Pool.QueryRow(ctx,
+			`SELECT EXISTS(SELECT 1 FROM information_schema.columns WHERE table_schema=current_schema() AND table_name=$1 AND column_name=$2)`,
+			table, column).Scan(&exists)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			pending = append(pending, "column "+m[1]+"."+m[2])
+		}
+	}
+
+	for _, step := range pendingSteps(loadSchemaPhases()) {
+		pending = append(pending, "schema change "+step.Change.ID+" ("+step.Phase+")")
+	}
+	return pending, nil
+}
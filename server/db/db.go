@@ -2,21 +2,42 @@ package db
 
 import (
 	"context"
+	"fmt"
 	"log"
-	"os"
 
 	"github.com/jackc/pgx/v5/pgxpool"
+	"ridewave/chaos"
+	"ridewave/telemetry"
 )
 
 var Pool *pgxpool.Pool
 
 func Connect() {
-	var err error
-	Pool, err = pgxpool.New(context.Background(), os.Getenv("DATABASE_URL"))
+	if err := Open(); err != nil {
+		log.Fatalf("%v\n", err)
+	}
+	log.Printf("Connected to PostgreSQL database (region %s)\n", Region())
+}
+
+// Open creates Pool without exiting on failure (used by `server --check`).
+func Open() error {
+	cfg, err := pgxpool.ParseConfig(RegionEnv("DATABASE_URL"))
+	if err != nil {
+		return fmt.Errorf("Invalid database URL: %w", err)
+	}
+	if chaos.Allowed() {
+		cfg.ConnConfig.Tracer = chaos.PgTracer{}
+	}
+	if telemetry.Enabled() {
+		cfg.ConnConfig.Tracer = telemetry.PgTracer{Next: cfg.ConnConfig.Tracer}
+	}
+	cfg.BeforeAcquire = scopeConn
+	cfg.BeforeClose = forgetConn
+	Pool, err = pgxpool.NewWithConfig(context.Background(), cfg)
 	if err != nil {
-		log.Fatalf("Unable to connect to database: %v\n", err)
+		return fmt.Errorf("Unable to connect to database: %w", err)
 	}
-	log.Println("Connected to PostgreSQL database")
+	return nil
 }
 
 func Close() {
@@ -1,14 +1,11 @@
 package db
 
 import (
-	"context"
 	"log"
 )
 
-// Migrate creates all tables if they don't exist, adds columns, indexes, and seeds default data.
-// Safe to run multiple times — all operations are idempotent (IF NOT EXISTS / ON CONFLICT).
-func Migrate() {
-	sql := `
+// migrationSQL creates all tables if they don't exist, adds columns, indexes, and seeds default data.
+const migrationSQL = `
 	CREATE EXTENSION IF NOT EXISTS pgcrypto;
 
 	-- ═══════════════════════════════════════════
@@ -59,7 +56,6 @@ func Migrate() {
 	ALTER TABLE driver ADD COLUMN IF NOT EXISTS "profileImage" TEXT;
 	ALTER TABLE driver ADD COLUMN IF NOT EXISTS "rcBook" TEXT;
 	ALTER TABLE driver ADD COLUMN IF NOT EXISTS "isOnline" BOOLEAN NOT NULL DEFAULT FALSE;
-	ALTER TABLE driver ADD COLUMN IF NOT EXISTS "upi_id" TEXT;
 
 	-- ═══════════════════════════════════════════
 	-- RIDES TABLE — full ride lifecycle
@@ -163,7 +159,7 @@ func Migrate() {
 		(gen_random_uuid()::text, 'Car', 50.0, 12.0, 2.0, 'car'),
 		(gen_random_uuid()::text, 'Sedan', 65.0, 15.0, 2.5, 'sedan'),
 		(gen_random_uuid()::text, 'SUV', 80.0, 18.0, 3.0, 'suv')
-	ON CONFLICT (name) DO NOTHING;
+	ON CONFLICT DO NOTHING;
 
 	-- ═══════════════════════════════════════════
 	-- SOS ALERTS TABLE — safety audit trail
@@ -260,11 +256,1024 @@ func Migrate() {
 	);
 	CREATE INDEX IF NOT EXISTS idx_api_logs_requestid ON external_api_logs("requestId");
 	CREATE INDEX IF NOT EXISTS idx_api_logs_created ON external_api_logs("createdAt");
+
+	-- ═══════════════════════════════════════════
+	-- SCHEDULED RIDES TABLE — book-for-later & arrive-by
+	-- ═══════════════════════════════════════════
+	CREATE TABLE IF NOT EXISTS scheduled_rides (
+		id TEXT PRIMARY KEY DEFAULT gen_random_uuid()::text,
+		"userId" TEXT NOT NULL REFERENCES "user"(id),
+		"routeId" TEXT,
+		"vehicleType" TEXT NOT NULL,
+		"originName" TEXT NOT NULL,
+		"destinationName" TEXT NOT NULL,
+		"originLat" DOUBLE PRECISION NOT NULL,
+		"originLng" DOUBLE PRECISION NOT NULL,
+		"destinationLat" DOUBLE PRECISION NOT NULL,
+		"destinationLng" DOUBLE PRECISION NOT NULL,
+		fare DOUBLE PRECISION NOT NULL,
+		distance INTEGER NOT NULL DEFAULT 0,
+		duration INTEGER NOT NULL DEFAULT 0,
+		"paymentMode" TEXT,
+		"pickupAt" TIMESTAMPTZ NOT NULL,
+		"arriveBy" TIMESTAMPTZ,
+		"dispatchAt" TIMESTAMPTZ NOT NULL,
+		status TEXT NOT NULL DEFAULT 'scheduled',
+		"rideId" TEXT REFERENCES rides(id),
+		"createdAt" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		"updatedAt" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);
+	CREATE INDEX IF NOT EXISTS idx_scheduled_rides_dispatch ON scheduled_rides(status, "dispatchAt");
+	CREATE INDEX IF NOT EXISTS idx_scheduled_rides_user ON scheduled_rides("userId", "pickupAt");
+
+	-- ═══════════════════════════════════════════
+	-- RIDE ANOMALIES TABLE — stuck/overrun rides for admin review
+	-- ═══════════════════════════════════════════
+	ALTER TABLE rides ADD COLUMN IF NOT EXISTS "autoCompleted" BOOLEAN NOT NULL DEFAULT FALSE;
+
+	CREATE TABLE IF NOT EXISTS ride_anomalies (
+		id TEXT PRIMARY KEY DEFAULT gen_random_uuid()::text,
+		"rideId" TEXT NOT NULL REFERENCES rides(id),
+		type TEXT NOT NULL,
+		details TEXT,
+		status TEXT NOT NULL DEFAULT 'open',
+		"resolvedAt" TIMESTAMPTZ,
+		"createdAt" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		UNIQUE ("rideId", type)
+	);
+	CREATE INDEX IF NOT EXISTS idx_ride_anomalies_status ON ride_anomalies(status, "createdAt");
+
+	-- ═══════════════════════════════════════════
+	-- DRIVER WALLETS — earnings ledger & payouts
+	-- ═══════════════════════════════════════════
+	CREATE TABLE IF NOT EXISTS wallets (
+		id TEXT PRIMARY KEY DEFAULT gen_random_uuid()::text,
+		"driverId" TEXT NOT NULL UNIQUE REFERENCES driver(id),
+		balance DOUBLE PRECISION NOT NULL DEFAULT 0,
+		"totalEarned" DOUBLE PRECISION NOT NULL DEFAULT 0,
+		"totalCommission" DOUBLE PRECISION NOT NULL DEFAULT 0,
+		"totalPaidOut" DOUBLE PRECISION NOT NULL DEFAULT 0,
+		"createdAt" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		"updatedAt" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);
+
+	CREATE TABLE IF NOT EXISTS wallet_transactions (
+		id TEXT PRIMARY KEY DEFAULT gen_random_uuid()::text,
+		"walletId" TEXT NOT NULL REFERENCES wallets(id),
+		"driverId" TEXT NOT NULL REFERENCES driver(id),
+		"rideId" TEXT REFERENCES rides(id),
+		type TEXT NOT NULL,
+		amount DOUBLE PRECISION NOT NULL,
+		commission DOUBLE PRECISION NOT NULL DEFAULT 0,
+		"balanceAfter" DOUBLE PRECISION NOT NULL,
+		reference TEXT,
+		"createdAt" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);
+	CREATE INDEX IF NOT EXISTS idx_wallet_transactions_driver ON wallet_transactions("driverId", "createdAt");
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_wallet_transactions_ride ON wallet_transactions("rideId", type) WHERE "rideId" IS NOT NULL;
+
+	-- PAYMENTS DEDUPE — one fare per ride: see ChangePaymentsDedupe in schema_changes.go
+
+	-- ═══════════════════════════════════════════
+	-- PROMO REDEMPTION — applied promo on the ride
+	-- ═══════════════════════════════════════════
+	ALTER TABLE rides ADD COLUMN IF NOT EXISTS "promoCode" TEXT;
+	ALTER TABLE rides ADD COLUMN IF NOT EXISTS discount DOUBLE PRECISION NOT NULL DEFAULT 0;
+	ALTER TABLE rides ADD COLUMN IF NOT EXISTS "originalFare" DOUBLE PRECISION;
+
+	-- ═══════════════════════════════════════════
+	-- VEHICLE TYPE AVAILABILITY — per-zone & time window
+	-- ═══════════════════════════════════════════
+	ALTER TABLE vehicle_types ADD COLUMN IF NOT EXISTS "allowedZones" TEXT[] NOT NULL DEFAULT '{}';
+	ALTER TABLE vehicle_types ADD COLUMN IF NOT EXISTS "availableFrom" TEXT;
+	ALTER TABLE vehicle_types ADD COLUMN IF NOT EXISTS "availableUntil" TEXT;
+
+	-- ═══════════════════════════════════════════
+	-- ZONE SOFT LAUNCH — beta mode & rider allowlist
+	-- ═══════════════════════════════════════════
+	CREATE TABLE IF NOT EXISTS zone_launch_modes (
+		zone TEXT PRIMARY KEY,
+		mode TEXT NOT NULL DEFAULT 'live',
+		"updatedAt" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);
+
+	CREATE TABLE IF NOT EXISTS zone_allowlist (
+		zone TEXT NOT NULL,
+		phone_number TEXT NOT NULL,
+		note TEXT,
+		"createdAt" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		PRIMARY KEY (zone, phone_number)
+	);
+
+	-- ═══════════════════════════════════════════
+	-- RATING CONFIG — server-driven rating prompts
+	-- ═══════════════════════════════════════════
+	CREATE TABLE IF NOT EXISTS rating_config (
+		id TEXT PRIMARY KEY DEFAULT gen_random_uuid()::text,
+		audience TEXT NOT NULL,
+		"vehicleType" TEXT NOT NULL DEFAULT '',
+		"mandatoryBelow" DOUBLE PRECISION NOT NULL DEFAULT 0,
+		"requireComment" BOOLEAN NOT NULL DEFAULT FALSE,
+		"updatedAt" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		UNIQUE (audience, "vehicleType")
+	);
+
+	CREATE TABLE IF NOT EXISTS rating_tags (
+		id TEXT PRIMARY KEY DEFAULT gen_random_uuid()::text,
+		audience TEXT NOT NULL,
+		"vehicleType" TEXT NOT NULL DEFAULT '',
+		key TEXT NOT NULL,
+		labels JSONB NOT NULL DEFAULT '{}',
+		"minRating" DOUBLE PRECISION NOT NULL DEFAULT 1,
+		"maxRating" DOUBLE PRECISION NOT NULL DEFAULT 5,
+		"sortOrder" INTEGER NOT NULL DEFAULT 0,
+		"isActive" BOOLEAN NOT NULL DEFAULT TRUE,
+		"createdAt" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		UNIQUE (audience, "vehicleType", key)
+	);
+
+	ALTER TABLE rides ADD COLUMN IF NOT EXISTS "ratingTags" TEXT[];
+	ALTER TABLE rides ADD COLUMN IF NOT EXISTS "ratingComment" TEXT;
+	ALTER TABLE rides ADD COLUMN IF NOT EXISTS "userRating" DOUBLE PRECISION;
+	ALTER TABLE rides ADD COLUMN IF NOT EXISTS "userRatingTags" TEXT[];
+	ALTER TABLE rides ADD COLUMN IF NOT EXISTS "userRatingComment" TEXT;
+
+	-- ═══════════════════════════════════════════
+	-- REFUNDS — full/partial refunds against payments
+	-- ═══════════════════════════════════════════
+	CREATE TABLE IF NOT EXISTS refunds (
+		id TEXT PRIMARY KEY DEFAULT gen_random_uuid()::text,
+		"rideId" TEXT NOT NULL REFERENCES rides(id),
+		"paymentId" TEXT NOT NULL REFERENCES payments(id),
+		amount DOUBLE PRECISION NOT NULL,
+		reason TEXT,
+		status TEXT NOT NULL DEFAULT 'pending',
+		reference TEXT,
+		"processedAt" TIMESTAMPTZ,
+		"createdAt" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);
+	CREATE INDEX IF NOT EXISTS idx_refunds_ride ON refunds("rideId");
+	CREATE INDEX IF NOT EXISTS idx_refunds_status ON refunds(status, "createdAt");
+
+	-- ═══════════════════════════════════════════
+	-- MARKETING CONSENT — per-channel opt-in/out (DND/TRAI)
+	-- ═══════════════════════════════════════════
+	CREATE TABLE IF NOT EXISTS marketing_consent (
+		"userId" TEXT NOT NULL REFERENCES "user"(id),
+		channel TEXT NOT NULL,
+		status TEXT NOT NULL,
+		source TEXT NOT NULL,
+		"updatedAt" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		PRIMARY KEY ("userId", channel)
+	);
+
+	-- Append-only audit trail of every consent change
+	CREATE TABLE IF NOT EXISTS marketing_consent_log (
+		id TEXT PRIMARY KEY DEFAULT gen_random_uuid()::text,
+		"userId" TEXT NOT NULL REFERENCES "user"(id),
+		channel TEXT NOT NULL,
+		status TEXT NOT NULL,
+		source TEXT NOT NULL,
+		"ipAddress" TEXT,
+		"createdAt" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);
+	CREATE INDEX IF NOT EXISTS idx_consent_log_created ON marketing_consent_log("createdAt");
+
+	-- ═══════════════════════════════════════════
+	-- LANGUAGES — driver spoken languages & rider preference
+	-- ═══════════════════════════════════════════
+	ALTER TABLE driver ADD COLUMN IF NOT EXISTS languages TEXT[] NOT NULL DEFAULT '{}';
+	ALTER TABLE "user" ADD COLUMN IF NOT EXISTS "preferredLanguage" TEXT;
+
+	-- ═══════════════════════════════════════════
+	-- ADMIN NOTES — internal annotations on users, drivers & rides
+	-- ═══════════════════════════════════════════
+	CREATE TABLE IF NOT EXISTS admin_notes (
+		id TEXT PRIMARY KEY DEFAULT gen_random_uuid()::text,
+		"entityType" TEXT NOT NULL,
+		"entityId" TEXT NOT NULL,
+		note TEXT NOT NULL DEFAULT '',
+		tags TEXT[] NOT NULL DEFAULT '{}',
+		author TEXT NOT NULL DEFAULT '',
+		"createdAt" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		"updatedAt" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);
+	CREATE INDEX IF NOT EXISTS idx_admin_notes_entity ON admin_notes("entityType", "entityId");
+	CREATE INDEX IF NOT EXISTS idx_admin_notes_tags ON admin_notes USING GIN (tags);
+
+	-- ═══════════════════════════════════════════
+	-- ADMIN ACCOUNTS — per-person logins with role scopes
+	-- ═══════════════════════════════════════════
+	CREATE TABLE IF NOT EXISTS admin_accounts (
+		id TEXT PRIMARY KEY DEFAULT gen_random_uuid()::text,
+		email TEXT UNIQUE NOT NULL,
+		name TEXT NOT NULL DEFAULT '',
+		"passwordHash" TEXT NOT NULL,
+		role TEXT NOT NULL DEFAULT 'support',
+		"isActive" BOOLEAN NOT NULL DEFAULT TRUE,
+		"lastLoginAt" TIMESTAMPTZ,
+		"createdAt" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		"updatedAt" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);
+
+	-- ═══════════════════════════════════════════
+	-- DUPLICATE ACCOUNTS — device fingerprints, detected clusters & review audit
+	-- ═══════════════════════════════════════════
+	CREATE TABLE IF NOT EXISTS account_devices (
+		"entityType" TEXT NOT NULL,
+		"entityId" TEXT NOT NULL,
+		fingerprint TEXT NOT NULL,
+		"firstSeenAt" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		"lastSeenAt" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		PRIMARY KEY ("entityType", "entityId", fingerprint)
+	);
+	CREATE INDEX IF NOT EXISTS idx_account_devices_fingerprint ON account_devices("entityType", fingerprint);
+
+	CREATE TABLE IF NOT EXISTS duplicate_clusters (
+		id TEXT PRIMARY KEY DEFAULT gen_random_uuid()::text,
+		"entityType" TEXT NOT NULL,
+		signal TEXT NOT NULL,
+		value TEXT NOT NULL,
+		"entityIds" TEXT[] NOT NULL,
+		status TEXT NOT NULL DEFAULT 'open',
+		"keptId" TEXT,
+		"detectedAt" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		"resolvedAt" TIMESTAMPTZ,
+		UNIQUE ("entityType", signal, value)
+	);
+	CREATE INDEX IF NOT EXISTS idx_duplicate_clusters_status ON duplicate_clusters(status);
+
+	CREATE TABLE IF NOT EXISTS duplicate_audit_log (
+		id TEXT PRIMARY KEY DEFAULT gen_random_uuid()::text,
+		"clusterId" TEXT NOT NULL REFERENCES duplicate_clusters(id),
+		action TEXT NOT NULL,
+		"adminId" TEXT,
+		"adminEmail" TEXT,
+		details TEXT NOT NULL DEFAULT '',
+		"createdAt" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);
+
+	-- ═══════════════════════════════════════════
+	-- RIDE TRACKS — actual GPS trail of each trip (for disputes & GIS export)
+	-- ═══════════════════════════════════════════
+	CREATE TABLE IF NOT EXISTS ride_tracks (
+		"rideId" TEXT PRIMARY KEY REFERENCES rides(id),
+		points JSONB NOT NULL DEFAULT '[]',
+		"createdAt" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);
+
+	-- ═══════════════════════════════════════════
+	-- RIDE DECLINES — drivers passing on requests (feeds acceptance rate)
+	-- ═══════════════════════════════════════════
+	ALTER TABLE driver ADD COLUMN IF NOT EXISTS "declineCount" INT NOT NULL DEFAULT 0;
+	CREATE TABLE IF NOT EXISTS ride_declines (
+		id TEXT PRIMARY KEY DEFAULT gen_random_uuid()::text,
+		"rideId" TEXT NOT NULL REFERENCES rides(id),
+		"driverId" TEXT NOT NULL REFERENCES driver(id),
+		reason TEXT NOT NULL,
+		"createdAt" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		UNIQUE ("rideId", "driverId")
+	);
+	CREATE INDEX IF NOT EXISTS idx_ride_declines_driver ON ride_declines("driverId", "createdAt");
+
+	-- ═══════════════════════════════════════════
+	-- BACKUP RUNS — encrypted Postgres/Redis backups shipped to object storage
+	-- ═══════════════════════════════════════════
+	CREATE TABLE IF NOT EXISTS backup_runs (
+		id TEXT PRIMARY KEY DEFAULT gen_random_uuid()::text,
+		kind TEXT NOT NULL,
+		region TEXT NOT NULL,
+		status TEXT NOT NULL,
+		object TEXT NOT NULL DEFAULT '',
+		"sizeBytes" BIGINT NOT NULL DEFAULT 0,
+		sha256 TEXT NOT NULL DEFAULT '',
+		error TEXT NOT NULL DEFAULT '',
+		"startedAt" TIMESTAMPTZ NOT NULL,
+		"finishedAt" TIMESTAMPTZ NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_backup_runs_started ON backup_runs("startedAt" DESC);
+
+	-- ═══════════════════════════════════════════
+	-- RIDE POOLING — shared rides and their ordered stops
+	-- ═══════════════════════════════════════════
+	INSERT INTO vehicle_types (id, name, "baseFare", "perKmRate", "perMinRate", icon) VALUES
+		(gen_random_uuid()::text, 'Pool', 35.0, 9.0, 1.5, 'pool')
+	ON CONFLICT DO NOTHING;
+
+	ALTER TABLE rides ADD COLUMN IF NOT EXISTS "poolId" TEXT;
+	CREATE INDEX IF NOT EXISTS idx_rides_pool ON rides("poolId") WHERE "poolId" IS NOT NULL;
+
+	CREATE TABLE IF NOT EXISTS ride_legs (
+		id TEXT PRIMARY KEY DEFAULT gen_random_uuid()::text,
+		"poolId" TEXT NOT NULL,
+		"rideId" TEXT NOT NULL REFERENCES rides(id) ON DELETE CASCADE,
+		kind TEXT NOT NULL,
+		seq INT NOT NULL,
+		lat DOUBLE PRECISION NOT NULL,
+		lng DOUBLE PRECISION NOT NULL,
+		"completedAt" TIMESTAMPTZ,
+		UNIQUE("rideId", kind)
+	);
+	CREATE INDEX IF NOT EXISTS idx_ride_legs_pool ON ride_legs("poolId", seq);
+
+	-- ═══════════════════════════════════════════
+	-- RIDE STOPS — ordered waypoints on multi-stop rides
+	-- ═══════════════════════════════════════════
+	CREATE TABLE IF NOT EXISTS ride_stops (
+		id TEXT PRIMARY KEY DEFAULT gen_random_uuid()::text,
+		"rideId" TEXT NOT NULL REFERENCES rides(id) ON DELETE CASCADE,
+		seq INT NOT NULL,
+		name TEXT NOT NULL,
+		lat DOUBLE PRECISION NOT NULL,
+		lng DOUBLE PRECISION NOT NULL,
+		"completedAt" TIMESTAMPTZ,
+		UNIQUE("rideId", seq)
+	);
+
+	-- ═══════════════════════════════════════════
+	-- SAVED PLACES — home, work & custom favourites for one-tap booking
+	-- ═══════════════════════════════════════════
+	CREATE TABLE IF NOT EXISTS saved_places (
+		id TEXT PRIMARY KEY DEFAULT gen_random_uuid()::text,
+		"userId" TEXT NOT NULL REFERENCES "user"(id) ON DELETE CASCADE,
+		label TEXT NOT NULL,
+		name TEXT NOT NULL,
+		address TEXT NOT NULL DEFAULT '',
+		lat DOUBLE PRECISION NOT NULL,
+		lng DOUBLE PRECISION NOT NULL,
+		"createdAt" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		"updatedAt" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);
+	CREATE INDEX IF NOT EXISTS idx_saved_places_user ON saved_places("userId");
+	-- A rider has at most one home and one work
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_saved_places_home_work ON saved_places("userId", label) WHERE label IN ('home', 'work');
+
+	-- ═══════════════════════════════════════════
+	-- TENANTS — white-label operators sharing this deployment
+	-- ═══════════════════════════════════════════
+	CREATE TABLE IF NOT EXISTS tenants (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL,
+		"apiKeyHash" TEXT UNIQUE,
+		domains TEXT[] NOT NULL DEFAULT '{}',
+		branding JSONB NOT NULL DEFAULT '{}',
+		zones TEXT[] NOT NULL DEFAULT '{}',
+		"isActive" BOOLEAN NOT NULL DEFAULT TRUE,
+		"createdAt" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		"updatedAt" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);
+	INSERT INTO tenants (id, name) VALUES ('default', 'RideWave') ON CONFLICT (id) DO NOTHING;
+
+	-- ═══════════════════════════════════════════
+	-- RIDE EVENTS — per-ride lifecycle timeline
+	-- ═══════════════════════════════════════════
+	CREATE TABLE IF NOT EXISTS ride_events (
+		id BIGSERIAL PRIMARY KEY,
+		"rideId" TEXT NOT NULL REFERENCES rides(id) ON DELETE CASCADE,
+		type TEXT NOT NULL,
+		"actorType" TEXT NOT NULL,
+		"actorId" TEXT,
+		data JSONB NOT NULL DEFAULT '{}',
+		"createdAt" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);
+	CREATE INDEX IF NOT EXISTS idx_ride_events_ride ON ride_events("rideId", "createdAt");
+
+	-- ═══════════════════════════════════════════
+	-- DRIVER DIAGNOSTICS — latest app heartbeat per driver
+	-- ═══════════════════════════════════════════
+	CREATE TABLE IF NOT EXISTS driver_diagnostics (
+		"driverId" TEXT PRIMARY KEY REFERENCES driver(id) ON DELETE CASCADE,
+		"batteryLevel" INT,
+		"isCharging" BOOLEAN,
+		"gpsAccuracy" DOUBLE PRECISION,
+		"networkType" TEXT NOT NULL DEFAULT 'unknown',
+		"appVersion" TEXT NOT NULL DEFAULT '',
+		platform TEXT NOT NULL DEFAULT '',
+		"osVersion" TEXT NOT NULL DEFAULT '',
+		"reportedAt" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);
+
+	-- ═══════════════════════════════════════════
+	-- VEHICLE TYPE DISPLAY — uploaded icons & client-facing metadata
+	-- ═══════════════════════════════════════════
+	CREATE TABLE IF NOT EXISTS vehicle_type_icons (
+		id TEXT PRIMARY KEY DEFAULT gen_random_uuid()::text,
+		"contentType" TEXT NOT NULL,
+		data BYTEA NOT NULL,
+		"createdAt" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);
+	ALTER TABLE vehicle_types ADD COLUMN IF NOT EXISTS "iconAssetId" TEXT REFERENCES vehicle_type_icons(id) ON DELETE SET NULL;
+	ALTER TABLE vehicle_types ADD COLUMN IF NOT EXISTS capacity INT;
+	ALTER TABLE vehicle_types ADD COLUMN IF NOT EXISTS description TEXT NOT NULL DEFAULT '';
+	ALTER TABLE vehicle_types ADD COLUMN IF NOT EXISTS "etaBlurb" TEXT NOT NULL DEFAULT '';
+
+	-- ═══════════════════════════════════════════
+	-- ADMIN SEARCH — trigram indexes behind the global search bar
+	-- ═══════════════════════════════════════════
+	CREATE EXTENSION IF NOT EXISTS pg_trgm;
+	CREATE INDEX IF NOT EXISTS idx_user_name_trgm ON "user" USING gin (name gin_trgm_ops);
+	CREATE INDEX IF NOT EXISTS idx_user_phone_trgm ON "user" USING gin (phone_number gin_trgm_ops);
+	CREATE INDEX IF NOT EXISTS idx_driver_name_trgm ON driver USING gin (name gin_trgm_ops);
+	CREATE INDEX IF NOT EXISTS idx_driver_phone_trgm ON driver USING gin (phone_number gin_trgm_ops);
+	CREATE INDEX IF NOT EXISTS idx_driver_registration_trgm ON driver USING gin (registration_number gin_trgm_ops);
+	CREATE INDEX IF NOT EXISTS idx_rides_id_trgm ON rides USING gin (id gin_trgm_ops);
+	CREATE INDEX IF NOT EXISTS idx_rides_promo_trgm ON rides USING gin ("promoCode" gin_trgm_ops);
+	CREATE INDEX IF NOT EXISTS idx_payments_rideid_trgm ON payments USING gin ("rideId" gin_trgm_ops);
+	CREATE INDEX IF NOT EXISTS idx_sos_rideid_trgm ON sos_alerts USING gin ("rideId" gin_trgm_ops);
+	CREATE INDEX IF NOT EXISTS idx_promo_code_trgm ON promo_codes USING gin (code gin_trgm_ops);
+
+	-- ═══════════════════════════════════════════
+	-- RIDE CANCELLATIONS — who cancelled, why, and whether the ride had been accepted
+	-- ═══════════════════════════════════════════
+	CREATE TABLE IF NOT EXISTS ride_cancellations (
+		id TEXT PRIMARY KEY DEFAULT gen_random_uuid()::text,
+		"rideId" TEXT NOT NULL REFERENCES rides(id) ON DELETE CASCADE,
+		actor TEXT NOT NULL,
+		"actorId" TEXT NOT NULL DEFAULT '',
+		reason TEXT NOT NULL DEFAULT '',
+		"afterAccept" BOOLEAN NOT NULL DEFAULT FALSE,
+		"createdAt" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);
+	CREATE INDEX IF NOT EXISTS idx_ride_cancellations_actor ON ride_cancellations(actor, "actorId", "createdAt");
+	CREATE INDEX IF NOT EXISTS idx_ride_cancellations_ride ON ride_cancellations("rideId");
+
+	-- ═══════════════════════════════════════════
+	-- DRIVER TRAINING — onboarding modules with a quiz new drivers must pass before approval
+	-- ═══════════════════════════════════════════
+	CREATE TABLE IF NOT EXISTS training_modules (
+		id TEXT PRIMARY KEY DEFAULT gen_random_uuid()::text,
+		title TEXT NOT NULL,
+		content TEXT NOT NULL DEFAULT '',
+		"videoUrl" TEXT NOT NULL DEFAULT '',
+		questions JSONB NOT NULL DEFAULT '[]',
+		"passScore" INT NOT NULL DEFAULT 80,
+		"sortOrder" INT NOT NULL DEFAULT 0,
+		"isActive" BOOLEAN NOT NULL DEFAULT TRUE,
+		"createdAt" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		"updatedAt" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);
+
+	CREATE TABLE IF NOT EXISTS driver_training_attempts (
+		id TEXT PRIMARY KEY DEFAULT gen_random_uuid()::text,
+		"driverId" TEXT NOT NULL REFERENCES driver(id) ON DELETE CASCADE,
+		"moduleId" TEXT NOT NULL REFERENCES training_modules(id) ON DELETE CASCADE,
+		score INT NOT NULL,
+		passed BOOLEAN NOT NULL,
+		answers JSONB NOT NULL DEFAULT '[]',
+		"createdAt" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);
+	CREATE INDEX IF NOT EXISTS idx_training_attempts_driver ON driver_training_attempts("driverId", "moduleId");
+
+	-- ═══════════════════════════════════════════
+	-- RIDE BIDDING — rider fare offers and driver bids in low-density zones
+	-- ═══════════════════════════════════════════
+	CREATE TABLE IF NOT EXISTS fare_offers (
+		id TEXT PRIMARY KEY DEFAULT gen_random_uuid()::text,
+		"userId" TEXT NOT NULL REFERENCES "user"(id) ON DELETE CASCADE,
+		zone TEXT NOT NULL,
+		"routeId" TEXT NOT NULL,
+		route JSONB NOT NULL,
+		"paymentMode" TEXT NOT NULL DEFAULT '',
+		"estimatedFare" DOUBLE PRECISION NOT NULL,
+		"suggestedFare" DOUBLE PRECISION NOT NULL,
+		"minFare" DOUBLE PRECISION NOT NULL,
+		"maxFare" DOUBLE PRECISION NOT NULL,
+		"notifiedDrivers" TEXT[] NOT NULL DEFAULT '{}',
+		status TEXT NOT NULL DEFAULT 'open', -- open | accepted | cancelled | expired
+		"rideId" TEXT REFERENCES rides(id) ON DELETE SET NULL,
+		"expiresAt" TIMESTAMPTZ NOT NULL,
+		"createdAt" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		"updatedAt" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);
+	CREATE INDEX IF NOT EXISTS idx_fare_offers_user ON fare_offers("userId", "createdAt");
+	CREATE INDEX IF NOT EXISTS idx_fare_offers_open ON fare_offers("expiresAt") WHERE status='open';
+
+	CREATE TABLE IF NOT EXISTS fare_bids (
+		id TEXT PRIMARY KEY DEFAULT gen_random_uuid()::text,
+		"offerId" TEXT NOT NULL REFERENCES fare_offers(id) ON DELETE CASCADE,
+		"driverId" TEXT NOT NULL REFERENCES driver(id) ON DELETE CASCADE,
+		amount DOUBLE PRECISION NOT NULL,
+		kind TEXT NOT NULL, -- accept | counter
+		status TEXT NOT NULL DEFAULT 'pending', -- pending | won | lost
+		"createdAt" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		"updatedAt" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		UNIQUE ("offerId", "driverId")
+	);
+
+	-- ═══════════════════════════════════════════
+	-- CARBON FOOTPRINT — EV vehicle classes and per-ride CO2 estimates
+	-- ═══════════════════════════════════════════
+	ALTER TABLE vehicle_types ADD COLUMN IF NOT EXISTS "isElectric" BOOLEAN NOT NULL DEFAULT FALSE;
+	ALTER TABLE vehicle_types ADD COLUMN IF NOT EXISTS "emissionFactor" DOUBLE PRECISION; -- g CO2/km, NULL = default
+	ALTER TABLE rides ADD COLUMN IF NOT EXISTS "isElectric" BOOLEAN NOT NULL DEFAULT FALSE;
+	ALTER TABLE rides ADD COLUMN IF NOT EXISTS "co2Grams" DOUBLE PRECISION;
+	ALTER TABLE rides ADD COLUMN IF NOT EXISTS "co2SavedGrams" DOUBLE PRECISION NOT NULL DEFAULT 0;
+	CREATE INDEX IF NOT EXISTS idx_rides_user_co2 ON rides("userId") WHERE "co2Grams" IS NOT NULL;
+
+	-- ═══════════════════════════════════════════
+	-- FLEETS — owners managing several drivers, taking a cut of their earnings
+	-- ═══════════════════════════════════════════
+	CREATE TABLE IF NOT EXISTS fleets (
+		id TEXT PRIMARY KEY DEFAULT gen_random_uuid()::text,
+		name TEXT NOT NULL,
+		"ownerName" TEXT NOT NULL,
+		"phoneNumber" TEXT NOT NULL,
+		email TEXT NOT NULL DEFAULT '',
+		"commissionPercent" DOUBLE PRECISION NOT NULL DEFAULT 0, -- of the driver's earning after platform commission
+		status TEXT NOT NULL DEFAULT 'active', -- active | suspended
+		"totalEarned" DOUBLE PRECISION NOT NULL DEFAULT 0,
+		"createdAt" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		"updatedAt" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);
+	ALTER TABLE driver ADD COLUMN IF NOT EXISTS "fleetId" TEXT REFERENCES fleets(id);
+	CREATE INDEX IF NOT EXISTS idx_driver_fleet ON driver("fleetId") WHERE "fleetId" IS NOT NULL;
+	ALTER TABLE wallet_transactions ADD COLUMN IF NOT EXISTS "fleetId" TEXT REFERENCES fleets(id);
+	ALTER TABLE wallet_transactions ADD COLUMN IF NOT EXISTS "fleetCommission" DOUBLE PRECISION NOT NULL DEFAULT 0;
+	CREATE INDEX IF NOT EXISTS idx_wallet_transactions_fleet ON wallet_transactions("fleetId", "createdAt") WHERE "fleetId" IS NOT NULL;
+
+	-- ═══════════════════════════════════════════
+	-- SERVICE ZONES — editable at runtime, circle or polygon
+	-- ═══════════════════════════════════════════
+	CREATE TABLE IF NOT EXISTS service_zones (
+		name TEXT PRIMARY KEY,
+		lat DOUBLE PRECISION NOT NULL,
+		lng DOUBLE PRECISION NOT NULL,
+		radius DOUBLE PRECISION NOT NULL DEFAULT 0, -- KM around lat/lng, used when there is no polygon
+		polygon JSONB, -- [[lat, lng], ...]
+		"launchMode" TEXT NOT NULL DEFAULT 'live',
+		"geofenceId" TEXT, -- Ola Maps geofence mirroring the zone
+		"sortOrder" INTEGER NOT NULL DEFAULT 0,
+		"isActive" BOOLEAN NOT NULL DEFAULT TRUE,
+		"createdAt" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		"updatedAt" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);
+
+	-- ═══════════════════════════════════════════
+	-- DROP-OFF PHOTOS — optional proof of drop-off, GPS-checked for disputes
+	-- ═══════════════════════════════════════════
+	CREATE TABLE IF NOT EXISTS ride_dropoff_photos (
+		"rideId" TEXT PRIMARY KEY REFERENCES rides(id) ON DELETE CASCADE,
+		"driverId" TEXT NOT NULL REFERENCES driver(id),
+		"contentType" TEXT NOT NULL,
+		data BYTEA NOT NULL,
+		note TEXT NOT NULL DEFAULT '',
+		"photoLat" DOUBLE PRECISION, -- from EXIF GPS
+		"photoLng" DOUBLE PRECISION,
+		"takenAt" TIMESTAMP, -- EXIF capture time, camera clock
+		"distanceMeters" INT, -- photo position to the drop-off
+		"gpsCheck" TEXT NOT NULL, -- match | mismatch | no_gps
+		"createdAt" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);
+
+	-- ═══════════════════════════════════════════
+	-- ADMIN EXPORTS — background CSV export jobs
+	-- ═══════════════════════════════════════════
+	CREATE TABLE IF NOT EXISTS admin_exports (
+		id TEXT PRIMARY KEY DEFAULT gen_random_uuid()::text,
+		entity TEXT NOT NULL, -- rides | payments | users | drivers
+		"fromDate" TIMESTAMPTZ NOT NULL,
+		"toDate" TIMESTAMPTZ NOT NULL, -- exclusive
+		status TEXT NOT NULL DEFAULT 'running', -- running | done | failed
+		"rowCount" INT NOT NULL DEFAULT 0,
+		data BYTEA, -- gzipped CSV
+		error TEXT,
+		"requestedBy" TEXT NOT NULL,
+		"createdAt" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		"finishedAt" TIMESTAMPTZ,
+		"expiresAt" TIMESTAMPTZ
+	);
+
+	-- ═══════════════════════════════════════════
+	-- ACCOUNT DELETION — GDPR erasure requests with a grace period
+	-- ═══════════════════════════════════════════
+	CREATE TABLE IF NOT EXISTS account_deletions (
+		id TEXT PRIMARY KEY DEFAULT gen_random_uuid()::text,
+		"accountType" TEXT NOT NULL, -- user | driver
+		"accountId" TEXT NOT NULL,
+		reason TEXT NOT NULL DEFAULT '',
+		status TEXT NOT NULL DEFAULT 'pending', -- pending | cancelled | completed
+		"scheduledFor" TIMESTAMPTZ NOT NULL, -- end of the grace period
+		"processedAt" TIMESTAMPTZ,
+		"processedBy" TEXT, -- admin email, "system" or the account itself when cancelled
+		note TEXT NOT NULL DEFAULT '',
+		"createdAt" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_account_deletions_pending ON account_deletions("accountType", "accountId") WHERE status='pending';
+	CREATE INDEX IF NOT EXISTS idx_account_deletions_due ON account_deletions("scheduledFor") WHERE status='pending';
+
+	-- ═══════════════════════════════════════════
+	-- RIDE INVOICES — fare breakdown snapshot taken at completion
+	-- ═══════════════════════════════════════════
+	CREATE SEQUENCE IF NOT EXISTS ride_invoice_seq;
+	CREATE TABLE IF NOT EXISTS ride_invoices (
+		"rideId" TEXT PRIMARY KEY REFERENCES rides(id) ON DELETE CASCADE,
+		"invoiceNumber" TEXT UNIQUE NOT NULL,
+		"baseFare" DOUBLE PRECISION NOT NULL DEFAULT 0,
+		"distanceFare" DOUBLE PRECISION NOT NULL DEFAULT 0,
+		"timeFare" DOUBLE PRECISION NOT NULL DEFAULT 0,
+		surge DOUBLE PRECISION NOT NULL DEFAULT 0, -- surge, bid and rounding adjustments
+		"platformFee" DOUBLE PRECISION NOT NULL DEFAULT 0,
+		discount DOUBLE PRECISION NOT NULL DEFAULT 0,
+		"promoCode" TEXT,
+		"issuedAt" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		"emailedAt" TIMESTAMPTZ
+	);
+
+	-- ═══════════════════════════════════════════
+	-- DRIVER SESSIONS — online/offline spans for online hours & utilization
+	-- ═══════════════════════════════════════════
+	CREATE TABLE IF NOT EXISTS driver_sessions (
+		id TEXT PRIMARY KEY DEFAULT gen_random_uuid()::text,
+		"driverId" TEXT NOT NULL REFERENCES driver(id) ON DELETE CASCADE,
+		"startedAt" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		"endedAt" TIMESTAMPTZ -- NULL while the driver is online
+	);
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_driver_sessions_open ON driver_sessions("driverId") WHERE "endedAt" IS NULL;
+	CREATE INDEX IF NOT EXISTS idx_driver_sessions_driver ON driver_sessions("driverId", "startedAt" DESC);
+	-- Every path that flips "isOnline" (toggle, suspension, deletion, ...) opens or closes a session
+	CREATE OR REPLACE FUNCTION track_driver_session() RETURNS trigger AS $$
+	BEGIN
+		IF NEW."isOnline" THEN
+			INSERT INTO driver_sessions ("driverId") VALUES (NEW.id) ON CONFLICT DO NOTHING;
+		ELSE
+			UPDATE driver_sessions SET "endedAt"=NOW() WHERE "driverId"=NEW.id AND "endedAt" IS NULL;
+		END IF;
+		RETURN NEW;
+	END $$ LANGUAGE plpgsql;
+	DROP TRIGGER IF EXISTS driver_session_tracking ON driver;
+	CREATE TRIGGER driver_session_tracking AFTER UPDATE OF "isOnline" ON driver
+		FOR EACH ROW WHEN (OLD."isOnline" IS DISTINCT FROM NEW."isOnline") EXECUTE FUNCTION track_driver_session();
+	INSERT INTO driver_sessions ("driverId") SELECT id FROM driver WHERE "isOnline" ON CONFLICT DO NOTHING;
+
+	-- ═══════════════════════════════════════════
+	-- REVIEWS — riders' rating, comment & tags per ride, moderated by admins
+	-- ═══════════════════════════════════════════
+	CREATE TABLE IF NOT EXISTS ride_reviews (
+		"rideId" TEXT PRIMARY KEY REFERENCES rides(id) ON DELETE CASCADE,
+		"driverId" TEXT NOT NULL REFERENCES driver(id),
+		"userId" TEXT NOT NULL REFERENCES "user"(id),
+		rating DOUBLE PRECISION NOT NULL,
+		comment TEXT NOT NULL DEFAULT '',
+		tags TEXT[] NOT NULL DEFAULT '{}',
+		"isHidden" BOOLEAN NOT NULL DEFAULT FALSE,
+		"hiddenReason" TEXT,
+		"hiddenBy" TEXT,
+		"hiddenAt" TIMESTAMPTZ,
+		"createdAt" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		"updatedAt" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);
+	CREATE INDEX IF NOT EXISTS idx_ride_reviews_driver ON ride_reviews("driverId", "createdAt" DESC);
+	-- Ratings given before reviews existed
+	INSERT INTO ride_reviews ("rideId", "driverId", "userId", rating, comment, tags, "createdAt", "updatedAt")
+	SELECT id, "driverId", "userId", rating, COALESCE("ratingComment", ''), COALESCE("ratingTags", '{}'), "updatedAt", "updatedAt"
+	FROM rides WHERE rating IS NOT NULL AND "driverId" IS NOT NULL
+	ON CONFLICT ("rideId") DO NOTHING;
+
+	-- ═══════════════════════════════════════════
+	-- COMMISSION — per vehicle type default, per driver override, audited per ride
+	-- ═══════════════════════════════════════════
+	ALTER TABLE vehicle_types ADD COLUMN IF NOT EXISTS "commissionPercent" DOUBLE PRECISION; -- NULL: PLATFORM_FEE_PERCENTAGE
+	ALTER TABLE driver ADD COLUMN IF NOT EXISTS "commissionPercent" DOUBLE PRECISION; -- NULL: the vehicle type's
+	ALTER TABLE rides ADD COLUMN IF NOT EXISTS "commissionPercent" DOUBLE PRECISION;
+	ALTER TABLE rides ADD COLUMN IF NOT EXISTS "commissionAmount" DOUBLE PRECISION;
+
+	-- ═══════════════════════════════════════════
+	-- SOS ESCALATION — on-call admins, driver position, acknowledgement & resolution
+	-- ═══════════════════════════════════════════
+	ALTER TABLE admin_accounts ADD COLUMN IF NOT EXISTS "isOnCall" BOOLEAN NOT NULL DEFAULT FALSE;
+	ALTER TABLE admin_accounts ADD COLUMN IF NOT EXISTS "phoneNumber" TEXT;
+	ALTER TABLE admin_accounts ADD COLUMN IF NOT EXISTS "fcmToken" TEXT;
+	ALTER TABLE sos_alerts ADD COLUMN IF NOT EXISTS "driverId" TEXT;
+	ALTER TABLE sos_alerts ADD COLUMN IF NOT EXISTS "driverLat" DOUBLE PRECISION;
+	ALTER TABLE sos_alerts ADD COLUMN IF NOT EXISTS "driverLng" DOUBLE PRECISION;
+	ALTER TABLE sos_alerts ADD COLUMN IF NOT EXISTS "driverLocationAt" TIMESTAMPTZ;
+	ALTER TABLE sos_alerts ADD COLUMN IF NOT EXISTS "escalationCount" INT NOT NULL DEFAULT 0;
+	ALTER TABLE sos_alerts ADD COLUMN IF NOT EXISTS "lastEscalatedAt" TIMESTAMPTZ;
+	ALTER TABLE sos_alerts ADD COLUMN IF NOT EXISTS "acknowledgedAt" TIMESTAMPTZ;
+	ALTER TABLE sos_alerts ADD COLUMN IF NOT EXISTS "acknowledgedBy" TEXT;
+	ALTER TABLE sos_alerts ADD COLUMN IF NOT EXISTS "resolvedBy" TEXT;
+	ALTER TABLE sos_alerts ADD COLUMN IF NOT EXISTS "resolutionNote" TEXT;
+	CREATE INDEX IF NOT EXISTS idx_sos_unacknowledged ON sos_alerts("lastEscalatedAt") WHERE status='active';
+
+	-- ═══════════════════════════════════════════
+	-- DRIVER LOCATION HISTORY — batched background pings, kept for route playback
+	-- ═══════════════════════════════════════════
+	CREATE TABLE IF NOT EXISTS driver_location_history (
+		id BIGSERIAL PRIMARY KEY,
+		"driverId" TEXT NOT NULL,
+		"rideId" TEXT,
+		lat DOUBLE PRECISION NOT NULL,
+		lng DOUBLE PRECISION NOT NULL,
+		"rawLat" DOUBLE PRECISION NOT NULL,
+		"rawLng" DOUBLE PRECISION NOT NULL,
+		heading DOUBLE PRECISION,
+		speed DOUBLE PRECISION,
+		accuracy DOUBLE PRECISION,
+		"recordedAt" TIMESTAMPTZ NOT NULL,
+		"createdAt" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);
+	CREATE INDEX IF NOT EXISTS idx_location_history_driver ON driver_location_history("driverId", "recordedAt");
+	CREATE INDEX IF NOT EXISTS idx_location_history_ride ON driver_location_history("rideId", "recordedAt") WHERE "rideId" IS NOT NULL;
+
+	-- ═══════════════════════════════════════════
+	-- RIDE DISPUTES — rider fare complaints and the admin's final charge
+	-- ═══════════════════════════════════════════
+	CREATE TABLE IF NOT EXISTS ride_disputes (
+		id TEXT PRIMARY KEY DEFAULT gen_random_uuid()::text,
+		"rideId" TEXT UNIQUE NOT NULL REFERENCES rides(id),
+		"userId" TEXT NOT NULL,
+		"driverId" TEXT,
+		category TEXT NOT NULL,
+		description TEXT NOT NULL DEFAULT '',
+		"requestedCharge" DOUBLE PRECISION,
+		"originalCharge" DOUBLE PRECISION NOT NULL,
+		"finalCharge" DOUBLE PRECISION,
+		status TEXT NOT NULL DEFAULT 'open', -- open | resolved | rejected
+		resolution TEXT,
+		"resolvedBy" TEXT,
+		"resolvedAt" TIMESTAMPTZ,
+		"refundId" TEXT REFERENCES refunds(id),
+		"extraPaymentId" TEXT REFERENCES payments(id),
+		"createdAt" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		"updatedAt" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);
+	CREATE INDEX IF NOT EXISTS idx_ride_disputes_status ON ride_disputes(status, "createdAt");
+
+	-- ═══════════════════════════════════════════
+	-- ADMIN AUDIT LOGS — every mutating admin request, with a diff of the row it touched
+	-- ═══════════════════════════════════════════
+	CREATE TABLE IF NOT EXISTS admin_audit_logs (
+		id TEXT PRIMARY KEY DEFAULT gen_random_uuid()::text,
+		"adminId" TEXT NOT NULL,
+		"adminEmail" TEXT NOT NULL,
+		method TEXT NOT NULL,
+		path TEXT NOT NULL,
+		route TEXT NOT NULL,
+		"entityType" TEXT NOT NULL,
+		"entityId" TEXT,
+		request JSONB,
+		changes JSONB,
+		status INT NOT NULL,
+		ip TEXT,
+		"requestId" TEXT,
+		"createdAt" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);
+	CREATE INDEX IF NOT EXISTS idx_admin_audit_created ON admin_audit_logs("createdAt" DESC);
+	CREATE INDEX IF NOT EXISTS idx_admin_audit_entity ON admin_audit_logs("entityType", "entityId", "createdAt" DESC);
+	CREATE INDEX IF NOT EXISTS idx_admin_audit_admin ON admin_audit_logs("adminId", "createdAt" DESC);
+
+	-- ═══════════════════════════════════════════
+	-- DRIVER METRICS — nightly performance aggregates & dispatch score
+	-- ═══════════════════════════════════════════
+	CREATE TABLE IF NOT EXISTS driver_metrics (
+		"driverId" TEXT PRIMARY KEY REFERENCES driver(id) ON DELETE CASCADE,
+		"windowDays" INT NOT NULL,
+		"offeredCount" INT NOT NULL DEFAULT 0,
+		"acceptedCount" INT NOT NULL DEFAULT 0,
+		"completedCount" INT NOT NULL DEFAULT 0,
+		"cancelledCount" INT NOT NULL DEFAULT 0,
+		"arrivedCount" INT NOT NULL DEFAULT 0,
+		"onTimeCount" INT NOT NULL DEFAULT 0,
+		"ratingCount" INT NOT NULL DEFAULT 0,
+		-- Rates are percentages, NULL while there's nothing to base them on
+		"acceptanceRate" DOUBLE PRECISION,
+		"cancellationRate" DOUBLE PRECISION,
+		"completionRate" DOUBLE PRECISION,
+		"onTimeRate" DOUBLE PRECISION,
+		"averageRating" DOUBLE PRECISION,
+		score DOUBLE PRECISION,
+		"computedAt" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);
+	CREATE INDEX IF NOT EXISTS idx_driver_metrics_score ON driver_metrics(score DESC NULLS LAST);
+
+	-- ═══════════════════════════════════════════
+	-- REFERRALS — rider invite codes & first-ride rewards
+	-- ═══════════════════════════════════════════
+	ALTER TABLE "user" ADD COLUMN IF NOT EXISTS "referralCode" TEXT;
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_user_referral_code ON "user"(UPPER("referralCode"));
+	-- A promo with an owner can only be redeemed by that rider (referral rewards)
+	ALTER TABLE promo_codes ADD COLUMN IF NOT EXISTS "userId" TEXT REFERENCES "user"(id) ON DELETE CASCADE;
+	CREATE TABLE IF NOT EXISTS referrals (
+		id TEXT PRIMARY KEY DEFAULT gen_random_uuid()::text,
+		"referrerId" TEXT NOT NULL REFERENCES "user"(id),
+		"refereeId" TEXT NOT NULL UNIQUE REFERENCES "user"(id),
+		code TEXT NOT NULL,
+		status TEXT NOT NULL DEFAULT 'pending', -- pending → rewarded
+		"rideId" TEXT REFERENCES rides(id), -- the referee's first completed ride
+		"referrerPromoId" TEXT REFERENCES promo_codes(id),
+		"refereePromoId" TEXT REFERENCES promo_codes(id),
+		"rewardedAt" TIMESTAMPTZ,
+		"createdAt" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);
+	CREATE INDEX IF NOT EXISTS idx_referrals_referrer ON referrals("referrerId", "createdAt" DESC);
+	CREATE INDEX IF NOT EXISTS idx_referrals_created ON referrals("createdAt" DESC);
+
+	-- ═══════════════════════════════════════════
+	-- NOTIFICATIONS OUTBOX — push & socket sends, written with the change they announce
+	-- ═══════════════════════════════════════════
+	CREATE TABLE IF NOT EXISTS notifications_outbox (
+		id TEXT PRIMARY KEY DEFAULT gen_random_uuid()::text,
+		channel TEXT NOT NULL, -- push | socket
+		"recipientType" TEXT NOT NULL, -- user | driver
+		"recipientId" TEXT NOT NULL,
+		"rideId" TEXT,
+		event TEXT, -- socket event name
+		title TEXT,
+		body TEXT,
+		data JSONB NOT NULL DEFAULT '{}',
+		status TEXT NOT NULL DEFAULT 'pending', -- pending → sent | skipped | dead
+		attempts INT NOT NULL DEFAULT 0,
+		"nextAttemptAt" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		"lastError" TEXT,
+		"sentAt" TIMESTAMPTZ,
+		"createdAt" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);
+	CREATE INDEX IF NOT EXISTS idx_notifications_outbox_due ON notifications_outbox("nextAttemptAt") WHERE status = 'pending';
+	CREATE INDEX IF NOT EXISTS idx_notifications_outbox_status ON notifications_outbox(status, "createdAt" DESC);
+
+	-- ═══════════════════════════════════════════
+	-- DRIVER PREFERENCES — destination mode & preferred drop-off zone
+	-- ═══════════════════════════════════════════
+	CREATE TABLE IF NOT EXISTS driver_preferences (
+		"driverId" TEXT PRIMARY KEY REFERENCES driver(id) ON DELETE CASCADE,
+		"destinationLat" DOUBLE PRECISION,
+		"destinationLng" DOUBLE PRECISION,
+		"destinationName" TEXT,
+		"destinationExpiresAt" TIMESTAMPTZ, -- destination mode switches itself off
+		"preferredZone" TEXT, -- service zone name
+		"updatedAt" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);
+
+	-- ═══════════════════════════════════════════
+	-- RIDE EARNINGS — what the driver earned on each completed ride, line by line
+	-- ═══════════════════════════════════════════
+	CREATE TABLE IF NOT EXISTS ride_earnings (
+		"rideId" TEXT PRIMARY KEY REFERENCES rides(id),
+		"driverId" TEXT NOT NULL REFERENCES driver(id),
+		"fleetId" TEXT REFERENCES fleets(id),
+		"grossFare" DOUBLE PRECISION NOT NULL, -- after any dispute adjustment
+		commission DOUBLE PRECISION NOT NULL DEFAULT 0,
+		"fleetCommission" DOUBLE PRECISION NOT NULL DEFAULT 0,
+		tip DOUBLE PRECISION NOT NULL DEFAULT 0,
+		incentives DOUBLE PRECISION NOT NULL DEFAULT 0,
+		net DOUBLE PRECISION GENERATED ALWAYS AS ("grossFare" - commission - "fleetCommission" + tip + incentives) STORED,
+		"completedAt" TIMESTAMPTZ NOT NULL,
+		"createdAt" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		"updatedAt" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);
+	CREATE INDEX IF NOT EXISTS idx_ride_earnings_driver ON ride_earnings("driverId", "completedAt" DESC); -- older rides: BackfillRideEarnings
+
+	-- ═══════════════════════════════════════════
+	-- INCENTIVES — ride-count quests with a wallet bonus
+	-- ═══════════════════════════════════════════
+	CREATE TABLE IF NOT EXISTS incentives (
+		id TEXT PRIMARY KEY DEFAULT gen_random_uuid()::text,
+		title TEXT NOT NULL,
+		description TEXT NOT NULL DEFAULT '',
+		"targetRides" INTEGER NOT NULL CHECK ("targetRides" > 0),
+		reward DOUBLE PRECISION NOT NULL CHECK (reward > 0),
+		"vehicleType" TEXT, -- NULL: rides in any vehicle type count
+		"startsAt" TIMESTAMPTZ NOT NULL,
+		"endsAt" TIMESTAMPTZ NOT NULL CHECK ("endsAt" > "startsAt"),
+		"isActive" BOOLEAN NOT NULL DEFAULT TRUE,
+		"createdBy" TEXT,
+		"createdAt" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		"updatedAt" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);
+	CREATE INDEX IF NOT EXISTS idx_incentives_window ON incentives("endsAt", "startsAt") WHERE "isActive";
+	CREATE TABLE IF NOT EXISTS driver_incentive_progress (
+		"incentiveId" TEXT NOT NULL REFERENCES incentives(id),
+		"driverId" TEXT NOT NULL REFERENCES driver(id),
+		rides INTEGER NOT NULL DEFAULT 0, -- completed rides inside the window
+		"achievedAt" TIMESTAMPTZ,
+		"rewardedAt" TIMESTAMPTZ,
+		"rewardRideId" TEXT REFERENCES rides(id), -- the ride that reached the target
+		"updatedAt" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		PRIMARY KEY ("incentiveId", "driverId")
+	);
+	CREATE INDEX IF NOT EXISTS idx_driver_incentive_progress_driver ON driver_incentive_progress("driverId");
+
+	-- ═══════════════════════════════════════════
+	-- I18N — the language drivers get messages and notifications in (riders: "user"."preferredLanguage")
+	-- ═══════════════════════════════════════════
+	ALTER TABLE driver ADD COLUMN IF NOT EXISTS "preferredLanguage" TEXT;
+
+	-- ═══════════════════════════════════════════
+	-- PICKUP ARRIVAL — when the driver reached the pickup, and what the rider's wait there cost
+	-- ═══════════════════════════════════════════
+	ALTER TABLE rides ADD COLUMN IF NOT EXISTS "arrivedAt" TIMESTAMPTZ;
+	ALTER TABLE rides ADD COLUMN IF NOT EXISTS "waitingCharge" DOUBLE PRECISION NOT NULL DEFAULT 0;
+
+	-- ═══════════════════════════════════════════
+	-- FARE COMPONENTS — the wait and cancellation fee priced by rides/fares
+	-- ═══════════════════════════════════════════
+	ALTER TABLE rides ADD COLUMN IF NOT EXISTS "waitingMinutes" INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE rides ADD COLUMN IF NOT EXISTS "cancellationFee" DOUBLE PRECISION NOT NULL DEFAULT 0;
+
+	-- ═══════════════════════════════════════════
+	-- PROMO RULES — who, on what and when a promo code can be used (empty = anyone, anything, any time)
+	-- ═══════════════════════════════════════════
+	ALTER TABLE promo_codes ADD COLUMN IF NOT EXISTS "firstRideOnly" BOOLEAN NOT NULL DEFAULT FALSE;
+	ALTER TABLE promo_codes ADD COLUMN IF NOT EXISTS "vehicleTypes" TEXT[] NOT NULL DEFAULT '{}';
+	ALTER TABLE promo_codes ADD COLUMN IF NOT EXISTS zones TEXT[] NOT NULL DEFAULT '{}';
+	ALTER TABLE promo_codes ADD COLUMN IF NOT EXISTS segments TEXT[] NOT NULL DEFAULT '{}';
+	ALTER TABLE promo_codes ADD COLUMN IF NOT EXISTS days INTEGER[] NOT NULL DEFAULT '{}'; -- 0 = Sunday
+	ALTER TABLE promo_codes ADD COLUMN IF NOT EXISTS "timeFrom" TEXT; -- HH:MM, service timezone
+	ALTER TABLE promo_codes ADD COLUMN IF NOT EXISTS "timeUntil" TEXT;
+	ALTER TABLE promo_codes ADD COLUMN IF NOT EXISTS "perUserLimit" INTEGER NOT NULL DEFAULT 0;
+	CREATE INDEX IF NOT EXISTS idx_rides_user_promo ON rides("userId", UPPER("promoCode")) WHERE "promoCode" IS NOT NULL;
+
+	-- ═══════════════════════════════════════════
+	-- NOTIFICATION PREFERENCES — optional message categories per rider/driver (no row = defaults)
+	-- ═══════════════════════════════════════════
+	CREATE TABLE IF NOT EXISTS notification_preferences (
+		"recipientType" TEXT NOT NULL, -- user | driver
+		"recipientId" TEXT NOT NULL,
+		promotions BOOLEAN NOT NULL DEFAULT TRUE,
+		"rideUpdates" TEXT NOT NULL DEFAULT 'push', -- push | sms
+		"emailReceipts" BOOLEAN NOT NULL DEFAULT TRUE,
+		"updatedAt" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		PRIMARY KEY ("recipientType", "recipientId")
+	);
+
+	-- ═══════════════════════════════════════════
+	-- RIDE ESTIMATES — priced routes, the top of the booking funnel (id = routeId)
+	-- ═══════════════════════════════════════════
+	CREATE TABLE IF NOT EXISTS ride_estimates (
+		id TEXT PRIMARY KEY,
+		"userId" TEXT NOT NULL REFERENCES "user"(id) ON DELETE CASCADE,
+		"vehicleType" TEXT NOT NULL,
+		fare DOUBLE PRECISION NOT NULL,
+		"createdAt" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);
+	CREATE INDEX IF NOT EXISTS idx_ride_estimates_created ON ride_estimates("createdAt");
+	CREATE INDEX IF NOT EXISTS idx_rides_route ON rides("routeId") WHERE "routeId" IS NOT NULL;
+
+	-- ═══════════════════════════════════════════
+	-- ANALYTICS STATS — per-day and per-hour ride totals for the dashboard, kept by the stats worker.
+	-- Rides count on the day they were requested; revenue and distance are completed rides only
+	-- ═══════════════════════════════════════════
+	CREATE TABLE IF NOT EXISTS daily_stats (
+		day DATE PRIMARY KEY,
+		rides INT NOT NULL DEFAULT 0,
+		completed INT NOT NULL DEFAULT 0,
+		cancelled INT NOT NULL DEFAULT 0,
+		revenue DOUBLE PRECISION NOT NULL DEFAULT 0,
+		tips DOUBLE PRECISION NOT NULL DEFAULT 0,
+		"distanceKm" DOUBLE PRECISION NOT NULL DEFAULT 0,
+		"ratingSum" DOUBLE PRECISION NOT NULL DEFAULT 0,
+		"ratingCount" INT NOT NULL DEFAULT 0,
+		"newUsers" INT NOT NULL DEFAULT 0,
+		"newDrivers" INT NOT NULL DEFAULT 0,
+		"refreshedAt" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);
+	CREATE TABLE IF NOT EXISTS daily_vehicle_stats (
+		day DATE NOT NULL,
+		"vehicleType" TEXT NOT NULL,
+		rides INT NOT NULL DEFAULT 0,
+		completed INT NOT NULL DEFAULT 0,
+		revenue DOUBLE PRECISION NOT NULL DEFAULT 0,
+		PRIMARY KEY (day, "vehicleType")
+	);
+	CREATE TABLE IF NOT EXISTS hourly_stats (
+		hour TIMESTAMPTZ PRIMARY KEY,
+		rides INT NOT NULL DEFAULT 0,
+		completed INT NOT NULL DEFAULT 0,
+		cancelled INT NOT NULL DEFAULT 0,
+		revenue DOUBLE PRECISION NOT NULL DEFAULT 0
+	);
+	CREATE INDEX IF NOT EXISTS idx_user_not_active ON "user"(status) WHERE status <> 'active';
+
+	-- ═══════════════════════════════════════════
+	-- FARE CURRENCY — what a ride was charged in, and per-zone currency and rounding overrides.
+	-- Everything before this was charged in rupees
+	-- ═══════════════════════════════════════════
+	ALTER TABLE rides ADD COLUMN IF NOT EXISTS currency TEXT NOT NULL DEFAULT 'INR';
+	ALTER TABLE payments ADD COLUMN IF NOT EXISTS currency TEXT NOT NULL DEFAULT 'INR';
+	ALTER TABLE service_zones ADD COLUMN IF NOT EXISTS currency TEXT;
+	ALTER TABLE service_zones ADD COLUMN IF NOT EXISTS "fareRounding" TEXT;
+	ALTER TABLE service_zones ADD COLUMN IF NOT EXISTS "fareRoundTo" DOUBLE PRECISION;
+
+	-- ═══════════════════════════════════════════
+	-- ROUTE STEPS — the planned route's turn-by-turn steps, for in-app driver navigation
+	-- ═══════════════════════════════════════════
+	ALTER TABLE rides ADD COLUMN IF NOT EXISTS "routeSteps" JSONB;
+
+	-- ═══════════════════════════════════════════
+	-- PAYMENT AMOUNT DUE — extra owed on a paid ride after a dispute raised its fare
+	-- ═══════════════════════════════════════════
+	ALTER TABLE payments ADD COLUMN IF NOT EXISTS "amountDue" DOUBLE PRECISION NOT NULL DEFAULT 0;
 	`
 
-	_, err := Pool.Exec(context.Background(), sql)
+// Migrate applies migrationSQL and any pending schema changes.
+// Safe to run multiple times — all operations are idempotent (IF NOT EXISTS / ON CONFLICT).
+func Migrate() {
+	_, err := Pool.Exec(Unscoped(), migrationSQL)
 	if err != nil {
 		log.Fatalf("Migration failed: %v", err)
 	}
+	tagRegion()
+	tagTenant()
+	applySchemaChanges()
 	log.Println("Database migration completed successfully")
 }
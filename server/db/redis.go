@@ -4,16 +4,17 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"os"
 
 	"github.com/redis/go-redis/v9"
+	"ridewave/chaos"
+	"ridewave/telemetry"
 )
 
 var RedisClient *redis.Client
 
 func InitRedis() {
-	addr := os.Getenv("REDIS_ADDR")
-	password := os.Getenv("REDIS_PASSWORD")
+	addr := RegionEnv("REDIS_ADDR")
+	password := RegionEnv("REDIS_PASSWORD")
 	
 	if addr == "" {
 		addr = "localhost:6379"
@@ -24,6 +25,12 @@ func InitRedis() {
 		Password: password, // no password set
 		DB:       0,        // use default DB
 	})
+	if chaos.Allowed() {
+		RedisClient.AddHook(chaos.RedisHook{})
+	}
+	if telemetry.Enabled() {
+		RedisClient.AddHook(telemetry.RedisHook{})
+	}
 
 	ctx := context.Background()
 	_, err := RedisClient.Ping(ctx).Result()
@@ -0,0 +1,50 @@
+package db
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+)
+
+var regionPattern = regexp.MustCompile(`^[a-z]{2,10}$`)
+
+// regionTables are tagged with the region their rows were written in.
+var regionTables = []string{`"user"`, "driver", "rides", "payments", "refunds", "scheduled_rides", "sos_alerts", "wallet_transactions"}
+
+// Region is the data-residency region this deployment serves (REGION, e.g. "in", "ae"; default "in").
+func Region() string {
+	region := strings.ToLower(strings.TrimSpace(os.Getenv("REGION")))
+	if region == "" {
+		return "in"
+	}
+	return region
+}
+
+// RegionEnv reads KEY_<REGION> (e.g. DATABASE_URL_AE) and falls back to KEY,
+// so a shared env file can hold every region's endpoints.
+func RegionEnv(key string) string {
+	if val := os.Getenv(key + "_" + strings.ToUpper(Region())); val != "" {
+		return val
+	}
+	return os.Getenv(key)
+}
+
+// tagRegion adds a region column to every resident table, defaulting new rows to this deployment's region.
+func tagRegion() {
+	region := Region()
+	if !regionPattern.MatchString(region) {
+		log.Fatalf("Invalid REGION %q: use a short lowercase code like \"in\"", region)
+	}
+
+	for _, table := range regionTables {
+		sql := fmt.Sprintf(`
+			ALTER TABLE %[1]s ADD COLUMN IF NOT EXISTS region TEXT;
+			ALTER TABLE %[1]s ALTER COLUMN region SET DEFAULT '%[2]s';
+			UPDATE %[1]s SET region='%[2]s' WHERE region IS NULL;`, table, region)
+		if _, err := Pool.Exec(Unscoped(), sql); err != nil {
+			log.Fatalf("Region tagging failed for %s: %v", table, err)
+		}
+	}
+}
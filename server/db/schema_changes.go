@@ -0,0 +1,471 @@
+package db
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ══════════════════════════════════════════════════
+// Expand / Contract Schema Changes — blue/green safe migrations
+// ══════════════════════════════════════════════════
+//
+// A breaking change (rename, type change, split column) ships in two phases:
+//
+//  1. expand   — additive only: add the new layout, backfill it and keep both layouts in
+//     sync, so the old and the new app version can run side by side. Applied by every
+//     `migrate` run.
+//  2. contract — destructive: drop the old layout. Applied only with MIGRATION_PHASE=contract,
+//     once no instance of the old version is left.
+//
+// Between the two phases handlers read through CompatColumn, which falls back to the old
+// column for rows written by old instances.
+
+const (
+	PhaseExpand   = "expand"
+	PhaseContract = "contract"
+)
+
+// SchemaChange is one expand/contract change. Statements run in order inside a transaction per phase.
+type SchemaChange struct {
+	ID          string
+	Description string
+	Expand      []string
+	Contract    []string
+}
+
+// ChangeDriverUpiID moves driver.upi_id to the camelCase "upiId" used by every other column.
+const ChangeDriverUpiID = "20261016_driver_upi_id"
+
+// ChangeDriverVehicles moves the vehicle off the driver row into vehicles, so a driver can
+// register several. The driver's vehicle columns stay as a copy of the active vehicle, which
+// dispatch, ride details and the admin views keep reading.
+const ChangeDriverVehicles = "20261016_driver_vehicles"
+
+// ChangePaymentsDedupe leaves one fare per ride before the uniqueness guards go on. Every row it
+// changes or removes is copied to payments_archive first, with the payment it was merged into.
+const ChangePaymentsDedupe = "20261016_payments_dedupe"
+
+// ChangeDisputeExtraCharges folds the pending 'adjustment' payments that resolved disputes used
+// to record, which nothing collected, into the paid payment's amount due.
+const ChangeDisputeExtraCharges = "20261016_dispute_extra_charges"
+
+var schemaChanges = []SchemaChange{
+	{
+		ID:          ChangeDriverUpiID,
+		Description: `Rename driver.upi_id to "upiId"`,
+		Expand: []string{
+			`ALTER TABLE driver ADD COLUMN IF NOT EXISTS upi_id TEXT`,
+			`ALTER TABLE driver ADD COLUMN IF NOT EXISTS "upiId" TEXT`,
+			`UPDATE driver SET "upiId"=upi_id WHERE "upiId" IS NULL AND upi_id IS NOT NULL`,
+			// Keep both columns in sync whichever one the running version writes
+			`CREATE OR REPLACE FUNCTION sync_driver_upi_id() RETURNS trigger AS $$
+			BEGIN
+				IF TG_OP = 'UPDATE' AND NEW."upiId" IS DISTINCT FROM OLD."upiId" THEN
+					NEW.upi_id := NEW."upiId";
+				ELSIF TG_OP = 'UPDATE' AND NEW.upi_id IS DISTINCT FROM OLD.upi_id THEN
+					NEW."upiId" := NEW.upi_id;
+				ELSE
+					NEW."upiId" := COALESCE(NEW."upiId", NEW.upi_id);
+					NEW.upi_id := COALESCE(NEW.upi_id, NEW."upiId");
+				END IF;
+				RETURN NEW;
+			END $$ LANGUAGE plpgsql`,
+			`DROP TRIGGER IF EXISTS driver_upi_id_sync ON driver`,
+			`CREATE TRIGGER driver_upi_id_sync BEFORE INSERT OR UPDATE ON driver FOR EACH ROW EXECUTE FUNCTION sync_driver_upi_id()`,
+		},
+		Contract: []string{
+			`DROP TRIGGER IF EXISTS driver_upi_id_sync ON driver`,
+			`DROP FUNCTION IF EXISTS sync_driver_upi_id()`,
+			`ALTER TABLE driver DROP COLUMN IF EXISTS upi_id`,
+		},
+	},
+	{
+		ID:          ChangeDriverVehicles,
+		Description: "Move driver vehicle fields into a vehicles table",
+		Expand: []string{
+			`CREATE TABLE IF NOT EXISTS vehicles (
+				id TEXT PRIMARY KEY DEFAULT gen_random_uuid()::text,
+				"driverId" TEXT NOT NULL REFERENCES driver(id) ON DELETE CASCADE,
+				"vehicleType" TEXT NOT NULL,
+				"registrationNumber" TEXT NOT NULL,
+				"registrationDate" TEXT NOT NULL DEFAULT '',
+				color TEXT,
+				"rcBook" TEXT,
+				"insuranceDoc" TEXT,
+				"permitDoc" TEXT,
+				status TEXT NOT NULL DEFAULT 'pending',
+				"rejectionReason" TEXT,
+				"reviewedBy" TEXT,
+				"reviewedAt" TIMESTAMPTZ,
+				"isActive" BOOLEAN NOT NULL DEFAULT FALSE,
+				"createdAt" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+				"updatedAt" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+			)`,
+			`CREATE UNIQUE INDEX IF NOT EXISTS idx_vehicles_active ON vehicles ("driverId") WHERE "isActive"`,
+			`CREATE UNIQUE INDEX IF NOT EXISTS idx_vehicles_registration ON vehicles ("registrationNumber") WHERE status <> 'rejected'`,
+			`CREATE INDEX IF NOT EXISTS idx_vehicles_status ON vehicles (status, "createdAt")`,
+			// Each existing driver's vehicle becomes their active one, approved if the driver was
+			`INSERT INTO vehicles ("driverId", "vehicleType", "registrationNumber", "registrationDate", color, "rcBook",
+				status, "isActive", "reviewedAt", "createdAt")
+			 SELECT d.id, d.vehicle_type, d.registration_number, d.registration_date, d.vehicle_color, d."rcBook",
+				CASE WHEN d.status IN ('pending', 'rejected') THEN d.status ELSE 'approved' END, TRUE,
+				CASE WHEN d.status = 'pending' THEN NULL ELSE d."updatedAt" END, d."createdAt"
+			 FROM driver d WHERE NOT EXISTS (SELECT 1 FROM vehicles v WHERE v."driverId"=d.id)`,
+			// The driver row mirrors whichever vehicle is active
+			`CREATE OR REPLACE FUNCTION mirror_active_vehicle() RETURNS trigger AS $$
+			BEGIN
+				UPDATE driver SET vehicle_type=NEW."vehicleType", registration_number=NEW."registrationNumber",
+					registration_date=NEW."registrationDate", vehicle_color=NEW.color, "rcBook"=NEW."rcBook", "updatedAt"=NOW()
+				WHERE id=NEW."driverId" AND (vehicle_type, registration_number, registration_date, vehicle_color, "rcBook")
+					IS DISTINCT FROM (NEW."vehicleType", NEW."registrationNumber", NEW."registrationDate", NEW.color, NEW."rcBook");
+				RETURN NULL;
+			END $$ LANGUAGE plpgsql`,
+			`DROP TRIGGER IF EXISTS vehicles_mirror_active ON vehicles`,
+			`CREATE TRIGGER vehicles_mirror_active AFTER INSERT OR UPDATE ON vehicles FOR EACH ROW
+			 WHEN (NEW."isActive") EXECUTE FUNCTION mirror_active_vehicle()`,
+			// Drivers registered by the old version get their vehicle too
+			`CREATE OR REPLACE FUNCTION create_driver_vehicle() RETURNS trigger AS $$
+			BEGIN
+				INSERT INTO vehicles ("driverId", "vehicleType", "registrationNumber", "registrationDate", color, "rcBook", "isActive")
+				VALUES (NEW.id, NEW.vehicle_type, NEW.registration_number, NEW.registration_date, NEW.vehicle_color, NEW."rcBook", TRUE)
+				ON CONFLICT DO NOTHING;
+				RETURN NULL;
+			END $$ LANGUAGE plpgsql`,
+			`DROP TRIGGER IF EXISTS driver_create_vehicle ON driver`,
+			`CREATE TRIGGER driver_create_vehicle AFTER INSERT ON driver FOR EACH ROW EXECUTE FUNCTION create_driver_vehicle()`,
+		},
+		Contract: []string{
+			`DROP TRIGGER IF EXISTS driver_create_vehicle ON driver`,
+			`DROP FUNCTION IF EXISTS create_driver_vehicle()`,
+		},
+	},
+	{
+		ID:          ChangePaymentsDedupe,
+		Description: "Archive and remove duplicate payments, then allow one per ride and mode and one paid per ride",
+		Expand: []string{
+			`CREATE TABLE IF NOT EXISTS payments_archive (
+				id TEXT PRIMARY KEY DEFAULT gen_random_uuid()::text,
+				"paymentId" TEXT NOT NULL,
+				"rideId" TEXT NOT NULL,
+				reason TEXT NOT NULL, -- status_normalized | duplicate | dispute_adjustment
+				"mergedIntoId" TEXT, -- the payment kept in place of a duplicate
+				original JSONB NOT NULL, -- the row as it was
+				"archivedAt" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_payments_archive_ride ON payments_archive("rideId")`,
+			// Old clients recorded 'success'; everything else says 'paid'
+			`INSERT INTO payments_archive ("paymentId", "rideId", reason, original)
+			 SELECT id, "rideId", 'status_normalized', to_jsonb(payments) FROM payments WHERE status='success'`,
+			`UPDATE payments SET status='paid' WHERE status='success'`,
+			// q supersedes p when both are for the ride and the same mode, or both are paid, and q is
+			// paid where p isn't or else was recorded first
+			`CREATE OR REPLACE FUNCTION payment_supersedes(q payments, p payments) RETURNS boolean AS $$
+				SELECT q."rideId"=p."rideId" AND q.id<>p.id
+				  AND (LOWER(q.mode)=LOWER(p.mode) OR (p.status='paid' AND q.status='paid'))
+				  AND ((q.status='paid' AND p.status<>'paid')
+				    OR ((q.status='paid')=(p.status='paid') AND (q."createdAt", q.id) < (p."createdAt", p.id)))
+			$$ LANGUAGE sql STABLE`,
+			`INSERT INTO payments_archive ("paymentId", "rideId", reason, "mergedIntoId", original)
+			 SELECT p.id, p."rideId", 'duplicate',
+				(SELECT q.id FROM payments q
+				 WHERE payment_supersedes(q, p) AND NOT EXISTS (SELECT 1 FROM payments r WHERE payment_supersedes(r, q))
+				 ORDER BY (q.status='paid') DESC, q."createdAt", q.id LIMIT 1),
+				to_jsonb(p)
+			 FROM payments p WHERE EXISTS (SELECT 1 FROM payments q WHERE payment_supersedes(q, p))`,
+			// Refunds and dispute charges against a duplicate move to the payment that's kept
+			`UPDATE refunds f SET "paymentId"=a."mergedIntoId" FROM payments_archive a
+			 WHERE a.reason='duplicate' AND a."mergedIntoId" IS NOT NULL AND f."paymentId"=a."paymentId"`,
+			`UPDATE ride_disputes d SET "extraPaymentId"=a."mergedIntoId" FROM payments_archive a
+			 WHERE a.reason='duplicate' AND a."mergedIntoId" IS NOT NULL AND d."extraPaymentId"=a."paymentId"`,
+			`DELETE FROM payments WHERE id IN (SELECT "paymentId" FROM payments_archive WHERE reason='duplicate')`,
+			`DROP FUNCTION payment_supersedes(payments, payments)`,
+			`CREATE UNIQUE INDEX IF NOT EXISTS idx_payments_ride_mode ON payments("rideId", (LOWER(mode)))`,
+			`CREATE UNIQUE INDEX IF NOT EXISTS idx_payments_ride_paid ON payments("rideId") WHERE status='paid'`,
+		},
+	},
+	{
+		ID:          ChangeDisputeExtraCharges,
+		Description: "Move pending dispute adjustment payments onto the paid payment's amount due",
+		Expand: []string{
+			`INSERT INTO payments_archive ("paymentId", "rideId", reason, "mergedIntoId", original)
+			 SELECT a.id, a."rideId", 'dispute_adjustment', p.id, to_jsonb(a)
+			 FROM payments a JOIN payments p ON p."rideId"=a."rideId" AND p.status='paid'
+			 WHERE a.mode='adjustment' AND a.status='pending'`,
+			`UPDATE payments p SET "amountDue"=p."amountDue"+a.amount
+			 FROM payments a WHERE a."rideId"=p."rideId" AND p.status='paid' AND a.mode='adjustment' AND a.status='pending'`,
+			`UPDATE rides SET "paymentStatus"='Pending', "updatedAt"=NOW()
+			 WHERE id IN (SELECT "rideId" FROM payments WHERE status='paid' AND "amountDue" > 0)`,
+			`UPDATE ride_disputes d SET "extraPaymentId"=x."mergedIntoId" FROM payments_archive x
+			 WHERE x.reason='dispute_adjustment' AND d."extraPaymentId"=x."paymentId"`,
+			`DELETE FROM payments WHERE id IN (SELECT "paymentId" FROM payments_archive WHERE reason='dispute_adjustment')`,
+		},
+	},
+}
+
+var (
+	phasesMu      sync.RWMutex
+	appliedPhases = map[string]string{} // change ID → furthest phase applied
+)
+
+// MigrationPhase is the furthest phase `migrate` may apply (MIGRATION_PHASE, default expand).
+func MigrationPhase() string {
+	if os.Getenv("MIGRATION_PHASE") == PhaseContract {
+		return PhaseContract
+	}
+	return PhaseExpand
+}
+
+// CompatColumn returns the SQL expression for reading a column renamed by a schema change:
+// the new column once contracted, the new column falling back to the old one mid-rollout,
+// and the old column if the change hasn't been expanded yet.
+func CompatColumn(changeID, newCol, oldCol string) string {
+	phasesMu.RLock()
+	defer phasesMu.RUnlock()
+	switch appliedPhases[changeID] {
+	case PhaseContract:
+		return newCol
+	case PhaseExpand:
+		return "COALESCE(" + newCol + ", " + oldCol + ")"
+	default:
+		return oldCol
+	}
+}
+
+func ensureSchemaMigrationsTable() {
+	_, err := Pool.Exec(Unscoped(), `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			id TEXT NOT NULL,
+			phase TEXT NOT NULL,
+			"appliedAt" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			PRIMARY KEY (id, phase)
+		)`)
+	if err != nil {
+		log.Fatalf("Failed to create schema_migrations: %v", err)
+	}
+}
+
+// loadSchemaPhases reads which phase of every change has been applied.
+func loadSchemaPhases() map[string]string {
+	phases := map[string]string{}
+	rows, err := Pool.Query(Unscoped(), `SELECT id, phase FROM schema_migrations`)
+	if err != nil {
+		return phases
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, phase string
+		if rows.Scan(&id, &phase) == nil && phases[id] != PhaseContract {
+			phases[id] = phase
+		}
+	}
+	return phases
+}
+
+// schemaStep is one phase of one change waiting to be applied.
+type schemaStep struct {
+	Change SchemaChange
+	Phase  string
+}
+
+func (s schemaStep) statements() []string {
+	if s.Phase == PhaseContract {
+		return s.Change.Contract
+	}
+	return s.Change.Expand
+}
+
+// pendingSteps lists the phases still to apply, up to MigrationPhase().
+func pendingSteps(applied map[string]string) []schemaStep {
+	var steps []schemaStep
+	for _, change := range schemaChanges {
+		if applied[change.ID] == "" {
+			steps = append(steps, schemaStep{change, PhaseExpand})
+		}
+		if MigrationPhase() == PhaseContract && applied[change.ID] != PhaseContract {
+			steps = append(steps, schemaStep{change, PhaseContract})
+		}
+	}
+	return steps
+}
+
+// applySchemaChanges runs every pending phase and refreshes the compat state handlers read.
+func applySchemaChanges() {
+	ensureSchemaMigrationsTable()
+
+	ctx := Unscoped()
+	for _, step := range pendingSteps(loadSchemaPhases()) {
+		err := pgx.BeginFunc(ctx, Pool, func(tx pgx.Tx) error {
+			for _, stmt := range step.statements() {
+				if _, err := tx.Exec(ctx, stmt); err != nil {
+					return err
+				}
+			}
+			_, err := tx.Exec(ctx,
+				`INSERT INTO schema_migrations (id, phase) VALUES ($1, $2) ON CONFLICT DO NOTHING`, step.Change.ID, step.Phase)
+			return err
+		})
+		if err != nil {
+			log.Fatalf("Schema change %s (%s) failed: %v", step.Change.ID, step.Phase, err)
+		}
+		log.Printf("Schema change %s: %s applied\n", step.Change.ID, step.Phase)
+	}
+
+	phases := loadSchemaPhases()
+	phasesMu.Lock()
+	appliedPhases = phases
+	phasesMu.Unlock()
+}
+
+// ══════════════════════════════════════════════════
+// Dry run — locks & estimated duration of pending steps
+// ══════════════════════════════════════════════════
+
+var (
+	stmtTablePattern = regexp.MustCompile(`(?is)^\s*(?:ALTER TABLE(?:\s+IF EXISTS)?|UPDATE|DELETE FROM|CREATE (?:UNIQUE )?INDEX(?: CONCURRENTLY)?(?: IF NOT EXISTS)?\s+\S+\s+ON|(?:CREATE|DROP) TRIGGER(?: IF EXISTS)?\s+\S+\s+(?:BEFORE|AFTER)?.*?\bON)\s+("?\w+"?)`)
+	volatileDefault  = regexp.MustCompile(`(?i)DEFAULT\s+[^,;]*(gen_random_uuid|random\(|clock_timestamp)`)
+)
+
+// Rough throughput used to turn row counts into durations; real numbers depend on hardware and bloat.
+const (
+	updateRowsPerSec  = 20000
+	rewriteRowsPerSec = 50000
+	indexRowsPerSec   = 100000
+	scanRowsPerSec    = 500000
+)
+
+type lockEstimate struct {
+	Lock     string
+	Blocks   string
+	Scaling  string // instant | scan | rewrite | update | index
+	Table    string
+	Rows     int64
+	Size     string
+	Duration time.Duration
+}
+
+// classifyStatement reports the table lock a statement takes and how its cost scales.
+func classifyStatement(stmt string) lockEstimate {
+	s := strings.ToUpper(strings.Join(strings.Fields(stmt), " "))
+	e := lockEstimate{Lock: "—", Blocks: "nothing", Scaling: "instant"}
+	if m := stmtTablePattern.FindStringSubmatch(stmt); m != nil {
+		e.Table = m[1]
+	}
+
+	switch {
+	case strings.HasPrefix(s, "CREATE INDEX CONCURRENTLY"), strings.HasPrefix(s, "CREATE UNIQUE INDEX CONCURRENTLY"):
+		e.Lock, e.Blocks, e.Scaling = "SHARE UPDATE EXCLUSIVE", "other DDL only", "index"
+	case strings.HasPrefix(s, "CREATE INDEX"), strings.HasPrefix(s, "CREATE UNIQUE INDEX"):
+		e.Lock, e.Blocks, e.Scaling = "SHARE", "writes", "index"
+	case strings.HasPrefix(s, "UPDATE"), strings.HasPrefix(s, "DELETE"):
+		e.Lock, e.Blocks, e.Scaling = "ROW EXCLUSIVE + row locks", "writes to affected rows", "update"
+	case strings.HasPrefix(s, "CREATE TRIGGER"):
+		e.Lock, e.Blocks = "SHARE ROW EXCLUSIVE", "writes"
+	case strings.HasPrefix(s, "DROP TRIGGER"):
+		e.Lock, e.Blocks = "ACCESS EXCLUSIVE", "reads and writes"
+	case strings.HasPrefix(s, "ALTER TABLE"):
+		e.Lock, e.Blocks = "ACCESS EXCLUSIVE", "reads and writes"
+		switch {
+		case strings.Contains(s, " TYPE "), volatileDefault.MatchString(stmt):
+			e.Scaling = "rewrite"
+		case strings.Contains(s, "SET NOT NULL"), strings.Contains(s, "ADD CONSTRAINT") && !strings.Contains(s, "NOT VALID"):
+			e.Scaling = "scan"
+		}
+	}
+	return e
+}
+
+// DryRunMigrations prints every pending expand/contract step with the locks it takes and a
+// duration estimate based on the live table sizes, without changing anything.
+func DryRunMigrations(w io.Writer) {
+	ensureSchemaMigrationsTable()
+	steps := pendingSteps(loadSchemaPhases())
+
+	fmt.Fprintf(w, "Migration dry run — phase %s, region %s\n\n", MigrationPhase(), Region())
+	if len(steps) == 0 {
+		fmt.Fprintln(w, "Nothing to apply.")
+		return
+	}
+
+	var total time.Duration
+	for _, step := range steps {
+		fmt.Fprintf(w, "%s [%s] %s\n", step.Change.ID, step.Phase, step.Change.Description)
+
+		for _, stmt := range step.statements() {
+			e := classifyStatement(stmt)
+			if e.Table != "" {
+				var rows int64
+				var size string
+				Pool.QueryRow(Unscoped(),
+					`SELECT GREATEST(reltuples, 0)::bigint, pg_size_pretty(pg_total_relation_size(oid)) FROM pg_class WHERE oid=to_regclass($1)`,
+					e.Table).Scan(&rows, &size)
+				e.Rows, e.Size = rows, size
+
+				// Ask the planner how many rows a data change will touch
+				if e.Scaling == "update" {
+					if n, ok := explainRows(stmt); ok {
+						e.Rows = n
+					}
+				}
+			}
+
+			switch e.Scaling {
+			case "update":
+				e.Duration = time.Duration(e.Rows/updateRowsPerSec) * time.Second
+			case "rewrite":
+				e.Duration = time.Duration(e.Rows/rewriteRowsPerSec) * time.Second
+			case "index":
+				e.Duration = time.Duration(e.Rows/indexRowsPerSec) * time.Second
+			case "scan":
+				e.Duration = time.Duration(e.Rows/scanRowsPerSec) * time.Second
+			}
+			total += e.Duration
+
+			fmt.Fprintf(w, "  • %s\n", firstLine(stmt))
+			fmt.Fprintf(w, "    lock: %s (blocks %s)  table: %s  rows: %d  size: %s  cost: %s  est: %s\n",
+				e.Lock, e.Blocks, orDash(e.Table), e.Rows, orDash(e.Size), e.Scaling, e.Duration.Round(time.Second))
+		}
+		fmt.Fprintln(w)
+	}
+
+	fmt.Fprintf(w, "Estimated total: %s. ACCESS EXCLUSIVE steps are instant but queue behind long-running transactions — run with a lock_timeout.\n",
+		total.Round(time.Second))
+}
+
+func explainRows(stmt string) (int64, bool) {
+	var plan []map[string]any
+	if err := Pool.QueryRow(Unscoped(), `EXPLAIN (FORMAT JSON) `+stmt).Scan(&plan); err != nil || len(plan) == 0 {
+		return 0, false
+	}
+	node, _ := plan[0]["Plan"].(map[string]any)
+	// ModifyTable reports 0 rows; the scan under it carries the estimate
+	if children, ok := node["Plans"].([]any); ok && len(children) > 0 {
+		if child, ok := children[0].(map[string]any); ok {
+			node = child
+		}
+	}
+	rows, ok := node["Plan Rows"].(float64)
+	return int64(rows), ok
+}
+
+func firstLine(stmt string) string {
+	line := strings.TrimSpace(strings.SplitN(strings.TrimSpace(stmt), "\n", 2)[0])
+	if len(line) > 100 {
+		line = line[:97] + "..."
+	}
+	return line
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "—"
+	}
+	return s
+}
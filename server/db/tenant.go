@@ -0,0 +1,138 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// DefaultTenant owns every row written before white-labelling and every request
+// that doesn't identify a tenant.
+const DefaultTenant = "default"
+
+// tenantTables carry a "tenantId" column and a row-level security policy.
+var tenantTables = []string{`"user"`, "driver", "rides", "vehicle_types", "scheduled_rides", "saved_places", "fleets"}
+
+// tenantUniques replaces global unique constraints with per-tenant ones, so the same
+// phone number can sign up with two brands and each brand can have its own "Car".
+var tenantUniques = []struct{ table, constraint, index, column string }{
+	{`"user"`, "user_phone_number_key", "idx_user_tenant_phone", "phone_number"},
+	{`"user"`, "user_email_key", "idx_user_tenant_email", "email"},
+	{"driver", "driver_phone_number_key", "idx_driver_tenant_phone", "phone_number"},
+	{"driver", "driver_email_key", "idx_driver_tenant_email", "email"},
+	{"vehicle_types", "vehicle_types_name_key", "idx_vehicle_types_tenant_name", "name"},
+	{"fleets", "fleets_phoneNumber_key", "idx_fleets_tenant_phone", `"phoneNumber"`},
+}
+
+type tenantKey struct{}
+
+// WithTenant scopes every query run with ctx to one tenant's rows.
+func WithTenant(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenantID)
+}
+
+// allTenants is the app.tenant_id of a session that may see every tenant's rows. It can't
+// collide with a tenant ID, and a session without the setting sees no tenant rows at all.
+const allTenants = "*"
+
+// WithoutTenant lets queries run with ctx see every tenant's rows (admin tools, public links)
+// while keeping ctx's cancellation and statement timeout.
+func WithoutTenant(ctx context.Context) context.Context {
+	return context.WithValue(ctx, tenantKey{}, allTenants)
+}
+
+// Unscoped is the context for work that runs outside any request and spans tenants:
+// background workers, dispatch, socket events and migrations.
+func Unscoped() context.Context {
+	return WithoutTenant(context.Background())
+}
+
+// TenantFrom returns the tenant ctx is scoped to, or "" for unscoped (workers, admin, migrations).
+func TenantFrom(ctx context.Context) string {
+	tenantID, _ := ctx.Value(tenantKey{}).(string)
+	if tenantID == allTenants {
+		return ""
+	}
+	return tenantID
+}
+
+type statementTimeoutKey struct{}
+
+// WithStatementTimeout makes Postgres abort any single statement run with ctx after d,
+// so a slow query can't hold a pooled connection long after its caller gave up.
+func WithStatementTimeout(ctx context.Context, d time.Duration) context.Context {
+	return context.WithValue(ctx, statementTimeoutKey{}, d)
+}
+
+// connScope is what a pooled connection's session was last set up for.
+type connScope struct {
+	tenantID  string
+	timeoutMs int64
+}
+
+// connScopes remembers each pooled connection's scope, so the settings are only sent
+// when a connection changes hands between tenants or timeouts.
+var connScopes sync.Map // *pgx.Conn → connScope
+
+// scopeConn runs before a connection is handed out: it points the RLS policies at the caller's
+// tenant and applies the caller's statement timeout (none for workers and migrations). A caller
+// that is neither scoped nor explicitly unscoped gets an empty tenant, which matches no rows.
+func scopeConn(ctx context.Context, conn *pgx.Conn) bool {
+	timeout, _ := ctx.Value(statementTimeoutKey{}).(time.Duration)
+	tenantID, _ := ctx.Value(tenantKey{}).(string)
+	scope := connScope{tenantID: tenantID, timeoutMs: timeout.Milliseconds()}
+	if current, ok := connScopes.Load(conn); ok && current.(connScope) == scope {
+		return true
+	}
+	// A caller that just gave up shouldn't cost the pool a healthy connection
+	if _, err := conn.Exec(context.WithoutCancel(ctx), `SELECT set_config('app.tenant_id', $1, false), set_config('statement_timeout', $2, false)`,
+		scope.tenantID, strconv.FormatInt(scope.timeoutMs, 10)); err != nil {
+		// Drop the connection rather than serve it with another tenant's scope
+		return false
+	}
+	connScopes.Store(conn, scope)
+	return true
+}
+
+func forgetConn(conn *pgx.Conn) {
+	connScopes.Delete(conn)
+}
+
+// tenantSetting is the session's tenant: allTenants when unscoped, empty when never set.
+const tenantSetting = `COALESCE(current_setting('app.tenant_id', true), '')`
+
+// tagTenant adds "tenantId" to every tenant table and enforces isolation with row-level security.
+// Only sessions set to allTenants see every row, so a query that forgot its tenant fails closed
+// while workers and admin tools, which ask for it, keep working across tenants.
+func tagTenant() {
+	for _, table := range tenantTables {
+		sql := fmt.Sprintf(`
+			ALTER TABLE %[1]s ADD COLUMN IF NOT EXISTS "tenantId" TEXT;
+			ALTER TABLE %[1]s ALTER COLUMN "tenantId" SET DEFAULT COALESCE(NULLIF(NULLIF(%[2]s, ''), '%[4]s'), '%[3]s');
+			UPDATE %[1]s SET "tenantId"='%[3]s' WHERE "tenantId" IS NULL;
+			ALTER TABLE %[1]s ALTER COLUMN "tenantId" SET NOT NULL;
+			ALTER TABLE %[1]s ENABLE ROW LEVEL SECURITY;
+			ALTER TABLE %[1]s FORCE ROW LEVEL SECURITY;
+			DROP POLICY IF EXISTS tenant_isolation ON %[1]s;
+			CREATE POLICY tenant_isolation ON %[1]s
+				USING (%[2]s = '%[4]s' OR "tenantId" = %[2]s)
+				WITH CHECK (%[2]s = '%[4]s' OR "tenantId" = %[2]s);`, table, tenantSetting, DefaultTenant, allTenants)
+		if _, err := Pool.Exec(Unscoped(), sql); err != nil {
+			log.Fatalf("Tenant tagging failed for %s: %v", table, err)
+		}
+	}
+
+	for _, u := range tenantUniques {
+		sql := fmt.Sprintf(`
+			CREATE UNIQUE INDEX IF NOT EXISTS %[3]s ON %[1]s ("tenantId", %[4]s);
+			ALTER TABLE %[1]s DROP CONSTRAINT IF EXISTS %[2]s;`, u.table, u.constraint, u.index, u.column)
+		if _, err := Pool.Exec(Unscoped(), sql); err != nil {
+			log.Fatalf("Tenant unique index failed for %s: %v", u.index, err)
+		}
+	}
+}
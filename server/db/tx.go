@@ -0,0 +1,75 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// txAttempts is how many times a transaction runs before a serialization failure or deadlock
+// is returned to the caller.
+const txAttempts = 3
+
+// txRetryDelay is the base wait before a retry; each attempt doubles it, plus jitter so the
+// transactions that collided don't collide again.
+const txRetryDelay = 20 * time.Millisecond
+
+// Querier is a transaction or Pool, for helpers that run inside their caller's transaction
+// when there is one.
+type Querier interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// TxBeginner is Pool, or anything else that starts transactions.
+type TxBeginner interface {
+	BeginTx(ctx context.Context, opts pgx.TxOptions) (pgx.Tx, error)
+}
+
+// WithTx runs fn in a read committed transaction on Pool, committing if it returns nil and
+// rolling back otherwise. See RunTx for retries.
+func WithTx(ctx context.Context, fn func(tx pgx.Tx) error) error {
+	return RunTx(ctx, Pool, pgx.TxOptions{}, fn)
+}
+
+// WithSerializableTx runs fn in a serializable transaction on Pool, for checks that must still
+// hold at commit, e.g. a limit counted over rows another transaction may be inserting.
+func WithSerializableTx(ctx context.Context, fn func(tx pgx.Tx) error) error {
+	return RunTx(ctx, Pool, pgx.TxOptions{IsoLevel: pgx.Serializable}, fn)
+}
+
+// RunTx runs fn in a transaction on b. When Postgres aborts it with a serialization failure or
+// deadlock the whole transaction, fn included, runs again up to txAttempts times, so fn must
+// only write through tx and must not keep state from a failed attempt.
+func RunTx(ctx context.Context, b TxBeginner, opts pgx.TxOptions, fn func(tx pgx.Tx) error) error {
+	var err error
+	for attempt := 0; attempt < txAttempts; attempt++ {
+		if attempt > 0 {
+			delay := txRetryDelay<<(attempt-1) + time.Duration(rand.Int63n(int64(txRetryDelay)))
+			select {
+			case <-ctx.Done():
+				return err
+			case <-time.After(delay):
+			}
+		}
+		err = pgx.BeginTxFunc(ctx, b, opts, fn)
+		if !retryableTx(err) {
+			return err
+		}
+	}
+	return err
+}
+
+// retryableTx reports whether err means the transaction lost a race and may succeed if rerun.
+func retryableTx(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	return pgErr.Code == "40001" || pgErr.Code == "40P01" // serialization_failure, deadlock_detected
+}
@@ -0,0 +1,220 @@
+// Package diag implements `server --check`: a readiness report covering configuration,
+// datastores, migrations and third-party APIs, for deploy pipelines and new environments.
+package diag
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"ridewave/backup"
+	"ridewave/db"
+	"ridewave/utils"
+)
+
+const (
+	statusOK   = "OK"
+	statusWarn = "WARN"
+	statusFail = "FAIL"
+)
+
+const checkTimeout = 10 * time.Second
+
+// postgresReady lets later checks skip the database when it's unreachable.
+var postgresReady bool
+
+// result is one line of the readiness report.
+type result struct {
+	Name     string
+	Status   string
+	Detail   string
+	Duration time.Duration
+}
+
+// requiredEnv must be set for the server to work at all.
+var requiredEnv = []string{"DATABASE_URL", "ACCESS_TOKEN_SECRET"}
+
+// integrationEnv is needed for logins, maps and push notifications.
+var integrationEnv = []string{"OLA_MAPS_API_KEY", "TWILIO_ACCOUNT_SID", "TWILIO_AUTH_TOKEN", "TWILIO_SERVICE_SID"}
+
+// recommendedEnv has insecure or degraded fallbacks when unset.
+var recommendedEnv = []string{"ADMIN_JWT_SECRET", "API_KEY", "RIDE_SHARE_SECRET", "PAYMENT_WEBHOOK_SECRET",
+	"EMAIL_ACTIVATION_SECRET", "REDIS_ADDR", "SMTP_HOST"}
+
+// Run performs every check, prints the report and returns the process exit code.
+func Run(w io.Writer) int {
+	fmt.Fprintf(w, "RideWave readiness check (region %s)\n\n", db.Region())
+
+	checks := []struct {
+		name string
+		fn   func(ctx context.Context) (string, string)
+	}{
+		{"env", checkEnv},
+		{"postgres", checkPostgres},
+		{"migrations", checkMigrations},
+		{"redis", checkRedis},
+		{"ola maps", checkOlaMaps},
+		{"twilio", checkTwilio},
+		{"fcm", checkFCM},
+		{"backups", checkBackups},
+	}
+
+	ready := true
+	for _, check := range checks {
+		ctx, cancel := context.WithTimeout(context.Background(), checkTimeout)
+		start := time.Now()
+		status, detail := check.fn(ctx)
+		cancel()
+
+		detail = strings.Join(strings.Fields(detail), " ")
+		r := result{Name: check.name, Status: status, Detail: detail, Duration: time.Since(start)}
+		fmt.Fprintf(w, "  [%-4s] %-11s %s (%dms)\n", r.Status, r.Name, r.Detail, r.Duration.Milliseconds())
+		if r.Status == statusFail {
+			ready = false
+		}
+	}
+
+	if db.Pool != nil {
+		db.Close()
+	}
+	if !ready {
+		fmt.Fprintln(w, "\nNot ready: fix the FAIL lines above.")
+		return 1
+	}
+	fmt.Fprintln(w, "\nReady.")
+	return 0
+}
+
+func checkEnv(_ context.Context) (string, string) {
+	var missing, missingIntegrations, missingRecommended, problems []string
+	for _, key := range requiredEnv {
+		if db.RegionEnv(key) == "" {
+			missing = append(missing, key)
+		}
+	}
+	for _, key := range integrationEnv {
+		if os.Getenv(key) == "" {
+			missingIntegrations = append(missingIntegrations, key)
+		}
+	}
+	for _, key := range recommendedEnv {
+		if db.RegionEnv(key) == "" {
+			missingRecommended = append(missingRecommended, key)
+		}
+	}
+
+	if port := os.Getenv("PORT"); port != "" {
+		if _, err := strconv.Atoi(port); err != nil {
+			problems = append(problems, "PORT is not a number")
+		}
+	}
+	if val := os.Getenv("PLATFORM_FEE_PERCENTAGE"); val != "" {
+		if _, err := strconv.ParseFloat(val, 64); err != nil {
+			problems = append(problems, "PLATFORM_FEE_PERCENTAGE is not a number")
+		}
+	}
+
+	if missing = append(missing, missingIntegrations...); len(missing) > 0 {
+		problems = append(problems, "missing: "+strings.Join(missing, ", "))
+	}
+	if len(problems) > 0 {
+		return statusFail, strings.Join(problems, "; ")
+	}
+	if len(missingRecommended) > 0 {
+		return statusWarn, "using fallbacks for: " + strings.Join(missingRecommended, ", ")
+	}
+	return statusOK, "all variables set"
+}
+
+func checkPostgres(ctx context.Context) (string, string) {
+	if err := db.Open(); err != nil {
+		return statusFail, err.Error()
+	}
+	var version string
+	if err := db.Pool.QueryRow(ctx, `SHOW server_version`).Scan(&version); err != nil {
+		return statusFail, err.Error()
+	}
+	postgresReady = true
+	return statusOK, "connected, PostgreSQL " + version
+}
+
+func checkMigrations(ctx context.Context) (string, string) {
+	if !postgresReady {
+		return statusFail, "skipped: no database connection"
+	}
+	pending, err := db.PendingMigrations(ctx)
+	if err != nil {
+		return statusFail, err.Error()
+	}
+	if len(pending) > 0 {
+		return statusWarn, fmt.Sprintf("%d pending, applied on startup or with `server migrate`: %s", len(pending), strings.Join(pending, ", "))
+	}
+	return statusOK, "schema is current (phase " + db.MigrationPhase() + ")"
+}
+
+func checkRedis(ctx context.Context) (string, string) {
+	addr := db.RegionEnv("REDIS_ADDR")
+	if addr == "" {
+		addr = "localhost:6379"
+	}
+	client := redis.NewClient(&redis.Options{Addr: addr, Password: db.RegionEnv("REDIS_PASSWORD")})
+	defer client.Close()
+	if err := client.Ping(ctx).Err(); err != nil {
+		return statusFail, addr + ": " + err.Error()
+	}
+	return statusOK, "connected to " + addr
+}
+
+func checkOlaMaps(_ context.Context) (string, string) {
+	if os.Getenv("OLA_MAPS_API_KEY") == "" {
+		return statusFail, "OLA_MAPS_API_KEY is not set"
+	}
+	if _, err := utils.NewOlaMapsClient().Autocomplete("Connaught Place"); err != nil {
+		return statusFail, err.Error()
+	}
+	return statusOK, "autocomplete call succeeded"
+}
+
+// checkTwilio reads the Verify service, which proves the credentials without sending an SMS.
+func checkTwilio(ctx context.Context) (string, string) {
+	sid, token, service := os.Getenv("TWILIO_ACCOUNT_SID"), os.Getenv("TWILIO_AUTH_TOKEN"), os.Getenv("TWILIO_SERVICE_SID")
+	if sid == "" || token == "" || service == "" {
+		return statusFail, "twilio credentials not configured"
+	}
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "https://verify.twilio.com/v2/Services/"+service, nil)
+	req.SetBasicAuth(sid, token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return statusFail, err.Error()
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return statusFail, "verify service lookup: " + resp.Status
+	}
+	return statusOK, "verify service reachable"
+}
+
+// checkFCM exchanges the service account for a token and sends a validate-only message, which
+// FCM checks without delivering anything.
+func checkFCM(ctx context.Context) (string, string) {
+	if err := utils.CheckFCM(ctx); err != nil {
+		return statusFail, err.Error()
+	}
+	return statusOK, "validate-only send accepted"
+}
+
+func checkBackups(_ context.Context) (string, string) {
+	if os.Getenv("BACKUP_ENABLED") != "true" {
+		return statusWarn, "scheduled backups are disabled (BACKUP_ENABLED)"
+	}
+	if _, err := backup.LoadConfig(); err != nil {
+		return statusFail, err.Error()
+	}
+	return statusOK, "configured"
+}
@@ -0,0 +1,149 @@
+package diag
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"ridewave/db"
+)
+
+// Readiness probe for load balancers: can this instance serve traffic right now? Unlike `--check`
+// it uses the running server's connections, and results are cached so frequent probes from
+// several balancers don't each hit Postgres, Redis or a paid API.
+
+const probeTimeout = 2 * time.Second
+
+// draining is set once shutdown starts; readiness then fails so balancers stop sending traffic
+// before connections are closed.
+var draining atomic.Bool
+
+// StartDraining marks the instance as shutting down.
+func StartDraining() { draining.Store(true) }
+
+// Draining reports whether shutdown has started.
+func Draining() bool { return draining.Load() }
+
+// DrainDelay is how long shutdown waits after failing readiness before it stops accepting
+// connections, so balancers have time to notice (SHUTDOWN_DRAIN_SECONDS, default 10; 0 skips it).
+func DrainDelay() time.Duration {
+	if val, err := strconv.Atoi(os.Getenv("SHUTDOWN_DRAIN_SECONDS")); err == nil && val >= 0 {
+		return time.Duration(val) * time.Second
+	}
+	return 10 * time.Second
+}
+
+// readyCacheTTL is how long Postgres and Redis results are reused (READINESS_CACHE_SECONDS, default 5).
+func readyCacheTTL() time.Duration {
+	if val, err := strconv.Atoi(os.Getenv("READINESS_CACHE_SECONDS")); err == nil && val >= 0 {
+		return time.Duration(val) * time.Second
+	}
+	return 5 * time.Second
+}
+
+// externalCacheTTL is how long Ola Maps and Twilio results are reused
+// (READINESS_EXTERNAL_CACHE_SECONDS, default 300); each check is a real API call.
+func externalCacheTTL() time.Duration {
+	if val, err := strconv.Atoi(os.Getenv("READINESS_EXTERNAL_CACHE_SECONDS")); err == nil && val >= 0 {
+		return time.Duration(val) * time.Second
+	}
+	return 5 * time.Minute
+}
+
+// ProbeResult is one dependency's latest check.
+type ProbeResult struct {
+	Status    string    `json:"status"` // OK or FAIL
+	Detail    string    `json:"detail,omitempty"`
+	LatencyMs int64     `json:"latencyMs"`
+	CheckedAt time.Time `json:"checkedAt"`
+}
+
+// ReadyReport is the readiness probe's answer.
+type ReadyReport struct {
+	Ready    bool                   `json:"ready"`
+	Draining bool                   `json:"draining"`
+	Checks   map[string]ProbeResult `json:"checks"`
+	// External covers third-party APIs when READINESS_CHECK_EXTERNAL=true. Every instance shares
+	// them, so a failure is reported but doesn't make the instance unready: taking the whole
+	// fleet out of rotation wouldn't bring Ola Maps back.
+	External map[string]ProbeResult `json:"external,omitempty"`
+}
+
+// cachedProbe runs fn at most once per ttl; concurrent callers wait for the run in flight.
+type cachedProbe struct {
+	fn  func(ctx context.Context) (string, string)
+	ttl func() time.Duration
+
+	mu     sync.Mutex
+	result ProbeResult
+}
+
+func (p *cachedProbe) get(ctx context.Context) ProbeResult {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.result.CheckedAt.IsZero() && time.Since(p.result.CheckedAt) < p.ttl() {
+		return p.result
+	}
+	// A probe that hangs up mustn't cache a failure for everyone else
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), probeTimeout)
+	defer cancel()
+	start := time.Now()
+	status, detail := p.fn(ctx)
+	if status == statusOK {
+		detail = ""
+	}
+	p.result = ProbeResult{Status: status, Detail: detail, LatencyMs: time.Since(start).Milliseconds(), CheckedAt: time.Now()}
+	return p.result
+}
+
+var readyProbes = map[string]*cachedProbe{
+	"postgres": {fn: pingPostgres, ttl: readyCacheTTL},
+	"redis":    {fn: pingRedis, ttl: readyCacheTTL},
+}
+
+var externalProbes = map[string]*cachedProbe{
+	"olaMaps": {fn: checkOlaMaps, ttl: externalCacheTTL},
+	"twilio":  {fn: checkTwilio, ttl: externalCacheTTL},
+}
+
+func pingPostgres(ctx context.Context) (string, string) {
+	if db.Pool == nil {
+		return statusFail, "not connected"
+	}
+	if err := db.Pool.Ping(ctx); err != nil {
+		return statusFail, err.Error()
+	}
+	return statusOK, ""
+}
+
+func pingRedis(ctx context.Context) (string, string) {
+	if db.RedisClient == nil {
+		return statusFail, "not connected"
+	}
+	if err := db.RedisClient.Ping(ctx).Err(); err != nil {
+		return statusFail, err.Error()
+	}
+	return statusOK, ""
+}
+
+// Readiness reports whether the instance should receive traffic: it isn't draining and
+// Postgres and Redis answer.
+func Readiness(ctx context.Context) ReadyReport {
+	report := ReadyReport{Draining: Draining(), Checks: map[string]ProbeResult{}}
+	report.Ready = !report.Draining
+	for name, probe := range readyProbes {
+		res := probe.get(ctx)
+		report.Checks[name] = res
+		report.Ready = report.Ready && res.Status == statusOK
+	}
+	if os.Getenv("READINESS_CHECK_EXTERNAL") == "true" {
+		report.External = map[string]ProbeResult{}
+		for name, probe := range externalProbes {
+			report.External[name] = probe.get(ctx)
+		}
+	}
+	return report
+}
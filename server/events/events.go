@@ -0,0 +1,66 @@
+// Package events is an in-process domain event bus. Handlers publish what happened to a
+// ride; subscribers (such as the ride timeline) react without the publisher knowing about them.
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// Ride lifecycle event types.
+const (
+	RideRequested = "requested"
+	RideOffered   = "offered"
+	RideDeclined  = "declined"
+	RideAccepted  = "accepted"
+	DriverArrived = "arrived"
+	RideStarted   = "started"
+	StopCompleted = "stop_completed"
+	RideCompleted = "completed"
+	RideCancelled = "cancelled"
+	RidePaid      = "paid"
+	RideRated     = "rated"
+	RideTipped    = "tipped"
+)
+
+// Who caused an event.
+const (
+	ActorUser   = "user"
+	ActorDriver = "driver"
+	ActorSystem = "system"
+)
+
+// Event is something that happened to a ride.
+type Event struct {
+	Type      string
+	RideID    string
+	ActorType string
+	ActorID   string
+	Data      map[string]any
+	At        time.Time
+}
+
+var (
+	mu          sync.RWMutex
+	subscribers []func(Event)
+)
+
+// Subscribe registers fn to receive every published event.
+func Subscribe(fn func(Event)) {
+	mu.Lock()
+	subscribers = append(subscribers, fn)
+	mu.Unlock()
+}
+
+// Publish delivers e to every subscriber, in order, on the caller's goroutine.
+func Publish(e Event) {
+	if e.At.IsZero() {
+		e.At = time.Now()
+	}
+	mu.RLock()
+	subs := subscribers
+	mu.RUnlock()
+	for _, fn := range subs {
+		fn(e)
+	}
+}
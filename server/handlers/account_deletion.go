@@ -0,0 +1,416 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+	"ridewave/db"
+	"ridewave/models"
+	"ridewave/stores"
+	"ridewave/utils"
+)
+
+// ══════════════════════════════════════════════════
+// Account Deletion — GDPR erasure after a grace period; financial records are kept
+// ══════════════════════════════════════════════════
+
+const (
+	deletionPending   = "pending"
+	deletionCancelled = "cancelled"
+	deletionCompleted = "completed"
+)
+
+const accountDeletionLockKey = "account_deletions:lock"
+
+// accountDeletionGrace is how long a deletion request can still be cancelled
+// (ACCOUNT_DELETION_GRACE_DAYS, default 30).
+func accountDeletionGrace() time.Duration {
+	if val, err := strconv.Atoi(os.Getenv("ACCOUNT_DELETION_GRACE_DAYS")); err == nil && val >= 0 {
+		return time.Duration(val) * 24 * time.Hour
+	}
+	return 30 * 24 * time.Hour
+}
+
+type accountDeletion struct {
+	ID           string     `json:"id"`
+	AccountType  string     `json:"accountType"`
+	AccountID    string     `json:"accountId"`
+	Reason       string     `json:"reason"`
+	Status       string     `json:"status"`
+	ScheduledFor time.Time  `json:"scheduledFor"`
+	ProcessedAt  *time.Time `json:"processedAt"`
+	ProcessedBy  *string    `json:"processedBy"`
+	Note         string     `json:"note"`
+	CreatedAt    time.Time  `json:"createdAt"`
+}
+
+const accountDeletionSelectCols = `id, "accountType", "accountId", reason, status, "scheduledFor", "processedAt", "processedBy", note, "createdAt"`
+
+func scanAccountDeletion(scanner interface{ Scan(dest ...any) error }, d *accountDeletion) error {
+	return scanner.Scan(&d.ID, &d.AccountType, &d.AccountID, &d.Reason, &d.Status, &d.ScheduledFor, &d.ProcessedAt,
+		&d.ProcessedBy, &d.Note, &d.CreatedAt)
+}
+
+// DELETE /api/v1/user/account
+func RequestUserAccountDeletion(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+	requestAccountDeletion(c, noteEntityUser, user.ID,
+		`SELECT EXISTS(SELECT 1 FROM rides WHERE "userId"=$1 AND status IN ('Requested', 'Accepted', 'Arriving', 'InProgress'))`)
+}
+
+// DELETE /api/v1/driver/account
+func RequestDriverAccountDeletion(c *gin.Context) {
+	driver := c.MustGet("driver").(*models.Driver)
+	requestAccountDeletion(c, noteEntityDriver, driver.ID,
+		`SELECT EXISTS(SELECT 1 FROM rides WHERE "driverId"=$1 AND status IN ('Accepted', 'Arriving', 'InProgress'))`)
+}
+
+// requestAccountDeletion schedules the account's erasure; asking again returns the pending request.
+func requestAccountDeletion(c *gin.Context, accountType, accountID, activeRideQuery string) {
+	var body struct {
+		Reason string `json:"reason"`
+	}
+	c.ShouldBindJSON(&body) // the reason is optional
+
+	ctx := c.Request.Context()
+	var busy bool
+	db.Pool.QueryRow(ctx, activeRideQuery, accountID).Scan(&busy)
+	if busy {
+		utils.RespondError(c, http.StatusConflict, "Finish or cancel your current ride before deleting your account", nil)
+		return
+	}
+
+	var req accountDeletion
+	err := scanAccountDeletion(db.Pool.QueryRow(ctx,
+		`INSERT INTO account_deletions ("accountType", "accountId", reason, "scheduledFor") VALUES ($1, $2, $3, $4)
+		 ON CONFLICT ("accountType", "accountId") WHERE status='pending' DO UPDATE SET reason=account_deletions.reason
+		 RETURNING `+accountDeletionSelectCols,
+		accountType, accountID, strings.TrimSpace(body.Reason), time.Now().Add(accountDeletionGrace())), &req)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to request account deletion", err)
+		return
+	}
+	utils.RespondSuccess(c, http.StatusAccepted,
+		"Your account will be deleted on "+req.ScheduledFor.Format("2 Jan 2006")+". You can cancel until then.",
+		gin.H{"deletion": req})
+}
+
+// POST /api/v1/user/account/cancel-deletion
+func CancelUserAccountDeletion(c *gin.Context) {
+	cancelOwnAccountDeletion(c, noteEntityUser, c.MustGet("user").(*models.User).ID)
+}
+
+// POST /api/v1/driver/account/cancel-deletion
+func CancelDriverAccountDeletion(c *gin.Context) {
+	cancelOwnAccountDeletion(c, noteEntityDriver, c.MustGet("driver").(*models.Driver).ID)
+}
+
+func cancelOwnAccountDeletion(c *gin.Context, accountType, accountID string) {
+	tag, err := db.Pool.Exec(c.Request.Context(),
+		`UPDATE account_deletions SET status=$3, "processedAt"=NOW(), "processedBy"=$1 || ':' || $2
+		 WHERE "accountType"=$1 AND "accountId"=$2 AND status=$4`, accountType, accountID, deletionCancelled, deletionPending)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to cancel account deletion", err)
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		utils.RespondError(c, http.StatusNotFound, "No pending account deletion", nil)
+		return
+	}
+	utils.RespondSuccess(c, http.StatusOK, "Account deletion cancelled", nil)
+}
+
+// StartAccountDeletionWorker erases accounts whose grace period has ended, once an hour.
+func StartAccountDeletionWorker(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				processDueAccountDeletions()
+			case <-ctx.Done():
+				utils.Logger.Info("Account Deletion Worker shutting down...")
+				return
+			}
+		}
+	}()
+}
+
+func processDueAccountDeletions() {
+	ctx := db.Unscoped()
+	// Only one instance works the queue at a time
+	claimed, err := db.RedisClient.SetNX(ctx, accountDeletionLockKey, 1, 30*time.Minute).Result()
+	if err != nil || !claimed {
+		return
+	}
+	defer db.RedisClient.Del(ctx, accountDeletionLockKey)
+
+	rows, err := db.Pool.Query(ctx,
+		`SELECT `+accountDeletionSelectCols+` FROM account_deletions WHERE status=$1 AND "scheduledFor" <= NOW()
+		 ORDER BY "scheduledFor" LIMIT 500`, deletionPending)
+	if err != nil {
+		utils.Logger.Error("Failed to load due account deletions", zap.Error(err))
+		return
+	}
+	var due []accountDeletion
+	for rows.Next() {
+		var d accountDeletion
+		if scanAccountDeletion(rows, &d) == nil {
+			due = append(due, d)
+		}
+	}
+	rows.Close()
+
+	for _, d := range due {
+		err := completeAccountDeletion(ctx, d, "system")
+		if errors.Is(err, errDeletionNotPending) {
+			continue // cancelled since it was loaded
+		}
+		if err != nil {
+			utils.Logger.Error("Account deletion failed", zap.String("requestId", d.ID), zap.Error(err))
+		}
+	}
+}
+
+// errDeletionNotPending means the request was cancelled or completed after it was loaded.
+var errDeletionNotPending = errors.New("account deletion request no longer pending")
+
+// completeAccountDeletion erases the account and closes its request. The request row stays locked
+// until commit, so a cancel racing the erasure either lands first and stops it or waits and fails.
+func completeAccountDeletion(ctx context.Context, d accountDeletion, processedBy string) error {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var status string
+	if err := tx.QueryRow(ctx, `SELECT status FROM account_deletions WHERE id=$1 FOR UPDATE`, d.ID).Scan(&status); err != nil {
+		return err
+	}
+	if status != deletionPending {
+		return errDeletionNotPending
+	}
+
+	if d.AccountType == noteEntityDriver {
+		err = anonymizeDriver(ctx, tx, d.AccountID)
+	} else {
+		err = anonymizeUser(ctx, tx, d.AccountID)
+	}
+	if err != nil {
+		return err
+	}
+	tag, err := tx.Exec(ctx,
+		`UPDATE account_deletions SET status=$2, "processedAt"=NOW(), "processedBy"=$3 WHERE id=$1 AND status=$4`,
+		d.ID, deletionCompleted, processedBy, deletionPending)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return errDeletionNotPending
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	purgeAccountRedis(ctx, d.AccountType, d.AccountID)
+	utils.Logger.Info("Account erased", zap.String("accountType", d.AccountType), zap.String("accountId", d.AccountID),
+		zap.String("by", processedBy))
+	return nil
+}
+
+// anonymizeUser strips the rider's PII. Rides, payments and consent history stay, tied to the ID only.
+func anonymizeUser(ctx context.Context, tx pgx.Tx, userID string) error {
+	var phone string
+	err := tx.QueryRow(ctx,
+		`UPDATE "user" u SET name='Deleted user', phone_number='deleted:' || u.id, email=NULL, "notificationToken"=NULL,
+		 "preferredLanguage"=NULL, status='deleted', "updatedAt"=NOW()
+		 FROM (SELECT id, phone_number FROM "user" WHERE id=$1 FOR UPDATE) old
+		 WHERE u.id=old.id RETURNING old.phone_number`, userID).Scan(&phone)
+	if err != nil {
+		return err
+	}
+	for _, q := range []string{
+		`DELETE FROM saved_places WHERE "userId"=$1`,
+		`DELETE FROM marketing_consent WHERE "userId"=$1`,
+		`DELETE FROM notification_preferences WHERE "recipientType"='user' AND "recipientId"=$1`,
+		`UPDATE scheduled_rides SET status='cancelled', "updatedAt"=NOW() WHERE "userId"=$1 AND status='scheduled'`,
+	} {
+		if _, err := tx.Exec(ctx, q, userID); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM zone_allowlist WHERE phone_number=$1`, phone); err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `DELETE FROM account_devices WHERE "entityType"=$1 AND "entityId"=$2`, noteEntityUser, userID)
+	return err
+}
+
+// anonymizeDriver strips the driver's PII and documents. Rides, wallet ledger and payouts stay.
+func anonymizeDriver(ctx context.Context, tx pgx.Tx, driverID string) error {
+	tag, err := tx.Exec(ctx,
+		`UPDATE driver SET name='Deleted driver', phone_number='deleted:' || id, email='deleted:' || id,
+		 registration_number='deleted:' || id, driving_license='', "rcBook"=NULL, "profileImage"=NULL, vehicle_color=NULL,
+		 "notificationToken"=NULL, languages='{}', "preferredLanguage"=NULL, status='deleted', "isOnline"=FALSE, "updatedAt"=NOW()
+		 WHERE id=$1`, driverID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	for _, q := range []string{
+		`DELETE FROM vehicles WHERE "driverId"=$1`,
+		`DELETE FROM driver_location WHERE "driverId"=$1`,
+		`DELETE FROM driver_diagnostics WHERE "driverId"=$1`,
+		`DELETE FROM notification_preferences WHERE "recipientType"='driver' AND "recipientId"=$1`,
+	} {
+		if _, err := tx.Exec(ctx, q, driverID); err != nil {
+			return err
+		}
+	}
+	_, err = tx.Exec(ctx, `DELETE FROM account_devices WHERE "entityType"=$1 AND "entityId"=$2`, noteEntityDriver, driverID)
+	return err
+}
+
+// purgeAccountRedis drops live state and per-identity keys the account left in Redis.
+func purgeAccountRedis(ctx context.Context, accountType, accountID string) {
+	identity := accountType + ":" + accountID
+	keys := []string{"ratelimit:identity:" + identity}
+	if accountType == noteEntityDriver {
+		stores.RemoveDriver(ctx, accountID)
+		keys = append(keys, stores.DriverActiveRideKeyPrefix+accountID)
+	}
+	for _, pattern := range []string{"idempotency:" + identity + ":*", "ratelimit:route:*:" + identity} {
+		iter := db.RedisClient.Scan(ctx, 0, pattern, 1000).Iterator()
+		for iter.Next(ctx) {
+			keys = append(keys, iter.Val())
+		}
+	}
+	if err := db.RedisClient.Del(ctx, keys...).Err(); err != nil {
+		utils.Logger.Warn("Failed to purge account Redis state", zap.String("account", identity), zap.Error(err))
+	}
+	if err := utils.RevokeSessions(ctx, accountType, accountID); err != nil {
+		utils.Logger.Warn("Failed to revoke erased account's sessions", zap.String("account", identity), zap.Error(err))
+	}
+}
+
+// ══════════════════════════════════════════════════
+// Admin: Deletion Request Queue
+// ══════════════════════════════════════════════════
+
+// GET /api/v1/admin/account-deletions?status=pending
+func AdminGetAccountDeletions(c *gin.Context) {
+	status := c.DefaultQuery("status", deletionPending)
+	rows, err := db.Pool.Query(adminContext(c),
+		`SELECT `+accountDeletionSelectCols+` FROM account_deletions WHERE status=$1 ORDER BY "scheduledFor" LIMIT 200`, status)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to fetch deletion requests", err)
+		return
+	}
+	var requests []accountDeletion
+	for rows.Next() {
+		var d accountDeletion
+		if scanAccountDeletion(rows, &d) == nil {
+			requests = append(requests, d)
+		}
+	}
+	rows.Close()
+
+	// Name, phone and any wallet balance still owed help decide whether to hold a request
+	type deletionRow struct {
+		accountDeletion
+		Name          string   `json:"name"`
+		PhoneNumber   string   `json:"phoneNumber"`
+		WalletBalance *float64 `json:"walletBalance,omitempty"`
+	}
+	result := []deletionRow{}
+	for _, d := range requests {
+		row := deletionRow{accountDeletion: d}
+		if d.AccountType == noteEntityDriver {
+			db.Pool.QueryRow(adminContext(c),
+				`SELECT d.name, d.phone_number, w.balance FROM driver d LEFT JOIN wallets w ON w."driverId"=d.id WHERE d.id=$1`,
+				d.AccountID).Scan(&row.Name, &row.PhoneNumber, &row.WalletBalance)
+		} else {
+			db.Pool.QueryRow(adminContext(c),
+				`SELECT COALESCE(name, ''), phone_number FROM "user" WHERE id=$1`, d.AccountID).Scan(&row.Name, &row.PhoneNumber)
+		}
+		result = append(result, row)
+	}
+	utils.RespondSuccess(c, http.StatusOK, "Account deletion requests", gin.H{"requests": result})
+}
+
+// pendingDeletion loads a pending request for an admin action.
+func pendingDeletion(c *gin.Context) (*accountDeletion, bool) {
+	var d accountDeletion
+	err := scanAccountDeletion(db.Pool.QueryRow(adminContext(c),
+		`SELECT `+accountDeletionSelectCols+` FROM account_deletions WHERE id=$1 AND status=$2`,
+		c.Param("id"), deletionPending), &d)
+	if errors.Is(err, pgx.ErrNoRows) {
+		utils.RespondError(c, http.StatusNotFound, "Pending deletion request not found", nil)
+		return nil, false
+	}
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to load deletion request", err)
+		return nil, false
+	}
+	return &d, true
+}
+
+// POST /api/v1/admin/account-deletion/:id/process — erase now, without waiting out the grace period
+func AdminProcessAccountDeletion(c *gin.Context) {
+	d, ok := pendingDeletion(c)
+	if !ok {
+		return
+	}
+	admin := c.MustGet("admin").(*models.AdminAccount)
+	err := completeAccountDeletion(adminContext(c), *d, admin.Email)
+	if errors.Is(err, errDeletionNotPending) {
+		utils.RespondError(c, http.StatusNotFound, "Pending deletion request not found", nil)
+		return
+	}
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to erase account", err)
+		return
+	}
+	utils.RespondSuccess(c, http.StatusOK, "Account erased", gin.H{"id": d.ID})
+}
+
+// POST /api/v1/admin/account-deletion/:id/cancel — e.g. while a dispute or unpaid fare is open
+func AdminCancelAccountDeletion(c *gin.Context) {
+	var body struct {
+		Note string `json:"note" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "A note explaining the cancellation is required", err)
+		return
+	}
+	d, ok := pendingDeletion(c)
+	if !ok {
+		return
+	}
+	admin := c.MustGet("admin").(*models.AdminAccount)
+	tag, err := db.Pool.Exec(adminContext(c),
+		`UPDATE account_deletions SET status=$2, "processedAt"=NOW(), "processedBy"=$3, note=$4 WHERE id=$1 AND status=$5`,
+		d.ID, deletionCancelled, admin.Email, body.Note, deletionPending)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to cancel deletion request", err)
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		utils.RespondError(c, http.StatusNotFound, "Pending deletion request not found", nil)
+		return
+	}
+	utils.RespondSuccess(c, http.StatusOK, "Deletion request cancelled", gin.H{"id": d.ID})
+}
@@ -1,26 +1,74 @@
 package handlers
 
 import (
-	"context"
+	"errors"
 	"math"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"ridewave/db"
+	"ridewave/middleware"
 	"ridewave/models"
+	"ridewave/promos"
+	"ridewave/repository"
 	"ridewave/stores"
 	"ridewave/utils"
+
+	"go.uber.org/zap"
 )
 
 // RegisterAdminRoutes defines all administrative API endpoints
-func RegisterAdminRoutes(r *gin.Engine, adminMiddleware gin.HandlerFunc) {
-	adminGroup := r.Group("/api/v1/admin")
-	adminGroup.Use(adminMiddleware)
+func RegisterAdminRoutes(r gin.IRouter, adminMiddleware gin.HandlerFunc) {
+	// Login is the only admin endpoint reachable without a token
+	r.POST("/admin/auth/login", middleware.RateLimitRoute("login", 5), AdminLogin)
+
+	// Role scopes (superadmin passes every check)
+	support := middleware.RequireAdminRole(middleware.RoleSupport)
+	finance := middleware.RequireAdminRole(middleware.RoleFinance)
+	superadmin := middleware.RequireAdminRole()
+
+	adminGroup := r.Group("/admin")
+	adminGroup.Use(adminMiddleware, middleware.AdminAudit())
 	{
 		// Dashboard
 		adminGroup.GET("/dashboard", AdminDashboard)
+		adminGroup.GET("/regions/summary", finance, AdminRegionSummary)
+		adminGroup.GET("/search", AdminGlobalSearch)
+
+		// Admin Accounts
+		adminGroup.GET("/me", AdminGetMe)
+		adminGroup.GET("/accounts", superadmin, AdminGetAccounts)
+		adminGroup.POST("/accounts", superadmin, AdminCreateAccount)
+		adminGroup.PUT("/account/:id", superadmin, AdminUpdateAccount)
+		adminGroup.GET("/audit-logs", superadmin, AdminGetAuditLogs)
+
+		// Fault Injection (staging only)
+		adminGroup.GET("/chaos", superadmin, AdminGetChaos)
+		adminGroup.PUT("/chaos", superadmin, AdminSetChaos)
+		adminGroup.DELETE("/chaos", superadmin, AdminResetChaos)
+
+		// Data Export
+		adminGroup.GET("/export/:entity", finance, AdminExportData)
+		adminGroup.GET("/exports", finance, AdminGetExportJobs)
+		adminGroup.POST("/exports", finance, AdminCreateExportJob)
+		adminGroup.GET("/exports/:id", finance, AdminGetExportJob)
+		adminGroup.GET("/exports/:id/download", finance, AdminDownloadExport)
+
+		// Backups
+		adminGroup.GET("/backups", superadmin, AdminGetBackupStatus)
+
+		// Redis Memory Guard
+		adminGroup.GET("/redis/audit", superadmin, AdminGetRedisAudit)
+		adminGroup.POST("/redis/audit", superadmin, AdminRunRedisAudit)
+
+		// White-label Tenants
+		adminGroup.GET("/tenants", superadmin, AdminGetTenants)
+		adminGroup.POST("/tenants", superadmin, AdminCreateTenant)
+		adminGroup.PUT("/tenant/:id", superadmin, AdminUpdateTenant)
+		adminGroup.POST("/tenant/:id/rotate-key", superadmin, AdminRotateTenantKey)
 
 		// Email OTP (admin-only)
 		adminGroup.POST("/email-otp-request", SendingOtpToEmail)
@@ -29,38 +77,131 @@ func RegisterAdminRoutes(r *gin.Engine, adminMiddleware gin.HandlerFunc) {
 		// User Management
 		adminGroup.GET("/users", AdminGetUsers)
 		adminGroup.GET("/user/:id", AdminGetUserDetail)
-		adminGroup.PUT("/user/:id/status", AdminUpdateUserStatus)
+		adminGroup.GET("/user/:id/active-ride", support, middleware.AuditRead(), AdminGetUserActiveRide)
+		adminGroup.PUT("/user/:id/status", support, AdminUpdateUserStatus)
 
 		// Driver Management
 		adminGroup.GET("/drivers", AdminGetDrivers)
 		adminGroup.GET("/driver/:id", AdminGetDriverDetail)
-		adminGroup.PUT("/driver/:id/status", AdminUpdateDriverStatus)
+		adminGroup.GET("/driver/:id/active-ride", support, middleware.AuditRead(), AdminGetDriverActiveRide)
+		adminGroup.PUT("/driver/:id/status", support, AdminUpdateDriverStatus)
+		adminGroup.GET("/vehicles", support, AdminGetVehicles)
+		adminGroup.PUT("/vehicle/:id/status", support, AdminReviewVehicle)
 		adminGroup.GET("/drivers/live", AdminGetLiveDrivers)
+		adminGroup.PUT("/driver/:id/fleet", finance, AdminAssignDriverFleet)
+		adminGroup.PUT("/driver/:id/commission", finance, AdminSetDriverCommission)
+
+		// Account Deletion Requests
+		adminGroup.GET("/account-deletions", support, AdminGetAccountDeletions)
+		adminGroup.POST("/account-deletion/:id/process", support, AdminProcessAccountDeletion)
+		adminGroup.POST("/account-deletion/:id/cancel", support, AdminCancelAccountDeletion)
+
+		// Fleet Management
+		adminGroup.GET("/fleets", AdminGetFleets)
+		adminGroup.GET("/fleet/:id", AdminGetFleetDetail)
+		adminGroup.PUT("/fleet", finance, AdminUpsertFleet)
 
 		// Ride Management
 		adminGroup.GET("/rides", AdminGetRides)
 		adminGroup.GET("/ride/:id", AdminGetRideDetail)
+		adminGroup.GET("/ride/:id/export", AdminExportRideRoute)
+		adminGroup.GET("/ride/:id/timeline", AdminGetRideTimeline)
+		adminGroup.GET("/ride/:id/trace", AdminGetRideTrace)
+		adminGroup.GET("/ride/:id/dropoff-photo", AdminGetDropoffPhoto)
+		adminGroup.GET("/ride-anomalies", support, AdminGetRideAnomalies)
+		adminGroup.PUT("/ride-anomaly/:id/resolve", support, AdminResolveRideAnomaly)
+
+		// Duplicate Account Review
+		adminGroup.GET("/duplicates", support, AdminGetDuplicates)
+		adminGroup.POST("/duplicates/scan", support, AdminScanDuplicates)
+		adminGroup.GET("/duplicate/:id", support, AdminGetDuplicateDetail)
+		adminGroup.POST("/duplicate/:id/resolve", support, AdminResolveDuplicate)
+
+		// Internal Notes & Tags
+		adminGroup.GET("/notes", AdminSearchNotes)
+		adminGroup.POST("/notes", AdminCreateNote)
+		adminGroup.PUT("/note/:id", AdminUpdateNote)
+		adminGroup.DELETE("/note/:id", AdminDeleteNote)
+
+		// Zone Launch Management
+		adminGroup.GET("/zones", AdminGetZones)
+		adminGroup.PUT("/zone", superadmin, AdminUpsertZone)
+		adminGroup.DELETE("/zone/:name", superadmin, AdminDeactivateZone)
+		adminGroup.POST("/zones/reload", superadmin, AdminReloadZones)
+		adminGroup.PUT("/zone/:name/launch-mode", superadmin, AdminSetZoneLaunchMode)
+		adminGroup.GET("/zone/:name/allowlist", support, AdminGetZoneAllowlist)
+		adminGroup.POST("/zone/:name/allowlist", support, AdminAddZoneAllowlist)
+		adminGroup.DELETE("/zone/:name/allowlist/:phone", support, AdminRemoveZoneAllowlist)
+
+		// Rating Prompt Configuration
+		adminGroup.GET("/rating-config", AdminGetRatingConfig)
+		adminGroup.PUT("/rating-config", superadmin, AdminUpsertRatingConfig)
+		adminGroup.PUT("/rating-tag", superadmin, AdminUpsertRatingTag)
+		adminGroup.DELETE("/rating-tag/:id", superadmin, AdminDeleteRatingTag)
+		adminGroup.GET("/training-modules", AdminGetTrainingModules)
+		adminGroup.PUT("/training-module", superadmin, AdminUpsertTrainingModule)
+		adminGroup.DELETE("/training-module/:id", superadmin, AdminDeleteTrainingModule)
+
+		// Review Moderation
+		adminGroup.GET("/reviews", AdminGetReviews)
+		adminGroup.PUT("/review/:rideId/visibility", support, AdminSetReviewVisibility)
+
+		// Marketing Consent Compliance
+		adminGroup.PUT("/user/:id/consent", support, AdminRecordUserConsent)
+		adminGroup.GET("/compliance/consent-export", support, AdminExportConsentLog)
 
 		// Payment Management
-		adminGroup.GET("/payments", AdminGetPayments)
+		adminGroup.GET("/payments", finance, AdminGetPayments)
+		adminGroup.GET("/refunds", finance, AdminGetRefunds)
+		adminGroup.POST("/ride/:id/refund", finance, AdminIssueRefund)
+		adminGroup.PUT("/refund/:id/status", finance, AdminUpdateRefundStatus)
+		adminGroup.GET("/disputes", finance, AdminGetDisputes)
+		adminGroup.GET("/dispute/:id", finance, AdminGetDispute)
+		adminGroup.POST("/dispute/:id/resolve", finance, AdminResolveDispute)
+		adminGroup.POST("/dispute/:id/reject", finance, AdminRejectDispute)
+		adminGroup.GET("/driver/:id/wallet", finance, AdminGetDriverWallet)
+		adminGroup.POST("/driver/:id/payout", finance, AdminMarkDriverPayout)
 
 		// Vehicle Type Management
 		adminGroup.GET("/vehicle-types", AdminGetAllVehicleTypes)
-		adminGroup.PUT("/vehicle-type", AdminUpsertVehicleType)
-		adminGroup.DELETE("/vehicle-type/:id", AdminDeleteVehicleType)
+		adminGroup.PUT("/vehicle-type", superadmin, AdminUpsertVehicleType)
+		adminGroup.DELETE("/vehicle-type/:id", superadmin, AdminDeleteVehicleType)
+		adminGroup.POST("/vehicle-type/:id/icon", superadmin, AdminUploadVehicleTypeIcon)
+		adminGroup.DELETE("/vehicle-type/:id/icon", superadmin, AdminDeleteVehicleTypeIcon)
+		adminGroup.PUT("/vehicle-type/:id/commission", finance, AdminSetVehicleTypeCommission)
+		adminGroup.GET("/commissions", finance, AdminGetCommissions)
 
 		// SOS Alert Management
-		adminGroup.GET("/sos-alerts", AdminGetSOSAlerts)
-		adminGroup.PUT("/sos/:id/resolve", AdminResolveSOSAlert)
+		adminGroup.GET("/sos-alerts", support, AdminGetSOSAlerts)
+		adminGroup.PUT("/sos/:id/acknowledge", support, AdminAcknowledgeSOSAlert)
+		adminGroup.PUT("/sos/:id/resolve", support, AdminResolveSOSAlert)
+
+		// Notifications outbox: failed pushes and their retries
+		adminGroup.GET("/notifications", support, AdminGetNotifications)
+		adminGroup.POST("/notification/:id/retry", support, AdminRetryNotification)
+		adminGroup.GET("/on-call", support, AdminGetOnCall)
+		adminGroup.PUT("/me/on-call", support, AdminSetOnCall)
 
 		// Promo Code Management
-		adminGroup.GET("/promo-codes", AdminGetPromoCodes)
-		adminGroup.POST("/promo-code", AdminCreatePromoCode)
-		adminGroup.PUT("/promo-code/:id", AdminUpdatePromoCode)
-		adminGroup.DELETE("/promo-code/:id", AdminDeletePromoCode)
+		adminGroup.GET("/promo-codes", finance, AdminGetPromoCodes)
+		adminGroup.POST("/promo-code", finance, AdminCreatePromoCode)
+		adminGroup.PUT("/promo-code/:id", finance, AdminUpdatePromoCode)
+		adminGroup.DELETE("/promo-code/:id", finance, AdminDeletePromoCode)
+		adminGroup.GET("/referrals", finance, AdminGetReferrals)
+		adminGroup.GET("/referrals/summary", finance, AdminGetReferralSummary)
+
+		// Driver incentives
+		adminGroup.GET("/incentives", finance, AdminGetIncentives)
+		adminGroup.GET("/incentive/:id", finance, AdminGetIncentive)
+		adminGroup.PUT("/incentive", finance, AdminUpsertIncentive)
+		adminGroup.DELETE("/incentive/:id", finance, AdminDeleteIncentive)
 
 		// Analytics
-		adminGroup.GET("/analytics/daily", AdminDailyAnalytics)
+		adminGroup.GET("/analytics/daily", finance, AdminDailyAnalytics)
+		adminGroup.GET("/analytics/cohorts", finance, AdminRiderCohorts)
+		adminGroup.GET("/analytics/funnel", finance, AdminBookingFunnel)
+		adminGroup.POST("/analytics/refresh", superadmin, AdminRefreshAnalyticsStats)
+		adminGroup.GET("/emissions", finance, AdminEmissionsReport)
 	}
 }
 
@@ -71,34 +212,34 @@ func RegisterAdminRoutes(r *gin.Engine, adminMiddleware gin.HandlerFunc) {
 
 // GET /api/v1/admin/dashboard
 func AdminDashboard(c *gin.Context) {
-	var totalUsers, totalDrivers, totalRides, activeDrivers, completedRides, cancelledRides, requestedRides, ongoingRides int
-	var totalRevenue, avgRating float64
-
-	db.Pool.QueryRow(context.Background(), `SELECT COUNT(*) FROM "user"`).Scan(&totalUsers)
-	db.Pool.QueryRow(context.Background(), `SELECT COUNT(*) FROM driver`).Scan(&totalDrivers)
-	db.Pool.QueryRow(context.Background(), `SELECT COUNT(*) FROM driver WHERE status='active'`).Scan(&activeDrivers)
-	db.Pool.QueryRow(context.Background(), `SELECT COUNT(*) FROM rides`).Scan(&totalRides)
-	db.Pool.QueryRow(context.Background(), `SELECT COUNT(*) FROM rides WHERE status='Completed'`).Scan(&completedRides)
-	db.Pool.QueryRow(context.Background(), `SELECT COUNT(*) FROM rides WHERE status='Cancelled'`).Scan(&cancelledRides)
-	db.Pool.QueryRow(context.Background(), `SELECT COUNT(*) FROM rides WHERE status='Requested'`).Scan(&requestedRides)
-	db.Pool.QueryRow(context.Background(), `SELECT COUNT(*) FROM rides WHERE status IN ('Accepted','Arriving','InProgress')`).Scan(&ongoingRides)
-	db.Pool.QueryRow(context.Background(), `SELECT COALESCE(SUM(charge), 0) FROM rides WHERE status='Completed'`).Scan(&totalRevenue)
-	db.Pool.QueryRow(context.Background(), `SELECT COALESCE(AVG(rating), 0) FROM rides WHERE rating IS NOT NULL`).Scan(&avgRating)
-
-	// Today's stats
-	var todayRides, todayCompleted, todayNewUsers, todayNewDrivers int
-	var todayRevenue float64
-	db.Pool.QueryRow(context.Background(), `SELECT COUNT(*) FROM rides WHERE DATE("createdAt")=CURRENT_DATE`).Scan(&todayRides)
-	db.Pool.QueryRow(context.Background(), `SELECT COUNT(*) FROM rides WHERE status='Completed' AND DATE("createdAt")=CURRENT_DATE`).Scan(&todayCompleted)
-	db.Pool.QueryRow(context.Background(), `SELECT COALESCE(SUM(charge), 0) FROM rides WHERE status='Completed' AND DATE("createdAt")=CURRENT_DATE`).Scan(&todayRevenue)
-	db.Pool.QueryRow(context.Background(), `SELECT COUNT(*) FROM "user" WHERE DATE("createdAt")=CURRENT_DATE`).Scan(&todayNewUsers)
-	db.Pool.QueryRow(context.Background(), `SELECT COUNT(*) FROM driver WHERE DATE("createdAt")=CURRENT_DATE`).Scan(&todayNewDrivers)
-
-	// This week
-	var weekRides int
-	var weekRevenue float64
-	db.Pool.QueryRow(context.Background(), `SELECT COUNT(*) FROM rides WHERE "createdAt" >= NOW() - INTERVAL '7 days'`).Scan(&weekRides)
-	db.Pool.QueryRow(context.Background(), `SELECT COALESCE(SUM(charge), 0) FROM rides WHERE status='Completed' AND "createdAt" >= NOW() - INTERVAL '7 days'`).Scan(&weekRevenue)
+	ctx := adminContext(c)
+
+	// Totals, today and the week come from the stats tables (analytics_stats.go); open rides and
+	// driver counts are live
+	total, err := sumDailyStats(ctx, 0)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to load dashboard stats", err)
+		return
+	}
+	today, err := sumDailyStats(ctx, 1)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to load dashboard stats", err)
+		return
+	}
+	week, err := sumDailyStats(ctx, 7)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to load dashboard stats", err)
+		return
+	}
+	live, err := loadLiveCounts(ctx)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to load dashboard stats", err)
+		return
+	}
+	var avgRating float64
+	if total.RatingCount > 0 {
+		avgRating = total.RatingSum / float64(total.RatingCount)
+	}
 
 	// Vehicle type popularity
 	type VehicleStat struct {
@@ -106,9 +247,9 @@ func AdminDashboard(c *gin.Context) {
 		Count       int     `json:"count"`
 		Revenue     float64 `json:"revenue"`
 	}
-	vtRows, _ := db.Pool.Query(context.Background(),
-		`SELECT COALESCE("vehicleType",'Unknown'), COUNT(*), COALESCE(SUM(charge),0) 
-		 FROM rides WHERE status='Completed' GROUP BY "vehicleType" ORDER BY COUNT(*) DESC LIMIT 10`)
+	vtRows, _ := db.Pool.Query(ctx,
+		`SELECT "vehicleType", SUM(completed)::int, SUM(revenue)
+		 FROM daily_vehicle_stats GROUP BY "vehicleType" HAVING SUM(completed) > 0 ORDER BY SUM(completed) DESC LIMIT 10`)
 	var vehicleStats []VehicleStat
 	if vtRows != nil {
 		defer vtRows.Close()
@@ -133,7 +274,7 @@ func AdminDashboard(c *gin.Context) {
 		Status     string  `json:"status"`
 		CreatedAt  string  `json:"createdAt"`
 	}
-	rrRows, _ := db.Pool.Query(context.Background(),
+	rrRows, _ := db.Pool.Query(ctx,
 		`SELECT r.id, COALESCE(u.name,''), COALESCE(d.name,''), r."currentLocationName", r."destinationLocationName", r.charge, r.status, r."createdAt"
 		 FROM rides r LEFT JOIN "user" u ON r."userId"=u.id LEFT JOIN driver d ON r."driverId"=d.id 
 		 ORDER BY r."createdAt" DESC LIMIT 5`)
@@ -152,34 +293,35 @@ func AdminDashboard(c *gin.Context) {
 
 	utils.RespondSuccess(c, http.StatusOK, "Dashboard stats", gin.H{
 		"users": gin.H{
-			"total":    totalUsers,
-			"newToday": todayNewUsers,
+			"total":    total.NewUsers,
+			"newToday": today.NewUsers,
 		},
 		"drivers": gin.H{
-			"total":    totalDrivers,
-			"active":   activeDrivers,
-			"newToday": todayNewDrivers,
+			"total":    total.NewDrivers,
+			"active":   live.ActiveDrivers,
+			"newToday": today.NewDrivers,
 		},
 		"rides": gin.H{
-			"total":     totalRides,
-			"completed": completedRides,
-			"cancelled": cancelledRides,
-			"requested": requestedRides,
-			"ongoing":   ongoingRides,
+			"total":     total.Rides,
+			"completed": total.Completed,
+			"cancelled": total.Cancelled,
+			"requested": live.RequestedRides,
+			"ongoing":   live.OngoingRides,
 			"avgRating": math.Round(avgRating*100) / 100,
 		},
 		"revenue": gin.H{
-			"total": totalRevenue,
-			"today": todayRevenue,
-			"week":  weekRevenue,
+			"total": total.Revenue,
+			"today": today.Revenue,
+			"week":  week.Revenue,
 		},
 		"today": gin.H{
-			"rides":     todayRides,
-			"completed": todayCompleted,
-			"revenue":   todayRevenue,
+			"rides":     today.Rides,
+			"completed": today.Completed,
+			"revenue":   today.Revenue,
 		},
-		"vehicleStats": vehicleStats,
-		"recentRides":  recentRides,
+		"vehicleStats":     vehicleStats,
+		"recentRides":      recentRides,
+		"statsRefreshedAt": statsRefreshedAt(ctx),
 	})
 }
 
@@ -189,44 +331,30 @@ func AdminDashboard(c *gin.Context) {
 
 // GET /api/v1/admin/users?page=1&limit=20&search=query
 func AdminGetUsers(c *gin.Context) {
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
-	search := c.Query("search")
-
-	if page < 1 {
-		page = 1
-	}
-	if limit < 1 || limit > 100 {
-		limit = 20
+	pg, err := utils.ParsePagination(c)
+	if err != nil {
+		utils.RespondErrorCode(c, http.StatusBadRequest, utils.CodeInvalidCursor, "Invalid cursor", err)
+		return
 	}
-	offset := (page - 1) * limit
-
-	var total int
-
-	baseQuery := `SELECT id, name, phone_number, email, "notificationToken", ratings, "totalRides", "createdAt", "updatedAt" FROM "user"`
-	countQuery := `SELECT COUNT(*) FROM "user"`
+	search := c.Query("search")
 
+	var conds []string
 	var args []interface{}
-	whereClause := ""
-
 	if search != "" {
-		searchPattern := "%" + search + "%"
-		whereClause = ` WHERE name ILIKE $1 OR phone_number ILIKE $1 OR email ILIKE $1`
-		args = append(args, searchPattern)
+		args = append(args, "%"+search+"%")
+		conds = append(conds, `(name ILIKE $1 OR phone_number ILIKE $1 OR email ILIKE $1)`)
 	}
 
-	db.Pool.QueryRow(context.Background(), countQuery+whereClause, args...).Scan(&total)
-
-	var queryArgs []interface{}
-	if search != "" {
-		queryArgs = []interface{}{"%" + search + "%", limit, offset}
-		baseQuery += ` WHERE name ILIKE $1 OR phone_number ILIKE $1 OR email ILIKE $1 ORDER BY "createdAt" DESC LIMIT $2 OFFSET $3`
-	} else {
-		queryArgs = []interface{}{limit, offset}
-		baseQuery += ` ORDER BY "createdAt" DESC LIMIT $1 OFFSET $2`
+	var total int
+	if !pg.UseCursor {
+		db.Pool.QueryRow(adminContext(c), `SELECT COUNT(*) FROM "user"`+utils.WhereClause(conds), args...).Scan(&total)
 	}
 
-	rows, err := db.Pool.Query(context.Background(), baseQuery, queryArgs...)
+	conds, args = pg.Keyset(conds, args, `"createdAt"`, "id")
+	tail, args := pg.Tail(args, `"createdAt"`, "id")
+	rows, err := db.Pool.Query(adminContext(c),
+		`SELECT id, name, phone_number, email, "notificationToken", ratings, "totalRides", "createdAt", "updatedAt" FROM "user"`+
+			utils.WhereClause(conds)+tail, args...)
 	if err != nil {
 		utils.RespondError(c, http.StatusInternalServerError, "Failed to fetch users", err)
 		return
@@ -243,11 +371,9 @@ func AdminGetUsers(c *gin.Context) {
 		users = []models.User{}
 	}
 
-	totalPages := int(math.Ceil(float64(total) / float64(limit)))
-
-	utils.RespondSuccess(c, http.StatusOK, "Users", gin.H{
-		"users": users, "total": total, "page": page, "limit": limit, "totalPages": totalPages,
-	})
+	users, resp := utils.Paginate(pg, users, total, func(u models.User) (time.Time, string) { return u.CreatedAt, u.ID })
+	resp["users"] = users
+	utils.RespondSuccess(c, http.StatusOK, "Users", resp)
 }
 
 // GET /api/v1/admin/user/:id — full user detail with ride history & stats
@@ -255,7 +381,7 @@ func AdminGetUserDetail(c *gin.Context) {
 	userID := c.Param("id")
 
 	var user models.User
-	err := db.Pool.QueryRow(context.Background(),
+	err := db.Pool.QueryRow(adminContext(c),
 		`SELECT id, name, phone_number, email, "notificationToken", ratings, "totalRides", "createdAt", "updatedAt" FROM "user" WHERE id=$1`, userID).
 		Scan(&user.ID, &user.Name, &user.PhoneNumber, &user.Email, &user.NotificationToken, &user.Ratings, &user.TotalRides, &user.CreatedAt, &user.UpdatedAt)
 	if err != nil {
@@ -266,21 +392,21 @@ func AdminGetUserDetail(c *gin.Context) {
 	// User's ride stats
 	var completedRides, cancelledRides, totalRidesCount int
 	var totalSpent, avgRide float64
-	db.Pool.QueryRow(context.Background(), `SELECT COUNT(*) FROM rides WHERE "userId"=$1`, userID).Scan(&totalRidesCount)
-	db.Pool.QueryRow(context.Background(), `SELECT COUNT(*) FROM rides WHERE "userId"=$1 AND status='Completed'`, userID).Scan(&completedRides)
-	db.Pool.QueryRow(context.Background(), `SELECT COUNT(*) FROM rides WHERE "userId"=$1 AND status='Cancelled'`, userID).Scan(&cancelledRides)
-	db.Pool.QueryRow(context.Background(), `SELECT COALESCE(SUM(charge), 0) FROM rides WHERE "userId"=$1 AND status='Completed'`, userID).Scan(&totalSpent)
+	db.Pool.QueryRow(adminContext(c), `SELECT COUNT(*) FROM rides WHERE "userId"=$1`, userID).Scan(&totalRidesCount)
+	db.Pool.QueryRow(adminContext(c), `SELECT COUNT(*) FROM rides WHERE "userId"=$1 AND status='Completed'`, userID).Scan(&completedRides)
+	db.Pool.QueryRow(adminContext(c), `SELECT COUNT(*) FROM rides WHERE "userId"=$1 AND status='Cancelled'`, userID).Scan(&cancelledRides)
+	db.Pool.QueryRow(adminContext(c), `SELECT COALESCE(SUM(charge), 0) FROM rides WHERE "userId"=$1 AND status='Completed'`, userID).Scan(&totalSpent)
 	if completedRides > 0 {
 		avgRide = totalSpent / float64(completedRides)
 	}
 
 	// Payment Breakdown
 	var cashRides, onlineRides int
-	db.Pool.QueryRow(context.Background(), `SELECT COUNT(*) FROM rides WHERE "userId"=$1 AND "paymentMode"='cash' AND status='Completed'`, userID).Scan(&cashRides)
-	db.Pool.QueryRow(context.Background(), `SELECT COUNT(*) FROM rides WHERE "userId"=$1 AND "paymentMode"!='cash' AND status='Completed'`, userID).Scan(&onlineRides)
+	db.Pool.QueryRow(adminContext(c), `SELECT COUNT(*) FROM rides WHERE "userId"=$1 AND "paymentMode"='cash' AND status='Completed'`, userID).Scan(&cashRides)
+	db.Pool.QueryRow(adminContext(c), `SELECT COUNT(*) FROM rides WHERE "userId"=$1 AND "paymentMode"!='cash' AND status='Completed'`, userID).Scan(&onlineRides)
 
 	// Recent rides (last 20)
-	rideRows, _ := db.Pool.Query(context.Background(),
+	rideRows, _ := db.Pool.Query(adminContext(c),
 		`SELECT r.id, r.charge, r."currentLocationName", r."destinationLocationName", r.distance, r.status, 
 		 COALESCE(r."vehicleType",''), r."createdAt", COALESCE(d.name,'') as driverName,
 		 COALESCE(r."paymentMode",''), COALESCE(r.tips, 0), COALESCE(r."estimatedDuration", 0)
@@ -326,6 +452,7 @@ func AdminGetUserDetail(c *gin.Context) {
 			"onlineRides":    onlineRides,
 		},
 		"recentRides": rides,
+		"adminNotes":  listEntityNotes(adminContext(c), noteEntityUser, userID),
 	})
 }
 
@@ -342,18 +469,21 @@ func AdminUpdateUserStatus(c *gin.Context) {
 
 	switch body.Action {
 	case "activate":
-		db.Pool.Exec(context.Background(),
+		db.Pool.Exec(adminContext(c),
 			`UPDATE "user" SET status='active', "updatedAt"=NOW() WHERE id=$1`, userID)
 	case "deactivate":
-		db.Pool.Exec(context.Background(),
+		db.Pool.Exec(adminContext(c),
 			`UPDATE "user" SET status='inactive', "notificationToken"=NULL, "updatedAt"=NOW() WHERE id=$1`, userID)
 	case "suspend":
-		db.Pool.Exec(context.Background(),
+		db.Pool.Exec(adminContext(c),
 			`UPDATE "user" SET status='suspended', "notificationToken"=NULL, "updatedAt"=NOW() WHERE id=$1`, userID)
 	default:
 		utils.RespondError(c, http.StatusBadRequest, "Invalid action. Use: activate, deactivate, suspend", nil)
 		return
 	}
+	if body.Action != "activate" {
+		utils.RevokeSessionsAsync(utils.SessionUser, userID)
+	}
 
 	utils.RespondSuccess(c, http.StatusOK, "User status updated", gin.H{"userId": userID, "action": body.Action})
 }
@@ -364,49 +494,35 @@ func AdminUpdateUserStatus(c *gin.Context) {
 
 // GET /api/v1/admin/drivers?page=1&limit=20&status=active
 func AdminGetDrivers(c *gin.Context) {
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	pg, err := utils.ParsePagination(c)
+	if err != nil {
+		utils.RespondErrorCode(c, http.StatusBadRequest, utils.CodeInvalidCursor, "Invalid cursor", err)
+		return
+	}
 	statusFilter := c.Query("status")
 	search := c.Query("search")
 
-	if page < 1 {
-		page = 1
-	}
-	if limit < 1 || limit > 100 {
-		limit = 20
-	}
-	offset := (page - 1) * limit
-
-	var total int
-	var queryArgs []interface{}
-	var query string
-	argIdx := 1
-
-	whereClause := ""
-	var filterArgs []interface{}
-
+	var conds []string
+	var args []interface{}
 	if statusFilter != "" {
-		whereClause += " WHERE status=$" + strconv.Itoa(argIdx)
-		filterArgs = append(filterArgs, statusFilter)
-		argIdx++
+		args = append(args, statusFilter)
+		conds = append(conds, "status=$"+strconv.Itoa(len(args)))
 	}
 	if search != "" {
-		if whereClause == "" {
-			whereClause += " WHERE"
-		} else {
-			whereClause += " AND"
-		}
-		whereClause += " (name ILIKE $" + strconv.Itoa(argIdx) + " OR phone_number ILIKE $" + strconv.Itoa(argIdx) + ")"
-		filterArgs = append(filterArgs, "%"+search+"%")
-		argIdx++
+		args = append(args, "%"+search+"%")
+		n := strconv.Itoa(len(args))
+		conds = append(conds, "(name ILIKE $"+n+" OR phone_number ILIKE $"+n+")")
 	}
 
-	db.Pool.QueryRow(context.Background(), `SELECT COUNT(*) FROM driver`+whereClause, filterArgs...).Scan(&total)
-
-	query = `SELECT ` + driverSelectCols + ` FROM driver` + whereClause + ` ORDER BY "createdAt" DESC LIMIT $` + strconv.Itoa(argIdx) + ` OFFSET $` + strconv.Itoa(argIdx+1)
-	queryArgs = append(filterArgs, limit, offset)
+	var total int
+	if !pg.UseCursor {
+		db.Pool.QueryRow(adminContext(c), `SELECT COUNT(*) FROM driver`+utils.WhereClause(conds), args...).Scan(&total)
+	}
 
-	rows, err := db.Pool.Query(context.Background(), query, queryArgs...)
+	conds, args = pg.Keyset(conds, args, `"createdAt"`, "id")
+	tail, args := pg.Tail(args, `"createdAt"`, "id")
+	rows, err := db.Pool.Query(adminContext(c),
+		`SELECT `+repository.DriverSelectCols()+` FROM driver`+utils.WhereClause(conds)+tail, args...)
 	if err != nil {
 		utils.RespondError(c, http.StatusInternalServerError, "Failed to fetch drivers", err)
 		return
@@ -416,28 +532,24 @@ func AdminGetDrivers(c *gin.Context) {
 	var drivers []models.Driver
 	for rows.Next() {
 		var d models.Driver
-		scanDriver(rows, &d)
+		repository.ScanDriver(rows, &d)
 		drivers = append(drivers, d)
 	}
 	if drivers == nil {
 		drivers = []models.Driver{}
 	}
 
-	totalPages := int(math.Ceil(float64(total) / float64(limit)))
-
-	utils.RespondSuccess(c, http.StatusOK, "Drivers", gin.H{
-		"drivers": drivers, "total": total, "page": page, "limit": limit, "totalPages": totalPages,
-	})
+	drivers, resp := utils.Paginate(pg, drivers, total, func(d models.Driver) (time.Time, string) { return d.CreatedAt, d.ID })
+	resp["drivers"] = drivers
+	utils.RespondSuccess(c, http.StatusOK, "Drivers", resp)
 }
 
 // GET /api/v1/admin/driver/:id — full driver detail with ride history, earnings, live location
 func AdminGetDriverDetail(c *gin.Context) {
 	driverID := c.Param("id")
 
-	var driver models.Driver
-	row := db.Pool.QueryRow(context.Background(),
-		`SELECT `+driverSelectCols+` FROM driver WHERE id=$1`, driverID)
-	if err := scanDriver(row, &driver); err != nil {
+	driver, err := repos.Drivers.GetByID(adminContext(c), driverID)
+	if err != nil {
 		utils.RespondError(c, http.StatusNotFound, "Driver not found", err)
 		return
 	}
@@ -445,11 +557,11 @@ func AdminGetDriverDetail(c *gin.Context) {
 	// Driver stats
 	var completedRides, cancelledRides, totalRidesCount int
 	var totalEarned, avgEarning, totalDistanceTraveled float64
-	db.Pool.QueryRow(context.Background(), `SELECT COUNT(*) FROM rides WHERE "driverId"=$1`, driverID).Scan(&totalRidesCount)
-	db.Pool.QueryRow(context.Background(), `SELECT COUNT(*) FROM rides WHERE "driverId"=$1 AND status='Completed'`, driverID).Scan(&completedRides)
-	db.Pool.QueryRow(context.Background(), `SELECT COUNT(*) FROM rides WHERE "driverId"=$1 AND status='Cancelled'`, driverID).Scan(&cancelledRides)
-	db.Pool.QueryRow(context.Background(), `SELECT COALESCE(SUM(charge), 0) FROM rides WHERE "driverId"=$1 AND status='Completed'`, driverID).Scan(&totalEarned)
-	db.Pool.QueryRow(context.Background(), `SELECT COALESCE(SUM(CAST(distance AS DOUBLE PRECISION)), 0) FROM rides WHERE "driverId"=$1 AND status='Completed'`, driverID).Scan(&totalDistanceTraveled)
+	db.Pool.QueryRow(adminContext(c), `SELECT COUNT(*) FROM rides WHERE "driverId"=$1`, driverID).Scan(&totalRidesCount)
+	db.Pool.QueryRow(adminContext(c), `SELECT COUNT(*) FROM rides WHERE "driverId"=$1 AND status='Completed'`, driverID).Scan(&completedRides)
+	db.Pool.QueryRow(adminContext(c), `SELECT COUNT(*) FROM rides WHERE "driverId"=$1 AND status='Cancelled'`, driverID).Scan(&cancelledRides)
+	db.Pool.QueryRow(adminContext(c), `SELECT COALESCE(SUM(charge), 0) FROM rides WHERE "driverId"=$1 AND status='Completed'`, driverID).Scan(&totalEarned)
+	db.Pool.QueryRow(adminContext(c), `SELECT COALESCE(SUM(CAST(distance AS DOUBLE PRECISION)), 0) FROM rides WHERE "driverId"=$1 AND status='Completed'`, driverID).Scan(&totalDistanceTraveled)
 	if completedRides > 0 {
 		avgEarning = totalEarned / float64(completedRides)
 	}
@@ -459,7 +571,7 @@ func AdminGetDriverDetail(c *gin.Context) {
 	var lat, lng float64
 	var heading *float64
 	var locUpdatedAt time.Time
-	err := db.Pool.QueryRow(context.Background(),
+	err = db.Pool.QueryRow(adminContext(c),
 		`SELECT lat, lng, heading, "updatedAt" FROM driver_location WHERE "driverId"=$1`, driverID).
 		Scan(&lat, &lng, &heading, &locUpdatedAt)
 	if err == nil {
@@ -472,7 +584,7 @@ func AdminGetDriverDetail(c *gin.Context) {
 	}
 
 	// Recent rides (last 10)
-	rideRows, _ := db.Pool.Query(context.Background(),
+	rideRows, _ := db.Pool.Query(adminContext(c),
 		`SELECT r.id, r.charge, r."currentLocationName", r."destinationLocationName", r.distance, r.status, 
 		 COALESCE(r."vehicleType",''), r."createdAt", COALESCE(u.name,'') as userName
 		 FROM rides r LEFT JOIN "user" u ON r."userId"=u.id 
@@ -508,7 +620,7 @@ func AdminGetDriverDetail(c *gin.Context) {
 		Rides    int       `json:"rides"`
 		Earnings float64   `json:"earnings"`
 	}
-	earningRows, _ := db.Pool.Query(context.Background(),
+	earningRows, _ := db.Pool.Query(adminContext(c),
 		`SELECT DATE(r."createdAt") as day, COUNT(*), COALESCE(SUM(r.charge), 0)
 		 FROM rides r WHERE r."driverId"=$1 AND r.status='Completed' AND r."createdAt" >= NOW() - INTERVAL '7 days'
 		 GROUP BY DATE(r."createdAt") ORDER BY day DESC`, driverID)
@@ -538,6 +650,15 @@ func AdminGetDriverDetail(c *gin.Context) {
 		"liveLocation":  liveLocation,
 		"recentRides":   rides,
 		"dailyEarnings": dailyEarnings,
+		"acceptance":    driverAcceptanceStats(adminContext(c), driverID),
+		"cancellation":  driverCancellationStats(adminContext(c), driverID),
+		"training":      trainingSummary(adminContext(c), driverID),
+		"fleet":         fleetSummary(adminContext(c), driverID),
+		"commission":    driverCommissionSummary(adminContext(c), driverID),
+		"diagnostics":   driverDiagnosticsSummary(adminContext(c), driverID),
+		"utilization":   driverUtilization(adminContext(c), driverID, time.Now().AddDate(0, 0, -30), time.Now()),
+		"metrics":       loadDriverMetrics(adminContext(c), driverID),
+		"adminNotes":    listEntityNotes(adminContext(c), noteEntityDriver, driverID),
 	})
 }
 
@@ -558,22 +679,45 @@ func AdminUpdateDriverStatus(c *gin.Context) {
 		return
 	}
 
+	// Approving a new driver needs every active training module passed
+	if body.Status == "active" {
+		var current string
+		db.Pool.QueryRow(adminContext(c), `SELECT status FROM driver WHERE id=$1`, driverID).Scan(&current)
+		if current == "pending" {
+			outstanding, err := outstandingTraining(adminContext(c), driverID)
+			if err != nil {
+				utils.RespondError(c, http.StatusInternalServerError, "Failed to check driver training", err)
+				return
+			}
+			if len(outstanding) > 0 {
+				utils.RespondError(c, http.StatusConflict,
+					"Driver has not passed training: "+strings.Join(outstanding, ", "), nil)
+				return
+			}
+		}
+	}
+
 	// When deactivating/suspending/rejecting, also force offline
 	if body.Status == "inactive" || body.Status == "suspended" || body.Status == "rejected" || body.Status == "pending" {
-		_, err := db.Pool.Exec(context.Background(),
+		_, err := db.Pool.Exec(adminContext(c),
 			`UPDATE driver SET status=$1, "isOnline"=FALSE, "updatedAt"=NOW() WHERE id=$2`, body.Status, driverID)
 		if err != nil {
 			utils.RespondError(c, http.StatusInternalServerError, "Failed to update driver", err)
 			return
 		}
-		stores.RemoveDriver(driverID)
+		stores.RemoveDriver(adminContext(c), driverID)
+		// Inactive drivers can still sign in; the other states lock the app
+		if body.Status != "inactive" {
+			utils.RevokeSessionsAsync(utils.SessionDriver, driverID)
+		}
 	} else {
-		_, err := db.Pool.Exec(context.Background(),
+		_, err := db.Pool.Exec(adminContext(c),
 			`UPDATE driver SET status=$1, "updatedAt"=NOW() WHERE id=$2`, body.Status, driverID)
 		if err != nil {
 			utils.RespondError(c, http.StatusInternalServerError, "Failed to update driver", err)
 			return
 		}
+		approveRegistrationVehicle(adminContext(c), driverID, c.MustGet("admin").(*models.AdminAccount).ID)
 	}
 
 	utils.RespondSuccess(c, http.StatusOK, "Driver status updated", gin.H{"driverId": driverID, "status": body.Status})
@@ -586,7 +730,7 @@ func AdminUpdateDriverStatus(c *gin.Context) {
 // GET /api/v1/admin/drivers/live
 func AdminGetLiveDrivers(c *gin.Context) {
 	// Fetch all driver locations from the DB (more reliable for admin)
-	rows, err := db.Pool.Query(context.Background(),
+	rows, err := db.Pool.Query(adminContext(c),
 		`SELECT dl."driverId", dl.lat, dl.lng, dl.heading, dl."updatedAt",
 		 d.name, d.phone_number, d.vehicle_type, COALESCE(d.vehicle_color, ''), d.registration_number, d.status
 		 FROM driver_location dl
@@ -636,47 +780,33 @@ func AdminGetLiveDrivers(c *gin.Context) {
 
 // GET /api/v1/admin/rides?page=1&limit=20&status=Completed&vehicleType=Car
 func AdminGetRides(c *gin.Context) {
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	pg, err := utils.ParsePagination(c)
+	if err != nil {
+		utils.RespondErrorCode(c, http.StatusBadRequest, utils.CodeInvalidCursor, "Invalid cursor", err)
+		return
+	}
 	statusFilter := c.Query("status")
 	vehicleFilter := c.Query("vehicleType")
 
-	if page < 1 {
-		page = 1
-	}
-	if limit < 1 || limit > 100 {
-		limit = 20
-	}
-	offset := (page - 1) * limit
-
-	var total int
-	whereClause := ""
-	var filterArgs []interface{}
-	argIdx := 1
-
+	var conds []string
+	var args []interface{}
 	if statusFilter != "" {
-		whereClause += " WHERE r.status=$" + strconv.Itoa(argIdx)
-		filterArgs = append(filterArgs, statusFilter)
-		argIdx++
+		args = append(args, statusFilter)
+		conds = append(conds, "r.status=$"+strconv.Itoa(len(args)))
 	}
 	if vehicleFilter != "" {
-		if whereClause == "" {
-			whereClause += " WHERE"
-		} else {
-			whereClause += " AND"
-		}
-		whereClause += " r.\"vehicleType\"=$" + strconv.Itoa(argIdx)
-		filterArgs = append(filterArgs, vehicleFilter)
-		argIdx++
+		args = append(args, vehicleFilter)
+		conds = append(conds, `r."vehicleType"=$`+strconv.Itoa(len(args)))
 	}
 
-	countWhere := whereClause
-	if countWhere != "" {
-		countWhere = " WHERE" + countWhere[6:] // replace " WHERE r." with " WHERE "
+	var total int
+	if !pg.UseCursor {
+		db.Pool.QueryRow(adminContext(c),
+			`SELECT COUNT(*) FROM rides r`+utils.WhereClause(conds), args...).Scan(&total)
 	}
-	db.Pool.QueryRow(context.Background(),
-		`SELECT COUNT(*) FROM rides r`+whereClause, filterArgs...).Scan(&total)
 
+	conds, args = pg.Keyset(conds, args, `r."createdAt"`, "r.id")
+	tail, args := pg.Tail(args, `r."createdAt"`, "r.id")
 	query := `SELECT r.id, r."userId", r."driverId", r.charge, r."currentLocationName", r."destinationLocationName", 
 		r.distance, r.status, r.rating, COALESCE(r."vehicleType", ''), COALESCE(r."paymentStatus", 'Pending'), 
 		COALESCE(r."paymentMode",''), COALESCE(r.tips, 0), COALESCE(r."estimatedDuration", 0), r."createdAt",
@@ -685,11 +815,9 @@ func AdminGetRides(c *gin.Context) {
 		FROM rides r 
 		LEFT JOIN "user" u ON r."userId"=u.id 
 		LEFT JOIN driver d ON r."driverId"=d.id` +
-		whereClause + ` ORDER BY r."createdAt" DESC LIMIT $` + strconv.Itoa(argIdx) + ` OFFSET $` + strconv.Itoa(argIdx+1)
-
-	queryArgs := append(filterArgs, limit, offset)
+		utils.WhereClause(conds) + tail
 
-	rows, err := db.Pool.Query(context.Background(), query, queryArgs...)
+	rows, err := db.Pool.Query(adminContext(c), query, args...)
 	if err != nil {
 		utils.RespondError(c, http.StatusInternalServerError, "Failed to fetch rides", err)
 		return
@@ -697,25 +825,25 @@ func AdminGetRides(c *gin.Context) {
 	defer rows.Close()
 
 	type AdminRide struct {
-		ID                      string   `json:"id"`
-		UserID                  string   `json:"userId"`
-		DriverID                *string  `json:"driverId"`
-		Charge                  float64  `json:"charge"`
-		CurrentLocationName     string   `json:"currentLocationName"`
-		DestinationLocationName string   `json:"destinationLocationName"`
-		Distance                string   `json:"distance"`
-		Status                  string   `json:"status"`
-		Rating                  *float64 `json:"rating"`
-		VehicleType             string   `json:"vehicleType"`
-		PaymentStatus           string   `json:"paymentStatus"`
-		PaymentMode             string   `json:"paymentMode"`
-		Tips                    float64  `json:"tips"`
-		Duration                int      `json:"duration"`
-		CreatedAt               string   `json:"createdAt"`
-		UserName                string   `json:"userName"`
-		UserPhone               string   `json:"userPhone"`
-		DriverName              string   `json:"driverName"`
-		DriverPhone             string   `json:"driverPhone"`
+		ID                      string    `json:"id"`
+		UserID                  string    `json:"userId"`
+		DriverID                *string   `json:"driverId"`
+		Charge                  float64   `json:"charge"`
+		CurrentLocationName     string    `json:"currentLocationName"`
+		DestinationLocationName string    `json:"destinationLocationName"`
+		Distance                string    `json:"distance"`
+		Status                  string    `json:"status"`
+		Rating                  *float64  `json:"rating"`
+		VehicleType             string    `json:"vehicleType"`
+		PaymentStatus           string    `json:"paymentStatus"`
+		PaymentMode             string    `json:"paymentMode"`
+		Tips                    float64   `json:"tips"`
+		Duration                int       `json:"duration"`
+		CreatedAt               time.Time `json:"createdAt"`
+		UserName                string    `json:"userName"`
+		UserPhone               string    `json:"userPhone"`
+		DriverName              string    `json:"driverName"`
+		DriverPhone             string    `json:"driverPhone"`
 	}
 	var rides []AdminRide
 	for rows.Next() {
@@ -729,11 +857,9 @@ func AdminGetRides(c *gin.Context) {
 		rides = []AdminRide{}
 	}
 
-	totalPages := int(math.Ceil(float64(total) / float64(limit)))
-
-	utils.RespondSuccess(c, http.StatusOK, "Rides", gin.H{
-		"rides": rides, "total": total, "page": page, "limit": limit, "totalPages": totalPages,
-	})
+	rides, resp := utils.Paginate(pg, rides, total, func(r AdminRide) (time.Time, string) { return r.CreatedAt, r.ID })
+	resp["rides"] = rides
+	utils.RespondSuccess(c, http.StatusOK, "Rides", resp)
 }
 
 // GET /api/v1/admin/ride/:id — full ride detail with polyline, coordinates, travel route
@@ -744,9 +870,9 @@ func AdminGetRideDetail(c *gin.Context) {
 	var driver models.Driver
 	var user models.User
 
-	err := db.Pool.QueryRow(context.Background(),
+	err := db.Pool.QueryRow(adminContext(c),
 		`SELECT 
-			r.id, r."userId", r."driverId", r.charge, r."currentLocationName", r."destinationLocationName", 
+			r.id, r."userId", r."driverId", r.charge, r.currency, r."currentLocationName", r."destinationLocationName", 
 			r.distance, r.status, COALESCE(r."paymentMode", ''), COALESCE(r."paymentStatus", 'Pending'), 
 			COALESCE(r.otp, ''), COALESCE(r.polyline, ''), COALESCE(r."estimatedDuration", 0), COALESCE(r."estimatedDistance", 0),
 			COALESCE(r."vehicleType", ''), r.rating, COALESCE(r."cancelReason", ''),
@@ -761,7 +887,7 @@ func AdminGetRideDetail(c *gin.Context) {
 		JOIN "user" u ON r."userId" = u.id
 		WHERE r.id=$1`, rideID).
 		Scan(
-			&ride.ID, &ride.UserID, &ride.DriverID, &ride.Charge, &ride.CurrentLocationName, &ride.DestinationLocationName,
+			&ride.ID, &ride.UserID, &ride.DriverID, &ride.Charge, &ride.Currency, &ride.CurrentLocationName, &ride.DestinationLocationName,
 			&ride.Distance, &ride.Status, &ride.PaymentMode, &ride.PaymentStatus,
 			&ride.OTP, &ride.Polyline, &ride.EstimatedDuration, &ride.EstimatedDistance,
 			&ride.VehicleType, &ride.Rating, &ride.CancelReason,
@@ -774,16 +900,16 @@ func AdminGetRideDetail(c *gin.Context) {
 		)
 
 	if err != nil {
-		utils.RespondError(c, http.StatusNotFound, "Ride not found", err)
+		utils.RespondErrorCode(c, http.StatusNotFound, utils.CodeRideNotFound, "Ride not found", err)
 		return
 	}
 
 	// Payment info
 	var payment *models.Payment
 	var p models.Payment
-	pErr := db.Pool.QueryRow(context.Background(),
-		`SELECT id, "rideId", amount, mode, status, "createdAt" FROM payments WHERE "rideId"=$1`, rideID).
-		Scan(&p.ID, &p.RideID, &p.Amount, &p.Mode, &p.Status, &p.CreatedAt)
+	pErr := db.Pool.QueryRow(adminContext(c),
+		`SELECT id, "rideId", amount, currency, mode, status, "createdAt" FROM payments WHERE "rideId"=$1`, rideID).
+		Scan(&p.ID, &p.RideID, &p.Amount, &p.Currency, &p.Mode, &p.Status, &p.CreatedAt)
 	if pErr == nil {
 		payment = &p
 	}
@@ -794,6 +920,7 @@ func AdminGetRideDetail(c *gin.Context) {
 		"userId":                  ride.UserID,
 		"driverId":                ride.DriverID,
 		"charge":                  ride.Charge,
+		"currency":                ride.Currency,
 		"currentLocationName":     ride.CurrentLocationName,
 		"destinationLocationName": ride.DestinationLocationName,
 		"distance":                ride.Distance,
@@ -842,10 +969,12 @@ func AdminGetRideDetail(c *gin.Context) {
 	}
 
 	utils.RespondSuccess(c, http.StatusOK, "Ride detail", gin.H{
-		"ride":    rideDetail,
-		"driver":  driverDetail,
-		"user":    userDetail,
-		"payment": payment,
+		"ride":         rideDetail,
+		"driver":       driverDetail,
+		"user":         userDetail,
+		"payment":      payment,
+		"dropoffPhoto": dropoffPhotoSummary(adminContext(c), rideID),
+		"adminNotes":   listEntityNotes(adminContext(c), noteEntityRide, rideID),
 	})
 }
 
@@ -855,40 +984,35 @@ func AdminGetRideDetail(c *gin.Context) {
 
 // GET /api/v1/admin/payments?page=1&limit=20&mode=Cash
 func AdminGetPayments(c *gin.Context) {
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
-	modeFilter := c.Query("mode")
-
-	if page < 1 {
-		page = 1
-	}
-	if limit < 1 || limit > 100 {
-		limit = 20
+	pg, err := utils.ParsePagination(c)
+	if err != nil {
+		utils.RespondErrorCode(c, http.StatusBadRequest, utils.CodeInvalidCursor, "Invalid cursor", err)
+		return
 	}
-	offset := (page - 1) * limit
+	modeFilter := c.Query("mode")
 
 	var total int
 	var totalAmount, paidAmount, pendingAmount float64
 
-	db.Pool.QueryRow(context.Background(), `SELECT COALESCE(SUM(amount), 0) FROM payments WHERE status='paid'`).Scan(&paidAmount)
-	db.Pool.QueryRow(context.Background(), `SELECT COALESCE(SUM(amount), 0) FROM payments WHERE status='pending'`).Scan(&pendingAmount)
-
-	var queryArgs []interface{}
-	var query string
+	db.Pool.QueryRow(adminContext(c), `SELECT COALESCE(SUM(amount), 0) FROM payments WHERE status='paid'`).Scan(&paidAmount)
+	db.Pool.QueryRow(adminContext(c), `SELECT COALESCE(SUM(amount), 0) FROM payments WHERE status='pending'`).Scan(&pendingAmount)
 
+	var conds []string
+	var args []interface{}
 	if modeFilter != "" {
-		db.Pool.QueryRow(context.Background(), `SELECT COUNT(*), COALESCE(SUM(amount), 0) FROM payments WHERE mode=$1`, modeFilter).Scan(&total, &totalAmount)
-		query = `SELECT p.id, p."rideId", p.amount, p.mode, p.status, p."createdAt"
-				 FROM payments p WHERE p.mode=$1 ORDER BY p."createdAt" DESC LIMIT $2 OFFSET $3`
-		queryArgs = []interface{}{modeFilter, limit, offset}
-	} else {
-		db.Pool.QueryRow(context.Background(), `SELECT COUNT(*), COALESCE(SUM(amount), 0) FROM payments`).Scan(&total, &totalAmount)
-		query = `SELECT p.id, p."rideId", p.amount, p.mode, p.status, p."createdAt"
-				 FROM payments p ORDER BY p."createdAt" DESC LIMIT $1 OFFSET $2`
-		queryArgs = []interface{}{limit, offset}
+		args = append(args, modeFilter)
+		conds = append(conds, "p.mode=$1")
+	}
+	if !pg.UseCursor {
+		db.Pool.QueryRow(adminContext(c),
+			`SELECT COUNT(*), COALESCE(SUM(amount), 0) FROM payments p`+utils.WhereClause(conds), args...).Scan(&total, &totalAmount)
 	}
 
-	rows, err := db.Pool.Query(context.Background(), query, queryArgs...)
+	conds, args = pg.Keyset(conds, args, `p."createdAt"`, "p.id")
+	tail, args := pg.Tail(args, `p."createdAt"`, "p.id")
+	rows, err := db.Pool.Query(adminContext(c),
+		`SELECT p.id, p."rideId", p.amount, p.currency, p.mode, p.status, p."createdAt"
+		 FROM payments p`+utils.WhereClause(conds)+tail, args...)
 	if err != nil {
 		utils.RespondError(c, http.StatusInternalServerError, "Failed to fetch payments", err)
 		return
@@ -898,46 +1022,80 @@ func AdminGetPayments(c *gin.Context) {
 	var payments []models.Payment
 	for rows.Next() {
 		var p models.Payment
-		rows.Scan(&p.ID, &p.RideID, &p.Amount, &p.Mode, &p.Status, &p.CreatedAt)
+		rows.Scan(&p.ID, &p.RideID, &p.Amount, &p.Currency, &p.Mode, &p.Status, &p.CreatedAt)
 		payments = append(payments, p)
 	}
 	if payments == nil {
 		payments = []models.Payment{}
 	}
 
-	totalPages := int(math.Ceil(float64(total) / float64(limit)))
+	payments, resp := utils.Paginate(pg, payments, total, func(p models.Payment) (time.Time, string) { return p.CreatedAt, p.ID })
+	resp["payments"] = payments
+	resp["paidAmount"] = paidAmount
+	resp["pendingAmount"] = pendingAmount
+	if !pg.UseCursor {
+		resp["totalAmount"] = totalAmount
+	}
+	utils.RespondSuccess(c, http.StatusOK, "Payments", resp)
+}
 
-	utils.RespondSuccess(c, http.StatusOK, "Payments", gin.H{
-		"payments":      payments,
-		"total":         total,
-		"totalAmount":   totalAmount,
-		"paidAmount":    paidAmount,
-		"pendingAmount": pendingAmount,
-		"page":          page,
-		"limit":         limit,
-		"totalPages":    totalPages,
-	})
+// GET /api/v1/admin/driver/:id/wallet
+func AdminGetDriverWallet(c *gin.Context) {
+	wallet, err := stores.GetOrCreateWallet(adminContext(c), c.Param("id"))
+	if err != nil {
+		utils.RespondError(c, http.StatusNotFound, "Wallet not found", err)
+		return
+	}
+	utils.RespondSuccess(c, http.StatusOK, "Driver wallet", gin.H{"wallet": wallet})
+}
+
+// POST /api/v1/admin/driver/:id/payout
+func AdminMarkDriverPayout(c *gin.Context) {
+	var body struct {
+		Amount    float64 `json:"amount" binding:"required,gt=0"`
+		Reference string  `json:"reference"` // bank UTR / transfer id
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid payout request", err)
+		return
+	}
+
+	txn, err := stores.RecordPayout(adminContext(c), c.Param("id"), body.Amount, body.Reference)
+	if errors.Is(err, stores.ErrInsufficientBalance) {
+		utils.RespondErrorCode(c, http.StatusBadRequest, utils.CodeInsufficientBalance, "Payout exceeds wallet balance", err)
+		return
+	}
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to record payout", err)
+		return
+	}
+
+	utils.Logger.Info("Driver payout recorded",
+		zap.String("driverId", txn.DriverID), zap.Float64("amount", body.Amount), zap.String("reference", body.Reference))
+
+	utils.RespondSuccess(c, http.StatusOK, "Payout recorded", gin.H{"transaction": txn})
 }
 
 // ══════════════════════════════════════════════════
 // Admin: Vehicle Type Management
 // ══════════════════════════════════════════════════
 
-// GET /api/v1/admin/vehicle-types — all vehicle types (including inactive)
+// GET /api/v1/admin/vehicle-types?tenant=... — all vehicle types (including inactive)
 func AdminGetAllVehicleTypes(c *gin.Context) {
-	rows, err := db.Pool.Query(context.Background(),
-		`SELECT id, name, "baseFare", "perKmRate", "perMinRate", COALESCE(icon, ''), "isActive", "createdAt", "updatedAt" 
-		 FROM vehicle_types ORDER BY "baseFare" ASC`)
+	rows, err := db.Pool.Query(adminTenantContext(c),
+		`SELECT `+vehicleTypeSelectCols+` FROM vehicle_types ORDER BY "baseFare" ASC`)
 	if err != nil {
 		utils.RespondError(c, http.StatusInternalServerError, "Failed to fetch vehicle types", err)
 		return
 	}
 	defer rows.Close()
 
+	now := time.Now()
 	var types []models.VehicleTypeConfig
 	for rows.Next() {
 		var vt models.VehicleTypeConfig
-		rows.Scan(&vt.ID, &vt.Name, &vt.BaseFare, &vt.PerKmRate, &vt.PerMinRate, &vt.Icon, &vt.IsActive, &vt.CreatedAt, &vt.UpdatedAt)
+		scanVehicleType(rows, &vt)
+		applyVehicleAvailability(&vt, "", now)
 		types = append(types, vt)
 	}
 	if types == nil {
@@ -946,7 +1104,7 @@ func AdminGetAllVehicleTypes(c *gin.Context) {
 	utils.RespondSuccess(c, http.StatusOK, "All vehicle types", gin.H{"vehicleTypes": types})
 }
 
-// PUT /api/v1/admin/vehicle-type — create or update
+// PUT /api/v1/admin/vehicle-type?tenant=... — create or update
 func AdminUpsertVehicleType(c *gin.Context) {
 	var body struct {
 		ID         string  `json:"id"`
@@ -955,31 +1113,77 @@ func AdminUpsertVehicleType(c *gin.Context) {
 		PerKmRate  float64 `json:"perKmRate" binding:"required"`
 		PerMinRate float64 `json:"perMinRate" binding:"required"`
 		Icon       string  `json:"icon"`
+
+		// Display metadata returned to the apps
+		Capacity    *int   `json:"capacity"`
+		Description string `json:"description"`
+		ETABlurb    string `json:"etaBlurb"` // e.g. "Affordable rides, arrives in minutes"
+
+		AllowedZones   []string `json:"allowedZones"`   // empty = all zones
+		AvailableFrom  *string  `json:"availableFrom"`  // "HH:MM", nil = all day
+		AvailableUntil *string  `json:"availableUntil"` // "HH:MM", may wrap midnight
+
+		IsElectric     bool     `json:"isElectric"`
+		EmissionFactor *float64 `json:"emissionFactor"` // g CO2/km, nil = default for EV/non-EV
 	}
 	if err := c.ShouldBindJSON(&body); err != nil {
 		utils.RespondError(c, http.StatusBadRequest, "Invalid request", err)
 		return
 	}
 
+	if (body.AvailableFrom == nil) != (body.AvailableUntil == nil) {
+		utils.RespondError(c, http.StatusBadRequest, "availableFrom and availableUntil must be set together", nil)
+		return
+	}
+	for _, t := range []*string{body.AvailableFrom, body.AvailableUntil} {
+		if t == nil {
+			continue
+		}
+		if _, err := utils.ParseClock(*t); err != nil {
+			utils.RespondError(c, http.StatusBadRequest, err.Error(), err)
+			return
+		}
+	}
+	if body.AllowedZones == nil {
+		body.AllowedZones = []string{}
+	}
+	if body.Capacity != nil && *body.Capacity < 1 {
+		utils.RespondError(c, http.StatusBadRequest, "capacity must be at least 1", nil)
+		return
+	}
+	if body.EmissionFactor != nil && *body.EmissionFactor < 0 {
+		utils.RespondError(c, http.StatusBadRequest, "emissionFactor can't be negative", nil)
+		return
+	}
+
 	if body.ID != "" {
-		_, err := db.Pool.Exec(context.Background(),
-			`UPDATE vehicle_types SET name=$1, "baseFare"=$2, "perKmRate"=$3, "perMinRate"=$4, icon=$5, "updatedAt"=NOW() WHERE id=$6`,
-			body.Name, body.BaseFare, body.PerKmRate, body.PerMinRate, body.Icon, body.ID)
+		_, err := db.Pool.Exec(adminContext(c),
+			`UPDATE vehicle_types SET name=$1, "baseFare"=$2, "perKmRate"=$3, "perMinRate"=$4, icon=$5,
+			 "allowedZones"=$6, "availableFrom"=$7, "availableUntil"=$8, capacity=$9, description=$10, "etaBlurb"=$11,
+			 "isElectric"=$13, "emissionFactor"=$14, "updatedAt"=NOW() WHERE id=$12`,
+			body.Name, body.BaseFare, body.PerKmRate, body.PerMinRate, body.Icon,
+			body.AllowedZones, body.AvailableFrom, body.AvailableUntil, body.Capacity, body.Description, body.ETABlurb, body.ID,
+			body.IsElectric, body.EmissionFactor)
 		if err != nil {
 			utils.RespondError(c, http.StatusInternalServerError, "Failed to update vehicle type", err)
 			return
 		}
+		invalidateVehicleTypes(c.Request.Context())
 		utils.RespondSuccess(c, http.StatusOK, "Vehicle type updated", nil)
 	} else {
 		var id string
-		err := db.Pool.QueryRow(context.Background(),
-			`INSERT INTO vehicle_types (id, name, "baseFare", "perKmRate", "perMinRate", icon) 
-			 VALUES (gen_random_uuid()::text, $1, $2, $3, $4, $5) RETURNING id`,
-			body.Name, body.BaseFare, body.PerKmRate, body.PerMinRate, body.Icon).Scan(&id)
+		err := db.Pool.QueryRow(adminTenantContext(c),
+			`INSERT INTO vehicle_types (id, name, "baseFare", "perKmRate", "perMinRate", icon, "allowedZones", "availableFrom", "availableUntil",
+			 capacity, description, "etaBlurb", "isElectric", "emissionFactor") 
+			 VALUES (gen_random_uuid()::text, $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13) RETURNING id`,
+			body.Name, body.BaseFare, body.PerKmRate, body.PerMinRate, body.Icon,
+			body.AllowedZones, body.AvailableFrom, body.AvailableUntil, body.Capacity, body.Description, body.ETABlurb,
+			body.IsElectric, body.EmissionFactor).Scan(&id)
 		if err != nil {
 			utils.RespondError(c, http.StatusInternalServerError, "Failed to create vehicle type", err)
 			return
 		}
+		invalidateVehicleTypes(c.Request.Context())
 		utils.RespondSuccess(c, http.StatusCreated, "Vehicle type created", gin.H{"id": id})
 	}
 }
@@ -987,12 +1191,13 @@ func AdminUpsertVehicleType(c *gin.Context) {
 // DELETE /api/v1/admin/vehicle-type/:id — soft delete (deactivate)
 func AdminDeleteVehicleType(c *gin.Context) {
 	id := c.Param("id")
-	_, err := db.Pool.Exec(context.Background(),
+	_, err := db.Pool.Exec(adminContext(c),
 		`UPDATE vehicle_types SET "isActive"=FALSE, "updatedAt"=NOW() WHERE id=$1`, id)
 	if err != nil {
 		utils.RespondError(c, http.StatusInternalServerError, "Failed to deactivate vehicle type", err)
 		return
 	}
+	invalidateVehicleTypes(c.Request.Context())
 	utils.RespondSuccess(c, http.StatusOK, "Vehicle type deactivated", nil)
 }
 
@@ -1000,20 +1205,22 @@ func AdminDeleteVehicleType(c *gin.Context) {
 // Admin: SOS Alert Management
 // ══════════════════════════════════════════════════
 
-// GET /api/v1/admin/sos-alerts?status=active
+// GET /api/v1/admin/sos-alerts?status=open|active|acknowledged|resolved
 func AdminGetSOSAlerts(c *gin.Context) {
-	statusFilter := c.DefaultQuery("status", "active")
+	statusFilter := c.DefaultQuery("status", "open")
 
-	rows, err := db.Pool.Query(context.Background(),
+	rows, err := db.Pool.Query(adminContext(c),
 		`SELECT s.id, COALESCE(s."rideId",''), s."userId", COALESCE(s.lat, 0), COALESCE(s.lng, 0), s.status, s."createdAt",
 		 COALESCE(u.name,'') as userName, u.phone_number as userPhone,
 		 COALESCE(r."currentLocationName",'') as origin, COALESCE(r."destinationLocationName",'') as destination,
-		 COALESCE(d.name,'') as driverName, COALESCE(d.phone_number,'') as driverPhone
+		 COALESCE(d.name,'') as driverName, COALESCE(d.phone_number,'') as driverPhone,
+		 s."driverLat", s."driverLng", s."driverLocationAt", s."escalationCount", s."lastEscalatedAt",
+		 s."acknowledgedAt", s."acknowledgedBy", s."resolvedAt", s."resolvedBy", s."resolutionNote"
 		 FROM sos_alerts s
 		 LEFT JOIN "user" u ON s."userId"=u.id
 		 LEFT JOIN rides r ON s."rideId"=r.id
 		 LEFT JOIN driver d ON r."driverId"=d.id
-		 WHERE s.status=$1
+		 WHERE s.status=$1 OR ($1='open' AND s.status IN ('active','acknowledged'))
 		 ORDER BY s."createdAt" DESC`, statusFilter)
 	if err != nil {
 		utils.RespondError(c, http.StatusInternalServerError, "Failed to fetch SOS alerts", err)
@@ -1022,26 +1229,38 @@ func AdminGetSOSAlerts(c *gin.Context) {
 	defer rows.Close()
 
 	type SOSDetail struct {
-		ID          string  `json:"id"`
-		RideID      string  `json:"rideId"`
-		UserID      string  `json:"userId"`
-		Lat         float64 `json:"lat"`
-		Lng         float64 `json:"lng"`
-		Status      string  `json:"status"`
-		CreatedAt   string  `json:"createdAt"`
-		UserName    string  `json:"userName"`
-		UserPhone   string  `json:"userPhone"`
-		Origin      string  `json:"origin"`
-		Destination string  `json:"destination"`
-		DriverName  string  `json:"driverName"`
-		DriverPhone string  `json:"driverPhone"`
+		ID               string     `json:"id"`
+		RideID           string     `json:"rideId"`
+		UserID           string     `json:"userId"`
+		Lat              float64    `json:"lat"`
+		Lng              float64    `json:"lng"`
+		Status           string     `json:"status"`
+		CreatedAt        string     `json:"createdAt"`
+		UserName         string     `json:"userName"`
+		UserPhone        string     `json:"userPhone"`
+		Origin           string     `json:"origin"`
+		Destination      string     `json:"destination"`
+		DriverName       string     `json:"driverName"`
+		DriverPhone      string     `json:"driverPhone"`
+		DriverLat        *float64   `json:"driverLat"`
+		DriverLng        *float64   `json:"driverLng"`
+		DriverLocationAt *time.Time `json:"driverLocationAt"`
+		EscalationCount  int        `json:"escalationCount"`
+		LastEscalatedAt  *time.Time `json:"lastEscalatedAt"`
+		AcknowledgedAt   *time.Time `json:"acknowledgedAt"`
+		AcknowledgedBy   *string    `json:"acknowledgedBy"`
+		ResolvedAt       *time.Time `json:"resolvedAt"`
+		ResolvedBy       *string    `json:"resolvedBy"`
+		ResolutionNote   *string    `json:"resolutionNote"`
 	}
 
 	var alerts []SOSDetail
 	for rows.Next() {
 		var a SOSDetail
 		rows.Scan(&a.ID, &a.RideID, &a.UserID, &a.Lat, &a.Lng, &a.Status, &a.CreatedAt,
-			&a.UserName, &a.UserPhone, &a.Origin, &a.Destination, &a.DriverName, &a.DriverPhone)
+			&a.UserName, &a.UserPhone, &a.Origin, &a.Destination, &a.DriverName, &a.DriverPhone,
+			&a.DriverLat, &a.DriverLng, &a.DriverLocationAt, &a.EscalationCount, &a.LastEscalatedAt,
+			&a.AcknowledgedAt, &a.AcknowledgedBy, &a.ResolvedAt, &a.ResolvedBy, &a.ResolutionNote)
 		alerts = append(alerts, a)
 	}
 	if alerts == nil {
@@ -1054,15 +1273,28 @@ func AdminGetSOSAlerts(c *gin.Context) {
 	})
 }
 
-// PUT /api/v1/admin/sos/:id/resolve
+// PUT /api/v1/admin/sos/:id/resolve — optional {note}; resolving an unacknowledged alert acknowledges it too
 func AdminResolveSOSAlert(c *gin.Context) {
 	alertID := c.Param("id")
-	_, err := db.Pool.Exec(context.Background(),
-		`UPDATE sos_alerts SET status='resolved', "resolvedAt"=NOW() WHERE id=$1`, alertID)
+	admin := c.MustGet("admin").(*models.AdminAccount)
+	var body struct {
+		Note string `json:"note"`
+	}
+	c.ShouldBindJSON(&body)
+
+	tag, err := db.Pool.Exec(adminContext(c),
+		`UPDATE sos_alerts SET status='resolved', "resolvedAt"=NOW(), "resolvedBy"=$2, "resolutionNote"=NULLIF($3, ''),
+		 "acknowledgedAt"=COALESCE("acknowledgedAt", NOW()), "acknowledgedBy"=COALESCE("acknowledgedBy", $2)
+		 WHERE id=$1 AND status<>'resolved'`, alertID, admin.Email, strings.TrimSpace(body.Note))
 	if err != nil {
 		utils.RespondError(c, http.StatusInternalServerError, "Failed to resolve SOS alert", err)
 		return
 	}
+	if tag.RowsAffected() == 0 {
+		utils.RespondError(c, http.StatusNotFound, "SOS alert not found or already resolved", nil)
+		return
+	}
+	publishAdminOps("sosUpdate", map[string]any{"alertId": alertID, "status": sosResolved, "by": admin.Email})
 	utils.RespondSuccess(c, http.StatusOK, "SOS alert resolved", nil)
 }
 
@@ -1072,10 +1304,8 @@ func AdminResolveSOSAlert(c *gin.Context) {
 
 // GET /api/v1/admin/promo-codes
 func AdminGetPromoCodes(c *gin.Context) {
-	rows, err := db.Pool.Query(context.Background(),
-		`SELECT id, code, "discountType", "discountValue", "maxDiscount", "minRideAmount", 
-		 "usageLimit", "usedCount", "expiresAt", "isActive", "createdAt"
-		 FROM promo_codes ORDER BY "createdAt" DESC`)
+	rows, err := db.Pool.Query(adminContext(c),
+		`SELECT `+promoSelectCols+` FROM promo_codes WHERE "userId" IS NULL ORDER BY "createdAt" DESC`)
 	if err != nil {
 		utils.RespondError(c, http.StatusInternalServerError, "Failed to fetch promo codes", err)
 		return
@@ -1085,8 +1315,7 @@ func AdminGetPromoCodes(c *gin.Context) {
 	var codes []models.PromoCode
 	for rows.Next() {
 		var pc models.PromoCode
-		rows.Scan(&pc.ID, &pc.Code, &pc.DiscountType, &pc.DiscountValue, &pc.MaxDiscount,
-			&pc.MinRideAmount, &pc.UsageLimit, &pc.UsedCount, &pc.ExpiresAt, &pc.IsActive, &pc.CreatedAt)
+		scanPromoCode(rows, &pc)
 		codes = append(codes, pc)
 	}
 	if codes == nil {
@@ -1105,11 +1334,16 @@ func AdminCreatePromoCode(c *gin.Context) {
 		MinRideAmount float64  `json:"minRideAmount"`
 		UsageLimit    int      `json:"usageLimit"`
 		ExpiresAt     *string  `json:"expiresAt"` // ISO date string
+		Rules         models.PromoRules `json:"rules"`
 	}
 	if err := c.ShouldBindJSON(&body); err != nil {
 		utils.RespondError(c, http.StatusBadRequest, "Invalid request", err)
 		return
 	}
+	if err := promos.Validate(body.Rules); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
 
 	if body.UsageLimit == 0 {
 		body.UsageLimit = 100
@@ -1121,10 +1355,14 @@ func AdminCreatePromoCode(c *gin.Context) {
 		expiresAt = *body.ExpiresAt
 	}
 
-	err := db.Pool.QueryRow(context.Background(),
-		`INSERT INTO promo_codes (id, code, "discountType", "discountValue", "maxDiscount", "minRideAmount", "usageLimit", "expiresAt")
-		 VALUES (gen_random_uuid()::text, $1, $2, $3, $4, $5, $6, $7) RETURNING id`,
-		body.Code, body.DiscountType, body.DiscountValue, body.MaxDiscount, body.MinRideAmount, body.UsageLimit, expiresAt).Scan(&id)
+	r := body.Rules
+	err := db.Pool.QueryRow(adminContext(c),
+		`INSERT INTO promo_codes (id, code, "discountType", "discountValue", "maxDiscount", "minRideAmount", "usageLimit", "expiresAt",
+		 "firstRideOnly", "vehicleTypes", zones, segments, days, "timeFrom", "timeUntil", "perUserLimit")
+		 VALUES (gen_random_uuid()::text, $1, $2, $3, $4, $5, $6, $7,
+		 $8, COALESCE($9, '{}'::text[]), COALESCE($10, '{}'::text[]), COALESCE($11, '{}'::text[]), COALESCE($12, '{}'::int[]), $13, $14, $15) RETURNING id`,
+		body.Code, body.DiscountType, body.DiscountValue, body.MaxDiscount, body.MinRideAmount, body.UsageLimit, expiresAt,
+		r.FirstRideOnly, r.VehicleTypes, r.Zones, r.Segments, r.Days, r.TimeFrom, r.TimeUntil, r.PerUserLimit).Scan(&id)
 	if err != nil {
 		utils.RespondError(c, http.StatusInternalServerError, "Failed to create promo code", err)
 		return
@@ -1138,20 +1376,34 @@ func AdminUpdatePromoCode(c *gin.Context) {
 	var body struct {
 		IsActive  *bool `json:"isActive"`
 		UsageLimit *int `json:"usageLimit"`
+		Rules     *models.PromoRules `json:"rules"` // replaces all the rules
 	}
 	if err := c.ShouldBindJSON(&body); err != nil {
 		utils.RespondError(c, http.StatusBadRequest, "Invalid request", err)
 		return
 	}
+	if body.Rules != nil {
+		if err := promos.Validate(*body.Rules); err != nil {
+			utils.RespondError(c, http.StatusBadRequest, err.Error(), nil)
+			return
+		}
+	}
 
 	if body.IsActive != nil {
-		db.Pool.Exec(context.Background(),
+		db.Pool.Exec(adminContext(c),
 			`UPDATE promo_codes SET "isActive"=$1 WHERE id=$2`, *body.IsActive, promoID)
 	}
 	if body.UsageLimit != nil {
-		db.Pool.Exec(context.Background(),
+		db.Pool.Exec(adminContext(c),
 			`UPDATE promo_codes SET "usageLimit"=$1 WHERE id=$2`, *body.UsageLimit, promoID)
 	}
+	if r := body.Rules; r != nil {
+		db.Pool.Exec(adminContext(c),
+			`UPDATE promo_codes SET "firstRideOnly"=$2, "vehicleTypes"=COALESCE($3, '{}'::text[]), zones=COALESCE($4, '{}'::text[]),
+			 segments=COALESCE($5, '{}'::text[]), days=COALESCE($6, '{}'::int[]), "timeFrom"=$7, "timeUntil"=$8, "perUserLimit"=$9
+			 WHERE id=$1`,
+			promoID, r.FirstRideOnly, r.VehicleTypes, r.Zones, r.Segments, r.Days, r.TimeFrom, r.TimeUntil, r.PerUserLimit)
+	}
 
 	utils.RespondSuccess(c, http.StatusOK, "Promo code updated", nil)
 }
@@ -1159,7 +1411,7 @@ func AdminUpdatePromoCode(c *gin.Context) {
 // DELETE /api/v1/admin/promo-code/:id
 func AdminDeletePromoCode(c *gin.Context) {
 	id := c.Param("id")
-	db.Pool.Exec(context.Background(),
+	db.Pool.Exec(adminContext(c),
 		`UPDATE promo_codes SET "isActive"=FALSE WHERE id=$1`, id)
 	utils.RespondSuccess(c, http.StatusOK, "Promo code deactivated", nil)
 }
@@ -1193,28 +1445,36 @@ func AdminDailyAnalytics(c *gin.Context) {
 		ActiveUsers     int     `json:"activeUsers"`
 	}
 
-	var summary OverallStats
-	db.Pool.QueryRow(context.Background(),
-		`SELECT 
-		 COUNT(*),
-		 SUM(CASE WHEN status='Completed' THEN 1 ELSE 0 END),
-		 SUM(CASE WHEN status='Cancelled' THEN 1 ELSE 0 END),
-		 SUM(CASE WHEN status='InProgress' THEN 1 ELSE 0 END),
-		 SUM(CASE WHEN status='Requested' THEN 1 ELSE 0 END),
-		 COALESCE(SUM(CASE WHEN status='Completed' THEN charge ELSE 0 END), 0),
-		 COALESCE(AVG(CASE WHEN status='Completed' THEN charge END), 0),
-		 COALESCE(SUM(tips), 0),
-		 COALESCE(SUM(CASE WHEN status='Completed' THEN CAST(distance AS DOUBLE PRECISION) ELSE 0 END) / 1000.0, 0)
-		 FROM rides`).
-		Scan(&summary.TotalRides, &summary.CompletedRides, &summary.CancelledRides,
-			&summary.InProgressRides, &summary.RequestedRides,
-			&summary.TotalRevenue, &summary.AverageFare, &summary.TotalTips, &summary.TotalDistance)
-
-	db.Pool.QueryRow(context.Background(), `SELECT COUNT(*) FROM "user"`).Scan(&summary.TotalUsers)
-	db.Pool.QueryRow(context.Background(), `SELECT COUNT(*) FROM "user" WHERE status='active'`).Scan(&summary.ActiveUsers)
-	db.Pool.QueryRow(context.Background(), `SELECT COUNT(*) FROM driver`).Scan(&summary.TotalDrivers)
-	db.Pool.QueryRow(context.Background(), `SELECT COUNT(*) FROM driver WHERE status='pending'`).Scan(&summary.PendingDrivers)
-	db.Pool.QueryRow(context.Background(), `SELECT COUNT(*) FROM driver WHERE "isOnline"=TRUE AND status='active'`).Scan(&summary.OnlineDrivers)
+	// Rides, revenue and sign-ups come from the stats tables (analytics_stats.go); open rides and
+	// driver and user status are live
+	total, err := sumDailyStats(adminContext(c), 0)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to load analytics", err)
+		return
+	}
+	live, err := loadLiveCounts(adminContext(c))
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to load analytics", err)
+		return
+	}
+	summary := OverallStats{
+		TotalRides:      total.Rides,
+		CompletedRides:  total.Completed,
+		CancelledRides:  total.Cancelled,
+		InProgressRides: live.InProgressRides,
+		RequestedRides:  live.RequestedRides,
+		TotalRevenue:    total.Revenue,
+		TotalTips:       total.Tips,
+		TotalDistance:   total.DistanceKm,
+		TotalUsers:      total.NewUsers,
+		TotalDrivers:    total.NewDrivers,
+		PendingDrivers:  live.PendingDrivers,
+		OnlineDrivers:   live.OnlineDrivers,
+		ActiveUsers:     total.NewUsers - live.InactiveUsers,
+	}
+	if total.Completed > 0 {
+		summary.AverageFare = total.Revenue / float64(total.Completed)
+	}
 
 	// ── 2. Peak hours analysis (which hours get most rides) ──
 	type PeakHour struct {
@@ -1223,11 +1483,10 @@ func AdminDailyAnalytics(c *gin.Context) {
 		Revenue    float64 `json:"revenue"`
 	}
 
-	peakRows, _ := db.Pool.Query(context.Background(),
-		`SELECT EXTRACT(HOUR FROM "createdAt")::int as hour, COUNT(*) as rides, 
-		 COALESCE(SUM(CASE WHEN status='Completed' THEN charge ELSE 0 END), 0) as revenue
-		 FROM rides WHERE "createdAt" >= NOW() - ($1 || ' days')::interval
-		 GROUP BY EXTRACT(HOUR FROM "createdAt") ORDER BY rides DESC`, days)
+	peakRows, _ := db.Pool.Query(adminContext(c),
+		`SELECT EXTRACT(HOUR FROM hour)::int as h, SUM(rides)::int as rides, SUM(revenue) as revenue
+		 FROM hourly_stats WHERE hour >= NOW() - ($1 || ' days')::interval
+		 GROUP BY h ORDER BY rides DESC`, days)
 
 	var peakHours []PeakHour
 	if peakRows != nil {
@@ -1253,24 +1512,12 @@ func AdminDailyAnalytics(c *gin.Context) {
 		NewDrivers int       `json:"newDrivers"`
 	}
 
-	dailyRows, _ := db.Pool.Query(context.Background(),
+	dailyRows, _ := db.Pool.Query(adminContext(c),
 		`SELECT d.day,
-		 COALESCE(r.total, 0), COALESCE(r.completed, 0), COALESCE(r.cancelled, 0), COALESCE(r.revenue, 0),
-		 COALESCE(u.new_users, 0), COALESCE(dr.new_drivers, 0)
+		 COALESCE(s.rides, 0), COALESCE(s.completed, 0), COALESCE(s.cancelled, 0), COALESCE(s.revenue, 0),
+		 COALESCE(s."newUsers", 0), COALESCE(s."newDrivers", 0)
 		 FROM generate_series(CURRENT_DATE - ($1 || ' days')::interval, CURRENT_DATE, '1 day') AS d(day)
-		 LEFT JOIN (
-			SELECT DATE("createdAt") as day, COUNT(*) as total,
-			SUM(CASE WHEN status='Completed' THEN 1 ELSE 0 END) as completed,
-			SUM(CASE WHEN status='Cancelled' THEN 1 ELSE 0 END) as cancelled,
-			COALESCE(SUM(CASE WHEN status='Completed' THEN charge ELSE 0 END), 0) as revenue
-			FROM rides GROUP BY DATE("createdAt")
-		 ) r ON r.day = d.day
-		 LEFT JOIN (
-			SELECT DATE("createdAt") as day, COUNT(*) as new_users FROM "user" GROUP BY DATE("createdAt")
-		 ) u ON u.day = d.day
-		 LEFT JOIN (
-			SELECT DATE("createdAt") as day, COUNT(*) as new_drivers FROM driver GROUP BY DATE("createdAt")
-		 ) dr ON dr.day = d.day
+		 LEFT JOIN daily_stats s ON s.day = d.day
 		 ORDER BY d.day ASC`, days)
 
 	var dailyStats []DayStat
@@ -1298,7 +1545,7 @@ func AdminDailyAnalytics(c *gin.Context) {
 		IsOnline     bool    `json:"isOnline"`
 	}
 
-	topRows, _ := db.Pool.Query(context.Background(),
+	topRows, _ := db.Pool.Query(adminContext(c),
 		`SELECT id, name, phone_number, "totalEarning", "totalRides", "totalDistance", ratings, "isOnline"
 		 FROM driver WHERE status='active' ORDER BY "totalEarning" DESC LIMIT 10`)
 
@@ -1322,11 +1569,10 @@ func AdminDailyAnalytics(c *gin.Context) {
 		Revenue     float64 `json:"revenue"`
 	}
 
-	vtRows, _ := db.Pool.Query(context.Background(),
-		`SELECT COALESCE("vehicleType", 'Unknown'), COUNT(*), 
-		 COALESCE(SUM(CASE WHEN status='Completed' THEN charge ELSE 0 END), 0)
-		 FROM rides WHERE "createdAt" >= NOW() - ($1 || ' days')::interval
-		 GROUP BY "vehicleType" ORDER BY COUNT(*) DESC`, days)
+	vtRows, _ := db.Pool.Query(adminContext(c),
+		`SELECT "vehicleType", SUM(rides)::int, SUM(revenue)
+		 FROM daily_vehicle_stats WHERE day >= CURRENT_DATE - $1::int
+		 GROUP BY "vehicleType" ORDER BY SUM(rides) DESC`, days)
 
 	var vehicleBreakdown []VehicleBreakdown
 	if vtRows != nil {
@@ -1347,7 +1593,9 @@ func AdminDailyAnalytics(c *gin.Context) {
 		"daily":            dailyStats,
 		"topDrivers":       topDrivers,
 		"vehicleBreakdown": vehicleBreakdown,
+		"utilization":      driverUtilization(adminContext(c), "", time.Now().AddDate(0, 0, -days), time.Now()),
 		"days":             days,
+		"statsRefreshedAt": statsRefreshedAt(adminContext(c)),
 	})
 }
 
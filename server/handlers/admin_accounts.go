@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+	"ridewave/db"
+	"ridewave/middleware"
+	"ridewave/models"
+	"ridewave/utils"
+)
+
+// ══════════════════════════════════════════════════
+// Admin Accounts — login & role management
+// ══════════════════════════════════════════════════
+
+const (
+	adminLoginAttemptsKeyPrefix = "admin_login_attempts:"
+	maxAdminLoginAttempts       = 5
+	minAdminPasswordLength      = 10
+)
+
+const adminAccountSelectCols = `id, email, name, "passwordHash", role, "isActive", "lastLoginAt", "createdAt", "updatedAt"`
+
+func scanAdminAccount(scanner interface{ Scan(dest ...any) error }, a *models.AdminAccount) error {
+	return scanner.Scan(&a.ID, &a.Email, &a.Name, &a.PasswordHash, &a.Role, &a.IsActive, &a.LastLoginAt, &a.CreatedAt, &a.UpdatedAt)
+}
+
+func isAdminRole(role string) bool {
+	return role == middleware.RoleSuperadmin || role == middleware.RoleSupport || role == middleware.RoleFinance
+}
+
+// EnsureBootstrapAdmin creates the first superadmin from ADMIN_BOOTSTRAP_EMAIL / ADMIN_BOOTSTRAP_PASSWORD
+// when no admin accounts exist yet. It is a no-op once any account has been created.
+func EnsureBootstrapAdmin() {
+	email := strings.ToLower(strings.TrimSpace(os.Getenv("ADMIN_BOOTSTRAP_EMAIL")))
+	password := os.Getenv("ADMIN_BOOTSTRAP_PASSWORD")
+	if email == "" || password == "" {
+		return
+	}
+
+	var count int
+	db.Pool.QueryRow(context.Background(), `SELECT COUNT(*) FROM admin_accounts`).Scan(&count)
+	if count > 0 {
+		return
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		utils.Logger.Error("Failed to hash bootstrap admin password", zap.Error(err))
+		return
+	}
+	_, err = db.Pool.Exec(context.Background(),
+		`INSERT INTO admin_accounts (email, name, "passwordHash", role) VALUES ($1, 'Superadmin', $2, $3)
+		 ON CONFLICT (email) DO NOTHING`, email, string(hash), middleware.RoleSuperadmin)
+	if err != nil {
+		utils.Logger.Error("Failed to create bootstrap admin", zap.Error(err))
+		return
+	}
+	utils.Logger.Info("Bootstrap superadmin created", zap.String("email", email))
+}
+
+// POST /api/v1/admin/auth/login
+func AdminLogin(c *gin.Context) {
+	var body struct {
+		Email    string `json:"email" binding:"required"`
+		Password string `json:"password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid request", err)
+		return
+	}
+	email := strings.ToLower(strings.TrimSpace(body.Email))
+
+	// Lock the account out for a while after repeated bad passwords
+	attemptsKey := adminLoginAttemptsKeyPrefix + email
+	attempts, _ := db.RedisClient.Get(adminContext(c), attemptsKey).Int()
+	if attempts >= maxAdminLoginAttempts {
+		utils.RespondError(c, http.StatusTooManyRequests, "Too many failed attempts. Try again in 15 minutes.", nil)
+		return
+	}
+
+	var admin models.AdminAccount
+	err := scanAdminAccount(db.Pool.QueryRow(adminContext(c),
+		`SELECT `+adminAccountSelectCols+` FROM admin_accounts WHERE email=$1`, email), &admin)
+	if err == nil {
+		err = bcrypt.CompareHashAndPassword([]byte(admin.PasswordHash), []byte(body.Password))
+	}
+	if err != nil {
+		db.RedisClient.Incr(adminContext(c), attemptsKey)
+		db.RedisClient.Expire(adminContext(c), attemptsKey, 15*time.Minute)
+		utils.RespondError(c, http.StatusUnauthorized, "Invalid email or password", nil)
+		return
+	}
+	if !admin.IsActive {
+		utils.RespondError(c, http.StatusForbidden, "Your admin account has been disabled", nil)
+		return
+	}
+	db.RedisClient.Del(adminContext(c), attemptsKey)
+
+	token, expiresAt, err := utils.GenerateAdminToken(admin.ID)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to generate token", err)
+		return
+	}
+	db.Pool.Exec(adminContext(c), `UPDATE admin_accounts SET "lastLoginAt"=NOW() WHERE id=$1`, admin.ID)
+
+	utils.RespondSuccess(c, http.StatusOK, "Authentication successful", gin.H{
+		"accessToken": token,
+		"expiresAt":   expiresAt,
+		"admin":       admin,
+	})
+}
+
+// GET /api/v1/admin/me
+func AdminGetMe(c *gin.Context) {
+	admin := c.MustGet("admin").(*models.AdminAccount)
+	utils.RespondSuccess(c, http.StatusOK, "Admin profile", gin.H{"admin": admin})
+}
+
+// GET /api/v1/admin/accounts
+func AdminGetAccounts(c *gin.Context) {
+	rows, err := db.Pool.Query(adminContext(c),
+		`SELECT `+adminAccountSelectCols+` FROM admin_accounts ORDER BY "createdAt" ASC`)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to fetch admin accounts", err)
+		return
+	}
+	defer rows.Close()
+
+	accounts := []models.AdminAccount{}
+	for rows.Next() {
+		var a models.AdminAccount
+		if scanAdminAccount(rows, &a) == nil {
+			accounts = append(accounts, a)
+		}
+	}
+	utils.RespondSuccess(c, http.StatusOK, "Admin accounts", gin.H{"accounts": accounts})
+}
+
+// POST /api/v1/admin/accounts
+func AdminCreateAccount(c *gin.Context) {
+	var body struct {
+		Email    string `json:"email" binding:"required"`
+		Name     string `json:"name"`
+		Password string `json:"password" binding:"required"`
+		Role     string `json:"role" binding:"required"` // superadmin | support | finance
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid request", err)
+		return
+	}
+	if !isAdminRole(body.Role) {
+		utils.RespondError(c, http.StatusBadRequest, "Role must be superadmin, support or finance", nil)
+		return
+	}
+	if len(body.Password) < minAdminPasswordLength {
+		utils.RespondError(c, http.StatusBadRequest, "Password must be at least 10 characters", nil)
+		return
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(body.Password), bcrypt.DefaultCost)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to create admin account", err)
+		return
+	}
+
+	var account models.AdminAccount
+	err = scanAdminAccount(db.Pool.QueryRow(adminContext(c),
+		`INSERT INTO admin_accounts (email, name, "passwordHash", role) VALUES ($1, $2, $3, $4)
+		 ON CONFLICT (email) DO NOTHING RETURNING `+adminAccountSelectCols,
+		strings.ToLower(strings.TrimSpace(body.Email)), body.Name, string(hash), body.Role), &account)
+	if errors.Is(err, pgx.ErrNoRows) {
+		utils.RespondError(c, http.StatusConflict, "An admin with this email already exists", nil)
+		return
+	}
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to create admin account", err)
+		return
+	}
+	utils.RespondSuccess(c, http.StatusCreated, "Admin account created", gin.H{"account": account})
+}
+
+// PUT /api/v1/admin/account/:id — change role, enable/disable, or reset password
+func AdminUpdateAccount(c *gin.Context) {
+	self := c.MustGet("admin").(*models.AdminAccount)
+	accountID := c.Param("id")
+	var body struct {
+		Name     *string `json:"name"`
+		Role     *string `json:"role"`
+		IsActive *bool   `json:"isActive"`
+		Password *string `json:"password"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid request", err)
+		return
+	}
+	if body.Role != nil && !isAdminRole(*body.Role) {
+		utils.RespondError(c, http.StatusBadRequest, "Role must be superadmin, support or finance", nil)
+		return
+	}
+	// Stop a superadmin from locking themselves out
+	if accountID == self.ID && ((body.Role != nil && *body.Role != middleware.RoleSuperadmin) || (body.IsActive != nil && !*body.IsActive)) {
+		utils.RespondError(c, http.StatusBadRequest, "You can't demote or disable your own account", nil)
+		return
+	}
+
+	var passwordHash *string
+	if body.Password != nil {
+		if len(*body.Password) < minAdminPasswordLength {
+			utils.RespondError(c, http.StatusBadRequest, "Password must be at least 10 characters", nil)
+			return
+		}
+		hash, err := bcrypt.GenerateFromPassword([]byte(*body.Password), bcrypt.DefaultCost)
+		if err != nil {
+			utils.RespondError(c, http.StatusInternalServerError, "Failed to update admin account", err)
+			return
+		}
+		h := string(hash)
+		passwordHash = &h
+	}
+
+	var account models.AdminAccount
+	err := scanAdminAccount(db.Pool.QueryRow(adminContext(c),
+		`UPDATE admin_accounts SET name=COALESCE($1, name), role=COALESCE($2, role), "isActive"=COALESCE($3, "isActive"),
+		 "passwordHash"=COALESCE($4, "passwordHash"), "updatedAt"=NOW()
+		 WHERE id=$5 RETURNING `+adminAccountSelectCols,
+		body.Name, body.Role, body.IsActive, passwordHash, accountID), &account)
+	if err != nil {
+		utils.RespondError(c, http.StatusNotFound, "Admin account not found", err)
+		return
+	}
+	utils.RespondSuccess(c, http.StatusOK, "Admin account updated", gin.H{"account": account})
+}
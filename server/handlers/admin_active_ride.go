@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"ridewave/db"
+	"ridewave/stores"
+	"ridewave/utils"
+)
+
+// ══════════════════════════════════════════════════
+// Admin: Active Ride — support's live view of a trip in progress
+// ══════════════════════════════════════════════════
+
+// activeRideMessageLimit is how many of the ride's recent messages support sees.
+const activeRideMessageLimit = 20
+
+type activeRidePoint struct {
+	Name   string   `json:"name,omitempty"`
+	Lat    *float64 `json:"lat"`
+	Lng    *float64 `json:"lng"`
+	Source string   `json:"source,omitempty"`
+}
+
+type activeRideParty struct {
+	ID       string           `json:"id"`
+	Name     string           `json:"name"`
+	Phone    string           `json:"phone"`
+	Vehicle  string           `json:"vehicle,omitempty"`
+	Location *activeRidePoint `json:"location"`
+}
+
+type activeRideMessage struct {
+	To        string    `json:"to"` // user | driver
+	Title     string    `json:"title"`
+	Body      string    `json:"body"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// GET /api/v1/admin/user/:id/active-ride
+func AdminGetUserActiveRide(c *gin.Context) {
+	adminActiveRide(c, `r."userId"=$1`)
+}
+
+// GET /api/v1/admin/driver/:id/active-ride
+func AdminGetDriverActiveRide(c *gin.Context) {
+	adminActiveRide(c, `r."driverId"=$1`)
+}
+
+// adminActiveRide shows support the party's current ride as it stands: its status, where the
+// driver and rider are, what they've been sent and whether it's paid.
+func adminActiveRide(c *gin.Context, owner string) {
+	ctx := adminContext(c)
+	var rideID, status, vehicleType, paymentMode, paymentStatus string
+	var charge, waitingCharge float64
+	var pickup, dropoff activeRidePoint
+	var rider activeRideParty
+	var driverID, driverName, driverPhone, vehicleNumber *string
+	var createdAt time.Time
+	var acceptedAt, arrivedAt, startedAt *time.Time
+	err := db.Pool.QueryRow(ctx,
+		`SELECT r.id, r.status, COALESCE(r."vehicleType", ''), r.charge, r."waitingCharge",
+		 COALESCE(r."paymentMode", ''), COALESCE(r."paymentStatus", 'Pending'),
+		 r."currentLocationName", r."originLat", r."originLng", r."destinationLocationName", r."destinationLat", r."destinationLng",
+		 r."createdAt", r."acceptedAt", r."arrivedAt", r."startedAt",
+		 u.id, u.name, u.phone_number, d.id, d.name, d.phone_number, COALESCE(d.registration_number, '')
+		 FROM rides r
+		 JOIN "user" u ON u.id=r."userId"
+		 LEFT JOIN driver d ON d.id=r."driverId"
+		 WHERE `+owner+` AND r.status IN ('Requested', 'Accepted', 'Arriving', 'InProgress')
+		 ORDER BY r."createdAt" DESC LIMIT 1`, c.Param("id")).
+		Scan(&rideID, &status, &vehicleType, &charge, &waitingCharge, &paymentMode, &paymentStatus,
+			&pickup.Name, &pickup.Lat, &pickup.Lng, &dropoff.Name, &dropoff.Lat, &dropoff.Lng,
+			&createdAt, &acceptedAt, &arrivedAt, &startedAt,
+			&rider.ID, &rider.Name, &rider.Phone, &driverID, &driverName, &driverPhone, &vehicleNumber)
+	if err != nil {
+		utils.RespondErrorCode(c, http.StatusNotFound, utils.CodeRideNotFound, "No active ride", err)
+		return
+	}
+
+	// Riders don't stream their position: before pickup they're at the pickup point, and once the
+	// trip starts they're wherever the car is
+	rider.Location = &activeRidePoint{Lat: pickup.Lat, Lng: pickup.Lng, Source: "pickup"}
+	var driver *activeRideParty
+	if driverID != nil {
+		driver = &activeRideParty{ID: *driverID, Name: *driverName, Phone: *driverPhone, Vehicle: *vehicleNumber}
+		if loc, err := stores.GetDriverLocation(ctx, *driverID); err == nil {
+			driver.Location = &activeRidePoint{Lat: &loc.Latitude, Lng: &loc.Longitude, Source: "live"}
+			if status == "InProgress" {
+				rider.Location = &activeRidePoint{Lat: &loc.Latitude, Lng: &loc.Longitude, Source: "driver"}
+			}
+		}
+	}
+
+	payment := gin.H{"mode": paymentMode, "status": paymentStatus, "charge": charge, "waitingCharge": waitingCharge}
+	if p, err := repos.Payments.ForRide(ctx, rideID); err == nil {
+		payment["payment"] = p
+	}
+
+	utils.RespondSuccess(c, http.StatusOK, "Active ride", gin.H{
+		"ride": gin.H{
+			"id":          rideID,
+			"status":      status,
+			"vehicleType": vehicleType,
+			"pickup":      pickup,
+			"dropoff":     dropoff,
+			"createdAt":   createdAt,
+			"acceptedAt":  acceptedAt,
+			"arrivedAt":   arrivedAt,
+			"startedAt":   startedAt,
+		},
+		"rider":    rider,
+		"driver":   driver,
+		"messages": activeRideMessages(ctx, rideID),
+		"timeline": loadRideEvents(ctx, rideID),
+		"payment":  payment,
+	})
+}
+
+// activeRideMessages is what the ride has sent the rider and driver, newest first.
+func activeRideMessages(ctx context.Context, rideID string) []activeRideMessage {
+	messages := []activeRideMessage{}
+	rows, err := db.Pool.Query(ctx,
+		`SELECT "recipientType", COALESCE(title, ''), COALESCE(body, ''), status, "createdAt" FROM notifications_outbox
+		 WHERE "rideId"=$1 AND channel='push' ORDER BY "createdAt" DESC LIMIT $2`, rideID, activeRideMessageLimit)
+	if err != nil {
+		return messages
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var m activeRideMessage
+		if rows.Scan(&m.To, &m.Title, &m.Body, &m.Status, &m.CreatedAt) == nil {
+			messages = append(messages, m)
+		}
+	}
+	return messages
+}
@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"ridewave/db"
+	"ridewave/stores"
+	"ridewave/utils"
+)
+
+// ══════════════════════════════════════════════════
+// Admin: Analytics — rider retention and the booking funnel
+// ══════════════════════════════════════════════════
+//
+// Cohorts group riders by the week they signed up and count how many complete a ride in each
+// week after. The funnel follows priced routes: an estimate is one routeId, which becomes a ride
+// when booked, then is accepted and completed. Rides booked without an estimate (scheduled
+// dispatches, rebooks) aren't in the funnel but are in the timing percentiles.
+
+// recordRideEstimate logs a priced route for the funnel. It runs in the background so the
+// estimate isn't slowed down, and a lost row only costs the funnel one estimate.
+func recordRideEstimate(userID, routeID string, route *stores.CachedRoute) {
+	utils.SafeGo(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if _, err := db.Pool.Exec(ctx,
+			`INSERT INTO ride_estimates (id, "userId", "vehicleType", fare) VALUES ($1, $2, $3, $4) ON CONFLICT (id) DO NOTHING`,
+			routeID, userID, route.VehicleType, route.Fare); err != nil {
+			utils.Logger.Warn("Failed to record ride estimate", zap.String("routeId", routeID), zap.Error(err))
+		}
+	})
+}
+
+type cohortWeek struct {
+	Week             int     `json:"week"` // weeks after signup, 0 = the signup week
+	Riders           int     `json:"riders"`
+	RetentionPercent float64 `json:"retentionPercent"`
+}
+
+type riderCohort struct {
+	Week      string       `json:"week"` // Monday the cohort signed up in
+	Signups   int          `json:"signups"`
+	Retention []cohortWeek `json:"retention"`
+}
+
+// GET /api/v1/admin/analytics/cohorts?weeks=8
+func AdminRiderCohorts(c *gin.Context) {
+	weeks, _ := strconv.Atoi(c.DefaultQuery("weeks", "8"))
+	if weeks < 1 || weeks > 52 {
+		weeks = 8
+	}
+	ctx := adminContext(c)
+
+	rows, err := db.Pool.Query(ctx,
+		`SELECT date_trunc('week', "createdAt") AS week, COUNT(*) FROM "user"
+		 WHERE "createdAt" >= date_trunc('week', NOW()) - make_interval(weeks => $1 - 1)
+		 GROUP BY week ORDER BY week`, weeks)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to load cohorts", err)
+		return
+	}
+	var cohorts []riderCohort
+	index := map[int64]int{} // cohort week (unix seconds) → position
+	thisWeek := time.Now()
+	for rows.Next() {
+		var week time.Time
+		var signups int
+		if rows.Scan(&week, &signups) != nil {
+			continue
+		}
+		index[week.Unix()] = len(cohorts)
+		// One entry per week the cohort has been around for, so a week nobody rode shows as 0
+		elapsed := int(thisWeek.Sub(week).Hours() / (24 * 7))
+		retention := make([]cohortWeek, elapsed+1)
+		for i := range retention {
+			retention[i].Week = i
+		}
+		cohorts = append(cohorts, riderCohort{Week: week.Format("2006-01-02"), Signups: signups, Retention: retention})
+	}
+	rows.Close()
+
+	// Week offsets are rounded: week starts can be an hour apart across a DST change
+	rows, err = db.Pool.Query(ctx,
+		`SELECT date_trunc('week', u."createdAt") AS week,
+		 ROUND(EXTRACT(EPOCH FROM date_trunc('week', r."createdAt") - date_trunc('week', u."createdAt")) / 604800)::int AS n,
+		 COUNT(DISTINCT u.id)
+		 FROM "user" u JOIN rides r ON r."userId"=u.id AND r.status='Completed'
+		 WHERE u."createdAt" >= date_trunc('week', NOW()) - make_interval(weeks => $1 - 1)
+		 GROUP BY 1, 2`, weeks)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to load cohorts", err)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var week time.Time
+		var n, riders int
+		if rows.Scan(&week, &n, &riders) != nil {
+			continue
+		}
+		i, ok := index[week.Unix()]
+		if !ok || n < 0 || n >= len(cohorts[i].Retention) {
+			continue
+		}
+		cw := &cohorts[i].Retention[n]
+		cw.Riders = riders
+		if pct := percentOf(riders, cohorts[i].Signups); pct != nil {
+			cw.RetentionPercent = *pct
+		}
+	}
+	if cohorts == nil {
+		cohorts = []riderCohort{}
+	}
+	utils.RespondSuccess(c, http.StatusOK, "Rider cohorts", gin.H{"weeks": weeks, "cohorts": cohorts})
+}
+
+type funnelStep struct {
+	Step              string   `json:"step"` // estimate | create | accepted | completed
+	Count             int      `json:"count"`
+	ConversionPercent *float64 `json:"conversionPercent"` // from the step before; nil for the first or when it had none
+	OverallPercent    *float64 `json:"overallPercent"`    // from estimates
+}
+
+// GET /api/v1/admin/analytics/funnel?days=30
+func AdminBookingFunnel(c *gin.Context) {
+	days, _ := strconv.Atoi(c.DefaultQuery("days", "30"))
+	if days < 1 || days > 365 {
+		days = 30
+	}
+	ctx := adminContext(c)
+	since := time.Now().AddDate(0, 0, -days)
+
+	// A route booked twice (a retried create without an Idempotency-Key) counts once, as its last ride
+	var counts [4]int
+	err := db.Pool.QueryRow(ctx,
+		`SELECT COUNT(*), COUNT(r.id), COUNT(r."acceptedAt"), COUNT(*) FILTER (WHERE r.status='Completed')
+		 FROM ride_estimates e
+		 LEFT JOIN LATERAL (
+			SELECT id, "acceptedAt", status FROM rides WHERE "routeId"=e.id ORDER BY "createdAt" DESC LIMIT 1
+		 ) r ON TRUE
+		 WHERE e."createdAt" >= $1`, since).Scan(&counts[0], &counts[1], &counts[2], &counts[3])
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to load booking funnel", err)
+		return
+	}
+	steps := make([]funnelStep, len(counts))
+	for i, name := range []string{"estimate", "create", "accepted", "completed"} {
+		steps[i] = funnelStep{Step: name, Count: counts[i]}
+		if i > 0 {
+			steps[i].ConversionPercent = percentOf(counts[i], counts[i-1])
+		}
+		steps[i].OverallPercent = percentOf(counts[i], counts[0])
+	}
+
+	timeToAccept, err := durationPercentiles(ctx, `"acceptedAt" - "createdAt"`, `"acceptedAt" IS NOT NULL`, since)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to load time to accept", err)
+		return
+	}
+	timeToPickup, err := durationPercentiles(ctx, `"arrivedAt" - "acceptedAt"`, `"arrivedAt" IS NOT NULL AND "acceptedAt" IS NOT NULL`, since)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to load time to pickup", err)
+		return
+	}
+
+	utils.RespondSuccess(c, http.StatusOK, "Booking funnel", gin.H{
+		"days":         days,
+		"from":         since,
+		"funnel":       steps,
+		"timeToAccept": timeToAccept, // request to a driver accepting
+		"timeToPickup": timeToPickup, // accepting to arriving at the pickup
+	})
+}
+
+// durationPercentiles summarises interval (an expression over rides) for rides requested since
+// `since` that match cond, in seconds. The percentiles are nil when there are no such rides.
+func durationPercentiles(ctx context.Context, interval, cond string, since time.Time) (gin.H, error) {
+	var rides int
+	var avg, p50, p90, p95 *float64
+	err := db.Pool.QueryRow(ctx,
+		`SELECT COUNT(*), AVG(s), percentile_cont(0.5) WITHIN GROUP (ORDER BY s),
+		 percentile_cont(0.9) WITHIN GROUP (ORDER BY s), percentile_cont(0.95) WITHIN GROUP (ORDER BY s)
+		 FROM (SELECT EXTRACT(EPOCH FROM `+interval+`)::float8 AS s FROM rides WHERE "createdAt" >= $1 AND `+cond+`) t`,
+		since).Scan(&rides, &avg, &p50, &p90, &p95)
+	if err != nil {
+		return nil, err
+	}
+	round := func(v *float64) *float64 {
+		if v == nil {
+			return nil
+		}
+		r := math.Round(*v)
+		return &r
+	}
+	return gin.H{
+		"rides":          rides,
+		"averageSeconds": round(avg),
+		"p50Seconds":     round(p50),
+		"p90Seconds":     round(p90),
+		"p95Seconds":     round(p95),
+	}, nil
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"ridewave/db"
+	"ridewave/utils"
+)
+
+// ══════════════════════════════════════════════════
+// Admin: Audit Log — written by middleware.AdminAudit
+// ══════════════════════════════════════════════════
+
+type adminAuditLog struct {
+	ID         string          `json:"id"`
+	AdminID    string          `json:"adminId"`
+	AdminEmail string          `json:"adminEmail"`
+	Method     string          `json:"method"`
+	Path       string          `json:"path"`
+	Route      string          `json:"route"`
+	EntityType string          `json:"entityType"`
+	EntityID   *string         `json:"entityId"`
+	Request    json.RawMessage `json:"request"`
+	Changes    json.RawMessage `json:"changes"`
+	Status     int             `json:"status"`
+	IP         *string         `json:"ip"`
+	RequestID  *string         `json:"requestId"`
+	CreatedAt  time.Time       `json:"createdAt"`
+}
+
+const adminAuditSelectCols = `id, "adminId", "adminEmail", method, path, route, "entityType", "entityId",
+	COALESCE(request, 'null'::jsonb), COALESCE(changes, 'null'::jsonb), status, ip, "requestId", "createdAt"`
+
+func scanAdminAuditLog(scanner interface{ Scan(dest ...any) error }, l *adminAuditLog) error {
+	return scanner.Scan(&l.ID, &l.AdminID, &l.AdminEmail, &l.Method, &l.Path, &l.Route, &l.EntityType, &l.EntityID,
+		&l.Request, &l.Changes, &l.Status, &l.IP, &l.RequestID, &l.CreatedAt)
+}
+
+// GET /api/v1/admin/audit-logs?adminId=&entityType=&entityId=&from=YYYY-MM-DD&to=YYYY-MM-DD&failed=true
+func AdminGetAuditLogs(c *gin.Context) {
+	pg, err := utils.ParsePagination(c)
+	if err != nil {
+		utils.RespondErrorCode(c, http.StatusBadRequest, utils.CodeInvalidCursor, "Invalid cursor", err)
+		return
+	}
+
+	conds := []string{}
+	args := []interface{}{}
+	add := func(cond string, arg any) {
+		args = append(args, arg)
+		conds = append(conds, cond+"$"+strconv.Itoa(len(args)))
+	}
+	for _, f := range []struct{ param, column string }{
+		{"adminId", `"adminId"`}, {"adminEmail", `"adminEmail"`}, {"entityType", `"entityType"`}, {"entityId", `"entityId"`},
+	} {
+		if v := c.Query(f.param); v != "" {
+			add(f.column+"=", v)
+		}
+	}
+	if from := c.Query("from"); from != "" {
+		t, err := time.Parse("2006-01-02", from)
+		if err != nil {
+			utils.RespondError(c, http.StatusBadRequest, "from must be YYYY-MM-DD", err)
+			return
+		}
+		add(`"createdAt">=`, t)
+	}
+	if to := c.Query("to"); to != "" {
+		t, err := time.Parse("2006-01-02", to)
+		if err != nil {
+			utils.RespondError(c, http.StatusBadRequest, "to must be YYYY-MM-DD", err)
+			return
+		}
+		add(`"createdAt"<`, t.AddDate(0, 0, 1))
+	}
+	if c.Query("failed") == "true" {
+		conds = append(conds, "status>=400")
+	}
+
+	var total int
+	if !pg.UseCursor {
+		db.Pool.QueryRow(adminContext(c), `SELECT COUNT(*) FROM admin_audit_logs`+utils.WhereClause(conds), args...).Scan(&total)
+	}
+
+	conds, args = pg.Keyset(conds, args, `"createdAt"`, "id")
+	tail, args := pg.Tail(args, `"createdAt"`, "id")
+	rows, err := db.Pool.Query(adminContext(c),
+		`SELECT `+adminAuditSelectCols+` FROM admin_audit_logs`+utils.WhereClause(conds)+tail, args...)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to fetch audit logs", err)
+		return
+	}
+	defer rows.Close()
+
+	logs := []adminAuditLog{}
+	for rows.Next() {
+		var l adminAuditLog
+		if scanAdminAuditLog(rows, &l) == nil {
+			logs = append(logs, l)
+		}
+	}
+
+	logs, resp := utils.Paginate(pg, logs, total, func(l adminAuditLog) (time.Time, string) { return l.CreatedAt, l.ID })
+	resp["logs"] = logs
+	utils.RespondSuccess(c, http.StatusOK, "Admin audit log", resp)
+}
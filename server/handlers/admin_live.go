@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+	"ridewave/events"
+	"ridewave/stores"
+	"ridewave/utils"
+)
+
+// ══════════════════════════════════════════════════
+// Live Admin Feed — ride and SOS events for the /admin socket namespace
+// ══════════════════════════════════════════════════
+
+// adminLiveRideEvents are the ride events worth showing live; offers, declines and ratings are too noisy.
+var adminLiveRideEvents = map[string]bool{
+	events.RideAccepted:  true,
+	events.DriverArrived: true,
+	events.RideStarted:   true,
+	events.RideCompleted: true,
+	events.RideCancelled: true,
+	events.RidePaid:      true,
+}
+
+func init() {
+	events.Subscribe(forwardRideEventToAdmins)
+}
+
+// forwardRideEventToAdmins relays ride events over Redis so admins on every instance see them.
+func forwardRideEventToAdmins(e events.Event) {
+	event := "rideStatus"
+	switch {
+	case e.Type == events.RideRequested:
+		event = "rideRequested"
+	case !adminLiveRideEvents[e.Type]:
+		return
+	}
+	publishAdminOps(event, map[string]any{
+		"rideId":    e.RideID,
+		"type":      e.Type,
+		"actorType": e.ActorType,
+		"actorId":   e.ActorID,
+		"data":      e.Data,
+	})
+}
+
+func publishAdminOps(event string, payload map[string]any) {
+	err := stores.PublishAdminOpsEvent(context.Background(), stores.AdminOpsEvent{Event: event, Payload: payload})
+	if err != nil {
+		utils.Logger.Warn("Failed to publish admin live event", zap.String("event", event), zap.Error(err))
+	}
+}
@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"ridewave/db"
+	"ridewave/utils"
+)
+
+// ══════════════════════════════════════════════════
+// Analytics Stats — pre-aggregated daily and hourly totals
+// ══════════════════════════════════════════════════
+//
+// The dashboard and analytics endpoints read daily_stats, daily_vehicle_stats and hourly_stats
+// instead of counting rides, users and drivers on every request. The worker recomputes the last
+// few days from the source tables; older days only change when a refresh is asked for. What's
+// happening right now (open rides, online drivers) is still counted live.
+
+const analyticsStatsLockKey = "analytics:stats:lock"
+
+// analyticsStatsInterval is how often the stats are refreshed (STATS_REFRESH_MINUTES, default 5).
+func analyticsStatsInterval() time.Duration {
+	if val, err := strconv.Atoi(os.Getenv("STATS_REFRESH_MINUTES")); err == nil && val > 0 {
+		return time.Duration(val) * time.Minute
+	}
+	return 5 * time.Minute
+}
+
+// analyticsStatsDays is how many days, today included, each scheduled refresh recomputes
+// (STATS_REFRESH_DAYS, default 3) — long enough to pick up rides that finish after midnight.
+func analyticsStatsDays() int {
+	if val, err := strconv.Atoi(os.Getenv("STATS_REFRESH_DAYS")); err == nil && val > 0 {
+		return val
+	}
+	return 3
+}
+
+// StartAnalyticsStatsWorker refreshes the stats tables on start and every STATS_REFRESH_MINUTES.
+// A Redis lock makes sure only one instance refreshes at a time.
+func StartAnalyticsStatsWorker(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(analyticsStatsInterval())
+		defer ticker.Stop()
+
+		run := func() {
+			ok, err := db.RedisClient.SetNX(ctx, analyticsStatsLockKey, "1", analyticsStatsInterval()/2).Result()
+			if err != nil || !ok {
+				return
+			}
+			if err := refreshAnalyticsStats(ctx, analyticsStatsDays()); err != nil {
+				utils.Logger.Error("Analytics stats refresh failed", zap.Error(err))
+			}
+		}
+		run()
+		for {
+			select {
+			case <-ticker.C:
+				run()
+			case <-ctx.Done():
+				utils.Logger.Info("Analytics Stats Worker shutting down...")
+				return
+			}
+		}
+	}()
+}
+
+// refreshAnalyticsStats recomputes the last `days` days of stats, or every day there is data for
+// when days is 0 or the tables have never been filled. Readers see the old rows until it commits.
+func refreshAnalyticsStats(ctx context.Context, days int) error {
+	start := time.Now()
+	var filled bool
+	if err := db.Pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM daily_stats)`).Scan(&filled); err != nil {
+		return err
+	}
+	var from time.Time
+	if filled && days > 0 {
+		if err := db.Pool.QueryRow(ctx, `SELECT CURRENT_DATE - $1::int + 1`, days).Scan(&from); err != nil {
+			return err
+		}
+	} else {
+		var earliest *time.Time
+		err := db.Pool.QueryRow(ctx,
+			`SELECT LEAST((SELECT MIN("createdAt") FROM rides), (SELECT MIN("createdAt") FROM "user"),
+			 (SELECT MIN("createdAt") FROM driver))::date`).Scan(&earliest)
+		if err != nil {
+			return err
+		}
+		if earliest == nil {
+			earliest = &start
+		}
+		from = *earliest
+	}
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	for _, table := range []string{"daily_stats", "daily_vehicle_stats"} {
+		if _, err := tx.Exec(ctx, `DELETE FROM `+table+` WHERE day >= $1::date`, from); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM hourly_stats WHERE hour >= $1::date`, from); err != nil {
+		return err
+	}
+
+	// Every day in the range gets a row, so a day without rides reads as zeros rather than missing
+	if _, err := tx.Exec(ctx,
+		`INSERT INTO daily_stats (day, rides, completed, cancelled, revenue, tips, "distanceKm", "ratingSum", "ratingCount", "newUsers", "newDrivers")
+		 SELECT d.day, COALESCE(r.rides, 0), COALESCE(r.completed, 0), COALESCE(r.cancelled, 0), COALESCE(r.revenue, 0),
+		 COALESCE(r.tips, 0), COALESCE(r.km, 0), COALESCE(r.rating_sum, 0), COALESCE(r.rating_count, 0),
+		 COALESCE(u.n, 0), COALESCE(dr.n, 0)
+		 FROM generate_series($1::date, CURRENT_DATE, '1 day') AS d(day)
+		 LEFT JOIN (
+			SELECT DATE("createdAt") AS day, COUNT(*) AS rides,
+			COUNT(*) FILTER (WHERE status='Completed') AS completed,
+			COUNT(*) FILTER (WHERE status='Cancelled') AS cancelled,
+			SUM(charge) FILTER (WHERE status='Completed') AS revenue,
+			SUM(tips) AS tips,
+			SUM(CAST(distance AS DOUBLE PRECISION)) FILTER (WHERE status='Completed') / 1000.0 AS km,
+			SUM(rating) AS rating_sum, COUNT(rating) AS rating_count
+			FROM rides WHERE "createdAt" >= $1::date GROUP BY 1
+		 ) r ON r.day = d.day
+		 LEFT JOIN (SELECT DATE("createdAt") AS day, COUNT(*) AS n FROM "user" WHERE "createdAt" >= $1::date GROUP BY 1) u ON u.day = d.day
+		 LEFT JOIN (SELECT DATE("createdAt") AS day, COUNT(*) AS n FROM driver WHERE "createdAt" >= $1::date GROUP BY 1) dr ON dr.day = d.day`,
+		from); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx,
+		`INSERT INTO daily_vehicle_stats (day, "vehicleType", rides, completed, revenue)
+		 SELECT DATE("createdAt"), COALESCE("vehicleType", 'Unknown'), COUNT(*),
+		 COUNT(*) FILTER (WHERE status='Completed'), COALESCE(SUM(charge) FILTER (WHERE status='Completed'), 0)
+		 FROM rides WHERE "createdAt" >= $1::date GROUP BY 1, 2`, from); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx,
+		`INSERT INTO hourly_stats (hour, rides, completed, cancelled, revenue)
+		 SELECT date_trunc('hour', "createdAt"), COUNT(*),
+		 COUNT(*) FILTER (WHERE status='Completed'), COUNT(*) FILTER (WHERE status='Cancelled'),
+		 COALESCE(SUM(charge) FILTER (WHERE status='Completed'), 0)
+		 FROM rides WHERE "createdAt" >= $1::date GROUP BY 1`, from); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+	utils.Logger.Info("Analytics stats refreshed",
+		zap.Time("from", from), zap.Duration("took", time.Since(start)))
+	return nil
+}
+
+// statsTotals adds up daily_stats rows.
+type statsTotals struct {
+	Rides       int
+	Completed   int
+	Cancelled   int
+	Revenue     float64
+	Tips        float64
+	DistanceKm  float64
+	RatingSum   float64
+	RatingCount int
+	NewUsers    int
+	NewDrivers  int
+}
+
+// sumDailyStats totals the last `days` days, today included, or every day when days is 0.
+func sumDailyStats(ctx context.Context, days int) (statsTotals, error) {
+	var t statsTotals
+	err := db.Pool.QueryRow(ctx,
+		`SELECT COALESCE(SUM(rides), 0), COALESCE(SUM(completed), 0), COALESCE(SUM(cancelled), 0),
+		 COALESCE(SUM(revenue), 0), COALESCE(SUM(tips), 0), COALESCE(SUM("distanceKm"), 0),
+		 COALESCE(SUM("ratingSum"), 0), COALESCE(SUM("ratingCount"), 0),
+		 COALESCE(SUM("newUsers"), 0), COALESCE(SUM("newDrivers"), 0)
+		 FROM daily_stats WHERE $1 = 0 OR day > CURRENT_DATE - $1::int`, days).
+		Scan(&t.Rides, &t.Completed, &t.Cancelled, &t.Revenue, &t.Tips, &t.DistanceKm,
+			&t.RatingSum, &t.RatingCount, &t.NewUsers, &t.NewDrivers)
+	return t, err
+}
+
+// statsRefreshedAt is when the stats were last refreshed, nil before the first refresh.
+func statsRefreshedAt(ctx context.Context) *time.Time {
+	var at *time.Time
+	db.Pool.QueryRow(ctx, `SELECT MAX("refreshedAt") FROM daily_stats`).Scan(&at)
+	return at
+}
+
+// liveCounts are the figures the dashboard counts on each request because they change by the second.
+type liveCounts struct {
+	RequestedRides  int
+	OngoingRides    int
+	InProgressRides int
+	ActiveDrivers   int
+	PendingDrivers  int
+	OnlineDrivers   int
+	InactiveUsers   int
+}
+
+func loadLiveCounts(ctx context.Context) (liveCounts, error) {
+	var l liveCounts
+	err := db.Pool.QueryRow(ctx,
+		`SELECT
+		 (SELECT COUNT(*) FROM rides WHERE status='Requested'),
+		 (SELECT COUNT(*) FROM rides WHERE status IN ('Accepted','Arriving','InProgress')),
+		 (SELECT COUNT(*) FROM rides WHERE status='InProgress'),
+		 (SELECT COUNT(*) FROM driver WHERE status='active'),
+		 (SELECT COUNT(*) FROM driver WHERE status='pending'),
+		 (SELECT COUNT(*) FROM driver WHERE "isOnline"=TRUE AND status='active'),
+		 (SELECT COUNT(*) FROM "user" WHERE status <> 'active')`).
+		Scan(&l.RequestedRides, &l.OngoingRides, &l.InProgressRides,
+			&l.ActiveDrivers, &l.PendingDrivers, &l.OnlineDrivers, &l.InactiveUsers)
+	return l, err
+}
+
+// POST /api/v1/admin/analytics/refresh?days=30 — recompute the stats now; without days every
+// day is recomputed, which is what to do after importing or correcting old rides
+func AdminRefreshAnalyticsStats(c *gin.Context) {
+	days, _ := strconv.Atoi(c.DefaultQuery("days", "0"))
+	if days < 0 {
+		utils.RespondError(c, http.StatusBadRequest, "days must be 0 (everything) or more", nil)
+		return
+	}
+	ok, err := db.RedisClient.SetNX(c.Request.Context(), analyticsStatsLockKey, "1", time.Minute).Result()
+	if err != nil {
+		utils.RespondError(c, http.StatusServiceUnavailable, "Redis unavailable", err)
+		return
+	}
+	if !ok {
+		utils.RespondError(c, http.StatusConflict, "A stats refresh ran or is running too recently; try again shortly", nil)
+		return
+	}
+	// A full recompute reads every ride and can outlive the request timeout
+	utils.SafeGo(func() {
+		if err := refreshAnalyticsStats(db.Unscoped(), days); err != nil {
+			utils.Logger.Error("Analytics stats refresh failed", zap.Error(err))
+		}
+	})
+	utils.RespondSuccess(c, http.StatusAccepted, "Analytics stats refresh started", gin.H{"days": days})
+}
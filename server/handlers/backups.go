@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"ridewave/backup"
+	"ridewave/db"
+	"ridewave/utils"
+)
+
+// ══════════════════════════════════════════════════
+// Backups — scheduled encrypted backups & admin status
+// ══════════════════════════════════════════════════
+
+const backupLockKey = "backup:lock"
+
+func backupInterval() time.Duration {
+	if val, err := strconv.Atoi(os.Getenv("BACKUP_INTERVAL_HOURS")); err == nil && val > 0 {
+		return time.Duration(val) * time.Hour
+	}
+	return 24 * time.Hour
+}
+
+// StartBackupWorker takes a Postgres + Redis backup every BACKUP_INTERVAL_HOURS when BACKUP_ENABLED=true.
+// A Redis lock makes sure only one instance backs up per interval.
+func StartBackupWorker(ctx context.Context) {
+	if os.Getenv("BACKUP_ENABLED") != "true" {
+		return
+	}
+	cfg, err := backup.LoadConfig()
+	if err != nil {
+		utils.Logger.Error("Backups disabled: invalid configuration", zap.Error(err))
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(backupInterval())
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				runScheduledBackup(ctx, cfg)
+			case <-ctx.Done():
+				utils.Logger.Info("Backup Worker shutting down...")
+				return
+			}
+		}
+	}()
+}
+
+func runScheduledBackup(ctx context.Context, cfg *backup.Config) {
+	ok, err := db.RedisClient.SetNX(ctx, backupLockKey, "1", backupInterval()/2).Result()
+	if err != nil || !ok {
+		return
+	}
+	for _, run := range backup.RunAll(ctx, cfg, db.RedisClient) {
+		if run.Status != "success" {
+			utils.Logger.Error("Backup failed", zap.String("kind", run.Kind), zap.String("error", run.Error))
+			continue
+		}
+		utils.Logger.Info("Backup shipped", zap.String("kind", run.Kind), zap.String("object", run.Manifest.Object),
+			zap.Int64("bytes", run.Manifest.EncryptedSize))
+	}
+}
+
+// GET /api/v1/admin/backups
+func AdminGetBackupStatus(c *gin.Context) {
+	type backupRun struct {
+		ID         string    `json:"id"`
+		Kind       string    `json:"kind"`
+		Region     string    `json:"region"`
+		Status     string    `json:"status"`
+		Object     string    `json:"object"`
+		SizeBytes  int64     `json:"sizeBytes"`
+		SHA256     string    `json:"sha256"`
+		Error      string    `json:"error"`
+		StartedAt  time.Time `json:"startedAt"`
+		FinishedAt time.Time `json:"finishedAt"`
+	}
+
+	rows, err := db.Pool.Query(adminContext(c),
+		`SELECT id, kind, region, status, object, "sizeBytes", sha256, error, "startedAt", "finishedAt"
+		 FROM backup_runs ORDER BY "startedAt" DESC LIMIT 30`)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to fetch backup runs", err)
+		return
+	}
+	defer rows.Close()
+
+	runs := []backupRun{}
+	for rows.Next() {
+		var r backupRun
+		if rows.Scan(&r.ID, &r.Kind, &r.Region, &r.Status, &r.Object, &r.SizeBytes, &r.SHA256, &r.Error, &r.StartedAt, &r.FinishedAt) == nil {
+			runs = append(runs, r)
+		}
+	}
+
+	// A kind is healthy if it has succeeded within two intervals
+	interval := backupInterval()
+	kinds := gin.H{}
+	healthy := true
+	for _, kind := range []string{backup.KindPostgres, backup.KindRedis} {
+		var lastSuccess *time.Time
+		db.Pool.QueryRow(adminContext(c),
+			`SELECT MAX("finishedAt") FROM backup_runs WHERE kind=$1 AND status='success'`, kind).Scan(&lastSuccess)
+		ok := lastSuccess != nil && time.Since(*lastSuccess) < 2*interval
+		healthy = healthy && ok
+		kinds[kind] = gin.H{"lastSuccessAt": lastSuccess, "healthy": ok}
+	}
+
+	_, cfgErr := backup.LoadConfig()
+	configError := ""
+	if cfgErr != nil {
+		configError = cfgErr.Error()
+	}
+
+	utils.RespondSuccess(c, http.StatusOK, "Backup status", gin.H{
+		"enabled":       os.Getenv("BACKUP_ENABLED") == "true",
+		"configError":   configError,
+		"intervalHours": interval.Hours(),
+		"healthy":       healthy,
+		"kinds":         kinds,
+		"runs":          runs,
+	})
+}
@@ -0,0 +1,618 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+	"ridewave/db"
+	"ridewave/events"
+	"ridewave/models"
+	"ridewave/stores"
+	"ridewave/utils"
+)
+
+// ══════════════════════════════════════════════════
+// Ride Bidding — riders name a fare in low-density zones, nearby drivers accept or counter
+// ══════════════════════════════════════════════════
+
+const (
+	bidKindAccept  = "accept"  // driver takes the rider's suggested fare
+	bidKindCounter = "counter" // driver names their own fare within the bounds
+)
+
+type biddingConfig struct {
+	Zones      map[string]bool // lower-cased service zone names
+	MinPercent float64         // lowest fare, as % of the estimate
+	MaxPercent float64         // highest fare, as % of the estimate
+	Timeout    time.Duration   // how long drivers can bid before the request expires
+	RadiusKm   float64         // drivers this close to the pickup are invited
+}
+
+func loadBiddingConfig() biddingConfig {
+	cfg := biddingConfig{
+		Zones:      map[string]bool{},
+		MinPercent: 80,
+		MaxPercent: 150,
+		Timeout:    3 * time.Minute,
+		RadiusKm:   10,
+	}
+	for _, zone := range strings.Split(os.Getenv("BIDDING_ZONES"), ",") {
+		if zone = strings.TrimSpace(zone); zone != "" {
+			cfg.Zones[strings.ToLower(zone)] = true
+		}
+	}
+	if val, err := strconv.ParseFloat(os.Getenv("BID_FARE_MIN_PERCENT"), 64); err == nil && val > 0 {
+		cfg.MinPercent = val
+	}
+	if val, err := strconv.ParseFloat(os.Getenv("BID_FARE_MAX_PERCENT"), 64); err == nil && val >= cfg.MinPercent {
+		cfg.MaxPercent = val
+	}
+	if val, err := strconv.Atoi(os.Getenv("BID_TIMEOUT_SECONDS")); err == nil && val > 0 {
+		cfg.Timeout = time.Duration(val) * time.Second
+	}
+	if val, err := strconv.ParseFloat(os.Getenv("BID_RADIUS_KM"), 64); err == nil && val > 0 {
+		cfg.RadiusKm = val
+	}
+	return cfg
+}
+
+// zoneAt returns the bidding zone the pickup point is in, or "" if bidding isn't offered there.
+func (cfg biddingConfig) zoneAt(lat, lng float64) string {
+	zone := zoneForPoint(lat, lng)
+	if zone == "" || !cfg.Zones[strings.ToLower(zone)] {
+		return ""
+	}
+	return zone
+}
+
+// fareBounds is the range riders may suggest and drivers may counter within.
+func (cfg biddingConfig) fareBounds(estimate float64) (float64, float64) {
+	return math.Ceil(estimate * cfg.MinPercent / 100), math.Floor(estimate * cfg.MaxPercent / 100)
+}
+
+type fareOffer struct {
+	ID              string             `json:"id"`
+	UserID          string             `json:"userId"`
+	Zone            string             `json:"zone"`
+	RouteID         string             `json:"-"`
+	Route           stores.CachedRoute `json:"-"`
+	PaymentMode     string             `json:"paymentMode"`
+	EstimatedFare   float64            `json:"estimatedFare"`
+	SuggestedFare   float64            `json:"suggestedFare"`
+	MinFare         float64            `json:"minFare"`
+	MaxFare         float64            `json:"maxFare"`
+	NotifiedDrivers []string           `json:"-"`
+	Status          string             `json:"status"`
+	RideID          *string            `json:"rideId"`
+	ExpiresAt       time.Time          `json:"expiresAt"`
+	CreatedAt       time.Time          `json:"createdAt"`
+}
+
+const fareOfferSelectCols = `id, "userId", zone, "routeId", route, "paymentMode", "estimatedFare", "suggestedFare",
+	"minFare", "maxFare", "notifiedDrivers", CASE WHEN status='open' AND "expiresAt" <= NOW() THEN 'expired' ELSE status END,
+	"rideId", "expiresAt", "createdAt"`
+
+func scanFareOffer(scanner interface{ Scan(dest ...any) error }, o *fareOffer) error {
+	var route []byte
+	if err := scanner.Scan(&o.ID, &o.UserID, &o.Zone, &o.RouteID, &route, &o.PaymentMode, &o.EstimatedFare, &o.SuggestedFare,
+		&o.MinFare, &o.MaxFare, &o.NotifiedDrivers, &o.Status, &o.RideID, &o.ExpiresAt, &o.CreatedAt); err != nil {
+		return err
+	}
+	return json.Unmarshal(route, &o.Route)
+}
+
+// summary is what drivers see of a request: the trip, not the rider.
+func (o *fareOffer) summary() map[string]any {
+	return map[string]any{
+		"offerId":         o.ID,
+		"originName":      o.Route.OriginName,
+		"destinationName": o.Route.DestinationName,
+		"pickupLat":       o.Route.OriginLat,
+		"pickupLng":       o.Route.OriginLng,
+		"distance":        o.Route.Distance,
+		"duration":        o.Route.Duration,
+		"vehicleType":     o.Route.VehicleType,
+		"suggestedFare":   o.SuggestedFare,
+		"minFare":         o.MinFare,
+		"maxFare":         o.MaxFare,
+		"expiresAt":       o.ExpiresAt,
+	}
+}
+
+type fareBid struct {
+	ID                 string    `json:"id"`
+	DriverID           string    `json:"driverId"`
+	DriverName         string    `json:"driverName"`
+	DriverRating       float64   `json:"driverRating"`
+	VehicleType        string    `json:"vehicleType"`
+	VehicleColor       string    `json:"vehicleColor"`
+	RegistrationNumber string    `json:"registrationNumber"`
+	Amount             float64   `json:"amount"`
+	Kind               string    `json:"kind"`
+	Status             string    `json:"status"`
+	UpdatedAt          time.Time `json:"updatedAt"`
+}
+
+func loadFareBids(ctx context.Context, offerID string) ([]fareBid, error) {
+	rows, err := db.Pool.Query(ctx,
+		`SELECT b.id, b."driverId", d.name, d.ratings, d.vehicle_type, COALESCE(d.vehicle_color, ''), d.registration_number,
+		 b.amount, b.kind, b.status, b."updatedAt"
+		 FROM fare_bids b JOIN driver d ON d.id=b."driverId"
+		 WHERE b."offerId"=$1 ORDER BY b.amount, b."updatedAt"`, offerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	bids := []fareBid{}
+	for rows.Next() {
+		var b fareBid
+		if err := rows.Scan(&b.ID, &b.DriverID, &b.DriverName, &b.DriverRating, &b.VehicleType, &b.VehicleColor,
+			&b.RegistrationNumber, &b.Amount, &b.Kind, &b.Status, &b.UpdatedAt); err != nil {
+			return nil, err
+		}
+		bids = append(bids, b)
+	}
+	return bids, rows.Err()
+}
+
+// publishBidEvent relays a bidding update to sockets; failures only cost the live update.
+func publishBidEvent(event stores.BidEvent) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := stores.PublishBidEvent(ctx, event); err != nil {
+		utils.Logger.Warn("Failed to publish bid event", zap.String("event", event.Event), zap.String("offerId", event.OfferID), zap.Error(err))
+	}
+}
+
+// excludeDriver returns ids without driverID.
+func excludeDriver(ids []string, driverID string) []string {
+	out := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if id != driverID {
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
+// ══════════════════════════════════════════════════
+// Rider: Fare Offers
+// ══════════════════════════════════════════════════
+
+// POST /api/v1/user/ride/bid — post an estimated route with a suggested fare to nearby drivers
+func CreateFareOffer(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+	var body struct {
+		RouteID       string  `json:"routeId" binding:"required"`
+		SuggestedFare float64 `json:"suggestedFare" binding:"required"`
+		PaymentMode   string  `json:"paymentMode"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	cached, err := stores.GetPlannedRoute(ctx, body.RouteID)
+	if err != nil {
+		utils.RespondErrorCode(c, http.StatusGone, utils.CodeRouteExpired, "This route has expired. Please get a fresh estimate.", err)
+		return
+	}
+	if cached.VehicleType == poolVehicleType {
+		utils.RespondError(c, http.StatusBadRequest, "Pool rides can't be booked by bidding", nil)
+		return
+	}
+	cfg := loadBiddingConfig()
+	zone := cfg.zoneAt(cached.OriginLat, cached.OriginLng)
+	if zone == "" {
+		utils.RespondError(c, http.StatusBadRequest, "Fare bidding isn't available in this area", nil)
+		return
+	}
+	if ok, reason := checkZoneAccess(ctx, user, cached.OriginLat, cached.OriginLng); !ok {
+		utils.RespondErrorCode(c, http.StatusForbidden, utils.CodeZoneNotServed, reason, nil)
+		return
+	}
+	minFare, maxFare := cfg.fareBounds(cached.Fare)
+	if body.SuggestedFare < minFare || body.SuggestedFare > maxFare {
+		utils.RespondError(c, http.StatusBadRequest,
+			fmt.Sprintf("Suggested fare must be between %s and %s", formatMoney(cached.Currency, minFare), formatMoney(cached.Currency, maxFare)), nil)
+		return
+	}
+
+	var hasOpen bool
+	db.Pool.QueryRow(ctx,
+		`SELECT EXISTS(SELECT 1 FROM fare_offers WHERE "userId"=$1 AND status='open' AND "expiresAt" > NOW())`,
+		user.ID).Scan(&hasOpen)
+	if hasOpen {
+		utils.RespondError(c, http.StatusConflict, "You already have an open fare request", nil)
+		return
+	}
+
+	// Invite online, approved drivers of the vehicle type near the pickup
+	nearby, _ := stores.GetNearbyDrivers(ctx, cached.OriginLat, cached.OriginLng, cfg.RadiusKm)
+	nearbyIDs := make([]string, 0, len(nearby))
+	for _, d := range nearby {
+		nearbyIDs = append(nearbyIDs, d.DriverID)
+	}
+	rows, err := db.Pool.Query(ctx,
+		`SELECT id, COALESCE("notificationToken", '') FROM driver
+		 WHERE id=ANY($1) AND "isOnline"=TRUE AND status='active' AND vehicle_type=$2`,
+		nearbyIDs, cached.VehicleType)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to find nearby drivers", err)
+		return
+	}
+	misses := tripPreferenceMisses(ctx, nearbyIDs, cached)
+	var driverIDs, tokens []string
+	for rows.Next() {
+		var id, token string
+		if rows.Scan(&id, &token) == nil && !misses[id] {
+			driverIDs = append(driverIDs, id)
+			if token != "" {
+				tokens = append(tokens, token)
+			}
+		}
+	}
+	rows.Close()
+	if len(driverIDs) == 0 {
+		utils.RespondErrorCode(c, http.StatusUnprocessableEntity, utils.CodeNoDrivers, "No drivers are available nearby right now. Please try again shortly.", nil)
+		return
+	}
+
+	route, _ := json.Marshal(cached)
+	var offer fareOffer
+	err = scanFareOffer(db.Pool.QueryRow(ctx,
+		`INSERT INTO fare_offers ("userId", zone, "routeId", route, "paymentMode", "estimatedFare", "suggestedFare",
+		 "minFare", "maxFare", "notifiedDrivers", "expiresAt")
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NOW() + make_interval(secs => $11))
+		 RETURNING `+fareOfferSelectCols,
+		user.ID, zone, body.RouteID, route, body.PaymentMode, cached.Fare, body.SuggestedFare,
+		minFare, maxFare, driverIDs, cfg.Timeout.Seconds()), &offer)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to create fare request", err)
+		return
+	}
+
+	summary := offer.summary()
+	publishBidEvent(stores.BidEvent{Event: "bidRequest", OfferID: offer.ID, DriverIDs: driverIDs, Payload: summary})
+	if len(tokens) > 0 {
+		go utils.SendPushToMultiple(tokens, "💬 Fare request nearby",
+			fmt.Sprintf("%s → %s · rider offers %s", cached.OriginName, cached.DestinationName, formatMoney(cached.Currency, body.SuggestedFare)),
+			utils.FCMData{
+				"type":          "bid_request",
+				"offerId":       offer.ID,
+				"suggestedFare": fmt.Sprintf("%.2f", body.SuggestedFare),
+				"minFare":       fmt.Sprintf("%.2f", minFare),
+				"maxFare":       fmt.Sprintf("%.2f", maxFare),
+			})
+	}
+
+	utils.RespondSuccess(c, http.StatusCreated, "Fare request sent to nearby drivers", gin.H{
+		"offer":           offer,
+		"notifiedDrivers": len(driverIDs),
+	})
+}
+
+// GET /api/v1/user/ride/bid/:id — the request and every driver's bid, cheapest first
+func GetFareOffer(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+	var offer fareOffer
+	err := scanFareOffer(db.Pool.QueryRow(c.Request.Context(),
+		`SELECT `+fareOfferSelectCols+` FROM fare_offers WHERE id=$1 AND "userId"=$2`, c.Param("id"), user.ID), &offer)
+	if err != nil {
+		utils.RespondError(c, http.StatusNotFound, "Fare request not found", err)
+		return
+	}
+	bids, err := loadFareBids(c.Request.Context(), offer.ID)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to fetch bids", err)
+		return
+	}
+	utils.RespondSuccess(c, http.StatusOK, "Fare request", gin.H{"offer": offer, "bids": bids})
+}
+
+// POST /api/v1/user/ride/bid/:id/choose — book the ride with the chosen driver at their price
+func ChooseFareBid(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+	var body struct {
+		BidID string `json:"bidId" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid request", err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to book ride", err)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	// Claiming the offer first means two taps can't book two drivers
+	var offer fareOffer
+	err = scanFareOffer(tx.QueryRow(ctx,
+		`UPDATE fare_offers SET status='accepted', "updatedAt"=NOW()
+		 WHERE id=$1 AND "userId"=$2 AND status='open' AND "expiresAt" > NOW()
+		 RETURNING `+fareOfferSelectCols, c.Param("id"), user.ID), &offer)
+	if err == pgx.ErrNoRows {
+		utils.RespondErrorCode(c, http.StatusConflict, utils.CodeRideExpired, "This fare request is no longer open", nil)
+		return
+	}
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to book ride", err)
+		return
+	}
+
+	var driverID string
+	var amount float64
+	err = tx.QueryRow(ctx,
+		`SELECT "driverId", amount FROM fare_bids WHERE id=$1 AND "offerId"=$2`, body.BidID, offer.ID).Scan(&driverID, &amount)
+	if err != nil {
+		utils.RespondError(c, http.StatusNotFound, "Bid not found", err)
+		return
+	}
+
+	var available bool
+	tx.QueryRow(ctx,
+		`SELECT "isOnline" AND status='active'
+		 AND NOT EXISTS(SELECT 1 FROM rides WHERE "driverId"=$1 AND status IN ('Accepted', 'Arriving', 'InProgress'))
+		 FROM driver WHERE id=$1`, driverID).Scan(&available)
+	if !available {
+		utils.RespondError(c, http.StatusConflict, "That driver is no longer available. Please choose another bid.", nil)
+		return
+	}
+
+	route := offer.Route
+	route.Fare = amount
+	rideID, err := repos.Rides.CreateTx(ctx, tx, newRide(user.ID, offer.RouteID, &route, offer.PaymentMode))
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to create ride", err)
+		return
+	}
+	otp := generateRideOTP()
+	_, err = tx.Exec(ctx,
+		`UPDATE rides SET "driverId"=$2, status='Accepted', "acceptedAt"=NOW(), otp=$3, "updatedAt"=NOW() WHERE id=$1`,
+		rideID, driverID, otp)
+	if err == nil {
+		_, err = tx.Exec(ctx, `UPDATE fare_offers SET "rideId"=$2 WHERE id=$1`, offer.ID, rideID)
+	}
+	if err == nil {
+		_, err = tx.Exec(ctx,
+			`UPDATE fare_bids SET status=CASE WHEN id=$2 THEN 'won' ELSE 'lost' END, "updatedAt"=NOW() WHERE "offerId"=$1`,
+			offer.ID, body.BidID)
+	}
+	if err == nil {
+		err = tx.Commit(ctx)
+	}
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to book ride", err)
+		return
+	}
+
+	if err := saveRideStops(ctx, rideID, route.Stops); err != nil {
+		utils.Logger.Error("Failed to save ride stops", zap.String("rideId", rideID), zap.Error(err))
+	}
+	publishRideEvent(rideID, events.RideRequested, events.ActorUser, user.ID, map[string]any{
+		"vehicleType": route.VehicleType, "fare": amount, "offerId": offer.ID, "suggestedFare": offer.SuggestedFare})
+	publishRideEvent(rideID, events.RideAccepted, events.ActorDriver, driverID, map[string]any{"bidId": body.BidID})
+
+	publishBidEvent(stores.BidEvent{Event: "bidWon", OfferID: offer.ID, DriverIDs: []string{driverID},
+		Payload: map[string]any{"rideId": rideID, "fare": amount}})
+	publishBidEvent(stores.BidEvent{Event: "bidClosed", OfferID: offer.ID, DriverIDs: excludeDriver(offer.NotifiedDrivers, driverID)})
+	sendNotifications(ctx, outboxPush("driver", driverID, rideID, "Bid accepted! 🚗",
+		fmt.Sprintf("The rider chose you for %s. Head to %s.", formatMoney(route.Currency, amount), route.OriginName), utils.FCMData{
+			"type":   "bid_won",
+			"rideId": rideID,
+		}))
+
+	utils.RespondSuccess(c, http.StatusCreated, "Ride booked", gin.H{
+		"rideId":   rideID,
+		"driverId": driverID,
+		"fare":     amount,
+		"otp":      otp,
+	})
+}
+
+// POST /api/v1/user/ride/bid/:id/cancel
+func CancelFareOffer(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+	var notified []string
+	err := db.Pool.QueryRow(c.Request.Context(),
+		`UPDATE fare_offers SET status='cancelled', "updatedAt"=NOW()
+		 WHERE id=$1 AND "userId"=$2 AND status='open' RETURNING "notifiedDrivers"`,
+		c.Param("id"), user.ID).Scan(&notified)
+	if err != nil {
+		utils.RespondErrorCode(c, http.StatusConflict, utils.CodeRideExpired, "This fare request is no longer open", err)
+		return
+	}
+	db.Pool.Exec(c.Request.Context(), `UPDATE fare_bids SET status='lost', "updatedAt"=NOW() WHERE "offerId"=$1`, c.Param("id"))
+	publishBidEvent(stores.BidEvent{Event: "bidClosed", OfferID: c.Param("id"), DriverIDs: notified})
+	utils.RespondSuccess(c, http.StatusOK, "Fare request cancelled", nil)
+}
+
+// ══════════════════════════════════════════════════
+// Driver: Bids
+// ══════════════════════════════════════════════════
+
+// GET /api/v1/driver/bids — open fare requests this driver was invited to, with their own bid
+func GetOpenFareOffers(c *gin.Context) {
+	driver := c.MustGet("driver").(*models.Driver)
+	rows, err := db.Pool.Query(c.Request.Context(),
+		`SELECT `+fareOfferSelectCols+`,
+		 (SELECT amount FROM fare_bids b WHERE b."offerId"=fare_offers.id AND b."driverId"=$1),
+		 (SELECT kind FROM fare_bids b WHERE b."offerId"=fare_offers.id AND b."driverId"=$1)
+		 FROM fare_offers
+		 WHERE $1=ANY("notifiedDrivers") AND status='open' AND "expiresAt" > NOW()
+		 ORDER BY "expiresAt"`, driver.ID)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to fetch fare requests", err)
+		return
+	}
+	defer rows.Close()
+
+	offers := []map[string]any{}
+	for rows.Next() {
+		var offer fareOffer
+		var myAmount *float64
+		var myKind *string
+		var route []byte
+		if err := rows.Scan(&offer.ID, &offer.UserID, &offer.Zone, &offer.RouteID, &route, &offer.PaymentMode,
+			&offer.EstimatedFare, &offer.SuggestedFare, &offer.MinFare, &offer.MaxFare, &offer.NotifiedDrivers,
+			&offer.Status, &offer.RideID, &offer.ExpiresAt, &offer.CreatedAt, &myAmount, &myKind); err != nil {
+			continue
+		}
+		json.Unmarshal(route, &offer.Route)
+		summary := offer.summary()
+		if myAmount != nil {
+			summary["myBid"] = map[string]any{"amount": *myAmount, "kind": *myKind}
+		}
+		offers = append(offers, summary)
+	}
+	utils.RespondSuccess(c, http.StatusOK, "Open fare requests", offers)
+}
+
+// POST /api/v1/driver/bid — accept the rider's fare (no amount) or counter within the bounds.
+// Bidding again on the same request replaces the earlier bid.
+func PlaceFareBid(c *gin.Context) {
+	driver := c.MustGet("driver").(*models.Driver)
+	if !driver.IsOnline || driver.Status != "active" {
+		respondDriverNotReady(c, driver)
+		return
+	}
+	var body struct {
+		OfferID string  `json:"offerId" binding:"required"`
+		Amount  float64 `json:"amount"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid request", err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	var offer fareOffer
+	err := scanFareOffer(db.Pool.QueryRow(ctx,
+		`SELECT `+fareOfferSelectCols+` FROM fare_offers
+		 WHERE id=$1 AND $2=ANY("notifiedDrivers") AND status='open' AND "expiresAt" > NOW()`,
+		body.OfferID, driver.ID), &offer)
+	if err != nil {
+		utils.RespondErrorCode(c, http.StatusConflict, utils.CodeRideExpired, "This fare request is no longer open", err)
+		return
+	}
+
+	kind := bidKindCounter
+	if body.Amount == 0 || body.Amount == offer.SuggestedFare {
+		body.Amount, kind = offer.SuggestedFare, bidKindAccept
+	}
+	if body.Amount < offer.MinFare || body.Amount > offer.MaxFare {
+		utils.RespondError(c, http.StatusBadRequest,
+			fmt.Sprintf("Counter-offer must be between %s and %s", formatMoney(offer.Route.Currency, offer.MinFare), formatMoney(offer.Route.Currency, offer.MaxFare)), nil)
+		return
+	}
+
+	var bidID string
+	err = db.Pool.QueryRow(ctx,
+		`INSERT INTO fare_bids ("offerId", "driverId", amount, kind) VALUES ($1, $2, $3, $4)
+		 ON CONFLICT ("offerId", "driverId") DO UPDATE SET amount=EXCLUDED.amount, kind=EXCLUDED.kind, "updatedAt"=NOW()
+		 RETURNING id`,
+		offer.ID, driver.ID, body.Amount, kind).Scan(&bidID)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to place bid", err)
+		return
+	}
+
+	publishBidEvent(stores.BidEvent{Event: "bidReceived", OfferID: offer.ID, UserID: offer.UserID, Payload: map[string]any{
+		"bidId":        bidID,
+		"driverId":     driver.ID,
+		"driverName":   driver.Name,
+		"driverRating": driver.Ratings,
+		"vehicleType":  driver.VehicleType,
+		"amount":       body.Amount,
+		"kind":         kind,
+	}})
+	var userToken *string
+	db.Pool.QueryRow(ctx, `SELECT "notificationToken" FROM "user" WHERE id=$1`, offer.UserID).Scan(&userToken)
+	if userToken != nil && *userToken != "" {
+		msg := fmt.Sprintf("%s accepted your fare of %s", driver.Name, formatMoney(offer.Route.Currency, body.Amount))
+		if kind == bidKindCounter {
+			msg = fmt.Sprintf("%s offers to drive you for %s", driver.Name, formatMoney(offer.Route.Currency, body.Amount))
+		}
+		go utils.SendPushNotification(utils.Recipient{}, *userToken, "New driver offer", msg, utils.FCMData{
+			"type":    "bid_received",
+			"offerId": offer.ID,
+			"bidId":   bidID,
+		})
+	}
+
+	utils.RespondSuccess(c, http.StatusOK, "Bid placed", gin.H{"bidId": bidID, "amount": body.Amount, "kind": kind})
+}
+
+// ══════════════════════════════════════════════════
+// Bid Expiry Worker
+// ══════════════════════════════════════════════════
+
+// StartBidExpiryWorker closes fare requests nobody was chosen for in time and tells both sides.
+func StartBidExpiryWorker(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(15 * time.Second)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				expireFareOffers()
+			case <-ctx.Done():
+				utils.Logger.Info("Bid Expiry Worker shutting down...")
+				return
+			}
+		}
+	}()
+}
+
+func expireFareOffers() {
+	ctx := context.Background()
+	rows, err := db.Pool.Query(ctx,
+		`UPDATE fare_offers SET status='expired', "updatedAt"=NOW()
+		 WHERE status='open' AND "expiresAt" <= NOW()
+		 RETURNING id, "userId", "notifiedDrivers"`)
+	if err != nil {
+		utils.Logger.Error("Failed to expire fare requests", zap.Error(err))
+		return
+	}
+	type expired struct {
+		id, userID string
+		drivers    []string
+	}
+	var offers []expired
+	for rows.Next() {
+		var o expired
+		if rows.Scan(&o.id, &o.userID, &o.drivers) == nil {
+			offers = append(offers, o)
+		}
+	}
+	rows.Close()
+
+	for _, o := range offers {
+		db.Pool.Exec(ctx, `UPDATE fare_bids SET status='lost', "updatedAt"=NOW() WHERE "offerId"=$1`, o.id)
+		publishBidEvent(stores.BidEvent{Event: "bidExpired", OfferID: o.id, UserID: o.userID})
+		publishBidEvent(stores.BidEvent{Event: "bidClosed", OfferID: o.id, DriverIDs: o.drivers})
+
+		sendNotifications(ctx, outboxPush("user", o.userID, "", "Fare request expired",
+			"No driver was chosen in time. Try again or book at the standard fare.", utils.FCMData{
+				"type":    "bid_expired",
+				"offerId": o.id,
+			}))
+	}
+}
@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"ridewave/db"
+	"ridewave/models"
+	"ridewave/utils"
+)
+
+// ══════════════════════════════════════════════════
+// Carbon Footprint — per-ride CO2 estimates and EV savings
+// ══════════════════════════════════════════════════
+
+// co2BaselineFactor is what a typical combustion car emits (CO2_BASELINE_G_PER_KM, default 150 g/km).
+// Vehicle types without their own factor use it, and EV savings are measured against it.
+func co2BaselineFactor() float64 {
+	factor := 150.0
+	if val, err := strconv.ParseFloat(os.Getenv("CO2_BASELINE_G_PER_KM"), 64); err == nil && val >= 0 {
+		factor = val
+	}
+	return factor
+}
+
+// evEmissionFactor is the grid-charging footprint of an EV type without its own factor
+// (EV_CO2_G_PER_KM, default 60 g/km).
+func evEmissionFactor() float64 {
+	factor := 60.0
+	if val, err := strconv.ParseFloat(os.Getenv("EV_CO2_G_PER_KM"), 64); err == nil && val >= 0 {
+		factor = val
+	}
+	return factor
+}
+
+// rideEmissions estimates a trip's CO2 and, for EVs, how much it saved against the baseline.
+func rideEmissions(meters int, isElectric bool, factor *float64) (co2, saved float64) {
+	perKm := co2BaselineFactor()
+	if isElectric {
+		perKm = evEmissionFactor()
+	}
+	if factor != nil {
+		perKm = *factor
+	}
+	km := float64(meters) / 1000.0
+	co2 = math.Round(km * perKm)
+	if isElectric {
+		saved = math.Max(0, math.Round(km*co2BaselineFactor())-co2)
+	}
+	return co2, saved
+}
+
+// vehicleRideEmissions is rideEmissions for a vehicle type, looked up in ctx's tenant.
+func vehicleRideEmissions(ctx context.Context, vehicleType string, meters int) (co2, saved float64, isElectric bool) {
+	var factor *float64
+	if vt := findVehicleType(ctx, vehicleType); vt != nil {
+		isElectric, factor = vt.IsElectric, vt.EmissionFactor
+	}
+	co2, saved = rideEmissions(meters, isElectric, factor)
+	return co2, saved, isElectric
+}
+
+// recordRideEmissions stamps a completed ride with its CO2 estimate, using its tenant's vehicle type.
+func recordRideEmissions(rideID string) {
+	ctx := db.Unscoped()
+	var meters int
+	var isElectric *bool
+	var factor *float64
+	err := db.Pool.QueryRow(ctx,
+		`SELECT COALESCE(r."estimatedDistance", 0), vt."isElectric", vt."emissionFactor"
+		 FROM rides r
+		 LEFT JOIN vehicle_types vt ON vt.name=r."vehicleType" AND vt."tenantId"=r."tenantId"
+		 WHERE r.id=$1`, rideID).Scan(&meters, &isElectric, &factor)
+	if err != nil {
+		utils.Logger.Warn("Failed to load ride for emissions", zap.String("rideId", rideID), zap.Error(err))
+		return
+	}
+	electric := isElectric != nil && *isElectric
+	co2, saved := rideEmissions(meters, electric, factor)
+	db.Pool.Exec(ctx,
+		`UPDATE rides SET "isElectric"=$2, "co2Grams"=$3, "co2SavedGrams"=$4 WHERE id=$1`, rideID, electric, co2, saved)
+}
+
+// GET /api/v1/user/carbon — the rider's footprint and what their EV rides saved
+func GetUserCarbonSummary(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+	var rides, evRides int
+	var co2, saved, monthSaved float64
+	err := db.Pool.QueryRow(c.Request.Context(),
+		`SELECT COUNT(*), COUNT(*) FILTER (WHERE "isElectric"), COALESCE(SUM("co2Grams"), 0), COALESCE(SUM("co2SavedGrams"), 0),
+		 COALESCE(SUM("co2SavedGrams") FILTER (WHERE "completedAt" >= date_trunc('month', NOW())), 0)
+		 FROM rides WHERE "userId"=$1 AND status='Completed' AND "co2Grams" IS NOT NULL`, user.ID).
+		Scan(&rides, &evRides, &co2, &saved, &monthSaved)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to fetch carbon summary", err)
+		return
+	}
+
+	utils.RespondSuccess(c, http.StatusOK, "Carbon summary", gin.H{
+		"rides":           rides,
+		"evRides":         evRides,
+		"co2Kg":           math.Round(co2/10) / 100,
+		"co2SavedKg":      math.Round(saved/10) / 100,
+		"co2SavedMonthKg": math.Round(monthSaved/10) / 100,
+	})
+}
+
+// ══════════════════════════════════════════════════
+// Admin: Fleet Emissions Report
+// ══════════════════════════════════════════════════
+
+type emissionsRow struct {
+	Period      string  `json:"period,omitempty"`      // YYYY-MM, in the monthly breakdown
+	VehicleType string  `json:"vehicleType,omitempty"` // in the per-type breakdown
+	Rides       int     `json:"rides"`
+	EVRides     int     `json:"evRides"`
+	DistanceKm  float64 `json:"distanceKm"`
+	EVKm        float64 `json:"evKm"`
+	CO2Kg       float64 `json:"co2Kg"`
+	CO2SavedKg  float64 `json:"co2SavedKg"`
+}
+
+const emissionsAggregateCols = `COUNT(*), COUNT(*) FILTER (WHERE "isElectric"),
+	ROUND(COALESCE(SUM("estimatedDistance"), 0) / 1000.0, 2)::float8,
+	ROUND(COALESCE(SUM("estimatedDistance") FILTER (WHERE "isElectric"), 0) / 1000.0, 2)::float8,
+	ROUND(COALESCE(SUM("co2Grams"), 0)::numeric / 1000, 2)::float8,
+	ROUND(COALESCE(SUM("co2SavedGrams"), 0)::numeric / 1000, 2)::float8`
+
+// GET /api/v1/admin/emissions?from=2026-01-01&to=2026-03-31&format=csv
+// Completed rides' estimated CO2, EV share and avoided emissions, by month and vehicle type.
+// Defaults to the last 12 months.
+func AdminEmissionsReport(c *gin.Context) {
+	to := time.Now()
+	from := to.AddDate(-1, 0, 0)
+	if t, err := time.Parse("2006-01-02", c.Query("from")); err == nil {
+		from = t
+	}
+	if t, err := time.Parse("2006-01-02", c.Query("to")); err == nil {
+		to = t.AddDate(0, 0, 1) // inclusive of the whole end day
+	}
+	ctx := adminContext(c)
+	where := `FROM rides WHERE status='Completed' AND "co2Grams" IS NOT NULL AND "completedAt" >= $1 AND "completedAt" < $2`
+
+	var total emissionsRow
+	err := db.Pool.QueryRow(ctx, `SELECT `+emissionsAggregateCols+` `+where, from, to).
+		Scan(&total.Rides, &total.EVRides, &total.DistanceKm, &total.EVKm, &total.CO2Kg, &total.CO2SavedKg)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to build emissions report", err)
+		return
+	}
+
+	byMonth, err := emissionsBreakdown(ctx, `to_char("completedAt", 'YYYY-MM')`, where, from, to)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to build emissions report", err)
+		return
+	}
+	byType, err := emissionsBreakdown(ctx, `COALESCE("vehicleType", '')`, where, from, to)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to build emissions report", err)
+		return
+	}
+	for i := range byType {
+		byType[i].VehicleType, byType[i].Period = byType[i].Period, ""
+	}
+
+	if c.Query("format") != "csv" {
+		utils.RespondSuccess(c, http.StatusOK, "Emissions report", gin.H{
+			"from":            from.Format("2006-01-02"),
+			"to":              to.AddDate(0, 0, -1).Format("2006-01-02"),
+			"baselineGPerKm":  co2BaselineFactor(),
+			"evDefaultGPerKm": evEmissionFactor(),
+			"total":           total,
+			"byMonth":         byMonth,
+			"byVehicleType":   byType,
+		})
+		return
+	}
+
+	c.Header("Content-Type", "text/csv")
+	c.Header("Content-Disposition", "attachment; filename=emissions-"+from.Format("20060102")+".csv")
+	w := csv.NewWriter(c.Writer)
+	w.Write([]string{"month", "rides", "evRides", "distanceKm", "evKm", "co2Kg", "co2SavedKg"})
+	for _, r := range append(byMonth, emissionsRow{Period: "total", Rides: total.Rides, EVRides: total.EVRides,
+		DistanceKm: total.DistanceKm, EVKm: total.EVKm, CO2Kg: total.CO2Kg, CO2SavedKg: total.CO2SavedKg}) {
+		w.Write([]string{r.Period, strconv.Itoa(r.Rides), strconv.Itoa(r.EVRides), fmt.Sprintf("%.2f", r.DistanceKm),
+			fmt.Sprintf("%.2f", r.EVKm), fmt.Sprintf("%.2f", r.CO2Kg), fmt.Sprintf("%.2f", r.CO2SavedKg)})
+	}
+	w.Flush()
+}
+
+// emissionsBreakdown groups the report by key; the group label lands in Period.
+func emissionsBreakdown(ctx context.Context, key, where string, from, to time.Time) ([]emissionsRow, error) {
+	rows, err := db.Pool.Query(ctx, `SELECT `+key+`, `+emissionsAggregateCols+` `+where+` GROUP BY 1 ORDER BY 1`, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []emissionsRow{}
+	for rows.Next() {
+		var r emissionsRow
+		if err := rows.Scan(&r.Period, &r.Rides, &r.EVRides, &r.DistanceKm, &r.EVKm, &r.CO2Kg, &r.CO2SavedKg); err != nil {
+			return nil, err
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"ridewave/chaos"
+	"ridewave/models"
+	"ridewave/utils"
+)
+
+// ══════════════════════════════════════════════════
+// Admin: Fault Injection — staging resilience drills
+// ══════════════════════════════════════════════════
+
+const maxChaosDuration = 2 * time.Hour
+
+func chaosAllowed(c *gin.Context) bool {
+	if !chaos.Allowed() {
+		utils.RespondError(c, http.StatusForbidden, "Fault injection is disabled on this deployment (set CHAOS_ENABLED=true)", nil)
+		return false
+	}
+	return true
+}
+
+// GET /api/v1/admin/chaos
+func AdminGetChaos(c *gin.Context) {
+	utils.RespondSuccess(c, http.StatusOK, "Fault injection state", gin.H{
+		"allowed": chaos.Allowed(),
+		"targets": chaos.Targets,
+		"state":   chaos.Get(),
+	})
+}
+
+// PUT /api/v1/admin/chaos
+func AdminSetChaos(c *gin.Context) {
+	admin := c.MustGet("admin").(*models.AdminAccount)
+	if !chaosAllowed(c) {
+		return
+	}
+	var body struct {
+		Target          string `json:"target" binding:"required"` // redis | postgres | external
+		Enabled         bool   `json:"enabled"`
+		DelayMs         int    `json:"delayMs"`
+		DelayPercent    int    `json:"delayPercent"`
+		FailPercent     int    `json:"failPercent"`
+		DurationMinutes int    `json:"durationMinutes"` // faults switch off after this (default 15, max 120)
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid request", err)
+		return
+	}
+	if body.Target != chaos.TargetRedis && body.Target != chaos.TargetPostgres && body.Target != chaos.TargetExternal {
+		utils.RespondError(c, http.StatusBadRequest, "Target must be redis, postgres or external", nil)
+		return
+	}
+	if body.DelayPercent < 0 || body.DelayPercent > 100 || body.FailPercent < 0 || body.FailPercent > 100 {
+		utils.RespondError(c, http.StatusBadRequest, "Percentages must be between 0 and 100", nil)
+		return
+	}
+	if body.DelayMs < 0 || body.DelayMs > 30000 {
+		utils.RespondError(c, http.StatusBadRequest, "Delay must be between 0 and 30000 ms", nil)
+		return
+	}
+
+	duration := time.Duration(body.DurationMinutes) * time.Minute
+	if duration <= 0 {
+		duration = 15 * time.Minute
+	}
+	if duration > maxChaosDuration {
+		duration = maxChaosDuration
+	}
+
+	chaos.Set(body.Target, chaos.Fault{
+		Enabled:      body.Enabled,
+		DelayMs:      body.DelayMs,
+		DelayPercent: body.DelayPercent,
+		FailPercent:  body.FailPercent,
+	}, time.Now().Add(duration), admin.Email)
+	utils.Logger.Warn("Fault injection updated", zap.String("target", body.Target), zap.Bool("enabled", body.Enabled),
+		zap.Int("delayMs", body.DelayMs), zap.Int("delayPercent", body.DelayPercent), zap.Int("failPercent", body.FailPercent),
+		zap.String("by", admin.Email))
+
+	utils.RespondSuccess(c, http.StatusOK, "Fault injection updated", gin.H{"state": chaos.Get()})
+}
+
+// DELETE /api/v1/admin/chaos — switch every fault off
+func AdminResetChaos(c *gin.Context) {
+	admin := c.MustGet("admin").(*models.AdminAccount)
+	chaos.Reset(admin.Email)
+	utils.Logger.Warn("Fault injection reset", zap.String("by", admin.Email))
+	utils.RespondSuccess(c, http.StatusOK, "All faults cleared", gin.H{"state": chaos.Get()})
+}
@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+	"ridewave/db"
+	"ridewave/models"
+	"ridewave/utils"
+)
+
+// ══════════════════════════════════════════════════
+// Commission — per vehicle type default, optional per driver override
+// ══════════════════════════════════════════════════
+//
+// A driver's override wins, then their vehicle type's rate, then PLATFORM_FEE_PERCENTAGE.
+// Estimates can't know the driver yet, so fares are priced at the vehicle type's rate;
+// the split at completion uses the driver's effective rate.
+
+// commissionPercentFor returns the commission rate that applies to driverID on a ride of vehicleType.
+func commissionPercentFor(ctx context.Context, driverID, vehicleType string) float64 {
+	var percent *float64
+	db.Pool.QueryRow(ctx,
+		`SELECT COALESCE(d."commissionPercent", vt."commissionPercent")
+		 FROM driver d LEFT JOIN vehicle_types vt ON vt.name=$2 AND vt."tenantId"=d."tenantId"
+		 WHERE d.id=$1`, driverID, vehicleType).Scan(&percent)
+	if percent == nil {
+		return platformFeePercent()
+	}
+	return *percent
+}
+
+// recordRideCommission works out the platform's commission on a completed ride and stores the
+// rate and amount on the ride for audit, on tx. A ride that already has one keeps it.
+func recordRideCommission(ctx context.Context, tx pgx.Tx, rideID, driverID string, charge float64) (float64, error) {
+	var vehicleType string
+	var recorded *float64
+	err := tx.QueryRow(ctx, `SELECT COALESCE("vehicleType", ''), "commissionAmount" FROM rides WHERE id=$1`, rideID).
+		Scan(&vehicleType, &recorded)
+	if err != nil {
+		return 0, err
+	}
+	if recorded != nil {
+		return *recorded, nil
+	}
+
+	percent := commissionPercentFor(ctx, driverID, vehicleType)
+	amount := commissionAt(charge, percent)
+	_, err = tx.Exec(ctx,
+		`UPDATE rides SET "commissionPercent"=$2, "commissionAmount"=$3 WHERE id=$1`, rideID, percent, amount)
+	return amount, err
+}
+
+// validCommissionPercent accepts nil (clear the override) or a rate from 0 to 100.
+func validCommissionPercent(percent *float64) bool {
+	return percent == nil || (*percent >= 0 && *percent <= 100)
+}
+
+// GET /api/v1/admin/commissions — the default, each vehicle type's rate and every driver override
+func AdminGetCommissions(c *gin.Context) {
+	ctx := adminTenantContext(c)
+
+	type vehicleCommission struct {
+		ID                string   `json:"id"`
+		Name              string   `json:"name"`
+		CommissionPercent *float64 `json:"commissionPercent"`
+		EffectivePercent  float64  `json:"effectivePercent"`
+	}
+	vehicleTypes := []vehicleCommission{}
+	rows, err := db.Pool.Query(ctx, `SELECT id, name, "commissionPercent" FROM vehicle_types ORDER BY "baseFare" ASC`)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to fetch vehicle types", err)
+		return
+	}
+	for rows.Next() {
+		var v vehicleCommission
+		if rows.Scan(&v.ID, &v.Name, &v.CommissionPercent) != nil {
+			continue
+		}
+		v.EffectivePercent = platformFeePercent()
+		if v.CommissionPercent != nil {
+			v.EffectivePercent = *v.CommissionPercent
+		}
+		vehicleTypes = append(vehicleTypes, v)
+	}
+	rows.Close()
+
+	type driverOverride struct {
+		ID                string  `json:"id"`
+		Name              string  `json:"name"`
+		PhoneNumber       string  `json:"phoneNumber"`
+		VehicleType       string  `json:"vehicleType"`
+		CommissionPercent float64 `json:"commissionPercent"`
+	}
+	drivers := []driverOverride{}
+	rows, err = db.Pool.Query(ctx,
+		`SELECT id, name, phone_number, vehicle_type, "commissionPercent" FROM driver
+		 WHERE "commissionPercent" IS NOT NULL ORDER BY name`)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to fetch driver overrides", err)
+		return
+	}
+	for rows.Next() {
+		var d driverOverride
+		if rows.Scan(&d.ID, &d.Name, &d.PhoneNumber, &d.VehicleType, &d.CommissionPercent) == nil {
+			drivers = append(drivers, d)
+		}
+	}
+	rows.Close()
+
+	utils.RespondSuccess(c, http.StatusOK, "Commission rates", gin.H{
+		"defaultPercent":  platformFeePercent(),
+		"vehicleTypes":    vehicleTypes,
+		"driverOverrides": drivers,
+	})
+}
+
+// PUT /api/v1/admin/vehicle-type/:id/commission — {commissionPercent: 12.5}, or null for the default
+// Changes the price of new estimates as well as the split on rides completed afterwards.
+func AdminSetVehicleTypeCommission(c *gin.Context) {
+	var body struct {
+		CommissionPercent *float64 `json:"commissionPercent"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil || !validCommissionPercent(body.CommissionPercent) {
+		utils.RespondError(c, http.StatusBadRequest, "commissionPercent must be between 0 and 100, or null", err)
+		return
+	}
+	tag, err := db.Pool.Exec(adminTenantContext(c),
+		`UPDATE vehicle_types SET "commissionPercent"=$2, "updatedAt"=NOW() WHERE id=$1`, c.Param("id"), body.CommissionPercent)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to update commission", err)
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		utils.RespondError(c, http.StatusNotFound, "Vehicle type not found", nil)
+		return
+	}
+	invalidateVehicleTypes(c.Request.Context())
+	logCommissionChange(c, "vehicle_type", c.Param("id"), body.CommissionPercent)
+	utils.RespondSuccess(c, http.StatusOK, "Vehicle type commission updated", gin.H{"commissionPercent": body.CommissionPercent})
+}
+
+// PUT /api/v1/admin/driver/:id/commission — {commissionPercent: 10}, or null to follow the vehicle type
+func AdminSetDriverCommission(c *gin.Context) {
+	var body struct {
+		CommissionPercent *float64 `json:"commissionPercent"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil || !validCommissionPercent(body.CommissionPercent) {
+		utils.RespondError(c, http.StatusBadRequest, "commissionPercent must be between 0 and 100, or null", err)
+		return
+	}
+	tag, err := db.Pool.Exec(adminContext(c),
+		`UPDATE driver SET "commissionPercent"=$2, "updatedAt"=NOW() WHERE id=$1`, c.Param("id"), body.CommissionPercent)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to update commission", err)
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		utils.RespondError(c, http.StatusNotFound, "Driver not found", nil)
+		return
+	}
+	logCommissionChange(c, "driver", c.Param("id"), body.CommissionPercent)
+	utils.RespondSuccess(c, http.StatusOK, "Driver commission updated", gin.H{"commissionPercent": body.CommissionPercent})
+}
+
+func logCommissionChange(c *gin.Context, target, id string, percent *float64) {
+	admin := c.MustGet("admin").(*models.AdminAccount)
+	fields := []zap.Field{zap.String("target", target), zap.String("id", id), zap.String("admin", admin.Email)}
+	if percent != nil {
+		fields = append(fields, zap.Float64("commissionPercent", *percent))
+	}
+	utils.Logger.Info("Commission rate changed", fields...)
+}
+
+// driverCommissionSummary is the driver's override (if any) and the rate their rides are split at.
+func driverCommissionSummary(ctx context.Context, driverID string) gin.H {
+	var override *float64
+	var vehicleType string
+	db.Pool.QueryRow(ctx, `SELECT "commissionPercent", vehicle_type FROM driver WHERE id=$1`, driverID).Scan(&override, &vehicleType)
+	return gin.H{
+		"overridePercent":  override,
+		"effectivePercent": commissionPercentFor(ctx, driverID, vehicleType),
+	}
+}
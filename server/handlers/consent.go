@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"ridewave/db"
+	"ridewave/models"
+	"ridewave/utils"
+)
+
+// ══════════════════════════════════════════════════
+// Marketing Consent — per-channel promotional opt-in/out
+// ══════════════════════════════════════════════════
+
+// GET /api/v1/user/communication-preferences
+func GetCommunicationPreferences(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+
+	prefs := map[string]gin.H{}
+	for _, ch := range []string{utils.ChannelSMS, utils.ChannelWhatsApp, utils.ChannelEmail} {
+		prefs[ch] = gin.H{"optedIn": false, "updatedAt": nil}
+	}
+
+	rows, err := db.Pool.Query(c.Request.Context(),
+		`SELECT channel, status, "updatedAt" FROM marketing_consent WHERE "userId"=$1`, user.ID)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to fetch preferences", err)
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var channel, status string
+		var updatedAt time.Time
+		rows.Scan(&channel, &status, &updatedAt)
+		prefs[channel] = gin.H{"optedIn": status == utils.ConsentOptedIn, "updatedAt": updatedAt}
+	}
+	utils.RespondSuccess(c, http.StatusOK, "Communication preferences", gin.H{"preferences": prefs})
+}
+
+// PUT /api/v1/user/communication-preferences
+func UpdateCommunicationPreference(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+	var body struct {
+		Channel string `json:"channel" binding:"required"` // sms | whatsapp | email
+		OptIn   *bool  `json:"optIn" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid request", err)
+		return
+	}
+	if !utils.IsMarketingChannel(body.Channel) {
+		utils.RespondError(c, http.StatusBadRequest, "Channel must be sms, whatsapp or email", nil)
+		return
+	}
+
+	status := utils.ConsentOptedOut
+	if *body.OptIn {
+		status = utils.ConsentOptedIn
+	}
+	if err := utils.RecordConsent(user.ID, body.Channel, status, "app", c.ClientIP()); err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to update preference", err)
+		return
+	}
+	utils.RespondSuccess(c, http.StatusOK, "Preference updated", gin.H{"channel": body.Channel, "status": status})
+}
+
+// ══════════════════════════════════════════════════
+// Admin: Consent Compliance
+// ══════════════════════════════════════════════════
+
+// PUT /api/v1/admin/user/:id/consent — record an out-of-app change (SMS STOP reply, DND registry, support request)
+func AdminRecordUserConsent(c *gin.Context) {
+	var body struct {
+		Channel string `json:"channel" binding:"required"`
+		Status  string `json:"status" binding:"required"` // opted_in | opted_out
+		Source  string `json:"source" binding:"required"` // e.g. sms_stop, dnd_registry, support
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid request", err)
+		return
+	}
+	if !utils.IsMarketingChannel(body.Channel) {
+		utils.RespondError(c, http.StatusBadRequest, "Channel must be sms, whatsapp or email", nil)
+		return
+	}
+	if body.Status != utils.ConsentOptedIn && body.Status != utils.ConsentOptedOut {
+		utils.RespondError(c, http.StatusBadRequest, "Status must be opted_in or opted_out", nil)
+		return
+	}
+
+	if err := utils.RecordConsent(c.Param("id"), body.Channel, body.Status, body.Source, c.ClientIP()); err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to record consent", err)
+		return
+	}
+	utils.RespondSuccess(c, http.StatusOK, "Consent recorded", nil)
+}
+
+// GET /api/v1/admin/compliance/consent-export?from=2025-01-01&to=2025-01-31&format=csv
+// Full consent change log for DND/TRAI audits. Defaults to the last 30 days.
+func AdminExportConsentLog(c *gin.Context) {
+	to := time.Now()
+	from := to.AddDate(0, 0, -30)
+	if t, err := time.Parse("2006-01-02", c.Query("from")); err == nil {
+		from = t
+	}
+	if t, err := time.Parse("2006-01-02", c.Query("to")); err == nil {
+		to = t.AddDate(0, 0, 1) // inclusive of the whole end day
+	}
+
+	rows, err := db.Pool.Query(adminContext(c),
+		`SELECT l.id, l."userId", u.phone_number, COALESCE(u.email, ''), l.channel, l.status, l.source,
+		 COALESCE(l."ipAddress", ''), l."createdAt"
+		 FROM marketing_consent_log l JOIN "user" u ON u.id=l."userId"
+		 WHERE l."createdAt" >= $1 AND l."createdAt" < $2
+		 ORDER BY l."createdAt" ASC`, from, to)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to export consent log", err)
+		return
+	}
+	defer rows.Close()
+
+	type ConsentEntry struct {
+		ID          string    `json:"id"`
+		UserID      string    `json:"userId"`
+		PhoneNumber string    `json:"phone_number"`
+		Email       string    `json:"email"`
+		Channel     string    `json:"channel"`
+		Status      string    `json:"status"`
+		Source      string    `json:"source"`
+		IPAddress   string    `json:"ipAddress"`
+		CreatedAt   time.Time `json:"createdAt"`
+	}
+	entries := []ConsentEntry{}
+	for rows.Next() {
+		var e ConsentEntry
+		rows.Scan(&e.ID, &e.UserID, &e.PhoneNumber, &e.Email, &e.Channel, &e.Status, &e.Source, &e.IPAddress, &e.CreatedAt)
+		entries = append(entries, e)
+	}
+
+	if c.Query("format") != "csv" {
+		utils.RespondSuccess(c, http.StatusOK, "Consent log", gin.H{"entries": entries, "count": len(entries)})
+		return
+	}
+
+	c.Header("Content-Type", "text/csv")
+	c.Header("Content-Disposition", "attachment; filename=consent-log-"+from.Format("20060102")+".csv")
+	w := csv.NewWriter(c.Writer)
+	w.Write([]string{"id", "userId", "phone_number", "email", "channel", "status", "source", "ipAddress", "createdAt"})
+	for _, e := range entries {
+		w.Write([]string{e.ID, e.UserID, e.PhoneNumber, e.Email, e.Channel, e.Status, e.Source, e.IPAddress, e.CreatedAt.Format(time.RFC3339)})
+	}
+	w.Flush()
+}
@@ -0,0 +1,209 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"ridewave/db"
+	"ridewave/stores"
+	"ridewave/utils"
+)
+
+// ══════════════════════════════════════════════════
+// Demand Zones — where riders are requesting, so drivers can reposition
+// ══════════════════════════════════════════════════
+
+const (
+	demandCacheTTL      = time.Minute
+	maxDemandRadiusKm   = 25.0
+	maxDemandCells      = 50
+	kmPerDegreeLatitude = 111.32
+)
+
+// demandWindow is how far back ride requests count towards demand (DEMAND_WINDOW_MINUTES, default 30).
+func demandWindow() int {
+	if val, err := strconv.Atoi(os.Getenv("DEMAND_WINDOW_MINUTES")); err == nil && val > 0 {
+		return val
+	}
+	return 30
+}
+
+// demandCellKm is the side of a heatmap cell (DEMAND_CELL_KM, default 1).
+func demandCellKm() float64 {
+	if val, err := strconv.ParseFloat(os.Getenv("DEMAND_CELL_KM"), 64); err == nil && val > 0 {
+		return val
+	}
+	return 1
+}
+
+type demandCell struct {
+	Lat             float64 `json:"lat"` // cell centre
+	Lng             float64 `json:"lng"`
+	Requests        int     `json:"requests"`
+	Unserved        int     `json:"unserved"` // still waiting, or cancelled before a driver took it
+	Drivers         int     `json:"drivers"`
+	SurgeLevel      string  `json:"surgeLevel"` // none | low | medium | high
+	SurgeMultiplier float64 `json:"surgeMultiplier"`
+}
+
+// surgeLevel grades a cell by requests per available driver.
+func surgeLevel(requests, drivers int) (string, float64) {
+	ratio := float64(requests) / math.Max(float64(drivers), 1)
+	switch {
+	case ratio >= 3.5:
+		return "high", 2.0
+	case ratio >= 2:
+		return "medium", 1.5
+	case ratio >= 1:
+		return "low", 1.2
+	default:
+		return "none", 1.0
+	}
+}
+
+// pickupSurge grades demand around a pickup the way the heatmap grades a cell: recent requests
+// within half a cell of the point against the drivers online within a cell's reach.
+func pickupSurge(ctx context.Context, lat, lng float64) (string, float64) {
+	half := demandCellKm() / 2
+	latSpan := half / kmPerDegreeLatitude
+	lngSpan := half / (kmPerDegreeLatitude * math.Max(math.Cos(lat*math.Pi/180), 0.01))
+
+	var requests int
+	db.Pool.QueryRow(ctx,
+		`SELECT COUNT(*) FROM rides
+		 WHERE "createdAt" > NOW() - make_interval(mins => $1)
+		 AND "originLat" BETWEEN $2 AND $3 AND "originLng" BETWEEN $4 AND $5`,
+		demandWindow(), lat-latSpan, lat+latSpan, lng-lngSpan, lng+lngSpan).Scan(&requests)
+	nearby, _ := stores.GetNearbyDrivers(ctx, lat, lng, demandCellKm())
+	return surgeLevel(requests, len(nearby))
+}
+
+// GET /api/v1/driver/demand-zones?lat=...&lng=...&radius=10
+func GetDemandZones(c *gin.Context) {
+	lat, errLat := strconv.ParseFloat(c.Query("lat"), 64)
+	lng, errLng := strconv.ParseFloat(c.Query("lng"), 64)
+	if errLat != nil || errLng != nil {
+		utils.RespondError(c, http.StatusBadRequest, "lat and lng are required", nil)
+		return
+	}
+	radius, err := strconv.ParseFloat(c.DefaultQuery("radius", "10"), 64)
+	if err != nil || radius <= 0 {
+		radius = 10
+	}
+	radius = math.Min(radius, maxDemandRadiusKm)
+
+	// Drivers a few hundred metres apart share the same cached heatmap
+	ctx := c.Request.Context()
+	cacheKey := fmt.Sprintf("demand:%s:%.2f:%.2f:%.0f", requestTenant(ctx), lat, lng, radius)
+	if cached, err := db.RedisClient.Get(ctx, cacheKey).Bytes(); err == nil {
+		var resp gin.H
+		if json.Unmarshal(cached, &resp) == nil {
+			utils.RespondSuccess(c, http.StatusOK, "Demand zones", resp)
+			return
+		}
+	}
+
+	cellKm := demandCellKm()
+	latStep := cellKm / kmPerDegreeLatitude
+	lngStep := cellKm / (kmPerDegreeLatitude * math.Max(math.Cos(lat*math.Pi/180), 0.01))
+	latSpan := radius / kmPerDegreeLatitude
+	lngSpan := radius / (kmPerDegreeLatitude * math.Max(math.Cos(lat*math.Pi/180), 0.01))
+
+	type cellKey struct{ row, col int64 }
+	cells := map[cellKey]*demandCell{}
+	cellFor := func(row, col int64) *demandCell {
+		key := cellKey{row, col}
+		if cells[key] == nil {
+			cells[key] = &demandCell{
+				Lat: math.Round(((float64(row)+0.5)*latStep)*1e5) / 1e5,
+				Lng: math.Round(((float64(col)+0.5)*lngStep)*1e5) / 1e5,
+			}
+		}
+		return cells[key]
+	}
+
+	// Request origins from the window, bucketed into grid cells (scoped to the driver's tenant)
+	rows, err := db.Pool.Query(ctx,
+		`SELECT FLOOR("originLat"/$1)::bigint AS "cellRow", FLOOR("originLng"/$2)::bigint AS "cellCol",
+		 COUNT(*), COUNT(*) FILTER (WHERE status='Requested' OR (status='Cancelled' AND "driverId" IS NULL))
+		 FROM rides
+		 WHERE "createdAt" > NOW() - make_interval(mins => $3)
+		 AND "originLat" BETWEEN $4 AND $5 AND "originLng" BETWEEN $6 AND $7
+		 GROUP BY "cellRow", "cellCol"`,
+		latStep, lngStep, demandWindow(), lat-latSpan, lat+latSpan, lng-lngSpan, lng+lngSpan)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to load demand", err)
+		return
+	}
+	for rows.Next() {
+		var row, col int64
+		var requests, unserved int
+		if rows.Scan(&row, &col, &requests, &unserved) == nil {
+			cell := cellFor(row, col)
+			cell.Requests, cell.Unserved = requests, unserved
+		}
+	}
+	rows.Close()
+
+	// Supply: online drivers from the live geo index, limited to the same tenant
+	nearby, _ := stores.GetNearbyDrivers(c.Request.Context(), lat, lng, radius)
+	if len(nearby) > 0 {
+		ids := make([]string, 0, len(nearby))
+		for _, d := range nearby {
+			ids = append(ids, d.DriverID)
+		}
+		online := map[string]bool{}
+		if rows, err := db.Pool.Query(ctx,
+			`SELECT id FROM driver WHERE id=ANY($1) AND "isOnline"=TRUE AND status='active'`, ids); err == nil {
+			for rows.Next() {
+				var id string
+				if rows.Scan(&id) == nil {
+					online[id] = true
+				}
+			}
+			rows.Close()
+		}
+		for _, d := range nearby {
+			if online[d.DriverID] {
+				row, col := int64(math.Floor(d.Latitude/latStep)), int64(math.Floor(d.Longitude/lngStep))
+				if cell, ok := cells[cellKey{row, col}]; ok {
+					cell.Drivers++
+				}
+			}
+		}
+	}
+
+	zones := make([]demandCell, 0, len(cells))
+	for _, cell := range cells {
+		cell.SurgeLevel, cell.SurgeMultiplier = surgeLevel(cell.Requests, cell.Drivers)
+		zones = append(zones, *cell)
+	}
+	sort.Slice(zones, func(i, j int) bool {
+		if zones[i].Requests != zones[j].Requests {
+			return zones[i].Requests > zones[j].Requests
+		}
+		return zones[i].Unserved > zones[j].Unserved
+	})
+	if len(zones) > maxDemandCells {
+		zones = zones[:maxDemandCells]
+	}
+
+	resp := gin.H{
+		"zones":         zones,
+		"cellKm":        cellKm,
+		"windowMinutes": demandWindow(),
+		"generatedAt":   time.Now(),
+	}
+	if val, err := json.Marshal(resp); err == nil {
+		db.RedisClient.Set(ctx, cacheKey, val, demandCacheTTL)
+	}
+	utils.RespondSuccess(c, http.StatusOK, "Demand zones", resp)
+}
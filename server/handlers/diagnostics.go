@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"ridewave/db"
+	"ridewave/models"
+	"ridewave/stores"
+	"ridewave/utils"
+)
+
+// ══════════════════════════════════════════════════
+// Driver Diagnostics — app heartbeat for "location not updating" complaints
+// ══════════════════════════════════════════════════
+
+const (
+	lowBatteryPercent     = 15
+	poorGPSAccuracyMeters = 50.0
+	staleDiagnosticsAfter = 15 * time.Minute
+)
+
+var networkTypes = map[string]bool{"wifi": true, "5g": true, "4g": true, "3g": true, "2g": true, "none": true, "unknown": true}
+
+const driverDiagnosticsSelectCols = `"driverId", "batteryLevel", "isCharging", "gpsAccuracy", "networkType", "appVersion", platform, "osVersion", "reportedAt"`
+
+func scanDriverDiagnostics(scanner interface{ Scan(dest ...any) error }, d *models.DriverDiagnostics) error {
+	return scanner.Scan(&d.DriverID, &d.BatteryLevel, &d.IsCharging, &d.GPSAccuracy, &d.NetworkType, &d.AppVersion, &d.Platform, &d.OSVersion, &d.ReportedAt)
+}
+
+// POST /api/v1/driver/diagnostics
+func ReportDriverDiagnostics(c *gin.Context) {
+	driver := c.MustGet("driver").(*models.Driver)
+	var body struct {
+		BatteryLevel *int     `json:"batteryLevel"`
+		IsCharging   *bool    `json:"isCharging"`
+		GPSAccuracy  *float64 `json:"gpsAccuracy"`
+		NetworkType  string   `json:"networkType"`
+		AppVersion   string   `json:"appVersion"`
+		Platform     string   `json:"platform"`
+		OSVersion    string   `json:"osVersion"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid request", err)
+		return
+	}
+	if body.BatteryLevel != nil && (*body.BatteryLevel < 0 || *body.BatteryLevel > 100) {
+		utils.RespondError(c, http.StatusBadRequest, "batteryLevel must be between 0 and 100", nil)
+		return
+	}
+	if body.GPSAccuracy != nil && *body.GPSAccuracy < 0 {
+		utils.RespondError(c, http.StatusBadRequest, "gpsAccuracy can't be negative", nil)
+		return
+	}
+	body.NetworkType = strings.ToLower(strings.TrimSpace(body.NetworkType))
+	if body.NetworkType == "" {
+		body.NetworkType = "unknown"
+	}
+	if !networkTypes[body.NetworkType] {
+		utils.RespondError(c, http.StatusBadRequest, "Unknown networkType", nil)
+		return
+	}
+
+	var diag models.DriverDiagnostics
+	err := scanDriverDiagnostics(db.Pool.QueryRow(c.Request.Context(),
+		`INSERT INTO driver_diagnostics ("driverId", "batteryLevel", "isCharging", "gpsAccuracy", "networkType", "appVersion", platform, "osVersion", "reportedAt")
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NOW())
+		 ON CONFLICT ("driverId") DO UPDATE SET "batteryLevel"=EXCLUDED."batteryLevel", "isCharging"=EXCLUDED."isCharging",
+		 "gpsAccuracy"=EXCLUDED."gpsAccuracy", "networkType"=EXCLUDED."networkType", "appVersion"=EXCLUDED."appVersion",
+		 platform=EXCLUDED.platform, "osVersion"=EXCLUDED."osVersion", "reportedAt"=NOW()
+		 RETURNING `+driverDiagnosticsSelectCols,
+		driver.ID, body.BatteryLevel, body.IsCharging, body.GPSAccuracy, body.NetworkType,
+		strings.TrimSpace(body.AppVersion), strings.ToLower(strings.TrimSpace(body.Platform)), strings.TrimSpace(body.OSVersion)), &diag)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to save diagnostics", err)
+		return
+	}
+	utils.RespondSuccess(c, http.StatusOK, "Diagnostics received", gin.H{"diagnostics": diag})
+}
+
+// driverDiagnosticsSummary is the latest snapshot plus the likely reasons a driver's location isn't updating.
+func driverDiagnosticsSummary(ctx context.Context, driverID string) gin.H {
+	var diag models.DriverDiagnostics
+	err := scanDriverDiagnostics(db.Pool.QueryRow(ctx,
+		`SELECT `+driverDiagnosticsSelectCols+` FROM driver_diagnostics WHERE "driverId"=$1`, driverID), &diag)
+	if err != nil {
+		return nil
+	}
+
+	warnings := []string{}
+	if age := time.Since(diag.ReportedAt); age > staleDiagnosticsAfter {
+		warnings = append(warnings, fmt.Sprintf("No heartbeat for %d mins: the app may be closed or offline", int(age.Minutes())))
+	}
+	if diag.BatteryLevel != nil && *diag.BatteryLevel < lowBatteryPercent && (diag.IsCharging == nil || !*diag.IsCharging) {
+		warnings = append(warnings, fmt.Sprintf("Battery at %d%%: the OS may be throttling location updates", *diag.BatteryLevel))
+	}
+	if diag.GPSAccuracy != nil && *diag.GPSAccuracy > poorGPSAccuracyMeters {
+		warnings = append(warnings, fmt.Sprintf("Poor GPS accuracy (%.0fm)", *diag.GPSAccuracy))
+	}
+	if diag.NetworkType == "none" || diag.NetworkType == "2g" {
+		warnings = append(warnings, "Weak or no network ("+diag.NetworkType+")")
+	}
+	if _, err := stores.GetDriverLocation(ctx, driverID); err != nil {
+		warnings = append(warnings, "No live location reported in the last hour")
+	}
+
+	return gin.H{"latest": diag, "warnings": warnings}
+}
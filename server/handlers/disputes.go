@@ -0,0 +1,397 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+	"ridewave/db"
+	"ridewave/models"
+	"ridewave/stores"
+	"ridewave/utils"
+)
+
+// ══════════════════════════════════════════════════
+// Fare Disputes — rider complaints, admin adjusts the final charge
+// ══════════════════════════════════════════════════
+//
+// Resolving a dispute sets the ride's final charge. On a paid ride a lower charge becomes a
+// pending refund and a higher one an amount due on the rider's payment, which the next payment
+// for the ride settles; on an unpaid ride the rider simply pays the new amount. The platform's
+// commission is recomputed at the ride's rate and the driver's wallet moves by the change in
+// their net earning, in the same transaction.
+
+const (
+	disputeOpen     = "open"
+	disputeResolved = "resolved"
+	disputeRejected = "rejected"
+)
+
+var disputeCategories = map[string]bool{
+	"overcharged":    true, // fare higher than the estimate
+	"longer_route":   true, // driver took a detour
+	"wrong_dropoff":  true, // trip ended away from the destination
+	"charged_cancel": true, // charged for a ride that didn't happen
+	"other":          true,
+}
+
+func disputeWindowDays() int {
+	if v, err := strconv.Atoi(os.Getenv("DISPUTE_WINDOW_DAYS")); err == nil && v > 0 {
+		return v
+	}
+	return 7
+}
+
+type rideDispute struct {
+	ID              string     `json:"id"`
+	RideID          string     `json:"rideId"`
+	UserID          string     `json:"userId"`
+	DriverID        *string    `json:"driverId"`
+	Category        string     `json:"category"`
+	Description     string     `json:"description"`
+	RequestedCharge *float64   `json:"requestedCharge"`
+	OriginalCharge  float64    `json:"originalCharge"`
+	FinalCharge     *float64   `json:"finalCharge"`
+	Status          string     `json:"status"`
+	Resolution      *string    `json:"resolution"`
+	ResolvedBy      *string    `json:"resolvedBy,omitempty"`
+	ResolvedAt      *time.Time `json:"resolvedAt"`
+	RefundID        *string    `json:"refundId"`
+	ExtraPaymentID  *string    `json:"extraPaymentId"`
+	CreatedAt       time.Time  `json:"createdAt"`
+	UpdatedAt       time.Time  `json:"updatedAt"`
+}
+
+const rideDisputeSelectCols = `id, "rideId", "userId", "driverId", category, description, "requestedCharge", "originalCharge",
+	"finalCharge", status, resolution, "resolvedBy", "resolvedAt", "refundId", "extraPaymentId", "createdAt", "updatedAt"`
+
+func scanRideDispute(scanner interface{ Scan(dest ...any) error }, d *rideDispute) error {
+	return scanner.Scan(&d.ID, &d.RideID, &d.UserID, &d.DriverID, &d.Category, &d.Description, &d.RequestedCharge, &d.OriginalCharge,
+		&d.FinalCharge, &d.Status, &d.Resolution, &d.ResolvedBy, &d.ResolvedAt, &d.RefundID, &d.ExtraPaymentID, &d.CreatedAt, &d.UpdatedAt)
+}
+
+// POST /api/v1/user/ride/:id/dispute — {category, description, requestedCharge}
+func CreateRideDispute(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+	ctx := c.Request.Context()
+	var body struct {
+		Category        string   `json:"category" binding:"required"`
+		Description     string   `json:"description"`
+		RequestedCharge *float64 `json:"requestedCharge"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid request", err)
+		return
+	}
+	if !disputeCategories[body.Category] {
+		utils.RespondError(c, http.StatusBadRequest, "Category must be overcharged, longer_route, wrong_dropoff, charged_cancel or other", nil)
+		return
+	}
+	if body.RequestedCharge != nil && *body.RequestedCharge < 0 {
+		utils.RespondError(c, http.StatusBadRequest, "requestedCharge can't be negative", nil)
+		return
+	}
+	if len(body.Description) > 2000 {
+		utils.RespondError(c, http.StatusBadRequest, "Description is too long", nil)
+		return
+	}
+
+	var d rideDispute
+	err := scanRideDispute(db.Pool.QueryRow(ctx,
+		`INSERT INTO ride_disputes ("rideId", "userId", "driverId", category, description, "requestedCharge", "originalCharge")
+		 SELECT id, "userId", "driverId", $3, $4, $5, charge FROM rides
+		 WHERE id=$1 AND "userId"=$2 AND status='Completed' AND "completedAt" >= NOW() - make_interval(days => $6)
+		 ON CONFLICT ("rideId") DO NOTHING
+		 RETURNING `+rideDisputeSelectCols,
+		c.Param("id"), user.ID, body.Category, strings.TrimSpace(body.Description), body.RequestedCharge, disputeWindowDays()), &d)
+	if errors.Is(err, pgx.ErrNoRows) {
+		var exists bool
+		db.Pool.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM ride_disputes WHERE "rideId"=$1 AND "userId"=$2)`, c.Param("id"), user.ID).Scan(&exists)
+		if exists {
+			utils.RespondError(c, http.StatusConflict, "This ride has already been disputed", nil)
+			return
+		}
+		utils.RespondError(c, http.StatusBadRequest,
+			fmt.Sprintf("Only your own completed rides from the last %d days can be disputed", disputeWindowDays()), nil)
+		return
+	}
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to open dispute", err)
+		return
+	}
+
+	utils.Logger.Info("Ride disputed", zap.String("rideId", d.RideID), zap.String("category", d.Category))
+	utils.RespondSuccess(c, http.StatusCreated, "Dispute submitted", gin.H{"dispute": d})
+}
+
+// GET /api/v1/user/ride/:id/dispute
+func GetRideDispute(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+	var d rideDispute
+	err := scanRideDispute(db.Pool.QueryRow(c.Request.Context(),
+		`SELECT `+rideDisputeSelectCols+` FROM ride_disputes WHERE "rideId"=$1 AND "userId"=$2`, c.Param("id"), user.ID), &d)
+	if err != nil {
+		utils.RespondError(c, http.StatusNotFound, "No dispute for this ride", nil)
+		return
+	}
+	d.ResolvedBy = nil // admin identity stays internal
+	utils.RespondSuccess(c, http.StatusOK, "Ride dispute", gin.H{"dispute": d})
+}
+
+// ══════════════════════════════════════════════════
+// Admin: Dispute Resolution
+// ══════════════════════════════════════════════════
+
+// GET /api/v1/admin/disputes?status=open&page=1&limit=20
+func AdminGetDisputes(c *gin.Context) {
+	pg, err := utils.ParsePagination(c)
+	if err != nil {
+		utils.RespondErrorCode(c, http.StatusBadRequest, utils.CodeInvalidCursor, "Invalid cursor", err)
+		return
+	}
+	conds := []string{"($1='' OR status=$1)"}
+	args := []interface{}{c.Query("status")}
+
+	var total int
+	if !pg.UseCursor {
+		db.Pool.QueryRow(adminContext(c), `SELECT COUNT(*) FROM ride_disputes`+utils.WhereClause(conds), args...).Scan(&total)
+	}
+
+	conds, args = pg.Keyset(conds, args, `"createdAt"`, "id")
+	tail, args := pg.Tail(args, `"createdAt"`, "id")
+	rows, err := db.Pool.Query(adminContext(c),
+		`SELECT `+rideDisputeSelectCols+` FROM ride_disputes`+utils.WhereClause(conds)+tail, args...)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to fetch disputes", err)
+		return
+	}
+	defer rows.Close()
+
+	disputes := []rideDispute{}
+	for rows.Next() {
+		var d rideDispute
+		if scanRideDispute(rows, &d) == nil {
+			disputes = append(disputes, d)
+		}
+	}
+
+	disputes, resp := utils.Paginate(pg, disputes, total, func(d rideDispute) (time.Time, string) { return d.CreatedAt, d.ID })
+	resp["disputes"] = disputes
+	utils.RespondSuccess(c, http.StatusOK, "Disputes", resp)
+}
+
+// GET /api/v1/admin/dispute/:id — the dispute with the ride's fare, payment and refunds
+func AdminGetDispute(c *gin.Context) {
+	ctx := adminContext(c)
+	var d rideDispute
+	err := scanRideDispute(db.Pool.QueryRow(ctx, `SELECT `+rideDisputeSelectCols+` FROM ride_disputes WHERE id=$1`, c.Param("id")), &d)
+	if err != nil {
+		utils.RespondError(c, http.StatusNotFound, "Dispute not found", err)
+		return
+	}
+
+	var charge float64
+	var paymentStatus, distance string
+	var estimatedDistance int
+	db.Pool.QueryRow(ctx,
+		`SELECT charge, COALESCE("paymentStatus", 'Pending'), distance, COALESCE("estimatedDistance", 0) FROM rides WHERE id=$1`, d.RideID).
+		Scan(&charge, &paymentStatus, &distance, &estimatedDistance)
+	refunds, refunded := listRideRefunds(ctx, d.RideID)
+
+	utils.RespondSuccess(c, http.StatusOK, "Dispute", gin.H{
+		"dispute": d,
+		"ride": gin.H{
+			"charge":              charge,
+			"paymentStatus":       paymentStatus,
+			"distance":            distance,
+			"estimatedDistanceKm": float64(estimatedDistance) / 1000,
+		},
+		"refunds":  refunds,
+		"refunded": refunded,
+	})
+}
+
+// POST /api/v1/admin/dispute/:id/resolve — {finalCharge, resolution}
+func AdminResolveDispute(c *gin.Context) {
+	admin := c.MustGet("admin").(*models.AdminAccount)
+	var body struct {
+		FinalCharge *float64 `json:"finalCharge" binding:"required"`
+		Resolution  string   `json:"resolution" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil || *body.FinalCharge < 0 || strings.TrimSpace(body.Resolution) == "" {
+		utils.RespondError(c, http.StatusBadRequest, "finalCharge (0 or more) and resolution are required", err)
+		return
+	}
+	finalCharge := round2(*body.FinalCharge)
+
+	ctx := adminContext(c)
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to resolve dispute", err)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	var d rideDispute
+	err = scanRideDispute(tx.QueryRow(ctx,
+		`SELECT `+rideDisputeSelectCols+` FROM ride_disputes WHERE id=$1 FOR UPDATE`, c.Param("id")), &d)
+	if err != nil {
+		utils.RespondError(c, http.StatusNotFound, "Dispute not found", err)
+		return
+	}
+	if d.Status != disputeOpen {
+		utils.RespondError(c, http.StatusConflict, "Dispute is already "+d.Status, nil)
+		return
+	}
+
+	var charge float64
+	var commissionPercent, commissionAmount *float64
+	var vehicleType string
+	err = tx.QueryRow(ctx,
+		`SELECT charge, "commissionPercent", "commissionAmount", COALESCE("vehicleType", '') FROM rides WHERE id=$1 FOR UPDATE`, d.RideID).
+		Scan(&charge, &commissionPercent, &commissionAmount, &vehicleType)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to load ride", err)
+		return
+	}
+
+	// Commission follows the fare at the rate the ride was split at
+	percent := platformFeePercent()
+	if commissionPercent != nil {
+		percent = *commissionPercent
+	} else if d.DriverID != nil {
+		percent = commissionPercentFor(ctx, *d.DriverID, vehicleType)
+	}
+	oldCommission := commissionAt(charge, percent)
+	if commissionAmount != nil {
+		oldCommission = *commissionAmount
+	}
+	newCommission := commissionAt(finalCharge, percent)
+	delta := round2(finalCharge - charge)
+
+	_, err = tx.Exec(ctx,
+		`UPDATE rides SET charge=$2, "commissionPercent"=$3, "commissionAmount"=$4, "updatedAt"=NOW() WHERE id=$1`,
+		d.RideID, finalCharge, percent, newCommission)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to update ride charge", err)
+		return
+	}
+
+	// Settle the difference against what the rider already paid
+	var paymentID string
+	var paid float64
+	err = tx.QueryRow(ctx, `SELECT id, amount FROM payments WHERE "rideId"=$1 AND status='paid' FOR UPDATE`, d.RideID).Scan(&paymentID, &paid)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to load payment", err)
+		return
+	}
+	var refundID, extraPaymentID *string
+	if paymentID != "" && delta < 0 {
+		var refunded float64
+		tx.QueryRow(ctx, `SELECT COALESCE(SUM(amount), 0) FROM refunds WHERE "paymentId"=$1 AND status<>'failed'`, paymentID).Scan(&refunded)
+		if amount := min(-delta, round2(paid-refunded)); amount > 0 {
+			var id string
+			err = tx.QueryRow(ctx,
+				`INSERT INTO refunds ("rideId", "paymentId", amount, reason) VALUES ($1, $2, $3, $4) RETURNING id`,
+				d.RideID, paymentID, amount, "Fare dispute: "+body.Resolution).Scan(&id)
+			if err != nil {
+				utils.RespondError(c, http.StatusInternalServerError, "Failed to create refund", err)
+				return
+			}
+			refundID = &id
+		}
+	}
+	if paymentID != "" && delta > 0 {
+		// A ride has one paid payment, so the extra goes on it as an amount due and the ride waits
+		// for payment again; paying for the ride settles it
+		if _, err = tx.Exec(ctx, `UPDATE payments SET "amountDue"="amountDue"+$2 WHERE id=$1`, paymentID, delta); err != nil {
+			utils.RespondError(c, http.StatusInternalServerError, "Failed to record extra charge", err)
+			return
+		}
+		if _, err = tx.Exec(ctx, `UPDATE rides SET "paymentStatus"='Pending', "updatedAt"=NOW() WHERE id=$1`, d.RideID); err != nil {
+			utils.RespondError(c, http.StatusInternalServerError, "Failed to record extra charge", err)
+			return
+		}
+		extraPaymentID = &paymentID
+	}
+	if d.DriverID != nil && (delta != 0 || newCommission != oldCommission) {
+		err = stores.AdjustRideEarning(ctx, tx, *d.DriverID, d.RideID, delta, round2(newCommission-oldCommission), "dispute:"+d.ID)
+		if err != nil {
+			utils.RespondError(c, http.StatusInternalServerError, "Failed to adjust driver earning", err)
+			return
+		}
+	}
+
+	err = scanRideDispute(tx.QueryRow(ctx,
+		`UPDATE ride_disputes SET status=$2, "finalCharge"=$3, resolution=$4, "resolvedBy"=$5, "resolvedAt"=NOW(),
+		 "refundId"=$6, "extraPaymentId"=$7, "updatedAt"=NOW()
+		 WHERE id=$1 RETURNING `+rideDisputeSelectCols,
+		d.ID, disputeResolved, finalCharge, strings.TrimSpace(body.Resolution), admin.Email, refundID, extraPaymentID), &d)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to resolve dispute", err)
+		return
+	}
+	if err := tx.Commit(ctx); err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to resolve dispute", err)
+		return
+	}
+	notifyDisputeOutcome(d)
+
+	utils.Logger.Info("Dispute resolved", zap.String("disputeId", d.ID), zap.Float64("charge", charge),
+		zap.Float64("finalCharge", finalCharge), zap.String("admin", admin.Email))
+	utils.RespondSuccess(c, http.StatusOK, "Dispute resolved", gin.H{
+		"dispute":    d,
+		"adjustment": delta,
+		"commission": gin.H{"before": oldCommission, "after": newCommission},
+	})
+}
+
+// POST /api/v1/admin/dispute/:id/reject — {resolution}; the charge stands
+func AdminRejectDispute(c *gin.Context) {
+	admin := c.MustGet("admin").(*models.AdminAccount)
+	var body struct {
+		Resolution string `json:"resolution" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil || strings.TrimSpace(body.Resolution) == "" {
+		utils.RespondError(c, http.StatusBadRequest, "A resolution is required", err)
+		return
+	}
+
+	var d rideDispute
+	err := scanRideDispute(db.Pool.QueryRow(adminContext(c),
+		`UPDATE ride_disputes SET status=$2, "finalCharge"="originalCharge", resolution=$3, "resolvedBy"=$4, "resolvedAt"=NOW(), "updatedAt"=NOW()
+		 WHERE id=$1 AND status=$5 RETURNING `+rideDisputeSelectCols,
+		c.Param("id"), disputeRejected, strings.TrimSpace(body.Resolution), admin.Email, disputeOpen), &d)
+	if err != nil {
+		utils.RespondError(c, http.StatusConflict, "Dispute not found or already closed", err)
+		return
+	}
+	notifyDisputeOutcome(d)
+	utils.RespondSuccess(c, http.StatusOK, "Dispute rejected", gin.H{"dispute": d})
+}
+
+// notifyDisputeOutcome tells the rider how their dispute was closed.
+func notifyDisputeOutcome(d rideDispute) {
+	msg := "We reviewed your fare dispute and the charge stands."
+	if d.Status == disputeResolved && d.FinalCharge != nil {
+		var currency string
+		db.Pool.QueryRow(db.Unscoped(), `SELECT currency FROM rides WHERE id=$1`, d.RideID).Scan(&currency)
+		msg = fmt.Sprintf("We reviewed your fare dispute. Your final fare is %s.", formatMoney(currency, *d.FinalCharge))
+		if d.RefundID != nil {
+			msg += " A refund is on its way."
+		} else if d.ExtraPaymentID != nil {
+			msg = fmt.Sprintf("We reviewed your fare dispute. Your final fare is %s. Please pay the remaining %s.",
+				formatMoney(currency, *d.FinalCharge), formatMoney(currency, round2(*d.FinalCharge-d.OriginalCharge)))
+		}
+	}
+	sendNotifications(context.Background(), outboxPush("user", d.UserID, d.RideID, "Fare dispute update", msg,
+		utils.FCMData{"type": "ride_dispute", "rideId": d.RideID, "status": d.Status}))
+}
@@ -3,13 +3,21 @@ package handlers
 import (
 	"context"
 	"fmt"
+	"math/rand"
 	"net/http"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
 	"ridewave/db"
+	"ridewave/events"
+	"ridewave/middleware"
 	"ridewave/models"
+	"ridewave/repository"
+	"ridewave/rides/statemachine"
 	"ridewave/stores"
 	"ridewave/utils"
 
@@ -17,12 +25,13 @@ import (
 )
 
 // RegisterDriverRoutes defines all driver-facing API endpoints
-func RegisterDriverRoutes(r *gin.Engine, authMiddleware gin.HandlerFunc) {
-	driverGroup := r.Group("/api/v1/driver")
+func RegisterDriverRoutes(r gin.IRouter, authMiddleware gin.HandlerFunc) {
+	driverGroup := r.Group("/driver")
 	{
 		// Auth
-		driverGroup.POST("/auth/login", DriverLogin)
-		driverGroup.POST("/auth/verify", DriverVerify)
+		driverGroup.POST("/auth/login", middleware.RateLimitRoute("login", 5), DriverLogin)
+		driverGroup.POST("/auth/verify", middleware.RateLimitRoute("verify", 10), DriverVerify)
+		driverGroup.POST("/auth/refresh", middleware.RateLimitRoute("refresh", 30), DriverRefreshToken)
 		driverGroup.POST("/auth/logout", authMiddleware, DriverLogout)
 
 		// Profile & Status
@@ -30,26 +39,64 @@ func RegisterDriverRoutes(r *gin.Engine, authMiddleware gin.HandlerFunc) {
 		driverGroup.PUT("/status", authMiddleware, UpdateDriverStatus)
 		driverGroup.PUT("/toggle-online", authMiddleware, ToggleOnline)
 		driverGroup.PUT("/notification-token", authMiddleware, UpdateDriverNotificationToken)
+		driverGroup.PUT("/languages", authMiddleware, UpdateDriverLanguages)
+		driverGroup.PUT("/preferred-language", authMiddleware, UpdateDriverPreferredLanguage)
+		driverGroup.GET("/preferences", authMiddleware, GetDriverPreferences)
+		driverGroup.PUT("/preferences", authMiddleware, UpdateDriverPreferences)
+		driverGroup.GET("/notification-preferences", authMiddleware, GetDriverNotificationPreferences)
+		driverGroup.PUT("/notification-preferences", authMiddleware, UpdateDriverNotificationPreferences)
+		driverGroup.GET("/incentives", authMiddleware, GetDriverIncentives)
+		driverGroup.GET("/vehicles", authMiddleware, GetDriverVehicles)
+		driverGroup.POST("/vehicles", authMiddleware, AddDriverVehicle)
+		driverGroup.PUT("/vehicles/:id/documents", authMiddleware, UpdateDriverVehicleDocuments)
+		driverGroup.DELETE("/vehicles/:id", authMiddleware, DeleteDriverVehicle)
+		driverGroup.PUT("/vehicle/active", authMiddleware, SetActiveDriverVehicle)
+		driverGroup.POST("/diagnostics", authMiddleware, ReportDriverDiagnostics)
+		driverGroup.DELETE("/account", authMiddleware, RequestDriverAccountDeletion)
+		driverGroup.POST("/account/cancel-deletion", authMiddleware, CancelDriverAccountDeletion)
 
 		// Vehicle types (shown during registration after OTP verify)
 		driverGroup.GET("/vehicle-types", GetVehicleTypes)
 
+		// Onboarding training (pending drivers, onboarding token)
+		driverGroup.GET("/training", middleware.IsOnboardingDriver(), GetTrainingModules)
+		driverGroup.POST("/training/:id/quiz", middleware.IsOnboardingDriver(), SubmitTrainingQuiz)
+
 		// Live Location
 		driverGroup.PUT("/location", authMiddleware, UpdateDriverLocationHandler)
+		driverGroup.PUT("/locations", authMiddleware, UpdateDriverLocationsBatch)
 		driverGroup.GET("/ride/:id/user-location", authMiddleware, GetUserLocationForDriver)
+		driverGroup.GET("/demand-zones", authMiddleware, GetDemandZones)
 
 		// Ride Management
 		driverGroup.GET("/incoming-ride", authMiddleware, GetIncomingRide)
 		driverGroup.PUT("/ride/status", authMiddleware, UpdatingRideStatus)
+		driverGroup.PUT("/ride/decline", authMiddleware, DeclineRide)
+		driverGroup.GET("/bids", authMiddleware, GetOpenFareOffers)
+		driverGroup.POST("/bid", authMiddleware, PlaceFareBid)
+		driverGroup.PUT("/ride/start-with-otp", authMiddleware, StartRideWithOTP)
+		driverGroup.PUT("/ride/stop/complete", authMiddleware, CompleteRideStop)
 		driverGroup.GET("/rides", authMiddleware, GetDriverRides)
 		driverGroup.GET("/ride/:id", authMiddleware, GetSingleDriverRide)
+		driverGroup.GET("/ride/:id/pool", authMiddleware, GetPoolLegs)
+		driverGroup.GET("/ride/:id/navigation", authMiddleware, GetRideNavigation)
+		driverGroup.POST("/ride/:id/dropoff-photo", authMiddleware, UploadDropoffPhoto)
+		driverGroup.GET("/rating-config", authMiddleware, GetDriverRatingConfig)
 		driverGroup.POST("/rate-user", authMiddleware, RateUser)
-		driverGroup.POST("/payment/confirm", authMiddleware, ConfirmPayment)
+		driverGroup.GET("/reviews", authMiddleware, GetMyReviews)
+		driverGroup.POST("/payment/confirm", authMiddleware, middleware.Idempotency(), ConfirmPayment)
+		driverGroup.GET("/payments/pending", authMiddleware, GetPendingPayments)
 
 		// Earnings
 		driverGroup.GET("/earnings", authMiddleware, GetEarnings)
 		driverGroup.GET("/earnings/daily", authMiddleware, GetDailyEarnings)
 		driverGroup.GET("/earnings/weekly", authMiddleware, GetWeeklyEarnings)
+		driverGroup.GET("/earnings/export", authMiddleware, ExportEarnings)
+		driverGroup.GET("/sessions", authMiddleware, GetDriverSessions)
+
+		// Wallet
+		driverGroup.GET("/wallet", authMiddleware, GetDriverWallet)
+		driverGroup.GET("/wallet/transactions", authMiddleware, GetDriverWalletTransactions)
 
 		// Public (accessible via query param)
 		driverGroup.GET("/list", GetDriversById)
@@ -65,11 +112,20 @@ func RegisterDriverRoutes(r *gin.Engine, authMiddleware gin.HandlerFunc) {
 func DriverLogin(c *gin.Context) {
 	var body struct {
 		PhoneNumber string `json:"phone_number" binding:"required"`
+		Country     string `json:"country"` // for numbers without a calling code; default region otherwise
 	}
 	if err := c.ShouldBindJSON(&body); err != nil {
 		utils.RespondError(c, http.StatusBadRequest, "Invalid request", err)
 		return
 	}
+	phone, ok := requestPhone(c, body.PhoneNumber, body.Country)
+	if !ok {
+		return
+	}
+	body.PhoneNumber = phone
+	if redirectToHomeRegion(c, body.PhoneNumber) {
+		return
+	}
 
 	if err := utils.SendTwilioOTP(body.PhoneNumber); err != nil {
 		utils.RespondError(c, http.StatusInternalServerError, "Failed to send OTP", err)
@@ -79,13 +135,6 @@ func DriverLogin(c *gin.Context) {
 	utils.RespondSuccess(c, http.StatusOK, "OTP sent", nil)
 }
 
-// Helper to scan a full driver row
-const driverSelectCols = `id, name, country, phone_number, email, vehicle_type, registration_number, registration_date, driving_license, vehicle_color, rate, "notificationToken", ratings, "totalEarning", "totalRides", "totalDistance", "pendingRides", "cancelRides", status, "isOnline", "createdAt", "updatedAt", COALESCE("rcBook", ''), COALESCE("profileImage", ''), "upi_id"`
-
-func scanDriver(scanner interface{ Scan(dest ...any) error }, d *models.Driver) error {
-	return scanner.Scan(&d.ID, &d.Name, &d.Country, &d.PhoneNumber, &d.Email, &d.VehicleType, &d.RegistrationNumber, &d.RegistrationDate, &d.DrivingLicense, &d.VehicleColor, &d.Rate, &d.NotificationToken, &d.Ratings, &d.TotalEarning, &d.TotalRides, &d.TotalDistance, &d.PendingRides, &d.CancelRides, &d.Status, &d.IsOnline, &d.CreatedAt, &d.UpdatedAt, &d.RCBook, &d.ProfileImage, &d.UpiID)
-}
-
 // POST /api/v1/driver/auth/verify
 func DriverVerify(c *gin.Context) {
 	var body struct {
@@ -107,34 +156,49 @@ func DriverVerify(c *gin.Context) {
 		utils.RespondError(c, http.StatusBadRequest, "Invalid request", err)
 		return
 	}
+	phone, ok := requestPhone(c, body.PhoneNumber, body.Country)
+	if !ok {
+		return
+	}
+	body.PhoneNumber = phone
 
 	if err := utils.VerifyTwilioOTP(body.PhoneNumber, body.OTP); err != nil {
-		utils.RespondError(c, http.StatusBadRequest, "Invalid OTP", err)
+		utils.RespondErrorCode(c, http.StatusBadRequest, utils.CodeOTPInvalid, "Invalid OTP", err)
 		return
 	}
 
-	var driver models.Driver
-	row := db.Pool.QueryRow(context.Background(),
-		`SELECT `+driverSelectCols+` FROM driver WHERE phone_number=$1`, body.PhoneNumber)
-	if err := scanDriver(row, &driver); err == nil {
+	driver, err := repos.Drivers.GetByPhone(c.Request.Context(), body.PhoneNumber)
+	if err == nil {
+		recordDeviceFingerprint(c, noteEntityDriver, driver.ID)
+
 		// Check driver account status
 		if driver.Status == "suspended" {
-			utils.RespondError(c, http.StatusForbidden, "Your account has been suspended. Contact support.", nil)
+			utils.RespondErrorCode(c, http.StatusForbidden, utils.CodeAccountSuspended, "Your account has been suspended. Contact support.", nil)
 			return
 		}
 		if driver.Status == "rejected" {
-			utils.RespondError(c, http.StatusForbidden, "Your registration was rejected. Contact support.", nil)
+			utils.RespondErrorCode(c, http.StatusForbidden, utils.CodeAccountRejected, "Your registration was rejected. Contact support.", nil)
 			return
 		}
 		if driver.Status == "pending" {
-			// Allow login but inform them about pending verification
+			// Allow login but inform them about pending verification; the onboarding token only opens training
+			onboardingToken, err := utils.GenerateOnboardingToken(driver.ID)
+			if err != nil {
+				utils.RespondError(c, http.StatusInternalServerError, "Failed to generate token", err)
+				return
+			}
 			utils.RespondSuccess(c, http.StatusOK, "Your registration is pending admin verification.", gin.H{
-				"isPending": true,
-				"driver":    driver,
+				"isPending":       true,
+				"onboardingToken": onboardingToken,
+				"driver":          driver,
 			})
 			return
 		}
-		utils.SendToken(c, &driver, driver.ID)
+		utils.SendToken(c, driver, driver.ID)
+		return
+	}
+	if err != repository.ErrNotFound {
+		utils.RespondError(c, http.StatusInternalServerError, "Database error", err)
 		return
 	}
 
@@ -144,29 +208,36 @@ func DriverVerify(c *gin.Context) {
 		return
 	}
 
-	row = db.Pool.QueryRow(context.Background(),
-		`INSERT INTO driver (id, name, country, phone_number, email, vehicle_type, registration_number, registration_date, driving_license, vehicle_color, rate, ratings, "totalEarning", "totalRides", "totalDistance", "pendingRides", "cancelRides", status, "isOnline", "createdAt", "updatedAt", "rcBook", "profileImage", "upi_id")
-		VALUES (gen_random_uuid()::text, $1,$2,$3,$4,$5,$6,NOW(),$7,$8,$9, 0,0,0,0,0,0,'pending',FALSE,NOW(),NOW(), $10, $11, $12)
-		RETURNING `+driverSelectCols,
-		body.Name, body.Country, body.PhoneNumber, body.Email, body.VehicleType,
-		body.RegistrationNumber, body.DrivingLicense, body.VehicleColor, body.Rate, body.RCBook, body.ProfileImage, body.UpiID)
-	if err := scanDriver(row, &driver); err != nil {
+	driver, err = repos.Drivers.Create(c.Request.Context(), repository.DriverRegistration{
+		Name: body.Name, Country: body.Country, PhoneNumber: body.PhoneNumber, Email: body.Email,
+		VehicleType: body.VehicleType, RegistrationNumber: body.RegistrationNumber, DrivingLicense: body.DrivingLicense,
+		VehicleColor: body.VehicleColor, Rate: body.Rate, RCBook: body.RCBook, ProfileImage: body.ProfileImage, UpiID: body.UpiID,
+	})
+	if err != nil {
 		utils.RespondError(c, http.StatusInternalServerError, "Database error during registration", err)
 		return
 	}
-	// New drivers are pending — don't issue a full token
+	recordDeviceFingerprint(c, noteEntityDriver, driver.ID)
+
+	// New drivers are pending — don't issue a full token, only one for the training modules
+	onboardingToken, err := utils.GenerateOnboardingToken(driver.ID)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to generate token", err)
+		return
+	}
 	utils.RespondSuccess(c, http.StatusCreated, "Registration submitted! Your account is pending admin verification.", gin.H{
-		"isPending": true,
-		"driver":    driver,
+		"isPending":       true,
+		"onboardingToken": onboardingToken,
+		"driver":          driver,
 	})
 }
 
 // POST /api/v1/driver/auth/logout
 func DriverLogout(c *gin.Context) {
 	driver := c.MustGet("driver").(*models.Driver)
-	db.Pool.Exec(context.Background(),
-		`UPDATE driver SET "notificationToken"=NULL, status='inactive', "updatedAt"=NOW() WHERE id=$1`, driver.ID)
-	stores.RemoveDriver(driver.ID)
+	repos.Drivers.Logout(c.Request.Context(), driver.ID)
+	stores.RemoveDriver(c.Request.Context(), driver.ID)
+	endCurrentSession(c, utils.SessionDriver, driver.ID)
 	utils.RespondSuccess(c, http.StatusOK, "Logged out successfully", nil)
 }
 
@@ -176,8 +247,11 @@ func DriverLogout(c *gin.Context) {
 
 // GET /api/v1/driver/me
 func GetLoggedInDriverData(c *gin.Context) {
-	driver, _ := c.Get("driver")
-	utils.RespondSuccess(c, http.StatusOK, "Driver data", gin.H{"driver": driver})
+	driver := c.MustGet("driver").(*models.Driver)
+	utils.RespondSuccess(c, http.StatusOK, "Driver data", gin.H{
+		"driver":  driver,
+		"metrics": loadDriverMetrics(c.Request.Context(), driver.ID),
+	})
 }
 
 // GET /api/v1/driver/list?ids=id1,id2
@@ -189,23 +263,11 @@ func GetDriversById(c *gin.Context) {
 	}
 	driverIds := strings.Split(ids, ",")
 
-	rows, err := db.Pool.Query(context.Background(),
-		`SELECT `+driverSelectCols+` FROM driver WHERE id=ANY($1)`, driverIds)
+	drivers, err := repos.Drivers.ListByIDs(c.Request.Context(), driverIds)
 	if err != nil {
 		utils.RespondError(c, http.StatusInternalServerError, "Internal server error", err)
 		return
 	}
-	defer rows.Close()
-
-	var drivers []models.Driver
-	for rows.Next() {
-		var d models.Driver
-		scanDriver(rows, &d)
-		drivers = append(drivers, d)
-	}
-	if drivers == nil {
-		drivers = []models.Driver{}
-	}
 	utils.RespondSuccess(c, http.StatusOK, "Drivers data", gin.H{"drivers": drivers})
 }
 
@@ -227,18 +289,15 @@ func UpdateDriverStatus(c *gin.Context) {
 		return
 	}
 
-	var updated models.Driver
-	row := db.Pool.QueryRow(context.Background(),
-		`UPDATE driver SET status=$1, "updatedAt"=NOW() WHERE id=$2 RETURNING `+driverSelectCols,
-		body.Status, driver.ID)
-	if err := scanDriver(row, &updated); err != nil {
+	updated, err := repos.Drivers.SetStatus(c.Request.Context(), driver.ID, body.Status)
+	if err != nil {
 		utils.RespondError(c, http.StatusInternalServerError, "Database error", err)
 		return
 	}
 
 	// If going inactive, remove from Redis
 	if body.Status == "inactive" {
-		stores.RemoveDriver(driver.ID)
+		stores.RemoveDriver(c.Request.Context(), driver.ID)
 	}
 
 	utils.RespondSuccess(c, http.StatusOK, "Status updated", gin.H{"driver": updated})
@@ -255,11 +314,8 @@ func UpdateDriverNotificationToken(c *gin.Context) {
 		return
 	}
 
-	var updated models.Driver
-	row := db.Pool.QueryRow(context.Background(),
-		`UPDATE driver SET "notificationToken"=$1, "updatedAt"=NOW() WHERE id=$2 RETURNING `+driverSelectCols,
-		body.NotificationToken, driver.ID)
-	if err := scanDriver(row, &updated); err != nil {
+	updated, err := repos.Drivers.SetNotificationToken(c.Request.Context(), driver.ID, body.NotificationToken)
+	if err != nil {
 		utils.RespondError(c, http.StatusInternalServerError, "Database error", err)
 		return
 	}
@@ -297,7 +353,7 @@ func UpdateDriverLocationHandler(c *gin.Context) {
 
 	// 2. REDIS-EXCLUSIVE UPDATE: Real-time tracking is handled solely by Redis
 	// No PostgreSQL IO for moving data to match Ola/Uber efficiency standards.
-	stores.UpdateDriverLocation(driver.ID, finalLat, finalLng, "")
+	stores.UpdateDriverLocation(c.Request.Context(), driver.ID, finalLat, finalLng, "")
 
 	utils.RespondSuccess(c, http.StatusOK, "Location updated", nil)
 }
@@ -307,25 +363,19 @@ func GetUserLocationForDriver(c *gin.Context) {
 	rideID := c.Param("id")
 	driver := c.MustGet("driver").(*models.Driver)
 
-	var originLat, originLng *float64
-	var destLat, destLng *float64
-	var originName, destName string
-	err := db.Pool.QueryRow(context.Background(),
-		`SELECT "originLat", "originLng", "destinationLat", "destinationLng", "currentLocationName", "destinationLocationName" 
-		FROM rides WHERE id=$1 AND "driverId"=$2`, rideID, driver.ID).
-		Scan(&originLat, &originLng, &destLat, &destLng, &originName, &destName)
+	ride, err := repos.Rides.GetForDriver(c.Request.Context(), rideID, driver.ID)
 	if err != nil {
-		utils.RespondError(c, http.StatusNotFound, "Ride not found", err)
+		utils.RespondErrorCode(c, http.StatusNotFound, utils.CodeRideNotFound, "Ride not found", err)
 		return
 	}
 
 	utils.RespondSuccess(c, http.StatusOK, "User location", gin.H{
-		"originLat":       originLat,
-		"originLng":       originLng,
-		"destinationLat":  destLat,
-		"destinationLng":  destLng,
-		"originName":      originName,
-		"destinationName": destName,
+		"originLat":       ride.OriginLat,
+		"originLng":       ride.OriginLng,
+		"destinationLat":  ride.DestinationLat,
+		"destinationLng":  ride.DestinationLng,
+		"originName":      ride.CurrentLocationName,
+		"destinationName": ride.DestinationLocationName,
 	})
 }
 
@@ -333,6 +383,26 @@ func GetUserLocationForDriver(c *gin.Context) {
 // Driver Online/Offline Toggle (Start/Stop Rides)
 // ══════════════════════════════════════════════════
 
+// driverStatusCode is the error code for a driver whose account isn't active.
+func driverStatusCode(status string) string {
+	switch status {
+	case "suspended":
+		return utils.CodeAccountSuspended
+	case "rejected":
+		return utils.CodeAccountRejected
+	}
+	return utils.CodeAccountPending
+}
+
+// respondDriverNotReady refuses ride actions from a driver who is offline or not approved.
+func respondDriverNotReady(c *gin.Context, driver *models.Driver) {
+	code := utils.CodeDriverOffline
+	if driver.Status != "active" {
+		code = driverStatusCode(driver.Status)
+	}
+	utils.RespondErrorCode(c, http.StatusForbidden, code, "You must be online and approved to manage rides.", nil)
+}
+
 // PUT /api/v1/driver/toggle-online — driver clicks Start/Stop button
 func ToggleOnline(c *gin.Context) {
 	driver := c.MustGet("driver").(*models.Driver)
@@ -345,18 +415,15 @@ func ToggleOnline(c *gin.Context) {
 		} else if driver.Status == "rejected" {
 			msg = "Your registration was rejected. Contact support."
 		}
-		utils.RespondError(c, http.StatusForbidden, msg, nil)
+		utils.RespondErrorCode(c, http.StatusForbidden, driverStatusCode(driver.Status), msg, nil)
 		return
 	}
 
 	// Toggle the current state
 	newOnlineState := !driver.IsOnline
 
-	var updated models.Driver
-	row := db.Pool.QueryRow(context.Background(),
-		`UPDATE driver SET "isOnline"=$1, "updatedAt"=NOW() WHERE id=$2 RETURNING `+driverSelectCols,
-		newOnlineState, driver.ID)
-	if err := scanDriver(row, &updated); err != nil {
+	updated, err := repos.Drivers.SetOnline(c.Request.Context(), driver.ID, newOnlineState)
+	if err != nil {
 		utils.RespondError(c, http.StatusInternalServerError, "Database error", err)
 		return
 	}
@@ -366,7 +433,7 @@ func ToggleOnline(c *gin.Context) {
 		utils.RespondSuccess(c, http.StatusOK, "You are now online and accepting rides!", gin.H{"driver": updated})
 	} else {
 		// Going offline — remove from Redis geo index
-		stores.RemoveDriver(driver.ID)
+		stores.RemoveDriver(c.Request.Context(), driver.ID)
 		utils.RespondSuccess(c, http.StatusOK, "You are now offline. No new rides will be dispatched.", gin.H{"driver": updated})
 	}
 }
@@ -384,28 +451,11 @@ func GetIncomingRide(c *gin.Context) {
 		utils.RespondSuccess(c, http.StatusOK, "You are offline. Go online to receive rides.", gin.H{"ride": nil, "isOnline": false})
 		return
 	}
-	var ride models.Ride
-	var user models.User
-	err := db.Pool.QueryRow(context.Background(),
-		`SELECT r.id, r."userId", r."driverId", r.charge, r."currentLocationName", r."destinationLocationName", 
-		r.distance, COALESCE(r.polyline, ''), COALESCE(r."estimatedDuration", 0), COALESCE(r."estimatedDistance", 0),
-		COALESCE(r."vehicleType", ''), r.status, r."originLat", r."originLng", r."destinationLat", r."destinationLng",
-		r."createdAt", r."updatedAt",
-		u.id, u.name, u.phone_number, u.ratings
-		FROM rides r 
-		JOIN "user" u ON r."userId"=u.id
-		WHERE r."driverId"=$1 AND r.status='Requested'
-		ORDER BY r."createdAt" DESC LIMIT 1`, driver.ID).
-		Scan(&ride.ID, &ride.UserID, &ride.DriverID, &ride.Charge, &ride.CurrentLocationName, &ride.DestinationLocationName,
-			&ride.Distance, &ride.Polyline, &ride.EstimatedDuration, &ride.EstimatedDistance,
-			&ride.VehicleType, &ride.Status, &ride.OriginLat, &ride.OriginLng, &ride.DestinationLat, &ride.DestinationLng,
-			&ride.CreatedAt, &ride.UpdatedAt,
-			&user.ID, &user.Name, &user.PhoneNumber, &user.Ratings)
+	ride, err := repos.Rides.IncomingForDriver(c.Request.Context(), driver.ID)
 	if err != nil {
 		utils.RespondSuccess(c, http.StatusOK, "No incoming ride", gin.H{"ride": nil})
 		return
 	}
-	ride.User = &user
 	utils.RespondSuccess(c, http.StatusOK, "Incoming ride", gin.H{"ride": ride})
 }
 
@@ -415,124 +465,255 @@ func UpdatingRideStatus(c *gin.Context) {
 
 	// Only online+active drivers can accept/manage rides
 	if !driver.IsOnline || driver.Status != "active" {
-		utils.RespondError(c, http.StatusForbidden, "You must be online and approved to manage rides.", nil)
+		respondDriverNotReady(c, driver)
 		return
 	}
 	var body struct {
-		RideID     string `json:"rideId" binding:"required"`
-		RideStatus string `json:"rideStatus" binding:"required"`
+		RideID       string `json:"rideId" binding:"required"`
+		RideStatus   string `json:"rideStatus" binding:"required"`
+		CancelReason string `json:"cancelReason"`
 	}
 	if err := c.ShouldBindJSON(&body); err != nil {
 		utils.RespondError(c, http.StatusBadRequest, "Invalid input data", err)
 		return
 	}
 
-	// Validate allowed status transitions
+	// Statuses a driver sets here; the state machine decides whether the ride can move to it
 	allowed := map[string]bool{"Accepted": true, "InProgress": true, "Completed": true, "Cancelled": true}
 	if !allowed[body.RideStatus] {
 		utils.RespondError(c, http.StatusBadRequest, "Invalid ride status", nil)
 		return
 	}
 
-	var charge float64
-	err := db.Pool.QueryRow(context.Background(), `SELECT charge FROM rides WHERE id=$1`, body.RideID).Scan(&charge)
-	if err != nil {
-		utils.RespondError(c, http.StatusNotFound, "Ride not found", err)
+	// Starting the trip needs the rider's OTP, so it goes through StartRideWithOTP instead
+	if body.RideStatus == "InProgress" {
+		utils.RespondError(c, http.StatusBadRequest, "Ask the rider for their OTP and start the ride via /ride/start-with-otp", nil)
 		return
 	}
 
-	// Set lifecycle timestamp based on status transition
-	timestampCol := ""
-	switch body.RideStatus {
-	case "Accepted":
-		timestampCol = `,"acceptedAt"=NOW()`
-	case "InProgress":
-		timestampCol = `,"startedAt"=NOW()`
-	case "Completed":
-		timestampCol = `,"completedAt"=NOW()`
-	case "Cancelled":
-		timestampCol = `,"cancelledAt"=NOW()`
+	// Fresh trip OTP for the rider to share with the driver at pickup
+	otp := ""
+	if body.RideStatus == "Accepted" {
+		otp = generateRideOTP()
 	}
 
-	var updated models.Ride
-	var user models.User
-	err = db.Pool.QueryRow(context.Background(),
-		`UPDATE rides SET status=$1, "updatedAt"=NOW()`+timestampCol+` 
-		WHERE id=$2 AND "driverId"=$3 
-		RETURNING id, "userId", "driverId", charge, "currentLocationName", "destinationLocationName", distance, status, rating, "createdAt", "updatedAt"`,
-		body.RideStatus, body.RideID, driver.ID).
-		Scan(&updated.ID, &updated.UserID, &updated.DriverID, &updated.Charge, &updated.CurrentLocationName, &updated.DestinationLocationName, &updated.Distance, &updated.Status, &updated.Rating, &updated.CreatedAt, &updated.UpdatedAt)
-
-	if err != nil {
-		utils.RespondError(c, http.StatusBadRequest, "Failed to update ride", err)
-		return
+	var eventData map[string]any
+	if body.RideStatus == "Cancelled" {
+		eventData = map[string]any{"reason": body.CancelReason}
 	}
 
-	db.Pool.QueryRow(context.Background(),
-		`SELECT id, name, phone_number, ratings FROM "user" WHERE id=$1`, updated.UserID).
-		Scan(&user.ID, &user.Name, &user.PhoneNumber, &user.Ratings)
-	updated.User = &user
+	// The state machine rejects skipped steps and races with a rider's cancel. The rider's
+	// notification is written with the status change, so it's sent even if FCM is down right now
+	var updated *models.Ride
+	var languageMatch gin.H
+	_, err := statemachine.Transition(c.Request.Context(), statemachine.Change{
+		RideID: body.RideID, To: body.RideStatus, ActorType: events.ActorDriver, ActorID: driver.ID, Data: eventData,
+	}, func(ctx context.Context, tx pgx.Tx, from string) error {
+		var err error
+		updated, err = repos.Rides.SetDriverStatus(ctx, tx, body.RideID, driver.ID, body.RideStatus, otp)
+		if err != nil {
+			return err
+		}
+
+		if body.RideStatus == "Completed" {
+			if err := settleRideCompletion(ctx, tx, updated.ID, driver.ID, updated.UserID, updated.Charge, updated.Distance); err != nil {
+				return err
+			}
+		}
 
-	if body.RideStatus == "Completed" {
-		var distVal float64
-		fmt.Sscanf(updated.Distance, "%f", &distVal)
-		db.Pool.Exec(context.Background(),
-			`UPDATE driver SET "totalEarning"="totalEarning"+$1, "totalRides"="totalRides"+1, "totalDistance"="totalDistance"+$2, "updatedAt"=NOW() WHERE id=$3`,
-			charge, distVal, driver.ID)
-		db.Pool.Exec(context.Background(),
-			`UPDATE "user" SET "totalRides"="totalRides"+1, "updatedAt"=NOW() WHERE id=$1`, updated.UserID)
-	}
+		// On acceptance, surface the languages both parties share
+		if body.RideStatus == "Accepted" {
+			languageMatch = rideLanguageMatch(ctx, updated.UserID, driver.ID)
+		}
 
-	// Send FCM notification to the User
-	var userToken *string
-	db.Pool.QueryRow(context.Background(), `SELECT "notificationToken" FROM "user" WHERE id=$1`, updated.UserID).Scan(&userToken)
-	
-	if userToken != nil && *userToken != "" {
+		// Notify the rider
 		title := "Ride Update"
 		msg := "Your ride status has changed."
 		switch body.RideStatus {
 		case "Accepted":
 			title = "Ride Accepted! 🚗"
-			msg = fmt.Sprintf("%s has accepted your request and is on the way.", driver.Name)
-		case "InProgress":
-			title = "Ride Started 🚀"
-			msg = "You are on your way to the destination."
+			msg = fmt.Sprintf("%s has accepted your request and is on the way. Share OTP %s to start your trip.", driver.Name, otp)
 		case "Completed":
 			title = "Ride Completed ✅"
-			msg = fmt.Sprintf("You have reached your destination. Total fare: ₹%.2f", charge)
+			msg = fmt.Sprintf("You have reached your destination. Total fare: %s", formatMoney(updated.Currency, updated.Charge))
 		case "Cancelled":
 			title = "Ride Cancelled ❌"
 			msg = "The driver has cancelled the ride."
 		}
-		
-		go utils.SendPushNotification(*userToken, title, msg, utils.FCMData{
+		data := utils.FCMData{
 			"type":       "ride_status",
 			"rideId":     updated.ID,
 			"status":     body.RideStatus,
 			"driverName": driver.Name,
 			"driverId":   driver.ID,
-		})
+		}
+		if otp != "" {
+			data["otp"] = otp
+		}
+		if languageMatch != nil {
+			data["sharedLanguages"] = strings.Join(languageMatch["sharedLanguages"].([]string), ",")
+		}
+		return queueNotifications(ctx, tx, rideStatusNotifications("user", updated.UserID, updated.ID, title, msg, data)...)
+	})
+	if err != nil {
+		respondTransitionError(c, err, "Failed to update ride")
+		return
+	}
+	kickNotificationOutbox()
+
+	switch body.RideStatus {
+	case "Accepted":
+		assignPoolSiblings(updated.ID, driver.ID)
+	case "Completed":
+		applyRideCompletion(updated.ID, driver.ID)
+		completePoolLeg(updated.ID, legDropoff)
+	case "Cancelled":
+		saveRideTrack(updated.ID, driver.ID)
+		releasePoolSeat(updated.ID)
+		recordRideCancellation(updated.ID, events.ActorDriver, driver.ID, body.CancelReason)
+	}
+
+	resp := gin.H{"updatedRide": updated}
+	if languageMatch != nil {
+		resp["language"] = languageMatch
 	}
-	utils.RespondSuccess(c, http.StatusOK, "Ride status updated", gin.H{"updatedRide": updated})
+	utils.RespondSuccess(c, http.StatusOK, "Ride status updated", resp)
 }
 
-// GET /api/v1/driver/rides
-func GetDriverRides(c *gin.Context) {
+const rideOTPAttemptsKeyPrefix = "rides:otpattempts:"
+const maxRideOTPAttempts = 5
+
+// generateRideOTP returns a 4-digit code the rider reads out to the driver at pickup.
+func generateRideOTP() string {
+	return strconv.Itoa(1000 + rand.Intn(9000))
+}
+
+// PUT /api/v1/driver/ride/start-with-otp
+func StartRideWithOTP(c *gin.Context) {
 	driver := c.MustGet("driver").(*models.Driver)
 
-	rows, err := db.Pool.Query(context.Background(),
-		`SELECT r.id, r."userId", r."driverId", r.charge, r."currentLocationName", r."destinationLocationName", 
-		 r.distance, r.status, r.rating, COALESCE(r."vehicleType",''), COALESCE(r."paymentMode",''),
-		 COALESCE(r."paymentStatus",'Pending'), COALESCE(r.tips, 0), r."createdAt", r."updatedAt",
-		 u.id, u.name, u.phone_number, u.ratings
-		FROM rides r 
-		JOIN "user" u ON r."userId"=u.id 
-		WHERE r."driverId"=$1 ORDER BY r."createdAt" DESC`, driver.ID)
+	if !driver.IsOnline || driver.Status != "active" {
+		respondDriverNotReady(c, driver)
+		return
+	}
+	var body struct {
+		RideID string `json:"rideId" binding:"required"`
+		OTP    string `json:"otp" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid input data", err)
+		return
+	}
+
+	status, rideOTP, err := repos.Rides.StartOTP(c.Request.Context(), body.RideID, driver.ID)
+	if err != nil {
+		utils.RespondErrorCode(c, http.StatusNotFound, utils.CodeRideNotFound, "Ride not found", err)
+		return
+	}
+	if !statemachine.CanTransition(status, statemachine.InProgress) {
+		utils.RespondErrorDetails(c, http.StatusConflict, utils.CodeRideTransition, "Ride cannot be started from status "+status,
+			gin.H{"from": status, "to": statemachine.InProgress}, nil)
+		return
+	}
+
+	// Cap guesses so a 4-digit code can't be brute-forced
+	attemptsKey := rideOTPAttemptsKeyPrefix + body.RideID
+	attempts, _ := db.RedisClient.Incr(c.Request.Context(), attemptsKey).Result()
+	db.RedisClient.Expire(c.Request.Context(), attemptsKey, 30*time.Minute)
+	if attempts > maxRideOTPAttempts {
+		utils.RespondErrorCode(c, http.StatusTooManyRequests, utils.CodeOTPAttempts, "Too many incorrect OTP attempts. Please contact support.", nil)
+		return
+	}
+	if rideOTP == nil || *rideOTP != strings.TrimSpace(body.OTP) {
+		utils.RespondErrorCode(c, http.StatusBadRequest, utils.CodeOTPInvalid, "Incorrect OTP", nil)
+		return
+	}
+
+	var updated *models.Ride
+	waited := false
+	_, err = statemachine.Transition(c.Request.Context(), statemachine.Change{
+		RideID: body.RideID, To: statemachine.InProgress, ActorType: events.ActorDriver, ActorID: driver.ID,
+	}, func(ctx context.Context, tx pgx.Tx, from string) error {
+		var err error
+		updated, err = repos.Rides.Start(ctx, tx, body.RideID, driver.ID)
+		if err != nil {
+			return err
+		}
+		if from == statemachine.Arriving {
+			if err := applyWaitingCharge(ctx, tx, updated.ID); err != nil {
+				return err
+			}
+			waited = true
+		}
+		return queueNotifications(ctx, tx, rideStatusNotifications("user", updated.UserID, updated.ID,
+			"Ride Started 🚀", "You are on your way to the destination.", utils.FCMData{
+				"type":       "ride_status",
+				"rideId":     updated.ID,
+				"status":     "InProgress",
+				"driverName": driver.Name,
+				"driverId":   driver.ID,
+			})...)
+	})
+	if err != nil {
+		respondTransitionError(c, err, "Failed to start ride")
+		return
+	}
+	// The waiting charge was added to the fare after the ride was read
+	if waited {
+		if charge, err := repos.Rides.Charge(c.Request.Context(), updated.ID); err == nil {
+			updated.Charge = charge
+		}
+	}
+	kickNotificationOutbox()
+	db.RedisClient.Del(c.Request.Context(), attemptsKey, ridePickupKeyPrefix+driver.ID)
+	stores.StartRideTrack(c.Request.Context(), driver.ID, updated.ID)
+	completePoolLeg(updated.ID, legPickup)
+	utils.RespondSuccess(c, http.StatusOK, "Ride started", gin.H{"updatedRide": updated})
+}
+
+// settleRideCompletion rolls a completed ride into the driver's and rider's lifetime totals and
+// credits the driver's wallet with the fare net of platform commission, on the transaction that
+// completes the ride, so a ride is never Completed without its driver being paid.
+func settleRideCompletion(ctx context.Context, tx pgx.Tx, rideID, driverID, userID string, charge float64, distance string) error {
+	var distVal float64
+	fmt.Sscanf(distance, "%f", &distVal)
+	if err := repos.Drivers.AddCompletedRide(ctx, tx, driverID, charge, distVal); err != nil {
+		return err
+	}
+	if err := repos.Users.AddCompletedRide(ctx, tx, userID); err != nil {
+		return err
+	}
+
+	commission, err := recordRideCommission(ctx, tx, rideID, driverID, charge)
+	if err != nil {
+		return err
+	}
+	return stores.CreditRideEarning(ctx, tx, driverID, rideID, charge, commission)
+}
+
+// applyRideCompletion runs what follows a settled completion: the driver's incentives, the trip's
+// track and emissions, and the rider's invoice.
+func applyRideCompletion(rideID, driverID string) {
+	utils.SafeGo(func() { trackIncentiveProgress(rideID) })
+	saveRideTrack(rideID, driverID)
+	recordRideEmissions(rideID)
+	utils.SafeGo(func() { issueRideInvoice(rideID) })
+}
+
+// GET /api/v1/driver/rides?page=1&limit=20&status=Completed&from=2026-01-01&to=2026-01-31
+// Also pages by ?cursor=. The summary covers every ride matching the filters, not just this page.
+func GetDriverRides(c *gin.Context) {
+	driver := c.MustGet("driver").(*models.Driver)
+	pg, err := utils.ParsePagination(c)
+	if err != nil {
+		utils.RespondErrorCode(c, http.StatusBadRequest, utils.CodeInvalidCursor, "Invalid cursor", err)
+		return
+	}
+	history, sum, err := repos.Rides.DriverHistory(c.Request.Context(), driver.ID, rideHistoryFilter(c), pg)
 	if err != nil {
 		utils.RespondError(c, http.StatusInternalServerError, "Internal server error", err)
 		return
 	}
-	defer rows.Close()
 
 	type RideWithUser struct {
 		ID                      string       `json:"id"`
@@ -553,28 +734,26 @@ func GetDriverRides(c *gin.Context) {
 		User                    *models.User `json:"user,omitempty"`
 	}
 
-	var rides []RideWithUser
-	for rows.Next() {
-		var r RideWithUser
-		var uID, uName, uPhone string
-		var uRating float64
-		rows.Scan(&r.ID, &r.UserID, &r.DriverID, &r.Charge, &r.CurrentLocationName, &r.DestinationLocationName,
-			&r.Distance, &r.Status, &r.Rating, &r.VehicleType, &r.PaymentMode, &r.PaymentStatus, &r.Tips,
-			&r.CreatedAt, &r.UpdatedAt,
-			&uID, &uName, &uPhone, &uRating)
-		
-		r.User = &models.User{
-			ID:          uID,
-			Name:        &uName,
-			PhoneNumber: uPhone,
-			Ratings:     uRating,
+	rides := make([]RideWithUser, 0, len(history))
+	for _, h := range history {
+		r := RideWithUser{
+			ID: h.ID, UserID: h.UserID, DriverID: h.DriverID, Charge: h.Charge,
+			CurrentLocationName: h.CurrentLocationName, DestinationLocationName: h.DestinationLocationName,
+			Distance: h.Distance, Status: h.Status, Rating: h.Rating, VehicleType: h.VehicleType,
+			PaymentMode: h.PaymentMode, PaymentStatus: h.PaymentStatus, Tips: h.Tips,
+			CreatedAt: h.CreatedAt, UpdatedAt: h.UpdatedAt,
+		}
+		if u, ok := h.User.(*models.User); ok {
+			r.User = &models.User{ID: u.ID, Name: u.Name, PhoneNumber: u.PhoneNumber, Ratings: u.Ratings}
 		}
 		rides = append(rides, r)
 	}
-	if rides == nil {
-		rides = []RideWithUser{}
-	}
-	utils.RespondSuccess(c, http.StatusOK, "Rides retrieved", gin.H{"rides": rides})
+
+	// Earned is what reached the driver: after commission, with tips and incentives
+	rides, resp := utils.Paginate(pg, rides, sum.Rides, func(r RideWithUser) (time.Time, string) { return r.CreatedAt, r.ID })
+	resp["rides"] = rides
+	resp["summary"] = gin.H{"rides": sum.Rides, "completedRides": sum.Completed, "totalEarned": sum.Amount}
+	utils.RespondSuccess(c, http.StatusOK, "Rides retrieved", resp)
 }
 
 // GET /api/v1/driver/ride/:id
@@ -582,31 +761,17 @@ func GetSingleDriverRide(c *gin.Context) {
 	rideID := c.Param("id")
 	driver := c.MustGet("driver").(*models.Driver)
 
-	var ride models.Ride
-	var user models.User
-	err := db.Pool.QueryRow(context.Background(),
-		`SELECT r.id, r."userId", r."driverId", r.charge, r."currentLocationName", r."destinationLocationName", 
-		r.distance, COALESCE(r.polyline, ''), COALESCE(r."estimatedDuration", 0), COALESCE(r."estimatedDistance", 0),
-		COALESCE(r."vehicleType", ''), r.status, r.rating, COALESCE(r."paymentMode", ''), COALESCE(r."paymentStatus", 'Pending'),
-		r."originLat", r."originLng", r."destinationLat", r."destinationLng",
-		r."createdAt", r."updatedAt",
-		u.id, u.name, u.phone_number, u.ratings
-		FROM rides r 
-		JOIN "user" u ON r."userId"=u.id
-		WHERE r.id=$1 AND r."driverId"=$2`, rideID, driver.ID).
-		Scan(&ride.ID, &ride.UserID, &ride.DriverID, &ride.Charge, &ride.CurrentLocationName, &ride.DestinationLocationName,
-			&ride.Distance, &ride.Polyline, &ride.EstimatedDuration, &ride.EstimatedDistance,
-			&ride.VehicleType, &ride.Status, &ride.Rating, &ride.PaymentMode, &ride.PaymentStatus,
-			&ride.OriginLat, &ride.OriginLng, &ride.DestinationLat, &ride.DestinationLng,
-			&ride.CreatedAt, &ride.UpdatedAt,
-			&user.ID, &user.Name, &user.PhoneNumber, &user.Ratings)
+	ride, err := repos.Rides.GetForDriver(c.Request.Context(), rideID, driver.ID)
 	if err != nil {
-		utils.RespondError(c, http.StatusNotFound, "Ride not found", err)
+		utils.RespondErrorCode(c, http.StatusNotFound, utils.CodeRideNotFound, "Ride not found", err)
 		return
 	}
-	ride.User = &user
 	ride.Driver = driver
-	utils.RespondSuccess(c, http.StatusOK, "Ride details", gin.H{"ride": ride})
+	utils.RespondSuccess(c, http.StatusOK, "Ride details", gin.H{
+		"ride":     ride,
+		"language": rideLanguageMatch(c.Request.Context(), ride.UserID, driver.ID),
+		"stops":    loadRideStops(c.Request.Context(), ride.ID),
+	})
 }
 
 // ══════════════════════════════════════════════════
@@ -616,12 +781,20 @@ func GetSingleDriverRide(c *gin.Context) {
 // GET /api/v1/driver/earnings
 func GetEarnings(c *gin.Context) {
 	driver := c.MustGet("driver").(*models.Driver)
+
+	totals, err := repos.Drivers.Earnings(c.Request.Context(), driver.ID)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to fetch earnings", err)
+		return
+	}
 	utils.RespondSuccess(c, http.StatusOK, "Earnings summary", gin.H{
-		"totalEarning":   driver.TotalEarning,
+		"totalEarning":   round2(totals.GrossFare + totals.Tips + totals.Incentives),
+		"breakdown":      totals,
 		"totalRides":     driver.TotalRides,
 		"totalDistance":   driver.TotalDistance,
 		"pendingRides":   driver.PendingRides,
 		"cancelledRides": driver.CancelRides,
+		"acceptance":     driverAcceptanceStats(c.Request.Context(), driver.ID),
 	})
 }
 
@@ -629,28 +802,34 @@ func GetEarnings(c *gin.Context) {
 func GetDailyEarnings(c *gin.Context) {
 	driver := c.MustGet("driver").(*models.Driver)
 
-	rows, err := db.Pool.Query(context.Background(),
-		`SELECT DATE(r."createdAt") as day, COUNT(*) as rides, COALESCE(SUM(r.charge), 0) as earnings
-		FROM rides r 
-		WHERE r."driverId"=$1 AND r.status='Completed' AND r."createdAt" >= NOW() - INTERVAL '7 days'
-		GROUP BY DATE(r."createdAt") ORDER BY day DESC`, driver.ID)
+	days, err := repos.Drivers.EarningsByDay(c.Request.Context(), driver.ID, 7)
 	if err != nil {
 		utils.RespondError(c, http.StatusInternalServerError, "Failed to fetch earnings", err)
 		return
 	}
-	defer rows.Close()
 
+	// Earnings is the fare total, as before the breakdown was added
 	type DayEarning struct {
 		Day      time.Time `json:"day"`
-		Rides    int       `json:"rides"`
 		Earnings float64   `json:"earnings"`
+		repository.EarningsTotals
+		OnlineHours float64 `json:"onlineHours"`
 	}
+	online := driverDailyOnlineHours(c.Request.Context(), driver.ID, 7)
 	var daily []DayEarning
-	for rows.Next() {
-		var d DayEarning
-		rows.Scan(&d.Day, &d.Rides, &d.Earnings)
+	for _, p := range days {
+		d := DayEarning{Day: p.Start, Earnings: p.GrossFare, EarningsTotals: p.EarningsTotals}
+		key := d.Day.Format("2006-01-02")
+		d.OnlineHours = online[key]
+		delete(online, key)
 		daily = append(daily, d)
 	}
+	// Days spent online without completing a ride still count
+	for key, hours := range online {
+		day, _ := time.Parse("2006-01-02", key)
+		daily = append(daily, DayEarning{Day: day, OnlineHours: hours})
+	}
+	sort.Slice(daily, func(i, j int) bool { return daily[i].Day.After(daily[j].Day) })
 	if daily == nil {
 		daily = []DayEarning{}
 	}
@@ -661,30 +840,60 @@ func GetDailyEarnings(c *gin.Context) {
 func GetWeeklyEarnings(c *gin.Context) {
 	driver := c.MustGet("driver").(*models.Driver)
 
-	rows, err := db.Pool.Query(context.Background(),
-		`SELECT DATE_TRUNC('week', r."createdAt") as week, COUNT(*) as rides, COALESCE(SUM(r.charge), 0) as earnings
-		FROM rides r 
-		WHERE r."driverId"=$1 AND r.status='Completed' AND r."createdAt" >= NOW() - INTERVAL '4 weeks'
-		GROUP BY DATE_TRUNC('week', r."createdAt") ORDER BY week DESC`, driver.ID)
+	weeks, err := repos.Drivers.EarningsByWeek(c.Request.Context(), driver.ID, 4)
 	if err != nil {
 		utils.RespondError(c, http.StatusInternalServerError, "Failed to fetch earnings", err)
 		return
 	}
-	defer rows.Close()
 
 	type WeekEarning struct {
 		Week     time.Time `json:"week"`
-		Rides    int       `json:"rides"`
 		Earnings float64   `json:"earnings"`
+		repository.EarningsTotals
 	}
-	var weekly []WeekEarning
-	for rows.Next() {
-		var w WeekEarning
-		rows.Scan(&w.Week, &w.Rides, &w.Earnings)
-		weekly = append(weekly, w)
-	}
-	if weekly == nil {
-		weekly = []WeekEarning{}
+	weekly := make([]WeekEarning, 0, len(weeks))
+	for _, p := range weeks {
+		weekly = append(weekly, WeekEarning{Week: p.Start, Earnings: p.GrossFare, EarningsTotals: p.EarningsTotals})
 	}
 	utils.RespondSuccess(c, http.StatusOK, "Weekly earnings", gin.H{"weekly": weekly})
 }
+
+// ══════════════════════════════════════════════════
+// Driver Wallet
+// ══════════════════════════════════════════════════
+
+// GET /api/v1/driver/wallet
+func GetDriverWallet(c *gin.Context) {
+	driver := c.MustGet("driver").(*models.Driver)
+	wallet, err := stores.GetOrCreateWallet(c.Request.Context(), driver.ID)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to fetch wallet", err)
+		return
+	}
+	utils.RespondSuccess(c, http.StatusOK, "Wallet", gin.H{"wallet": wallet})
+}
+
+// GET /api/v1/driver/wallet/transactions?page=1&limit=20
+func GetDriverWalletTransactions(c *gin.Context) {
+	driver := c.MustGet("driver").(*models.Driver)
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	txns, err := stores.ListWalletTransactions(c.Request.Context(), driver.ID, limit, (page-1)*limit)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to fetch wallet transactions", err)
+		return
+	}
+	utils.RespondSuccess(c, http.StatusOK, "Wallet transactions", gin.H{
+		"transactions": txns,
+		"page":         page,
+		"limit":        limit,
+	})
+}
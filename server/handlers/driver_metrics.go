@@ -0,0 +1,311 @@
+package handlers
+
+import (
+	"context"
+	"math"
+	"os"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+	"ridewave/db"
+	"ridewave/events"
+	"ridewave/utils"
+)
+
+// ══════════════════════════════════════════════════
+// Driver Metrics — nightly performance aggregates & dispatch score
+// ══════════════════════════════════════════════════
+//
+// Once a night every driver's last DRIVER_METRICS_WINDOW_DAYS (default 30) of rides are rolled up
+// into driver_metrics: acceptance, cancellation, completion and on-time arrival rates plus their
+// average rating. The rates are blended into a 0–100 score that dispatch can use to offer rides to
+// the best drivers first (see DISPATCH_SCORE_HEADSTART_SECONDS).
+
+const driverMetricsRunKeyPrefix = "driver_metrics:run:"
+
+// Weights of each rate in the score. A rate with no data is left out and the rest re-weighted.
+const (
+	scoreWeightAcceptance   = 0.25
+	scoreWeightCompletion   = 0.20
+	scoreWeightCancellation = 0.15
+	scoreWeightOnTime       = 0.15
+	scoreWeightRating       = 0.25
+)
+
+func driverMetricsWindowDays() int {
+	if val, err := strconv.Atoi(os.Getenv("DRIVER_METRICS_WINDOW_DAYS")); err == nil && val > 0 {
+		return val
+	}
+	return 30
+}
+
+// driverMetricsHour is the local hour the nightly aggregation runs at (DRIVER_METRICS_HOUR, default 2).
+func driverMetricsHour() int {
+	if val, err := strconv.Atoi(os.Getenv("DRIVER_METRICS_HOUR")); err == nil && val >= 0 && val < 24 {
+		return val
+	}
+	return 2
+}
+
+// onTimeArrivalMinutes is how soon after accepting a driver must reach the pickup to count as on time
+// (ON_TIME_ARRIVAL_MINUTES, default 10).
+func onTimeArrivalMinutes() int {
+	if val, err := strconv.Atoi(os.Getenv("ON_TIME_ARRIVAL_MINUTES")); err == nil && val > 0 {
+		return val
+	}
+	return 10
+}
+
+// driverScoreMinOffers is how many offers a driver needs in the window before they're scored
+// (DRIVER_SCORE_MIN_OFFERS, default 5), so new drivers aren't judged on a couple of rides.
+func driverScoreMinOffers() int {
+	if val, err := strconv.Atoi(os.Getenv("DRIVER_SCORE_MIN_OFFERS")); err == nil && val >= 0 {
+		return val
+	}
+	return 5
+}
+
+// dispatchScoreHeadstart is how long drivers scoring at least dispatchPriorityScore see a request
+// before everyone else (DISPATCH_SCORE_HEADSTART_SECONDS, default 0 = no weighting).
+func dispatchScoreHeadstart() time.Duration {
+	if val, err := strconv.Atoi(os.Getenv("DISPATCH_SCORE_HEADSTART_SECONDS")); err == nil && val > 0 {
+		return time.Duration(val) * time.Second
+	}
+	return 0
+}
+
+// dispatchPriorityScore is the score that earns a driver the dispatch head start (DISPATCH_PRIORITY_SCORE, default 80).
+func dispatchPriorityScore() float64 {
+	if val, err := strconv.ParseFloat(os.Getenv("DISPATCH_PRIORITY_SCORE"), 64); err == nil && val >= 0 {
+		return val
+	}
+	return 80
+}
+
+type driverMetrics struct {
+	WindowDays       int       `json:"windowDays"`
+	OfferedCount     int       `json:"offeredCount"`
+	AcceptedCount    int       `json:"acceptedCount"`
+	CompletedCount   int       `json:"completedCount"`
+	CancelledCount   int       `json:"cancelledCount"`
+	ArrivedCount     int       `json:"arrivedCount"`
+	OnTimeCount      int       `json:"onTimeCount"`
+	RatingCount      int       `json:"ratingCount"`
+	AcceptanceRate   *float64  `json:"acceptanceRate"`
+	CancellationRate *float64  `json:"cancellationRate"`
+	CompletionRate   *float64  `json:"completionRate"`
+	OnTimeRate       *float64  `json:"onTimeRate"`
+	AverageRating    *float64  `json:"averageRating"`
+	Score            *float64  `json:"score"`
+	ComputedAt       time.Time `json:"computedAt"`
+}
+
+const driverMetricsSelectCols = `"windowDays", "offeredCount", "acceptedCount", "completedCount", "cancelledCount",
+	"arrivedCount", "onTimeCount", "ratingCount", "acceptanceRate", "cancellationRate", "completionRate", "onTimeRate",
+	"averageRating", score, "computedAt"`
+
+func scanDriverMetrics(scanner interface{ Scan(dest ...any) error }, m *driverMetrics) error {
+	return scanner.Scan(&m.WindowDays, &m.OfferedCount, &m.AcceptedCount, &m.CompletedCount, &m.CancelledCount,
+		&m.ArrivedCount, &m.OnTimeCount, &m.RatingCount, &m.AcceptanceRate, &m.CancellationRate, &m.CompletionRate, &m.OnTimeRate,
+		&m.AverageRating, &m.Score, &m.ComputedAt)
+}
+
+// loadDriverMetrics returns the driver's latest aggregates, or nil before the first nightly run.
+func loadDriverMetrics(ctx context.Context, driverID string) *driverMetrics {
+	var m driverMetrics
+	err := scanDriverMetrics(db.Pool.QueryRow(ctx,
+		`SELECT `+driverMetricsSelectCols+` FROM driver_metrics WHERE "driverId"=$1`, driverID), &m)
+	if err != nil {
+		return nil
+	}
+	return &m
+}
+
+// StartDriverMetricsWorker recomputes driver metrics once a night at DRIVER_METRICS_HOUR, or sooner
+// when the last run is more than a day old (first deploy, missed nights).
+func StartDriverMetricsWorker(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if driverMetricsDue() {
+					runDriverMetrics()
+				}
+			case <-ctx.Done():
+				utils.Logger.Info("Driver Metrics Worker shutting down...")
+				return
+			}
+		}
+	}()
+}
+
+func driverMetricsDue() bool {
+	if time.Now().Hour() == driverMetricsHour() {
+		return true
+	}
+	var last *time.Time
+	db.Pool.QueryRow(context.Background(), `SELECT MAX("computedAt") FROM driver_metrics`).Scan(&last)
+	return last == nil || time.Since(*last) > 26*time.Hour
+}
+
+// runDriverMetrics aggregates every driver once per day; the per-day Redis key keeps other
+// instances (and later ticks the same night) from repeating the work.
+func runDriverMetrics() {
+	ctx := db.Unscoped()
+	claimed, err := db.RedisClient.SetNX(ctx, driverMetricsRunKeyPrefix+time.Now().Format("2006-01-02"), 1, 25*time.Hour).Result()
+	if err != nil || !claimed {
+		return
+	}
+
+	start := time.Now()
+	n, err := computeDriverMetrics(ctx)
+	if err != nil {
+		utils.Logger.Error("Driver metrics aggregation failed", zap.Error(err))
+		return
+	}
+	utils.Logger.Info("Driver metrics computed", zap.Int("drivers", n), zap.Duration("took", time.Since(start)))
+}
+
+// computeDriverMetrics rolls up the window for every driver and stores the result. Offers are the
+// rides a driver was sent plus any they took directly (bids, pool legs), so acceptance can't exceed 100%.
+func computeDriverMetrics(ctx context.Context) (int, error) {
+	days := driverMetricsWindowDays()
+	rows, err := db.Pool.Query(ctx,
+		`WITH since AS (SELECT NOW() - make_interval(days => $1) AS t),
+		 accepted AS (
+			SELECT r.id, r."driverId", r.status, r."acceptedAt" FROM rides r, since
+			WHERE r."driverId" IS NOT NULL AND r."acceptedAt" >= since.t),
+		 offers AS (
+			SELECT "driverId", COUNT(*) AS n FROM (
+				SELECT e.data->>'driverId' AS "driverId", e."rideId" FROM ride_events e, since
+				WHERE e.type=$3 AND e."createdAt" >= since.t
+				UNION SELECT "driverId", id FROM accepted) o
+			GROUP BY 1),
+		 outcomes AS (
+			SELECT "driverId", COUNT(*) AS accepted, COUNT(*) FILTER (WHERE status='Completed') AS completed,
+			 COUNT(*) FILTER (WHERE status IN ('Completed', 'Cancelled')) AS finished
+			FROM accepted GROUP BY 1),
+		 cancels AS (
+			SELECT "actorId" AS "driverId", COUNT(*) AS n FROM ride_cancellations, since
+			WHERE actor='driver' AND "afterAccept" AND "createdAt" >= since.t GROUP BY 1),
+		 arrivals AS (
+			SELECT a."driverId", COUNT(*) AS arrived,
+			 COUNT(*) FILTER (WHERE e.at - a."acceptedAt" <= make_interval(mins => $2)) AS "onTime"
+			FROM accepted a JOIN (
+				SELECT "rideId", MIN(ride_events."createdAt") AS at FROM ride_events, since
+				WHERE type=$4 AND ride_events."createdAt" >= since.t GROUP BY 1) e ON e."rideId"=a.id
+			GROUP BY 1),
+		 ratings AS (
+			SELECT "driverId", COUNT(*) AS n, AVG(rating) AS avg FROM ride_reviews, since
+			WHERE "createdAt" >= since.t GROUP BY 1)
+		 SELECT d.id, COALESCE(o.n, 0), COALESCE(oc.accepted, 0), COALESCE(oc.completed, 0), COALESCE(oc.finished, 0),
+		  COALESCE(c.n, 0), COALESCE(ar.arrived, 0), COALESCE(ar."onTime", 0), COALESCE(rt.n, 0), COALESCE(rt.avg, 0)
+		 FROM driver d
+		 LEFT JOIN offers o ON o."driverId"=d.id
+		 LEFT JOIN outcomes oc ON oc."driverId"=d.id
+		 LEFT JOIN cancels c ON c."driverId"=d.id
+		 LEFT JOIN arrivals ar ON ar."driverId"=d.id
+		 LEFT JOIN ratings rt ON rt."driverId"=d.id
+		 WHERE d.status<>'deleted'`,
+		days, onTimeArrivalMinutes(), events.RideOffered, events.DriverArrived)
+	if err != nil {
+		return 0, err
+	}
+
+	type driverRow struct {
+		id       string
+		finished int
+		m        driverMetrics
+	}
+	var drivers []driverRow
+	for rows.Next() {
+		var r driverRow
+		var avgRating float64
+		err := rows.Scan(&r.id, &r.m.OfferedCount, &r.m.AcceptedCount, &r.m.CompletedCount, &r.finished,
+			&r.m.CancelledCount, &r.m.ArrivedCount, &r.m.OnTimeCount, &r.m.RatingCount, &avgRating)
+		if err != nil {
+			continue
+		}
+		r.m.WindowDays = days
+		r.m.AcceptanceRate = percentOf(r.m.AcceptedCount, r.m.OfferedCount)
+		r.m.CancellationRate = percentOf(r.m.CancelledCount, r.m.AcceptedCount)
+		r.m.CompletionRate = percentOf(r.m.CompletedCount, r.finished)
+		r.m.OnTimeRate = percentOf(r.m.OnTimeCount, r.m.ArrivedCount)
+		if r.m.RatingCount > 0 {
+			avg := math.Round(avgRating*100) / 100
+			r.m.AverageRating = &avg
+		}
+		if r.m.OfferedCount >= driverScoreMinOffers() {
+			r.m.Score = driverScore(r.m)
+		}
+		drivers = append(drivers, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, r := range drivers {
+		m := r.m
+		_, err := db.Pool.Exec(ctx,
+			`INSERT INTO driver_metrics ("driverId", "windowDays", "offeredCount", "acceptedCount", "completedCount", "cancelledCount",
+			 "arrivedCount", "onTimeCount", "ratingCount", "acceptanceRate", "cancellationRate", "completionRate", "onTimeRate",
+			 "averageRating", score, "computedAt")
+			 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, NOW())
+			 ON CONFLICT ("driverId") DO UPDATE SET "windowDays"=EXCLUDED."windowDays", "offeredCount"=EXCLUDED."offeredCount",
+			 "acceptedCount"=EXCLUDED."acceptedCount", "completedCount"=EXCLUDED."completedCount",
+			 "cancelledCount"=EXCLUDED."cancelledCount", "arrivedCount"=EXCLUDED."arrivedCount", "onTimeCount"=EXCLUDED."onTimeCount",
+			 "ratingCount"=EXCLUDED."ratingCount", "acceptanceRate"=EXCLUDED."acceptanceRate",
+			 "cancellationRate"=EXCLUDED."cancellationRate", "completionRate"=EXCLUDED."completionRate",
+			 "onTimeRate"=EXCLUDED."onTimeRate", "averageRating"=EXCLUDED."averageRating", score=EXCLUDED.score, "computedAt"=NOW()`,
+			r.id, m.WindowDays, m.OfferedCount, m.AcceptedCount, m.CompletedCount, m.CancelledCount,
+			m.ArrivedCount, m.OnTimeCount, m.RatingCount, m.AcceptanceRate, m.CancellationRate, m.CompletionRate, m.OnTimeRate,
+			m.AverageRating, m.Score)
+		if err != nil {
+			utils.Logger.Warn("Failed to store driver metrics", zap.String("driverId", r.id), zap.Error(err))
+		}
+	}
+	return len(drivers), nil
+}
+
+// percentOf is n/total as a percentage to one decimal, or nil when total is zero.
+func percentOf(n, total int) *float64 {
+	if total <= 0 {
+		return nil
+	}
+	pct := math.Round(float64(n)/float64(total)*1000) / 10
+	return &pct
+}
+
+// driverScore blends the rates into 0–100; cancellations count against the driver.
+func driverScore(m driverMetrics) *float64 {
+	var sum, weights float64
+	add := func(value *float64, weight float64, invert bool) {
+		if value == nil {
+			return
+		}
+		v := *value
+		if invert {
+			v = 100 - v
+		}
+		sum += v * weight
+		weights += weight
+	}
+	add(m.AcceptanceRate, scoreWeightAcceptance, false)
+	add(m.CompletionRate, scoreWeightCompletion, false)
+	add(m.CancellationRate, scoreWeightCancellation, true)
+	add(m.OnTimeRate, scoreWeightOnTime, false)
+	if m.AverageRating != nil {
+		rating := *m.AverageRating / 5 * 100
+		add(&rating, scoreWeightRating, false)
+	}
+	if weights == 0 {
+		return nil
+	}
+	score := math.Round(sum/weights*10) / 10
+	return &score
+}
@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"ridewave/db"
+	"ridewave/models"
+	"ridewave/stores"
+	"ridewave/utils"
+)
+
+// ══════════════════════════════════════════════════
+// Driver Preferences — destination mode & preferred drop-off zone
+// ══════════════════════════════════════════════════
+//
+// A driver heading home sets a destination, and dispatch then only offers them trips that end
+// closer to it and leave in roughly its direction. Destination mode switches itself off after a
+// while so it can't be used to cherry-pick all day. A preferred zone limits offers to trips
+// that drop off inside that service zone.
+
+// driverDestinationModeHours is how long destination mode stays on (DRIVER_DESTINATION_MODE_HOURS, default 2).
+func driverDestinationModeHours() int {
+	if val, err := strconv.Atoi(os.Getenv("DRIVER_DESTINATION_MODE_HOURS")); err == nil && val > 0 {
+		return val
+	}
+	return 2
+}
+
+// driverDestinationMaxAngle is how far, in degrees, a trip's direction may stray from the
+// direction of the driver's destination (DRIVER_DESTINATION_MAX_ANGLE, default 45).
+func driverDestinationMaxAngle() float64 {
+	if val, err := strconv.ParseFloat(os.Getenv("DRIVER_DESTINATION_MAX_ANGLE"), 64); err == nil && val > 0 && val <= 180 {
+		return val
+	}
+	return 45
+}
+
+// tripDestination is where a driver in destination mode is heading.
+type tripDestination struct {
+	Lat       float64   `json:"lat"`
+	Lng       float64   `json:"lng"`
+	Name      string    `json:"name"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// driverPreferences filter which trips a driver is offered. Nil fields are off.
+type driverPreferences struct {
+	Destination   *tripDestination `json:"destination"`
+	PreferredZone *string          `json:"preferredZone"`
+	UpdatedAt     *time.Time       `json:"updatedAt"`
+}
+
+const driverPreferencesSelectCols = `"driverId", "destinationLat", "destinationLng", COALESCE("destinationName", ''),
+	"destinationExpiresAt", "preferredZone", "updatedAt"`
+
+// scanDriverPreferences reads a driver_preferences row, leaving out an expired destination.
+func scanDriverPreferences(scanner interface{ Scan(dest ...any) error }, driverID *string, p *driverPreferences) error {
+	var lat, lng *float64
+	var name string
+	var expiresAt *time.Time
+	if err := scanner.Scan(driverID, &lat, &lng, &name, &expiresAt, &p.PreferredZone, &p.UpdatedAt); err != nil {
+		return err
+	}
+	if lat != nil && lng != nil && expiresAt != nil && expiresAt.After(time.Now()) {
+		p.Destination = &tripDestination{Lat: *lat, Lng: *lng, Name: name, ExpiresAt: *expiresAt}
+	}
+	return nil
+}
+
+// suits reports whether a trip from origin to drop-off fits the driver's preferences: inside the
+// preferred zone, and ending closer to their destination in roughly its direction.
+func (p driverPreferences) suits(originLat, originLng, dropLat, dropLng float64) bool {
+	if p.PreferredZone != nil {
+		zone := findServiceZone(*p.PreferredZone)
+		if zone != nil && !zoneContains(*zone, dropLat, dropLng) {
+			return false
+		}
+	}
+	if d := p.Destination; d != nil {
+		if utils.CalculateDistance(dropLat, dropLng, d.Lat, d.Lng) >= utils.CalculateDistance(originLat, originLng, d.Lat, d.Lng) {
+			return false
+		}
+		heading := utils.Bearing(originLat, originLng, d.Lat, d.Lng)
+		if utils.BearingDiff(utils.Bearing(originLat, originLng, dropLat, dropLng), heading) > driverDestinationMaxAngle() {
+			return false
+		}
+	}
+	return true
+}
+
+// tripPreferenceMisses returns the drivers among driverIDs whose preferences the trip doesn't suit.
+func tripPreferenceMisses(ctx context.Context, driverIDs []string, trip *stores.CachedRoute) map[string]bool {
+	misses := map[string]bool{}
+	if len(driverIDs) == 0 {
+		return misses
+	}
+	rows, err := db.Pool.Query(ctx,
+		`SELECT `+driverPreferencesSelectCols+` FROM driver_preferences WHERE "driverId"=ANY($1)`, driverIDs)
+	if err != nil {
+		return misses
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var driverID string
+		var p driverPreferences
+		if scanDriverPreferences(rows, &driverID, &p) != nil {
+			continue
+		}
+		if !p.suits(trip.OriginLat, trip.OriginLng, trip.DestinationLat, trip.DestinationLng) {
+			misses[driverID] = true
+		}
+	}
+	return misses
+}
+
+// loadDriverPreferences returns the driver's preferences, all off if they've never set any.
+func loadDriverPreferences(ctx context.Context, driverID string) driverPreferences {
+	var p driverPreferences
+	var id string
+	scanDriverPreferences(db.Pool.QueryRow(ctx,
+		`SELECT `+driverPreferencesSelectCols+` FROM driver_preferences WHERE "driverId"=$1`, driverID), &id, &p)
+	return p
+}
+
+// GET /api/v1/driver/preferences
+func GetDriverPreferences(c *gin.Context) {
+	driver := c.MustGet("driver").(*models.Driver)
+	utils.RespondSuccess(c, http.StatusOK, "Driver preferences", gin.H{
+		"preferences":          loadDriverPreferences(c.Request.Context(), driver.ID),
+		"destinationModeHours": driverDestinationModeHours(),
+	})
+}
+
+// PUT /api/v1/driver/preferences — {destination: {lat, lng, name}, preferredZone: "Airport"}.
+// Replaces both: leave one out (or null) to switch it off. Setting a destination restarts its timer.
+func UpdateDriverPreferences(c *gin.Context) {
+	driver := c.MustGet("driver").(*models.Driver)
+	var body struct {
+		Destination *struct {
+			Lat  float64 `json:"lat"`
+			Lng  float64 `json:"lng"`
+			Name string  `json:"name"`
+		} `json:"destination"`
+		PreferredZone *string `json:"preferredZone"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid input data", err)
+		return
+	}
+
+	var lat, lng *float64
+	var name *string
+	if d := body.Destination; d != nil {
+		if d.Lat < -90 || d.Lat > 90 || d.Lng < -180 || d.Lng > 180 || (d.Lat == 0 && d.Lng == 0) {
+			utils.RespondError(c, http.StatusBadRequest, "destination needs a valid lat and lng", nil)
+			return
+		}
+		lat, lng, name = &d.Lat, &d.Lng, &d.Name
+	}
+	var zone *string
+	if body.PreferredZone != nil && strings.TrimSpace(*body.PreferredZone) != "" {
+		z := findServiceZone(strings.TrimSpace(*body.PreferredZone))
+		if z == nil {
+			utils.RespondError(c, http.StatusBadRequest, "Unknown service zone "+*body.PreferredZone, nil)
+			return
+		}
+		zone = &z.Name
+	}
+
+	_, err := db.Pool.Exec(c.Request.Context(),
+		`INSERT INTO driver_preferences ("driverId", "destinationLat", "destinationLng", "destinationName", "destinationExpiresAt", "preferredZone", "updatedAt")
+		 VALUES ($1, $2, $3, NULLIF($4, ''), CASE WHEN $2::float8 IS NULL THEN NULL ELSE NOW() + make_interval(hours => $5) END, $6, NOW())
+		 ON CONFLICT ("driverId") DO UPDATE SET "destinationLat"=EXCLUDED."destinationLat", "destinationLng"=EXCLUDED."destinationLng",
+		 "destinationName"=EXCLUDED."destinationName", "destinationExpiresAt"=EXCLUDED."destinationExpiresAt",
+		 "preferredZone"=EXCLUDED."preferredZone", "updatedAt"=NOW()`,
+		driver.ID, lat, lng, name, driverDestinationModeHours(), zone)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to update preferences", err)
+		return
+	}
+	utils.RespondSuccess(c, http.StatusOK, "Preferences updated", gin.H{"preferences": loadDriverPreferences(c.Request.Context(), driver.ID)})
+}
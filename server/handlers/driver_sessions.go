@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"ridewave/db"
+	"ridewave/models"
+	"ridewave/utils"
+)
+
+// ══════════════════════════════════════════════════
+// Driver Sessions — online hours and utilization
+// ══════════════════════════════════════════════════
+//
+// driver_sessions rows are opened and closed by a trigger whenever driver."isOnline" flips,
+// so every path that takes a driver offline is covered. Spans are clipped to the requested
+// range; an open session counts up to now.
+
+type driverSession struct {
+	ID              string     `json:"id"`
+	StartedAt       time.Time  `json:"startedAt"`
+	EndedAt         *time.Time `json:"endedAt"`
+	DurationMinutes int        `json:"durationMinutes"`
+}
+
+// driverOnlineSeconds sums online time in [from, to). An empty driverID covers every driver.
+func driverOnlineSeconds(ctx context.Context, driverID string, from, to time.Time) float64 {
+	var seconds float64
+	db.Pool.QueryRow(ctx,
+		`SELECT COALESCE(SUM(EXTRACT(EPOCH FROM LEAST(COALESCE("endedAt", NOW()), $3) - GREATEST("startedAt", $2))), 0)
+		 FROM driver_sessions
+		 WHERE ($1='' OR "driverId"=$1) AND "startedAt" < $3 AND COALESCE("endedAt", NOW()) > $2`,
+		driverID, from, to).Scan(&seconds)
+	return seconds
+}
+
+// driverOnRideSeconds sums time from accepting a ride until it completed or was cancelled, in [from, to).
+func driverOnRideSeconds(ctx context.Context, driverID string, from, to time.Time) float64 {
+	var seconds float64
+	db.Pool.QueryRow(ctx,
+		`SELECT COALESCE(SUM(EXTRACT(EPOCH FROM LEAST(COALESCE("completedAt", "cancelledAt", NOW()), $3) - GREATEST("acceptedAt", $2))), 0)
+		 FROM rides
+		 WHERE ($1='' OR "driverId"=$1) AND "driverId" IS NOT NULL AND "acceptedAt" IS NOT NULL
+		   AND "acceptedAt" < $3 AND COALESCE("completedAt", "cancelledAt", NOW()) > $2`,
+		driverID, from, to).Scan(&seconds)
+	return seconds
+}
+
+// driverUtilization is online hours, hours spent on rides and the share of online time on a ride.
+// Pool legs overlap, so the percentage is capped at 100.
+func driverUtilization(ctx context.Context, driverID string, from, to time.Time) gin.H {
+	online := driverOnlineSeconds(ctx, driverID, from, to)
+	onRide := driverOnRideSeconds(ctx, driverID, from, to)
+	var utilization *float64
+	if online > 0 {
+		pct := math.Min(100, math.Round(onRide/online*1000)/10)
+		utilization = &pct
+	}
+	return gin.H{
+		"from":               from,
+		"to":                 to,
+		"onlineHours":        math.Round(online/36) / 100,
+		"onRideHours":        math.Round(onRide/36) / 100,
+		"utilizationPercent": utilization,
+	}
+}
+
+// driverDailyOnlineHours is online hours per calendar day for the last `days` days, keyed YYYY-MM-DD.
+func driverDailyOnlineHours(ctx context.Context, driverID string, days int) map[string]float64 {
+	hours := map[string]float64{}
+	rows, err := db.Pool.Query(ctx,
+		`SELECT d::date, SUM(EXTRACT(EPOCH FROM LEAST(COALESCE(s."endedAt", NOW()), d + INTERVAL '1 day') - GREATEST(s."startedAt", d)))
+		 FROM generate_series(date_trunc('day', NOW()) - make_interval(days => $2 - 1), date_trunc('day', NOW()), INTERVAL '1 day') d
+		 JOIN driver_sessions s ON s."driverId"=$1 AND s."startedAt" < d + INTERVAL '1 day' AND COALESCE(s."endedAt", NOW()) > d
+		 GROUP BY d`, driverID, days)
+	if err != nil {
+		return hours
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var day time.Time
+		var seconds float64
+		if rows.Scan(&day, &seconds) == nil {
+			hours[day.Format("2006-01-02")] = math.Round(seconds/36) / 100
+		}
+	}
+	return hours
+}
+
+// GET /api/v1/driver/sessions?from=YYYY-MM-DD&to=YYYY-MM-DD (default: the last 7 days)
+func GetDriverSessions(c *gin.Context) {
+	driver := c.MustGet("driver").(*models.Driver)
+	ctx := c.Request.Context()
+
+	today := time.Now().Format("2006-01-02")
+	from, to, err := parseExportRange(
+		c.DefaultQuery("from", time.Now().AddDate(0, 0, -6).Format("2006-01-02")), c.DefaultQuery("to", today))
+	if err != nil {
+		utils.RespondError(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+	if to.Sub(from) > 92*24*time.Hour {
+		utils.RespondError(c, http.StatusBadRequest, "Range can be at most 92 days", nil)
+		return
+	}
+
+	rows, err := db.Pool.Query(ctx,
+		`SELECT id, "startedAt", "endedAt", EXTRACT(EPOCH FROM COALESCE("endedAt", NOW()) - "startedAt")::int / 60
+		 FROM driver_sessions
+		 WHERE "driverId"=$1 AND "startedAt" < $3 AND COALESCE("endedAt", NOW()) > $2
+		 ORDER BY "startedAt" DESC LIMIT 500`, driver.ID, from, to)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to fetch sessions", err)
+		return
+	}
+	defer rows.Close()
+
+	sessions := []driverSession{}
+	for rows.Next() {
+		var s driverSession
+		if rows.Scan(&s.ID, &s.StartedAt, &s.EndedAt, &s.DurationMinutes) == nil {
+			sessions = append(sessions, s)
+		}
+	}
+
+	utils.RespondSuccess(c, http.StatusOK, "Driver sessions", gin.H{
+		"sessions": sessions,
+		"summary":  driverUtilization(ctx, driver.ID, from, to),
+	})
+}
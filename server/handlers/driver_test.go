@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"ridewave/models"
+	"ridewave/repository"
+	"ridewave/repository/mock"
+	"ridewave/utils"
+)
+
+// seedDriverRides gives online driver d1 a completed ride for rider u1, then a ride request an
+// hour later, and gives another driver a completed ride.
+func seedDriverRides(r repository.Repos) *models.Driver {
+	driver := &models.Driver{ID: "d1", Name: "Ravi", Status: "active", IsOnline: true}
+	r.Drivers.(*mock.Drivers).ByID[driver.ID] = driver
+
+	t0 := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	rider := &models.User{ID: "u1", Name: strPtr("Meera"), PhoneNumber: "+919800000001", Ratings: 4.8,
+		NotificationToken: strPtr("rider-token")}
+	rides := r.Rides.(*mock.Rides).ByID
+	rides["r1"] = &models.Ride{ID: "r1", UserID: "u1", DriverID: strPtr("d1"), User: rider, Status: "Completed",
+		Charge: 150, Tips: 20, CreatedAt: t0}
+	rides["r2"] = &models.Ride{ID: "r2", UserID: "u1", DriverID: strPtr("d1"), User: rider, Status: "Requested",
+		Charge: 90, CurrentLocationName: "MG Road", DestinationLocationName: "Indiranagar",
+		OriginLat: floatPtr(12.975), OriginLng: floatPtr(77.606), DestinationLat: floatPtr(12.978), DestinationLng: floatPtr(77.64),
+		CreatedAt: t0.Add(time.Hour)}
+	rides["r3"] = &models.Ride{ID: "r3", UserID: "u2", DriverID: strPtr("d2"), Status: "Completed", Charge: 300, CreatedAt: t0}
+	return driver
+}
+
+func TestGetIncomingRide(t *testing.T) {
+	r := useMockRepos(t)
+	driver := seedDriverRides(r)
+
+	resp := serve(t, GetIncomingRide, http.MethodGet, "/api/v1/driver/incoming-ride", nil, driver)
+	var data struct {
+		Ride *models.Ride `json:"ride"`
+	}
+	resp.decode(t, &data)
+	if data.Ride == nil || data.Ride.ID != "r2" {
+		t.Fatalf("ride = %+v, want the request r2", data.Ride)
+	}
+	if data.Ride.User == nil {
+		t.Error("incoming ride has no rider")
+	}
+
+	// Offline drivers aren't shown requests
+	offline := *driver
+	offline.IsOnline = false
+	resp = serve(t, GetIncomingRide, http.MethodGet, "/api/v1/driver/incoming-ride", nil, &offline)
+	var off struct {
+		Ride     *models.Ride `json:"ride"`
+		IsOnline *bool        `json:"isOnline"`
+	}
+	resp.decode(t, &off)
+	if off.Ride != nil || off.IsOnline == nil || *off.IsOnline {
+		t.Errorf("offline driver got %+v, want no ride and isOnline false", off)
+	}
+}
+
+func TestGetIncomingRideNone(t *testing.T) {
+	r := useMockRepos(t)
+	driver := seedDriverRides(r)
+	delete(r.Rides.(*mock.Rides).ByID, "r2")
+
+	resp := serve(t, GetIncomingRide, http.MethodGet, "/api/v1/driver/incoming-ride", nil, driver)
+	if resp.Status != http.StatusOK || resp.Message != "No incoming ride" {
+		t.Errorf("got %d %q, want 200 No incoming ride", resp.Status, resp.Message)
+	}
+}
+
+func TestGetUserLocationForDriver(t *testing.T) {
+	r := useMockRepos(t)
+	driver := seedDriverRides(r)
+
+	resp := serve(t, GetUserLocationForDriver, http.MethodGet, "/api/v1/driver/ride/r2/user-location", nil, driver,
+		gin.Param{Key: "id", Value: "r2"})
+	var loc struct {
+		OriginLat       float64 `json:"originLat"`
+		DestinationLng  float64 `json:"destinationLng"`
+		OriginName      string  `json:"originName"`
+		DestinationName string  `json:"destinationName"`
+	}
+	resp.decode(t, &loc)
+	if loc.OriginLat != 12.975 || loc.DestinationLng != 77.64 || loc.OriginName != "MG Road" || loc.DestinationName != "Indiranagar" {
+		t.Errorf("location = %+v", loc)
+	}
+
+	// Another driver's ride looks the same as a missing one
+	resp = serve(t, GetUserLocationForDriver, http.MethodGet, "/api/v1/driver/ride/r3/user-location", nil, driver,
+		gin.Param{Key: "id", Value: "r3"})
+	if resp.Status != http.StatusNotFound || resp.Code != utils.CodeRideNotFound {
+		t.Errorf("got %d %s, want 404 %s", resp.Status, resp.Code, utils.CodeRideNotFound)
+	}
+}
+
+func TestGetDriverRides(t *testing.T) {
+	r := useMockRepos(t)
+	driver := seedDriverRides(r)
+
+	resp := serve(t, GetDriverRides, http.MethodGet, "/api/v1/driver/rides?cursor=&limit=1", nil, driver)
+	if resp.Status != http.StatusOK {
+		t.Fatalf("status = %d, want 200 (%s)", resp.Status, resp.Message)
+	}
+	var data struct {
+		Rides []struct {
+			ID   string       `json:"id"`
+			User *models.User `json:"user"`
+		} `json:"rides"`
+		HasMore    bool   `json:"hasMore"`
+		NextCursor string `json:"nextCursor"`
+		Summary    struct {
+			Rides          int     `json:"rides"`
+			CompletedRides int     `json:"completedRides"`
+			TotalEarned    float64 `json:"totalEarned"`
+		} `json:"summary"`
+	}
+	resp.decode(t, &data)
+
+	if len(data.Rides) != 1 || data.Rides[0].ID != "r2" {
+		t.Fatalf("rides = %+v, want only the newest ride r2", data.Rides)
+	}
+	if !data.HasMore || data.NextCursor == "" {
+		t.Errorf("hasMore = %v, nextCursor = %q, want another page", data.HasMore, data.NextCursor)
+	}
+	if s := data.Summary; s.Rides != 2 || s.CompletedRides != 1 || s.TotalEarned != 170 {
+		t.Errorf("summary = %+v, want 2 rides, 1 completed, 170 earned", s)
+	}
+	// Drivers see who they drove, not the rider's push token
+	u := data.Rides[0].User
+	if u == nil || u.ID != "u1" || u.PhoneNumber != "+919800000001" || u.NotificationToken != nil {
+		t.Errorf("user = %+v, want u1 with only the public fields", u)
+	}
+}
+
+func TestGetDriversById(t *testing.T) {
+	r := useMockRepos(t)
+	seedDriverRides(r)
+	r.Drivers.(*mock.Drivers).ByID["d2"] = &models.Driver{ID: "d2", Name: "Kiran"}
+
+	resp := serve(t, GetDriversById, http.MethodGet, "/api/v1/driver/list?ids=d1,d2,d9", nil, nil)
+	var data struct {
+		Drivers []models.Driver `json:"drivers"`
+	}
+	resp.decode(t, &data)
+	if len(data.Drivers) != 2 {
+		t.Errorf("drivers = %+v, want d1 and d2", data.Drivers)
+	}
+
+	resp = serve(t, GetDriversById, http.MethodGet, "/api/v1/driver/list", nil, nil)
+	if resp.Status != http.StatusBadRequest {
+		t.Errorf("status = %d without ids, want 400", resp.Status)
+	}
+}
+
+func TestGetWeeklyEarnings(t *testing.T) {
+	r := useMockRepos(t)
+	driver := seedDriverRides(r)
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	r.Drivers.(*mock.Drivers).Earned["d1"] = []repository.PeriodEarnings{
+		{Start: today, EarningsTotals: repository.EarningsTotals{Rides: 2, GrossFare: 300, Commission: 30, Net: 270}},
+		{Start: today.AddDate(0, 0, -7), EarningsTotals: repository.EarningsTotals{Rides: 1, GrossFare: 100, Tips: 10, Net: 100}},
+		{Start: today.AddDate(0, 0, -60), EarningsTotals: repository.EarningsTotals{Rides: 5, GrossFare: 900, Net: 800}},
+	}
+
+	resp := serve(t, GetWeeklyEarnings, http.MethodGet, "/api/v1/driver/earnings/weekly", nil, driver)
+	var data struct {
+		Weekly []struct {
+			Week     time.Time `json:"week"`
+			Earnings float64   `json:"earnings"`
+			repository.EarningsTotals
+		} `json:"weekly"`
+	}
+	resp.decode(t, &data)
+
+	// Two weeks from the last four, newest first; earnings is the fare total
+	if len(data.Weekly) != 2 {
+		t.Fatalf("weekly = %+v, want two weeks", data.Weekly)
+	}
+	if w := data.Weekly[0]; !w.Week.After(data.Weekly[1].Week) || w.Earnings != 300 || w.Rides != 2 || w.Net != 270 {
+		t.Errorf("latest week = %+v", w)
+	}
+	if w := data.Weekly[1]; w.Earnings != 100 || w.Tips != 10 {
+		t.Errorf("earlier week = %+v", w)
+	}
+}
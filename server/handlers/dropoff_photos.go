@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"ridewave/db"
+	"ridewave/models"
+	"ridewave/utils"
+)
+
+// ══════════════════════════════════════════════════
+// Drop-off Photos — optional proof of drop-off, GPS-checked for dispute review
+// ══════════════════════════════════════════════════
+
+const maxDropoffPhotoBytes = 5 * 1024 * 1024
+
+// dropoffPhotoWindow is how long after completion a driver can still attach a photo.
+const dropoffPhotoWindow = 24 * time.Hour
+
+// dropoffPhotoTypes are the accepted formats (sniffed). Only JPEGs carry EXIF we can check.
+var dropoffPhotoTypes = map[string]bool{"image/jpeg": true, "image/png": true, "image/webp": true}
+
+const (
+	dropoffGPSMatch    = "match"
+	dropoffGPSMismatch = "mismatch"
+	dropoffGPSMissing  = "no_gps"
+)
+
+// dropoffPhotoMaxDistance is how far (meters) the photo's GPS may be from the drop-off
+// and still count as a match (DROPOFF_PHOTO_MAX_DISTANCE_METERS, default 250).
+func dropoffPhotoMaxDistance() float64 {
+	if val, err := strconv.ParseFloat(os.Getenv("DROPOFF_PHOTO_MAX_DISTANCE_METERS"), 64); err == nil && val > 0 {
+		return val
+	}
+	return 250
+}
+
+// POST /api/v1/driver/ride/:id/dropoff-photo — multipart "photo" (JPEG, PNG or WebP, max 5MB), optional "note"
+// Uploading again replaces the earlier photo.
+func UploadDropoffPhoto(c *gin.Context) {
+	driver := c.MustGet("driver").(*models.Driver)
+	rideID := c.Param("id")
+	ctx := c.Request.Context()
+
+	var status string
+	var completedAt *time.Time
+	var destLat, destLng *float64
+	err := db.Pool.QueryRow(ctx,
+		`SELECT status, "completedAt", "destinationLat", "destinationLng" FROM rides WHERE id=$1 AND "driverId"=$2`,
+		rideID, driver.ID).Scan(&status, &completedAt, &destLat, &destLng)
+	if err != nil {
+		utils.RespondErrorCode(c, http.StatusNotFound, utils.CodeRideNotFound, "Ride not found", err)
+		return
+	}
+	if status != "InProgress" && status != "Completed" {
+		utils.RespondError(c, http.StatusConflict, "A drop-off photo can only be added to a ride in progress or completed", nil)
+		return
+	}
+	if completedAt != nil && time.Since(*completedAt) > dropoffPhotoWindow {
+		utils.RespondError(c, http.StatusConflict, "The drop-off photo window for this ride has closed", nil)
+		return
+	}
+
+	file, _, err := c.Request.FormFile("photo")
+	if err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "photo file is required", err)
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxDropoffPhotoBytes+1))
+	if err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Failed to read photo", err)
+		return
+	}
+	if len(data) > maxDropoffPhotoBytes {
+		utils.RespondError(c, http.StatusRequestEntityTooLarge, "Photo must be 5MB or smaller", nil)
+		return
+	}
+	contentType := http.DetectContentType(data)
+	if !dropoffPhotoTypes[contentType] {
+		utils.RespondError(c, http.StatusUnsupportedMediaType, "Photo must be a JPEG, PNG or WebP image", nil)
+		return
+	}
+
+	// Cross-check where the camera says the photo was taken against the drop-off
+	var meta utils.PhotoMetadata
+	if contentType == "image/jpeg" {
+		if m, err := utils.ReadJPEGExif(data); err == nil {
+			meta = *m
+		}
+	}
+	gpsCheck := dropoffGPSMissing
+	var distance *int
+	if meta.Lat != nil && destLat != nil && destLng != nil {
+		meters := int(math.Round(utils.CalculateDistance(*meta.Lat, *meta.Lng, *destLat, *destLng) * 1000))
+		distance = &meters
+		gpsCheck = dropoffGPSMatch
+		if float64(meters) > dropoffPhotoMaxDistance() {
+			gpsCheck = dropoffGPSMismatch
+		}
+	}
+
+	_, err = db.Pool.Exec(ctx,
+		`INSERT INTO ride_dropoff_photos ("rideId", "driverId", "contentType", data, note, "photoLat", "photoLng", "takenAt", "distanceMeters", "gpsCheck")
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		 ON CONFLICT ("rideId") DO UPDATE SET "contentType"=EXCLUDED."contentType", data=EXCLUDED.data, note=EXCLUDED.note,
+		   "photoLat"=EXCLUDED."photoLat", "photoLng"=EXCLUDED."photoLng", "takenAt"=EXCLUDED."takenAt",
+		   "distanceMeters"=EXCLUDED."distanceMeters", "gpsCheck"=EXCLUDED."gpsCheck", "createdAt"=NOW()`,
+		rideID, driver.ID, contentType, data, strings.TrimSpace(c.PostForm("note")), meta.Lat, meta.Lng, meta.TakenAt, distance, gpsCheck)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to save photo", err)
+		return
+	}
+
+	utils.RespondSuccess(c, http.StatusOK, "Drop-off photo saved", gin.H{
+		"rideId":         rideID,
+		"gpsCheck":       gpsCheck,
+		"distanceMeters": distance,
+	})
+}
+
+// dropoffPhotoSummary describes a ride's drop-off photo for the admin ride detail, or nil.
+func dropoffPhotoSummary(ctx context.Context, rideID string) gin.H {
+	var note, gpsCheck string
+	var photoLat, photoLng *float64
+	var takenAt *time.Time
+	var distance *int
+	var uploadedAt time.Time
+	err := db.Pool.QueryRow(ctx,
+		`SELECT note, "photoLat", "photoLng", "takenAt", "distanceMeters", "gpsCheck", "createdAt"
+		 FROM ride_dropoff_photos WHERE "rideId"=$1`, rideID).
+		Scan(&note, &photoLat, &photoLng, &takenAt, &distance, &gpsCheck, &uploadedAt)
+	if err != nil {
+		return nil
+	}
+	return gin.H{
+		"url":            "/api/v1/admin/ride/" + rideID + "/dropoff-photo",
+		"note":           note,
+		"photoLat":       photoLat,
+		"photoLng":       photoLng,
+		"takenAt":        takenAt,
+		"distanceMeters": distance,
+		"gpsCheck":       gpsCheck,
+		"uploadedAt":     uploadedAt,
+	}
+}
+
+// GET /api/v1/admin/ride/:id/dropoff-photo
+func AdminGetDropoffPhoto(c *gin.Context) {
+	var contentType string
+	var data []byte
+	err := db.Pool.QueryRow(adminContext(c),
+		`SELECT "contentType", data FROM ride_dropoff_photos WHERE "rideId"=$1`, c.Param("id")).Scan(&contentType, &data)
+	if err != nil {
+		utils.RespondError(c, http.StatusNotFound, "No drop-off photo for this ride", nil)
+		return
+	}
+	c.Header("Cache-Control", "private, no-store")
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Data(http.StatusOK, contentType, data)
+}
@@ -0,0 +1,405 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+	"ridewave/db"
+	"ridewave/models"
+	"ridewave/stores"
+	"ridewave/utils"
+)
+
+// ══════════════════════════════════════════════════
+// Duplicate Account Detection
+// ══════════════════════════════════════════════════
+
+// duplicateSignal is one way two accounts can be recognised as the same person.
+// query must return (value TEXT, ids TEXT[]) for every value shared by more than one account.
+type duplicateSignal struct {
+	EntityType string
+	Name       string
+	Query      string
+}
+
+var duplicateSignals = []duplicateSignal{
+	{noteEntityDriver, "driving_license",
+		`SELECT regexp_replace(UPPER(driving_license), '[^A-Z0-9]', '', 'g') AS v, array_agg(id ORDER BY "createdAt")
+		 FROM driver WHERE driving_license <> '' GROUP BY v HAVING COUNT(*) > 1`},
+	{noteEntityDriver, "registration_number",
+		`SELECT regexp_replace(UPPER(registration_number), '[^A-Z0-9]', '', 'g') AS v, array_agg(id ORDER BY "createdAt")
+		 FROM driver WHERE registration_number <> '' GROUP BY v HAVING COUNT(*) > 1`},
+	{noteEntityDriver, "payout_upi",
+		`SELECT LOWER(TRIM("upiId")) AS v, array_agg(id ORDER BY "createdAt")
+		 FROM driver WHERE COALESCE(TRIM("upiId"), '') <> '' GROUP BY v HAVING COUNT(*) > 1`},
+	{noteEntityDriver, "device",
+		`SELECT fingerprint, array_agg(DISTINCT "entityId")
+		 FROM account_devices WHERE "entityType"='driver' GROUP BY fingerprint HAVING COUNT(DISTINCT "entityId") > 1`},
+	{noteEntityDriver, "phone",
+		`SELECT RIGHT(regexp_replace(phone_number, '[^0-9]', '', 'g'), 10) AS v, array_agg(id ORDER BY "createdAt")
+		 FROM driver WHERE phone_number NOT LIKE 'deleted:%' GROUP BY v HAVING COUNT(*) > 1`},
+	{noteEntityUser, "phone",
+		`SELECT RIGHT(regexp_replace(phone_number, '[^0-9]', '', 'g'), 10) AS v, array_agg(id ORDER BY "createdAt")
+		 FROM "user" WHERE phone_number NOT LIKE 'deleted:%' GROUP BY v HAVING COUNT(*) > 1`},
+	{noteEntityUser, "email",
+		`SELECT LOWER(TRIM(email)) AS v, array_agg(id ORDER BY "createdAt")
+		 FROM "user" WHERE COALESCE(TRIM(email), '') <> '' GROUP BY v HAVING COUNT(*) > 1`},
+	{noteEntityUser, "device",
+		`SELECT fingerprint, array_agg(DISTINCT "entityId")
+		 FROM account_devices WHERE "entityType"='user' GROUP BY fingerprint HAVING COUNT(DISTINCT "entityId") > 1`},
+}
+
+const duplicateClusterSelectCols = `id, "entityType", signal, value, "entityIds", status, "keptId", "detectedAt", "resolvedAt"`
+
+func scanDuplicateCluster(scanner interface{ Scan(dest ...any) error }, d *models.DuplicateCluster) error {
+	return scanner.Scan(&d.ID, &d.EntityType, &d.Signal, &d.Value, &d.EntityIDs, &d.Status, &d.KeptID, &d.DetectedAt, &d.ResolvedAt)
+}
+
+// recordDeviceFingerprint remembers the X-Device-Id a user or driver logged in from.
+func recordDeviceFingerprint(c *gin.Context, entityType, entityID string) {
+	fingerprint := strings.TrimSpace(c.GetHeader("X-Device-Id"))
+	if fingerprint == "" || entityID == "" {
+		return
+	}
+	db.Pool.Exec(c.Request.Context(),
+		`INSERT INTO account_devices ("entityType", "entityId", fingerprint) VALUES ($1, $2, $3)
+		 ON CONFLICT ("entityType", "entityId", fingerprint) DO UPDATE SET "lastSeenAt"=NOW()`,
+		entityType, entityID, fingerprint)
+}
+
+// duplicateScanInterval is how often the background scan runs (DUPLICATE_SCAN_INTERVAL_HOURS, default 6).
+func duplicateScanInterval() time.Duration {
+	if val, err := strconv.Atoi(os.Getenv("DUPLICATE_SCAN_INTERVAL_HOURS")); err == nil && val > 0 {
+		return time.Duration(val) * time.Hour
+	}
+	return 6 * time.Hour
+}
+
+// StartDuplicateScanWorker periodically refreshes the duplicate-account review queue.
+func StartDuplicateScanWorker(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(duplicateScanInterval())
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				scanDuplicateAccounts()
+			case <-ctx.Done():
+				utils.Logger.Info("Duplicate Scan Worker shutting down...")
+				return
+			}
+		}
+	}()
+}
+
+// scanDuplicateAccounts runs every signal and upserts a cluster per shared value.
+// A resolved cluster is reopened only if a new account joins it. Returns the number of open clusters.
+func scanDuplicateAccounts() int {
+	ctx := db.Unscoped()
+	for _, sig := range duplicateSignals {
+		rows, err := db.Pool.Query(ctx, sig.Query)
+		if err != nil {
+			utils.Logger.Error("Duplicate scan failed", zap.String("signal", sig.Name), zap.Error(err))
+			continue
+		}
+
+		type match struct {
+			value string
+			ids   []string
+		}
+		var matches []match
+		for rows.Next() {
+			var m match
+			if rows.Scan(&m.value, &m.ids) == nil {
+				matches = append(matches, m)
+			}
+		}
+		rows.Close()
+
+		for _, m := range matches {
+			_, err := db.Pool.Exec(ctx,
+				`INSERT INTO duplicate_clusters ("entityType", signal, value, "entityIds") VALUES ($1, $2, $3, $4)
+				 ON CONFLICT ("entityType", signal, value) DO UPDATE SET
+				   "entityIds"=EXCLUDED."entityIds",
+				   status=CASE WHEN duplicate_clusters."entityIds" @> EXCLUDED."entityIds" THEN duplicate_clusters.status ELSE 'open' END,
+				   "resolvedAt"=CASE WHEN duplicate_clusters."entityIds" @> EXCLUDED."entityIds" THEN duplicate_clusters."resolvedAt" ELSE NULL END`,
+				sig.EntityType, sig.Name, m.value, m.ids)
+			if err != nil {
+				utils.Logger.Error("Failed to save duplicate cluster", zap.String("signal", sig.Name), zap.Error(err))
+			}
+		}
+	}
+
+	var open int
+	db.Pool.QueryRow(ctx, `SELECT COUNT(*) FROM duplicate_clusters WHERE status='open'`).Scan(&open)
+	return open
+}
+
+// duplicateAccounts returns a short summary of each account in a cluster for side-by-side review.
+func duplicateAccounts(ctx context.Context, entityType string, ids []string) []gin.H {
+	query := `SELECT id, name, phone_number, COALESCE(email, ''), status, "createdAt" FROM "user" WHERE id=ANY($1) ORDER BY "createdAt"`
+	if entityType == noteEntityDriver {
+		query = `SELECT id, name, phone_number, COALESCE(email, ''), status, "createdAt" FROM driver WHERE id=ANY($1) ORDER BY "createdAt"`
+	}
+
+	accounts := []gin.H{}
+	rows, err := db.Pool.Query(ctx, query, ids)
+	if err != nil {
+		return accounts
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id, name, phone, email, status string
+		var createdAt time.Time
+		if rows.Scan(&id, &name, &phone, &email, &status, &createdAt) == nil {
+			accounts = append(accounts, gin.H{
+				"id":           id,
+				"name":         name,
+				"phone_number": phone,
+				"email":        email,
+				"status":       status,
+				"createdAt":    createdAt,
+			})
+		}
+	}
+	return accounts
+}
+
+// POST /api/v1/admin/duplicates/scan — run detection now instead of waiting for the worker
+func AdminScanDuplicates(c *gin.Context) {
+	open := scanDuplicateAccounts()
+	utils.RespondSuccess(c, http.StatusOK, "Duplicate scan complete", gin.H{"openClusters": open})
+}
+
+// GET /api/v1/admin/duplicates?status=open&entityType=driver&page=1&limit=20
+func AdminGetDuplicates(c *gin.Context) {
+	pg, err := utils.ParsePagination(c)
+	if err != nil {
+		utils.RespondErrorCode(c, http.StatusBadRequest, utils.CodeInvalidCursor, "Invalid cursor", err)
+		return
+	}
+	status := c.DefaultQuery("status", "open")
+	entityType := c.Query("entityType")
+
+	conds := []string{`($1='' OR status=$1) AND ($2='' OR "entityType"=$2)`}
+	args := []interface{}{status, entityType}
+
+	var total int
+	if !pg.UseCursor {
+		db.Pool.QueryRow(adminContext(c),
+			`SELECT COUNT(*) FROM duplicate_clusters`+utils.WhereClause(conds), args...).Scan(&total)
+	}
+
+	conds, args = pg.Keyset(conds, args, `"detectedAt"`, "id")
+	tail, args := pg.Tail(args, `"detectedAt"`, "id")
+	rows, err := db.Pool.Query(adminContext(c),
+		`SELECT `+duplicateClusterSelectCols+` FROM duplicate_clusters`+utils.WhereClause(conds)+tail, args...)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to fetch duplicate clusters", err)
+		return
+	}
+	var clusters []models.DuplicateCluster
+	for rows.Next() {
+		var d models.DuplicateCluster
+		scanDuplicateCluster(rows, &d)
+		clusters = append(clusters, d)
+	}
+	rows.Close()
+
+	clusters, resp := utils.Paginate(pg, clusters, total, func(d models.DuplicateCluster) (time.Time, string) { return d.DetectedAt, d.ID })
+
+	type ClusterWithAccounts struct {
+		models.DuplicateCluster
+		Accounts []gin.H `json:"accounts"`
+	}
+	result := []ClusterWithAccounts{}
+	for _, d := range clusters {
+		result = append(result, ClusterWithAccounts{d, duplicateAccounts(adminContext(c), d.EntityType, d.EntityIDs)})
+	}
+
+	resp["clusters"] = result
+	utils.RespondSuccess(c, http.StatusOK, "Duplicate clusters", resp)
+}
+
+// GET /api/v1/admin/duplicate/:id — cluster, accounts and review history
+func AdminGetDuplicateDetail(c *gin.Context) {
+	var cluster models.DuplicateCluster
+	err := scanDuplicateCluster(db.Pool.QueryRow(adminContext(c),
+		`SELECT `+duplicateClusterSelectCols+` FROM duplicate_clusters WHERE id=$1`, c.Param("id")), &cluster)
+	if err != nil {
+		utils.RespondError(c, http.StatusNotFound, "Duplicate cluster not found", err)
+		return
+	}
+
+	rows, err := db.Pool.Query(adminContext(c),
+		`SELECT action, COALESCE("adminEmail", ''), details, "createdAt" FROM duplicate_audit_log
+		 WHERE "clusterId"=$1 ORDER BY "createdAt" ASC`, cluster.ID)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to fetch audit trail", err)
+		return
+	}
+	defer rows.Close()
+
+	audit := []gin.H{}
+	for rows.Next() {
+		var action, adminEmail, details string
+		var createdAt time.Time
+		rows.Scan(&action, &adminEmail, &details, &createdAt)
+		audit = append(audit, gin.H{"action": action, "adminEmail": adminEmail, "details": details, "createdAt": createdAt})
+	}
+
+	utils.RespondSuccess(c, http.StatusOK, "Duplicate cluster", gin.H{
+		"cluster":  cluster,
+		"accounts": duplicateAccounts(adminContext(c), cluster.EntityType, cluster.EntityIDs),
+		"audit":    audit,
+	})
+}
+
+// POST /api/v1/admin/duplicate/:id/resolve
+func AdminResolveDuplicate(c *gin.Context) {
+	admin := c.MustGet("admin").(*models.AdminAccount)
+	var body struct {
+		Action string `json:"action" binding:"required"` // merge | suspend | dismiss
+		KeepID string `json:"keepId"`                    // account that survives; required for merge/suspend
+		Note   string `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid request", err)
+		return
+	}
+	if body.Action != "merge" && body.Action != "suspend" && body.Action != "dismiss" {
+		utils.RespondError(c, http.StatusBadRequest, "Action must be merge, suspend or dismiss", nil)
+		return
+	}
+
+	ctx := adminContext(c)
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to resolve cluster", err)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	var cluster models.DuplicateCluster
+	err = scanDuplicateCluster(tx.QueryRow(ctx,
+		`SELECT `+duplicateClusterSelectCols+` FROM duplicate_clusters WHERE id=$1 FOR UPDATE`, c.Param("id")), &cluster)
+	if errors.Is(err, pgx.ErrNoRows) {
+		utils.RespondError(c, http.StatusNotFound, "Duplicate cluster not found", nil)
+		return
+	}
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to resolve cluster", err)
+		return
+	}
+	if cluster.Status != "open" {
+		utils.RespondError(c, http.StatusConflict, "Cluster has already been resolved", nil)
+		return
+	}
+
+	var others []string
+	if body.Action != "dismiss" {
+		found := false
+		for _, id := range cluster.EntityIDs {
+			if id == body.KeepID {
+				found = true
+			} else {
+				others = append(others, id)
+			}
+		}
+		if !found {
+			utils.RespondError(c, http.StatusBadRequest, "keepId must be one of the accounts in this cluster", nil)
+			return
+		}
+	}
+
+	table, rideColumn, closedStatus := `"user"`, `"userId"`, "inactive"
+	if cluster.EntityType == noteEntityDriver {
+		table, rideColumn = "driver", `"driverId"`
+	}
+	if body.Action == "suspend" {
+		closedStatus = "suspended"
+	}
+
+	details := body.Note
+	switch body.Action {
+	case "merge":
+		// Drivers' earnings live in their wallets; they must be settled before the rides move
+		if cluster.EntityType == noteEntityDriver {
+			for _, id := range others {
+				if wallet, err := stores.GetOrCreateWallet(adminContext(c), id); err == nil && wallet.Balance != 0 {
+					utils.RespondError(c, http.StatusConflict,
+						fmt.Sprintf("Driver %s has an unsettled wallet balance of %s", id, formatMoney("", wallet.Balance)), nil)
+					return
+				}
+			}
+		}
+		tag, err := tx.Exec(ctx,
+			`UPDATE rides SET `+rideColumn+`=$1, "updatedAt"=NOW() WHERE `+rideColumn+`=ANY($2)`, body.KeepID, others)
+		if err != nil {
+			utils.RespondError(c, http.StatusInternalServerError, "Failed to merge rides", err)
+			return
+		}
+		if cluster.EntityType == noteEntityUser {
+			if _, err := tx.Exec(ctx,
+				`UPDATE scheduled_rides SET "userId"=$1, "updatedAt"=NOW() WHERE "userId"=ANY($2)`, body.KeepID, others); err != nil {
+				utils.RespondError(c, http.StatusInternalServerError, "Failed to merge scheduled rides", err)
+				return
+			}
+		}
+		details = strings.TrimSpace(fmt.Sprintf("kept %s, moved %d rides from %s. %s",
+			body.KeepID, tag.RowsAffected(), strings.Join(others, ","), body.Note))
+		fallthrough
+	case "suspend":
+		update := `UPDATE ` + table + ` SET status=$1, "updatedAt"=NOW() WHERE id=ANY($2)`
+		if cluster.EntityType == noteEntityDriver {
+			update = `UPDATE driver SET status=$1, "isOnline"=FALSE, "updatedAt"=NOW() WHERE id=ANY($2)`
+		}
+		if _, err := tx.Exec(ctx, update, closedStatus, others); err != nil {
+			utils.RespondError(c, http.StatusInternalServerError, "Failed to update accounts", err)
+			return
+		}
+		if body.Action == "suspend" {
+			details = strings.TrimSpace(fmt.Sprintf("kept %s, suspended %s. %s", body.KeepID, strings.Join(others, ","), body.Note))
+		}
+	}
+
+	status := map[string]string{"merge": "merged", "suspend": "suspended", "dismiss": "dismissed"}[body.Action]
+	_, err = tx.Exec(ctx,
+		`UPDATE duplicate_clusters SET status=$1, "keptId"=NULLIF($2, ''), "resolvedAt"=NOW() WHERE id=$3`,
+		status, body.KeepID, cluster.ID)
+	if err == nil {
+		_, err = tx.Exec(ctx,
+			`INSERT INTO duplicate_audit_log ("clusterId", action, "adminId", "adminEmail", details) VALUES ($1, $2, $3, $4, $5)`,
+			cluster.ID, body.Action, admin.ID, admin.Email, details)
+	}
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to resolve cluster", err)
+		return
+	}
+	if err := tx.Commit(ctx); err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to resolve cluster", err)
+		return
+	}
+
+	// Closed driver accounts must disappear from dispatch immediately
+	if cluster.EntityType == noteEntityDriver {
+		for _, id := range others {
+			stores.RemoveDriver(adminContext(c), id)
+		}
+	}
+	for _, id := range others {
+		utils.RevokeSessionsAsync(cluster.EntityType, id)
+	}
+	utils.RespondSuccess(c, http.StatusOK, "Duplicate cluster "+status, gin.H{"status": status})
+}
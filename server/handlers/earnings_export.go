@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"ridewave/db"
+	"ridewave/models"
+	"ridewave/stores"
+	"ridewave/utils"
+)
+
+// ══════════════════════════════════════════════════
+// Driver Earnings Statement — CSV/PDF export for tax filing
+// ══════════════════════════════════════════════════
+
+type statementRide struct {
+	RideID          string
+	CompletedAt     time.Time
+	VehicleType     string
+	DistanceKm      float64
+	Fare            float64
+	Tips            float64
+	Commission      float64
+	FleetCommission float64
+	Net             float64 // fare - commission - fleet commission + tips
+}
+
+type statementPayout struct {
+	PaidAt    time.Time
+	Reference string
+	Amount    float64
+}
+
+type earningsStatement struct {
+	From, To        time.Time // To is exclusive
+	Rides           []statementRide
+	Payouts         []statementPayout
+	Fares           float64
+	Tips            float64
+	Commission      float64
+	FleetCommission float64
+	Net             float64
+	PaidOut         float64
+}
+
+// buildEarningsStatement collects the driver's completed rides and payouts in [from, to).
+// Rides from before the wallet ledger have no ledger entry; their commission comes from the ride
+// or, for rides older than that, is recomputed at the default rate.
+func buildEarningsStatement(ctx context.Context, driverID string, from, to time.Time) (*earningsStatement, error) {
+	s := &earningsStatement{From: from, To: to}
+
+	rows, err := db.Pool.Query(ctx,
+		`SELECT r.id, COALESCE(r."completedAt", r."updatedAt"), COALESCE(r."vehicleType", ''),
+		 COALESCE(r."estimatedDistance", 0) / 1000.0, COALESCE(r.charge, 0), COALESCE(r.tips, 0),
+		 COALESCE(wt.commission, r."commissionAmount"), COALESCE(wt."fleetCommission", 0)
+		 FROM rides r
+		 LEFT JOIN wallet_transactions wt ON wt."rideId"=r.id AND wt.type=$4
+		 WHERE r."driverId"=$1 AND r.status='Completed'
+		 AND COALESCE(r."completedAt", r."updatedAt") >= $2 AND COALESCE(r."completedAt", r."updatedAt") < $3
+		 ORDER BY 2`, driverID, from, to, stores.WalletTxRideEarning)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var r statementRide
+		var commission *float64
+		if err := rows.Scan(&r.RideID, &r.CompletedAt, &r.VehicleType, &r.DistanceKm, &r.Fare, &r.Tips,
+			&commission, &r.FleetCommission); err != nil {
+			return nil, err
+		}
+		if commission != nil {
+			r.Commission = *commission
+		} else {
+			r.Commission = platformCommission(r.Fare)
+		}
+		r.Net = r.Fare - r.Commission - r.FleetCommission + r.Tips
+		s.Rides = append(s.Rides, r)
+
+		s.Fares += r.Fare
+		s.Tips += r.Tips
+		s.Commission += r.Commission
+		s.FleetCommission += r.FleetCommission
+		s.Net += r.Net
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	payoutRows, err := db.Pool.Query(ctx,
+		`SELECT "createdAt", COALESCE(reference, ''), -amount FROM wallet_transactions
+		 WHERE "driverId"=$1 AND type=$4 AND "createdAt" >= $2 AND "createdAt" < $3 ORDER BY "createdAt"`,
+		driverID, from, to, stores.WalletTxPayout)
+	if err != nil {
+		return nil, err
+	}
+	defer payoutRows.Close()
+	for payoutRows.Next() {
+		var p statementPayout
+		if err := payoutRows.Scan(&p.PaidAt, &p.Reference, &p.Amount); err != nil {
+			return nil, err
+		}
+		s.Payouts = append(s.Payouts, p)
+		s.PaidOut += p.Amount
+	}
+	return s, payoutRows.Err()
+}
+
+// GET /api/v1/driver/earnings/export?from=2026-04-01&to=2027-03-31&format=csv|pdf
+// Defaults to the last 12 months as CSV.
+func ExportEarnings(c *gin.Context) {
+	driver := c.MustGet("driver").(*models.Driver)
+
+	format := c.DefaultQuery("format", "csv")
+	if format != "csv" && format != "pdf" {
+		utils.RespondError(c, http.StatusBadRequest, "format must be 'csv' or 'pdf'", nil)
+		return
+	}
+	to := time.Now()
+	from := to.AddDate(-1, 0, 0)
+	if t, err := time.Parse("2006-01-02", c.Query("from")); err == nil {
+		from = t
+	}
+	if t, err := time.Parse("2006-01-02", c.Query("to")); err == nil {
+		to = t.AddDate(0, 0, 1) // inclusive of the whole end day
+	}
+	if !from.Before(to) {
+		utils.RespondError(c, http.StatusBadRequest, "from must be before to", nil)
+		return
+	}
+
+	statement, err := buildEarningsStatement(c.Request.Context(), driver.ID, from, to)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to build earnings statement", err)
+		return
+	}
+
+	filename := fmt.Sprintf("earnings-%s-%s.%s", from.Format("20060102"), to.AddDate(0, 0, -1).Format("20060102"), format)
+	c.Header("Content-Disposition", "attachment; filename="+filename)
+	if format == "pdf" {
+		c.Data(http.StatusOK, "application/pdf", renderEarningsPDF(driver, statement))
+		return
+	}
+
+	c.Header("Content-Type", "text/csv")
+	w := csv.NewWriter(c.Writer)
+	w.Write([]string{"date", "rideId", "vehicleType", "distanceKm", "fare", "tips", "commission", "fleetCommission", "net"})
+	for _, r := range statement.Rides {
+		w.Write([]string{r.CompletedAt.Format("2006-01-02 15:04"), r.RideID, r.VehicleType, formatAmount(r.DistanceKm),
+			formatAmount(r.Fare), formatAmount(r.Tips), formatAmount(r.Commission), formatAmount(r.FleetCommission), formatAmount(r.Net)})
+	}
+	w.Write([]string{"total", "", "", "", formatAmount(statement.Fares), formatAmount(statement.Tips), formatAmount(statement.Commission),
+		formatAmount(statement.FleetCommission), formatAmount(statement.Net)})
+	w.Write(nil)
+	w.Write([]string{"payoutDate", "reference", "amount"})
+	for _, p := range statement.Payouts {
+		w.Write([]string{p.PaidAt.Format("2006-01-02 15:04"), p.Reference, formatAmount(p.Amount)})
+	}
+	w.Write([]string{"total", "", formatAmount(statement.PaidOut)})
+	w.Flush()
+}
+
+func formatAmount(v float64) string {
+	return fmt.Sprintf("%.2f", v)
+}
+
+func renderEarningsPDF(driver *models.Driver, s *earningsStatement) []byte {
+	pdf := utils.NewTextPDF()
+	pdf.Line("RideWave - Driver Earnings Statement", 14, true)
+	pdf.Space(6)
+	pdf.Line(fmt.Sprintf("Driver:  %s (%s)", driver.Name, driver.PhoneNumber), 9, false)
+	pdf.Line(fmt.Sprintf("Period:  %s to %s", s.From.Format("02 Jan 2006"), s.To.AddDate(0, 0, -1).Format("02 Jan 2006")), 9, false)
+	pdf.Line("Issued:  "+time.Now().Format("02 Jan 2006"), 9, false)
+	pdf.Space(10)
+
+	pdf.Line("Summary", 11, true)
+	pdf.Line(fmt.Sprintf("%-32s %14d", "Completed rides", len(s.Rides)), 9, false)
+	pdf.Line(fmt.Sprintf("%-32s %14s", "Gross fares", formatAmount(s.Fares)), 9, false)
+	pdf.Line(fmt.Sprintf("%-32s %14s", "Tips", formatAmount(s.Tips)), 9, false)
+	pdf.Line(fmt.Sprintf("%-32s %14s", "Platform commission", formatAmount(-s.Commission)), 9, false)
+	pdf.Line(fmt.Sprintf("%-32s %14s", "Fleet commission", formatAmount(-s.FleetCommission)), 9, false)
+	pdf.Line(fmt.Sprintf("%-32s %14s", "Net earnings", formatAmount(s.Net)), 9, true)
+	pdf.Line(fmt.Sprintf("%-32s %14s", "Paid out in period", formatAmount(s.PaidOut)), 9, false)
+	pdf.Space(10)
+
+	pdf.Line("Rides", 11, true)
+	pdf.Line(fmt.Sprintf("%-16s %-8s %8s %10s %8s %10s %8s %10s", "Date", "Ride", "Km", "Fare", "Tips", "Commission", "Fleet", "Net"), 8, true)
+	for _, r := range s.Rides {
+		pdf.Line(fmt.Sprintf("%-16s %-8.8s %8s %10s %8s %10s %8s %10s", r.CompletedAt.Format("2006-01-02 15:04"), r.RideID,
+			formatAmount(r.DistanceKm), formatAmount(r.Fare), formatAmount(r.Tips), formatAmount(r.Commission), formatAmount(r.FleetCommission), formatAmount(r.Net)), 8, false)
+	}
+	pdf.Space(10)
+
+	pdf.Line("Payouts", 11, true)
+	pdf.Line(fmt.Sprintf("%-16s %-40s %12s", "Date", "Reference", "Amount"), 8, true)
+	for _, p := range s.Payouts {
+		pdf.Line(fmt.Sprintf("%-16s %-40.40s %12s", p.PaidAt.Format("2006-01-02 15:04"), p.Reference, formatAmount(p.Amount)), 8, false)
+	}
+	if len(s.Payouts) == 0 {
+		pdf.Line("No payouts in this period.", 8, false)
+	}
+	return pdf.Bytes()
+}
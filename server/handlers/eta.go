@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+	"ridewave/db"
+	"ridewave/stores"
+	"ridewave/utils"
+)
+
+// ══════════════════════════════════════════════════
+// Live ETA — periodic pickup & drop-off estimates pushed to the rider
+// ══════════════════════════════════════════════════
+
+const rideETAKeyPrefix = "rides:eta:"
+
+// etaConfig holds the ETA service tunables, all overridable via ENV.
+type etaConfig struct {
+	Interval  time.Duration // how often each active ride is re-estimated
+	UseMatrix bool          // ask the Distance Matrix API first
+}
+
+func loadETAConfig() etaConfig {
+	cfg := etaConfig{
+		Interval:  30 * time.Second,
+		UseMatrix: os.Getenv("OLA_MAPS_API_KEY") != "" && os.Getenv("ETA_USE_DISTANCE_MATRIX") != "false",
+	}
+	if val, err := strconv.Atoi(os.Getenv("ETA_INTERVAL_SECONDS")); err == nil && val >= 5 {
+		cfg.Interval = time.Duration(val) * time.Second
+	}
+	return cfg
+}
+
+// StartETAWorker re-estimates every Accepted and InProgress ride on a short interval and
+// pushes an etaUpdate to the rider's socket.
+func StartETAWorker(ctx context.Context) {
+	go func() {
+		cfg := loadETAConfig()
+		ticker := time.NewTicker(cfg.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				updateRideETAs(cfg)
+			case <-ctx.Done():
+				utils.Logger.Info("ETA Worker shutting down...")
+				return
+			}
+		}
+	}()
+}
+
+type etaRide struct {
+	ID                string
+	UserID            string
+	DriverID          string
+	Status            string
+	OriginLat         float64
+	OriginLng         float64
+	DestinationLat    float64
+	DestinationLng    float64
+	EstimatedDuration int
+	EstimatedDistance int
+}
+
+func updateRideETAs(cfg etaConfig) {
+	ctx := db.Unscoped()
+	rows, err := db.Pool.Query(ctx,
+		`SELECT id, "userId", "driverId", status, "originLat", "originLng", "destinationLat", "destinationLng",
+		 COALESCE("estimatedDuration", 0), COALESCE("estimatedDistance", 0)
+		 FROM rides WHERE status IN ('Accepted', 'InProgress') AND "driverId" IS NOT NULL
+		 AND "originLat" IS NOT NULL AND "originLng" IS NOT NULL
+		 AND "destinationLat" IS NOT NULL AND "destinationLng" IS NOT NULL`)
+	if err != nil {
+		utils.Logger.Error("Failed to query active rides for ETA", zap.Error(err))
+		return
+	}
+
+	var rides []etaRide
+	for rows.Next() {
+		var r etaRide
+		if err := rows.Scan(&r.ID, &r.UserID, &r.DriverID, &r.Status, &r.OriginLat, &r.OriginLng, &r.DestinationLat,
+			&r.DestinationLng, &r.EstimatedDuration, &r.EstimatedDistance); err == nil {
+			rides = append(rides, r)
+		}
+	}
+	rows.Close()
+
+	for _, r := range rides {
+		// Only one instance estimates a ride per interval
+		claimed, err := db.RedisClient.SetNX(ctx, rideETAKeyPrefix+r.ID, 1, cfg.Interval-time.Second).Result()
+		if err != nil || !claimed {
+			continue
+		}
+		loc, err := stores.GetDriverLocation(ctx, r.DriverID)
+		if err != nil {
+			continue // No live location to estimate from
+		}
+
+		event := estimateRideETA(ctx, cfg, r, loc.Latitude, loc.Longitude)
+		if err := stores.PublishETAUpdate(ctx, event); err != nil {
+			utils.Logger.Warn("Failed to publish ETA update", zap.String("rideId", r.ID), zap.Error(err))
+		}
+	}
+}
+
+// estimateRideETA times the driver's remaining legs: to the pickup and on to the drop-off
+// while Accepted, straight to the drop-off once InProgress.
+func estimateRideETA(ctx context.Context, cfg etaConfig, r etaRide, lat, lng float64) stores.ETAUpdateEvent {
+	event := stores.ETAUpdateEvent{RideID: r.ID, UserID: r.UserID, Status: r.Status, UpdatedAt: time.Now()}
+	driver := fmt.Sprintf("%f,%f", lat, lng)
+	pickup := fmt.Sprintf("%f,%f", r.OriginLat, r.OriginLng)
+	dropoff := fmt.Sprintf("%f,%f", r.DestinationLat, r.DestinationLng)
+
+	if cfg.UseMatrix {
+		olaClient := utils.NewOlaMapsClient().WithContext(ctx)
+		if r.Status == "Accepted" {
+			// driver→pickup and pickup→drop-off in one call, on the matrix diagonal
+			matrix, err := olaClient.GetDistanceMatrix([]string{driver, pickup}, []string{pickup, dropoff})
+			if toPickup, ok := matrixElement(matrix, err, 0, 0); ok {
+				if trip, ok := matrixElement(matrix, err, 1, 1); ok {
+					event.PickupETASeconds, event.PickupDistanceMeters = &toPickup[0], &toPickup[1]
+					event.DropoffETASeconds = toPickup[0] + trip[0]
+					event.DropoffDistanceMeters = toPickup[1] + trip[1]
+					event.Source = "matrix"
+					return event
+				}
+			}
+		} else {
+			matrix, err := olaClient.GetDistanceMatrix([]string{driver}, []string{dropoff})
+			if toDropoff, ok := matrixElement(matrix, err, 0, 0); ok {
+				event.DropoffETASeconds, event.DropoffDistanceMeters = toDropoff[0], toDropoff[1]
+				event.Source = "matrix"
+				return event
+			}
+		}
+	}
+
+	event.Source = "haversine"
+	if r.Status == "Accepted" {
+		seconds, meters := utils.StraightLineETA(lat, lng, r.OriginLat, r.OriginLng)
+		event.PickupETASeconds, event.PickupDistanceMeters = &seconds, &meters
+		// The booked route's own estimate is better than a straight line for the trip itself
+		trip, tripMeters := r.EstimatedDuration, r.EstimatedDistance
+		if trip == 0 || tripMeters == 0 {
+			trip, tripMeters = utils.StraightLineETA(r.OriginLat, r.OriginLng, r.DestinationLat, r.DestinationLng)
+		}
+		event.DropoffETASeconds = seconds + trip
+		event.DropoffDistanceMeters = meters + tripMeters
+		return event
+	}
+	event.DropoffETASeconds, event.DropoffDistanceMeters = utils.StraightLineETA(lat, lng, r.DestinationLat, r.DestinationLng)
+	return event
+}
+
+// matrixElement returns {duration seconds, distance meters} of one matrix cell, if it was routed.
+func matrixElement(matrix *utils.OlaDistanceMatrixResponse, err error, row, col int) ([2]int, bool) {
+	if err != nil || matrix == nil || row >= len(matrix.Rows) || col >= len(matrix.Rows[row].Elements) {
+		return [2]int{}, false
+	}
+	el := matrix.Rows[row].Elements[col]
+	if el.Status != "" && el.Status != "OK" && el.Status != "ok" {
+		return [2]int{}, false
+	}
+	return [2]int{el.Duration.Value, el.Distance.Value}, true
+}
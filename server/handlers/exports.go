@@ -0,0 +1,329 @@
+package handlers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"ridewave/db"
+	"ridewave/models"
+	"ridewave/utils"
+)
+
+// ══════════════════════════════════════════════════
+// Admin Data Export — streamed CSV, or background jobs for large ranges
+// ══════════════════════════════════════════════════
+
+// exportEntity is one exportable dataset, filtered on its creation time.
+type exportEntity struct {
+	header []string
+	query  string // $1 from (inclusive), $2 to (exclusive)
+}
+
+var exportEntities = map[string]exportEntity{
+	"rides": {
+		header: []string{"id", "userId", "driverId", "tenantId", "status", "vehicleType", "charge", "currency", "tips", "paymentMode",
+			"paymentStatus", "origin", "destination", "estimatedDistanceM", "estimatedDurationS", "cancelReason",
+			"commissionPercent", "commissionAmount", "createdAt", "completedAt", "cancelledAt"},
+		query: `SELECT id, "userId", "driverId", "tenantId", status, "vehicleType", charge, currency, tips, "paymentMode",
+			"paymentStatus", "currentLocationName", "destinationLocationName", "estimatedDistance", "estimatedDuration", "cancelReason",
+			"commissionPercent", "commissionAmount", "createdAt", "completedAt", "cancelledAt"
+			FROM rides WHERE "createdAt" >= $1 AND "createdAt" < $2 ORDER BY "createdAt", id`,
+	},
+	"payments": {
+		header: []string{"id", "rideId", "amount", "currency", "mode", "status", "createdAt"},
+		query: `SELECT id, "rideId", amount, currency, mode, status, "createdAt"
+			FROM payments WHERE "createdAt" >= $1 AND "createdAt" < $2 ORDER BY "createdAt", id`,
+	},
+	"users": {
+		header: []string{"id", "tenantId", "name", "phoneNumber", "email", "ratings", "totalRides", "status", "createdAt"},
+		query: `SELECT id, "tenantId", name, phone_number, email, ratings, "totalRides", status, "createdAt"
+			FROM "user" WHERE "createdAt" >= $1 AND "createdAt" < $2 ORDER BY "createdAt", id`,
+	},
+	"drivers": {
+		header: []string{"id", "tenantId", "fleetId", "name", "phoneNumber", "email", "country", "vehicleType",
+			"registrationNumber", "status", "ratings", "totalRides", "totalEarning", "createdAt"},
+		query: `SELECT id, "tenantId", "fleetId", name, phone_number, email, country, vehicle_type,
+			registration_number, status, ratings, "totalRides", "totalEarning", "createdAt"
+			FROM driver WHERE "createdAt" >= $1 AND "createdAt" < $2 ORDER BY "createdAt", id`,
+	},
+}
+
+// exportSyncMaxDays is the widest range served as a direct download (EXPORT_SYNC_MAX_DAYS, default 31);
+// anything wider has to go through an export job.
+func exportSyncMaxDays() int {
+	if val, err := strconv.Atoi(os.Getenv("EXPORT_SYNC_MAX_DAYS")); err == nil && val > 0 {
+		return val
+	}
+	return 31
+}
+
+// exportRetention is how long finished job files are kept (EXPORT_RETENTION_DAYS, default 7).
+func exportRetention() time.Duration {
+	if val, err := strconv.Atoi(os.Getenv("EXPORT_RETENTION_DAYS")); err == nil && val > 0 {
+		return time.Duration(val) * 24 * time.Hour
+	}
+	return 7 * 24 * time.Hour
+}
+
+// parseExportRange reads from/to (YYYY-MM-DD, to inclusive); both are required.
+func parseExportRange(fromStr, toStr string) (time.Time, time.Time, error) {
+	from, err := time.Parse("2006-01-02", fromStr)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("from must be YYYY-MM-DD")
+	}
+	to, err := time.Parse("2006-01-02", toStr)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("to must be YYYY-MM-DD")
+	}
+	to = to.AddDate(0, 0, 1) // inclusive of the whole end day
+	if !from.Before(to) {
+		return time.Time{}, time.Time{}, fmt.Errorf("from must not be after to")
+	}
+	return from, to, nil
+}
+
+func exportFilename(entity string, from, to time.Time) string {
+	return fmt.Sprintf("%s-%s-%s.csv", entity, from.Format("20060102"), to.AddDate(0, 0, -1).Format("20060102"))
+}
+
+// writeExportCSV streams the entity's rows in [from, to) as CSV, calling flush every 1000 rows.
+func writeExportCSV(ctx context.Context, out io.Writer, e exportEntity, from, to time.Time, flush func()) (int, error) {
+	rows, err := db.Pool.Query(ctx, e.query, from, to)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	w := csv.NewWriter(out)
+	w.Write(e.header)
+	count := 0
+	record := make([]string, len(e.header))
+	for rows.Next() {
+		values, err := rows.Values()
+		if err != nil {
+			return count, err
+		}
+		for i, v := range values {
+			record[i] = formatExportValue(v)
+		}
+		if err := w.Write(record); err != nil {
+			return count, err
+		}
+		count++
+		if count%1000 == 0 {
+			w.Flush()
+			if flush != nil {
+				flush()
+			}
+		}
+	}
+	w.Flush()
+	if err := rows.Err(); err != nil {
+		return count, err
+	}
+	return count, w.Error()
+}
+
+func formatExportValue(v any) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case time.Time:
+		return val.UTC().Format(time.RFC3339)
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	default:
+		return fmt.Sprint(val)
+	}
+}
+
+// GET /api/v1/admin/export/:entity?from=2026-01-01&to=2026-01-31 — rides, payments, users or drivers as CSV
+func AdminExportData(c *gin.Context) {
+	entity := c.Param("entity")
+	e, ok := exportEntities[entity]
+	if !ok {
+		utils.RespondError(c, http.StatusNotFound, "Unknown export; use rides, payments, users or drivers", nil)
+		return
+	}
+	from, to, err := parseExportRange(c.Query("from"), c.Query("to"))
+	if err != nil {
+		utils.RespondError(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+	if to.Sub(from) > time.Duration(exportSyncMaxDays())*24*time.Hour {
+		utils.RespondError(c, http.StatusBadRequest,
+			fmt.Sprintf("Ranges over %d days must be exported with POST /api/v1/admin/exports", exportSyncMaxDays()), nil)
+		return
+	}
+
+	admin := c.MustGet("admin").(*models.AdminAccount)
+	c.Header("Content-Type", "text/csv")
+	c.Header("Content-Disposition", "attachment; filename="+exportFilename(entity, from, to))
+	count, err := writeExportCSV(adminContext(c), c.Writer, e, from, to, c.Writer.Flush)
+	if err != nil {
+		// Headers are gone by now; the truncated file is all we can signal with
+		utils.Logger.Error("Data export failed mid-stream", zap.String("entity", entity), zap.Error(err))
+		return
+	}
+	utils.Logger.Info("Admin data export", zap.String("admin", admin.Email), zap.String("entity", entity),
+		zap.Time("from", from), zap.Time("to", to), zap.Int("rows", count))
+}
+
+type exportJob struct {
+	ID          string     `json:"id"`
+	Entity      string     `json:"entity"`
+	From        time.Time  `json:"from"`
+	To          time.Time  `json:"to"`     // exclusive
+	Status      string     `json:"status"` // running | done | failed
+	Rows        int        `json:"rows"`
+	Bytes       int        `json:"bytes"` // gzipped
+	Error       string     `json:"error,omitempty"`
+	RequestedBy string     `json:"requestedBy"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	FinishedAt  *time.Time `json:"finishedAt"`
+	ExpiresAt   *time.Time `json:"expiresAt"`
+	DownloadURL string     `json:"downloadUrl,omitempty"`
+}
+
+const exportJobSelectCols = `id, entity, "fromDate", "toDate", status, "rowCount", COALESCE(LENGTH(data), 0), COALESCE(error, ''), "requestedBy", "createdAt", "finishedAt", "expiresAt"`
+
+func scanExportJob(scanner interface{ Scan(dest ...any) error }, j *exportJob) error {
+	if err := scanner.Scan(&j.ID, &j.Entity, &j.From, &j.To, &j.Status, &j.Rows, &j.Bytes, &j.Error, &j.RequestedBy,
+		&j.CreatedAt, &j.FinishedAt, &j.ExpiresAt); err != nil {
+		return err
+	}
+	if j.Status == "done" {
+		j.DownloadURL = "/api/v1/admin/exports/" + j.ID + "/download"
+	}
+	return nil
+}
+
+// POST /api/v1/admin/exports — { entity, from, to }; poll GET /exports/:id, then download
+func AdminCreateExportJob(c *gin.Context) {
+	var body struct {
+		Entity string `json:"entity" binding:"required"`
+		From   string `json:"from" binding:"required"`
+		To     string `json:"to" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid request", err)
+		return
+	}
+	e, ok := exportEntities[body.Entity]
+	if !ok {
+		utils.RespondError(c, http.StatusBadRequest, "entity must be rides, payments, users or drivers", nil)
+		return
+	}
+	from, to, err := parseExportRange(body.From, body.To)
+	if err != nil {
+		utils.RespondError(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	ctx := adminContext(c)
+	// Housekeeping: drop expired files and fail jobs whose instance went away mid-run
+	db.Pool.Exec(ctx, `DELETE FROM admin_exports WHERE "expiresAt" < NOW()`)
+	db.Pool.Exec(ctx,
+		`UPDATE admin_exports SET status='failed', error='interrupted', "finishedAt"=NOW()
+		 WHERE status='running' AND "createdAt" < NOW() - INTERVAL '6 hours'`)
+
+	admin := c.MustGet("admin").(*models.AdminAccount)
+	var job exportJob
+	err = scanExportJob(db.Pool.QueryRow(ctx,
+		`INSERT INTO admin_exports (entity, "fromDate", "toDate", "requestedBy") VALUES ($1, $2, $3, $4)
+		 RETURNING `+exportJobSelectCols, body.Entity, from, to, admin.Email), &job)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to start export", err)
+		return
+	}
+
+	utils.Logger.Info("Admin export job started", zap.String("admin", admin.Email), zap.String("entity", body.Entity),
+		zap.String("jobId", job.ID))
+	utils.SafeGo(func() { runExportJob(job.ID, e, from, to) })
+	utils.RespondSuccess(c, http.StatusAccepted, "Export started", gin.H{"job": job})
+}
+
+// runExportJob builds the gzipped CSV and stores it on the job row.
+func runExportJob(jobID string, e exportEntity, from, to time.Time) {
+	ctx := db.WithoutTenant(context.Background())
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	count, err := writeExportCSV(ctx, zw, e, from, to, nil)
+	if err == nil {
+		err = zw.Close()
+	}
+	if err != nil {
+		utils.Logger.Error("Export job failed", zap.String("jobId", jobID), zap.Error(err))
+		db.Pool.Exec(ctx,
+			`UPDATE admin_exports SET status='failed', error=$2, "rowCount"=$3, "finishedAt"=NOW() WHERE id=$1`, jobID, err.Error(), count)
+		return
+	}
+	_, err = db.Pool.Exec(ctx,
+		`UPDATE admin_exports SET status='done', data=$2, "rowCount"=$3, "finishedAt"=NOW(), "expiresAt"=$4 WHERE id=$1`,
+		jobID, buf.Bytes(), count, time.Now().Add(exportRetention()))
+	if err != nil {
+		utils.Logger.Error("Failed to store export", zap.String("jobId", jobID), zap.Error(err))
+		db.Pool.Exec(ctx,
+			`UPDATE admin_exports SET status='failed', error=$2, "finishedAt"=NOW() WHERE id=$1`, jobID, err.Error())
+	}
+}
+
+// GET /api/v1/admin/exports
+func AdminGetExportJobs(c *gin.Context) {
+	rows, err := db.Pool.Query(adminContext(c),
+		`SELECT `+exportJobSelectCols+` FROM admin_exports ORDER BY "createdAt" DESC LIMIT 50`)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to fetch exports", err)
+		return
+	}
+	defer rows.Close()
+
+	jobs := []exportJob{}
+	for rows.Next() {
+		var j exportJob
+		if scanExportJob(rows, &j) == nil {
+			jobs = append(jobs, j)
+		}
+	}
+	utils.RespondSuccess(c, http.StatusOK, "Export jobs", gin.H{"jobs": jobs})
+}
+
+// GET /api/v1/admin/exports/:id
+func AdminGetExportJob(c *gin.Context) {
+	var job exportJob
+	err := scanExportJob(db.Pool.QueryRow(adminContext(c),
+		`SELECT `+exportJobSelectCols+` FROM admin_exports WHERE id=$1`, c.Param("id")), &job)
+	if err != nil {
+		utils.RespondError(c, http.StatusNotFound, "Export not found", nil)
+		return
+	}
+	utils.RespondSuccess(c, http.StatusOK, "Export job", gin.H{"job": job})
+}
+
+// GET /api/v1/admin/exports/:id/download — the CSV, gzipped
+func AdminDownloadExport(c *gin.Context) {
+	var entity string
+	var from, to time.Time
+	var data []byte
+	err := db.Pool.QueryRow(adminContext(c),
+		`SELECT entity, "fromDate", "toDate", data FROM admin_exports
+		 WHERE id=$1 AND status='done' AND "expiresAt" > NOW()`, c.Param("id")).Scan(&entity, &from, &to, &data)
+	if err != nil {
+		utils.RespondError(c, http.StatusNotFound, "Export not found, not finished or expired", nil)
+		return
+	}
+	c.Header("Content-Disposition", "attachment; filename="+exportFilename(entity, from, to)+".gz")
+	c.Data(http.StatusOK, "application/gzip", data)
+}
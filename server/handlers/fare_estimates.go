@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"ridewave/models"
+	"ridewave/promos"
+	"ridewave/stores"
+	"ridewave/utils"
+)
+
+// ══════════════════════════════════════════════════
+// Fare Estimates — every vehicle type in one call, with fare ranges
+// ══════════════════════════════════════════════════
+//
+// The vehicle picker needs a price for each type before the rider chooses one. Instead of a
+// Directions call per type, the trip is measured once with the Distance Matrix and each active
+// type is priced from that. Booking still needs a routeId, so the app asks for the chosen type's
+// estimate once the rider picks.
+
+// fareRangePercent is how far either side of the fare the quoted range reaches, covering route
+// and traffic variance (FARE_RANGE_PERCENT, default 10).
+func fareRangePercent() float64 {
+	if val, err := strconv.ParseFloat(os.Getenv("FARE_RANGE_PERCENT"), 64); err == nil && val >= 0 && val < 100 {
+		return val
+	}
+	return 10
+}
+
+// fareRange is the low and high end riders should expect; busy pickups stretch the high end by
+// the surge multiplier.
+func fareRange(fare, surgeMultiplier float64) (float64, float64) {
+	spread := fareRangePercent() / 100
+	return math.Floor(fare * (1 - spread)), math.Ceil(fare * surgeMultiplier * (1 + spread))
+}
+
+// matrixTrip measures origin → stops → destination with a single Distance Matrix call, summing
+// the legs along the matrix diagonal.
+func matrixTrip(ctx context.Context, origin, destination string, stops []stores.RouteStop) (distance, duration int, err error) {
+	origins := []string{origin}
+	destinations := []string{}
+	for _, stop := range stops {
+		point := fmt.Sprintf("%f,%f", stop.Lat, stop.Lng)
+		origins = append(origins, point)
+		destinations = append(destinations, point)
+	}
+	destinations = append(destinations, destination)
+
+	matrix, err := utils.NewOlaMapsClient().WithContext(ctx).GetDistanceMatrix(origins, destinations)
+	for i := range origins {
+		leg, ok := matrixElement(matrix, err, i, i)
+		if !ok {
+			if err == nil {
+				err = errors.New("no route between the trip's points")
+			}
+			return 0, 0, err
+		}
+		duration += leg[0]
+		distance += leg[1]
+	}
+	return distance, duration, nil
+}
+
+// vehicleEstimate is one vehicle type's price for the trip.
+type vehicleEstimate struct {
+	VehicleType       string   `json:"vehicleType"`
+	IconURL           string   `json:"iconUrl,omitempty"`
+	Icon              string   `json:"icon"`
+	Capacity          *int     `json:"capacity"`
+	Description       string   `json:"description"`
+	ETABlurb          string   `json:"etaBlurb"`
+	Available         bool     `json:"available"`
+	UnavailableReason string   `json:"unavailableReason,omitempty"`
+	Fare              float64  `json:"fare"`
+	MinFare           float64  `json:"minFare"`
+	MaxFare           float64  `json:"maxFare"`
+	Discount          *float64 `json:"discount,omitempty"`
+	FinalFare         *float64 `json:"finalFare,omitempty"`
+	PromoError        string   `json:"promoError,omitempty"`
+	CO2Grams          float64  `json:"co2Grams"`
+	CO2SavedGrams     *float64 `json:"co2SavedGrams,omitempty"`
+	PickupETASeconds  *int     `json:"pickupEtaSeconds,omitempty"` // nearest driver of this type, if any
+	PickupETAText     string   `json:"pickupEtaText,omitempty"`
+}
+
+// respondAllVehicleEstimates prices the trip for every active vehicle type (Pool only without stops).
+func respondAllVehicleEstimates(c *gin.Context, user *models.User, origin, destination string, stops []stores.RouteStop,
+	promoCode string, pickupLat, pickupLng float64) {
+	ctx := c.Request.Context()
+
+	distance, duration, err := matrixTrip(ctx, origin, destination, stops)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to calculate route", err)
+		return
+	}
+
+	cached, err := loadVehicleTypes(ctx)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to fetch vehicle types", err)
+		return
+	}
+	var types []cachedVehicleType
+	for _, t := range cached {
+		if t.IsActive && !(len(stops) > 0 && t.Name == poolVehicleType) {
+			types = append(types, t)
+		}
+	}
+
+	surgeLevelName, surgeMultiplier := pickupSurge(ctx, pickupLat, pickupLng)
+	zone, now := zoneForPoint(pickupLat, pickupLng), time.Now()
+	currency := currencyAt(pickupLat, pickupLng)
+	nearby := nearbyDriversWithETA(ctx, pickupLat, pickupLng)
+
+	estimates := make([]vehicleEstimate, 0, len(types))
+	for _, t := range types {
+		vt := t.VehicleTypeConfig
+		applyVehicleAvailability(&vt, zone, now)
+		fare := currency.Round(fareBreakdownAt(vt.BaseFare, vt.PerKmRate, vt.PerMinRate, t.CommissionPercent, distance, duration).Total())
+		minFare, maxFare := fareRange(fare, surgeMultiplier)
+		e := vehicleEstimate{
+			VehicleType:       vt.Name,
+			IconURL:           vt.IconURL,
+			Icon:              vt.Icon,
+			Capacity:          vt.Capacity,
+			Description:       vt.Description,
+			ETABlurb:          vt.ETABlurb,
+			Available:         vt.Available,
+			UnavailableReason: vt.UnavailableReason,
+			Fare:              fare,
+			MinFare:           minFare,
+			MaxFare:           maxFare,
+		}
+		co2, saved := rideEmissions(distance, vt.IsElectric, vt.EmissionFactor)
+		e.CO2Grams = co2
+		if vt.IsElectric {
+			e.CO2SavedGrams = &saved
+		}
+		if eta, ok := pickupETA(nearby, vt.Name); ok {
+			e.PickupETASeconds, e.PickupETAText = &eta.Seconds, eta.Text
+		}
+		if promoCode != "" && vt.Available {
+			booking := promos.Booking{VehicleType: vt.Name, Zone: zone, At: now.In(serviceLocation())}
+			if _, discount, err := validatePromo(ctx, user.ID, promoCode, booking, fare); err != nil {
+				e.PromoError = err.Error()
+			} else {
+				finalFare := fare - discount
+				e.Discount, e.FinalFare = &discount, &finalFare
+			}
+		}
+		estimates = append(estimates, e)
+	}
+
+	resp := gin.H{
+		"distance":      fmt.Sprintf("%.2f km", float64(distance)/1000.0),
+		"duration":      fmt.Sprintf("%d mins", int(float64(duration)/60.0)),
+		"surge":         gin.H{"level": surgeLevelName, "multiplier": surgeMultiplier},
+		"currency":      currency,
+		"vehicles":      estimates,
+		"nearbyDrivers": nearby,
+	}
+	if len(stops) > 0 {
+		resp["stops"] = stops
+	}
+	utils.RespondSuccess(c, http.StatusOK, "Ride estimates", resp)
+}
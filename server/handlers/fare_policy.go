@@ -0,0 +1,15 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"ridewave/rides/fares"
+	"ridewave/utils"
+)
+
+// GET /api/v1/public/fare-policy — what waiting at the pickup and cancelling after acceptance cost,
+// for the apps to show before the rider books
+func GetFarePolicy(c *gin.Context) {
+	utils.RespondSuccess(c, http.StatusOK, "Fare policy", gin.H{"policy": fares.LoadPolicy()})
+}
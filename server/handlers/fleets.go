@@ -0,0 +1,460 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"ridewave/db"
+	"ridewave/middleware"
+	"ridewave/models"
+	"ridewave/utils"
+)
+
+// ══════════════════════════════════════════════════
+// Fleets — owners who lease vehicles to several drivers
+// ══════════════════════════════════════════════════
+
+const fleetSelectCols = `id, name, "ownerName", "phoneNumber", email, "commissionPercent", status, "totalEarned", "createdAt", "updatedAt"`
+
+func scanFleet(scanner interface{ Scan(dest ...any) error }, f *models.Fleet) error {
+	return scanner.Scan(&f.ID, &f.Name, &f.OwnerName, &f.PhoneNumber, &f.Email, &f.CommissionPercent, &f.Status,
+		&f.TotalEarned, &f.CreatedAt, &f.UpdatedAt)
+}
+
+// RegisterFleetRoutes defines the fleet owner dashboard API
+func RegisterFleetRoutes(r gin.IRouter, fleetMiddleware gin.HandlerFunc) {
+	fleetGroup := r.Group("/fleet")
+	{
+		// Auth
+		fleetGroup.POST("/auth/login", middleware.RateLimitRoute("login", 5), FleetLogin)
+		fleetGroup.POST("/auth/verify", middleware.RateLimitRoute("verify", 10), FleetVerify)
+
+		fleetGroup.GET("/me", fleetMiddleware, GetFleetMe)
+		fleetGroup.GET("/drivers", fleetMiddleware, GetFleetDrivers)
+		fleetGroup.GET("/earnings", fleetMiddleware, GetFleetEarnings)
+	}
+}
+
+// fleetReportWindow reads ?from=&to= (YYYY-MM-DD, both inclusive), defaulting to the last 30 days.
+func fleetReportWindow(c *gin.Context) (from, to time.Time) {
+	to = time.Now()
+	from = to.AddDate(0, 0, -30)
+	if t, err := time.Parse("2006-01-02", c.Query("from")); err == nil {
+		from = t
+	}
+	if t, err := time.Parse("2006-01-02", c.Query("to")); err == nil {
+		to = t.AddDate(0, 0, 1)
+	}
+	return from, to
+}
+
+type fleetDriverStats struct {
+	ID                 string  `json:"id"`
+	Name               string  `json:"name"`
+	PhoneNumber        string  `json:"phoneNumber"`
+	VehicleType        string  `json:"vehicleType"`
+	RegistrationNumber string  `json:"registrationNumber"`
+	Status             string  `json:"status"`
+	IsOnline           bool    `json:"isOnline"`
+	Ratings            float64 `json:"ratings"`
+	CompletedRides     int     `json:"completedRides"`
+	CancelledRides     int     `json:"cancelledRides"`
+	GrossFares         float64 `json:"grossFares"`
+	DriverEarnings     float64 `json:"driverEarnings"`
+	FleetCommission    float64 `json:"fleetCommission"`
+}
+
+// fleetDriverPerformance lists a fleet's drivers with their rides and earnings in [from, to).
+// Earnings come from the ledger, so only rides completed while in the fleet count.
+func fleetDriverPerformance(ctx context.Context, fleetID string, from, to time.Time) ([]fleetDriverStats, error) {
+	rows, err := db.Pool.Query(ctx,
+		`SELECT d.id, d.name, d.phone_number, d.vehicle_type, d.registration_number, d.status, d."isOnline", d.ratings,
+		 COALESCE(r.completed, 0), COALESCE(r.cancelled, 0), COALESCE(r.gross, 0)::float8,
+		 COALESCE(w.earned, 0), COALESCE(w.commission, 0)
+		 FROM driver d
+		 LEFT JOIN LATERAL (
+			SELECT COUNT(*) FILTER (WHERE status='Completed') AS completed, COUNT(*) FILTER (WHERE status='Cancelled') AS cancelled,
+			 SUM(charge) FILTER (WHERE status='Completed') AS gross
+			FROM rides WHERE "driverId"=d.id AND "createdAt" >= $2 AND "createdAt" < $3
+		 ) r ON TRUE
+		 LEFT JOIN LATERAL (
+			SELECT SUM(amount) AS earned, SUM("fleetCommission") AS commission
+			FROM wallet_transactions WHERE "driverId"=d.id AND "fleetId"=$1 AND type='ride_earning'
+			 AND "createdAt" >= $2 AND "createdAt" < $3
+		 ) w ON TRUE
+		 WHERE d."fleetId"=$1
+		 ORDER BY d.name`, fleetID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	drivers := []fleetDriverStats{}
+	for rows.Next() {
+		var d fleetDriverStats
+		if err := rows.Scan(&d.ID, &d.Name, &d.PhoneNumber, &d.VehicleType, &d.RegistrationNumber, &d.Status, &d.IsOnline,
+			&d.Ratings, &d.CompletedRides, &d.CancelledRides, &d.GrossFares, &d.DriverEarnings, &d.FleetCommission); err != nil {
+			return nil, err
+		}
+		drivers = append(drivers, d)
+	}
+	return drivers, rows.Err()
+}
+
+type fleetEarningsRow struct {
+	Day                string  `json:"day,omitempty"`
+	Rides              int     `json:"rides"`
+	GrossFares         float64 `json:"grossFares"`
+	PlatformCommission float64 `json:"platformCommission"`
+	DriverEarnings     float64 `json:"driverEarnings"`
+	FleetCommission    float64 `json:"fleetCommission"`
+}
+
+const fleetEarningsCols = `COUNT(*), ROUND(COALESCE(SUM(amount + commission + "fleetCommission"), 0)::numeric, 2)::float8,
+	ROUND(COALESCE(SUM(commission), 0)::numeric, 2)::float8, ROUND(COALESCE(SUM(amount), 0)::numeric, 2)::float8,
+	ROUND(COALESCE(SUM("fleetCommission"), 0)::numeric, 2)::float8`
+
+// fleetEarnings totals the fleet's ledger entries in [from, to), with a per-day breakdown.
+func fleetEarnings(ctx context.Context, fleetID string, from, to time.Time) (fleetEarningsRow, []fleetEarningsRow, error) {
+	where := `FROM wallet_transactions WHERE "fleetId"=$1 AND type='ride_earning' AND "createdAt" >= $2 AND "createdAt" < $3`
+
+	var total fleetEarningsRow
+	err := db.Pool.QueryRow(ctx, `SELECT `+fleetEarningsCols+` `+where, fleetID, from, to).
+		Scan(&total.Rides, &total.GrossFares, &total.PlatformCommission, &total.DriverEarnings, &total.FleetCommission)
+	if err != nil {
+		return total, nil, err
+	}
+
+	rows, err := db.Pool.Query(ctx,
+		`SELECT to_char("createdAt", 'YYYY-MM-DD'), `+fleetEarningsCols+` `+where+` GROUP BY 1 ORDER BY 1`, fleetID, from, to)
+	if err != nil {
+		return total, nil, err
+	}
+	defer rows.Close()
+
+	days := []fleetEarningsRow{}
+	for rows.Next() {
+		var d fleetEarningsRow
+		if err := rows.Scan(&d.Day, &d.Rides, &d.GrossFares, &d.PlatformCommission, &d.DriverEarnings, &d.FleetCommission); err != nil {
+			return total, nil, err
+		}
+		days = append(days, d)
+	}
+	return total, days, rows.Err()
+}
+
+// ══════════════════════════════════════════════════
+// Fleet Owner: Auth & Dashboard
+// ══════════════════════════════════════════════════
+
+// POST /api/v1/fleet/auth/login — sends an OTP to a registered fleet owner's phone
+func FleetLogin(c *gin.Context) {
+	var body struct {
+		PhoneNumber string `json:"phone_number" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid request", err)
+		return
+	}
+	phone, ok := requestPhone(c, body.PhoneNumber, "")
+	if !ok {
+		return
+	}
+	body.PhoneNumber = phone
+
+	var exists bool
+	db.Pool.QueryRow(c.Request.Context(),
+		`SELECT EXISTS (SELECT 1 FROM fleets WHERE "phoneNumber"=$1)`, body.PhoneNumber).Scan(&exists)
+	if !exists {
+		utils.RespondError(c, http.StatusNotFound, "No fleet is registered with this phone number", nil)
+		return
+	}
+
+	if err := utils.SendTwilioOTP(body.PhoneNumber); err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to send OTP", err)
+		return
+	}
+	utils.RespondSuccess(c, http.StatusOK, "OTP sent", nil)
+}
+
+// POST /api/v1/fleet/auth/verify
+func FleetVerify(c *gin.Context) {
+	var body struct {
+		PhoneNumber string `json:"phone_number" binding:"required"`
+		OTP         string `json:"otp" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid request", err)
+		return
+	}
+	phone, ok := requestPhone(c, body.PhoneNumber, "")
+	if !ok {
+		return
+	}
+	body.PhoneNumber = phone
+
+	if err := utils.VerifyTwilioOTP(body.PhoneNumber, body.OTP); err != nil {
+		utils.RespondErrorCode(c, http.StatusBadRequest, utils.CodeOTPInvalid, "Invalid OTP", err)
+		return
+	}
+
+	var fleet models.Fleet
+	err := scanFleet(db.Pool.QueryRow(c.Request.Context(),
+		`SELECT `+fleetSelectCols+` FROM fleets WHERE "phoneNumber"=$1`, body.PhoneNumber), &fleet)
+	if errors.Is(err, pgx.ErrNoRows) {
+		utils.RespondError(c, http.StatusNotFound, "No fleet is registered with this phone number", nil)
+		return
+	}
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Database error", err)
+		return
+	}
+	if fleet.Status != "active" {
+		utils.RespondErrorCode(c, http.StatusForbidden, utils.CodeAccountSuspended, "Your fleet account has been suspended. Contact support.", nil)
+		return
+	}
+
+	token, err := utils.GenerateFleetToken(fleet.ID)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to generate token", err)
+		return
+	}
+	utils.RespondSuccess(c, http.StatusOK, "Authentication successful", gin.H{
+		"accessToken": token,
+		"fleet":       fleet,
+	})
+}
+
+// GET /api/v1/fleet/me
+func GetFleetMe(c *gin.Context) {
+	fleet := c.MustGet("fleet").(*models.Fleet)
+	var drivers, active, online int
+	db.Pool.QueryRow(c.Request.Context(),
+		`SELECT COUNT(*), COUNT(*) FILTER (WHERE status='active'), COUNT(*) FILTER (WHERE "isOnline")
+		 FROM driver WHERE "fleetId"=$1`, fleet.ID).Scan(&drivers, &active, &online)
+
+	utils.RespondSuccess(c, http.StatusOK, "Fleet", gin.H{
+		"fleet": fleet,
+		"drivers": gin.H{
+			"total":  drivers,
+			"active": active,
+			"online": online,
+		},
+	})
+}
+
+// GET /api/v1/fleet/drivers?from=2026-01-01&to=2026-01-31 — each driver's rides, rating and earnings
+func GetFleetDrivers(c *gin.Context) {
+	fleet := c.MustGet("fleet").(*models.Fleet)
+	from, to := fleetReportWindow(c)
+	drivers, err := fleetDriverPerformance(c.Request.Context(), fleet.ID, from, to)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to fetch fleet drivers", err)
+		return
+	}
+	utils.RespondSuccess(c, http.StatusOK, "Fleet drivers", gin.H{
+		"from":    from.Format("2006-01-02"),
+		"to":      to.AddDate(0, 0, -1).Format("2006-01-02"),
+		"drivers": drivers,
+	})
+}
+
+// GET /api/v1/fleet/earnings?from=2026-01-01&to=2026-01-31 — the fleet's commission, by day
+func GetFleetEarnings(c *gin.Context) {
+	fleet := c.MustGet("fleet").(*models.Fleet)
+	from, to := fleetReportWindow(c)
+	total, byDay, err := fleetEarnings(c.Request.Context(), fleet.ID, from, to)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to fetch fleet earnings", err)
+		return
+	}
+	utils.RespondSuccess(c, http.StatusOK, "Fleet earnings", gin.H{
+		"from":              from.Format("2006-01-02"),
+		"to":                to.AddDate(0, 0, -1).Format("2006-01-02"),
+		"commissionPercent": fleet.CommissionPercent,
+		"total":             total,
+		"byDay":             byDay,
+	})
+}
+
+// ══════════════════════════════════════════════════
+// Admin: Fleet Oversight
+// ══════════════════════════════════════════════════
+
+// GET /api/v1/admin/fleets
+func AdminGetFleets(c *gin.Context) {
+	rows, err := db.Pool.Query(adminContext(c),
+		`SELECT `+fleetSelectCols+`, "tenantId", (SELECT COUNT(*) FROM driver d WHERE d."fleetId"=fleets.id)
+		 FROM fleets ORDER BY "createdAt" DESC`)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to fetch fleets", err)
+		return
+	}
+	defer rows.Close()
+
+	fleets := []gin.H{}
+	for rows.Next() {
+		var f models.Fleet
+		var tenantID string
+		var drivers int
+		if err := rows.Scan(&f.ID, &f.Name, &f.OwnerName, &f.PhoneNumber, &f.Email, &f.CommissionPercent, &f.Status,
+			&f.TotalEarned, &f.CreatedAt, &f.UpdatedAt, &tenantID, &drivers); err != nil {
+			continue
+		}
+		fleets = append(fleets, gin.H{"fleet": f, "tenantId": tenantID, "drivers": drivers})
+	}
+	utils.RespondSuccess(c, http.StatusOK, "Fleets", fleets)
+}
+
+// GET /api/v1/admin/fleet/:id?from=&to= — the fleet, its drivers' performance and its earnings
+func AdminGetFleetDetail(c *gin.Context) {
+	ctx := adminContext(c)
+	var f models.Fleet
+	err := scanFleet(db.Pool.QueryRow(ctx, `SELECT `+fleetSelectCols+` FROM fleets WHERE id=$1`, c.Param("id")), &f)
+	if errors.Is(err, pgx.ErrNoRows) {
+		utils.RespondError(c, http.StatusNotFound, "Fleet not found", nil)
+		return
+	}
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to fetch fleet", err)
+		return
+	}
+
+	from, to := fleetReportWindow(c)
+	drivers, err := fleetDriverPerformance(ctx, f.ID, from, to)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to fetch fleet drivers", err)
+		return
+	}
+	total, byDay, err := fleetEarnings(ctx, f.ID, from, to)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to fetch fleet earnings", err)
+		return
+	}
+
+	utils.RespondSuccess(c, http.StatusOK, "Fleet detail", gin.H{
+		"fleet":    f,
+		"from":     from.Format("2006-01-02"),
+		"to":       to.AddDate(0, 0, -1).Format("2006-01-02"),
+		"drivers":  drivers,
+		"earnings": total,
+		"byDay":    byDay,
+	})
+}
+
+// PUT /api/v1/admin/fleet?tenant= — create (no id) or update a fleet. A new fleet joins ?tenant=.
+// Commission changes apply to rides completed afterwards; suspending stops the split and the owner's access.
+func AdminUpsertFleet(c *gin.Context) {
+	var body struct {
+		ID                string  `json:"id"`
+		Name              string  `json:"name" binding:"required"`
+		OwnerName         string  `json:"ownerName" binding:"required"`
+		PhoneNumber       string  `json:"phoneNumber" binding:"required"`
+		Email             string  `json:"email"`
+		CommissionPercent float64 `json:"commissionPercent"`
+		Status            string  `json:"status"` // active | suspended, default active
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid request", err)
+		return
+	}
+	if body.CommissionPercent < 0 || body.CommissionPercent > 100 || math.IsNaN(body.CommissionPercent) {
+		utils.RespondError(c, http.StatusBadRequest, "commissionPercent must be between 0 and 100", nil)
+		return
+	}
+	phone, ok := requestPhone(c, body.PhoneNumber, "")
+	if !ok {
+		return
+	}
+	body.PhoneNumber = phone
+	if body.Status == "" {
+		body.Status = "active"
+	}
+	if body.Status != "active" && body.Status != "suspended" {
+		utils.RespondError(c, http.StatusBadRequest, "status must be active or suspended", nil)
+		return
+	}
+
+	var f models.Fleet
+	var err error
+	if body.ID == "" {
+		err = scanFleet(db.Pool.QueryRow(adminTenantContext(c),
+			`INSERT INTO fleets (name, "ownerName", "phoneNumber", email, "commissionPercent", status)
+			 VALUES ($1, $2, $3, $4, $5, $6) RETURNING `+fleetSelectCols,
+			body.Name, body.OwnerName, body.PhoneNumber, body.Email, body.CommissionPercent, body.Status), &f)
+	} else {
+		err = scanFleet(db.Pool.QueryRow(adminContext(c),
+			`UPDATE fleets SET name=$2, "ownerName"=$3, "phoneNumber"=$4, email=$5, "commissionPercent"=$6, status=$7, "updatedAt"=NOW()
+			 WHERE id=$1 RETURNING `+fleetSelectCols,
+			body.ID, body.Name, body.OwnerName, body.PhoneNumber, body.Email, body.CommissionPercent, body.Status), &f)
+	}
+	if errors.Is(err, pgx.ErrNoRows) {
+		utils.RespondError(c, http.StatusNotFound, "Fleet not found", nil)
+		return
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		utils.RespondError(c, http.StatusConflict, "Another fleet already uses this phone number", nil)
+		return
+	}
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to save fleet", err)
+		return
+	}
+	utils.RespondSuccess(c, http.StatusOK, "Fleet saved", f)
+}
+
+// PUT /api/v1/admin/driver/:id/fleet — assign a driver to a fleet of their tenant, or remove them ("fleetId": "")
+func AdminAssignDriverFleet(c *gin.Context) {
+	var body struct {
+		FleetID string `json:"fleetId"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid request", err)
+		return
+	}
+	ctx := adminContext(c)
+
+	var tenantID string
+	if err := db.Pool.QueryRow(ctx, `SELECT "tenantId" FROM driver WHERE id=$1`, c.Param("id")).Scan(&tenantID); err != nil {
+		utils.RespondError(c, http.StatusNotFound, "Driver not found", nil)
+		return
+	}
+	if body.FleetID != "" {
+		var exists bool
+		db.Pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM fleets WHERE id=$1 AND "tenantId"=$2)`, body.FleetID, tenantID).Scan(&exists)
+		if !exists {
+			utils.RespondError(c, http.StatusBadRequest, "Fleet not found in the driver's tenant", nil)
+			return
+		}
+	}
+
+	_, err := db.Pool.Exec(ctx,
+		`UPDATE driver SET "fleetId"=NULLIF($2, ''), "updatedAt"=NOW() WHERE id=$1`, c.Param("id"), body.FleetID)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to update driver fleet", err)
+		return
+	}
+	message := "Driver assigned to fleet"
+	if body.FleetID == "" {
+		message = "Driver removed from fleet"
+	}
+	utils.RespondSuccess(c, http.StatusOK, message, gin.H{"driverId": c.Param("id"), "fleetId": body.FleetID})
+}
+
+// fleetSummary is the admin driver detail's view of the driver's fleet, nil when independent.
+func fleetSummary(ctx context.Context, driverID string) *models.Fleet {
+	var f models.Fleet
+	err := scanFleet(db.Pool.QueryRow(ctx,
+		`SELECT `+fleetSelectCols+` FROM fleets WHERE id=(SELECT "fleetId" FROM driver WHERE id=$1)`, driverID), &f)
+	if err != nil {
+		return nil
+	}
+	return &f
+}
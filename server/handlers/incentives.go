@@ -0,0 +1,366 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+	"ridewave/db"
+	"ridewave/models"
+	"ridewave/stores"
+	"ridewave/utils"
+)
+
+// ══════════════════════════════════════════════════
+// Driver Incentives — "complete N rides in a window" quests
+// ══════════════════════════════════════════════════
+//
+// Finance admins set up incentives such as "complete 10 rides this weekend for a ₹500 bonus".
+// Each completed ride recounts the driver's rides in every incentive it falls inside. When the
+// count reaches the target the bonus goes straight into their wallet, once per incentive.
+
+// Where a driver's incentive stands relative to its window.
+const (
+	incentiveUpcoming = "upcoming"
+	incentiveRunning  = "active"
+	incentiveEnded    = "ended"
+)
+
+type incentive struct {
+	ID          string    `json:"id"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	TargetRides int       `json:"targetRides"`
+	Reward      float64   `json:"reward"`
+	VehicleType *string   `json:"vehicleType"`
+	StartsAt    time.Time `json:"startsAt"`
+	EndsAt      time.Time `json:"endsAt"`
+	IsActive    bool      `json:"isActive"`
+	CreatedBy   *string   `json:"createdBy"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+const incentiveSelectCols = `i.id, i.title, i.description, i."targetRides", i.reward, i."vehicleType", i."startsAt", i."endsAt",
+	i."isActive", i."createdBy", i."createdAt", i."updatedAt"`
+
+// scanIncentive reads incentiveSelectCols, followed by any extra columns into extra.
+func scanIncentive(scanner interface{ Scan(dest ...any) error }, i *incentive, extra ...any) error {
+	return scanner.Scan(append([]any{&i.ID, &i.Title, &i.Description, &i.TargetRides, &i.Reward, &i.VehicleType,
+		&i.StartsAt, &i.EndsAt, &i.IsActive, &i.CreatedBy, &i.CreatedAt, &i.UpdatedAt}, extra...)...)
+}
+
+// windowStatus reports whether the incentive hasn't started, is running or is over at now.
+func (i incentive) windowStatus(now time.Time) string {
+	switch {
+	case now.Before(i.StartsAt):
+		return incentiveUpcoming
+	case now.Before(i.EndsAt):
+		return incentiveRunning
+	}
+	return incentiveEnded
+}
+
+// trackIncentiveProgress recounts the driver's completed rides for every active incentive the
+// ride falls inside, and pays the bonus for any that have now reached their target. Counting
+// rather than incrementing means a repeated call can't count a ride twice.
+func trackIncentiveProgress(rideID string) {
+	ctx := db.Unscoped()
+	rows, err := db.Pool.Query(ctx,
+		`WITH ride AS (
+			SELECT "driverId", COALESCE("vehicleType", '') AS "vehicleType", COALESCE("completedAt", NOW()) AS at
+			FROM rides WHERE id=$1 AND status='Completed' AND "driverId" IS NOT NULL
+		 )
+		 INSERT INTO driver_incentive_progress ("incentiveId", "driverId", rides, "achievedAt")
+		 SELECT i.id, ride."driverId", n.rides, CASE WHEN n.rides >= i."targetRides" THEN NOW() END
+		 FROM ride
+		 JOIN incentives i ON i."isActive" AND ride.at >= i."startsAt" AND ride.at < i."endsAt"
+		 AND (i."vehicleType" IS NULL OR i."vehicleType"=ride."vehicleType")
+		 CROSS JOIN LATERAL (
+			SELECT COUNT(*)::int AS rides FROM rides r
+			WHERE r."driverId"=ride."driverId" AND r.status='Completed'
+			AND r."completedAt" >= i."startsAt" AND r."completedAt" < i."endsAt"
+			AND (i."vehicleType" IS NULL OR r."vehicleType"=i."vehicleType")
+		 ) n
+		 ON CONFLICT ("incentiveId", "driverId") DO UPDATE SET rides=EXCLUDED.rides,
+		 "achievedAt"=COALESCE(driver_incentive_progress."achievedAt", EXCLUDED."achievedAt"), "updatedAt"=NOW()
+		 RETURNING "incentiveId", "driverId", "achievedAt" IS NOT NULL AND "rewardedAt" IS NULL`, rideID)
+	if err != nil {
+		utils.Logger.Error("Failed to track incentive progress", zap.String("rideId", rideID), zap.Error(err))
+		return
+	}
+	var due []string
+	var driverID string
+	for rows.Next() {
+		var incentiveID string
+		var payable bool
+		if rows.Scan(&incentiveID, &driverID, &payable) == nil && payable {
+			due = append(due, incentiveID)
+		}
+	}
+	rows.Close()
+	if len(due) == 0 {
+		return
+	}
+
+	rows, err = db.Pool.Query(ctx, `SELECT `+incentiveSelectCols+` FROM incentives i WHERE i.id=ANY($1)`, due)
+	if err != nil {
+		utils.Logger.Error("Failed to load achieved incentives", zap.String("rideId", rideID), zap.Error(err))
+		return
+	}
+	var achieved []incentive
+	for rows.Next() {
+		var i incentive
+		if scanIncentive(rows, &i) == nil {
+			achieved = append(achieved, i)
+		}
+	}
+	rows.Close()
+
+	for _, i := range achieved {
+		paid, err := stores.CreditIncentiveBonus(ctx, driverID, i.ID, rideID, i.Reward)
+		if err != nil {
+			utils.Logger.Error("Failed to credit incentive bonus",
+				zap.String("incentiveId", i.ID), zap.String("driverId", driverID), zap.Error(err))
+			continue
+		}
+		if !paid {
+			continue
+		}
+		utils.Logger.Info("Incentive achieved", zap.String("incentiveId", i.ID), zap.String("driverId", driverID),
+			zap.Float64("reward", i.Reward))
+		sendNotifications(ctx, outboxPush("driver", driverID, rideID, "Bonus earned 🎉",
+			fmt.Sprintf("You completed \"%s\" — %s has been added to your wallet.", i.Title, formatMoney("", i.Reward)), utils.FCMData{
+				"type":        "incentive_rewarded",
+				"incentiveId": i.ID,
+			}))
+	}
+}
+
+// ══════════════════════════════════════════════════
+// Driver: Incentives
+// ══════════════════════════════════════════════════
+
+// GET /api/v1/driver/incentives — upcoming and running incentives for the driver's vehicle type,
+// plus those that ended in the last week, each with the driver's progress
+func GetDriverIncentives(c *gin.Context) {
+	driver := c.MustGet("driver").(*models.Driver)
+
+	rows, err := db.Pool.Query(c.Request.Context(),
+		`SELECT `+incentiveSelectCols+`, COALESCE(p.rides, 0), p."achievedAt", p."rewardedAt"
+		 FROM incentives i
+		 LEFT JOIN driver_incentive_progress p ON p."incentiveId"=i.id AND p."driverId"=$1
+		 WHERE i."isActive" AND i."endsAt" > NOW() - INTERVAL '7 days'
+		 AND (i."vehicleType" IS NULL OR i."vehicleType"=$2)
+		 ORDER BY i."endsAt"`, driver.ID, driver.VehicleType)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to fetch incentives", err)
+		return
+	}
+	defer rows.Close()
+
+	type driverIncentive struct {
+		incentive
+		Status     string     `json:"status"` // upcoming | active | ended
+		Rides      int        `json:"rides"`
+		Remaining  int        `json:"remaining"`
+		AchievedAt *time.Time `json:"achievedAt"`
+		RewardedAt *time.Time `json:"rewardedAt"`
+	}
+	now := time.Now()
+	incentives := []driverIncentive{}
+	for rows.Next() {
+		var d driverIncentive
+		if err := scanIncentive(rows, &d.incentive, &d.Rides, &d.AchievedAt, &d.RewardedAt); err != nil {
+			continue
+		}
+		d.Status = d.windowStatus(now)
+		d.Remaining = max(d.TargetRides-d.Rides, 0)
+		incentives = append(incentives, d)
+	}
+	utils.RespondSuccess(c, http.StatusOK, "Incentives", gin.H{"incentives": incentives})
+}
+
+// ══════════════════════════════════════════════════
+// Admin: Incentives
+// ══════════════════════════════════════════════════
+
+// GET /api/v1/admin/incentives?status=active — every incentive with how many drivers took part,
+// reached the target and were paid
+func AdminGetIncentives(c *gin.Context) {
+	status := c.Query("status")
+	if status != "" && status != incentiveUpcoming && status != incentiveRunning && status != incentiveEnded {
+		utils.RespondError(c, http.StatusBadRequest, "status must be upcoming, active or ended", nil)
+		return
+	}
+
+	rows, err := db.Pool.Query(adminContext(c),
+		`SELECT `+incentiveSelectCols+`, COUNT(p."driverId"), COUNT(p."achievedAt"), COUNT(p."rewardedAt")
+		 FROM incentives i
+		 LEFT JOIN driver_incentive_progress p ON p."incentiveId"=i.id
+		 GROUP BY i.id ORDER BY i."startsAt" DESC LIMIT 200`)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to fetch incentives", err)
+		return
+	}
+	defer rows.Close()
+
+	type incentiveStats struct {
+		incentive
+		Status       string  `json:"status"`
+		Participants int     `json:"participants"`
+		Achieved     int     `json:"achieved"`
+		Rewarded     int     `json:"rewarded"`
+		PaidOut      float64 `json:"paidOut"`
+	}
+	now := time.Now()
+	incentives := []incentiveStats{}
+	for rows.Next() {
+		var s incentiveStats
+		if err := scanIncentive(rows, &s.incentive, &s.Participants, &s.Achieved, &s.Rewarded); err != nil {
+			continue
+		}
+		s.Status = s.windowStatus(now)
+		if status != "" && s.Status != status {
+			continue
+		}
+		s.PaidOut = round2(float64(s.Rewarded) * s.Reward)
+		incentives = append(incentives, s)
+	}
+	utils.RespondSuccess(c, http.StatusOK, "Incentives", gin.H{"incentives": incentives})
+}
+
+// GET /api/v1/admin/incentive/:id — the incentive and its drivers, furthest along first
+func AdminGetIncentive(c *gin.Context) {
+	ctx := adminContext(c)
+	var i incentive
+	err := scanIncentive(db.Pool.QueryRow(ctx, `SELECT `+incentiveSelectCols+` FROM incentives i WHERE i.id=$1`, c.Param("id")), &i)
+	if errors.Is(err, pgx.ErrNoRows) {
+		utils.RespondError(c, http.StatusNotFound, "Incentive not found", nil)
+		return
+	}
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to fetch incentive", err)
+		return
+	}
+
+	rows, err := db.Pool.Query(ctx,
+		`SELECT p."driverId", COALESCE(d.name, ''), d.phone_number, p.rides, p."achievedAt", p."rewardedAt", p."rewardRideId"
+		 FROM driver_incentive_progress p JOIN driver d ON d.id=p."driverId"
+		 WHERE p."incentiveId"=$1 ORDER BY p.rides DESC, p."achievedAt" LIMIT 500`, i.ID)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to fetch incentive progress", err)
+		return
+	}
+	defer rows.Close()
+
+	type driverProgress struct {
+		DriverID     string     `json:"driverId"`
+		Name         string     `json:"name"`
+		PhoneNumber  string     `json:"phoneNumber"`
+		Rides        int        `json:"rides"`
+		AchievedAt   *time.Time `json:"achievedAt"`
+		RewardedAt   *time.Time `json:"rewardedAt"`
+		RewardRideID *string    `json:"rewardRideId"`
+	}
+	drivers := []driverProgress{}
+	for rows.Next() {
+		var p driverProgress
+		if err := rows.Scan(&p.DriverID, &p.Name, &p.PhoneNumber, &p.Rides, &p.AchievedAt, &p.RewardedAt, &p.RewardRideID); err != nil {
+			continue
+		}
+		drivers = append(drivers, p)
+	}
+	utils.RespondSuccess(c, http.StatusOK, "Incentive", gin.H{
+		"incentive": i,
+		"status":    i.windowStatus(time.Now()),
+		"drivers":   drivers,
+	})
+}
+
+// PUT /api/v1/admin/incentive — create, or update with id. Edits apply from each driver's next
+// completed ride; bonuses already paid stand.
+func AdminUpsertIncentive(c *gin.Context) {
+	admin := c.MustGet("admin").(*models.AdminAccount)
+	var body struct {
+		ID          string    `json:"id"`
+		Title       string    `json:"title" binding:"required"`
+		Description string    `json:"description"`
+		TargetRides int       `json:"targetRides" binding:"required"`
+		Reward      float64   `json:"reward" binding:"required"`
+		VehicleType string    `json:"vehicleType"` // empty: any vehicle type
+		StartsAt    time.Time `json:"startsAt" binding:"required"`
+		EndsAt      time.Time `json:"endsAt" binding:"required"`
+		IsActive    *bool     `json:"isActive"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid request", err)
+		return
+	}
+	body.Title = strings.TrimSpace(body.Title)
+	if body.Title == "" || body.TargetRides < 1 || body.Reward <= 0 {
+		utils.RespondError(c, http.StatusBadRequest, "title, a targetRides of at least 1 and a positive reward are required", nil)
+		return
+	}
+	if !body.EndsAt.After(body.StartsAt) {
+		utils.RespondError(c, http.StatusBadRequest, "endsAt must be after startsAt", nil)
+		return
+	}
+	var vehicleType *string
+	if name := strings.TrimSpace(body.VehicleType); name != "" {
+		vt := findVehicleType(adminContext(c), name)
+		if vt == nil {
+			utils.RespondError(c, http.StatusBadRequest, "Unknown vehicle type "+name, nil)
+			return
+		}
+		vehicleType = &vt.Name
+	}
+	isActive := true
+	if body.IsActive != nil {
+		isActive = *body.IsActive
+	}
+
+	var i incentive
+	var err error
+	if body.ID == "" {
+		err = scanIncentive(db.Pool.QueryRow(adminContext(c),
+			`INSERT INTO incentives AS i (title, description, "targetRides", reward, "vehicleType", "startsAt", "endsAt", "isActive", "createdBy")
+			 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING `+incentiveSelectCols,
+			body.Title, body.Description, body.TargetRides, round2(body.Reward), vehicleType, body.StartsAt, body.EndsAt, isActive, admin.Email), &i)
+	} else {
+		err = scanIncentive(db.Pool.QueryRow(adminContext(c),
+			`UPDATE incentives i SET title=$2, description=$3, "targetRides"=$4, reward=$5, "vehicleType"=$6,
+			 "startsAt"=$7, "endsAt"=$8, "isActive"=$9, "updatedAt"=NOW()
+			 WHERE id=$1 RETURNING `+incentiveSelectCols,
+			body.ID, body.Title, body.Description, body.TargetRides, round2(body.Reward), vehicleType, body.StartsAt, body.EndsAt, isActive), &i)
+	}
+	if errors.Is(err, pgx.ErrNoRows) {
+		utils.RespondError(c, http.StatusNotFound, "Incentive not found", nil)
+		return
+	}
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to save incentive", err)
+		return
+	}
+	utils.RespondSuccess(c, http.StatusOK, "Incentive saved", gin.H{"incentive": i})
+}
+
+// DELETE /api/v1/admin/incentive/:id — soft delete (deactivate); progress stops counting, paid bonuses stand
+func AdminDeleteIncentive(c *gin.Context) {
+	tag, err := db.Pool.Exec(adminContext(c),
+		`UPDATE incentives SET "isActive"=FALSE, "updatedAt"=NOW() WHERE id=$1`, c.Param("id"))
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to deactivate incentive", err)
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		utils.RespondError(c, http.StatusNotFound, "Incentive not found", nil)
+		return
+	}
+	utils.RespondSuccess(c, http.StatusOK, "Incentive deactivated", nil)
+}
@@ -0,0 +1,304 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+	"ridewave/db"
+	"ridewave/models"
+	"ridewave/utils"
+)
+
+// ══════════════════════════════════════════════════
+// Ride Invoices — fare breakdown emailed on completion, re-downloadable as PDF or HTML
+// ══════════════════════════════════════════════════
+
+type rideInvoice struct {
+	Number       string
+	IssuedAt     time.Time
+	RideID       string
+	VehicleType  string
+	Pickup       string
+	Dropoff      string
+	DistanceKm   float64
+	DurationMin  int
+	CompletedAt  time.Time
+	PaymentMode  string
+	Currency     string
+	RiderID      string
+	RiderName    string
+	RiderEmail   *string
+	DriverName   string
+	BaseFare     float64
+	DistanceFare float64
+	TimeFare     float64
+	Waiting      float64
+	Surge        float64
+	PlatformFee  float64
+	PromoCode    string
+	Discount     float64
+	Fare         float64
+	Tip          float64
+	Total        float64
+	EmailedAt    *time.Time
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// recordRideInvoice snapshots the completed ride's fare breakdown and numbers the invoice.
+// The vehicle type's rates at completion are used; whatever they don't explain (surge, an
+// accepted bid, rounding up) goes on the surge line so the lines always add up to the fare.
+// The waiting charge is read from the ride as it is, so it's left out of the surge line.
+// Calling it again for the same ride is a no-op.
+func recordRideInvoice(ctx context.Context, rideID string) error {
+	var tenantID, vehicleType string
+	var meters, seconds int
+	var charge, discount, waiting float64
+	var originalFare *float64
+	var promoCode *string
+	err := db.Pool.QueryRow(ctx,
+		`SELECT COALESCE("tenantId", ''), COALESCE("vehicleType", ''), COALESCE("estimatedDistance", 0), COALESCE("estimatedDuration", 0),
+		 charge, "originalFare", COALESCE(discount, 0), "promoCode", "waitingCharge"
+		 FROM rides WHERE id=$1 AND status='Completed'`, rideID).
+		Scan(&tenantID, &vehicleType, &meters, &seconds, &charge, &originalFare, &discount, &promoCode, &waiting)
+	if err != nil {
+		return err
+	}
+
+	b := calculateFareBreakdown(db.WithTenant(ctx, tenantID), vehicleType, meters, seconds)
+	base, distance, timeFare, fee := round2(b.BaseFare), round2(b.DistanceFare), round2(b.TimeFare), round2(b.PlatformFee)
+	beforeDiscount := charge - waiting + discount
+	if originalFare != nil {
+		beforeDiscount = *originalFare
+	}
+	surge := round2(beforeDiscount - (base + distance + timeFare + fee))
+
+	_, err = db.Pool.Exec(ctx,
+		`INSERT INTO ride_invoices ("rideId", "invoiceNumber", "baseFare", "distanceFare", "timeFare", surge, "platformFee", discount, "promoCode")
+		 VALUES ($1, 'RW-' || to_char(NOW(), 'YYYYMMDD') || '-' || lpad(nextval('ride_invoice_seq')::text, 6, '0'),
+		 $2, $3, $4, $5, $6, $7, $8)
+		 ON CONFLICT ("rideId") DO NOTHING`,
+		rideID, base, distance, timeFare, surge, fee, discount, promoCode)
+	return err
+}
+
+// loadRideInvoice joins the invoice snapshot with the ride as it is now, so a tip added after
+// completion shows up on a re-download.
+func loadRideInvoice(ctx context.Context, rideID string) (*rideInvoice, error) {
+	var inv rideInvoice
+	var riderName, promoCode *string
+	var completedAt *time.Time
+	err := db.Pool.QueryRow(ctx,
+		`SELECT i."invoiceNumber", i."issuedAt", r.id, COALESCE(r."vehicleType", ''), r."currentLocationName", r."destinationLocationName",
+		 COALESCE(r."estimatedDistance", 0) / 1000.0, COALESCE(r."estimatedDuration", 0) / 60, r."completedAt",
+		 COALESCE(r."paymentMode", ''), r.currency, u.id, u.name, u.email, COALESCE(d.name, ''),
+		 i."baseFare", i."distanceFare", i."timeFare", i.surge, i."platformFee", i."promoCode", i.discount,
+		 r."waitingCharge", r.charge, COALESCE(r.tips, 0), i."emailedAt"
+		 FROM ride_invoices i
+		 JOIN rides r ON r.id=i."rideId"
+		 JOIN "user" u ON u.id=r."userId"
+		 LEFT JOIN driver d ON d.id=r."driverId"
+		 WHERE i."rideId"=$1`, rideID).
+		Scan(&inv.Number, &inv.IssuedAt, &inv.RideID, &inv.VehicleType, &inv.Pickup, &inv.Dropoff,
+			&inv.DistanceKm, &inv.DurationMin, &completedAt,
+			&inv.PaymentMode, &inv.Currency, &inv.RiderID, &riderName, &inv.RiderEmail, &inv.DriverName,
+			&inv.BaseFare, &inv.DistanceFare, &inv.TimeFare, &inv.Surge, &inv.PlatformFee, &promoCode, &inv.Discount,
+			&inv.Waiting, &inv.Fare, &inv.Tip, &inv.EmailedAt)
+	if err != nil {
+		return nil, err
+	}
+	if riderName != nil {
+		inv.RiderName = *riderName
+	}
+	if promoCode != nil {
+		inv.PromoCode = *promoCode
+	}
+	if completedAt != nil {
+		inv.CompletedAt = *completedAt
+	}
+	inv.Total = round2(inv.Fare + inv.Tip)
+	return &inv, nil
+}
+
+// issueRideInvoice records the invoice for a just-completed ride and emails it to the rider,
+// if they have an email address and haven't turned receipts off. Runs in the background from
+// applyRideCompletion.
+func issueRideInvoice(rideID string) {
+	ctx := db.Unscoped()
+	if err := recordRideInvoice(ctx, rideID); err != nil {
+		utils.Logger.Error("Failed to record ride invoice", zap.String("rideId", rideID), zap.Error(err))
+		return
+	}
+	inv, err := loadRideInvoice(ctx, rideID)
+	if err != nil {
+		utils.Logger.Error("Failed to load ride invoice", zap.String("rideId", rideID), zap.Error(err))
+		return
+	}
+	if inv.RiderEmail == nil || *inv.RiderEmail == "" || inv.EmailedAt != nil {
+		return
+	}
+
+	html, err := renderInvoiceHTML(inv)
+	if err != nil {
+		utils.Logger.Error("Failed to render ride invoice", zap.String("rideId", rideID), zap.Error(err))
+		return
+	}
+	err = utils.SendEmail(utils.Recipient{Type: "user", ID: inv.RiderID, Category: utils.NotifyReceipts},
+		[]string{*inv.RiderEmail}, "Your RideWave receipt - "+inv.CompletedAt.Format("2 Jan 2006"), html,
+		utils.EmailAttachment{Filename: inv.Number + ".pdf", ContentType: "application/pdf", Data: renderInvoicePDF(inv)})
+	if errors.Is(err, utils.ErrNotificationMuted) {
+		return
+	}
+	if err != nil {
+		utils.Logger.Warn("Failed to email ride invoice", zap.String("rideId", rideID), zap.Error(err))
+		return
+	}
+	db.Pool.Exec(ctx, `UPDATE ride_invoices SET "emailedAt"=NOW() WHERE "rideId"=$1`, rideID)
+}
+
+// GET /api/v1/user/ride/:id/invoice?format=pdf|html
+func GetRideInvoice(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+	rideID := c.Param("id")
+	ctx := c.Request.Context()
+
+	var status string
+	err := db.Pool.QueryRow(ctx, `SELECT status FROM rides WHERE id=$1 AND "userId"=$2`, rideID, user.ID).Scan(&status)
+	if err != nil {
+		utils.RespondErrorCode(c, http.StatusNotFound, utils.CodeRideNotFound, "Ride not found", nil)
+		return
+	}
+	if status != "Completed" {
+		utils.RespondError(c, http.StatusConflict, "An invoice is only available once the ride is completed", nil)
+		return
+	}
+
+	inv, err := loadRideInvoice(ctx, rideID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Completed before invoices existed, or the completion hook failed — issue it now
+		if err = recordRideInvoice(ctx, rideID); err == nil {
+			inv, err = loadRideInvoice(ctx, rideID)
+		}
+	}
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to load invoice", err)
+		return
+	}
+
+	switch c.DefaultQuery("format", "pdf") {
+	case "pdf":
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.pdf"`, inv.Number))
+		c.Data(http.StatusOK, "application/pdf", renderInvoicePDF(inv))
+	case "html":
+		html, err := renderInvoiceHTML(inv)
+		if err != nil {
+			utils.RespondError(c, http.StatusInternalServerError, "Failed to render invoice", err)
+			return
+		}
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(html))
+	default:
+		utils.RespondError(c, http.StatusBadRequest, "format must be pdf or html", nil)
+	}
+}
+
+// invoiceLine is one row of the fare breakdown; zero-value optional lines are left out.
+type invoiceLine struct {
+	Label  string
+	Amount float64
+}
+
+func invoiceLines(inv *rideInvoice) []invoiceLine {
+	lines := []invoiceLine{
+		{"Base fare", inv.BaseFare},
+		{fmt.Sprintf("Distance (%.1f km)", inv.DistanceKm), inv.DistanceFare},
+		{fmt.Sprintf("Time (%d min)", inv.DurationMin), inv.TimeFare},
+	}
+	if inv.Waiting != 0 {
+		lines = append(lines, invoiceLine{"Waiting at pickup", inv.Waiting})
+	}
+	if inv.Surge != 0 {
+		lines = append(lines, invoiceLine{"Surge & adjustments", inv.Surge})
+	}
+	lines = append(lines, invoiceLine{"Platform fee", inv.PlatformFee})
+	if inv.Discount != 0 {
+		label := "Promo discount"
+		if inv.PromoCode != "" {
+			label += " (" + inv.PromoCode + ")"
+		}
+		lines = append(lines, invoiceLine{label, -inv.Discount})
+	}
+	lines = append(lines, invoiceLine{"Ride fare", inv.Fare})
+	if inv.Tip != 0 {
+		lines = append(lines, invoiceLine{"Tip", inv.Tip})
+	}
+	return lines
+}
+
+var invoiceTemplate = template.Must(template.New("invoice").Parse(`<!DOCTYPE html>
+<html><body style="font-family: Arial, sans-serif; color: #222; max-width: 560px;">
+<h2>RideWave receipt</h2>
+<p>Hi {{if .Inv.RiderName}}{{.Inv.RiderName}}{{else}}there{{end}}, thanks for riding with us.</p>
+<p style="color: #666; font-size: 13px;">
+Invoice {{.Inv.Number}} &middot; {{.Inv.CompletedAt.Format "2 Jan 2006, 15:04"}}<br>
+{{.Inv.Pickup}} &rarr; {{.Inv.Dropoff}}<br>
+{{if .Inv.VehicleType}}{{.Inv.VehicleType}}{{end}}{{if .Inv.DriverName}} with {{.Inv.DriverName}}{{end}}{{if .Inv.PaymentMode}} &middot; Paid by {{.Inv.PaymentMode}}{{end}}
+</p>
+<table style="width: 100%; border-collapse: collapse; font-size: 14px;">
+{{range .Lines}}<tr><td style="padding: 4px 0;">{{.Label}}</td><td style="text-align: right;">{{printf "%.2f" .Amount}}</td></tr>
+{{end}}<tr style="border-top: 1px solid #ccc; font-weight: bold;"><td style="padding: 6px 0;">Total ({{.Inv.Currency}})</td><td style="text-align: right;">{{printf "%.2f" .Inv.Total}}</td></tr>
+</table>
+<p style="color: #666; font-size: 12px;">Ride ID {{.Inv.RideID}}. The PDF invoice is attached and can be downloaded again from the app.</p>
+</body></html>`))
+
+func renderInvoiceHTML(inv *rideInvoice) (string, error) {
+	var buf bytes.Buffer
+	err := invoiceTemplate.Execute(&buf, struct {
+		Inv   *rideInvoice
+		Lines []invoiceLine
+	}{inv, invoiceLines(inv)})
+	return buf.String(), err
+}
+
+func renderInvoicePDF(inv *rideInvoice) []byte {
+	pdf := utils.NewTextPDF()
+	pdf.Line("RideWave - Ride Invoice", 14, true)
+	pdf.Space(6)
+	pdf.Line("Invoice: "+inv.Number, 9, false)
+	pdf.Line("Issued:  "+inv.IssuedAt.Format("02 Jan 2006"), 9, false)
+	pdf.Line("Ride:    "+inv.RideID, 9, false)
+	pdf.Line("Rider:   "+inv.RiderName, 9, false)
+	if inv.DriverName != "" {
+		pdf.Line("Driver:  "+inv.DriverName, 9, false)
+	}
+	pdf.Space(8)
+
+	pdf.Line("Trip", 11, true)
+	pdf.Line("From:    "+inv.Pickup, 9, false)
+	pdf.Line("To:      "+inv.Dropoff, 9, false)
+	pdf.Line("Date:    "+inv.CompletedAt.Format("02 Jan 2006 15:04"), 9, false)
+	if inv.VehicleType != "" {
+		pdf.Line("Vehicle: "+inv.VehicleType, 9, false)
+	}
+	if inv.PaymentMode != "" {
+		pdf.Line("Payment: "+inv.PaymentMode, 9, false)
+	}
+	pdf.Space(8)
+
+	pdf.Line("Fare breakdown", 11, true)
+	for _, l := range invoiceLines(inv) {
+		pdf.Line(fmt.Sprintf("%-36s %14s", l.Label, formatAmount(l.Amount)), 9, false)
+	}
+	pdf.Line(fmt.Sprintf("%-36s %14s", "Total ("+inv.Currency+")", formatAmount(inv.Total)), 9, true)
+	return pdf.Bytes()
+}
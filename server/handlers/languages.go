@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"ridewave/db"
+	"ridewave/i18n"
+	"ridewave/models"
+	"ridewave/utils"
+)
+
+// ══════════════════════════════════════════════════
+// Language Matching — driver languages vs rider preference
+// ══════════════════════════════════════════════════
+
+// dispatchLanguageHeadstart is how long language-matched drivers see a request before everyone else
+// (DISPATCH_LANGUAGE_HEADSTART_SECONDS, default 0 = no weighting).
+func dispatchLanguageHeadstart() time.Duration {
+	if val, err := strconv.Atoi(os.Getenv("DISPATCH_LANGUAGE_HEADSTART_SECONDS")); err == nil && val > 0 {
+		return time.Duration(val) * time.Second
+	}
+	return 0
+}
+
+// normalizeLanguages lowercases and de-duplicates language codes ("HI", "hi " → "hi").
+func normalizeLanguages(langs []string) []string {
+	seen := map[string]bool{}
+	out := []string{}
+	for _, l := range langs {
+		l = strings.ToLower(strings.TrimSpace(l))
+		if l == "" || seen[l] {
+			continue
+		}
+		seen[l] = true
+		out = append(out, l)
+	}
+	return out
+}
+
+// speaksLanguage reports whether lang is among the driver's languages.
+func speaksLanguage(languages []string, lang string) bool {
+	for _, l := range languages {
+		if l == lang {
+			return true
+		}
+	}
+	return false
+}
+
+// rideLanguageMatch returns the rider's preferred language, the driver's languages and their overlap.
+func rideLanguageMatch(ctx context.Context, userID, driverID string) gin.H {
+	var riderLang string
+	driverLangs := []string{}
+	db.Pool.QueryRow(ctx,
+		`SELECT COALESCE("preferredLanguage", '') FROM "user" WHERE id=$1`, userID).Scan(&riderLang)
+	db.Pool.QueryRow(ctx,
+		`SELECT COALESCE(languages, '{}') FROM driver WHERE id=$1`, driverID).Scan(&driverLangs)
+
+	shared := []string{}
+	if riderLang != "" && speaksLanguage(driverLangs, riderLang) {
+		shared = append(shared, riderLang)
+	}
+	return gin.H{
+		"riderLanguage":   riderLang,
+		"driverLanguages": driverLangs,
+		"sharedLanguages": shared,
+	}
+}
+
+// PUT /api/v1/driver/languages
+func UpdateDriverLanguages(c *gin.Context) {
+	driver := c.MustGet("driver").(*models.Driver)
+	var body struct {
+		Languages []string `json:"languages" binding:"required"` // ISO 639-1 codes, e.g. ["ta", "en"]
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid request", err)
+		return
+	}
+
+	langs := normalizeLanguages(body.Languages)
+	_, err := db.Pool.Exec(c.Request.Context(),
+		`UPDATE driver SET languages=$1, "updatedAt"=NOW() WHERE id=$2`, langs, driver.ID)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to update languages", err)
+		return
+	}
+	utils.RespondSuccess(c, http.StatusOK, "Languages updated", gin.H{"languages": langs})
+}
+
+// PUT /api/v1/user/preferred-language
+func UpdatePreferredLanguage(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+	var body struct {
+		Language string `json:"language" binding:"required"` // ISO 639-1 code, e.g. "hi"
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid request", err)
+		return
+	}
+
+	lang := strings.ToLower(strings.TrimSpace(body.Language))
+	_, err := db.Pool.Exec(c.Request.Context(),
+		`UPDATE "user" SET "preferredLanguage"=NULLIF($1, ''), "updatedAt"=NOW() WHERE id=$2`, lang, user.ID)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to update language", err)
+		return
+	}
+	// Any language helps driver matching; only ones with a catalog change the app's messages
+	c.Set(i18n.ProfileLanguageKey, lang)
+	utils.RespondSuccess(c, http.StatusOK, "Preferred language updated", gin.H{"language": lang, "localized": i18n.Supported(lang)})
+}
+
+// PUT /api/v1/driver/preferred-language
+// The language the driver's responses and notifications are sent in.
+func UpdateDriverPreferredLanguage(c *gin.Context) {
+	driver := c.MustGet("driver").(*models.Driver)
+	var body struct {
+		Language string `json:"language" binding:"required"` // ISO 639-1 code, e.g. "ta"
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid request", err)
+		return
+	}
+
+	lang := strings.ToLower(strings.TrimSpace(body.Language))
+	if !i18n.Supported(lang) {
+		utils.RespondError(c, http.StatusBadRequest, "Supported languages: "+strings.Join(i18n.Languages(), ", "), nil)
+		return
+	}
+	_, err := db.Pool.Exec(c.Request.Context(),
+		`UPDATE driver SET "preferredLanguage"=$1, "updatedAt"=NOW() WHERE id=$2`, lang, driver.ID)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to update language", err)
+		return
+	}
+	c.Set(i18n.ProfileLanguageKey, lang)
+	utils.RespondSuccess(c, http.StatusOK, "Preferred language updated", gin.H{"language": lang})
+}
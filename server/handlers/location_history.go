@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"ridewave/db"
+	"ridewave/models"
+	"ridewave/stores"
+	"ridewave/utils"
+)
+
+// ══════════════════════════════════════════════════
+// Driver Location History — batched pings from the background
+// ══════════════════════════════════════════════════
+//
+// The driver app buffers GPS fixes while in the background and uploads them in batches.
+// Each batch is snapped to the road in one call and kept for route playback; the newest
+// fix also moves the driver's live position if it is fresh.
+
+const (
+	maxPointAge     = 24 * time.Hour  // older fixes are dropped
+	livePointMaxAge = 2 * time.Minute // newer fixes also move the live position
+)
+
+type locationPoint struct {
+	Lat        float64   `json:"lat" binding:"required"`
+	Lng        float64   `json:"lng" binding:"required"`
+	Heading    *float64  `json:"heading"`
+	Speed      *float64  `json:"speed"`    // m/s
+	Accuracy   *float64  `json:"accuracy"` // metres
+	RecordedAt time.Time `json:"recordedAt" binding:"required"`
+}
+
+// PUT /api/v1/driver/locations — {points: [{lat, lng, heading, speed, accuracy, recordedAt}]}, up to 100
+func UpdateDriverLocationsBatch(c *gin.Context) {
+	driver := c.MustGet("driver").(*models.Driver)
+	ctx := c.Request.Context()
+	var body struct {
+		Points []locationPoint `json:"points" binding:"required,min=1,max=100,dive"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "points must hold 1 to 100 fixes with lat, lng and recordedAt", err)
+		return
+	}
+
+	// Drop fixes that are off the map, too old or from the future, then replay in time order
+	now := time.Now()
+	points := make([]locationPoint, 0, len(body.Points))
+	for _, p := range body.Points {
+		if p.Lat < -90 || p.Lat > 90 || p.Lng < -180 || p.Lng > 180 ||
+			now.Sub(p.RecordedAt) > maxPointAge || p.RecordedAt.Sub(now) > livePointMaxAge {
+			continue
+		}
+		points = append(points, p)
+	}
+	rejected := len(body.Points) - len(points)
+	if len(points) == 0 {
+		utils.RespondError(c, http.StatusBadRequest, "No usable points in batch", nil)
+		return
+	}
+	sort.SliceStable(points, func(i, j int) bool { return points[i].RecordedAt.Before(points[j].RecordedAt) })
+
+	raw := make([][2]float64, len(points))
+	for i, p := range points {
+		raw[i] = [2]float64{p.Lat, p.Lng}
+	}
+	snapped, snapErr := utils.NewOlaMapsClient().SnapPathToRoad(raw)
+	if snapErr != nil {
+		utils.Logger.Warn("Batch SnapToRoad failed, storing raw coordinates", zap.String("driverId", driver.ID), zap.Error(snapErr))
+		snapped = raw
+	}
+
+	// Fixes taken during a ride are tagged with it for playback
+	var rideID *string
+	db.Pool.QueryRow(ctx,
+		`SELECT id FROM rides WHERE "driverId"=$1 AND status IN ('Accepted','Arriving','InProgress')
+		 ORDER BY "createdAt" DESC LIMIT 1`, driver.ID).Scan(&rideID)
+
+	if err := saveLocationHistory(ctx, driver.ID, rideID, points, snapped); err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to store locations", err)
+		return
+	}
+
+	latest, last := points[len(points)-1], snapped[len(snapped)-1]
+	live := now.Sub(latest.RecordedAt) <= livePointMaxAge
+	if live {
+		stores.UpdateDriverLocation(ctx, driver.ID, last[0], last[1], "")
+	}
+
+	utils.RespondSuccess(c, http.StatusOK, "Locations recorded", gin.H{
+		"stored":      len(points),
+		"rejected":    rejected,
+		"snapped":     snapErr == nil,
+		"liveUpdated": live,
+	})
+}
+
+// saveLocationHistory inserts a batch in one statement.
+func saveLocationHistory(ctx context.Context, driverID string, rideID *string, points []locationPoint, snapped [][2]float64) error {
+	n := len(points)
+	lats, lngs, rawLats, rawLngs := make([]float64, n), make([]float64, n), make([]float64, n), make([]float64, n)
+	headings, speeds, accuracies := make([]*float64, n), make([]*float64, n), make([]*float64, n)
+	recordedAt := make([]time.Time, n)
+	for i, p := range points {
+		lats[i], lngs[i] = snapped[i][0], snapped[i][1]
+		rawLats[i], rawLngs[i] = p.Lat, p.Lng
+		headings[i], speeds[i], accuracies[i] = p.Heading, p.Speed, p.Accuracy
+		recordedAt[i] = p.RecordedAt
+	}
+	_, err := db.Pool.Exec(ctx,
+		`INSERT INTO driver_location_history ("driverId", "rideId", lat, lng, "rawLat", "rawLng", heading, speed, accuracy, "recordedAt")
+		 SELECT $1::text, $2::text, * FROM unnest($3::float8[], $4::float8[], $5::float8[], $6::float8[], $7::float8[], $8::float8[], $9::float8[], $10::timestamptz[])`,
+		driverID, rideID, lats, lngs, rawLats, rawLngs, headings, speeds, accuracies, recordedAt)
+	return err
+}
@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"ridewave/db"
+	"ridewave/models"
+	"ridewave/utils"
+)
+
+// ══════════════════════════════════════════════════
+// Driver Navigation — turn-by-turn steps and external navigation links
+// ══════════════════════════════════════════════════
+//
+// The steps are the ones Ola Maps returned when the rider's route was planned, kept on the ride,
+// so they cover pickup to destination through any stops. Until the rider is picked up the links
+// lead to the pickup; after that, to the next stop not yet completed and on to the destination.
+
+type navigationPoint struct {
+	Name string  `json:"name"`
+	Lat  float64 `json:"lat"`
+	Lng  float64 `json:"lng"`
+}
+
+type navigationLeg struct {
+	Leg       int               `json:"leg"`
+	To        navigationPoint   `json:"to"`
+	Distance  int               `json:"distance"` // meters
+	Duration  int               `json:"duration"` // seconds
+	Completed bool              `json:"completed"`
+	Steps     []utils.RouteStep `json:"steps"`
+}
+
+// GET /api/v1/driver/ride/:id/navigation
+func GetRideNavigation(c *gin.Context) {
+	driver := c.MustGet("driver").(*models.Driver)
+	rideID := c.Param("id")
+	ctx := c.Request.Context()
+
+	var status, vehicleType, originName, destName string
+	var originLat, originLng, destLat, destLng *float64
+	var stepsJSON []byte
+	err := db.Pool.QueryRow(ctx,
+		`SELECT status, COALESCE("vehicleType", ''), "currentLocationName", "destinationLocationName",
+		 "originLat", "originLng", "destinationLat", "destinationLng", "routeSteps"
+		 FROM rides WHERE id=$1 AND "driverId"=$2`, rideID, driver.ID).
+		Scan(&status, &vehicleType, &originName, &destName, &originLat, &originLng, &destLat, &destLng, &stepsJSON)
+	if err != nil {
+		utils.RespondErrorCode(c, http.StatusNotFound, utils.CodeRideNotFound, "Ride not found", err)
+		return
+	}
+	if status != "Accepted" && status != "Arriving" && status != "InProgress" {
+		utils.RespondError(c, http.StatusConflict, "Navigation is only available during an ongoing ride", nil)
+		return
+	}
+	if originLat == nil || originLng == nil || destLat == nil || destLng == nil {
+		utils.RespondError(c, http.StatusUnprocessableEntity, "This ride has no saved coordinates to navigate to", nil)
+		return
+	}
+
+	// One leg per stop, then the last one to the destination
+	stops := loadRideStops(ctx, rideID)
+	legs := make([]navigationLeg, 0, len(stops)+1)
+	for i, s := range stops {
+		legs = append(legs, navigationLeg{Leg: i, To: navigationPoint{s.Name, s.Lat, s.Lng},
+			Completed: s.CompletedAt != nil, Steps: []utils.RouteStep{}})
+	}
+	legs = append(legs, navigationLeg{Leg: len(stops), To: navigationPoint{destName, *destLat, *destLng}, Steps: []utils.RouteStep{}})
+
+	// Rides booked before steps were kept have none; the links still work
+	var steps []utils.RouteStep
+	if len(stepsJSON) > 0 {
+		json.Unmarshal(stepsJSON, &steps)
+	}
+	for _, step := range steps {
+		if step.Leg < 0 || step.Leg >= len(legs) {
+			continue
+		}
+		leg := &legs[step.Leg]
+		leg.Steps = append(leg.Steps, step)
+		leg.Distance += step.Distance
+		leg.Duration += step.Duration
+	}
+
+	phase := "trip"
+	var route []navigationPoint // where the driver goes next, in order
+	if status != "InProgress" {
+		phase = "pickup"
+		route = []navigationPoint{{originName, *originLat, *originLng}}
+	} else {
+		for _, leg := range legs {
+			if !leg.Completed {
+				route = append(route, leg.To)
+			}
+		}
+	}
+	currentLeg := len(legs) - 1
+	for i, leg := range legs {
+		if !leg.Completed {
+			currentLeg = i
+			break
+		}
+	}
+
+	utils.RespondSuccess(c, http.StatusOK, "Ride navigation", gin.H{
+		"rideId":     rideID,
+		"status":     status,
+		"phase":      phase, // pickup | trip
+		"next":       route[0],
+		"currentLeg": currentLeg,
+		"legs":       legs,
+		"deepLinks":  navigationLinks(route, vehicleType),
+	})
+}
+
+// navigationLinks opens the route in an external app from the driver's current position: Google
+// Maps with any stops as waypoints, or the geo: URI for whichever app handles it (Ola Maps, Waze),
+// which only takes the next point.
+func navigationLinks(route []navigationPoint, vehicleType string) gin.H {
+	latLng := func(p navigationPoint) string { return fmt.Sprintf("%f,%f", p.Lat, p.Lng) }
+	travelMode := "driving"
+	if vehicleType == "Bike" {
+		travelMode = "two-wheeler"
+	}
+
+	q := url.Values{}
+	q.Set("api", "1")
+	q.Set("destination", latLng(route[len(route)-1]))
+	if len(route) > 1 {
+		waypoints := make([]string, 0, len(route)-1)
+		for _, p := range route[:len(route)-1] {
+			waypoints = append(waypoints, latLng(p))
+		}
+		q.Set("waypoints", strings.Join(waypoints, "|"))
+	}
+	q.Set("travelmode", travelMode)
+	q.Set("dir_action", "navigate")
+
+	next := latLng(route[0])
+	return gin.H{
+		"googleMaps": "https://www.google.com/maps/dir/?" + q.Encode(),
+		"geo":        "geo:" + next + "?q=" + next,
+	}
+}
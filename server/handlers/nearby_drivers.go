@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+	"ridewave/db"
+	"ridewave/stores"
+	"ridewave/utils"
+)
+
+// nearbyDriverRadiusKm is how far around the pickup drivers are shown to the rider.
+const nearbyDriverRadiusKm = 5.0
+
+// nearbyDriver is an available driver near the pickup, timed to it.
+type nearbyDriver struct {
+	ID          string  `json:"id"`
+	VehicleType string  `json:"vehicleType"`
+	Latitude    float64 `json:"latitude"`
+	Longitude   float64 `json:"longitude"`
+	utils.DriverETA
+}
+
+// nearbyDriversWithETA lists online, active drivers around the pickup, nearest first, each with
+// a drive time to it. Errors only shrink the list: an estimate never fails for want of drivers.
+func nearbyDriversWithETA(ctx context.Context, pickupLat, pickupLng float64) []nearbyDriver {
+	locs, err := stores.GetNearbyDrivers(ctx, pickupLat, pickupLng, nearbyDriverRadiusKm)
+	if err != nil || len(locs) == 0 {
+		return nil
+	}
+	ids := make([]string, len(locs))
+	for i, d := range locs {
+		ids[i] = d.DriverID
+	}
+	vehicleTypes := map[string]string{}
+	rows, err := db.Pool.Query(ctx,
+		`SELECT id, vehicle_type FROM driver WHERE id=ANY($1) AND "isOnline"=TRUE AND status='active'`, ids)
+	if err != nil {
+		utils.Logger.Warn("Failed to load nearby drivers", zap.Error(err))
+		return nil
+	}
+	for rows.Next() {
+		var id, vehicleType string
+		if rows.Scan(&id, &vehicleType) == nil {
+			vehicleTypes[id] = vehicleType
+		}
+	}
+	rows.Close()
+
+	var points []utils.ETAPoint
+	for _, d := range locs {
+		if _, ok := vehicleTypes[d.DriverID]; ok {
+			points = append(points, utils.ETAPoint{ID: d.DriverID, Lat: d.Latitude, Lng: d.Longitude})
+		}
+	}
+	etas := utils.NearbyDriverETAs(ctx, pickupLat, pickupLng, points)
+
+	drivers := make([]nearbyDriver, 0, len(points))
+	for _, p := range points {
+		drivers = append(drivers, nearbyDriver{
+			ID: p.ID, VehicleType: vehicleTypes[p.ID], Latitude: p.Lat, Longitude: p.Lng, DriverETA: etas[p.ID],
+		})
+	}
+	return drivers
+}
+
+// pickupETA is the soonest any nearby driver of the vehicle type can reach the pickup.
+func pickupETA(drivers []nearbyDriver, vehicleType string) (utils.DriverETA, bool) {
+	want := dispatchVehicleType(vehicleType)
+	var best utils.DriverETA
+	found := false
+	for _, d := range drivers {
+		if d.VehicleType == want && (!found || d.Seconds < best.Seconds) {
+			best, found = d.DriverETA, true
+		}
+	}
+	return best, found
+}
@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"ridewave/db"
+	"ridewave/models"
+	"ridewave/utils"
+)
+
+// ══════════════════════════════════════════════════
+// Admin: Internal Notes & Tags — support context on users, drivers and rides
+// ══════════════════════════════════════════════════
+
+const (
+	noteEntityUser   = "user"
+	noteEntityDriver = "driver"
+	noteEntityRide   = "ride"
+)
+
+// noteEntityTables maps an annotatable entity type to the table it lives in.
+var noteEntityTables = map[string]string{
+	noteEntityUser:   `"user"`,
+	noteEntityDriver: "driver",
+	noteEntityRide:   "rides",
+}
+
+const adminNoteSelectCols = `id, "entityType", "entityId", note, tags, author, "createdAt", "updatedAt"`
+
+func scanAdminNote(scanner interface{ Scan(dest ...any) error }, n *models.AdminNote) error {
+	return scanner.Scan(&n.ID, &n.EntityType, &n.EntityID, &n.Note, &n.Tags, &n.Author, &n.CreatedAt, &n.UpdatedAt)
+}
+
+// normalizeNoteTags trims, lowercases and de-duplicates tags ("VIP ", "vip" → "vip").
+func normalizeNoteTags(tags []string) []string {
+	seen := map[string]bool{}
+	out := []string{}
+	for _, t := range tags {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t == "" || seen[t] {
+			continue
+		}
+		seen[t] = true
+		out = append(out, t)
+	}
+	return out
+}
+
+// listEntityNotes returns every note on an entity, newest first, for admin detail views.
+func listEntityNotes(ctx context.Context, entityType, entityID string) []models.AdminNote {
+	notes := []models.AdminNote{}
+	rows, err := db.Pool.Query(ctx,
+		`SELECT `+adminNoteSelectCols+` FROM admin_notes WHERE "entityType"=$1 AND "entityId"=$2
+		 ORDER BY "createdAt" DESC`, entityType, entityID)
+	if err != nil {
+		return notes
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var n models.AdminNote
+		if scanAdminNote(rows, &n) == nil {
+			notes = append(notes, n)
+		}
+	}
+	return notes
+}
+
+// GET /api/v1/admin/notes?entityType=user&entityId=...&tag=vip&q=refund&page=1&limit=20
+func AdminSearchNotes(c *gin.Context) {
+	pg, err := utils.ParsePagination(c)
+	if err != nil {
+		utils.RespondErrorCode(c, http.StatusBadRequest, utils.CodeInvalidCursor, "Invalid cursor", err)
+		return
+	}
+	entityType := c.Query("entityType")
+	entityID := c.Query("entityId")
+	tag := strings.ToLower(strings.TrimSpace(c.Query("tag")))
+	search := c.Query("q")
+
+	conds := []string{`($1='' OR "entityType"=$1) AND ($2='' OR "entityId"=$2)
+		 AND ($3='' OR $3=ANY(tags)) AND ($4='' OR note ILIKE '%' || $4 || '%')`}
+	args := []interface{}{entityType, entityID, tag, search}
+
+	var total int
+	if !pg.UseCursor {
+		db.Pool.QueryRow(adminContext(c),
+			`SELECT COUNT(*) FROM admin_notes`+utils.WhereClause(conds), args...).Scan(&total)
+	}
+
+	conds, args = pg.Keyset(conds, args, `"createdAt"`, "id")
+	tail, args := pg.Tail(args, `"createdAt"`, "id")
+	rows, err := db.Pool.Query(adminContext(c),
+		`SELECT `+adminNoteSelectCols+` FROM admin_notes`+utils.WhereClause(conds)+tail, args...)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to fetch notes", err)
+		return
+	}
+	defer rows.Close()
+
+	var notes []models.AdminNote
+	for rows.Next() {
+		var n models.AdminNote
+		scanAdminNote(rows, &n)
+		notes = append(notes, n)
+	}
+	if notes == nil {
+		notes = []models.AdminNote{}
+	}
+
+	notes, resp := utils.Paginate(pg, notes, total, func(n models.AdminNote) (time.Time, string) { return n.CreatedAt, n.ID })
+	resp["notes"] = notes
+	utils.RespondSuccess(c, http.StatusOK, "Notes", resp)
+}
+
+// POST /api/v1/admin/notes
+func AdminCreateNote(c *gin.Context) {
+	admin := c.MustGet("admin").(*models.AdminAccount)
+	var body struct {
+		EntityType string   `json:"entityType" binding:"required"` // user | driver | ride
+		EntityID   string   `json:"entityId" binding:"required"`
+		Note       string   `json:"note"`
+		Tags       []string `json:"tags"` // e.g. ["vip", "chargeback risk"]
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid request", err)
+		return
+	}
+
+	table, ok := noteEntityTables[body.EntityType]
+	if !ok {
+		utils.RespondError(c, http.StatusBadRequest, "Entity type must be user, driver or ride", nil)
+		return
+	}
+	tags := normalizeNoteTags(body.Tags)
+	if strings.TrimSpace(body.Note) == "" && len(tags) == 0 {
+		utils.RespondError(c, http.StatusBadRequest, "A note or at least one tag is required", nil)
+		return
+	}
+
+	var exists bool
+	db.Pool.QueryRow(adminContext(c),
+		fmt.Sprintf(`SELECT EXISTS(SELECT 1 FROM %s WHERE id=$1)`, table), body.EntityID).Scan(&exists)
+	if !exists {
+		utils.RespondError(c, http.StatusNotFound, "No "+body.EntityType+" with that id", nil)
+		return
+	}
+
+	var note models.AdminNote
+	err := scanAdminNote(db.Pool.QueryRow(adminContext(c),
+		`INSERT INTO admin_notes ("entityType", "entityId", note, tags, author)
+		 VALUES ($1, $2, $3, $4, $5) RETURNING `+adminNoteSelectCols,
+		body.EntityType, body.EntityID, strings.TrimSpace(body.Note), tags, admin.Email), &note)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to save note", err)
+		return
+	}
+	utils.RespondSuccess(c, http.StatusCreated, "Note added", gin.H{"note": note})
+}
+
+// PUT /api/v1/admin/note/:id
+func AdminUpdateNote(c *gin.Context) {
+	var body struct {
+		Note *string  `json:"note"`
+		Tags []string `json:"tags"` // replaces the existing tags when provided
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid request", err)
+		return
+	}
+
+	var tags []string
+	if body.Tags != nil {
+		tags = normalizeNoteTags(body.Tags)
+	}
+
+	var note models.AdminNote
+	err := scanAdminNote(db.Pool.QueryRow(adminContext(c),
+		`UPDATE admin_notes SET note=COALESCE($1, note), tags=COALESCE($2, tags), "updatedAt"=NOW()
+		 WHERE id=$3 RETURNING `+adminNoteSelectCols,
+		body.Note, tags, c.Param("id")), &note)
+	if err != nil {
+		utils.RespondError(c, http.StatusNotFound, "Note not found", err)
+		return
+	}
+	utils.RespondSuccess(c, http.StatusOK, "Note updated", gin.H{"note": note})
+}
+
+// DELETE /api/v1/admin/note/:id
+func AdminDeleteNote(c *gin.Context) {
+	tag, err := db.Pool.Exec(adminContext(c), `DELETE FROM admin_notes WHERE id=$1`, c.Param("id"))
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to delete note", err)
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		utils.RespondError(c, http.StatusNotFound, "Note not found", nil)
+		return
+	}
+	utils.RespondSuccess(c, http.StatusOK, "Note deleted", nil)
+}
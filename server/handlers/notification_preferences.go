@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"ridewave/models"
+	"ridewave/utils"
+)
+
+// ══════════════════════════════════════════════════
+// Notification Preferences — optional message categories for riders & drivers
+// ══════════════════════════════════════════════════
+//
+// Promotions can be turned off, ride updates sent by SMS instead of push, and emailed receipts
+// stopped. OTPs, payment and safety messages aren't optional. Marketing consent per channel
+// (communication-preferences) still applies to promotional SMS, WhatsApp and email on top.
+
+// GET /api/v1/user/notification-preferences
+func GetUserNotificationPreferences(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+	getNotificationPreferences(c, "user", user.ID)
+}
+
+// PUT /api/v1/user/notification-preferences
+func UpdateUserNotificationPreferences(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+	updateNotificationPreferences(c, "user", user.ID)
+}
+
+// GET /api/v1/driver/notification-preferences
+func GetDriverNotificationPreferences(c *gin.Context) {
+	driver := c.MustGet("driver").(*models.Driver)
+	getNotificationPreferences(c, "driver", driver.ID)
+}
+
+// PUT /api/v1/driver/notification-preferences
+func UpdateDriverNotificationPreferences(c *gin.Context) {
+	driver := c.MustGet("driver").(*models.Driver)
+	updateNotificationPreferences(c, "driver", driver.ID)
+}
+
+func getNotificationPreferences(c *gin.Context, recipientType, recipientID string) {
+	prefs, err := utils.GetNotificationPreferences(c.Request.Context(), recipientType, recipientID)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to fetch notification preferences", err)
+		return
+	}
+	utils.RespondSuccess(c, http.StatusOK, "Notification preferences", gin.H{"preferences": prefs})
+}
+
+// updateNotificationPreferences changes the fields sent and keeps the rest.
+func updateNotificationPreferences(c *gin.Context, recipientType, recipientID string) {
+	var body struct {
+		Promotions    *bool   `json:"promotions"`
+		RideUpdates   *string `json:"rideUpdates"` // push | sms
+		EmailReceipts *bool   `json:"emailReceipts"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid request", err)
+		return
+	}
+	if body.RideUpdates != nil && *body.RideUpdates != utils.ChannelPush && *body.RideUpdates != utils.ChannelSMS {
+		utils.RespondError(c, http.StatusBadRequest, "rideUpdates must be push or sms", nil)
+		return
+	}
+
+	ctx := c.Request.Context()
+	prefs, err := utils.GetNotificationPreferences(ctx, recipientType, recipientID)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to fetch notification preferences", err)
+		return
+	}
+	if body.Promotions != nil {
+		prefs.Promotions = *body.Promotions
+	}
+	if body.RideUpdates != nil {
+		prefs.RideUpdates = *body.RideUpdates
+	}
+	if body.EmailReceipts != nil {
+		prefs.EmailReceipts = *body.EmailReceipts
+	}
+	if err := utils.SaveNotificationPreferences(ctx, recipientType, recipientID, prefs); err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to update notification preferences", err)
+		return
+	}
+	prefs, _ = utils.GetNotificationPreferences(ctx, recipientType, recipientID)
+	utils.RespondSuccess(c, http.StatusOK, "Notification preferences updated", gin.H{"preferences": prefs})
+}
@@ -0,0 +1,390 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.uber.org/zap"
+	"ridewave/db"
+	"ridewave/i18n"
+	"ridewave/notify"
+	"ridewave/rides/statemachine"
+	"ridewave/stores"
+	"ridewave/utils"
+)
+
+// ══════════════════════════════════════════════════
+// Notifications Outbox — push and socket sends that survive FCM and Redis outages
+// ══════════════════════════════════════════════════
+//
+// A notification is written to notifications_outbox in the same transaction as the change it
+// announces, so it exists exactly when the change does. A worker delivers it afterwards and
+// retries failures with exponential backoff; one that keeps failing is parked as dead for an
+// admin to look at and retry.
+
+const (
+	outboxChannelPush   = "push"
+	outboxChannelSocket = "socket"
+
+	outboxPending = "pending"
+	outboxSent    = "sent"
+	outboxSkipped = "skipped" // nothing to deliver to: no device token, or FCM isn't configured
+	outboxDead    = "dead"
+
+	outboxPollInterval    = 5 * time.Second
+	outboxBatchSize       = 50
+	outboxConcurrency     = 10
+	outboxLease           = time.Minute // a claimed row is retried if its instance dies mid-send
+	outboxBaseDelay       = 5 * time.Second
+	outboxMaxDelay        = 10 * time.Minute
+	outboxRideStatusEvent = "rideStatus"
+)
+
+// outboxWriter is the transaction making the change, or db.Pool when there isn't one.
+type outboxWriter interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+// outboxNotification is one push or socket event for a rider ("user") or driver.
+type outboxNotification struct {
+	Channel       string
+	RecipientType string
+	RecipientID   string
+	RideID        string
+	Event         string // socket only
+	Title         string // push only
+	Body          string // push only
+	Data          utils.FCMData
+}
+
+// outboxPush is a push to one rider ("user") or driver.
+func outboxPush(recipientType, recipientID, rideID, title, body string, data utils.FCMData) outboxNotification {
+	return outboxNotification{Channel: outboxChannelPush, RecipientType: recipientType, RecipientID: recipientID,
+		RideID: rideID, Title: title, Body: body, Data: data}
+}
+
+// rideStatusNotifications is a ride update as a push, plus a rideStatus socket event for an app
+// that's open, both carrying data.
+func rideStatusNotifications(recipientType, recipientID, rideID, title, body string, data utils.FCMData) []outboxNotification {
+	return []outboxNotification{
+		outboxPush(recipientType, recipientID, rideID, title, body, data),
+		{Channel: outboxChannelSocket, RecipientType: recipientType, RecipientID: recipientID, RideID: rideID, Event: outboxRideStatusEvent, Data: data},
+	}
+}
+
+// queueNotifications writes notifications to the outbox through w. Call kickNotificationOutbox
+// once w's transaction has committed to send them straight away.
+func queueNotifications(ctx context.Context, w outboxWriter, notifications ...outboxNotification) error {
+	for _, n := range notifications {
+		if n.Data == nil {
+			n.Data = utils.FCMData{}
+		}
+		_, err := w.Exec(ctx,
+			`INSERT INTO notifications_outbox (channel, "recipientType", "recipientId", "rideId", event, title, body, data)
+			 VALUES ($1, $2, $3, NULLIF($4, ''), NULLIF($5, ''), NULLIF($6, ''), NULLIF($7, ''), $8)`,
+			n.Channel, n.RecipientType, n.RecipientID, n.RideID, n.Event, n.Title, n.Body, n.Data)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sendNotifications queues notifications that don't go with a write, and sends them now.
+func sendNotifications(ctx context.Context, notifications ...outboxNotification) {
+	if err := queueNotifications(ctx, db.Pool, notifications...); err != nil {
+		utils.Logger.Error("Failed to queue notifications", zap.Error(err))
+		return
+	}
+	kickNotificationOutbox()
+}
+
+var outboxKick = make(chan struct{}, 1)
+
+// kickNotificationOutbox wakes this instance's worker instead of waiting for its next poll.
+func kickNotificationOutbox() {
+	select {
+	case outboxKick <- struct{}{}:
+	default:
+	}
+}
+
+// outboxMaxAttempts is how many sends are tried before a notification is dead
+// (NOTIFICATION_MAX_ATTEMPTS, default 8: about ten minutes of retries).
+func outboxMaxAttempts() int {
+	if val, err := strconv.Atoi(os.Getenv("NOTIFICATION_MAX_ATTEMPTS")); err == nil && val > 0 {
+		return val
+	}
+	return 8
+}
+
+// outboxRetryDelay backs off exponentially from outboxBaseDelay up to outboxMaxDelay.
+func outboxRetryDelay(attempts int) time.Duration {
+	delay := outboxBaseDelay
+	for i := 1; i < attempts && delay < outboxMaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, outboxMaxDelay)
+}
+
+// StartNotificationOutboxWorker delivers due notifications every few seconds, and as soon as
+// one is queued on this instance.
+func StartNotificationOutboxWorker(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(outboxPollInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+			case <-outboxKick:
+			case <-ctx.Done():
+				utils.Logger.Info("Notification Outbox Worker shutting down...")
+				return
+			}
+			deliverDueNotifications(db.Unscoped())
+		}
+	}()
+}
+
+// claimedNotification is an outbox row this instance is delivering.
+type claimedNotification struct {
+	outboxNotification
+	ID       string
+	Attempts int
+}
+
+// deliverDueNotifications claims due notifications a batch at a time, so instances never send
+// the same one together, and delivers them.
+func deliverDueNotifications(ctx context.Context) {
+	for {
+		rows, err := db.Pool.Query(ctx,
+			`UPDATE notifications_outbox SET attempts=attempts+1, "nextAttemptAt"=NOW() + make_interval(secs => $2)
+			 WHERE id IN (SELECT id FROM notifications_outbox WHERE status=$3 AND "nextAttemptAt" <= NOW()
+			              ORDER BY "nextAttemptAt" LIMIT $1 FOR UPDATE SKIP LOCKED)
+			 RETURNING id, channel, "recipientType", "recipientId", COALESCE("rideId", ''), COALESCE(event, ''),
+			 COALESCE(title, ''), COALESCE(body, ''), data, attempts`,
+			outboxBatchSize, outboxLease.Seconds(), outboxPending)
+		if err != nil {
+			utils.Logger.Error("Failed to claim outbox notifications", zap.Error(err))
+			return
+		}
+		var batch []claimedNotification
+		for rows.Next() {
+			var n claimedNotification
+			if rows.Scan(&n.ID, &n.Channel, &n.RecipientType, &n.RecipientID, &n.RideID, &n.Event,
+				&n.Title, &n.Body, &n.Data, &n.Attempts) == nil {
+				batch = append(batch, n)
+			}
+		}
+		rows.Close()
+		if len(batch) == 0 {
+			return
+		}
+
+		var wg sync.WaitGroup
+		sem := make(chan struct{}, outboxConcurrency)
+		for _, n := range batch {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(n claimedNotification) {
+				defer func() { <-sem; wg.Done() }()
+				status, err := deliverNotification(ctx, n.outboxNotification)
+				recordDelivery(ctx, n, status, err)
+			}(n)
+		}
+		wg.Wait()
+
+		if len(batch) < outboxBatchSize {
+			return
+		}
+	}
+}
+
+// deliverNotification sends one notification, returning sent or skipped, or the error to retry on.
+func deliverNotification(ctx context.Context, n outboxNotification) (string, error) {
+	switch n.Channel {
+	case outboxChannelSocket:
+		return outboxSent, stores.PublishNotification(ctx, stores.NotificationEvent{
+			Event: n.Event, RecipientType: n.RecipientType, RecipientID: n.RecipientID, Payload: n.Data,
+		})
+	case outboxChannelPush:
+		table := `"user"`
+		if n.RecipientType == "driver" {
+			table = "driver"
+		}
+		var token, phone *string
+		var lang string
+		db.Pool.QueryRow(ctx, `SELECT "notificationToken", phone_number, COALESCE("preferredLanguage", '') FROM `+table+` WHERE id=$1`,
+			n.RecipientID).Scan(&token, &phone, &lang)
+		// Queued in English; translated at send time so a language change applies to retries too
+		lang = i18n.Resolve("", lang)
+		title, body := i18n.T(lang, n.Title), i18n.T(lang, n.Body)
+
+		m := notify.Message{Event: notificationEvent(n.Data), Recipient: pushRecipient(n), Title: title, Body: body, Data: n.Data}
+		if token != nil {
+			m.Token = *token
+		}
+		if phone != nil {
+			m.Phone = *phone
+		}
+		// A recipient who takes ride updates by SMS gets them that way only
+		channels := notify.Channels(m.Event)
+		if m.Recipient.Category == utils.NotifyRideUpdates && utils.NotificationAllowed(ctx, m.Recipient, utils.ChannelSMS) {
+			channels = []string{utils.ChannelSMS}
+		}
+		_, err := notify.Deliver(ctx, m, channels)
+		if errors.Is(err, notify.ErrUnreachable) {
+			return outboxSkipped, nil
+		}
+		return outboxSent, err
+	}
+	return "", errors.New("unknown notification channel " + n.Channel)
+}
+
+// rideUpdatePushTypes are the pushes that are ride updates, which a rider or driver can take by
+// SMS instead.
+var rideUpdatePushTypes = map[string]bool{
+	"ride_status":               true,
+	"ride_stop":                 true,
+	"ride_cancelled":            true,
+	"ride_auto_completed":       true,
+	"pool_joined":               true,
+	"scheduled_ride_dispatched": true,
+}
+
+// notificationEvent names a push for notify's routing: ride status pushes by the status they
+// announce, the rest by their type.
+func notificationEvent(data utils.FCMData) string {
+	if data["type"] == "ride_status" {
+		switch data["status"] {
+		case statemachine.Accepted:
+			return notify.EventRideAccepted
+		case statemachine.Arriving:
+			return notify.EventDriverArrived
+		}
+	}
+	return data["type"]
+}
+
+// pushRecipient is who a push is for, categorised by its type so their preferences apply.
+func pushRecipient(n outboxNotification) utils.Recipient {
+	to := utils.Recipient{Type: n.RecipientType, ID: n.RecipientID}
+	if rideUpdatePushTypes[n.Data["type"]] {
+		to.Category = utils.NotifyRideUpdates
+	}
+	return to
+}
+
+// recordDelivery stores the outcome of a send: done, due again after a backoff, or dead once
+// outboxMaxAttempts sends have failed.
+func recordDelivery(ctx context.Context, n claimedNotification, status string, err error) {
+	var dbErr error
+	switch {
+	case err == nil:
+		_, dbErr = db.Pool.Exec(ctx,
+			`UPDATE notifications_outbox SET status=$2, "sentAt"=NOW(), "lastError"=NULL WHERE id=$1`, n.ID, status)
+	case n.Attempts >= outboxMaxAttempts():
+		utils.Logger.Error("Notification dead after repeated failures",
+			zap.String("id", n.ID), zap.String("channel", n.Channel), zap.Int("attempts", n.Attempts), zap.Error(err))
+		_, dbErr = db.Pool.Exec(ctx,
+			`UPDATE notifications_outbox SET status=$2, "lastError"=$3 WHERE id=$1`, n.ID, outboxDead, err.Error())
+	default:
+		_, dbErr = db.Pool.Exec(ctx,
+			`UPDATE notifications_outbox SET "nextAttemptAt"=NOW() + make_interval(secs => $2), "lastError"=$3 WHERE id=$1`,
+			n.ID, outboxRetryDelay(n.Attempts).Seconds(), err.Error())
+	}
+	if dbErr != nil {
+		utils.Logger.Error("Failed to record notification delivery", zap.String("id", n.ID), zap.Error(dbErr))
+	}
+}
+
+// ══════════════════════════════════════════════════
+// Admin: Notifications Outbox
+// ══════════════════════════════════════════════════
+
+// outboxEntry is an outbox row as admins see it.
+type outboxEntry struct {
+	ID            string            `json:"id"`
+	Channel       string            `json:"channel"`
+	RecipientType string            `json:"recipientType"`
+	RecipientID   string            `json:"recipientId"`
+	RideID        *string           `json:"rideId"`
+	Event         *string           `json:"event"`
+	Title         *string           `json:"title"`
+	Body          *string           `json:"body"`
+	Data          map[string]string `json:"data"`
+	Status        string            `json:"status"`
+	Attempts      int               `json:"attempts"`
+	NextAttemptAt time.Time         `json:"nextAttemptAt"`
+	LastError     *string           `json:"lastError"`
+	SentAt        *time.Time        `json:"sentAt"`
+	CreatedAt     time.Time         `json:"createdAt"`
+}
+
+const outboxSelectCols = `id, channel, "recipientType", "recipientId", "rideId", event, title, body, data, status,
+	attempts, "nextAttemptAt", "lastError", "sentAt", "createdAt"`
+
+func scanOutboxEntry(scanner interface{ Scan(dest ...any) error }, e *outboxEntry) error {
+	return scanner.Scan(&e.ID, &e.Channel, &e.RecipientType, &e.RecipientID, &e.RideID, &e.Event, &e.Title, &e.Body,
+		&e.Data, &e.Status, &e.Attempts, &e.NextAttemptAt, &e.LastError, &e.SentAt, &e.CreatedAt)
+}
+
+// GET /api/v1/admin/notifications?status=dead&rideId=&recipientId=
+func AdminGetNotifications(c *gin.Context) {
+	pg, err := utils.ParsePagination(c)
+	if err != nil {
+		utils.RespondErrorCode(c, http.StatusBadRequest, utils.CodeInvalidCursor, "Invalid cursor", err)
+		return
+	}
+	conds := []string{`($1='' OR status=$1)`, `($2='' OR "rideId"=$2)`, `($3='' OR "recipientId"=$3)`}
+	args := []interface{}{c.Query("status"), c.Query("rideId"), c.Query("recipientId")}
+
+	var total int
+	if !pg.UseCursor {
+		db.Pool.QueryRow(adminContext(c), `SELECT COUNT(*) FROM notifications_outbox`+utils.WhereClause(conds), args...).Scan(&total)
+	}
+
+	conds, args = pg.Keyset(conds, args, `"createdAt"`, "id")
+	tail, args := pg.Tail(args, `"createdAt"`, "id")
+	rows, err := db.Pool.Query(adminContext(c),
+		`SELECT `+outboxSelectCols+` FROM notifications_outbox`+utils.WhereClause(conds)+tail, args...)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to fetch notifications", err)
+		return
+	}
+	defer rows.Close()
+
+	entries := []outboxEntry{}
+	for rows.Next() {
+		var e outboxEntry
+		if scanOutboxEntry(rows, &e) == nil {
+			entries = append(entries, e)
+		}
+	}
+
+	entries, resp := utils.Paginate(pg, entries, total, func(e outboxEntry) (time.Time, string) { return e.CreatedAt, e.ID })
+	resp["notifications"] = entries
+	utils.RespondSuccess(c, http.StatusOK, "Notifications", resp)
+}
+
+// POST /api/v1/admin/notification/:id/retry — send a dead notification again, with fresh attempts
+func AdminRetryNotification(c *gin.Context) {
+	var e outboxEntry
+	err := scanOutboxEntry(db.Pool.QueryRow(adminContext(c),
+		`UPDATE notifications_outbox SET status=$2, attempts=0, "nextAttemptAt"=NOW()
+		 WHERE id=$1 AND status=$3 RETURNING `+outboxSelectCols, c.Param("id"), outboxPending, outboxDead), &e)
+	if err != nil {
+		utils.RespondError(c, http.StatusNotFound, "No dead notification with this ID", err)
+		return
+	}
+	kickNotificationOutbox()
+	utils.RespondSuccess(c, http.StatusOK, "Notification queued for retry", gin.H{"notification": e})
+}
@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"ridewave/db"
+	"ridewave/models"
+	"ridewave/stores"
+	"ridewave/utils"
+)
+
+// ══════════════════════════════════════════════════
+// Payment Status Events
+// ══════════════════════════════════════════════════
+
+// notifyPaymentStatus fans a payment status change out to both apps: a socket event
+// for whoever is connected and a push for whoever is backgrounded.
+func notifyPaymentStatus(rideID, status, mode string, amount float64) {
+	var userID, currency string
+	var driverID *string
+	err := db.Pool.QueryRow(db.Unscoped(),
+		`SELECT "userId", "driverId", currency FROM rides WHERE id=$1`, rideID).Scan(&userID, &driverID, &currency)
+	if err != nil {
+		utils.Logger.Error("Failed to load ride for payment update", zap.String("rideId", rideID), zap.Error(err))
+		return
+	}
+
+	event := stores.PaymentUpdateEvent{
+		RideID:   rideID,
+		UserID:   userID,
+		Status:   status,
+		Mode:     mode,
+		Amount:   amount,
+		Currency: currency,
+	}
+	if driverID != nil {
+		event.DriverID = *driverID
+	}
+	if err := stores.PublishPaymentUpdate(context.Background(), event); err != nil {
+		utils.Logger.Error("Failed to publish payment update", zap.String("rideId", rideID), zap.Error(err))
+	}
+
+	data := utils.FCMData{
+		"type":   "payment_status",
+		"rideId": rideID,
+		"status": status,
+	}
+	userTitle, userMsg := "Payment Received ✅", fmt.Sprintf("%s paid via %s. Thanks for riding!", formatMoney(currency, amount), mode)
+	driverTitle, driverMsg := "Payment Received 💰", fmt.Sprintf("%s received via %s.", formatMoney(currency, amount), mode)
+	if status != "Paid" {
+		userTitle, userMsg = "Payment Failed", "Your payment didn't go through. Please retry or pay the driver directly."
+		driverTitle, driverMsg = "Payment Failed", "The rider's payment failed. Please collect the fare directly."
+	}
+	notifications := []outboxNotification{outboxPush("user", userID, rideID, userTitle, userMsg, data)}
+	if driverID != nil {
+		notifications = append(notifications, outboxPush("driver", *driverID, rideID, driverTitle, driverMsg, data))
+	}
+	sendNotifications(context.Background(), notifications...)
+}
+
+// RegisterWebhookRoutes mounts gateway callbacks. main registers them ahead of APIKeyAuth:
+// gateways don't send the app's x-api-key, and each callback checks its own signature instead.
+func RegisterWebhookRoutes(r gin.IRouter) {
+	r.POST("/user/payment/webhook", PaymentWebhook)
+}
+
+// POST /api/v1/user/payment/webhook
+// Gateway callback. The raw body must be signed with HMAC-SHA256 using PAYMENT_WEBHOOK_SECRET
+// and the hex digest sent in the X-Webhook-Signature header.
+func PaymentWebhook(c *gin.Context) {
+	secret := os.Getenv("PAYMENT_WEBHOOK_SECRET")
+	if secret == "" {
+		utils.RespondError(c, http.StatusInternalServerError, "Payment webhook not configured", nil)
+		return
+	}
+
+	raw, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid request", err)
+		return
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(raw)
+	expected := hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(expected), []byte(strings.ToLower(c.GetHeader("X-Webhook-Signature")))) {
+		utils.RespondError(c, http.StatusUnauthorized, "Invalid webhook signature", nil)
+		return
+	}
+
+	var body struct {
+		RideID     string  `json:"rideId"`
+		Status     string  `json:"status"` // "paid" | "failed"
+		Amount     float64 `json:"amount"`
+		Mode       string  `json:"mode"`
+		GatewayRef string  `json:"gatewayRef"`
+	}
+	if err := json.Unmarshal(raw, &body); err != nil || body.RideID == "" {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid webhook payload", err)
+		return
+	}
+	if body.Mode == "" {
+		body.Mode = "online"
+	}
+
+	// Gateways call in without a tenant, and a payment once recorded must also mark its ride paid
+	ctx := db.WithoutTenant(context.WithoutCancel(c.Request.Context()))
+
+	switch strings.ToLower(body.Status) {
+	case "paid", "captured", "success":
+		if body.Amount == 0 {
+			body.Amount, _ = repos.Rides.Charge(ctx, body.RideID)
+		}
+		recorded, err := recordRidePayment(body.RideID, body.Amount, body.Mode)
+		if err != nil {
+			utils.RespondError(c, http.StatusInternalServerError, "Failed to record payment", err)
+			return
+		}
+		if recorded {
+			go notifyPaymentStatus(body.RideID, "Paid", body.Mode, body.Amount)
+		}
+
+	case "failed":
+		if failed, _ := repos.Rides.MarkPaymentFailed(ctx, body.RideID); failed {
+			go notifyPaymentStatus(body.RideID, "Failed", body.Mode, body.Amount)
+		}
+
+	default:
+		// Intermediate gateway states (created, authorized, ...) are acknowledged and ignored
+	}
+
+	utils.Logger.Info("Payment webhook processed",
+		zap.String("rideId", body.RideID), zap.String("status", body.Status), zap.String("gatewayRef", body.GatewayRef))
+	utils.RespondSuccess(c, http.StatusOK, "Webhook processed", nil)
+}
+
+// GET /api/v1/driver/payments/pending
+// Completed rides whose fare hasn't been marked as received yet; a paid ride whose fare a dispute
+// raised shows what's still due.
+func GetPendingPayments(c *gin.Context) {
+	driver := c.MustGet("driver").(*models.Driver)
+
+	rows, err := db.Pool.Query(c.Request.Context(),
+		`SELECT r.id, COALESCE((SELECT "amountDue" FROM payments WHERE "rideId"=r.id AND status='paid' AND "amountDue" > 0), r.charge),
+		 COALESCE(r."paymentMode", ''), COALESCE(r."paymentStatus", 'Pending'),
+		 r."destinationLocationName", COALESCE(r."completedAt", r."updatedAt"),
+		 u.id, COALESCE(u.name, ''), u.phone_number
+		 FROM rides r JOIN "user" u ON u.id=r."userId"
+		 WHERE r."driverId"=$1 AND r.status='Completed' AND COALESCE(r."paymentStatus", 'Pending') <> 'Paid'
+		 ORDER BY r."completedAt" DESC NULLS LAST`, driver.ID)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to fetch pending payments", err)
+		return
+	}
+	defer rows.Close()
+
+	type PendingPayment struct {
+		RideID        string    `json:"rideId"`
+		Amount        float64   `json:"amount"`
+		PaymentMode   string    `json:"paymentMode"`
+		PaymentStatus string    `json:"paymentStatus"`
+		Destination   string    `json:"destination"`
+		CompletedAt   time.Time `json:"completedAt"`
+		UserID        string    `json:"userId"`
+		UserName      string    `json:"userName"`
+		UserPhone     string    `json:"userPhone"`
+	}
+
+	var pending []PendingPayment
+	var totalDue float64
+	for rows.Next() {
+		var p PendingPayment
+		rows.Scan(&p.RideID, &p.Amount, &p.PaymentMode, &p.PaymentStatus, &p.Destination, &p.CompletedAt,
+			&p.UserID, &p.UserName, &p.UserPhone)
+		totalDue += p.Amount
+		pending = append(pending, p)
+	}
+	if pending == nil {
+		pending = []PendingPayment{}
+	}
+	utils.RespondSuccess(c, http.StatusOK, "Pending payments", gin.H{
+		"payments": pending,
+		"count":    len(pending),
+		"totalDue": totalDue,
+	})
+}
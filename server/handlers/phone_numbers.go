@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.uber.org/zap"
+	"ridewave/db"
+	"ridewave/utils"
+)
+
+// ══════════════════════════════════════════════════
+// Phone Numbers — one E.164 form per number
+// ══════════════════════════════════════════════════
+//
+// Accounts are looked up by phone number, so "+91 98765 43210" and "9876543210" must be the same
+// account. Every number a client or admin sends is normalized before it's used or stored, and
+// numbers stored before that are rewritten at startup.
+
+// e164Pattern matches a stored number that's already normalized.
+const e164Pattern = `^\+[1-9][0-9]{7,14}$`
+
+// requestPhone normalizes a phone number from a request, responding 400 when it isn't one.
+// country is an ISO code or name for numbers typed without a calling code, "" for the default.
+func requestPhone(c *gin.Context, raw, country string) (string, bool) {
+	phone, err := utils.NormalizePhone(raw, country)
+	if err != nil {
+		utils.RespondErrorCode(c, http.StatusBadRequest, utils.CodePhoneInvalid, "Enter a valid phone number", err)
+		return "", false
+	}
+	return phone, true
+}
+
+// storedPhones are the account tables keyed by phone number, with the driver's country to read
+// national numbers by.
+var storedPhones = []struct{ table, column, country string }{
+	{`"user"`, "phone_number", "''"},
+	{"driver", "phone_number", "country"},
+	{"fleets", `"phoneNumber"`, "''"},
+}
+
+// NormalizeStoredPhones rewrites phone numbers stored before normalization in E.164. A number
+// that would collide with an account already using its E.164 form is left as it is; the
+// duplicate-account scan groups the two for an admin to merge. A no-op once every number is
+// normalized or can't be.
+func NormalizeStoredPhones() {
+	ctx := db.Unscoped()
+	for _, t := range storedPhones {
+		updated, collided := 0, 0
+		rows, err := db.Pool.Query(ctx,
+			`SELECT id, `+t.column+`, COALESCE(`+t.country+`, '') FROM `+t.table+`
+			 WHERE `+t.column+` !~ $1 AND `+t.column+` NOT LIKE 'deleted:%'`, e164Pattern)
+		if err != nil {
+			utils.Logger.Error("Failed to load phone numbers to normalize", zap.String("table", t.table), zap.Error(err))
+			continue
+		}
+		type stored struct{ id, phone, country string }
+		var pending []stored
+		for rows.Next() {
+			var s stored
+			if rows.Scan(&s.id, &s.phone, &s.country) == nil {
+				pending = append(pending, s)
+			}
+		}
+		rows.Close()
+
+		for _, s := range pending {
+			phone, err := utils.NormalizePhone(s.phone, s.country)
+			if err != nil {
+				continue
+			}
+			_, err = db.Pool.Exec(ctx, `UPDATE `+t.table+` SET `+t.column+`=$1, "updatedAt"=NOW() WHERE id=$2`, phone, s.id)
+			var pgErr *pgconn.PgError
+			switch {
+			case errors.As(err, &pgErr) && pgErr.Code == "23505":
+				collided++
+			case err != nil:
+				utils.Logger.Error("Failed to normalize phone number", zap.String("table", t.table), zap.String("id", s.id), zap.Error(err))
+			default:
+				updated++
+			}
+		}
+		if updated > 0 || collided > 0 {
+			utils.Logger.Info("Phone numbers normalized", zap.String("table", t.table), zap.Int("updated", updated), zap.Int("collisions", collided))
+		}
+	}
+	normalizeAllowlistPhones(ctx)
+}
+
+// normalizeAllowlistPhones rewrites zone allowlist entries, merging any that become the same.
+func normalizeAllowlistPhones(ctx context.Context) {
+	rows, err := db.Pool.Query(ctx, `SELECT zone, phone_number FROM zone_allowlist WHERE phone_number !~ $1`, e164Pattern)
+	if err != nil {
+		utils.Logger.Error("Failed to load allowlist numbers to normalize", zap.Error(err))
+		return
+	}
+	var entries [][2]string
+	for rows.Next() {
+		var zone, phone string
+		if rows.Scan(&zone, &phone) == nil {
+			entries = append(entries, [2]string{zone, phone})
+		}
+	}
+	rows.Close()
+
+	for _, e := range entries {
+		phone, err := utils.NormalizePhone(e[1], "")
+		if err != nil {
+			continue
+		}
+		_, err = db.Pool.Exec(ctx,
+			`WITH moved AS (DELETE FROM zone_allowlist WHERE zone=$1 AND phone_number=$2 RETURNING note, "createdAt")
+			 INSERT INTO zone_allowlist (zone, phone_number, note, "createdAt") SELECT $1, $3, note, "createdAt" FROM moved
+			 ON CONFLICT (zone, phone_number) DO NOTHING`, e[0], e[1], phone)
+		if err != nil {
+			utils.Logger.Error("Failed to normalize allowlist number", zap.String("zone", e[0]), zap.Error(err))
+		}
+	}
+}
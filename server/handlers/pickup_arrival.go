@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+	"ridewave/db"
+	"ridewave/events"
+	"ridewave/rides/fares"
+	"ridewave/rides/statemachine"
+	"ridewave/stores"
+	"ridewave/utils"
+)
+
+// ══════════════════════════════════════════════════
+// Pickup Arrival — geofenced arrival and the rider's waiting charge
+// ══════════════════════════════════════════════════
+
+// pickupArrivalRadiusMeters is how close to the pickup a driver must be to count as arrived.
+const pickupArrivalRadiusMeters = 100
+
+// ridePickupKeyPrefix holds the pickup a driver is heading to (drivers:pickup:<driverId>), from
+// acceptance until they reach it, so location updates are checked without a database read.
+const ridePickupKeyPrefix = "drivers:pickup:"
+
+const ridePickupTTL = 2 * time.Hour
+
+type pickupWatch struct {
+	RideID string  `json:"rideId"`
+	Lat    float64 `json:"lat"`
+	Lng    float64 `json:"lng"`
+}
+
+func init() {
+	events.Subscribe(watchAcceptedPickup)
+	stores.OnDriverLocation(detectPickupArrival)
+}
+
+// watchAcceptedPickup starts watching for the driver to reach the pickup of a ride they accepted.
+func watchAcceptedPickup(e events.Event) {
+	if e.Type != events.RideAccepted {
+		return
+	}
+	utils.SafeGo(func() {
+		ctx := db.Unscoped()
+		var driverID *string
+		var lat, lng *float64
+		err := db.Pool.QueryRow(ctx,
+			`SELECT "driverId", "originLat", "originLng" FROM rides WHERE id=$1 AND status='Accepted'`, e.RideID).
+			Scan(&driverID, &lat, &lng)
+		if err != nil || driverID == nil || lat == nil || lng == nil {
+			return
+		}
+		val, _ := json.Marshal(pickupWatch{RideID: e.RideID, Lat: *lat, Lng: *lng})
+		if err := db.RedisClient.Set(ctx, ridePickupKeyPrefix+*driverID, val, ridePickupTTL).Err(); err != nil {
+			utils.Logger.Warn("Failed to watch pickup arrival", zap.String("rideId", e.RideID), zap.Error(err))
+		}
+	})
+}
+
+// detectPickupArrival marks the ride Arriving the first time the driver's location falls inside
+// the pickup geofence. The watch is removed before the transition, so only one update acts on it.
+func detectPickupArrival(ctx context.Context, driverID string, lat, lng float64) {
+	raw, err := db.RedisClient.Get(ctx, ridePickupKeyPrefix+driverID).Bytes()
+	if err != nil {
+		return
+	}
+	var w pickupWatch
+	if json.Unmarshal(raw, &w) != nil || utils.CalculateDistance(lat, lng, w.Lat, w.Lng)*1000 > pickupArrivalRadiusMeters {
+		return
+	}
+	if n, _ := db.RedisClient.Del(ctx, ridePickupKeyPrefix+driverID).Result(); n == 0 {
+		return
+	}
+	utils.SafeGo(func() { markDriverArrived(driverID, w.RideID) })
+}
+
+// markDriverArrived moves an accepted ride to Arriving, which starts the rider's wait, and tells
+// the rider how long they can keep the driver waiting for free.
+func markDriverArrived(driverID, rideID string) {
+	policy := fares.LoadPolicy()
+	_, err := statemachine.Transition(db.Unscoped(), statemachine.Change{
+		RideID: rideID, To: statemachine.Arriving, From: []string{statemachine.Accepted},
+		ActorType: events.ActorDriver, ActorID: driverID, Data: map[string]any{"geofence": true},
+	}, func(ctx context.Context, tx pgx.Tx, from string) error {
+		var userID, driverName, currency string
+		err := tx.QueryRow(ctx,
+			`UPDATE rides r SET status='Arriving', "arrivedAt"=NOW(), "updatedAt"=NOW()
+			 FROM driver d WHERE r.id=$1 AND r."driverId"=$2 AND d.id=r."driverId"
+			 RETURNING r."userId", d.name, r.currency`, rideID, driverID).Scan(&userID, &driverName, &currency)
+		if err != nil {
+			return err
+		}
+		msg := fmt.Sprintf("%s is waiting at your pickup point.", driverName)
+		if policy.WaitingChargePerMinute > 0 {
+			msg = fmt.Sprintf("%s is waiting at your pickup point. Waiting is free for %d min, then %s/min.",
+				driverName, policy.WaitingFreeMinutes, formatMoney(currency, policy.WaitingChargePerMinute))
+		}
+		return queueNotifications(ctx, tx, rideStatusNotifications("user", userID, rideID, "Your driver has arrived 📍", msg, utils.FCMData{
+			"type":                   "ride_status",
+			"rideId":                 rideID,
+			"status":                 statemachine.Arriving,
+			"driverName":             driverName,
+			"driverId":               driverID,
+			"freeWaitingSeconds":     strconv.Itoa(policy.WaitingFreeMinutes * 60),
+			"waitingChargePerMinute": strconv.FormatFloat(policy.WaitingChargePerMinute, 'f', 2, 64),
+		})...)
+	})
+	if err != nil {
+		// A ride cancelled or started before the driver got there has nothing to mark
+		if !errors.Is(err, statemachine.ErrInvalidTransition) {
+			utils.Logger.Warn("Failed to mark driver arrived", zap.String("rideId", rideID), zap.Error(err))
+		}
+		return
+	}
+	kickNotificationOutbox()
+}
+
+// applyWaitingCharge adds the rider's wait at the pickup to the fare as the trip starts. It runs
+// in the start transaction; rides started without a detected arrival have no wait to charge.
+func applyWaitingCharge(ctx context.Context, tx pgx.Tx, rideID string) error {
+	var arrivedAt *time.Time
+	var now time.Time
+	if err := tx.QueryRow(ctx, `SELECT "arrivedAt", NOW() FROM rides WHERE id=$1`, rideID).Scan(&arrivedAt, &now); err != nil {
+		return err
+	}
+	if arrivedAt == nil {
+		return nil
+	}
+	w := fares.LoadPolicy().Waiting(*arrivedAt, now)
+	_, err := tx.Exec(ctx,
+		`UPDATE rides SET "waitingMinutes"=$2, "waitingCharge"=$3, charge=charge+$3 WHERE id=$1`, rideID, w.Minutes, w.Charge)
+	return err
+}
@@ -0,0 +1,429 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"ridewave/db"
+	"ridewave/models"
+	"ridewave/stores"
+	"ridewave/utils"
+)
+
+// ══════════════════════════════════════════════════
+// Ride Pooling — two riders, one driver, split fares
+// ══════════════════════════════════════════════════
+
+const poolVehicleType = "Pool"
+const maxPoolRiders = 2
+
+const (
+	legPickup  = "pickup"
+	legDropoff = "dropoff"
+)
+
+// poolDriverVehicleType is the driver vehicle that serves Pool requests (POOL_DRIVER_VEHICLE_TYPE, default Car).
+func poolDriverVehicleType() string {
+	if val := os.Getenv("POOL_DRIVER_VEHICLE_TYPE"); val != "" {
+		return val
+	}
+	return "Car"
+}
+
+func poolPickupRadiusKm() float64 {
+	if val, err := strconv.ParseFloat(os.Getenv("POOL_PICKUP_RADIUS_KM"), 64); err == nil && val > 0 {
+		return val
+	}
+	return 2.0
+}
+
+// poolMaxDetourPercent caps how much longer a rider's trip may get by sharing it.
+func poolMaxDetourPercent() float64 {
+	if val, err := strconv.ParseFloat(os.Getenv("POOL_MAX_DETOUR_PERCENT"), 64); err == nil && val >= 0 {
+		return val
+	}
+	return 50.0
+}
+
+// dispatchVehicleType maps a requested ride type to the driver vehicle type that serves it.
+func dispatchVehicleType(vehicleType string) string {
+	if vehicleType == poolVehicleType {
+		return poolDriverVehicleType()
+	}
+	return vehicleType
+}
+
+type poolStop struct {
+	RideID string
+	Kind   string
+	Lat    float64
+	Lng    float64
+}
+
+// poolRider is one ride's solo trip, as quoted when it was booked.
+type poolRider struct {
+	RideID   string
+	UserID   string
+	DriverID *string
+	Charge   float64
+	Distance int
+	Duration int
+	Pickup   [2]float64
+	Dropoff  [2]float64
+}
+
+type poolPlan struct {
+	Stops    []poolStop
+	Distance int
+	Duration int
+	Fares    map[string]float64
+}
+
+// joinOrOpenPool tries to add a new Pool ride to a nearby open pool; failing that the ride
+// opens its own pool. It returns the pool ID, the rider's fare and whether a co-rider was found.
+func joinOrOpenPool(ctx context.Context, rideID, userID string, cached *stores.CachedRoute) (string, float64, bool) {
+	rider := poolRider{
+		RideID: rideID, UserID: userID, Charge: cached.Fare, Distance: cached.Distance, Duration: cached.Duration,
+		Pickup: [2]float64{cached.OriginLat, cached.OriginLng}, Dropoff: [2]float64{cached.DestinationLat, cached.DestinationLng},
+	}
+
+	for _, anchor := range openPoolsNear(ctx, rider) {
+		plan, err := planPool(ctx, anchor, rider)
+		if err != nil {
+			utils.Logger.Debug("Pool match rejected", zap.String("poolId", anchor.RideID), zap.Error(err))
+			continue
+		}
+		driverID, err := joinPool(anchor, rider, plan)
+		if err != nil {
+			utils.Logger.Debug("Pool join failed", zap.String("poolId", anchor.RideID), zap.Error(err))
+			continue
+		}
+		notifyPoolJoined(anchor, plan.Fares[anchor.RideID], driverID)
+		return anchor.RideID, plan.Fares[rideID], true
+	}
+
+	_, err := db.Pool.Exec(ctx,
+		`WITH pooled AS (UPDATE rides SET "poolId"=id WHERE id=$1)
+		 INSERT INTO ride_legs ("poolId", "rideId", kind, seq, lat, lng)
+		 VALUES ($1, $1, 'pickup', 0, $2, $3), ($1, $1, 'dropoff', 1, $4, $5)`,
+		rideID, rider.Pickup[0], rider.Pickup[1], rider.Dropoff[0], rider.Dropoff[1])
+	if err != nil {
+		utils.Logger.Error("Failed to open ride pool", zap.String("rideId", rideID), zap.Error(err))
+	}
+	return rideID, cached.Fare, false
+}
+
+// openPoolsNear lists single-rider pools that haven't picked anyone up yet, closest pickup first.
+// ctx carries the rider's tenant, so riders are only pooled within one brand.
+func openPoolsNear(ctx context.Context, rider poolRider) []poolRider {
+	rows, err := db.Pool.Query(ctx,
+		`SELECT r.id, r."userId", r."driverId", r.charge, COALESCE(r."estimatedDistance", 0), COALESCE(r."estimatedDuration", 0),
+		 r."originLat", r."originLng", r."destinationLat", r."destinationLng"
+		 FROM rides r
+		 WHERE r."vehicleType"=$1 AND r."poolId"=r.id AND r.status IN ('Requested', 'Accepted') AND r."userId"<>$2
+		 AND r."createdAt" > NOW() - INTERVAL '15 minutes' AND r."originLat" IS NOT NULL AND r."destinationLat" IS NOT NULL
+		 AND (SELECT COUNT(*) FROM rides p WHERE p."poolId"=r.id AND p.status<>'Cancelled') < $3`,
+		poolVehicleType, rider.UserID, maxPoolRiders)
+	if err != nil {
+		utils.Logger.Error("Failed to query open pools", zap.Error(err))
+		return nil
+	}
+	defer rows.Close()
+
+	var pools []poolRider
+	for rows.Next() {
+		var p poolRider
+		if rows.Scan(&p.RideID, &p.UserID, &p.DriverID, &p.Charge, &p.Distance, &p.Duration,
+			&p.Pickup[0], &p.Pickup[1], &p.Dropoff[0], &p.Dropoff[1]) != nil {
+			continue
+		}
+		if utils.CalculateDistance(p.Pickup[0], p.Pickup[1], rider.Pickup[0], rider.Pickup[1]) <= poolPickupRadiusKm() {
+			pools = append(pools, p)
+		}
+	}
+	sort.Slice(pools, func(i, j int) bool {
+		return utils.CalculateDistance(pools[i].Pickup[0], pools[i].Pickup[1], rider.Pickup[0], rider.Pickup[1]) <
+			utils.CalculateDistance(pools[j].Pickup[0], pools[j].Pickup[1], rider.Pickup[0], rider.Pickup[1])
+	})
+	if len(pools) > 3 {
+		pools = pools[:3]
+	}
+	return pools
+}
+
+// planPool asks the Ola Route Optimizer for the best stop order, starting at the first rider's pickup,
+// and checks every rider is picked up before being dropped off and isn't detoured too far.
+func planPool(ctx context.Context, anchor, rider poolRider) (*poolPlan, error) {
+	stops := []poolStop{
+		{anchor.RideID, legPickup, anchor.Pickup[0], anchor.Pickup[1]},
+		{rider.RideID, legPickup, rider.Pickup[0], rider.Pickup[1]},
+		{anchor.RideID, legDropoff, anchor.Dropoff[0], anchor.Dropoff[1]},
+		{rider.RideID, legDropoff, rider.Dropoff[0], rider.Dropoff[1]},
+	}
+	locations := make([]string, len(stops))
+	for i, s := range stops {
+		locations[i] = fmt.Sprintf("%f,%f", s.Lat, s.Lng)
+	}
+
+	resp, err := utils.NewOlaMapsClient().WithContext(ctx).RouteOptimizer(strings.Join(locations, "|"), "first", "any", false, "driving")
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Routes) == 0 {
+		return nil, fmt.Errorf("route optimizer returned no routes")
+	}
+	route := resp.Routes[0]
+
+	// The order may or may not include the fixed starting point
+	order := route.WaypointOrder
+	if len(order) == len(stops)-1 {
+		order = append([]int{0}, order...)
+	}
+	if len(order) != len(stops) || len(route.Legs) != len(stops)-1 {
+		return nil, fmt.Errorf("unexpected optimizer response: %d stops, %d legs", len(order), len(route.Legs))
+	}
+
+	plan := &poolPlan{Fares: map[string]float64{}}
+	pickedAt := map[string]int{}
+	for i, idx := range order {
+		if idx < 0 || idx >= len(stops) {
+			return nil, fmt.Errorf("unexpected waypoint index %d", idx)
+		}
+		stop := stops[idx]
+		if stop.Kind == legPickup {
+			pickedAt[stop.RideID] = i
+		} else if _, ok := pickedAt[stop.RideID]; !ok {
+			return nil, fmt.Errorf("dropoff before pickup for ride %s", stop.RideID)
+		}
+		plan.Stops = append(plan.Stops, stop)
+	}
+	for _, leg := range route.Legs {
+		plan.Distance += int(leg.Distance.Value)
+		plan.Duration += int(leg.Duration.Value)
+	}
+
+	// Time each rider spends on board vs. their solo trip
+	maxDetour := 1 + poolMaxDetourPercent()/100
+	for _, r := range []poolRider{anchor, rider} {
+		onBoard := 0
+		for i := pickedAt[r.RideID]; i < len(plan.Stops)-1; i++ {
+			onBoard += int(route.Legs[i].Duration.Value)
+			if plan.Stops[i+1].RideID == r.RideID && plan.Stops[i+1].Kind == legDropoff {
+				break
+			}
+		}
+		if r.Duration > 0 && float64(onBoard) > float64(r.Duration)*maxDetour {
+			return nil, fmt.Errorf("ride %s detour too long (%ds vs %ds solo)", r.RideID, onBoard, r.Duration)
+		}
+	}
+
+	// Price the shared trip once and split it by each rider's solo distance; nobody pays more than quoted
+	currency := currencyAt(anchor.Pickup[0], anchor.Pickup[1])
+	total := CalculateFare(ctx, currency, poolVehicleType, plan.Distance, plan.Duration)
+	soloDistance := anchor.Distance + rider.Distance
+	for _, r := range []poolRider{anchor, rider} {
+		share := total / 2
+		if soloDistance > 0 {
+			share = total * float64(r.Distance) / float64(soloDistance)
+		}
+		plan.Fares[r.RideID] = math.Min(currency.Round(share), r.Charge)
+	}
+	return plan, nil
+}
+
+// joinPool attaches the rider to the anchor's pool, reprices both rides and rewrites the leg order.
+// The anchor row is locked so two riders can't both take the last seat.
+func joinPool(anchor, rider poolRider, plan *poolPlan) (*string, error) {
+	ctx := db.Unscoped()
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	var status string
+	var driverID *string
+	err = tx.QueryRow(ctx, `SELECT status, "driverId" FROM rides WHERE id=$1 FOR UPDATE`, anchor.RideID).Scan(&status, &driverID)
+	if err != nil {
+		return nil, err
+	}
+	if status != "Requested" && status != "Accepted" {
+		return nil, fmt.Errorf("pool is no longer open (%s)", status)
+	}
+	var riders int
+	tx.QueryRow(ctx, `SELECT COUNT(*) FROM rides WHERE "poolId"=$1 AND status<>'Cancelled'`, anchor.RideID).Scan(&riders)
+	if riders >= maxPoolRiders {
+		return nil, fmt.Errorf("pool is full")
+	}
+
+	// Once the pool has a driver the new rider is held for them to accept
+	_, err = tx.Exec(ctx,
+		`UPDATE rides SET "poolId"=$1, "driverId"=$2, charge=LEAST(charge, $3), "updatedAt"=NOW() WHERE id=$4`,
+		anchor.RideID, driverID, plan.Fares[rider.RideID], rider.RideID)
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.Exec(ctx,
+		`UPDATE rides SET charge=LEAST(charge, $1), "updatedAt"=NOW() WHERE id=$2`, plan.Fares[anchor.RideID], anchor.RideID)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM ride_legs WHERE "poolId"=$1`, anchor.RideID); err != nil {
+		return nil, err
+	}
+	for seq, stop := range plan.Stops {
+		_, err = tx.Exec(ctx,
+			`INSERT INTO ride_legs ("poolId", "rideId", kind, seq, lat, lng) VALUES ($1, $2, $3, $4, $5, $6)`,
+			anchor.RideID, stop.RideID, stop.Kind, seq, stop.Lat, stop.Lng)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return driverID, tx.Commit(ctx)
+}
+
+// notifyPoolJoined tells the first rider about their lower fare and the driver (if any) about the new pickup.
+func notifyPoolJoined(anchor poolRider, fare float64, driverID *string) {
+	notifications := []outboxNotification{outboxPush("user", anchor.UserID, anchor.RideID, "Co-rider joined 🤝",
+		fmt.Sprintf("Someone is sharing your Pool ride. Your fare is now %s.", currencyAt(anchor.Pickup[0], anchor.Pickup[1]).Format(fare)), utils.FCMData{
+			"type":   "pool_joined",
+			"rideId": anchor.RideID,
+			"fare":   fmt.Sprintf("%.2f", fare),
+		})}
+	if driverID != nil {
+		notifications = append(notifications, outboxPush("driver", *driverID, anchor.RideID,
+			"New Pool rider 🚗", "A second rider joined your Pool trip. Check your stops.", utils.FCMData{
+				"type":   "pool_joined",
+				"poolId": anchor.RideID,
+			}))
+	}
+	sendNotifications(context.Background(), notifications...)
+}
+
+// assignPoolSiblings gives the rest of a pool to the driver who accepted one of its rides.
+func assignPoolSiblings(rideID, driverID string) {
+	db.Pool.Exec(db.Unscoped(),
+		`UPDATE rides SET "driverId"=$2, "updatedAt"=NOW()
+		 WHERE "poolId"=(SELECT "poolId" FROM rides WHERE id=$1) AND id<>$1 AND "driverId" IS NULL AND status='Requested'`,
+		rideID, driverID)
+}
+
+// completePoolLeg marks a rider's pickup or dropoff stop as done.
+func completePoolLeg(rideID, kind string) {
+	db.Pool.Exec(db.Unscoped(),
+		`UPDATE ride_legs SET "completedAt"=NOW() WHERE "rideId"=$1 AND kind=$2 AND "completedAt" IS NULL`, rideID, kind)
+}
+
+// releasePoolSeat drops a cancelled ride's stops from its pool. Riders who joined a pool
+// whose first ride is cancelled before a driver took it are dispatched on their own.
+func releasePoolSeat(rideID string) {
+	db.Pool.Exec(db.Unscoped(), `DELETE FROM ride_legs WHERE "rideId"=$1`, rideID)
+
+	rows, err := db.Pool.Query(db.Unscoped(),
+		`SELECT id FROM rides WHERE "poolId"=$1 AND id<>$1 AND status='Requested' AND "driverId" IS NULL`, rideID)
+	if err != nil {
+		return
+	}
+	var orphaned []string
+	for rows.Next() {
+		var id string
+		if rows.Scan(&id) == nil {
+			orphaned = append(orphaned, id)
+		}
+	}
+	rows.Close()
+	for _, id := range orphaned {
+		redispatchRide(id)
+	}
+}
+
+// ridePoolSummary describes a pooled ride for its rider without exposing the co-rider.
+func ridePoolSummary(ctx context.Context, rideID string) gin.H {
+	var poolID *string
+	var riders int
+	err := db.Pool.QueryRow(ctx,
+		`SELECT r."poolId", (SELECT COUNT(*) FROM rides p WHERE p."poolId"=r."poolId" AND p.status<>'Cancelled')
+		 FROM rides r WHERE r.id=$1`, rideID).Scan(&poolID, &riders)
+	if err != nil || poolID == nil {
+		return nil
+	}
+	return gin.H{"poolId": *poolID, "riders": riders, "shared": riders > 1}
+}
+
+// GET /api/v1/driver/ride/:id/pool
+func GetPoolLegs(c *gin.Context) {
+	driver := c.MustGet("driver").(*models.Driver)
+	rideID := c.Param("id")
+
+	var poolID *string
+	err := db.Pool.QueryRow(c.Request.Context(),
+		`SELECT "poolId" FROM rides WHERE id=$1 AND "driverId"=$2`, rideID, driver.ID).Scan(&poolID)
+	if err != nil {
+		utils.RespondErrorCode(c, http.StatusNotFound, utils.CodeRideNotFound, "Ride not found", err)
+		return
+	}
+	if poolID == nil {
+		utils.RespondError(c, http.StatusBadRequest, "This is not a Pool ride", nil)
+		return
+	}
+
+	type poolLeg struct {
+		RideID      string     `json:"rideId"`
+		RiderName   string     `json:"riderName"`
+		Kind        string     `json:"kind"`
+		Seq         int        `json:"seq"`
+		Lat         float64    `json:"lat"`
+		Lng         float64    `json:"lng"`
+		Fare        float64    `json:"fare"`
+		RideStatus  string     `json:"rideStatus"`
+		CompletedAt *time.Time `json:"completedAt"`
+	}
+
+	rows, err := db.Pool.Query(c.Request.Context(),
+		`SELECT l."rideId", COALESCE(u.name, ''), l.kind, l.seq, l.lat, l.lng, r.charge, r.status, l."completedAt"
+		 FROM ride_legs l
+		 JOIN rides r ON r.id=l."rideId"
+		 JOIN "user" u ON u.id=r."userId"
+		 WHERE l."poolId"=$1 AND r."driverId"=$2
+		 ORDER BY l.seq`, *poolID, driver.ID)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to fetch pool stops", err)
+		return
+	}
+	defer rows.Close()
+
+	legs := []poolLeg{}
+	for rows.Next() {
+		var l poolLeg
+		if rows.Scan(&l.RideID, &l.RiderName, &l.Kind, &l.Seq, &l.Lat, &l.Lng, &l.Fare, &l.RideStatus, &l.CompletedAt) == nil {
+			legs = append(legs, l)
+		}
+	}
+
+	utils.RespondSuccess(c, http.StatusOK, "Pool stops", gin.H{"poolId": *poolID, "legs": legs})
+}
+
+// dispatchOrPool sends a new ride to nearby drivers, first trying to seat Pool requests in an existing pool.
+// A rider who joins a pool isn't dispatched separately: the pool's first ride already is.
+func dispatchOrPool(ctx context.Context, rideID string, user *models.User, cached *stores.CachedRoute) (int, gin.H) {
+	if cached.VehicleType != poolVehicleType {
+		return dispatchRideRequest(rideID, user, cached), nil
+	}
+	poolID, fare, matched := joinOrOpenPool(ctx, rideID, user.ID, cached)
+	cached.Fare = fare
+	pool := gin.H{"poolId": poolID, "shared": matched}
+	if matched {
+		return 0, pool
+	}
+	return dispatchRideRequest(rideID, user, cached), pool
+}
@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"ridewave/db"
+	"ridewave/models"
+	"ridewave/promos"
+	"ridewave/stores"
+	"ridewave/utils"
+)
+
+// ══════════════════════════════════════════════════
+// Promo Codes — rider-side validation & redemption
+// ══════════════════════════════════════════════════
+
+// promoError is a user-facing reason a promo code can't be applied.
+type promoError string
+
+func (e promoError) Error() string { return string(e) }
+
+const promoSelectCols = `id, code, "discountType", "discountValue", "maxDiscount", "minRideAmount",
+	"usageLimit", "usedCount", "expiresAt", "isActive", "userId", "createdAt",
+	"firstRideOnly", "vehicleTypes", zones, segments, days, "timeFrom", "timeUntil", "perUserLimit"`
+
+func scanPromoCode(scanner interface{ Scan(dest ...any) error }, pc *models.PromoCode) error {
+	r := &pc.Rules
+	return scanner.Scan(&pc.ID, &pc.Code, &pc.DiscountType, &pc.DiscountValue, &pc.MaxDiscount,
+		&pc.MinRideAmount, &pc.UsageLimit, &pc.UsedCount, &pc.ExpiresAt, &pc.IsActive, &pc.UserID, &pc.CreatedAt,
+		&r.FirstRideOnly, &r.VehicleTypes, &r.Zones, &r.Segments, &r.Days, &r.TimeFrom, &r.TimeUntil, &r.PerUserLimit)
+}
+
+// promoBooking describes a booking from its planned route, for the promo rules.
+func promoBooking(route *stores.CachedRoute) promos.Booking {
+	return promos.Booking{
+		VehicleType: route.VehicleType,
+		Zone:        zoneForPoint(route.OriginLat, route.OriginLng),
+		At:          time.Now().In(serviceLocation()),
+	}
+}
+
+// promoRider loads the rider's history the code's rules look at, through q so a redemption
+// counts it in its own transaction; codes that don't target riders skip the query.
+func promoRider(ctx context.Context, q db.Querier, pc *models.PromoCode, userID string) (promos.Rider, error) {
+	var r promos.Rider
+	if !promos.NeedsRider(pc.Rules) {
+		return r, nil
+	}
+	err := q.QueryRow(ctx,
+		`SELECT COUNT(*) FILTER (WHERE status<>'Cancelled'),
+		 COUNT(*) FILTER (WHERE status='Completed'),
+		 COUNT(*) FILTER (WHERE status='Completed' AND "completedAt" > $3),
+		 COUNT(*) FILTER (WHERE status<>'Cancelled' AND UPPER("promoCode")=UPPER($2))
+		 FROM rides WHERE "userId"=$1`, userID, pc.Code, time.Now().Add(-promos.RecentWindow)).
+		Scan(&r.Rides, &r.CompletedRides, &r.RecentRides, &r.Uses)
+	return r, err
+}
+
+// promoDiscount checks a promo against a rider, booking and fare and returns the discount it grants.
+func promoDiscount(ctx context.Context, q db.Querier, pc *models.PromoCode, userID string, booking promos.Booking, fare float64) (float64, error) {
+	if pc.UserID != nil && *pc.UserID != userID {
+		return 0, promoError("Invalid promo code")
+	}
+	if !pc.IsActive {
+		return 0, promoError("This promo code is no longer active")
+	}
+	if pc.ExpiresAt != nil && time.Now().After(*pc.ExpiresAt) {
+		return 0, promoError("This promo code has expired")
+	}
+	if pc.UsedCount >= pc.UsageLimit {
+		return 0, promoError("This promo code has reached its usage limit")
+	}
+	if fare < pc.MinRideAmount {
+		return 0, promoError("Ride fare is below the minimum amount for this promo")
+	}
+	rider, err := promoRider(ctx, q, pc, userID)
+	if err != nil {
+		return 0, err
+	}
+	var rejected promos.Rejection
+	if err := promos.Check(pc.Rules, rider, booking); errors.As(err, &rejected) {
+		return 0, promoError(rejected)
+	}
+
+	var discount float64
+	if pc.DiscountType == "flat" {
+		discount = pc.DiscountValue
+	} else {
+		discount = fare * pc.DiscountValue / 100.0
+	}
+	if pc.MaxDiscount != nil && discount > *pc.MaxDiscount {
+		discount = *pc.MaxDiscount
+	}
+	if discount > fare {
+		discount = fare
+	}
+	return math.Round(discount*100) / 100, nil
+}
+
+// validatePromo looks up a code (case-insensitive) and prices it against a booking's fare without redeeming it.
+func validatePromo(ctx context.Context, userID, code string, booking promos.Booking, fare float64) (*models.PromoCode, float64, error) {
+	var pc models.PromoCode
+	err := scanPromoCode(db.Pool.QueryRow(ctx,
+		`SELECT `+promoSelectCols+` FROM promo_codes WHERE UPPER(code)=UPPER($1)`, strings.TrimSpace(code)), &pc)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, 0, promoError("Invalid promo code")
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	discount, err := promoDiscount(ctx, db.Pool, &pc, userID, booking, fare)
+	if err != nil {
+		return nil, 0, err
+	}
+	return &pc, discount, nil
+}
+
+// insertRideWithPromo redeems the promo and books the ride at the discounted fare in one
+// serializable transaction, so usedCount is only bumped for rides that were actually created and
+// two bookings at once can't both pass a first-ride or per-rider limit.
+func insertRideWithPromo(ctx context.Context, userID, routeID string, cached *stores.CachedRoute, paymentMode, code string) (string, float64, error) {
+	var rideID string
+	var discount float64
+	err := db.WithSerializableTx(ctx, func(tx pgx.Tx) error {
+		// Lock the promo row so concurrent bookings can't overshoot the usage limit
+		var pc models.PromoCode
+		err := scanPromoCode(tx.QueryRow(ctx,
+			`SELECT `+promoSelectCols+` FROM promo_codes WHERE UPPER(code)=UPPER($1) FOR UPDATE`, strings.TrimSpace(code)), &pc)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return promoError("Invalid promo code")
+		}
+		if err != nil {
+			return err
+		}
+
+		discount, err = promoDiscount(ctx, tx, &pc, userID, promoBooking(cached), cached.Fare)
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(ctx, `UPDATE promo_codes SET "usedCount"="usedCount"+1 WHERE id=$1`, pc.ID); err != nil {
+			return err
+		}
+
+		discounted := *cached
+		discounted.Fare = cached.Fare - discount
+
+		rideID, err = repos.Rides.CreateTx(ctx, tx, newRide(userID, routeID, &discounted, paymentMode))
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx,
+			`UPDATE rides SET "promoCode"=$1, discount=$2, "originalFare"=$3 WHERE id=$4`,
+			pc.Code, discount, cached.Fare, rideID)
+		return err
+	})
+	if err != nil {
+		return "", 0, err
+	}
+	return rideID, discount, nil
+}
+
+// POST /api/v1/user/promo/validate
+func ValidatePromoCode(c *gin.Context) {
+	var body struct {
+		Code        string  `json:"code" binding:"required"`
+		RouteID     string  `json:"routeId"` // preferred: price against the server-side estimate
+		Fare        float64 `json:"fare"`
+		VehicleType string  `json:"vehicleType"` // without a route, for codes limited to vehicle types
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid request", err)
+		return
+	}
+
+	fare := body.Fare
+	booking := promos.Booking{VehicleType: body.VehicleType, At: time.Now().In(serviceLocation())}
+	if body.RouteID != "" {
+		cached, err := stores.GetPlannedRoute(c.Request.Context(), body.RouteID)
+		if err != nil {
+			utils.RespondErrorCode(c, http.StatusGone, utils.CodeRouteExpired, "This route has expired. Please get a fresh estimate.", err)
+			return
+		}
+		fare, booking = cached.Fare, promoBooking(cached)
+	}
+
+	user := c.MustGet("user").(*models.User)
+	promo, discount, err := validatePromo(c.Request.Context(), user.ID, body.Code, booking, fare)
+	if err != nil {
+		var rejected promoError
+		if errors.As(err, &rejected) {
+			utils.RespondErrorCode(c, http.StatusBadRequest, utils.CodePromoInvalid, rejected.Error(), nil)
+			return
+		}
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to validate promo code", err)
+		return
+	}
+
+	utils.RespondSuccess(c, http.StatusOK, "Promo code applied", gin.H{
+		"code":         promo.Code,
+		"discountType": promo.DiscountType,
+		"discount":     discount,
+		"fare":         fare,
+		"finalFare":    fare - discount,
+	})
+}
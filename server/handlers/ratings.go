@@ -0,0 +1,286 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"ridewave/db"
+	"ridewave/models"
+	"ridewave/utils"
+)
+
+// ══════════════════════════════════════════════════
+// Rating Prompts — server-driven tags & mandatory feedback
+// ══════════════════════════════════════════════════
+
+const (
+	ratingAudienceRider  = "rider"  // rider rating the driver
+	ratingAudienceDriver = "driver" // driver rating the rider
+)
+
+// feedbackError is a user-facing reason a rating submission was rejected.
+type feedbackError string
+
+func (e feedbackError) Error() string { return string(e) }
+
+const ratingTagSelectCols = `id, audience, "vehicleType", key, labels, "minRating", "maxRating", "sortOrder", "isActive", "createdAt"`
+
+func scanRatingTag(scanner interface{ Scan(dest ...any) error }, t *models.RatingTag) error {
+	var labels []byte
+	if err := scanner.Scan(&t.ID, &t.Audience, &t.VehicleType, &t.Key, &labels, &t.MinRating, &t.MaxRating,
+		&t.SortOrder, &t.IsActive, &t.CreatedAt); err != nil {
+		return err
+	}
+	return json.Unmarshal(labels, &t.Labels)
+}
+
+// loadRatingConfig returns the vehicle-specific rule if one exists, else the audience default.
+func loadRatingConfig(ctx context.Context, audience, vehicleType string) models.RatingConfig {
+	cfg := models.RatingConfig{Audience: audience}
+	db.Pool.QueryRow(ctx,
+		`SELECT id, audience, "vehicleType", "mandatoryBelow", "requireComment", "updatedAt"
+		 FROM rating_config WHERE audience=$1 AND "vehicleType" IN ($2, '')
+		 ORDER BY "vehicleType" DESC LIMIT 1`, audience, vehicleType).
+		Scan(&cfg.ID, &cfg.Audience, &cfg.VehicleType, &cfg.MandatoryBelow, &cfg.RequireComment, &cfg.UpdatedAt)
+	return cfg
+}
+
+// loadRatingTags returns the active tags for an audience; a vehicle-specific tag overrides a default one with the same key.
+func loadRatingTags(ctx context.Context, audience, vehicleType string) []models.RatingTag {
+	rows, err := db.Pool.Query(ctx,
+		`SELECT `+ratingTagSelectCols+` FROM rating_tags
+		 WHERE audience=$1 AND "vehicleType" IN ($2, '') AND "isActive"=TRUE
+		 ORDER BY "sortOrder" ASC, "vehicleType" DESC`, audience, vehicleType)
+	if err != nil {
+		return []models.RatingTag{}
+	}
+	defer rows.Close()
+
+	tags := []models.RatingTag{}
+	index := map[string]int{}
+	for rows.Next() {
+		var t models.RatingTag
+		if scanRatingTag(rows, &t) != nil {
+			continue
+		}
+		if i, seen := index[t.Key]; seen {
+			if t.VehicleType != "" {
+				tags[i] = t
+			}
+			continue
+		}
+		index[t.Key] = len(tags)
+		tags = append(tags, t)
+	}
+	return tags
+}
+
+// localizedLabel picks the label for lang, falling back to English and then the raw key.
+func localizedLabel(t models.RatingTag, lang string) string {
+	if label, ok := t.Labels[lang]; ok && label != "" {
+		return label
+	}
+	if label, ok := t.Labels["en"]; ok && label != "" {
+		return label
+	}
+	return t.Key
+}
+
+// validateRatingFeedback enforces the configured rules for a rating submission.
+func validateRatingFeedback(audience, vehicleType string, rating float64, tags []string, comment string) error {
+	if rating < 1 || rating > 5 {
+		return feedbackError("Rating must be between 1 and 5")
+	}
+
+	valid := map[string]models.RatingTag{}
+	for _, t := range loadRatingTags(context.Background(), audience, vehicleType) {
+		valid[t.Key] = t
+	}
+	for _, key := range tags {
+		t, ok := valid[key]
+		if !ok {
+			return feedbackError(fmt.Sprintf("Unknown feedback tag %q", key))
+		}
+		if rating < t.MinRating || rating > t.MaxRating {
+			return feedbackError(fmt.Sprintf("Tag %q doesn't apply to a %.0f-star rating", key, rating))
+		}
+	}
+
+	cfg := loadRatingConfig(context.Background(), audience, vehicleType)
+	if rating < cfg.MandatoryBelow {
+		hasComment := strings.TrimSpace(comment) != ""
+		if cfg.RequireComment && !hasComment {
+			return feedbackError("Please tell us what went wrong")
+		}
+		if !hasComment && len(tags) == 0 {
+			return feedbackError("Please pick a reason or leave a comment for this rating")
+		}
+	}
+	return nil
+}
+
+func respondRatingConfig(c *gin.Context, audience string) {
+	vehicleType := c.Query("vehicleType")
+	lang := c.DefaultQuery("lang", "en")
+
+	type TagOption struct {
+		Key       string  `json:"key"`
+		Label     string  `json:"label"`
+		MinRating float64 `json:"minRating"`
+		MaxRating float64 `json:"maxRating"`
+	}
+	options := []TagOption{}
+	for _, t := range loadRatingTags(c.Request.Context(), audience, vehicleType) {
+		options = append(options, TagOption{
+			Key:       t.Key,
+			Label:     localizedLabel(t, lang),
+			MinRating: t.MinRating,
+			MaxRating: t.MaxRating,
+		})
+	}
+
+	cfg := loadRatingConfig(c.Request.Context(), audience, vehicleType)
+	utils.RespondSuccess(c, http.StatusOK, "Rating config", gin.H{
+		"mandatoryBelow": cfg.MandatoryBelow,
+		"requireComment": cfg.RequireComment,
+		"tags":           options,
+	})
+}
+
+// GET /api/v1/user/rating-config?vehicleType=Car&lang=en
+func GetRiderRatingConfig(c *gin.Context) {
+	respondRatingConfig(c, ratingAudienceRider)
+}
+
+// GET /api/v1/driver/rating-config?vehicleType=Car&lang=en
+func GetDriverRatingConfig(c *gin.Context) {
+	respondRatingConfig(c, ratingAudienceDriver)
+}
+
+// ══════════════════════════════════════════════════
+// Admin: Rating Configuration
+// ══════════════════════════════════════════════════
+
+// GET /api/v1/admin/rating-config
+func AdminGetRatingConfig(c *gin.Context) {
+	rows, err := db.Pool.Query(adminContext(c),
+		`SELECT id, audience, "vehicleType", "mandatoryBelow", "requireComment", "updatedAt"
+		 FROM rating_config ORDER BY audience, "vehicleType"`)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to fetch rating config", err)
+		return
+	}
+	defer rows.Close()
+
+	configs := []models.RatingConfig{}
+	for rows.Next() {
+		var cfg models.RatingConfig
+		rows.Scan(&cfg.ID, &cfg.Audience, &cfg.VehicleType, &cfg.MandatoryBelow, &cfg.RequireComment, &cfg.UpdatedAt)
+		configs = append(configs, cfg)
+	}
+
+	tagRows, err := db.Pool.Query(adminContext(c),
+		`SELECT `+ratingTagSelectCols+` FROM rating_tags ORDER BY audience, "vehicleType", "sortOrder"`)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to fetch rating tags", err)
+		return
+	}
+	defer tagRows.Close()
+
+	tags := []models.RatingTag{}
+	for tagRows.Next() {
+		var t models.RatingTag
+		scanRatingTag(tagRows, &t)
+		tags = append(tags, t)
+	}
+
+	utils.RespondSuccess(c, http.StatusOK, "Rating config", gin.H{"configs": configs, "tags": tags})
+}
+
+// PUT /api/v1/admin/rating-config — create or update the rule for an audience/vehicle type
+func AdminUpsertRatingConfig(c *gin.Context) {
+	var body struct {
+		Audience       string  `json:"audience" binding:"required"`
+		VehicleType    string  `json:"vehicleType"`
+		MandatoryBelow float64 `json:"mandatoryBelow"`
+		RequireComment bool    `json:"requireComment"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid request", err)
+		return
+	}
+	if body.Audience != ratingAudienceRider && body.Audience != ratingAudienceDriver {
+		utils.RespondError(c, http.StatusBadRequest, "Audience must be 'rider' or 'driver'", nil)
+		return
+	}
+
+	_, err := db.Pool.Exec(adminContext(c),
+		`INSERT INTO rating_config (audience, "vehicleType", "mandatoryBelow", "requireComment", "updatedAt")
+		 VALUES ($1, $2, $3, $4, NOW())
+		 ON CONFLICT (audience, "vehicleType") DO UPDATE
+		 SET "mandatoryBelow"=EXCLUDED."mandatoryBelow", "requireComment"=EXCLUDED."requireComment", "updatedAt"=NOW()`,
+		body.Audience, body.VehicleType, body.MandatoryBelow, body.RequireComment)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to save rating config", err)
+		return
+	}
+	utils.RespondSuccess(c, http.StatusOK, "Rating config saved", nil)
+}
+
+// PUT /api/v1/admin/rating-tag — create or update a feedback tag
+func AdminUpsertRatingTag(c *gin.Context) {
+	var body struct {
+		Audience    string            `json:"audience" binding:"required"`
+		VehicleType string            `json:"vehicleType"`
+		Key         string            `json:"key" binding:"required"`
+		Labels      map[string]string `json:"labels" binding:"required"` // {"en": "Rude driver", "hi": "..."}
+		MinRating   float64           `json:"minRating"`
+		MaxRating   float64           `json:"maxRating"`
+		SortOrder   int               `json:"sortOrder"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid request", err)
+		return
+	}
+	if body.Audience != ratingAudienceRider && body.Audience != ratingAudienceDriver {
+		utils.RespondError(c, http.StatusBadRequest, "Audience must be 'rider' or 'driver'", nil)
+		return
+	}
+	if body.MinRating == 0 {
+		body.MinRating = 1
+	}
+	if body.MaxRating == 0 {
+		body.MaxRating = 5
+	}
+
+	labels, _ := json.Marshal(body.Labels)
+	var id string
+	err := db.Pool.QueryRow(adminContext(c),
+		`INSERT INTO rating_tags (audience, "vehicleType", key, labels, "minRating", "maxRating", "sortOrder")
+		 VALUES ($1, $2, $3, $4, $5, $6, $7)
+		 ON CONFLICT (audience, "vehicleType", key) DO UPDATE
+		 SET labels=EXCLUDED.labels, "minRating"=EXCLUDED."minRating", "maxRating"=EXCLUDED."maxRating",
+		     "sortOrder"=EXCLUDED."sortOrder", "isActive"=TRUE
+		 RETURNING id`,
+		body.Audience, body.VehicleType, body.Key, labels, body.MinRating, body.MaxRating, body.SortOrder).Scan(&id)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to save rating tag", err)
+		return
+	}
+	utils.RespondSuccess(c, http.StatusOK, "Rating tag saved", gin.H{"id": id})
+}
+
+// DELETE /api/v1/admin/rating-tag/:id — soft delete (deactivate)
+func AdminDeleteRatingTag(c *gin.Context) {
+	_, err := db.Pool.Exec(adminContext(c),
+		`UPDATE rating_tags SET "isActive"=FALSE WHERE id=$1`, c.Param("id"))
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to deactivate rating tag", err)
+		return
+	}
+	utils.RespondSuccess(c, http.StatusOK, "Rating tag deactivated", nil)
+}
@@ -0,0 +1,373 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"ridewave/db"
+	"ridewave/rides/statemachine"
+	"ridewave/stores"
+	"ridewave/utils"
+)
+
+// ══════════════════════════════════════════════════
+// Redis Memory Guard — key counts, memory and TTL enforcement
+// ══════════════════════════════════════════════════
+
+const (
+	redisAuditLockKey   = "redis:audit:lock"
+	redisAuditReportKey = "redis:audit:last"
+	redisAuditScanBatch = 1000
+)
+
+// redisKeyPolicy caps how long keys under a prefix may live. Keys found without a TTL get MaxTTL.
+type redisKeyPolicy struct {
+	Prefix string
+	MaxTTL time.Duration
+}
+
+// redisKeyPolicies lists every key family the server writes with an expiry. A key under one of
+// these prefixes without a TTL was left behind by a crash between a write and its EXPIRE.
+var redisKeyPolicies = []redisKeyPolicy{
+	{stores.RouteCacheKeyPrefix, 15 * time.Minute},
+	{stores.DriverDataKeyPrefix, time.Hour},
+	{stores.DriverActiveRideKeyPrefix, 12 * time.Hour},
+	{stores.RideTrackKeyPrefix, 24 * time.Hour},
+	{stores.SocketDriverKeyPrefix, 24 * time.Hour},
+	{rideAtDestinationKeyPrefix, 24 * time.Hour},
+	{ridePromptedKeyPrefix, 24 * time.Hour},
+	{ridePickupKeyPrefix, ridePickupTTL},
+	{rideOTPAttemptsKeyPrefix, 30 * time.Minute},
+	{rideETAKeyPrefix, 10 * time.Minute},
+	{utils.NearbyETAKeyPrefix, time.Minute},
+	{statemachine.LockKeyPrefix, time.Minute},
+	{adminLoginAttemptsKeyPrefix, 15 * time.Minute},
+	{"demand:", demandCacheTTL},
+	{"idempotency:", 24 * time.Hour},
+	{"ratelimit:", time.Hour},
+	{"redis:audit:", 24 * time.Hour},
+	{backupLockKey, 24 * time.Hour},
+	{analyticsStatsLockKey, time.Hour},
+}
+
+// redisPersistentKeys are meant to live without a TTL.
+var redisPersistentKeys = map[string]bool{
+	stores.DriverGeoKey: true,
+	"retry:writes":      true,
+	"retry:writes:dead": true,
+}
+
+type redisKeyStats struct {
+	Prefix   string `json:"prefix"`
+	Keys     int64  `json:"keys"`
+	NoTTL    int64  `json:"noTtl"`
+	Enforced int64  `json:"enforced"` // TTLs set by this run
+}
+
+type redisAuditReport struct {
+	RanAt           time.Time       `json:"ranAt"`
+	DurationMs      int64           `json:"durationMs"`
+	DBSize          int64           `json:"dbSize"`
+	Scanned         int64           `json:"scanned"`
+	Truncated       bool            `json:"truncated"` // stopped at REDIS_AUDIT_MAX_KEYS
+	UsedMemory      int64           `json:"usedMemory"`
+	UsedMemoryPeak  int64           `json:"usedMemoryPeak"`
+	MaxMemory       int64           `json:"maxMemory"`
+	MaxMemoryPolicy string          `json:"maxMemoryPolicy"`
+	MemoryPercent   float64         `json:"memoryPercent"` // of maxmemory, 0 when unlimited
+	OverThreshold   bool            `json:"overThreshold"`
+	Prefixes        []redisKeyStats `json:"prefixes"`
+	UnknownNoTTL    []string        `json:"unknownNoTtl"`   // sample of unrecognised keys without a TTL
+	StaleGeoPruned  int64           `json:"staleGeoPruned"` // drivers dropped from the geo index
+	EnforcedTotal   int64           `json:"enforcedTotal"`
+	NoTTLTotal      int64           `json:"noTtlTotal"`
+}
+
+// redisAuditInterval is REDIS_AUDIT_INTERVAL_MINUTES (default 15).
+func redisAuditInterval() time.Duration {
+	if val, err := strconv.Atoi(os.Getenv("REDIS_AUDIT_INTERVAL_MINUTES")); err == nil && val > 0 {
+		return time.Duration(val) * time.Minute
+	}
+	return 15 * time.Minute
+}
+
+// redisAuditMaxKeys bounds one run's SCAN (REDIS_AUDIT_MAX_KEYS, default 500000).
+func redisAuditMaxKeys() int64 {
+	if val, err := strconv.ParseInt(os.Getenv("REDIS_AUDIT_MAX_KEYS"), 10, 64); err == nil && val > 0 {
+		return val
+	}
+	return 500000
+}
+
+// redisMemoryWarnPercent is the share of maxmemory that logs a warning (REDIS_MEMORY_WARN_PERCENT, default 80).
+func redisMemoryWarnPercent() float64 {
+	if val, err := strconv.ParseFloat(os.Getenv("REDIS_MEMORY_WARN_PERCENT"), 64); err == nil && val > 0 {
+		return val
+	}
+	return 80
+}
+
+// StartRedisAuditWorker audits Redis every REDIS_AUDIT_INTERVAL_MINUTES. A Redis lock makes sure
+// only one instance runs each audit.
+func StartRedisAuditWorker(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(redisAuditInterval())
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				ok, err := db.RedisClient.SetNX(ctx, redisAuditLockKey, "1", redisAuditInterval()/2).Result()
+				if err != nil || !ok {
+					continue
+				}
+				runRedisAudit(ctx)
+			case <-ctx.Done():
+				utils.Logger.Info("Redis Audit Worker shutting down...")
+				return
+			}
+		}
+	}()
+}
+
+// runRedisAudit counts keys per policy prefix, gives TTL-less keys their policy TTL, prunes
+// drivers whose location expired from the geo index and records memory usage.
+func runRedisAudit(ctx context.Context) (*redisAuditReport, error) {
+	start := time.Now()
+	report := &redisAuditReport{RanAt: start, UnknownNoTTL: []string{}}
+	stats := make([]redisKeyStats, len(redisKeyPolicies)+1)
+	for i, p := range redisKeyPolicies {
+		stats[i].Prefix = p.Prefix
+	}
+	stats[len(redisKeyPolicies)].Prefix = "other"
+
+	maxKeys := redisAuditMaxKeys()
+	var cursor uint64
+	for {
+		keys, next, err := db.RedisClient.Scan(ctx, cursor, "*", redisAuditScanBatch).Result()
+		if err != nil {
+			return nil, err
+		}
+		if err := auditKeyBatch(ctx, keys, stats, report); err != nil {
+			return nil, err
+		}
+		report.Scanned += int64(len(keys))
+		cursor = next
+		if cursor == 0 {
+			break
+		}
+		if report.Scanned >= maxKeys {
+			report.Truncated = true
+			break
+		}
+	}
+
+	for _, s := range stats {
+		if s.Keys > 0 {
+			report.Prefixes = append(report.Prefixes, s)
+		}
+		report.NoTTLTotal += s.NoTTL
+		report.EnforcedTotal += s.Enforced
+	}
+
+	report.StaleGeoPruned = pruneStaleDriverGeo(ctx)
+	report.DBSize, _ = db.RedisClient.DBSize(ctx).Result()
+	readRedisMemory(ctx, report)
+	report.DurationMs = time.Since(start).Milliseconds()
+
+	if report.OverThreshold {
+		utils.Logger.Warn("Redis memory above threshold",
+			zap.Int64("usedMemory", report.UsedMemory), zap.Int64("maxMemory", report.MaxMemory),
+			zap.Float64("percent", report.MemoryPercent))
+	}
+	if report.EnforcedTotal > 0 || report.StaleGeoPruned > 0 || len(report.UnknownNoTTL) > 0 {
+		utils.Logger.Info("Redis audit enforced TTLs",
+			zap.Int64("enforced", report.EnforcedTotal), zap.Int64("staleGeoPruned", report.StaleGeoPruned),
+			zap.Strings("unknownNoTtl", report.UnknownNoTTL))
+	}
+
+	if val, err := json.Marshal(report); err == nil {
+		db.RedisClient.Set(ctx, redisAuditReportKey, val, 24*time.Hour)
+	}
+	return report, nil
+}
+
+// auditKeyBatch classifies one SCAN page and expires policy keys that have no TTL.
+func auditKeyBatch(ctx context.Context, keys []string, stats []redisKeyStats, report *redisAuditReport) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	pipe := db.RedisClient.Pipeline()
+	ttls := make([]*redis.DurationCmd, len(keys))
+	for i, key := range keys {
+		ttls[i] = pipe.TTL(ctx, key)
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return err
+	}
+
+	expire := db.RedisClient.Pipeline()
+	for i, key := range keys {
+		policy := -1
+		for j, p := range redisKeyPolicies {
+			if strings.HasPrefix(key, p.Prefix) {
+				policy = j
+				break
+			}
+		}
+		s := &stats[len(redisKeyPolicies)]
+		if policy >= 0 {
+			s = &stats[policy]
+		}
+		s.Keys++
+
+		// -1 means the key exists without an expiry (-2: it expired since SCAN)
+		if ttls[i].Val() != -1 || redisPersistentKeys[key] {
+			continue
+		}
+		s.NoTTL++
+		if policy < 0 {
+			if len(report.UnknownNoTTL) < 20 {
+				report.UnknownNoTTL = append(report.UnknownNoTTL, key)
+			}
+			continue
+		}
+		expire.Expire(ctx, key, redisKeyPolicies[policy].MaxTTL)
+		s.Enforced++
+	}
+	if expire.Len() > 0 {
+		if _, err := expire.Exec(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pruneStaleDriverGeo drops drivers from the geo index whose location data has expired. The
+// index itself never expires, so drivers who vanish without disconnecting would stay forever.
+func pruneStaleDriverGeo(ctx context.Context) int64 {
+	members, err := db.RedisClient.ZRange(ctx, stores.DriverGeoKey, 0, -1).Result()
+	if err != nil || len(members) == 0 {
+		return 0
+	}
+	pipe := db.RedisClient.Pipeline()
+	exists := make([]*redis.IntCmd, len(members))
+	for i, id := range members {
+		exists[i] = pipe.Exists(ctx, stores.DriverDataKeyPrefix+id)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0
+	}
+
+	stale := []interface{}{}
+	for i, id := range members {
+		if exists[i].Val() == 0 {
+			stale = append(stale, id)
+		}
+	}
+	if len(stale) == 0 {
+		return 0
+	}
+	removed, _ := db.RedisClient.ZRem(ctx, stores.DriverGeoKey, stale...).Result()
+	return removed
+}
+
+// readRedisMemory fills the memory fields from INFO memory.
+func readRedisMemory(ctx context.Context, report *redisAuditReport) {
+	info, err := db.RedisClient.Info(ctx, "memory").Result()
+	if err != nil {
+		return
+	}
+	for _, line := range strings.Split(info, "\n") {
+		name, value, ok := strings.Cut(strings.TrimSpace(line), ":")
+		if !ok {
+			continue
+		}
+		switch name {
+		case "used_memory":
+			report.UsedMemory, _ = strconv.ParseInt(value, 10, 64)
+		case "used_memory_peak":
+			report.UsedMemoryPeak, _ = strconv.ParseInt(value, 10, 64)
+		case "maxmemory":
+			report.MaxMemory, _ = strconv.ParseInt(value, 10, 64)
+		case "maxmemory_policy":
+			report.MaxMemoryPolicy = value
+		}
+	}
+	if report.MaxMemory > 0 {
+		report.MemoryPercent = float64(report.UsedMemory*10000/report.MaxMemory) / 100
+		report.OverThreshold = report.MemoryPercent >= redisMemoryWarnPercent()
+	}
+}
+
+// RedisAuditSummary is the last audit's headline numbers for /health, nil before the first run.
+func RedisAuditSummary(ctx context.Context) gin.H {
+	if db.RedisClient == nil {
+		return nil
+	}
+	raw, err := db.RedisClient.Get(ctx, redisAuditReportKey).Bytes()
+	if err != nil {
+		return nil
+	}
+	var report redisAuditReport
+	if json.Unmarshal(raw, &report) != nil {
+		return nil
+	}
+	return gin.H{
+		"ranAt":         report.RanAt,
+		"dbSize":        report.DBSize,
+		"usedMemory":    report.UsedMemory,
+		"memoryPercent": report.MemoryPercent,
+		"overThreshold": report.OverThreshold,
+		"noTtlKeys":     report.NoTTLTotal,
+		"enforced":      report.EnforcedTotal,
+	}
+}
+
+// GET /api/v1/admin/redis/audit — the last audit report
+func AdminGetRedisAudit(c *gin.Context) {
+	raw, err := db.RedisClient.Get(c.Request.Context(), redisAuditReportKey).Bytes()
+	if err == redis.Nil {
+		utils.RespondSuccess(c, http.StatusOK, "No Redis audit has run yet", nil)
+		return
+	}
+	if err != nil {
+		utils.RespondError(c, http.StatusServiceUnavailable, "Redis unavailable", err)
+		return
+	}
+	var report redisAuditReport
+	if err := json.Unmarshal(raw, &report); err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to read Redis audit", err)
+		return
+	}
+	utils.RespondSuccess(c, http.StatusOK, "Redis audit", report)
+}
+
+// POST /api/v1/admin/redis/audit — start an audit now (enforces TTLs like the scheduled run);
+// the report lands in GET /redis/audit once done
+func AdminRunRedisAudit(c *gin.Context) {
+	ok, err := db.RedisClient.SetNX(c.Request.Context(), redisAuditLockKey, "1", time.Minute).Result()
+	if err != nil {
+		utils.RespondError(c, http.StatusServiceUnavailable, "Redis unavailable", err)
+		return
+	}
+	if !ok {
+		utils.RespondError(c, http.StatusConflict, "A Redis audit ran or is running too recently; try again shortly", nil)
+		return
+	}
+	// Scans can outlive the request timeout on a large keyspace
+	utils.SafeGo(func() {
+		if _, err := runRedisAudit(context.Background()); err != nil {
+			utils.Logger.Error("Redis audit failed", zap.Error(err))
+		}
+	})
+	utils.RespondSuccess(c, http.StatusAccepted, "Redis audit started", nil)
+}
@@ -0,0 +1,445 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.uber.org/zap"
+	"ridewave/db"
+	"ridewave/events"
+	"ridewave/models"
+	"ridewave/utils"
+)
+
+// ══════════════════════════════════════════════════
+// Referrals — rider invite codes & first-ride rewards
+// ══════════════════════════════════════════════════
+//
+// Every rider gets a referral code. A new rider can apply someone's code before their first
+// completed ride; when that ride completes, both riders are issued a single-use flat promo code
+// that only they can redeem.
+
+const (
+	referralPending  = "pending"
+	referralRewarded = "rewarded"
+)
+
+// referralCodeAlphabet leaves out characters that are easy to misread (0/O, 1/I/L).
+const referralCodeAlphabet = "ABCDEFGHJKMNPQRSTUVWXYZ23456789"
+
+// referralConfig holds the reward amounts, all overridable via ENV.
+type referralConfig struct {
+	ReferrerReward float64 // REFERRAL_REFERRER_REWARD, default 100
+	RefereeReward  float64 // REFERRAL_REFEREE_REWARD, default 100
+	ValidDays      int     // REFERRAL_REWARD_VALID_DAYS, default 90
+}
+
+func loadReferralConfig() referralConfig {
+	cfg := referralConfig{ReferrerReward: 100, RefereeReward: 100, ValidDays: 90}
+	if val, err := strconv.ParseFloat(os.Getenv("REFERRAL_REFERRER_REWARD"), 64); err == nil && val >= 0 {
+		cfg.ReferrerReward = val
+	}
+	if val, err := strconv.ParseFloat(os.Getenv("REFERRAL_REFEREE_REWARD"), 64); err == nil && val >= 0 {
+		cfg.RefereeReward = val
+	}
+	if val, err := strconv.Atoi(os.Getenv("REFERRAL_REWARD_VALID_DAYS")); err == nil && val > 0 {
+		cfg.ValidDays = val
+	}
+	return cfg
+}
+
+func init() {
+	events.Subscribe(rewardReferralOnCompletion)
+}
+
+type referral struct {
+	ID              string     `json:"id"`
+	ReferrerID      string     `json:"referrerId"`
+	ReferrerName    string     `json:"referrerName"`
+	RefereeID       string     `json:"refereeId"`
+	RefereeName     string     `json:"refereeName"`
+	Code            string     `json:"code"`
+	Status          string     `json:"status"`
+	RideID          *string    `json:"rideId"`
+	ReferrerPromoID *string    `json:"referrerPromoId"`
+	RefereePromoID  *string    `json:"refereePromoId"`
+	RewardedAt      *time.Time `json:"rewardedAt"`
+	CreatedAt       time.Time  `json:"createdAt"`
+}
+
+const referralSelectCols = `rf.id, rf."referrerId", COALESCE(a.name, ''), rf."refereeId", COALESCE(b.name, ''), rf.code, rf.status,
+	rf."rideId", rf."referrerPromoId", rf."refereePromoId", rf."rewardedAt", rf."createdAt"`
+
+const referralFrom = ` FROM referrals rf LEFT JOIN "user" a ON a.id=rf."referrerId" LEFT JOIN "user" b ON b.id=rf."refereeId"`
+
+func scanReferral(scanner interface{ Scan(dest ...any) error }, r *referral) error {
+	return scanner.Scan(&r.ID, &r.ReferrerID, &r.ReferrerName, &r.RefereeID, &r.RefereeName, &r.Code, &r.Status,
+		&r.RideID, &r.ReferrerPromoID, &r.RefereePromoID, &r.RewardedAt, &r.CreatedAt)
+}
+
+// newReferralCode returns n random characters from referralCodeAlphabet.
+func newReferralCode(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	for i := range buf {
+		buf[i] = referralCodeAlphabet[int(buf[i])%len(referralCodeAlphabet)]
+	}
+	return string(buf), nil
+}
+
+// ensureReferralCode returns the rider's referral code, giving them one on first use.
+func ensureReferralCode(ctx context.Context, userID string) (string, error) {
+	for attempt := 0; attempt < 5; attempt++ {
+		candidate, err := newReferralCode(8)
+		if err != nil {
+			return "", err
+		}
+		var code string
+		err = db.Pool.QueryRow(ctx,
+			`UPDATE "user" SET "referralCode"=COALESCE("referralCode", $2) WHERE id=$1 RETURNING "referralCode"`,
+			userID, candidate).Scan(&code)
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			continue // someone already has this code
+		}
+		return code, err
+	}
+	return "", errors.New("could not generate a unique referral code")
+}
+
+// GET /api/v1/user/referral — the rider's code, rewards on offer and the friends they've invited
+func GetMyReferral(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+	ctx := c.Request.Context()
+
+	code, err := ensureReferralCode(ctx, user.ID)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to load referral code", err)
+		return
+	}
+
+	rows, err := db.Pool.Query(ctx,
+		`SELECT `+referralSelectCols+referralFrom+` WHERE rf."referrerId"=$1 ORDER BY rf."createdAt" DESC LIMIT 100`, user.ID)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to fetch referrals", err)
+		return
+	}
+	defer rows.Close()
+
+	type invite struct {
+		Name       string     `json:"name"`
+		Status     string     `json:"status"`
+		JoinedAt   time.Time  `json:"joinedAt"`
+		RewardedAt *time.Time `json:"rewardedAt"`
+	}
+	invites := []invite{}
+	for rows.Next() {
+		var r referral
+		if scanReferral(rows, &r) != nil {
+			continue
+		}
+		invites = append(invites, invite{Name: firstName(r.RefereeName), Status: r.Status, JoinedAt: r.CreatedAt, RewardedAt: r.RewardedAt})
+	}
+
+	var invited, rewarded int
+	db.Pool.QueryRow(ctx,
+		`SELECT COUNT(*), COUNT(*) FILTER (WHERE status=$2) FROM referrals WHERE "referrerId"=$1`, user.ID, referralRewarded).
+		Scan(&invited, &rewarded)
+	var referredBy *string
+	db.Pool.QueryRow(ctx, `SELECT code FROM referrals WHERE "refereeId"=$1`, user.ID).Scan(&referredBy)
+
+	cfg := loadReferralConfig()
+	utils.RespondSuccess(c, http.StatusOK, "Referral", gin.H{
+		"code":           code,
+		"referrerReward": cfg.ReferrerReward,
+		"refereeReward":  cfg.RefereeReward,
+		"invited":        invited,
+		"rewarded":       rewarded,
+		"invites":        invites,
+		"appliedCode":    referredBy,
+	})
+}
+
+// POST /api/v1/user/referral/apply — {code}; only before the rider's first completed ride
+func ApplyReferralCode(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+	ctx := c.Request.Context()
+	var body struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "A referral code is required", err)
+		return
+	}
+	code := strings.ToUpper(strings.TrimSpace(body.Code))
+
+	var referrerID string
+	err := db.Pool.QueryRow(ctx,
+		`SELECT id FROM "user" WHERE UPPER("referralCode")=$1 AND status<>'deleted'`, code).Scan(&referrerID)
+	if err != nil {
+		utils.RespondError(c, http.StatusNotFound, "Invalid referral code", nil)
+		return
+	}
+	if referrerID == user.ID {
+		utils.RespondError(c, http.StatusBadRequest, "You can't use your own referral code", nil)
+		return
+	}
+
+	var hasRidden, circular bool
+	db.Pool.QueryRow(ctx,
+		`SELECT EXISTS(SELECT 1 FROM rides WHERE "userId"=$1 AND status='Completed'),
+		 EXISTS(SELECT 1 FROM referrals WHERE "referrerId"=$1 AND "refereeId"=$2)`, user.ID, referrerID).Scan(&hasRidden, &circular)
+	if hasRidden {
+		utils.RespondError(c, http.StatusBadRequest, "Referral codes can only be applied before your first ride", nil)
+		return
+	}
+	if circular {
+		utils.RespondError(c, http.StatusBadRequest, "You referred this rider, so you can't use their code", nil)
+		return
+	}
+
+	var r referral
+	err = scanReferral(db.Pool.QueryRow(ctx,
+		`WITH rf AS (
+			INSERT INTO referrals ("referrerId", "refereeId", code) VALUES ($1, $2, $3)
+			ON CONFLICT ("refereeId") DO NOTHING RETURNING *)
+		 SELECT `+referralSelectCols+` FROM rf LEFT JOIN "user" a ON a.id=rf."referrerId" LEFT JOIN "user" b ON b.id=rf."refereeId"`,
+		referrerID, user.ID, code), &r)
+	if errors.Is(err, pgx.ErrNoRows) {
+		utils.RespondError(c, http.StatusConflict, "You've already applied a referral code", nil)
+		return
+	}
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to apply referral code", err)
+		return
+	}
+
+	utils.Logger.Info("Referral code applied", zap.String("referrerId", referrerID), zap.String("refereeId", user.ID))
+	utils.RespondSuccess(c, http.StatusCreated, "Referral code applied", gin.H{
+		"code":          r.Code,
+		"referrerName":  firstName(r.ReferrerName),
+		"status":        r.Status,
+		"refereeReward": loadReferralConfig().RefereeReward,
+	})
+}
+
+// rewardReferralOnCompletion rewards a pending referral once the referee completes a ride.
+func rewardReferralOnCompletion(e events.Event) {
+	if e.Type != events.RideCompleted {
+		return
+	}
+	utils.SafeGo(func() { issueReferralRewards(e.RideID) })
+}
+
+// issueReferralRewards gives the referrer and the referee their promo codes. The pending →
+// rewarded transition runs under a row lock, so a referral is only ever rewarded once.
+func issueReferralRewards(rideID string) {
+	ctx := db.Unscoped()
+	cfg := loadReferralConfig()
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		utils.Logger.Error("Failed to start referral reward", zap.String("rideId", rideID), zap.Error(err))
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	var referralID, referrerID, refereeID string
+	err = tx.QueryRow(ctx,
+		`SELECT rf.id, rf."referrerId", rf."refereeId" FROM referrals rf JOIN rides r ON r."userId"=rf."refereeId"
+		 WHERE r.id=$1 AND r.status='Completed' AND rf.status=$2 FOR UPDATE OF rf`, rideID, referralPending).
+		Scan(&referralID, &referrerID, &refereeID)
+	if err != nil {
+		return // no pending referral for this rider
+	}
+
+	referrerPromo, err := issueReferralPromo(ctx, tx, referrerID, cfg.ReferrerReward, cfg.ValidDays)
+	if err != nil {
+		utils.Logger.Error("Failed to issue referrer reward", zap.String("referralId", referralID), zap.Error(err))
+		return
+	}
+	refereePromo, err := issueReferralPromo(ctx, tx, refereeID, cfg.RefereeReward, cfg.ValidDays)
+	if err != nil {
+		utils.Logger.Error("Failed to issue referee reward", zap.String("referralId", referralID), zap.Error(err))
+		return
+	}
+
+	_, err = tx.Exec(ctx,
+		`UPDATE referrals SET status=$2, "rideId"=$3, "referrerPromoId"=$4, "refereePromoId"=$5, "rewardedAt"=NOW() WHERE id=$1`,
+		referralID, referralRewarded, rideID, promoID(referrerPromo), promoID(refereePromo))
+	if err != nil {
+		utils.Logger.Error("Failed to mark referral rewarded", zap.String("referralId", referralID), zap.Error(err))
+		return
+	}
+	err = queueNotifications(ctx, tx, append(
+		referralRewardNotifications(referrerID, rideID, referrerPromo, "Your friend took their first ride!"),
+		referralRewardNotifications(refereeID, rideID, refereePromo, "Thanks for riding with us!")...)...)
+	if err != nil {
+		utils.Logger.Error("Failed to queue referral reward notifications", zap.String("referralId", referralID), zap.Error(err))
+		return
+	}
+	if err := tx.Commit(ctx); err != nil {
+		utils.Logger.Error("Failed to commit referral reward", zap.String("referralId", referralID), zap.Error(err))
+		return
+	}
+
+	kickNotificationOutbox()
+	utils.Logger.Info("Referral rewarded", zap.String("referralId", referralID), zap.String("rideId", rideID))
+}
+
+// issueReferralPromo creates a single-use flat promo only userID can redeem, or nil for a zero reward.
+func issueReferralPromo(ctx context.Context, tx pgx.Tx, userID string, amount float64, validDays int) (*models.PromoCode, error) {
+	if amount <= 0 {
+		return nil, nil
+	}
+	suffix, err := newReferralCode(8)
+	if err != nil {
+		return nil, err
+	}
+	var pc models.PromoCode
+	err = scanPromoCode(tx.QueryRow(ctx,
+		`INSERT INTO promo_codes (code, "discountType", "discountValue", "usageLimit", "expiresAt", "userId")
+		 VALUES ($1, 'flat', $2, 1, NOW() + make_interval(days => $3), $4) RETURNING `+promoSelectCols,
+		"REF-"+suffix, amount, validDays, userID), &pc)
+	if err != nil {
+		return nil, err
+	}
+	return &pc, nil
+}
+
+func promoID(pc *models.PromoCode) *string {
+	if pc == nil {
+		return nil
+	}
+	return &pc.ID
+}
+
+// referralRewardNotifications is the push telling a rider about their reward code, if they got one.
+func referralRewardNotifications(userID, rideID string, pc *models.PromoCode, title string) []outboxNotification {
+	if pc == nil {
+		return nil
+	}
+	msg := fmt.Sprintf("Use code %s for %s off your next ride.", pc.Code, formatMoney("", pc.DiscountValue))
+	return []outboxNotification{outboxPush("user", userID, rideID, title, msg, utils.FCMData{"type": "referral_reward", "promoCode": pc.Code})}
+}
+
+// ══════════════════════════════════════════════════
+// Admin: Referral Reporting
+// ══════════════════════════════════════════════════
+
+// GET /api/v1/admin/referrals?status=&referrerId=&page=1&limit=20
+func AdminGetReferrals(c *gin.Context) {
+	pg, err := utils.ParsePagination(c)
+	if err != nil {
+		utils.RespondErrorCode(c, http.StatusBadRequest, utils.CodeInvalidCursor, "Invalid cursor", err)
+		return
+	}
+	conds := []string{`($1='' OR rf.status=$1)`, `($2='' OR rf."referrerId"=$2)`}
+	args := []interface{}{c.Query("status"), c.Query("referrerId")}
+
+	var total int
+	if !pg.UseCursor {
+		db.Pool.QueryRow(adminContext(c), `SELECT COUNT(*) FROM referrals rf`+utils.WhereClause(conds), args...).Scan(&total)
+	}
+
+	conds, args = pg.Keyset(conds, args, `rf."createdAt"`, "rf.id")
+	tail, args := pg.Tail(args, `rf."createdAt"`, "rf.id")
+	rows, err := db.Pool.Query(adminContext(c),
+		`SELECT `+referralSelectCols+referralFrom+utils.WhereClause(conds)+tail, args...)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to fetch referrals", err)
+		return
+	}
+	defer rows.Close()
+
+	referrals := []referral{}
+	for rows.Next() {
+		var r referral
+		if scanReferral(rows, &r) == nil {
+			referrals = append(referrals, r)
+		}
+	}
+
+	referrals, resp := utils.Paginate(pg, referrals, total, func(r referral) (time.Time, string) { return r.CreatedAt, r.ID })
+	resp["referrals"] = referrals
+	utils.RespondSuccess(c, http.StatusOK, "Referrals", resp)
+}
+
+// GET /api/v1/admin/referrals/summary?days=30 — conversion, rewards issued & redeemed, top referrers
+func AdminGetReferralSummary(c *gin.Context) {
+	days, _ := strconv.Atoi(c.DefaultQuery("days", "30"))
+	if days < 1 || days > 365 {
+		days = 30
+	}
+	ctx := adminContext(c)
+
+	var applied, rewarded int
+	db.Pool.QueryRow(ctx,
+		`SELECT COUNT(*), COUNT(*) FILTER (WHERE status=$2) FROM referrals WHERE "createdAt" >= NOW() - make_interval(days => $1)`,
+		days, referralRewarded).Scan(&applied, &rewarded)
+	var conversion *float64
+	if applied > 0 {
+		pct := math.Round(float64(rewarded)/float64(applied)*1000) / 10
+		conversion = &pct
+	}
+
+	// Rewards issued in the period, and how many have been spent so far
+	var issued, redeemed int
+	var issuedValue, redeemedValue float64
+	db.Pool.QueryRow(ctx,
+		`SELECT COUNT(*), COALESCE(SUM(pc."discountValue"), 0)
+		 FROM referrals rf JOIN promo_codes pc ON pc.id IN (rf."referrerPromoId", rf."refereePromoId")
+		 WHERE rf."rewardedAt" >= NOW() - make_interval(days => $1)`, days).Scan(&issued, &issuedValue)
+	db.Pool.QueryRow(ctx,
+		`SELECT COUNT(*), COALESCE(SUM(r.discount), 0)
+		 FROM referrals rf JOIN promo_codes pc ON pc.id IN (rf."referrerPromoId", rf."refereePromoId")
+		 JOIN rides r ON r."promoCode"=pc.code AND r.status<>'Cancelled'
+		 WHERE rf."rewardedAt" >= NOW() - make_interval(days => $1)`, days).Scan(&redeemed, &redeemedValue)
+
+	type topReferrer struct {
+		UserID   string `json:"userId"`
+		Name     string `json:"name"`
+		Invited  int    `json:"invited"`
+		Rewarded int    `json:"rewarded"`
+	}
+	top := []topReferrer{}
+	rows, err := db.Pool.Query(ctx,
+		`SELECT rf."referrerId", COALESCE(u.name, ''), COUNT(*), COUNT(*) FILTER (WHERE rf.status=$2)
+		 FROM referrals rf LEFT JOIN "user" u ON u.id=rf."referrerId"
+		 WHERE rf."createdAt" >= NOW() - make_interval(days => $1)
+		 GROUP BY 1, 2 ORDER BY 4 DESC, 3 DESC LIMIT 10`, days, referralRewarded)
+	if err == nil {
+		defer rows.Close()
+		for rows.Next() {
+			var t topReferrer
+			if rows.Scan(&t.UserID, &t.Name, &t.Invited, &t.Rewarded) == nil {
+				top = append(top, t)
+			}
+		}
+	}
+
+	utils.RespondSuccess(c, http.StatusOK, "Referral summary", gin.H{
+		"days":              days,
+		"applied":           applied,
+		"rewarded":          rewarded,
+		"conversionPercent": conversion,
+		"rewardsIssued":     issued,
+		"rewardsValue":      round2(issuedValue),
+		"rewardsRedeemed":   redeemed,
+		"redeemedDiscount":  round2(redeemedValue),
+		"topReferrers":      top,
+	})
+}
@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"ridewave/db"
+	"ridewave/models"
+	"ridewave/utils"
+)
+
+// ══════════════════════════════════════════════════
+// Refunds
+// ══════════════════════════════════════════════════
+
+const refundSelectCols = `id, "rideId", "paymentId", amount, COALESCE(reason, ''), status, COALESCE(reference, ''), "processedAt", "createdAt"`
+
+func scanRefund(scanner interface{ Scan(dest ...any) error }, r *models.Refund) error {
+	return scanner.Scan(&r.ID, &r.RideID, &r.PaymentID, &r.Amount, &r.Reason, &r.Status, &r.Reference, &r.ProcessedAt, &r.CreatedAt)
+}
+
+// listRideRefunds returns a ride's refunds and the total that hasn't failed.
+func listRideRefunds(ctx context.Context, rideID string) ([]models.Refund, float64) {
+	refunds := []models.Refund{}
+	var refunded float64
+
+	rows, err := db.Pool.Query(ctx,
+		`SELECT `+refundSelectCols+` FROM refunds WHERE "rideId"=$1 ORDER BY "createdAt" ASC`, rideID)
+	if err != nil {
+		return refunds, 0
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r models.Refund
+		if scanRefund(rows, &r) != nil {
+			continue
+		}
+		if r.Status != "failed" {
+			refunded += r.Amount
+		}
+		refunds = append(refunds, r)
+	}
+	return refunds, refunded
+}
+
+// POST /api/v1/admin/ride/:id/refund
+func AdminIssueRefund(c *gin.Context) {
+	rideID := c.Param("id")
+	var body struct {
+		Amount float64 `json:"amount"` // omit for a full refund of the remaining balance
+		Reason string  `json:"reason" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid request", err)
+		return
+	}
+	if body.Amount < 0 {
+		utils.RespondError(c, http.StatusBadRequest, "Refund amount must be positive", nil)
+		return
+	}
+
+	ctx := adminContext(c)
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to issue refund", err)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	// Lock the payment so concurrent refunds can't exceed what was paid
+	var paymentID, currency string
+	var paid float64
+	err = tx.QueryRow(ctx,
+		`SELECT id, amount, currency FROM payments WHERE "rideId"=$1 AND status='paid' FOR UPDATE`, rideID).Scan(&paymentID, &paid, &currency)
+	if errors.Is(err, pgx.ErrNoRows) {
+		utils.RespondError(c, http.StatusNotFound, "No settled payment found for this ride", nil)
+		return
+	}
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to issue refund", err)
+		return
+	}
+
+	var alreadyRefunded float64
+	tx.QueryRow(ctx,
+		`SELECT COALESCE(SUM(amount), 0) FROM refunds WHERE "paymentId"=$1 AND status<>'failed'`, paymentID).Scan(&alreadyRefunded)
+
+	remaining := math.Round((paid-alreadyRefunded)*100) / 100
+	if body.Amount == 0 {
+		body.Amount = remaining
+	}
+	if body.Amount <= 0 || body.Amount > remaining {
+		utils.RespondError(c, http.StatusBadRequest,
+			"Refund exceeds refundable balance of "+formatMoney(currency, remaining), nil)
+		return
+	}
+
+	var refund models.Refund
+	err = scanRefund(tx.QueryRow(ctx,
+		`INSERT INTO refunds ("rideId", "paymentId", amount, reason)
+		 VALUES ($1, $2, $3, $4) RETURNING `+refundSelectCols,
+		rideID, paymentID, body.Amount, body.Reason), &refund)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to issue refund", err)
+		return
+	}
+	if err := tx.Commit(ctx); err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to issue refund", err)
+		return
+	}
+
+	utils.RespondSuccess(c, http.StatusCreated, "Refund issued", gin.H{
+		"refund":     refund,
+		"refundable": remaining - body.Amount,
+	})
+}
+
+// PUT /api/v1/admin/refund/:id/status
+func AdminUpdateRefundStatus(c *gin.Context) {
+	var body struct {
+		Status    string `json:"status" binding:"required"` // processed | failed
+		Reference string `json:"reference"`                 // gateway / bank reference
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid request", err)
+		return
+	}
+	if body.Status != "processed" && body.Status != "failed" {
+		utils.RespondError(c, http.StatusBadRequest, "Status must be 'processed' or 'failed'", nil)
+		return
+	}
+
+	var refund models.Refund
+	err := scanRefund(db.Pool.QueryRow(adminContext(c),
+		`UPDATE refunds SET status=$1, reference=COALESCE(NULLIF($2, ''), reference), "processedAt"=NOW()
+		 WHERE id=$3 AND status='pending' RETURNING `+refundSelectCols,
+		body.Status, body.Reference, c.Param("id")), &refund)
+	if err != nil {
+		utils.RespondError(c, http.StatusNotFound, "Pending refund not found", err)
+		return
+	}
+	utils.RespondSuccess(c, http.StatusOK, "Refund updated", gin.H{"refund": refund})
+}
+
+// GET /api/v1/admin/refunds?page=1&limit=20&status=pending
+func AdminGetRefunds(c *gin.Context) {
+	pg, err := utils.ParsePagination(c)
+	if err != nil {
+		utils.RespondErrorCode(c, http.StatusBadRequest, utils.CodeInvalidCursor, "Invalid cursor", err)
+		return
+	}
+	statusFilter := c.Query("status")
+
+	conds := []string{"($1='' OR status=$1)"}
+	args := []interface{}{statusFilter}
+
+	var total int
+	var totalAmount float64
+	if !pg.UseCursor {
+		db.Pool.QueryRow(adminContext(c),
+			`SELECT COUNT(*), COALESCE(SUM(amount), 0) FROM refunds`+utils.WhereClause(conds), args...).Scan(&total, &totalAmount)
+	}
+
+	conds, args = pg.Keyset(conds, args, `"createdAt"`, "id")
+	tail, args := pg.Tail(args, `"createdAt"`, "id")
+	rows, err := db.Pool.Query(adminContext(c),
+		`SELECT `+refundSelectCols+` FROM refunds`+utils.WhereClause(conds)+tail, args...)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to fetch refunds", err)
+		return
+	}
+	defer rows.Close()
+
+	var refunds []models.Refund
+	for rows.Next() {
+		var r models.Refund
+		scanRefund(rows, &r)
+		refunds = append(refunds, r)
+	}
+	if refunds == nil {
+		refunds = []models.Refund{}
+	}
+
+	refunds, resp := utils.Paginate(pg, refunds, total, func(r models.Refund) (time.Time, string) { return r.CreatedAt, r.ID })
+	resp["refunds"] = refunds
+	if !pg.UseCursor {
+		resp["totalAmount"] = totalAmount
+	}
+	utils.RespondSuccess(c, http.StatusOK, "Refunds", resp)
+}
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"ridewave/db"
+	"ridewave/utils"
+)
+
+// ══════════════════════════════════════════════════
+// Data Residency — region routing & cross-region admin stats
+// ══════════════════════════════════════════════════
+
+const regionFanoutTimeout = 5 * time.Second
+
+// regionStats holds the aggregate, PII-free figures a region shares with the others.
+type regionStats struct {
+	Region         string  `json:"region"`
+	TotalUsers     int     `json:"totalUsers"`
+	TotalDrivers   int     `json:"totalDrivers"`
+	ActiveDrivers  int     `json:"activeDrivers"`
+	TotalRides     int     `json:"totalRides"`
+	CompletedRides int     `json:"completedRides"`
+	OngoingRides   int     `json:"ongoingRides"`
+	TotalRevenue   float64 `json:"totalRevenue"`
+	TodayRides     int     `json:"todayRides"`
+	TodayRevenue   float64 `json:"todayRevenue"`
+}
+
+func localRegionStats(ctx context.Context) regionStats {
+	s := regionStats{Region: db.Region()}
+	db.Pool.QueryRow(ctx, `SELECT COUNT(*) FROM "user"`).Scan(&s.TotalUsers)
+	db.Pool.QueryRow(ctx, `SELECT COUNT(*), COUNT(*) FILTER (WHERE status='active') FROM driver`).Scan(&s.TotalDrivers, &s.ActiveDrivers)
+	db.Pool.QueryRow(ctx,
+		`SELECT COUNT(*), COUNT(*) FILTER (WHERE status='Completed'),
+		 COUNT(*) FILTER (WHERE status IN ('Accepted','Arriving','InProgress')),
+		 COALESCE(SUM(charge) FILTER (WHERE status='Completed'), 0),
+		 COUNT(*) FILTER (WHERE DATE("createdAt")=CURRENT_DATE),
+		 COALESCE(SUM(charge) FILTER (WHERE status='Completed' AND DATE("createdAt")=CURRENT_DATE), 0)
+		 FROM rides`).
+		Scan(&s.TotalRides, &s.CompletedRides, &s.OngoingRides, &s.TotalRevenue, &s.TodayRides, &s.TodayRevenue)
+	return s
+}
+
+// redirectToHomeRegion answers 421 with the right endpoint when a phone number belongs to
+// another region, so sign-ups never create records outside their home region.
+func redirectToHomeRegion(c *gin.Context, phone string) bool {
+	home := utils.RegionForPhone(phone)
+	if home == "" || home == db.Region() {
+		return false
+	}
+	c.JSON(http.StatusMisdirectedRequest, gin.H{
+		"success":  false,
+		"code":     utils.CodeWrongRegion,
+		"message":  "This account is served from another region",
+		"region":   home,
+		"endpoint": utils.RegionEndpoints()[home],
+	})
+	return true
+}
+
+// fetchRegionStats pulls the internal stats of another region's deployment.
+func fetchRegionStats(ctx context.Context, endpoint string) (regionStats, error) {
+	var out struct {
+		Data regionStats `json:"data"`
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"/api/v1/internal/region-stats", nil)
+	if err != nil {
+		return out.Data, err
+	}
+	req.Header.Set("X-Federation-Secret", os.Getenv("REGION_FEDERATION_SECRET"))
+	req.Header.Set("x-api-key", os.Getenv("API_KEY"))
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return out.Data, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return out.Data, &regionStatusError{code: resp.StatusCode}
+	}
+	err = json.NewDecoder(resp.Body).Decode(&out)
+	return out.Data, err
+}
+
+type regionStatusError struct{ code int }
+
+func (e *regionStatusError) Error() string { return "region responded " + http.StatusText(e.code) }
+
+// GET /api/v1/public/regions
+func GetRegions(c *gin.Context) {
+	endpoints := utils.RegionEndpoints()
+	regions := []gin.H{}
+	for region, endpoint := range endpoints {
+		regions = append(regions, gin.H{"region": region, "endpoint": endpoint})
+	}
+	sort.Slice(regions, func(i, j int) bool { return regions[i]["region"].(string) < regions[j]["region"].(string) })
+	utils.RespondSuccess(c, http.StatusOK, "Regions", gin.H{"current": db.Region(), "regions": regions})
+}
+
+// GET /api/v1/internal/region-stats — aggregate counts only, called by peer regions
+func InternalRegionStats(c *gin.Context) {
+	// Peer regions ask for the whole region, not one tenant
+	utils.RespondSuccess(c, http.StatusOK, "Region stats", localRegionStats(db.WithoutTenant(c.Request.Context())))
+}
+
+// GET /api/v1/admin/regions/summary
+func AdminRegionSummary(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(adminContext(c), regionFanoutTimeout)
+	defer cancel()
+
+	local := db.Region()
+	regions := []gin.H{}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var totals regionStats
+	add := func(s regionStats) {
+		totals.TotalUsers += s.TotalUsers
+		totals.TotalDrivers += s.TotalDrivers
+		totals.ActiveDrivers += s.ActiveDrivers
+		totals.TotalRides += s.TotalRides
+		totals.CompletedRides += s.CompletedRides
+		totals.OngoingRides += s.OngoingRides
+		totals.TotalRevenue += s.TotalRevenue
+		totals.TodayRides += s.TodayRides
+		totals.TodayRevenue += s.TodayRevenue
+	}
+
+	for region, endpoint := range utils.RegionEndpoints() {
+		if region == local {
+			continue
+		}
+		wg.Add(1)
+		go func(region, endpoint string) {
+			defer wg.Done()
+			stats, err := fetchRegionStats(ctx, endpoint)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				regions = append(regions, gin.H{"region": region, "reachable": false, "error": err.Error()})
+				return
+			}
+			stats.Region = region
+			add(stats)
+			regions = append(regions, gin.H{"region": region, "reachable": true, "stats": stats})
+		}(region, endpoint)
+	}
+
+	stats := localRegionStats(adminContext(c))
+	mu.Lock()
+	add(stats)
+	regions = append(regions, gin.H{"region": local, "reachable": true, "stats": stats})
+	mu.Unlock()
+	wg.Wait()
+
+	sort.Slice(regions, func(i, j int) bool { return regions[i]["region"].(string) < regions[j]["region"].(string) })
+	totals.Region = "all"
+	utils.RespondSuccess(c, http.StatusOK, "Region summary", gin.H{"regions": regions, "totals": totals})
+}
+
+// RegisterInternalRoutes exposes the region-to-region endpoints behind the federation secret.
+func RegisterInternalRoutes(r gin.IRouter, federationMiddleware gin.HandlerFunc) {
+	internalGroup := r.Group("/internal")
+	internalGroup.Use(federationMiddleware)
+	{
+		internalGroup.GET("/region-stats", InternalRegionStats)
+	}
+}
@@ -0,0 +1,12 @@
+package handlers
+
+import "ridewave/repository"
+
+// repos is the data-access layer handlers are moving onto. main wires in the Postgres
+// implementations from the app container; repository/mock provides in-memory ones.
+var repos repository.Repos
+
+// UseRepositories sets the repositories handlers read and write through.
+func UseRepositories(r repository.Repos) {
+	repos = r
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"ridewave/models"
+	"ridewave/repository"
+	"ridewave/repository/mock"
+	"ridewave/utils"
+)
+
+func TestMain(m *testing.M) {
+	utils.Logger = zap.NewNop()
+	gin.SetMode(gin.TestMode)
+	os.Exit(m.Run())
+}
+
+// useMockRepos points handlers at fresh in-memory repositories for the rest of the test.
+func useMockRepos(t *testing.T) repository.Repos {
+	t.Helper()
+	prev := repos
+	r := mock.New()
+	UseRepositories(r)
+	t.Cleanup(func() { UseRepositories(prev) })
+	return r
+}
+
+type testResponse struct {
+	Status  int
+	Success bool            `json:"success"`
+	Message string          `json:"message"`
+	Code    string          `json:"code"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// decode unmarshals the response's data into v.
+func (r testResponse) decode(t *testing.T, v any) {
+	t.Helper()
+	if err := json.Unmarshal(r.Data, v); err != nil {
+		t.Fatalf("decoding data %s: %v", r.Data, err)
+	}
+}
+
+// serve runs handler on one request made by actor, a *models.User or *models.Driver as the
+// auth middleware would set it, with the route's path params.
+func serve(t *testing.T, handler gin.HandlerFunc, method, target string, body any, actor any, params ...gin.Param) testResponse {
+	t.Helper()
+	var reader io.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = bytes.NewReader(raw)
+	}
+
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+	c.Request = httptest.NewRequest(method, target, reader)
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Params = params
+	switch a := actor.(type) {
+	case *models.User:
+		c.Set("user", a)
+	case *models.Driver:
+		c.Set("driver", a)
+	}
+	handler(c)
+
+	resp := testResponse{Status: rec.Code}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decoding response %q: %v", rec.Body.String(), err)
+	}
+	return resp
+}
+
+func strPtr(s string) *string { return &s }
+
+func floatPtr(f float64) *float64 { return &f }
@@ -0,0 +1,228 @@
+package handlers
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"ridewave/db"
+	"ridewave/models"
+	"ridewave/utils"
+)
+
+// ══════════════════════════════════════════════════
+// Reviews — rating, comment & tags riders leave for drivers
+// ══════════════════════════════════════════════════
+
+type rideReview struct {
+	RideID       string     `json:"rideId"`
+	DriverID     string     `json:"driverId"`
+	Rating       float64    `json:"rating"`
+	Comment      string     `json:"comment"`
+	Tags         []string   `json:"tags"`
+	ReviewerName string     `json:"reviewerName"` // first name only outside the admin panel
+	IsHidden     bool       `json:"isHidden"`
+	HiddenReason *string    `json:"hiddenReason,omitempty"`
+	HiddenBy     *string    `json:"hiddenBy,omitempty"`
+	HiddenAt     *time.Time `json:"hiddenAt,omitempty"`
+	CreatedAt    time.Time  `json:"createdAt"`
+}
+
+const rideReviewSelectCols = `rr."rideId", rr."driverId", rr.rating, rr.comment, rr.tags, COALESCE(u.name, ''),
+	rr."isHidden", rr."hiddenReason", rr."hiddenBy", rr."hiddenAt", rr."createdAt"`
+
+const rideReviewFrom = ` FROM ride_reviews rr LEFT JOIN "user" u ON u.id=rr."userId"`
+
+func scanRideReview(scanner interface{ Scan(dest ...any) error }, r *rideReview) error {
+	return scanner.Scan(&r.RideID, &r.DriverID, &r.Rating, &r.Comment, &r.Tags, &r.ReviewerName,
+		&r.IsHidden, &r.HiddenReason, &r.HiddenBy, &r.HiddenAt, &r.CreatedAt)
+}
+
+// saveRideReview stores the rider's review of their ride's driver; rating again replaces it.
+// The driver comes from the ride, not the request.
+func saveRideReview(ctx context.Context, rideID, userID string, rating float64, comment string, tags []string) {
+	if tags == nil {
+		tags = []string{}
+	}
+	_, err := db.Pool.Exec(ctx,
+		`INSERT INTO ride_reviews ("rideId", "driverId", "userId", rating, comment, tags)
+		 SELECT id, "driverId", "userId", $3, $4, $5 FROM rides WHERE id=$1 AND "userId"=$2 AND "driverId" IS NOT NULL
+		 ON CONFLICT ("rideId") DO UPDATE SET rating=EXCLUDED.rating, comment=EXCLUDED.comment, tags=EXCLUDED.tags, "updatedAt"=NOW()`,
+		rideID, userID, rating, strings.TrimSpace(comment), tags)
+	if err != nil {
+		utils.Logger.Error("Failed to save ride review", zap.String("rideId", rideID), zap.Error(err))
+	}
+}
+
+func firstName(name string) string {
+	name = strings.TrimSpace(name)
+	if i := strings.IndexByte(name, ' '); i > 0 {
+		return name[:i]
+	}
+	return name
+}
+
+// respondDriverReviews lists a driver's visible reviews, newest first, with a summary.
+func respondDriverReviews(c *gin.Context, driverID string) {
+	ctx := c.Request.Context()
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if limit < 1 || limit > 50 {
+		limit = 20
+	}
+
+	rows, err := db.Pool.Query(ctx,
+		`SELECT `+rideReviewSelectCols+rideReviewFrom+`
+		 WHERE rr."driverId"=$1 AND NOT rr."isHidden" ORDER BY rr."createdAt" DESC LIMIT $2`, driverID, limit)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to fetch reviews", err)
+		return
+	}
+	defer rows.Close()
+
+	reviews := []rideReview{}
+	for rows.Next() {
+		var r rideReview
+		if scanRideReview(rows, &r) != nil {
+			continue
+		}
+		r.ReviewerName = firstName(r.ReviewerName)
+		reviews = append(reviews, r)
+	}
+
+	var count int
+	var average float64
+	db.Pool.QueryRow(ctx,
+		`SELECT COUNT(*), COALESCE(AVG(rating), 0) FROM ride_reviews WHERE "driverId"=$1 AND NOT "isHidden"`, driverID).
+		Scan(&count, &average)
+
+	// Most mentioned tags, for a "riders say" summary
+	type tagCount struct {
+		Tag   string `json:"tag"`
+		Count int    `json:"count"`
+	}
+	topTags := []tagCount{}
+	tagRows, err := db.Pool.Query(ctx,
+		`SELECT tag, COUNT(*) FROM ride_reviews, unnest(tags) tag
+		 WHERE "driverId"=$1 AND NOT "isHidden" GROUP BY tag ORDER BY COUNT(*) DESC LIMIT 5`, driverID)
+	if err == nil {
+		defer tagRows.Close()
+		for tagRows.Next() {
+			var t tagCount
+			if tagRows.Scan(&t.Tag, &t.Count) == nil {
+				topTags = append(topTags, t)
+			}
+		}
+	}
+
+	utils.RespondSuccess(c, http.StatusOK, "Driver reviews", gin.H{
+		"reviews": reviews,
+		"summary": gin.H{
+			"count":         count,
+			"averageRating": math.Round(average*100) / 100,
+			"topTags":       topTags,
+		},
+	})
+}
+
+// GET /api/v1/user/driver/:id/reviews?limit=20
+func GetDriverReviews(c *gin.Context) {
+	respondDriverReviews(c, c.Param("id"))
+}
+
+// GET /api/v1/driver/reviews?limit=20
+func GetMyReviews(c *gin.Context) {
+	respondDriverReviews(c, c.MustGet("driver").(*models.Driver).ID)
+}
+
+// ══════════════════════════════════════════════════
+// Admin: Review Moderation
+// ══════════════════════════════════════════════════
+
+// GET /api/v1/admin/reviews?driverId=&hidden=true|false&maxRating=&page=1&limit=50
+func AdminGetReviews(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 200 {
+		limit = 50
+	}
+
+	where := []string{"TRUE"}
+	args := []any{}
+	if driverID := c.Query("driverId"); driverID != "" {
+		args = append(args, driverID)
+		where = append(where, `rr."driverId"=$`+strconv.Itoa(len(args)))
+	}
+	if hidden := c.Query("hidden"); hidden != "" {
+		args = append(args, hidden == "true")
+		where = append(where, `rr."isHidden"=$`+strconv.Itoa(len(args)))
+	}
+	if maxRating, err := strconv.ParseFloat(c.Query("maxRating"), 64); err == nil {
+		args = append(args, maxRating)
+		where = append(where, `rr.rating<=$`+strconv.Itoa(len(args)))
+	}
+	if c.Query("withComment") == "true" {
+		where = append(where, `rr.comment<>''`)
+	}
+	args = append(args, limit, (page-1)*limit)
+
+	rows, err := db.Pool.Query(adminContext(c),
+		`SELECT `+rideReviewSelectCols+rideReviewFrom+` WHERE `+strings.Join(where, " AND ")+`
+		 ORDER BY rr."createdAt" DESC LIMIT $`+strconv.Itoa(len(args)-1)+` OFFSET $`+strconv.Itoa(len(args)), args...)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to fetch reviews", err)
+		return
+	}
+	defer rows.Close()
+
+	reviews := []rideReview{}
+	for rows.Next() {
+		var r rideReview
+		if scanRideReview(rows, &r) == nil {
+			reviews = append(reviews, r)
+		}
+	}
+	utils.RespondSuccess(c, http.StatusOK, "Reviews", gin.H{"reviews": reviews, "page": page, "limit": limit})
+}
+
+// PUT /api/v1/admin/review/:rideId/visibility — hide an abusive review, or restore it
+// The star rating still counts toward the driver's average; only the review is hidden.
+func AdminSetReviewVisibility(c *gin.Context) {
+	var body struct {
+		Hidden bool   `json:"hidden"`
+		Reason string `json:"reason"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid request", err)
+		return
+	}
+	if body.Hidden && strings.TrimSpace(body.Reason) == "" {
+		utils.RespondError(c, http.StatusBadRequest, "A reason is required to hide a review", nil)
+		return
+	}
+	admin := c.MustGet("admin").(*models.AdminAccount)
+
+	var r rideReview
+	err := scanRideReview(db.Pool.QueryRow(adminContext(c),
+		`WITH rr AS (
+			UPDATE ride_reviews SET "isHidden"=$2, "hiddenReason"=CASE WHEN $2 THEN $3 END,
+			 "hiddenBy"=CASE WHEN $2 THEN $4 END, "hiddenAt"=CASE WHEN $2 THEN NOW() END, "updatedAt"=NOW()
+			WHERE "rideId"=$1 RETURNING *)
+		 SELECT `+rideReviewSelectCols+` FROM rr LEFT JOIN "user" u ON u.id=rr."userId"`,
+		c.Param("rideId"), body.Hidden, strings.TrimSpace(body.Reason), admin.Email), &r)
+	if err != nil {
+		utils.RespondError(c, http.StatusNotFound, "Review not found", err)
+		return
+	}
+
+	utils.Logger.Info("Review visibility changed", zap.String("rideId", r.RideID), zap.Bool("hidden", r.IsHidden),
+		zap.String("admin", admin.Email))
+	utils.RespondSuccess(c, http.StatusOK, "Review updated", gin.H{"review": r})
+}
@@ -4,103 +4,126 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"maps"
 	"math"
 	"net/http"
 	"os"
 	"ridewave/db"
+	"ridewave/events"
 	"ridewave/models"
+	"ridewave/repository"
+	"ridewave/rides/fares"
+	"ridewave/rides/statemachine"
 	"ridewave/stores"
 	"ridewave/utils"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
 	"github.com/skip2/go-qrcode"
 	"go.uber.org/zap"
 )
 
-var serviceZones []models.ServiceZone
-
-func init() {
-	// Parse authorized zones from ENV (Format: Name:Lat:Lng:RadiusKM;...)
-	zonesEnv := os.Getenv("SERVICE_ZONES")
-	if zonesEnv == "" {
-		zonesEnv = "New Delhi:28.6139:77.2090:50;Chennai:13.0827:80.2707:50;Bengaluru:12.9716:77.5946:50;Madurai:9.9252:78.1198:50"
-	}
+// fareBreakdown is how a fare is made up before rounding and any promo discount.
+type fareBreakdown struct {
+	BaseFare     float64
+	DistanceFare float64
+	TimeFare     float64
+	PlatformFee  float64
+}
 
-	for _, zone := range strings.Split(zonesEnv, ";") {
-		parts := strings.Split(zone, ":")
-		if len(parts) < 4 {
-			continue
-		}
-		name := parts[0]
-		zLat, _ := strconv.ParseFloat(parts[1], 64)
-		zLng, _ := strconv.ParseFloat(parts[2], 64)
-		radius, _ := strconv.ParseFloat(parts[3], 64)
-
-		serviceZones = append(serviceZones, models.ServiceZone{
-			Name:   name,
-			Lat:    zLat,
-			Lng:    zLng,
-			Radius: radius,
-		})
-	}
+func (b fareBreakdown) Total() float64 {
+	return b.BaseFare + b.DistanceFare + b.TimeFare + b.PlatformFee
 }
 
-// CalculateFare fetches fare rates from the vehicle_types table and calculates the estimated fare.
+// CalculateFare fetches fare rates from the vehicle_types table and calculates the estimated fare,
+// rounded the way the pickup's currency says (see currencyAt).
 // Falls back to default rates if the vehicle type is not found in the database.
-func CalculateFare(vehicleType string, distanceMeters int, durationSeconds int) float64 {
-	var baseFare, perKmRate, perMinRate float64
+// Rates are per tenant, so ctx should carry the rider's tenant.
+func CalculateFare(ctx context.Context, currency fares.Currency, vehicleType string, distanceMeters int, durationSeconds int) float64 {
+	return currency.Round(calculateFareBreakdown(ctx, vehicleType, distanceMeters, durationSeconds).Total())
+}
 
-	err := db.Pool.QueryRow(context.Background(),
-		`SELECT "baseFare", "perKmRate", "perMinRate" FROM vehicle_types WHERE name=$1 AND "isActive"=TRUE`,
-		vehicleType).Scan(&baseFare, &perKmRate, &perMinRate)
+// calculateFareBreakdown prices a trip component by component; CalculateFare rounds its total.
+func calculateFareBreakdown(ctx context.Context, vehicleType string, distanceMeters int, durationSeconds int) fareBreakdown {
+	vt := findVehicleType(ctx, vehicleType)
+	if vt == nil || !vt.IsActive {
+		// Fallback defaults if the lookup fails
+		return fareBreakdownAt(50.0, 12.0, 2.0, nil, distanceMeters, durationSeconds)
+	}
+	return fareBreakdownAt(vt.BaseFare, vt.PerKmRate, vt.PerMinRate, vt.CommissionPercent, distanceMeters, durationSeconds)
+}
 
-	if err != nil {
-		// Fallback defaults if DB lookup fails
-		baseFare = 50.0
-		perKmRate = 12.0
-		perMinRate = 2.0
+// fareBreakdownAt prices a trip at the given rates; a nil commission means PLATFORM_FEE_PERCENTAGE.
+func fareBreakdownAt(baseFare, perKmRate, perMinRate float64, commission *float64, distanceMeters int, durationSeconds int) fareBreakdown {
+	feePercent := platformFeePercent()
+	if commission != nil {
+		feePercent = *commission
 	}
 
 	distanceKm := float64(distanceMeters) / 1000.0
 	durationMin := float64(durationSeconds) / 60.0
 
 	// Core Ride Cost
-	rideCost := baseFare + (distanceKm * perKmRate) + (durationMin * perMinRate)
+	b := fareBreakdown{BaseFare: baseFare, DistanceFare: distanceKm * perKmRate, TimeFare: durationMin * perMinRate}
+
+	// Platform Fee (Commission) - the vehicle type's, else PLATFORM_FEE_PERCENTAGE
+	b.PlatformFee = (b.BaseFare + b.DistanceFare + b.TimeFare) * (feePercent / 100.0)
+	return b
+}
 
-	// Platform Fee (Commission) - Configurable via ENV
-	feePercentStr := os.Getenv("PLATFORM_FEE_PERCENTAGE")
+// platformFeePercent returns the default commission the platform adds on top of the ride cost.
+// Vehicle types and drivers can override it (see commissionPercentFor).
+func platformFeePercent() float64 {
 	feePercent := 15.0 // Default 15%
-	if val, err := strconv.ParseFloat(feePercentStr, 64); err == nil {
+	if val, err := strconv.ParseFloat(os.Getenv("PLATFORM_FEE_PERCENTAGE"), 64); err == nil {
 		feePercent = val
 	}
-	
-	platformFee := rideCost * (feePercent / 100.0)
-	
-	// Total Fare
-	totalFare := rideCost + platformFee
+	return feePercent
+}
+
+// platformCommission splits the platform's share back out of a final fare at the default rate.
+func platformCommission(charge float64) float64 {
+	return commissionAt(charge, platformFeePercent())
+}
 
-	return math.Ceil(totalFare)
+// commissionAt splits a percent commission, charged on top of the ride cost, back out of a fare.
+func commissionAt(charge, percent float64) float64 {
+	return math.Round((charge-charge/(1+percent/100.0))*100) / 100
 }
 
-// GET /api/v1/user/vehicle-types & /api/v1/driver/vehicle-types
+// GET /api/v1/user/vehicle-types & /api/v1/driver/vehicle-types?lat=...&lng=...
+// With a location, zone restrictions are evaluated too; otherwise only time windows.
 func GetVehicleTypes(c *gin.Context) {
-	rows, err := db.Pool.Query(context.Background(),
-		`SELECT id, name, "baseFare", "perKmRate", "perMinRate", COALESCE(icon, ''), "isActive", "createdAt", "updatedAt" 
-		 FROM vehicle_types WHERE "isActive"=TRUE ORDER BY "baseFare" ASC`)
+	cached, err := loadVehicleTypes(c.Request.Context())
 	if err != nil {
 		utils.RespondError(c, http.StatusInternalServerError, "Failed to fetch vehicle types", err)
 		return
 	}
-	defer rows.Close()
 
+	zone := ""
+	lat, errLat := strconv.ParseFloat(c.Query("lat"), 64)
+	lng, errLng := strconv.ParseFloat(c.Query("lng"), 64)
+	if errLat == nil && errLng == nil {
+		zone = zoneForPoint(lat, lng)
+	}
+
+	// Pool is a rider-side product served by regular cars, so drivers can't register with it
+	forDriver := strings.HasPrefix(utils.RoutePath(c), "/driver")
+
+	now := time.Now()
 	var types []models.VehicleTypeConfig
-	for rows.Next() {
-		var vt models.VehicleTypeConfig
-		rows.Scan(&vt.ID, &vt.Name, &vt.BaseFare, &vt.PerKmRate, &vt.PerMinRate, &vt.Icon, &vt.IsActive, &vt.CreatedAt, &vt.UpdatedAt)
+	for _, t := range cached {
+		vt := t.VehicleTypeConfig
+		if !vt.IsActive || (forDriver && vt.Name == poolVehicleType) {
+			continue
+		}
+		applyVehicleAvailability(&vt, zone, now)
 		types = append(types, vt)
 	}
 	if types == nil {
@@ -115,58 +138,147 @@ func GetRideEstimate(c *gin.Context) {
 		Origin      string `json:"origin"`      // "lat,lng"
 		Destination string `json:"destination"` // "lat,lng"
 		VehicleType string `json:"vehicleType"`
+		PromoCode   string `json:"promoCode"`
+		Stops       []rideStopInput `json:"stops"` // optional, in visiting order
 	}
 
 	if err := c.ShouldBindJSON(&body); err != nil {
 		utils.RespondError(c, http.StatusBadRequest, "Invalid request body", err)
 		return
 	}
+	stops, err := parseRideStops(body.Stops)
+	if err != nil {
+		utils.RespondError(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+	if len(stops) > 0 && body.VehicleType == poolVehicleType {
+		utils.RespondError(c, http.StatusBadRequest, "Pool rides can't have extra stops", nil)
+		return
+	}
+
+	pickupLat, pickupLng := utils.ParseLatLng(body.Origin)
+	if ok, reason := checkZoneAccess(c.Request.Context(), c.MustGet("user").(*models.User), pickupLat, pickupLng); !ok {
+		utils.RespondErrorCode(c, http.StatusForbidden, utils.CodeZoneNotServed, reason, nil)
+		return
+	}
+	// Without a vehicle type, price every type for the vehicle picker in one go
+	if body.VehicleType == "" {
+		respondAllVehicleEstimates(c, c.MustGet("user").(*models.User), body.Origin, body.Destination, stops, body.PromoCode, pickupLat, pickupLng)
+		return
+	}
+	if ok, reason := checkVehicleAvailability(c.Request.Context(), body.VehicleType, pickupLat, pickupLng); !ok {
+		utils.RespondErrorCode(c, http.StatusUnprocessableEntity, utils.CodeVehicleUnavailable, reason, nil)
+		return
+	}
+
+	routeID, cached, err := planRouteVia(c.Request.Context(), body.Origin, body.Destination, stops, body.VehicleType)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to calculate route", err)
+		return
+	}
+	recordRideEstimate(c.MustGet("user").(*models.User).ID, routeID, cached)
+
+	resp := gin.H{
+		"polyline":  cached.Polyline,
+		"distance":  fmt.Sprintf("%.2f km", float64(cached.Distance)/1000.0),
+		"duration":  fmt.Sprintf("%d mins", int(float64(cached.Duration)/60.0)),
+		"fare":      cached.Fare,
+		"currency":  currencyAt(pickupLat, pickupLng),
+		"routeId":   routeID,
+	}
+	surgeLevelName, surgeMultiplier := pickupSurge(c.Request.Context(), pickupLat, pickupLng)
+	resp["minFare"], resp["maxFare"] = fareRange(cached.Fare, surgeMultiplier)
+	resp["surge"] = gin.H{"level": surgeLevelName, "multiplier": surgeMultiplier}
+	co2, saved, isElectric := vehicleRideEmissions(c.Request.Context(), body.VehicleType, cached.Distance)
+	resp["co2Grams"] = co2
+	if isElectric {
+		resp["co2SavedGrams"] = saved
+	}
+	if len(stops) > 0 {
+		resp["stops"] = stops
+	}
+	nearby := nearbyDriversWithETA(c.Request.Context(), pickupLat, pickupLng)
+	resp["nearbyDrivers"] = nearby
+	if eta, ok := pickupETA(nearby, body.VehicleType); ok {
+		resp["pickupEtaSeconds"], resp["pickupEtaText"] = eta.Seconds, eta.Text
+	}
+
+	// In bidding zones the rider may name their own fare within these bounds instead
+	if cfg := loadBiddingConfig(); body.VehicleType != poolVehicleType && cfg.zoneAt(pickupLat, pickupLng) != "" {
+		minFare, maxFare := cfg.fareBounds(cached.Fare)
+		resp["bidding"] = gin.H{"minFare": minFare, "maxFare": maxFare, "timeoutSeconds": int(cfg.Timeout.Seconds())}
+	}
+
+	// A bad promo shouldn't block the estimate — surface why it didn't apply instead
+	if body.PromoCode != "" {
+		if promo, discount, err := validatePromo(c.Request.Context(), c.MustGet("user").(*models.User).ID, body.PromoCode, promoBooking(cached), cached.Fare); err != nil {
+			resp["promoError"] = err.Error()
+		} else {
+			resp["promoCode"] = promo.Code
+			resp["discount"] = discount
+			resp["finalFare"] = cached.Fare - discount
+		}
+	}
+
+	utils.RespondSuccess(c, http.StatusOK, "Ride estimate", resp)
+}
+
+// planRoute fetches directions from Ola Maps, prices the trip and caches the
+// planned route in Redis so the booking can later reference it by routeID.
+func planRoute(ctx context.Context, origin, destination, vehicleType string) (string, *stores.CachedRoute, error) {
+	return planRouteVia(ctx, origin, destination, nil, vehicleType)
+}
+
+// planRouteVia is planRoute through intermediate stops; the fare covers the whole route.
+func planRouteVia(ctx context.Context, origin, destination string, stops []stores.RouteStop, vehicleType string) (string, *stores.CachedRoute, error) {
+	olaClient := utils.NewOlaMapsClient().WithContext(ctx)
 
-	olaClient := utils.NewOlaMapsClient()
-	
 	// Map vehicle types to Ola Modes
 	mode := "driving"
-	if body.VehicleType == "Bike" {
+	if vehicleType == "Bike" {
 		mode = "bike"
-	} else if body.VehicleType == "Auto" {
+	} else if vehicleType == "Auto" {
 		mode = "auto"
 	}
 
-	// Refactor directions call to support mode (using literal URL for now or updating client)
-	// For production, we use the client's refactored method.
-	polyline, distance, duration, routeID, err := olaClient.GetDirectionsWithMode(body.Origin, body.Destination, mode)
+	waypoints := make([]string, 0, len(stops))
+	for _, stop := range stops {
+		waypoints = append(waypoints, fmt.Sprintf("%f,%f", stop.Lat, stop.Lng))
+	}
+
+	route, err := olaClient.GetRoute(origin, destination, waypoints, mode)
 	if err != nil {
-		utils.RespondError(c, http.StatusInternalServerError, "Failed to calculate route", err)
-		return
+		return "", nil, err
 	}
+	routeID, distance, duration := route.RouteID, route.Distance, route.Duration
 
-	pickupLat, pickupLng := utils.ParseLatLng(body.Origin)
-	destLat, destLng := utils.ParseLatLng(body.Destination)
-	fare := CalculateFare(body.VehicleType, distance, duration)
+	pickupLat, pickupLng := utils.ParseLatLng(origin)
+	destLat, destLng := utils.ParseLatLng(destination)
+	currency := currencyAt(pickupLat, pickupLng)
+	fare := CalculateFare(ctx, currency, vehicleType, distance, duration)
 
 	// OLA/UBER OPTIMIZATION: Cache the planned route in Redis
 	// This prevents fare tampering and reduces frontend payload size.
-	stores.StorePlannedRoute(routeID, stores.CachedRoute{
-		Polyline:        polyline,
+	cached := stores.CachedRoute{
+		Polyline:        route.Polyline,
 		Distance:        distance,
 		Duration:        duration,
 		Fare:            fare,
-		VehicleType:     body.VehicleType,
-		OriginName:      body.Origin, // Or body.OriginName if you have it
-		DestinationName: body.Destination,
+		Currency:        currency.Code,
+		VehicleType:     vehicleType,
+		OriginName:      origin,
+		DestinationName: destination,
 		OriginLat:       pickupLat,
 		OriginLng:       pickupLng,
 		DestinationLat:  destLat,
 		DestinationLng:  destLng,
-	})
-
-	utils.RespondSuccess(c, http.StatusOK, "Ride estimate", gin.H{
-		"polyline":  polyline,
-		"distance":  fmt.Sprintf("%.2f km", float64(distance)/1000.0),
-		"duration":  fmt.Sprintf("%d mins", int(float64(duration)/60.0)),
-		"fare":      fare,
-		"routeId":   routeID,
-	})
+		Stops:           stops,
+		Steps:           route.Steps,
+	}
+	if err := stores.StorePlannedRoute(ctx, routeID, cached); err != nil {
+		utils.Logger.Warn("Failed to cache planned route", zap.String("routeId", routeID), zap.Error(err))
+	}
+	return routeID, &cached, nil
 }
 
 
@@ -176,23 +288,24 @@ func CheckServiceAvailability(c *gin.Context) {
 	lat, _ := strconv.ParseFloat(c.Query("lat"), 64)
 	lng, _ := strconv.ParseFloat(c.Query("lng"), 64)
 
-	isAvailable := false
-	nearestCity := ""
-	
-	for _, zone := range serviceZones {
-		dist := utils.CalculateDistance(lat, lng, zone.Lat, zone.Lng)
-		if dist <= zone.Radius {
-			isAvailable = true
-			nearestCity = zone.Name
-			break
-		}
-	}
+	nearestCity := zoneForPoint(lat, lng)
+	isAvailable := nearestCity != ""
 
 	msg := "Service is available in your area (" + nearestCity + ")"
-	if !isAvailable {
+	if isAvailable {
+		// Beta cities are only open to allowlisted riders
+		if ok, reason := checkZoneAccess(c.Request.Context(), c.MustGet("user").(*models.User), lat, lng); !ok {
+			utils.RespondSuccess(c, http.StatusOK, "Service check", gin.H{
+				"isAvailable": false,
+				"comingSoon":  true,
+				"message":     reason,
+			})
+			return
+		}
+	} else {
 		// Construct dynamic list of available cities
 		var cities []string
-		for _, z := range serviceZones {
+		for _, z := range activeServiceZones() {
 			cities = append(cities, z.Name)
 		}
 		msg = fmt.Sprintf("Service not available. We operate in: %s", strings.Join(cities, ", "))
@@ -245,6 +358,9 @@ func CreateRide(c *gin.Context) {
 	var body struct {
 		RouteID     string `json:"routeId"`
 		VehicleType string `json:"vehicleType"`
+		PaymentMode string `json:"paymentMode"`
+		PromoCode   string `json:"promoCode"`
+		Stops       []rideStopInput `json:"stops"` // optional; must match the estimate
 	}
 
 	if err := c.ShouldBindJSON(&body); err != nil {
@@ -253,37 +369,150 @@ func CreateRide(c *gin.Context) {
 	}
 
 	// 1. Retrieve the audited route from Redis cache
-	cached, err := stores.GetPlannedRoute(body.RouteID)
+	cached, err := stores.GetPlannedRoute(c.Request.Context(), body.RouteID)
 	if err != nil {
-		utils.RespondError(c, http.StatusGone, "This route has expired. Please get a fresh estimate.", err)
+		utils.RespondErrorCode(c, http.StatusGone, utils.CodeRouteExpired, "This route has expired. Please get a fresh estimate.", err)
 		return
 	}
 
+	// Stops are priced into the estimate, so changing them needs a fresh one
+	if body.Stops != nil {
+		stops, err := parseRideStops(body.Stops)
+		if err != nil {
+			utils.RespondError(c, http.StatusBadRequest, err.Error(), nil)
+			return
+		}
+		if !sameRideStops(stops, cached.Stops) {
+			utils.RespondErrorCode(c, http.StatusConflict, utils.CodeRouteChanged, "Stops differ from the estimate. Please get a fresh estimate.", nil)
+			return
+		}
+	}
+
 	var rideId string
-	err = db.Pool.QueryRow(context.Background(),
-		`INSERT INTO rides (
-			id, "userId", "driverId", charge, "currentLocationName", "destinationLocationName", 
-			distance, polyline, "routeId", "estimatedDuration", "estimatedDistance", "vehicleType",
-			"originLat", "originLng", "destinationLat", "destinationLng",
-			status, "createdAt", "updatedAt"
-		) VALUES (
-			gen_random_uuid()::text, $1, NULL, $2, $3, $4, 
-			$5, NULL, $6, $7, $8, $9,
-			$10, $11, $12, $13,
-			'Requested', NOW(), NOW()
-		) RETURNING id`,
-		user.ID, cached.Fare, cached.OriginName, cached.DestinationName,
-		fmt.Sprintf("%d", cached.Distance), body.RouteID, cached.Duration, cached.Distance, cached.VehicleType,
-		cached.OriginLat, cached.OriginLng, cached.DestinationLat, cached.DestinationLng,
-	).Scan(&rideId)
+	var discount float64
+	if body.PromoCode != "" {
+		rideId, discount, err = insertRideWithPromo(c.Request.Context(), user.ID, body.RouteID, cached, body.PaymentMode, body.PromoCode)
+		var rejected promoError
+		if errors.As(err, &rejected) {
+			utils.RespondErrorCode(c, http.StatusBadRequest, utils.CodePromoInvalid, rejected.Error(), err)
+			return
+		}
+	} else {
+		rideId, err = repos.Rides.Create(c.Request.Context(), newRide(user.ID, body.RouteID, cached, body.PaymentMode))
+	}
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to create ride", err)
+		return
+	}
+	if err := saveRideStops(c.Request.Context(), rideId, cached.Stops); err != nil {
+		utils.Logger.Error("Failed to save ride stops", zap.String("rideId", rideId), zap.Error(err))
+	}
+	cached.Fare -= discount
+	publishRideEvent(rideId, events.RideRequested, events.ActorUser, user.ID, map[string]any{
+		"vehicleType": cached.VehicleType, "fare": cached.Fare, "stops": len(cached.Stops)})
+
+	nearbyCount, pool := dispatchOrPool(c.Request.Context(), rideId, user, cached)
+
+	resp := gin.H{
+		"rideId":        rideId,
+		"fare":          cached.Fare,
+		"discount":      discount,
+		"nearbyDrivers": nearbyCount,
+	}
+	if pool != nil {
+		resp["pool"] = pool
+	}
+	utils.RespondSuccess(c, http.StatusCreated, "Ride requested", resp)
+}
 
+// POST /api/v1/user/ride/:id/rebook — "book again" with fresh pricing
+func RebookRide(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+	rideID := c.Param("id")
+
+	ride, err := repos.Rides.GetForUser(c.Request.Context(), rideID, user.ID)
+	if err != nil {
+		utils.RespondErrorCode(c, http.StatusNotFound, utils.CodeRideNotFound, "Ride not found", err)
+		return
+	}
+	originLat, originLng, destLat, destLng := ride.OriginLat, ride.OriginLng, ride.DestinationLat, ride.DestinationLng
+	if originLat == nil || originLng == nil || destLat == nil || destLng == nil {
+		utils.RespondError(c, http.StatusUnprocessableEntity, "This ride has no saved coordinates and cannot be rebooked", nil)
+		return
+	}
+	if ok, reason := checkZoneAccess(c.Request.Context(), user, *originLat, *originLng); !ok {
+		utils.RespondErrorCode(c, http.StatusForbidden, utils.CodeZoneNotServed, reason, nil)
+		return
+	}
+
+	// Re-estimate the same trip so the rider always pays current pricing
+	origin := fmt.Sprintf("%f,%f", *originLat, *originLng)
+	destination := fmt.Sprintf("%f,%f", *destLat, *destLng)
+	routeID, cached, err := planRouteVia(c.Request.Context(), origin, destination, routeStops(loadRideStops(c.Request.Context(), rideID)), ride.VehicleType)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to calculate route", err)
+		return
+	}
+
+	// Preserve the human-readable place names from the original booking
+	cached.OriginName = ride.CurrentLocationName
+	cached.DestinationName = ride.DestinationLocationName
+	// The ride is booked against routeId, so the route with the original names must be cached
+	if err := stores.StorePlannedRoute(c.Request.Context(), routeID, *cached); err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to save route", err)
+		return
+	}
+
+	newRideID, err := repos.Rides.Create(c.Request.Context(), newRide(user.ID, routeID, cached, ride.PaymentMode))
 	if err != nil {
 		utils.RespondError(c, http.StatusInternalServerError, "Failed to create ride", err)
 		return
 	}
+	if err := saveRideStops(c.Request.Context(), newRideID, cached.Stops); err != nil {
+		utils.Logger.Error("Failed to save ride stops", zap.String("rideId", newRideID), zap.Error(err))
+	}
+	publishRideEvent(newRideID, events.RideRequested, events.ActorUser, user.ID, map[string]any{
+		"vehicleType": cached.VehicleType, "fare": cached.Fare, "stops": len(cached.Stops), "rebookedFromId": rideID})
+
+	nearbyCount, pool := dispatchOrPool(c.Request.Context(), newRideID, user, cached)
 
+	resp := gin.H{
+		"rideId":         newRideID,
+		"rebookedFromId": rideID,
+		"routeId":        routeID,
+		"fare":           cached.Fare,
+		"distance":       fmt.Sprintf("%.2f km", float64(cached.Distance)/1000.0),
+		"duration":       fmt.Sprintf("%d mins", int(float64(cached.Duration)/60.0)),
+		"nearbyDrivers":  nearbyCount,
+	}
+	if pool != nil {
+		resp["pool"] = pool
+	}
+	utils.RespondSuccess(c, http.StatusCreated, "Ride requested", resp)
+}
+
+// newRide is the ride request booked on a cached planned route.
+func newRide(userID, routeID string, cached *stores.CachedRoute, paymentMode string) repository.NewRide {
+	// Scheduled bookings and routes cached before currencies carry none; they're charged in the pickup's
+	// zone currency
+	currency := cached.Currency
+	if currency == "" {
+		currency = currencyAt(cached.OriginLat, cached.OriginLng).Code
+	}
+	return repository.NewRide{
+		UserID: userID, RouteID: routeID, VehicleType: cached.VehicleType, PaymentMode: paymentMode, Currency: currency,
+		Fare: cached.Fare, Distance: cached.Distance, Duration: cached.Duration,
+		OriginName: cached.OriginName, DestinationName: cached.DestinationName,
+		OriginLat: cached.OriginLat, OriginLng: cached.OriginLng, DestinationLat: cached.DestinationLat, DestinationLng: cached.DestinationLng,
+		Steps: cached.Steps,
+	}
+}
+
+// dispatchRideRequest notifies nearby online drivers about a new ride (FCM + Redis pub/sub)
+// and returns how many drivers were found near the pickup point.
+func dispatchRideRequest(rideId string, user *models.User, cached *stores.CachedRoute) int {
 	// Find nearby drivers from Redis (5km radius)
-	nearbyDrivers, _ := stores.GetNearbyDrivers(cached.OriginLat, cached.OriginLng, 5.0)
+	nearbyDrivers, _ := stores.GetNearbyDrivers(context.Background(), cached.OriginLat, cached.OriginLng, 5.0)
 
 	// Background: filter by online+active status and send push notifications
 	utils.SafeGo(func() {
@@ -298,43 +527,68 @@ func CreateRide(c *gin.Context) {
 		}
 
 
+		// Drivers in destination mode, or with a preferred zone, only get trips that suit them
+		misses := tripPreferenceMisses(db.Unscoped(), driverIDs, cached)
+
 		// Cross-check with DB: only online + active drivers of requested vehicle type get notifications
-		rows, err := db.Pool.Query(context.Background(),
-			`SELECT id, "notificationToken" FROM driver 
-			 WHERE id=ANY($1) AND "isOnline"=TRUE AND status='active' AND "vehicle_type"=$2 AND "notificationToken" IS NOT NULL AND "notificationToken" != ''`,
-			driverIDs, body.VehicleType)
+		candidates, err := repos.Drivers.DispatchCandidates(db.Unscoped(), driverIDs, dispatchVehicleType(cached.VehicleType), rideId)
 		if err != nil {
 			utils.Logger.Error("Failed to query online drivers", zap.Error(err))
 			return
 		}
-		defer rows.Close()
-
-		var tokens []string
-		for rows.Next() {
-			var id string
-			var token *string
-			rows.Scan(&id, &token)
-			if token != nil && *token != "" {
-				tokens = append(tokens, *token)
+
+		// Drivers who speak the rider's language, or score well, can be given a short head start
+		var riderLang string
+		headstart := dispatchLanguageHeadstart()
+		if headstart > 0 {
+			riderLang, _ = repos.Users.PreferredLanguage(db.Unscoped(), user.ID)
+		}
+		scoreHeadstart, priorityScore := dispatchScoreHeadstart(), dispatchPriorityScore()
+		headstart = max(headstart, scoreHeadstart)
+
+		var tokens, matchedTokens, offeredIDs, matchedIDs []string
+		for _, d := range candidates {
+			if d.NotificationToken == "" || misses[d.ID] {
+				continue
+			}
+			if (riderLang != "" && speaksLanguage(d.Languages, riderLang)) || (scoreHeadstart > 0 && d.Score != nil && *d.Score >= priorityScore) {
+				matchedTokens = append(matchedTokens, d.NotificationToken)
+				matchedIDs = append(matchedIDs, d.ID)
+			} else {
+				tokens = append(tokens, d.NotificationToken)
+				offeredIDs = append(offeredIDs, d.ID)
+			}
+		}
+
+		title := "🚗 New Ride Request!"
+		msg := fmt.Sprintf("Pickup: %s → %s (%s)", cached.OriginName, cached.DestinationName, formatMoney(cached.Currency, cached.Fare))
+		data := utils.FCMData{
+			"type":            "ride_request",
+			"rideId":          rideId,
+			"pickupLat":       fmt.Sprintf("%.6f", cached.OriginLat),
+			"pickupLng":       fmt.Sprintf("%.6f", cached.OriginLng),
+			"originName":      cached.OriginName,
+			"destinationName": cached.DestinationName,
+			"fare":            fmt.Sprintf("%.2f", cached.Fare),
+			"currency":        cached.Currency,
+			"vehicleType":     cached.VehicleType,
+		}
+
+		if len(matchedTokens) > 0 {
+			utils.SendPushToMultiple(matchedTokens, title, msg, data)
+			publishRideOffers(rideId, matchedIDs)
+
+			// Only widen to everyone else if nobody matched has taken it yet
+			time.Sleep(headstart)
+			if status, _, _ := repos.Rides.Status(db.Unscoped(), rideId); status != "Requested" {
+				return
 			}
 		}
 
 		// Send FCM push notifications to all online nearby drivers
 		if len(tokens) > 0 {
-			utils.SendPushToMultiple(tokens,
-				"🚗 New Ride Request!",
-				fmt.Sprintf("Pickup: %s → %s (₹%.0f)", cached.OriginName, cached.DestinationName, cached.Fare),
-				utils.FCMData{
-					"type":            "ride_request",
-					"rideId":          rideId,
-					"pickupLat":       fmt.Sprintf("%.6f", cached.OriginLat),
-					"pickupLng":       fmt.Sprintf("%.6f", cached.OriginLng),
-					"originName":      cached.OriginName,
-					"destinationName": cached.DestinationName,
-					"fare":            fmt.Sprintf("%.2f", cached.Fare),
-					"vehicleType":     cached.VehicleType,
-				},
-			)
+			utils.SendPushToMultiple(tokens, title, msg, data)
+			publishRideOffers(rideId, offeredIDs)
 		}
 
 		// Also publish to Redis pub/sub for WebSocket listeners
@@ -349,13 +603,11 @@ func CreateRide(c *gin.Context) {
 			Fare:        cached.Fare,
 			Distance:    cached.Distance,
 			Duration:    cached.Duration,
+			SkipDrivers: slices.Collect(maps.Keys(misses)),
 		})
 	})
 
-	utils.RespondSuccess(c, http.StatusCreated, "Ride requested", gin.H{
-		"rideId":        rideId,
-		"nearbyDrivers": len(nearbyDrivers),
-	})
+	return len(nearbyDrivers)
 }
 
 // POST /api/v1/user/ride/cancel
@@ -370,32 +622,79 @@ func CancelRide(c *gin.Context) {
 		return
 	}
 
+	// Riders can only cancel before the trip starts; the state machine also stops a cancel from
+	// overwriting a driver's accept that landed at the same moment
+	userID := c.MustGet("user").(*models.User).ID
 	var driverID *string
-	err := db.Pool.QueryRow(context.Background(),
-		`UPDATE rides SET status='Cancelled', "cancelReason"=$1, "cancelledAt"=NOW(), "updatedAt"=NOW() 
-		 WHERE id=$2 RETURNING "driverId"`,
-		body.CancelReason, body.RideID).Scan(&driverID)
-
+	var fee fares.Cancellation
+	_, err := statemachine.Transition(c.Request.Context(), statemachine.Change{
+		RideID: body.RideID, To: statemachine.Cancelled,
+		From:      []string{statemachine.Requested, statemachine.Accepted, statemachine.Arriving},
+		ActorType: events.ActorUser, ActorID: userID, Data: map[string]any{"reason": body.CancelReason},
+	}, func(ctx context.Context, tx pgx.Tx, from string) error {
+		// Once a driver is on the way, cancelling costs the rider by how long ago they accepted
+		acceptedAt, arrivedAt, now, err := repos.Rides.PickupTimes(ctx, tx, body.RideID)
+		if err != nil {
+			return err
+		}
+		if from != statemachine.Requested {
+			fee = fares.LoadPolicy().Cancellation(acceptedAt, arrivedAt, now)
+		}
+		driverID, err = repos.Rides.CancelByUser(ctx, tx, body.RideID, userID, body.CancelReason, fee)
+		return err
+	})
 	if err != nil {
-		utils.RespondError(c, http.StatusInternalServerError, "Failed to cancel ride", err)
+		respondTransitionError(c, err, "Failed to cancel ride")
 		return
 	}
 
+	releasePoolSeat(body.RideID)
+	recordRideCancellation(body.RideID, events.ActorUser, userID, body.CancelReason)
+
 	// If a driver was assigned, notify them
 	if driverID != nil && *driverID != "" {
-		var driverToken *string
-		db.Pool.QueryRow(context.Background(), `SELECT "notificationToken" FROM driver WHERE id=$1`, *driverID).Scan(&driverToken)
-		
-		if driverToken != nil && *driverToken != "" {
-			go utils.SendPushNotification(*driverToken, "Ride Cancelled ❌", "The user has cancelled the ride request.", utils.FCMData{
+		saveRideTrack(body.RideID, *driverID)
+
+		sendNotifications(c.Request.Context(), rideStatusNotifications("driver", *driverID, body.RideID,
+			"Ride Cancelled ❌", "The user has cancelled the ride request.", utils.FCMData{
 				"type":   "ride_cancelled",
 				"rideId": body.RideID,
-			})
-		}
+			})...)
 		// Also remove driver from busy status if needed, but usually they just go back to online
 	}
 
-	utils.RespondSuccess(c, http.StatusOK, "Ride cancelled", nil)
+	utils.RespondSuccess(c, http.StatusOK, "Ride cancelled", gin.H{
+		"cancellationFee": fee.Fee,
+		"waitingMinutes":  fee.Waiting.Minutes,
+		"waitingCharge":   fee.Waiting.Charge,
+		"totalFee":        fee.Total(),
+	})
+}
+
+// ridePolyline returns the stored polyline, or recovers it from the logged Ola Maps response for the route.
+func ridePolyline(ctx context.Context, polyline, routeID string) string {
+	if polyline != "" || routeID == "" {
+		return polyline
+	}
+	var respPayload []byte
+	err := db.Pool.QueryRow(ctx,
+		`SELECT "responsePayload" FROM external_api_logs WHERE "requestId" = $1`, routeID).Scan(&respPayload)
+	if err != nil {
+		return ""
+	}
+
+	// Extract polyline from the logged Ola Maps response
+	var result struct {
+		Routes []struct {
+			OverviewPolyline struct {
+				Points string `json:"points"`
+			} `json:"overview_polyline"`
+		} `json:"routes"`
+	}
+	if json.Unmarshal(respPayload, &result) == nil && len(result.Routes) > 0 {
+		return result.Routes[0].OverviewPolyline.Points
+	}
+	return ""
 }
 
 // GET /api/v1/user/ride/:id
@@ -403,39 +702,9 @@ func GetRideDetails(c *gin.Context) {
 	rideID := c.Param("id")
 	currentUser, _ := c.Get("user")
 
-	var ride models.Ride
-	var driver models.Driver
-	var user models.User
-
-	err := db.Pool.QueryRow(context.Background(),
-		`SELECT 
-			r.id, r."userId", r."driverId", r.charge, r."currentLocationName", r."destinationLocationName", 
-			r.distance, r.status, COALESCE(r."paymentMode", ''), COALESCE(r."paymentStatus", 'Pending'), 
-			COALESCE(r.otp, ''), COALESCE(r.polyline, ''), COALESCE(r."routeId", ''),
-			r."originLat", r."originLng", r."destinationLat", r."destinationLng",
-			r."createdAt",
-			COALESCE(d.id, ''), COALESCE(d.name, ''), COALESCE(d.phone_number, ''), COALESCE(d.vehicle_type, ''), 
-			COALESCE(d.vehicle_color, ''), COALESCE(d.registration_number, ''), COALESCE(d.ratings, 0), COALESCE(d."totalRides", 0), 
-			COALESCE(d."totalDistance", 0), COALESCE(d."profileImage", ''), d.upi_id,
-			u.id, u.name, u.phone_number, u.ratings
-		FROM rides r
-		LEFT JOIN driver d ON r."driverId" = d.id
-		JOIN "user" u ON r."userId" = u.id
-		WHERE r.id=$1`, rideID).
-		Scan(
-			&ride.ID, &ride.UserID, &ride.DriverID, &ride.Charge, &ride.CurrentLocationName, &ride.DestinationLocationName,
-			&ride.Distance, &ride.Status, &ride.PaymentMode, &ride.PaymentStatus,
-			&ride.OTP, &ride.Polyline, &ride.RouteID,
-			&ride.OriginLat, &ride.OriginLng, &ride.DestinationLat, &ride.DestinationLng,
-			&ride.CreatedAt,
-			&driver.ID, &driver.Name, &driver.PhoneNumber, &driver.VehicleType,
-			&driver.VehicleColor, &driver.RegistrationNumber, &driver.Ratings, &driver.TotalRides,
-			&driver.TotalDistance, &driver.ProfileImage, &driver.UpiID,
-			&user.ID, &user.Name, &user.PhoneNumber, &user.Ratings,
-		)
-
+	ride, err := repos.Rides.Details(c.Request.Context(), rideID)
 	if err != nil {
-		utils.RespondError(c, http.StatusNotFound, "Ride not found", err)
+		utils.RespondErrorCode(c, http.StatusNotFound, utils.CodeRideNotFound, "Ride not found", err)
 		return
 	}
 
@@ -448,36 +717,12 @@ func GetRideDetails(c *gin.Context) {
 		}
 	}
 
-	if driver.ID != "" {
-		ride.Driver = &driver
-	}
-	ride.User = &user
-
 	// FALLBACK: If polyline is missing from the optimized rides table, fetch it from the Audit Log
-	if ride.Polyline == "" && ride.RouteID != "" {
-		var respPayload []byte
-		err := db.Pool.QueryRow(context.Background(),
-			`SELECT "responsePayload" FROM external_api_logs WHERE "requestId" = $1`,
-			ride.RouteID).Scan(&respPayload)
-		
-		if err == nil {
-			// Extract polyline from the logged Ola Maps response
-			var result struct {
-				Routes []struct {
-					OverviewPolyline struct {
-						Points string `json:"points"`
-					} `json:"overview_polyline"`
-				} `json:"routes"`
-			}
-			if json.Unmarshal(respPayload, &result) == nil && len(result.Routes) > 0 {
-				ride.Polyline = result.Routes[0].OverviewPolyline.Points
-			}
-		}
-	}
+	ride.Polyline = ridePolyline(c.Request.Context(), ride.Polyline, ride.RouteID)
 
-	// Generate UPI QR Code if driver has UPI ID
+	// Generate UPI QR Code if driver has UPI ID (UPI only settles rupees)
 	var qrCodeBase64 string
-	if driver.UpiID != nil && *driver.UpiID != "" {
+	if driver := ride.Driver; driver != nil && driver.UpiID != nil && *driver.UpiID != "" && ride.Currency == "INR" {
 		// Construct UPI URL: upi://pay?pa=<upi_id>&pn=<name>&am=<amount>&cu=INR
 		// Encoded properly for QR generation.
 		param := fmt.Sprintf("upi://pay?pa=%s&pn=%s&am=%.2f&cu=INR", *driver.UpiID, driver.Name, ride.Charge)
@@ -491,10 +736,18 @@ func GetRideDetails(c *gin.Context) {
 		}
 	}
 
-	utils.RespondSuccess(c, http.StatusOK, "Ride details", gin.H{
+	resp := gin.H{
 		"ride":      ride,
 		"paymentQr": qrCodeBase64, // Send QR image string to frontend
-	})
+	}
+	if ride.Driver != nil {
+		resp["language"] = rideLanguageMatch(c.Request.Context(), ride.UserID, ride.Driver.ID)
+	}
+	if pool := ridePoolSummary(c.Request.Context(), ride.ID); pool != nil {
+		resp["pool"] = pool
+	}
+	resp["stops"] = loadRideStops(c.Request.Context(), ride.ID)
+	utils.RespondSuccess(c, http.StatusOK, "Ride details", resp)
 }
 
 // POST /api/v1/user/rate-driver
@@ -502,23 +755,29 @@ func RateDriver(c *gin.Context) {
 	var body struct {
 		RideID   string  `json:"rideId"`
 		DriverID string  `json:"driverId"`
-		Rating   float64 `json:"rating"`
-		Comment  string  `json:"comment"`
+		Rating   float64  `json:"rating"`
+		Comment  string   `json:"comment"`
+		Tags     []string `json:"tags"`
 	}
 	if err := c.ShouldBindJSON(&body); err != nil {
 		utils.RespondError(c, http.StatusBadRequest, "Invalid request", err)
 		return
 	}
 
-	db.Pool.Exec(context.Background(), `UPDATE rides SET rating=$1 WHERE id=$2`, body.Rating, body.RideID)
+	vehicleType, _ := repos.Rides.VehicleType(c.Request.Context(), body.RideID)
+	if err := validateRatingFeedback(ratingAudienceRider, vehicleType, body.Rating, body.Tags, body.Comment); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
 
-	_, err := db.Pool.Exec(context.Background(),
-		`UPDATE driver SET ratings = (ratings * "totalRides" + $1) / ("totalRides" + 1), "updatedAt"=NOW() WHERE id=$2`,
-		body.Rating, body.DriverID)
+	repos.Rides.RateDriver(c.Request.Context(), body.RideID, body.Rating, body.Tags, body.Comment)
+	saveRideReview(c.Request.Context(), body.RideID, c.MustGet("user").(*models.User).ID, body.Rating, body.Comment, body.Tags)
 
-	if err != nil {
+	if err := repos.Drivers.AddRating(c.Request.Context(), body.DriverID, body.Rating); err != nil {
 		utils.Logger.Error("Failed to update driver rating", zap.Error(err))
 	}
+	publishRideEvent(body.RideID, events.RideRated, events.ActorUser, c.MustGet("user").(*models.User).ID,
+		map[string]any{"rating": body.Rating})
 
 	utils.RespondSuccess(c, http.StatusOK, "Rating submitted", nil)
 }
@@ -526,21 +785,36 @@ func RateDriver(c *gin.Context) {
 // POST /api/v1/driver/rate-user
 func RateUser(c *gin.Context) {
 	var body struct {
-		UserID string  `json:"userId"`
-		Rating float64 `json:"rating"`
+		UserID  string   `json:"userId"`
+		RideID  string   `json:"rideId"`
+		Rating  float64  `json:"rating"`
+		Comment string   `json:"comment"`
+		Tags    []string `json:"tags"`
 	}
 	if err := c.ShouldBindJSON(&body); err != nil {
 		utils.RespondError(c, http.StatusBadRequest, "Invalid request", err)
 		return
 	}
 
-	_, err := db.Pool.Exec(context.Background(),
-		`UPDATE "user" SET ratings = (ratings * "totalRides" + $1) / ("totalRides" + 1), "updatedAt"=NOW() WHERE id=$2`,
-		body.Rating, body.UserID)
+	var vehicleType string
+	if body.RideID != "" {
+		vehicleType, _ = repos.Rides.VehicleType(c.Request.Context(), body.RideID)
+	}
+	if err := validateRatingFeedback(ratingAudienceDriver, vehicleType, body.Rating, body.Tags, body.Comment); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+	if body.RideID != "" {
+		repos.Rides.RateUser(c.Request.Context(), body.RideID, body.UserID, body.Rating, body.Tags, body.Comment)
+	}
 
-	if err != nil {
+	if err := repos.Users.AddRating(c.Request.Context(), body.UserID, body.Rating); err != nil {
 		utils.Logger.Error("Failed to update user rating", zap.Error(err))
 	}
+	if body.RideID != "" {
+		publishRideEvent(body.RideID, events.RideRated, events.ActorDriver, c.MustGet("driver").(*models.Driver).ID,
+			map[string]any{"rating": body.Rating})
+	}
 
 	utils.RespondSuccess(c, http.StatusOK, "Rating submitted", nil)
 }
@@ -560,11 +834,18 @@ func TriggerSOS(c *gin.Context) {
 
 	utils.Logger.Error("SOS TRIGGERED", zap.String("rideId", body.RideID), zap.Float64("lat", body.Lat), zap.Float64("lng", body.Lng))
 
-	// Persist SOS alert for admin audit trail
-	db.Pool.Exec(context.Background(),
-		`INSERT INTO sos_alerts (id, "rideId", "userId", lat, lng, status, "createdAt")
-		VALUES (gen_random_uuid()::text, $1, $2, $3, $4, 'active', NOW())`,
-		body.RideID, body.UserID, body.Lat, body.Lng)
+	// Persist SOS alert for admin audit trail, then page on-call admins right away
+	var alertID string
+	err := db.Pool.QueryRow(c.Request.Context(),
+		`INSERT INTO sos_alerts (id, "rideId", "userId", lat, lng, status, "createdAt", "driverId")
+		VALUES (gen_random_uuid()::text, NULLIF($1, ''), $2, $3, $4, 'active', NOW(), (SELECT "driverId" FROM rides WHERE id=$1))
+		RETURNING id`,
+		body.RideID, body.UserID, body.Lat, body.Lng).Scan(&alertID)
+	if err != nil {
+		utils.Logger.Error("Failed to record SOS alert", zap.String("rideId", body.RideID), zap.Error(err))
+	} else {
+		utils.SafeGo(func() { escalateSOSAlert(alertID) })
+	}
 
 	utils.RespondSuccess(c, http.StatusOK, "SOS Alert Sent!", nil)
 }
@@ -581,22 +862,33 @@ func ConfirmPayment(c *gin.Context) {
 		return
 	}
 
-	_, err := db.Pool.Exec(context.Background(),
-		`UPDATE rides SET "paymentStatus"='Paid', "paymentMode"=$1, "updatedAt"=NOW() WHERE id=$2`,
-		body.Mode, body.RideID)
+	if body.Amount == 0 {
+		body.Amount, _ = repos.Rides.Charge(c.Request.Context(), body.RideID)
+	}
+
+	recorded, err := recordRidePayment(body.RideID, body.Amount, body.Mode)
 	if err != nil {
-		utils.RespondError(c, http.StatusInternalServerError, "Failed to confirm payment", err)
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to record payment", err)
 		return
 	}
-
-	if body.Amount == 0 {
-		db.Pool.QueryRow(context.Background(), `SELECT charge FROM rides WHERE id=$1`, body.RideID).Scan(&body.Amount)
+	if !recorded {
+		utils.RespondSuccess(c, http.StatusOK, "Payment already recorded", nil)
+		return
 	}
 
-	db.Pool.Exec(context.Background(),
-		`INSERT INTO payments (id, "rideId", amount, mode, status, "createdAt")
-		VALUES (gen_random_uuid()::text, $1, $2, $3, 'paid', NOW())`,
-		body.RideID, body.Amount, body.Mode)
+	go notifyPaymentStatus(body.RideID, "Paid", body.Mode, body.Amount)
 
 	utils.RespondSuccess(c, http.StatusOK, "Payment confirmed", nil)
 }
+
+// recordRidePayment stores the fare for a ride exactly once and marks the ride paid in the same
+// transaction. If the ride already has a paid entry nothing is written; a pending entry for the
+// same mode is upgraded in place. Returns false when the payment had already been recorded.
+func recordRidePayment(rideID string, amount float64, mode string) (bool, error) {
+	recorded, err := repos.Payments.Record(db.Unscoped(), rideID, amount, mode)
+	if err != nil || !recorded {
+		return false, err
+	}
+	publishRideEvent(rideID, events.RidePaid, events.ActorSystem, "", map[string]any{"amount": amount, "mode": mode})
+	return true, nil
+}
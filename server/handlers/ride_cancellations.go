@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"ridewave/db"
+	"ridewave/events"
+	"ridewave/stores"
+	"ridewave/utils"
+)
+
+// ══════════════════════════════════════════════════
+// Ride Cancellations — who cancelled and why, and the driver cancellation penalty
+// ══════════════════════════════════════════════════
+
+// driverCancelSuspendRate is the cancellation rate (% of accepted rides, DRIVER_CANCEL_SUSPEND_RATE,
+// default 30) above which a driver is suspended automatically. 0 turns auto-suspension off.
+func driverCancelSuspendRate() float64 {
+	rate := 30.0
+	if val, err := strconv.ParseFloat(os.Getenv("DRIVER_CANCEL_SUSPEND_RATE"), 64); err == nil && val >= 0 {
+		rate = val
+	}
+	return rate
+}
+
+// driverCancelWindowDays is the rolling window the rate is measured over (DRIVER_CANCEL_WINDOW_DAYS, default 7).
+func driverCancelWindowDays() int {
+	days := 7
+	if val, err := strconv.Atoi(os.Getenv("DRIVER_CANCEL_WINDOW_DAYS")); err == nil && val > 0 {
+		days = val
+	}
+	return days
+}
+
+// driverCancelMinRides is how many rides a driver must have accepted in the window before the
+// rate can suspend them, so one early cancellation isn't 100% (DRIVER_CANCEL_MIN_RIDES, default 10).
+func driverCancelMinRides() int {
+	n := 10
+	if val, err := strconv.Atoi(os.Getenv("DRIVER_CANCEL_MIN_RIDES")); err == nil && val > 0 {
+		n = val
+	}
+	return n
+}
+
+// recordRideCancellation logs who cancelled a ride and why. A driver backing out of a ride they
+// had accepted counts towards their cancellation rate, which may suspend them.
+func recordRideCancellation(rideID, actor, actorID, reason string) {
+	ctx := db.Unscoped()
+	var afterAccept bool
+	db.Pool.QueryRow(ctx, `SELECT "acceptedAt" IS NOT NULL FROM rides WHERE id=$1`, rideID).Scan(&afterAccept)
+
+	_, err := db.Pool.Exec(ctx,
+		`INSERT INTO ride_cancellations ("rideId", actor, "actorId", reason, "afterAccept") VALUES ($1, $2, $3, $4, $5)`,
+		rideID, actor, actorID, reason, afterAccept)
+	if err != nil {
+		utils.Logger.Error("Failed to record ride cancellation", zap.String("rideId", rideID), zap.Error(err))
+	}
+	if actor != events.ActorDriver || !afterAccept {
+		return
+	}
+
+	db.Pool.Exec(ctx, `UPDATE driver SET "cancelRides"="cancelRides"+1, "updatedAt"=NOW() WHERE id=$1`, actorID)
+	enforceDriverCancelLimit(ctx, actorID)
+}
+
+// driverCancelRate counts the rides a driver accepted in the window and how many of those they
+// cancelled, as a percentage.
+func driverCancelRate(ctx context.Context, driverID string) (accepted, cancelled int, rate float64) {
+	days := driverCancelWindowDays()
+	db.Pool.QueryRow(ctx,
+		`SELECT COUNT(*) FROM rides WHERE "driverId"=$1 AND "acceptedAt" >= NOW() - make_interval(days => $2)`,
+		driverID, days).Scan(&accepted)
+	db.Pool.QueryRow(ctx,
+		`SELECT COUNT(*) FROM ride_cancellations
+		 WHERE actor='driver' AND "actorId"=$1 AND "afterAccept" AND "createdAt" >= NOW() - make_interval(days => $2)`,
+		driverID, days).Scan(&cancelled)
+	if accepted > 0 {
+		rate = math.Round(float64(cancelled)/float64(accepted)*1000) / 10
+	}
+	return accepted, cancelled, rate
+}
+
+// enforceDriverCancelLimit suspends an active driver whose cancellation rate is over the limit,
+// takes them offline and leaves a note on their account for support.
+func enforceDriverCancelLimit(ctx context.Context, driverID string) {
+	limit := driverCancelSuspendRate()
+	if limit <= 0 {
+		return
+	}
+	accepted, cancelled, rate := driverCancelRate(ctx, driverID)
+	if accepted < driverCancelMinRides() || rate <= limit {
+		return
+	}
+
+	tag, err := db.Pool.Exec(ctx,
+		`UPDATE driver SET status='suspended', "isOnline"=FALSE, "updatedAt"=NOW() WHERE id=$1 AND status='active'`, driverID)
+	if err != nil || tag.RowsAffected() == 0 {
+		return // not active (already suspended, or gone)
+	}
+	stores.RemoveDriver(ctx, driverID)
+	utils.RevokeSessionsAsync(utils.SessionDriver, driverID)
+
+	note := fmt.Sprintf("Auto-suspended: cancelled %d of %d accepted rides (%.1f%%) in the last %d days, over the %.1f%% limit.",
+		cancelled, accepted, rate, driverCancelWindowDays(), limit)
+	db.Pool.Exec(ctx,
+		`INSERT INTO admin_notes ("entityType", "entityId", note, tags, author) VALUES ($1, $2, $3, $4, 'system')`,
+		noteEntityDriver, driverID, note, []string{"auto-suspended", "cancellations"})
+	utils.Logger.Warn("Driver auto-suspended for cancellations",
+		zap.String("driverId", driverID), zap.Int("cancelled", cancelled), zap.Int("accepted", accepted), zap.Float64("rate", rate))
+
+	sendNotifications(ctx, outboxPush("driver", driverID, "", "Account suspended",
+		"Your account was suspended because too many accepted rides were cancelled. Contact support.", utils.FCMData{
+			"type": "account_suspended",
+		}))
+}
+
+// driverCancellationStats summarises a driver's cancellations for the admin driver detail.
+func driverCancellationStats(ctx context.Context, driverID string) gin.H {
+	accepted, cancelled, rate := driverCancelRate(ctx, driverID)
+	var lifetime float64
+	db.Pool.QueryRow(ctx, `SELECT "cancelRides" FROM driver WHERE id=$1`, driverID).Scan(&lifetime)
+
+	reasons := gin.H{}
+	rows, err := db.Pool.Query(ctx,
+		`SELECT COALESCE(NULLIF(reason, ''), 'unspecified'), COUNT(*) FROM ride_cancellations
+		 WHERE actor='driver' AND "actorId"=$1 AND "afterAccept" GROUP BY 1`, driverID)
+	if err == nil {
+		defer rows.Close()
+		for rows.Next() {
+			var reason string
+			var n int
+			if rows.Scan(&reason, &n) == nil {
+				reasons[reason] = n
+			}
+		}
+	}
+
+	return gin.H{
+		"windowDays":       driverCancelWindowDays(),
+		"acceptedCount":    accepted,
+		"cancelledCount":   cancelled,
+		"cancellationRate": rate,
+		"suspendAboveRate": driverCancelSuspendRate(),
+		"minAcceptedRides": driverCancelMinRides(),
+		"lifetimeCount":    lifetime,
+		"reasons":          reasons,
+	}
+}
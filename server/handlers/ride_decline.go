@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"context"
+	"math"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"ridewave/db"
+	"ridewave/events"
+	"ridewave/models"
+	"ridewave/stores"
+	"ridewave/utils"
+)
+
+// ══════════════════════════════════════════════════
+// Ride Decline — drivers passing on requests, and acceptance rate
+// ══════════════════════════════════════════════════
+
+var declineReasons = map[string]bool{
+	"too_far":       true,
+	"low_fare":      true,
+	"unsafe_area":   true,
+	"vehicle_issue": true,
+	"on_break":      true,
+	"other":         true,
+}
+
+// PUT /api/v1/driver/ride/decline
+func DeclineRide(c *gin.Context) {
+	driver := c.MustGet("driver").(*models.Driver)
+	var body struct {
+		RideID string `json:"rideId" binding:"required"`
+		Reason string `json:"reason" binding:"required"` // too_far | low_fare | unsafe_area | vehicle_issue | on_break | other
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid request", err)
+		return
+	}
+	if !declineReasons[body.Reason] {
+		utils.RespondError(c, http.StatusBadRequest, "Reason must be too_far, low_fare, unsafe_area, vehicle_issue, on_break or other", nil)
+		return
+	}
+
+	var status string
+	var assignedTo *string
+	err := db.Pool.QueryRow(c.Request.Context(),
+		`SELECT status, "driverId" FROM rides WHERE id=$1`, body.RideID).Scan(&status, &assignedTo)
+	if err != nil {
+		utils.RespondErrorCode(c, http.StatusNotFound, utils.CodeRideNotFound, "Ride not found", err)
+		return
+	}
+	if status != "Requested" {
+		utils.RespondErrorCode(c, http.StatusConflict, utils.CodeRideExpired, "This ride is no longer open", nil)
+		return
+	}
+	if assignedTo != nil && *assignedTo != driver.ID {
+		utils.RespondErrorCode(c, http.StatusConflict, utils.CodeRideTaken, "This ride is assigned to another driver", nil)
+		return
+	}
+
+	tag, err := db.Pool.Exec(c.Request.Context(),
+		`INSERT INTO ride_declines ("rideId", "driverId", reason) VALUES ($1, $2, $3)
+		 ON CONFLICT ("rideId", "driverId") DO NOTHING`, body.RideID, driver.ID, body.Reason)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to record decline", err)
+		return
+	}
+	if tag.RowsAffected() > 0 {
+		db.Pool.Exec(c.Request.Context(),
+			`UPDATE driver SET "declineCount"="declineCount"+1 WHERE id=$1`, driver.ID)
+		publishRideEvent(body.RideID, events.RideDeclined, events.ActorDriver, driver.ID, map[string]any{"reason": body.Reason})
+	}
+
+	// A ride held for this driver goes back to the pool for everyone else
+	if assignedTo != nil {
+		tag, err = db.Pool.Exec(c.Request.Context(),
+			`UPDATE rides SET "driverId"=NULL, "updatedAt"=NOW() WHERE id=$1 AND "driverId"=$2 AND status='Requested'`,
+			body.RideID, driver.ID)
+		if err == nil && tag.RowsAffected() > 0 {
+			redispatchRide(body.RideID)
+		}
+	}
+
+	utils.RespondSuccess(c, http.StatusOK, "Ride declined", gin.H{
+		"rideId":     body.RideID,
+		"reason":     body.Reason,
+		"acceptance": driverAcceptanceStats(c.Request.Context(), driver.ID),
+	})
+}
+
+// redispatchRide pushes an open ride to nearby drivers again, rebuilding the route from the ride row.
+func redispatchRide(rideID string) {
+	var userID string
+	var cached stores.CachedRoute
+	var originLat, originLng, destLat, destLng *float64
+	err := db.Pool.QueryRow(db.Unscoped(),
+		`SELECT "userId", charge, COALESCE("currentLocationName", ''), COALESCE("destinationLocationName", ''),
+		 COALESCE("estimatedDistance", 0), COALESCE("estimatedDuration", 0), COALESCE("vehicleType", ''),
+		 "originLat", "originLng", "destinationLat", "destinationLng"
+		 FROM rides WHERE id=$1`, rideID).
+		Scan(&userID, &cached.Fare, &cached.OriginName, &cached.DestinationName,
+			&cached.Distance, &cached.Duration, &cached.VehicleType,
+			&originLat, &originLng, &destLat, &destLng)
+	if err != nil || originLat == nil || originLng == nil {
+		utils.Logger.Warn("Cannot redispatch ride", zap.String("rideId", rideID), zap.Error(err))
+		return
+	}
+	cached.OriginLat, cached.OriginLng = *originLat, *originLng
+	if destLat != nil && destLng != nil {
+		cached.DestinationLat, cached.DestinationLng = *destLat, *destLng
+	}
+	dispatchRideRequest(rideID, &models.User{ID: userID}, &cached)
+}
+
+// driverAcceptanceStats summarises how often a driver takes the rides offered to them.
+func driverAcceptanceStats(ctx context.Context, driverID string) gin.H {
+	var accepted, declined int
+	db.Pool.QueryRow(ctx,
+		`SELECT COUNT(*) FROM rides WHERE "driverId"=$1 AND "acceptedAt" IS NOT NULL`, driverID).Scan(&accepted)
+	db.Pool.QueryRow(ctx,
+		`SELECT COALESCE("declineCount", 0) FROM driver WHERE id=$1`, driverID).Scan(&declined)
+
+	acceptanceRate := 100.0
+	if accepted+declined > 0 {
+		acceptanceRate = math.Round(float64(accepted)/float64(accepted+declined)*1000) / 10
+	}
+
+	reasons := gin.H{}
+	rows, err := db.Pool.Query(ctx,
+		`SELECT reason, COUNT(*) FROM ride_declines WHERE "driverId"=$1 GROUP BY reason`, driverID)
+	if err == nil {
+		defer rows.Close()
+		for rows.Next() {
+			var reason string
+			var n int
+			if rows.Scan(&reason, &n) == nil {
+				reasons[reason] = n
+			}
+		}
+	}
+
+	return gin.H{
+		"acceptedCount":  accepted,
+		"declineCount":   declined,
+		"acceptanceRate": acceptanceRate,
+		"declineReasons": reasons,
+	}
+}
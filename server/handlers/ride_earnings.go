@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"go.uber.org/zap"
+	"ridewave/db"
+	"ridewave/stores"
+	"ridewave/utils"
+)
+
+// ══════════════════════════════════════════════════
+// Ride Earnings — per-ride breakdown behind the driver's earnings screens
+// ══════════════════════════════════════════════════
+//
+// Each completed ride gets a ride_earnings row when the driver's wallet is credited, and tips
+// and dispute adjustments update it. The earnings endpoints sum these rows, so every figure a
+// driver sees can be traced to rides and recomputed.
+
+// BackfillRideEarnings adds rows for rides completed before the breakdown was kept, from the ride
+// and its ledger entries. Rides older than commission auditing are charged the default rate, as
+// the earnings statement does. A no-op once every completed ride has a row.
+func BackfillRideEarnings() {
+	tag, err := db.Pool.Exec(db.Unscoped(),
+		`INSERT INTO ride_earnings ("rideId", "driverId", "fleetId", "grossFare", commission, "fleetCommission", tip, "completedAt")
+		 SELECT r.id, r."driverId", e."fleetId", r.charge,
+		 COALESCE(r."commissionAmount", e.commission + COALESCE(a.commission, 0), ROUND((r.charge - r.charge / (1 + $1 / 100.0))::numeric, 2)::float8),
+		 COALESCE(e."fleetCommission", 0) + COALESCE(a."fleetCommission", 0), COALESCE(r.tips, 0), COALESCE(r."completedAt", r."updatedAt")
+		 FROM rides r
+		 LEFT JOIN wallet_transactions e ON e."rideId"=r.id AND e.type=$2
+		 LEFT JOIN wallet_transactions a ON a."rideId"=r.id AND a.type=$3
+		 WHERE r.status='Completed' AND r."driverId" IS NOT NULL
+		 AND NOT EXISTS (SELECT 1 FROM ride_earnings re WHERE re."rideId"=r.id)
+		 ON CONFLICT ("rideId") DO NOTHING`, platformFeePercent(), stores.WalletTxRideEarning, stores.WalletTxFareAdjustment)
+	if err != nil {
+		utils.Logger.Error("Failed to backfill ride earnings", zap.Error(err))
+		return
+	}
+	if tag.RowsAffected() > 0 {
+		utils.Logger.Info("Ride earnings backfilled", zap.Int64("rides", tag.RowsAffected()))
+	}
+}
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"ridewave/db"
+	"ridewave/stores"
+	"ridewave/utils"
+)
+
+// ══════════════════════════════════════════════════
+// Ride Route Export — planned vs actual geometry (GPX / GeoJSON)
+// ══════════════════════════════════════════════════
+
+// saveRideTrack stops recording the driver's trail and persists whatever was captured for the ride.
+func saveRideTrack(rideID, driverID string) {
+	stores.StopRideTrack(context.Background(), driverID)
+
+	points, err := stores.GetRideTrack(context.Background(), rideID)
+	if err != nil || len(points) == 0 {
+		return
+	}
+	val, _ := json.Marshal(points)
+	_, err = db.Pool.Exec(context.Background(),
+		`INSERT INTO ride_tracks ("rideId", points) VALUES ($1, $2)
+		 ON CONFLICT ("rideId") DO UPDATE SET points=EXCLUDED.points`, rideID, val)
+	if err != nil {
+		utils.Logger.Error("Failed to save ride track", zap.String("rideId", rideID), zap.Error(err))
+		return
+	}
+	db.RedisClient.Del(context.Background(), stores.RideTrackKeyPrefix+rideID)
+}
+
+// loadRideTrack returns the persisted trail, falling back to Redis for a ride still in progress.
+func loadRideTrack(ctx context.Context, rideID string) []stores.TrackPoint {
+	var raw []byte
+	err := db.Pool.QueryRow(ctx,
+		`SELECT points FROM ride_tracks WHERE "rideId"=$1`, rideID).Scan(&raw)
+	if err == nil {
+		var points []stores.TrackPoint
+		if json.Unmarshal(raw, &points) == nil {
+			return points
+		}
+	}
+	points, _ := stores.GetRideTrack(ctx, rideID)
+	return points
+}
+
+type gpxPoint struct {
+	Lat  float64 `xml:"lat,attr"`
+	Lon  float64 `xml:"lon,attr"`
+	Time string  `xml:"time,omitempty"`
+}
+
+type gpxDoc struct {
+	XMLName xml.Name `xml:"gpx"`
+	Version string   `xml:"version,attr"`
+	Creator string   `xml:"creator,attr"`
+	Xmlns   string   `xml:"xmlns,attr"`
+	Route   struct {
+		Name   string     `xml:"name"`
+		Points []gpxPoint `xml:"rtept"`
+	} `xml:"rte"`
+	Track struct {
+		Name    string `xml:"name"`
+		Segment struct {
+			Points []gpxPoint `xml:"trkpt"`
+		} `xml:"trkseg"`
+	} `xml:"trk"`
+}
+
+// GET /api/v1/admin/ride/:id/export?format=gpx|geojson
+func AdminExportRideRoute(c *gin.Context) {
+	rideID := c.Param("id")
+	format := c.DefaultQuery("format", "geojson")
+	if format != "gpx" && format != "geojson" {
+		utils.RespondError(c, http.StatusBadRequest, "Format must be gpx or geojson", nil)
+		return
+	}
+
+	var polyline, routeID, status string
+	var createdAt time.Time
+	err := db.Pool.QueryRow(adminContext(c),
+		`SELECT COALESCE(polyline, ''), COALESCE("routeId", ''), status, "createdAt" FROM rides WHERE id=$1`, rideID).
+		Scan(&polyline, &routeID, &status, &createdAt)
+	if err != nil {
+		utils.RespondErrorCode(c, http.StatusNotFound, utils.CodeRideNotFound, "Ride not found", err)
+		return
+	}
+
+	planned := utils.DecodePolyline(ridePolyline(adminContext(c), polyline, routeID))
+	actual := loadRideTrack(adminContext(c), rideID)
+
+	if format == "gpx" {
+		doc := gpxDoc{Version: "1.1", Creator: "RideWave", Xmlns: "http://www.topografix.com/GPX/1/1"}
+		doc.Route.Name = "Planned route " + rideID
+		for _, p := range planned {
+			doc.Route.Points = append(doc.Route.Points, gpxPoint{Lat: p[0], Lon: p[1]})
+		}
+		doc.Track.Name = "Actual route " + rideID
+		for _, p := range actual {
+			doc.Track.Segment.Points = append(doc.Track.Segment.Points,
+				gpxPoint{Lat: p.Lat, Lon: p.Lng, Time: p.Time.UTC().Format(time.RFC3339)})
+		}
+
+		out, err := xml.MarshalIndent(doc, "", "  ")
+		if err != nil {
+			utils.RespondError(c, http.StatusInternalServerError, "Failed to build GPX", err)
+			return
+		}
+		c.Header("Content-Disposition", "attachment; filename=ride-"+rideID+".gpx")
+		c.Data(http.StatusOK, "application/gpx+xml", append([]byte(xml.Header), out...))
+		return
+	}
+
+	// GeoJSON coordinates are [lng, lat]
+	plannedCoords := [][]float64{}
+	for _, p := range planned {
+		plannedCoords = append(plannedCoords, []float64{p[1], p[0]})
+	}
+	actualCoords := [][]float64{}
+	actualTimes := []string{}
+	for _, p := range actual {
+		actualCoords = append(actualCoords, []float64{p.Lng, p.Lat})
+		actualTimes = append(actualTimes, p.Time.UTC().Format(time.RFC3339))
+	}
+
+	c.Header("Content-Disposition", "attachment; filename=ride-"+rideID+".geojson")
+	c.Header("Content-Type", "application/geo+json")
+	c.JSON(http.StatusOK, gin.H{
+		"type": "FeatureCollection",
+		"features": []gin.H{
+			{
+				"type":       "Feature",
+				"geometry":   gin.H{"type": "LineString", "coordinates": plannedCoords},
+				"properties": gin.H{"name": "planned", "rideId": rideID, "status": status, "bookedAt": createdAt},
+			},
+			{
+				"type":       "Feature",
+				"geometry":   gin.H{"type": "LineString", "coordinates": actualCoords},
+				"properties": gin.H{"name": "actual", "rideId": rideID, "status": status, "times": actualTimes},
+			},
+		},
+	})
+}
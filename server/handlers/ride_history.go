@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"ridewave/repository"
+)
+
+// rideHistoryFilter narrows a ride history list from the query string:
+// ?status=Completed,Cancelled&from=2026-01-01&to=2026-01-31 (dates inclusive, on the ride's creation).
+func rideHistoryFilter(c *gin.Context) repository.RideHistoryFilter {
+	var f repository.RideHistoryFilter
+	if status := c.Query("status"); status != "" {
+		f.Statuses = strings.Split(status, ",")
+	}
+	if t, err := time.Parse("2006-01-02", c.Query("from")); err == nil {
+		f.From = &t
+	}
+	if t, err := time.Parse("2006-01-02", c.Query("to")); err == nil {
+		end := t.AddDate(0, 0, 1) // inclusive of the whole end day
+		f.To = &end
+	}
+	return f
+}
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"ridewave/db"
+	"ridewave/events"
+	"ridewave/models"
+	"ridewave/stores"
+	"ridewave/utils"
+)
+
+// ══════════════════════════════════════════════════
+// Multi-stop Rides — ordered waypoints between pickup and destination
+// ══════════════════════════════════════════════════
+
+const maxRideStops = 3
+
+// rideStopInput is a waypoint as sent by the rider app.
+type rideStopInput struct {
+	Location string `json:"location"` // "lat,lng"
+	Name     string `json:"name"`
+}
+
+type rideStop struct {
+	Seq         int        `json:"seq"`
+	Name        string     `json:"name"`
+	Lat         float64    `json:"lat"`
+	Lng         float64    `json:"lng"`
+	CompletedAt *time.Time `json:"completedAt"`
+}
+
+// parseRideStops validates waypoints and keeps their order.
+func parseRideStops(inputs []rideStopInput) ([]stores.RouteStop, error) {
+	if len(inputs) > maxRideStops {
+		return nil, fmt.Errorf("A ride can have at most %d stops", maxRideStops)
+	}
+	stops := make([]stores.RouteStop, 0, len(inputs))
+	for i, in := range inputs {
+		if !strings.Contains(in.Location, ",") {
+			return nil, fmt.Errorf("Stop %d must be a \"lat,lng\" location", i+1)
+		}
+		lat, lng := utils.ParseLatLng(in.Location)
+		if lat == 0 && lng == 0 {
+			return nil, fmt.Errorf("Stop %d must be a \"lat,lng\" location", i+1)
+		}
+		name := strings.TrimSpace(in.Name)
+		if name == "" {
+			name = in.Location
+		}
+		stops = append(stops, stores.RouteStop{Name: name, Lat: lat, Lng: lng})
+	}
+	return stops, nil
+}
+
+// sameRideStops reports whether the stops sent at booking match the estimate's.
+func sameRideStops(a, b []stores.RouteStop) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if utils.CalculateDistance(a[i].Lat, a[i].Lng, b[i].Lat, b[i].Lng) > 0.01 {
+			return false
+		}
+	}
+	return true
+}
+
+// saveRideStops persists a booked ride's waypoints.
+func saveRideStops(ctx context.Context, rideID string, stops []stores.RouteStop) error {
+	for seq, stop := range stops {
+		_, err := db.Pool.Exec(ctx,
+			`INSERT INTO ride_stops ("rideId", seq, name, lat, lng) VALUES ($1, $2, $3, $4, $5)`,
+			rideID, seq, stop.Name, stop.Lat, stop.Lng)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadRideStops returns a ride's waypoints in visiting order.
+func loadRideStops(ctx context.Context, rideID string) []rideStop {
+	stops := []rideStop{}
+	rows, err := db.Pool.Query(ctx,
+		`SELECT seq, name, lat, lng, "completedAt" FROM ride_stops WHERE "rideId"=$1 ORDER BY seq`, rideID)
+	if err != nil {
+		return stops
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var s rideStop
+		if rows.Scan(&s.Seq, &s.Name, &s.Lat, &s.Lng, &s.CompletedAt) == nil {
+			stops = append(stops, s)
+		}
+	}
+	return stops
+}
+
+// routeStops converts stored waypoints back into route stops (e.g. to rebook the same trip).
+func routeStops(stops []rideStop) []stores.RouteStop {
+	out := make([]stores.RouteStop, 0, len(stops))
+	for _, s := range stops {
+		out = append(out, stores.RouteStop{Name: s.Name, Lat: s.Lat, Lng: s.Lng})
+	}
+	return out
+}
+
+// PUT /api/v1/driver/ride/stop/complete
+func CompleteRideStop(c *gin.Context) {
+	driver := c.MustGet("driver").(*models.Driver)
+	var body struct {
+		RideID string `json:"rideId" binding:"required"`
+		Seq    *int   `json:"seq" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid input data", err)
+		return
+	}
+
+	var status, userID string
+	err := db.Pool.QueryRow(c.Request.Context(),
+		`SELECT status, "userId" FROM rides WHERE id=$1 AND "driverId"=$2`, body.RideID, driver.ID).Scan(&status, &userID)
+	if err != nil {
+		utils.RespondErrorCode(c, http.StatusNotFound, utils.CodeRideNotFound, "Ride not found", err)
+		return
+	}
+	if status != "InProgress" {
+		utils.RespondError(c, http.StatusConflict, "Stops can only be completed during the trip", nil)
+		return
+	}
+
+	// Stops are visited in order, so every earlier stop must already be done
+	var pending int
+	db.Pool.QueryRow(c.Request.Context(),
+		`SELECT COUNT(*) FROM ride_stops WHERE "rideId"=$1 AND seq<$2 AND "completedAt" IS NULL`, body.RideID, *body.Seq).Scan(&pending)
+	if pending > 0 {
+		utils.RespondError(c, http.StatusConflict, "Complete the earlier stops first", nil)
+		return
+	}
+
+	var name string
+	err = db.Pool.QueryRow(c.Request.Context(),
+		`UPDATE ride_stops SET "completedAt"=NOW() WHERE "rideId"=$1 AND seq=$2 AND "completedAt" IS NULL RETURNING name`,
+		body.RideID, *body.Seq).Scan(&name)
+	if err != nil {
+		utils.RespondError(c, http.StatusNotFound, "Stop not found or already completed", err)
+		return
+	}
+	publishRideEvent(body.RideID, events.StopCompleted, events.ActorDriver, driver.ID, map[string]any{"seq": *body.Seq, "name": name})
+
+	sendNotifications(c.Request.Context(), outboxPush("user", userID, body.RideID, "Stop reached 📍", fmt.Sprintf("You've reached %s.", name), utils.FCMData{
+		"type":   "ride_stop",
+		"rideId": body.RideID,
+		"seq":    fmt.Sprintf("%d", *body.Seq),
+	}))
+
+	utils.RespondSuccess(c, http.StatusOK, "Stop completed", gin.H{"rideId": body.RideID, "stops": loadRideStops(c.Request.Context(), body.RideID)})
+}
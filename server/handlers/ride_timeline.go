@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"ridewave/db"
+	"ridewave/events"
+	"ridewave/models"
+	"ridewave/utils"
+)
+
+// ══════════════════════════════════════════════════
+// Ride Timeline — every lifecycle event, recorded from the event bus
+// ══════════════════════════════════════════════════
+
+const rideEventWrite = "ride_event"
+
+func init() {
+	events.Subscribe(saveRideEvent)
+	utils.RegisterRetryableWrite(rideEventWrite, func(ctx context.Context, payload json.RawMessage) error {
+		var e events.Event
+		if err := json.Unmarshal(payload, &e); err != nil {
+			return err
+		}
+		return insertRideEvent(ctx, e)
+	})
+}
+
+type rideEvent struct {
+	Type      string         `json:"type"`
+	Label     string         `json:"label,omitempty"`
+	ActorType string         `json:"actorType,omitempty"`
+	ActorID   *string        `json:"actorId,omitempty"`
+	Data      map[string]any `json:"data"`
+	At        time.Time      `json:"at"`
+}
+
+// riderTimelineLabels lists the events a rider sees; offers and declines stay internal.
+var riderTimelineLabels = map[string]string{
+	events.RideRequested: "Ride requested",
+	events.RideAccepted:  "Driver accepted",
+	events.DriverArrived: "Driver arrived at pickup",
+	events.RideStarted:   "Trip started",
+	events.StopCompleted: "Stop reached",
+	events.RideCompleted: "Trip completed",
+	events.RideCancelled: "Ride cancelled",
+	events.RidePaid:      "Payment received",
+	events.RideRated:     "You rated your driver",
+	events.RideTipped:    "You tipped your driver",
+}
+
+// riderTimelineData is the event data a rider may see.
+var riderTimelineData = map[string]bool{"name": true, "seq": true, "amount": true, "mode": true, "reason": true, "rating": true}
+
+// publishRideEvent records something that happened to a ride on the event bus.
+func publishRideEvent(rideID, eventType, actorType, actorID string, data map[string]any) {
+	events.Publish(events.Event{Type: eventType, RideID: rideID, ActorType: actorType, ActorID: actorID, Data: data})
+}
+
+// publishRideOffers records each driver a ride request was sent to.
+func publishRideOffers(rideID string, driverIDs []string) {
+	for _, id := range driverIDs {
+		publishRideEvent(rideID, events.RideOffered, events.ActorSystem, "", map[string]any{"driverId": id})
+	}
+}
+
+// saveRideEvent appends a bus event to the ride's timeline.
+func saveRideEvent(e events.Event) {
+	if e.RideID == "" {
+		return
+	}
+	if err := insertRideEvent(context.Background(), e); err != nil {
+		utils.Logger.Warn("Failed to record ride event, queued for retry", zap.String("rideId", e.RideID), zap.String("type", e.Type), zap.Error(err))
+		utils.QueueFailedWrite(rideEventWrite, e, err)
+	}
+}
+
+func insertRideEvent(ctx context.Context, e events.Event) error {
+	data := e.Data
+	if data == nil {
+		data = map[string]any{}
+	}
+	_, err := db.Pool.Exec(ctx,
+		`INSERT INTO ride_events ("rideId", type, "actorType", "actorId", data, "createdAt") VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6)`,
+		e.RideID, e.Type, e.ActorType, e.ActorID, data, e.At)
+	return err
+}
+
+func loadRideEvents(ctx context.Context, rideID string) []rideEvent {
+	timeline := []rideEvent{}
+	rows, err := db.Pool.Query(ctx,
+		`SELECT type, "actorType", "actorId", data, "createdAt" FROM ride_events WHERE "rideId"=$1 ORDER BY "createdAt", id`, rideID)
+	if err != nil {
+		return timeline
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var e rideEvent
+		if rows.Scan(&e.Type, &e.ActorType, &e.ActorID, &e.Data, &e.At) == nil {
+			timeline = append(timeline, e)
+		}
+	}
+	return timeline
+}
+
+// GET /api/v1/admin/ride/:id/timeline
+func AdminGetRideTimeline(c *gin.Context) {
+	rideID := c.Param("id")
+	var exists bool
+	db.Pool.QueryRow(adminContext(c), `SELECT EXISTS(SELECT 1 FROM rides WHERE id=$1)`, rideID).Scan(&exists)
+	if !exists {
+		utils.RespondErrorCode(c, http.StatusNotFound, utils.CodeRideNotFound, "Ride not found", nil)
+		return
+	}
+	utils.RespondSuccess(c, http.StatusOK, "Ride timeline", gin.H{"rideId": rideID, "timeline": loadRideEvents(adminContext(c), rideID)})
+}
+
+// GET /api/v1/user/ride/:id/timeline — what happened to the rider's trip, without internal dispatch detail
+func GetRideTimeline(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+	rideID := c.Param("id")
+
+	var exists bool
+	db.Pool.QueryRow(c.Request.Context(),
+		`SELECT EXISTS(SELECT 1 FROM rides WHERE id=$1 AND "userId"=$2)`, rideID, user.ID).Scan(&exists)
+	if !exists {
+		utils.RespondErrorCode(c, http.StatusNotFound, utils.CodeRideNotFound, "Ride not found", nil)
+		return
+	}
+
+	timeline := []rideEvent{}
+	for _, e := range loadRideEvents(c.Request.Context(), rideID) {
+		label, ok := riderTimelineLabels[e.Type]
+		// Only the rider's own rating of the driver is theirs to see
+		if !ok || (e.Type == events.RideRated && e.ActorType != events.ActorUser) {
+			continue
+		}
+		data := map[string]any{}
+		for k, v := range e.Data {
+			if riderTimelineData[k] {
+				data[k] = v
+			}
+		}
+		timeline = append(timeline, rideEvent{Type: e.Type, Label: label, Data: data, At: e.At})
+	}
+	utils.RespondSuccess(c, http.StatusOK, "Ride timeline", gin.H{"rideId": rideID, "timeline": timeline})
+}
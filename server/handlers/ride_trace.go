@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"ridewave/db"
+	"ridewave/utils"
+)
+
+// ══════════════════════════════════════════════════
+// Admin: Ride Route Playback — actual trail vs planned route
+// ══════════════════════════════════════════════════
+//
+// For route disputes and fare complaints. Deviation is measured only over the trip itself
+// (startedAt → completedAt); the drive to the pickup is played back but not scored.
+
+const (
+	tracePhasePickup = "pickup"
+	tracePhaseTrip   = "trip"
+)
+
+// tracePoint is one recorded fix, as played back. Raw coordinates are only known for batched pings.
+type tracePoint struct {
+	Lat        float64   `json:"lat"`
+	Lng        float64   `json:"lng"`
+	RawLat     *float64  `json:"rawLat,omitempty"`
+	RawLng     *float64  `json:"rawLng,omitempty"`
+	Heading    *float64  `json:"heading,omitempty"`
+	Speed      *float64  `json:"speed,omitempty"`
+	RecordedAt time.Time `json:"recordedAt"`
+	Phase      string    `json:"phase"` // pickup | trip
+}
+
+// offRouteThresholdMeters is how far from the planned route a fix may be before it counts as off route.
+func offRouteThresholdMeters() float64 {
+	if v, err := strconv.ParseFloat(os.Getenv("OFF_ROUTE_THRESHOLD_METERS"), 64); err == nil && v > 0 {
+		return v
+	}
+	return 200
+}
+
+// loadRideTrace returns the ride's breadcrumbs from driver_location_history, or from the
+// in-ride track when the driver app never sent batches.
+func loadRideTrace(ctx context.Context, rideID string) ([]tracePoint, string) {
+	trace := []tracePoint{}
+	rows, err := db.Pool.Query(ctx,
+		`SELECT lat, lng, "rawLat", "rawLng", heading, speed, "recordedAt" FROM driver_location_history
+		 WHERE "rideId"=$1 ORDER BY "recordedAt" ASC LIMIT 10000`, rideID)
+	if err == nil {
+		defer rows.Close()
+		for rows.Next() {
+			var p tracePoint
+			if rows.Scan(&p.Lat, &p.Lng, &p.RawLat, &p.RawLng, &p.Heading, &p.Speed, &p.RecordedAt) == nil {
+				trace = append(trace, p)
+			}
+		}
+	}
+	if len(trace) > 0 {
+		return trace, "location_history"
+	}
+	for _, p := range loadRideTrack(ctx, rideID) {
+		trace = append(trace, tracePoint{Lat: p.Lat, Lng: p.Lng, RecordedAt: p.Time})
+	}
+	if len(trace) > 0 {
+		return trace, "ride_track"
+	}
+	return trace, "none"
+}
+
+// routeDeviation compares the trip's fixes with the planned route.
+func routeDeviation(planned, actual [][2]float64) gin.H {
+	if len(planned) < 2 || len(actual) == 0 {
+		return gin.H{"maxDeviationMeters": nil, "avgDeviationMeters": nil, "offRoutePercent": nil}
+	}
+	threshold := offRouteThresholdMeters()
+	var maxMeters, sumMeters float64
+	offRoute := 0
+	for _, p := range actual {
+		meters := utils.DistanceToPath(p[0], p[1], planned) * 1000
+		maxMeters = math.Max(maxMeters, meters)
+		sumMeters += meters
+		if meters > threshold {
+			offRoute++
+		}
+	}
+	return gin.H{
+		"maxDeviationMeters": math.Round(maxMeters),
+		"avgDeviationMeters": math.Round(sumMeters / float64(len(actual))),
+		"offRoutePercent":    math.Round(float64(offRoute)/float64(len(actual))*1000) / 10,
+	}
+}
+
+// percentChange is how much actual differs from planned, or nil without a plan.
+func percentChange(planned, actual float64) *float64 {
+	if planned <= 0 {
+		return nil
+	}
+	pct := math.Round((actual-planned)/planned*1000) / 10
+	return &pct
+}
+
+// GET /api/v1/admin/ride/:id/trace — recorded breadcrumbs, planned polyline and deviation metrics
+func AdminGetRideTrace(c *gin.Context) {
+	ctx := adminContext(c)
+	rideID := c.Param("id")
+
+	var polyline, routeID, status string
+	var charge float64
+	var plannedMeters, plannedSeconds int
+	var startedAt, completedAt *time.Time
+	err := db.Pool.QueryRow(ctx,
+		`SELECT COALESCE(polyline, ''), COALESCE("routeId", ''), status, charge,
+		 COALESCE("estimatedDistance", 0), COALESCE("estimatedDuration", 0), "startedAt", "completedAt"
+		 FROM rides WHERE id=$1`, rideID).
+		Scan(&polyline, &routeID, &status, &charge, &plannedMeters, &plannedSeconds, &startedAt, &completedAt)
+	if err != nil {
+		utils.RespondErrorCode(c, http.StatusNotFound, utils.CodeRideNotFound, "Ride not found", err)
+		return
+	}
+
+	polyline = ridePolyline(ctx, polyline, routeID)
+	planned := utils.DecodePolyline(polyline)
+	if planned == nil {
+		planned = [][2]float64{}
+	}
+	trace, source := loadRideTrace(ctx, rideID)
+
+	var trip [][2]float64
+	for i := range trace {
+		p := &trace[i]
+		p.Phase = tracePhasePickup
+		if startedAt != nil && !p.RecordedAt.Before(*startedAt) && (completedAt == nil || !p.RecordedAt.After(*completedAt)) {
+			p.Phase = tracePhaseTrip
+			trip = append(trip, [2]float64{p.Lat, p.Lng})
+		}
+	}
+
+	plannedKm := float64(plannedMeters) / 1000
+	if plannedKm == 0 {
+		plannedKm = utils.PathLength(planned)
+	}
+	actualKm := utils.PathLength(trip)
+	plannedMinutes := float64(plannedSeconds) / 60
+	var actualMinutes *float64
+	if startedAt != nil && completedAt != nil {
+		m := math.Round(completedAt.Sub(*startedAt).Minutes()*10) / 10
+		actualMinutes = &m
+	}
+
+	metrics := routeDeviation(planned, trip)
+	metrics["tripPoints"] = len(trip)
+	metrics["offRouteThresholdMeters"] = offRouteThresholdMeters()
+	metrics["plannedDistanceKm"] = math.Round(plannedKm*100) / 100
+	metrics["actualDistanceKm"] = math.Round(actualKm*100) / 100
+	metrics["distanceDeviationPercent"] = percentChange(plannedKm, actualKm)
+	metrics["plannedDurationMinutes"] = math.Round(plannedMinutes*10) / 10
+	metrics["actualDurationMinutes"] = actualMinutes
+	if actualMinutes != nil {
+		metrics["durationDeviationPercent"] = percentChange(plannedMinutes, *actualMinutes)
+	} else {
+		metrics["durationDeviationPercent"] = nil
+	}
+
+	utils.RespondSuccess(c, http.StatusOK, "Ride trace", gin.H{
+		"rideId":      rideID,
+		"status":      status,
+		"charge":      charge,
+		"startedAt":   startedAt,
+		"completedAt": completedAt,
+		"source":      source,
+		"points":      trace,
+		"planned": gin.H{
+			"polyline": polyline,
+			"points":   planned,
+		},
+		"metrics": metrics,
+	})
+}
@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"ridewave/repository"
+	"ridewave/rides/statemachine"
+	"ridewave/utils"
+)
+
+// respondTransitionError answers a failed statemachine.Transition. A ride the caller doesn't own
+// looks the same as a missing one (the apply's UPDATE matched nothing).
+func respondTransitionError(c *gin.Context, err error, failMsg string) {
+	var invalid *statemachine.TransitionError
+	switch {
+	case errors.Is(err, statemachine.ErrRideNotFound), errors.Is(err, repository.ErrNotFound), errors.Is(err, pgx.ErrNoRows):
+		utils.RespondErrorCode(c, http.StatusNotFound, utils.CodeRideNotFound, "Ride not found", err)
+	case errors.As(err, &invalid):
+		utils.RespondErrorDetails(c, http.StatusConflict, utils.CodeRideTransition,
+			fmt.Sprintf("Ride is %s and can't be moved to %s", invalid.From, invalid.To), gin.H{"from": invalid.From, "to": invalid.To}, err)
+	case errors.Is(err, statemachine.ErrRideBusy):
+		utils.RespondErrorCode(c, http.StatusConflict, utils.CodeRideBusy, "Ride is being updated. Please try again.", err)
+	default:
+		utils.RespondError(c, http.StatusInternalServerError, failMsg, err)
+	}
+}
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.uber.org/zap"
+	"ridewave/db"
+	"ridewave/models"
+	"ridewave/utils"
+)
+
+// ══════════════════════════════════════════════════
+// Saved Places — home, work & custom favourites
+// ══════════════════════════════════════════════════
+
+const maxSavedPlaces = 20
+
+const savedPlaceSelectCols = `id, "userId", label, name, address, lat, lng, "createdAt", "updatedAt"`
+
+func scanSavedPlace(scanner interface{ Scan(dest ...any) error }, p *models.SavedPlace) error {
+	return scanner.Scan(&p.ID, &p.UserID, &p.Label, &p.Name, &p.Address, &p.Lat, &p.Lng, &p.CreatedAt, &p.UpdatedAt)
+}
+
+type savedPlaceBody struct {
+	Label   string   `json:"label" binding:"required"`
+	Name    string   `json:"name"`
+	Address string   `json:"address"`
+	Lat     *float64 `json:"lat" binding:"required"`
+	Lng     *float64 `json:"lng" binding:"required"`
+}
+
+// normalize validates the body and fills in the address (and name) from a reverse geocode when missing.
+func (b *savedPlaceBody) normalize() string {
+	b.Label = strings.TrimSpace(b.Label)
+	if lower := strings.ToLower(b.Label); lower == "home" || lower == "work" {
+		b.Label = lower
+	}
+	if b.Label == "" || len(b.Label) > 40 {
+		return "Label must be 1-40 characters"
+	}
+	if *b.Lat < -90 || *b.Lat > 90 || *b.Lng < -180 || *b.Lng > 180 {
+		return "Invalid coordinates"
+	}
+
+	b.Address = strings.TrimSpace(b.Address)
+	if b.Address == "" {
+		address, err := utils.NewOlaMapsClient().ReverseGeocode(*b.Lat, *b.Lng)
+		if err != nil {
+			utils.Logger.Warn("Reverse geocode failed for saved place", zap.Error(err))
+		}
+		b.Address = address
+	}
+	b.Name = strings.TrimSpace(b.Name)
+	if b.Name == "" {
+		b.Name = b.Address
+	}
+	if b.Name == "" {
+		b.Name = b.Label
+	}
+	return ""
+}
+
+// GET /api/v1/user/places/saved
+func GetSavedPlaces(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+
+	// Home and work first, then custom places in the order they were saved
+	rows, err := db.Pool.Query(c.Request.Context(),
+		`SELECT `+savedPlaceSelectCols+` FROM saved_places WHERE "userId"=$1
+		 ORDER BY CASE label WHEN 'home' THEN 0 WHEN 'work' THEN 1 ELSE 2 END, "createdAt"`, user.ID)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to fetch saved places", err)
+		return
+	}
+	defer rows.Close()
+
+	places := []models.SavedPlace{}
+	for rows.Next() {
+		var p models.SavedPlace
+		if scanSavedPlace(rows, &p) == nil {
+			places = append(places, p)
+		}
+	}
+	utils.RespondSuccess(c, http.StatusOK, "Saved places", gin.H{"places": places})
+}
+
+// POST /api/v1/user/places/saved — saving home or work again replaces it
+func CreateSavedPlace(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+	var body savedPlaceBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+	if reason := body.normalize(); reason != "" {
+		utils.RespondError(c, http.StatusBadRequest, reason, nil)
+		return
+	}
+
+	var count int
+	db.Pool.QueryRow(c.Request.Context(), `SELECT COUNT(*) FROM saved_places WHERE "userId"=$1`, user.ID).Scan(&count)
+	if count >= maxSavedPlaces && body.Label != "home" && body.Label != "work" {
+		utils.RespondError(c, http.StatusUnprocessableEntity, "You can save up to 20 places", nil)
+		return
+	}
+
+	var p models.SavedPlace
+	err := scanSavedPlace(db.Pool.QueryRow(c.Request.Context(),
+		`INSERT INTO saved_places ("userId", label, name, address, lat, lng) VALUES ($1, $2, $3, $4, $5, $6)
+		 ON CONFLICT ("userId", label) WHERE label IN ('home', 'work')
+		 DO UPDATE SET name=EXCLUDED.name, address=EXCLUDED.address, lat=EXCLUDED.lat, lng=EXCLUDED.lng, "updatedAt"=NOW()
+		 RETURNING `+savedPlaceSelectCols,
+		user.ID, body.Label, body.Name, body.Address, *body.Lat, *body.Lng), &p)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to save place", err)
+		return
+	}
+	utils.RespondSuccess(c, http.StatusCreated, "Place saved", gin.H{"place": p})
+}
+
+// PUT /api/v1/user/places/saved/:id
+func UpdateSavedPlace(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+	var body savedPlaceBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+	if reason := body.normalize(); reason != "" {
+		utils.RespondError(c, http.StatusBadRequest, reason, nil)
+		return
+	}
+
+	var p models.SavedPlace
+	err := scanSavedPlace(db.Pool.QueryRow(c.Request.Context(),
+		`UPDATE saved_places SET label=$1, name=$2, address=$3, lat=$4, lng=$5, "updatedAt"=NOW()
+		 WHERE id=$6 AND "userId"=$7 RETURNING `+savedPlaceSelectCols,
+		body.Label, body.Name, body.Address, *body.Lat, *body.Lng, c.Param("id"), user.ID), &p)
+	if errors.Is(err, pgx.ErrNoRows) {
+		utils.RespondError(c, http.StatusNotFound, "Saved place not found", nil)
+		return
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		utils.RespondError(c, http.StatusConflict, "You already have a saved place labelled "+body.Label, nil)
+		return
+	}
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to update saved place", err)
+		return
+	}
+	utils.RespondSuccess(c, http.StatusOK, "Place updated", gin.H{"place": p})
+}
+
+// DELETE /api/v1/user/places/saved/:id
+func DeleteSavedPlace(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+	tag, err := db.Pool.Exec(c.Request.Context(),
+		`DELETE FROM saved_places WHERE id=$1 AND "userId"=$2`, c.Param("id"), user.ID)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to delete saved place", err)
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		utils.RespondError(c, http.StatusNotFound, "Saved place not found", nil)
+		return
+	}
+	utils.RespondSuccess(c, http.StatusOK, "Saved place deleted", nil)
+}
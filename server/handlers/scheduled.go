@@ -0,0 +1,431 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"ridewave/db"
+	"ridewave/events"
+	"ridewave/models"
+	"ridewave/stores"
+	"ridewave/utils"
+)
+
+// ══════════════════════════════════════════════════
+// Scheduled Rides — book-for-later & arrive-by bookings
+// ══════════════════════════════════════════════════
+
+// scheduledRideLead returns how long before pickup a scheduled booking is turned
+// into a live ride request. Configurable via SCHEDULED_RIDE_LEAD_MINUTES (default 15).
+func scheduledRideLead() time.Duration {
+	if val, err := strconv.Atoi(os.Getenv("SCHEDULED_RIDE_LEAD_MINUTES")); err == nil && val > 0 {
+		return time.Duration(val) * time.Minute
+	}
+	return 15 * time.Minute
+}
+
+// arriveByBuffer is the slack added on top of the trip ETA for driver arrival and traffic.
+// Configurable via ARRIVE_BY_BUFFER_MINUTES (default 10).
+func arriveByBuffer() time.Duration {
+	if val, err := strconv.Atoi(os.Getenv("ARRIVE_BY_BUFFER_MINUTES")); err == nil && val >= 0 {
+		return time.Duration(val) * time.Minute
+	}
+	return 10 * time.Minute
+}
+
+const scheduledRideSelectCols = `id, "userId", COALESCE("routeId", ''), "vehicleType", "originName", "destinationName",
+	"originLat", "originLng", "destinationLat", "destinationLng", fare, distance, duration, COALESCE("paymentMode", ''),
+	"pickupAt", "arriveBy", "dispatchAt", status, "rideId", "createdAt", "updatedAt"`
+
+func scanScheduledRide(scanner interface{ Scan(dest ...any) error }, s *models.ScheduledRide) error {
+	return scanner.Scan(&s.ID, &s.UserID, &s.RouteID, &s.VehicleType, &s.OriginName, &s.DestinationName,
+		&s.OriginLat, &s.OriginLng, &s.DestinationLat, &s.DestinationLng, &s.Fare, &s.Distance, &s.Duration, &s.PaymentMode,
+		&s.PickupAt, &s.ArriveBy, &s.DispatchAt, &s.Status, &s.RideID, &s.CreatedAt, &s.UpdatedAt)
+}
+
+// insertScheduledRide stores a future booking with the fare quoted at scheduling time.
+func insertScheduledRide(ctx context.Context, userID, routeID string, cached *stores.CachedRoute, paymentMode string, pickupAt time.Time, arriveBy *time.Time) (*models.ScheduledRide, error) {
+	dispatchAt := pickupAt.Add(-scheduledRideLead())
+	if dispatchAt.Before(time.Now()) {
+		dispatchAt = time.Now()
+	}
+
+	var sr models.ScheduledRide
+	row := db.Pool.QueryRow(ctx,
+		`INSERT INTO scheduled_rides (
+			id, "userId", "routeId", "vehicleType", "originName", "destinationName",
+			"originLat", "originLng", "destinationLat", "destinationLng", fare, distance, duration, "paymentMode",
+			"pickupAt", "arriveBy", "dispatchAt", status, "createdAt", "updatedAt"
+		) VALUES (
+			gen_random_uuid()::text, $1, $2, $3, $4, $5,
+			$6, $7, $8, $9, $10, $11, $12, NULLIF($13, ''),
+			$14, $15, $16, 'scheduled', NOW(), NOW()
+		) RETURNING `+scheduledRideSelectCols,
+		userID, routeID, cached.VehicleType, cached.OriginName, cached.DestinationName,
+		cached.OriginLat, cached.OriginLng, cached.DestinationLat, cached.DestinationLng, cached.Fare, cached.Distance, cached.Duration, paymentMode,
+		pickupAt, arriveBy, dispatchAt)
+	if err := scanScheduledRide(row, &sr); err != nil {
+		return nil, err
+	}
+	return &sr, nil
+}
+
+// scheduledRideMaxAhead caps how far in advance a ride can be booked.
+// Configurable via SCHEDULED_RIDE_MAX_DAYS (default 7).
+func scheduledRideMaxAhead() time.Duration {
+	if val, err := strconv.Atoi(os.Getenv("SCHEDULED_RIDE_MAX_DAYS")); err == nil && val > 0 {
+		return time.Duration(val) * 24 * time.Hour
+	}
+	return 7 * 24 * time.Hour
+}
+
+// POST /api/v1/user/ride/schedule — book a ride for a future pickup time
+func ScheduleRide(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+	var body struct {
+		RouteID     string `json:"routeId" binding:"required"`
+		PickupAt    string `json:"pickupAt" binding:"required"` // RFC3339
+		PaymentMode string `json:"paymentMode"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	pickupAt, err := time.Parse(time.RFC3339, body.PickupAt)
+	if err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "pickupAt must be an RFC3339 timestamp", err)
+		return
+	}
+
+	lead := scheduledRideLead()
+	if pickupAt.Before(time.Now().Add(lead)) {
+		utils.RespondError(c, http.StatusBadRequest,
+			fmt.Sprintf("Scheduled rides must be booked at least %d minutes in advance", int(lead.Minutes())), nil)
+		return
+	}
+	if pickupAt.After(time.Now().Add(scheduledRideMaxAhead())) {
+		utils.RespondError(c, http.StatusBadRequest, "Pickup time is too far in the future", nil)
+		return
+	}
+
+	// Fare is locked from the audited route estimate
+	cached, err := stores.GetPlannedRoute(c.Request.Context(), body.RouteID)
+	if err != nil {
+		utils.RespondErrorCode(c, http.StatusGone, utils.CodeRouteExpired, "This route has expired. Please get a fresh estimate.", err)
+		return
+	}
+
+	sr, err := insertScheduledRide(c.Request.Context(), user.ID, body.RouteID, cached, body.PaymentMode, pickupAt, nil)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to schedule ride", err)
+		return
+	}
+
+	utils.RespondSuccess(c, http.StatusCreated, "Ride scheduled", gin.H{"scheduledRide": sr})
+}
+
+// GET /api/v1/user/rides/scheduled?status=scheduled
+func GetScheduledRides(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+	statusFilter := c.Query("status")
+
+	query := `SELECT ` + scheduledRideSelectCols + ` FROM scheduled_rides WHERE "userId"=$1`
+	args := []interface{}{user.ID}
+	if statusFilter != "" {
+		query += ` AND status=$2`
+		args = append(args, statusFilter)
+	}
+	query += ` ORDER BY "pickupAt" ASC`
+
+	rows, err := db.Pool.Query(c.Request.Context(), query, args...)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to fetch scheduled rides", err)
+		return
+	}
+	defer rows.Close()
+
+	var scheduled []models.ScheduledRide
+	for rows.Next() {
+		var sr models.ScheduledRide
+		scanScheduledRide(rows, &sr)
+		scheduled = append(scheduled, sr)
+	}
+	if scheduled == nil {
+		scheduled = []models.ScheduledRide{}
+	}
+	utils.RespondSuccess(c, http.StatusOK, "Scheduled rides", gin.H{"scheduledRides": scheduled})
+}
+
+// POST /api/v1/user/ride/scheduled/:id/cancel
+func CancelScheduledRide(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+	id := c.Param("id")
+
+	// Only bookings that haven't been dispatched yet can be cancelled here;
+	// once live, the regular /ride/cancel flow applies.
+	tag, err := db.Pool.Exec(c.Request.Context(),
+		`UPDATE scheduled_rides SET status='cancelled', "updatedAt"=NOW() WHERE id=$1 AND "userId"=$2 AND status='scheduled'`,
+		id, user.ID)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to cancel scheduled ride", err)
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		utils.RespondError(c, http.StatusNotFound, "Scheduled ride not found or already dispatched", nil)
+		return
+	}
+
+	utils.RespondSuccess(c, http.StatusOK, "Scheduled ride cancelled", gin.H{"id": id})
+}
+
+// POST /api/v1/user/ride/arrive-by — book so the rider reaches the destination by a target time
+func CreateArriveByRide(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+	var body struct {
+		Origin      string `json:"origin" binding:"required"`      // "lat,lng"
+		Destination string `json:"destination" binding:"required"` // "lat,lng"
+		VehicleType string `json:"vehicleType" binding:"required"`
+		ArriveBy    string `json:"arriveBy" binding:"required"` // RFC3339
+		PaymentMode string `json:"paymentMode"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	arriveBy, err := time.Parse(time.RFC3339, body.ArriveBy)
+	if err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "arriveBy must be an RFC3339 timestamp", err)
+		return
+	}
+
+	routeID, cached, err := planRoute(c.Request.Context(), body.Origin, body.Destination, body.VehicleType)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to calculate route", err)
+		return
+	}
+
+	// Work backwards from the target: trip ETA plus a buffer for pickup and traffic
+	tripDuration := time.Duration(cached.Duration) * time.Second
+	buffer := arriveByBuffer()
+	pickupAt := arriveBy.Add(-tripDuration - buffer)
+
+	if pickupAt.Before(time.Now()) {
+		earliestArrival := time.Now().Add(buffer + tripDuration)
+		utils.RespondSuccess(c, http.StatusOK, "Target arrival time is not achievable", gin.H{
+			"achievable":      false,
+			"arriveBy":        arriveBy,
+			"earliestArrival": earliestArrival,
+			"tripDuration":    fmt.Sprintf("%d mins", int(tripDuration.Minutes())),
+			"fare":            cached.Fare,
+			"routeId":         routeID,
+		})
+		return
+	}
+	if pickupAt.After(time.Now().Add(scheduledRideMaxAhead())) {
+		utils.RespondError(c, http.StatusBadRequest, "Pickup time is too far in the future", nil)
+		return
+	}
+
+	sr, err := insertScheduledRide(c.Request.Context(), user.ID, routeID, cached, body.PaymentMode, pickupAt, &arriveBy)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to schedule ride", err)
+		return
+	}
+
+	utils.RespondSuccess(c, http.StatusCreated, "Ride scheduled", gin.H{
+		"achievable":    true,
+		"scheduledRide": sr,
+		"tripDuration":  fmt.Sprintf("%d mins", int(tripDuration.Minutes())),
+	})
+}
+
+// ══════════════════════════════════════════════════
+// Scheduled Ride Dispatcher — background worker
+// ══════════════════════════════════════════════════
+
+// StartScheduledRideWorker converts due scheduled bookings into live ride requests.
+// Runs every minute until ctx is cancelled.
+func StartScheduledRideWorker(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				dispatchDueScheduledRides()
+			case <-ctx.Done():
+				utils.Logger.Info("Scheduled Ride Worker shutting down...")
+				return
+			}
+		}
+	}()
+}
+
+// scheduledDispatchTimeout is how long a booking can sit in 'dispatching' before it's taken to
+// have been abandoned by an instance that crashed mid-dispatch.
+const scheduledDispatchTimeout = 5 * time.Minute
+
+// scheduledRideMaxLate is how long past its pickup time a booking is still worth dispatching
+// after a retry; later than that, the rider has most likely made other plans.
+const scheduledRideMaxLate = 30 * time.Minute
+
+func dispatchDueScheduledRides() {
+	ctx := db.Unscoped()
+	recoverStuckScheduledRides(ctx)
+
+	// Claim due rows atomically so multiple instances never dispatch the same booking
+	rows, err := db.Pool.Query(ctx,
+		`UPDATE scheduled_rides SET status='dispatching', "updatedAt"=NOW()
+		 WHERE id IN (
+			SELECT id FROM scheduled_rides WHERE status='scheduled' AND "dispatchAt" <= NOW()
+			ORDER BY "dispatchAt" ASC LIMIT 50 FOR UPDATE SKIP LOCKED
+		 ) RETURNING `+scheduledRideSelectCols)
+	if err != nil {
+		utils.Logger.Error("Failed to claim scheduled rides", zap.Error(err))
+		return
+	}
+
+	var due []models.ScheduledRide
+	for rows.Next() {
+		var sr models.ScheduledRide
+		if err := scanScheduledRide(rows, &sr); err == nil {
+			due = append(due, sr)
+		}
+	}
+	rows.Close()
+
+	for _, sr := range due {
+		cached := &stores.CachedRoute{
+			Distance:        sr.Distance,
+			Duration:        sr.Duration,
+			Fare:            sr.Fare,
+			VehicleType:     sr.VehicleType,
+			OriginName:      sr.OriginName,
+			DestinationName: sr.DestinationName,
+			OriginLat:       sr.OriginLat,
+			OriginLng:       sr.OriginLng,
+			DestinationLat:  sr.DestinationLat,
+			DestinationLng:  sr.DestinationLng,
+		}
+
+		rideID, err := dispatchScheduledRide(ctx, &sr, cached)
+		if errors.Is(err, errScheduledRideReclaimed) {
+			// Recovery requeued or failed the booking meanwhile; it owns the booking now
+			utils.Logger.Warn("Scheduled ride reclaimed before dispatch finished", zap.String("scheduledRideId", sr.ID))
+			continue
+		}
+		if err != nil {
+			utils.Logger.Error("Failed to dispatch scheduled ride", zap.String("scheduledRideId", sr.ID), zap.Error(err))
+			failScheduledRide(ctx, &sr)
+			continue
+		}
+
+		publishRideEvent(rideID, events.RideRequested, events.ActorSystem, "", map[string]any{
+			"vehicleType": sr.VehicleType, "fare": sr.Fare, "scheduledRideId": sr.ID})
+
+		dispatchRideRequest(rideID, &models.User{ID: sr.UserID}, cached)
+
+		// Let the rider know we're now looking for a driver
+		sendNotifications(ctx, outboxPush("user", sr.UserID, rideID, "Finding your driver 🔍",
+			fmt.Sprintf("Your scheduled ride to %s is now being dispatched.", sr.DestinationName), utils.FCMData{
+				"type":            "scheduled_ride_dispatched",
+				"rideId":          rideID,
+				"scheduledRideId": sr.ID,
+			}))
+		utils.Logger.Info("Scheduled ride dispatched", zap.String("scheduledRideId", sr.ID), zap.String("rideId", rideID))
+	}
+}
+
+// dispatchScheduledRide creates the live ride and marks the booking dispatched in one
+// transaction, so a booking left in 'dispatching' never has a ride and is safe to retry.
+func dispatchScheduledRide(ctx context.Context, sr *models.ScheduledRide, cached *stores.CachedRoute) (string, error) {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback(ctx)
+
+	rideID, err := repos.Rides.CreateTx(ctx, tx, newRide(sr.UserID, sr.RouteID, cached, sr.PaymentMode))
+	if err != nil {
+		return "", err
+	}
+	tag, err := tx.Exec(ctx,
+		`UPDATE scheduled_rides SET status='dispatched', "rideId"=$1, "updatedAt"=NOW() WHERE id=$2 AND status='dispatching'`,
+		rideID, sr.ID)
+	if err != nil {
+		return "", err
+	}
+	if tag.RowsAffected() == 0 {
+		// Rolled back with the ride, so the booking never gets two
+		return "", errScheduledRideReclaimed
+	}
+	return rideID, tx.Commit(ctx)
+}
+
+var errScheduledRideReclaimed = errors.New("scheduled ride no longer dispatching")
+
+// recoverStuckScheduledRides handles bookings an instance claimed but never finished, e.g. because
+// it crashed. Ones still within scheduledRideMaxLate of pickup go back to 'scheduled' and are
+// dispatched on this run; the rest fail and the rider is told.
+func recoverStuckScheduledRides(ctx context.Context) {
+	const stuck = `status='dispatching' AND "updatedAt" < NOW() - make_interval(secs => $1)`
+
+	tag, err := db.Pool.Exec(ctx,
+		`UPDATE scheduled_rides SET status='scheduled', "updatedAt"=NOW()
+		 WHERE `+stuck+` AND "pickupAt" > NOW() - make_interval(secs => $2)`,
+		scheduledDispatchTimeout.Seconds(), scheduledRideMaxLate.Seconds())
+	if err != nil {
+		utils.Logger.Error("Failed to requeue stuck scheduled rides", zap.Error(err))
+		return
+	}
+	if tag.RowsAffected() > 0 {
+		utils.Logger.Warn("Requeued scheduled rides stuck in dispatching", zap.Int64("count", tag.RowsAffected()))
+	}
+
+	rows, err := db.Pool.Query(ctx,
+		`SELECT `+scheduledRideSelectCols+` FROM scheduled_rides WHERE `+stuck, scheduledDispatchTimeout.Seconds())
+	if err != nil {
+		utils.Logger.Error("Failed to load stuck scheduled rides", zap.Error(err))
+		return
+	}
+	var late []models.ScheduledRide
+	for rows.Next() {
+		var sr models.ScheduledRide
+		if scanScheduledRide(rows, &sr) == nil {
+			late = append(late, sr)
+		}
+	}
+	rows.Close()
+	for _, sr := range late {
+		utils.Logger.Warn("Scheduled ride stuck in dispatching past its pickup time", zap.String("scheduledRideId", sr.ID))
+		failScheduledRide(ctx, &sr)
+	}
+}
+
+// failScheduledRide marks a claimed booking failed and tells the rider, so it doesn't go quiet.
+func failScheduledRide(ctx context.Context, sr *models.ScheduledRide) {
+	tag, err := db.Pool.Exec(ctx,
+		`UPDATE scheduled_rides SET status='failed', "updatedAt"=NOW() WHERE id=$1 AND status='dispatching'`, sr.ID)
+	if err != nil {
+		utils.Logger.Error("Failed to mark scheduled ride failed", zap.String("scheduledRideId", sr.ID), zap.Error(err))
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		return
+	}
+	sendNotifications(ctx, outboxPush("user", sr.UserID, "", "Scheduled ride not booked",
+		fmt.Sprintf("We couldn't book your scheduled ride to %s. Please book it again.", sr.DestinationName), utils.FCMData{
+			"type":            "scheduled_ride_failed",
+			"scheduledRideId": sr.ID,
+		}))
+}
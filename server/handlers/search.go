@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"ridewave/db"
+	"ridewave/middleware"
+	"ridewave/models"
+	"ridewave/utils"
+)
+
+// ══════════════════════════════════════════════════
+// Admin: Global Search — one query box across users, drivers, rides, payments and SOS
+// ══════════════════════════════════════════════════
+
+const (
+	minSearchLength     = 3 // trigram indexes need at least three characters
+	defaultSearchLimit  = 5
+	maxSearchGroupLimit = 20
+)
+
+type userSearchResult struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Phone  string `json:"phone"`
+	Email  string `json:"email"`
+	Status string `json:"status"`
+}
+
+type driverSearchResult struct {
+	ID                 string `json:"id"`
+	Name               string `json:"name"`
+	Phone              string `json:"phone"`
+	RegistrationNumber string `json:"registrationNumber"`
+	VehicleType        string `json:"vehicleType"`
+	Status             string `json:"status"`
+	IsOnline           bool   `json:"isOnline"`
+}
+
+type rideSearchResult struct {
+	ID          string    `json:"id"`
+	Status      string    `json:"status"`
+	UserID      string    `json:"userId"`
+	DriverID    *string   `json:"driverId"`
+	Origin      string    `json:"origin"`
+	Destination string    `json:"destination"`
+	Charge      float64   `json:"charge"`
+	PromoCode   string    `json:"promoCode"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+type sosSearchResult struct {
+	ID        string    `json:"id"`
+	RideID    string    `json:"rideId"`
+	UserID    string    `json:"userId"`
+	UserName  string    `json:"userName"`
+	UserPhone string    `json:"userPhone"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type promoSearchResult struct {
+	ID         string     `json:"id"`
+	Code       string     `json:"code"`
+	IsActive   bool       `json:"isActive"`
+	UsedCount  int        `json:"usedCount"`
+	UsageLimit int        `json:"usageLimit"`
+	ExpiresAt  *time.Time `json:"expiresAt"`
+}
+
+// searchGroup runs one group's query and scans every row.
+func searchGroup[T any](ctx context.Context, scan func(pgx.Rows, *T) error, query string, args ...any) ([]T, error) {
+	rows, err := db.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []T{}
+	for rows.Next() {
+		var r T
+		if err := scan(rows, &r); err != nil {
+			return nil, err
+		}
+		results = append(results, r)
+	}
+	return results, rows.Err()
+}
+
+// GET /api/v1/admin/search?q=&limit=5 — matches phone numbers, names, ride/payment ID prefixes,
+// registration plates and promo codes. Payments and promo codes need the finance role, SOS
+// alerts the support role.
+func AdminGlobalSearch(c *gin.Context) {
+	q := strings.TrimSpace(c.Query("q"))
+	if len([]rune(q)) < minSearchLength {
+		utils.RespondError(c, http.StatusBadRequest, "Search needs at least 3 characters", nil)
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultSearchLimit)))
+	if err != nil || limit < 1 {
+		limit = defaultSearchLimit
+	}
+	if limit > maxSearchGroupLimit {
+		limit = maxSearchGroupLimit
+	}
+
+	ctx := adminContext(c)
+	contains, prefix := "%"+q+"%", q+"%"
+	resp := gin.H{"query": q}
+
+	users, err := searchGroup(ctx, func(rows pgx.Rows, u *userSearchResult) error {
+		return rows.Scan(&u.ID, &u.Name, &u.Phone, &u.Email, &u.Status)
+	},
+		`SELECT id, COALESCE(name, ''), phone_number, COALESCE(email, ''), status FROM "user"
+		 WHERE id=$2 OR name ILIKE $1 OR phone_number ILIKE $1
+		 ORDER BY GREATEST(similarity(COALESCE(name, ''), $2), similarity(phone_number, $2)) DESC, "createdAt" DESC
+		 LIMIT $3`, contains, q, limit)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Search failed", err)
+		return
+	}
+	resp["users"] = users
+
+	drivers, err := searchGroup(ctx, func(rows pgx.Rows, d *driverSearchResult) error {
+		return rows.Scan(&d.ID, &d.Name, &d.Phone, &d.RegistrationNumber, &d.VehicleType, &d.Status, &d.IsOnline)
+	},
+		`SELECT id, name, phone_number, registration_number, vehicle_type, status, "isOnline" FROM driver
+		 WHERE id=$2 OR name ILIKE $1 OR phone_number ILIKE $1 OR registration_number ILIKE $1
+		 ORDER BY GREATEST(similarity(name, $2), similarity(phone_number, $2), similarity(registration_number, $2)) DESC, "createdAt" DESC
+		 LIMIT $3`, contains, q, limit)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Search failed", err)
+		return
+	}
+	resp["drivers"] = drivers
+
+	// Rides match on their own ID or promo code, or on the rider's phone or driver's phone/plate
+	rides, err := searchGroup(ctx, func(rows pgx.Rows, r *rideSearchResult) error {
+		return rows.Scan(&r.ID, &r.Status, &r.UserID, &r.DriverID, &r.Origin, &r.Destination, &r.Charge, &r.PromoCode, &r.CreatedAt)
+	},
+		`SELECT id, status, "userId", "driverId", "currentLocationName", "destinationLocationName", charge,
+		 COALESCE("promoCode", ''), "createdAt" FROM rides
+		 WHERE id ILIKE $2 OR "promoCode" ILIKE $3
+		 OR "userId" IN (SELECT id FROM "user" WHERE phone_number ILIKE $1)
+		 OR "driverId" IN (SELECT id FROM driver WHERE phone_number ILIKE $1 OR registration_number ILIKE $1)
+		 ORDER BY "createdAt" DESC LIMIT $4`, contains, prefix, q, limit)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Search failed", err)
+		return
+	}
+	resp["rides"] = rides
+
+	if middleware.HasAdminRole(c, middleware.RoleFinance) {
+		payments, err := searchGroup(ctx, func(rows pgx.Rows, p *models.Payment) error {
+			return rows.Scan(&p.ID, &p.RideID, &p.Amount, &p.Currency, &p.Mode, &p.Status, &p.CreatedAt)
+		},
+			`SELECT id, "rideId", amount, currency, mode, status, "createdAt" FROM payments
+			 WHERE id=$3 OR "rideId" ILIKE $2
+			 OR "rideId" IN (SELECT r.id FROM rides r JOIN "user" u ON u.id=r."userId" WHERE u.phone_number ILIKE $1)
+			 ORDER BY "createdAt" DESC LIMIT $4`, contains, prefix, q, limit)
+		if err != nil {
+			utils.RespondError(c, http.StatusInternalServerError, "Search failed", err)
+			return
+		}
+		resp["payments"] = payments
+
+		promoCodes, err := searchGroup(ctx, func(rows pgx.Rows, p *promoSearchResult) error {
+			return rows.Scan(&p.ID, &p.Code, &p.IsActive, &p.UsedCount, &p.UsageLimit, &p.ExpiresAt)
+		},
+			`SELECT id, code, "isActive", "usedCount", "usageLimit", "expiresAt" FROM promo_codes
+			 WHERE code ILIKE $1 ORDER BY similarity(code, $2) DESC LIMIT $3`, contains, q, limit)
+		if err != nil {
+			utils.RespondError(c, http.StatusInternalServerError, "Search failed", err)
+			return
+		}
+		resp["promoCodes"] = promoCodes
+	}
+
+	if middleware.HasAdminRole(c, middleware.RoleSupport) {
+		alerts, err := searchGroup(ctx, func(rows pgx.Rows, a *sosSearchResult) error {
+			return rows.Scan(&a.ID, &a.RideID, &a.UserID, &a.UserName, &a.UserPhone, &a.Status, &a.CreatedAt)
+		},
+			`SELECT s.id, COALESCE(s."rideId", ''), s."userId", COALESCE(u.name, ''), COALESCE(u.phone_number, ''), s.status, s."createdAt"
+			 FROM sos_alerts s
+			 LEFT JOIN "user" u ON u.id=s."userId"
+			 WHERE s.id=$3 OR s."rideId" ILIKE $2 OR u.phone_number ILIKE $1 OR u.name ILIKE $1
+			 ORDER BY s."createdAt" DESC LIMIT $4`, contains, prefix, q, limit)
+		if err != nil {
+			utils.RespondError(c, http.StatusInternalServerError, "Search failed", err)
+			return
+		}
+		resp["sosAlerts"] = alerts
+	}
+
+	utils.RespondSuccess(c, http.StatusOK, "Search results", resp)
+}
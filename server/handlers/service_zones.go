@@ -0,0 +1,405 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+	"ridewave/db"
+	"ridewave/models"
+	"ridewave/rides/fares"
+	"ridewave/stores"
+	"ridewave/utils"
+)
+
+// ══════════════════════════════════════════════════
+// Service Zones — stored in the DB, reloaded on every instance without a restart
+// ══════════════════════════════════════════════════
+
+var (
+	serviceZonesMu sync.RWMutex
+	serviceZones   []models.ServiceZone // active zones in match order; replaced wholesale, never mutated
+)
+
+// activeServiceZones returns the current zone snapshot. Callers must not modify it.
+func activeServiceZones() []models.ServiceZone {
+	serviceZonesMu.RLock()
+	defer serviceZonesMu.RUnlock()
+	return serviceZones
+}
+
+func setServiceZones(zones []models.ServiceZone) {
+	serviceZonesMu.Lock()
+	serviceZones = zones
+	serviceZonesMu.Unlock()
+}
+
+// findServiceZone looks up an active zone by name (case-insensitive).
+func findServiceZone(name string) *models.ServiceZone {
+	zones := activeServiceZones()
+	for i := range zones {
+		if strings.EqualFold(zones[i].Name, name) {
+			return &zones[i]
+		}
+	}
+	return nil
+}
+
+// zoneContains checks the zone's polygon when it has one, else its radius.
+func zoneContains(zone models.ServiceZone, lat, lng float64) bool {
+	if len(zone.Polygon) >= 3 {
+		return utils.PointInPolygon(lat, lng, zone.Polygon)
+	}
+	return utils.CalculateDistance(lat, lng, zone.Lat, zone.Lng) <= zone.Radius
+}
+
+// currencyAt is the currency and rounding for a ride picked up at a point: the deployment's
+// (FARE_CURRENCY and friends) with the zone's overrides on top.
+func currencyAt(lat, lng float64) fares.Currency {
+	c := fares.LoadCurrency()
+	if zone := findServiceZone(zoneForPoint(lat, lng)); zone != nil {
+		c = c.WithOverrides(zone.Currency, zone.FareRounding, zone.FareRoundTo)
+	}
+	return c
+}
+
+// formatMoney shows an amount in a stored currency code for notifications, e.g. "₹120"; an empty
+// code is the deployment's currency.
+func formatMoney(code string, amount float64) string {
+	return fares.LoadCurrency().ForCode(code).Format(amount)
+}
+
+// parseServiceZonesEnv reads SERVICE_ZONES (Format: Name:Lat:Lng:RadiusKM[:beta];...), which
+// seeds an empty service_zones table and is the fallback if the DB can't be read at startup.
+func parseServiceZonesEnv() []models.ServiceZone {
+	zonesEnv := os.Getenv("SERVICE_ZONES")
+	if zonesEnv == "" {
+		zonesEnv = "New Delhi:28.6139:77.2090:50;Chennai:13.0827:80.2707:50;Bengaluru:12.9716:77.5946:50;Madurai:9.9252:78.1198:50"
+	}
+
+	var zones []models.ServiceZone
+	for i, zone := range strings.Split(zonesEnv, ";") {
+		parts := strings.Split(zone, ":")
+		if len(parts) < 4 {
+			continue
+		}
+		zLat, _ := strconv.ParseFloat(parts[1], 64)
+		zLng, _ := strconv.ParseFloat(parts[2], 64)
+		radius, _ := strconv.ParseFloat(parts[3], 64)
+
+		// New cities can ship in beta so only allowlisted riders can book until launch
+		launchMode := zoneModeLive
+		if len(parts) >= 5 && parts[4] == zoneModeBeta {
+			launchMode = zoneModeBeta
+		}
+
+		zones = append(zones, models.ServiceZone{
+			Name:       parts[0],
+			Lat:        zLat,
+			Lng:        zLng,
+			Radius:     radius,
+			LaunchMode: launchMode,
+			SortOrder:  i,
+			IsActive:   true,
+		})
+	}
+	return zones
+}
+
+const serviceZoneSelectCols = `name, lat, lng, radius, polygon, "launchMode", "geofenceId", "sortOrder", "isActive", "updatedAt",
+	currency, "fareRounding", "fareRoundTo"`
+
+func scanServiceZone(scanner interface{ Scan(dest ...any) error }, z *models.ServiceZone) error {
+	var polygon []byte
+	if err := scanner.Scan(&z.Name, &z.Lat, &z.Lng, &z.Radius, &polygon, &z.LaunchMode, &z.GeofenceID,
+		&z.SortOrder, &z.IsActive, &z.UpdatedAt, &z.Currency, &z.FareRounding, &z.FareRoundTo); err != nil {
+		return err
+	}
+	z.Polygon = nil
+	if len(polygon) > 0 {
+		json.Unmarshal(polygon, &z.Polygon)
+	}
+	return nil
+}
+
+// queryServiceZones lists zones in match order, optionally including deactivated ones.
+func queryServiceZones(ctx context.Context, includeInactive bool) ([]models.ServiceZone, error) {
+	rows, err := db.Pool.Query(ctx,
+		`SELECT `+serviceZoneSelectCols+` FROM service_zones WHERE "isActive" OR $1 ORDER BY "sortOrder", name`,
+		includeInactive)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	zones := []models.ServiceZone{}
+	for rows.Next() {
+		var z models.ServiceZone
+		if err := scanServiceZone(rows, &z); err != nil {
+			return nil, err
+		}
+		zones = append(zones, z)
+	}
+	return zones, rows.Err()
+}
+
+// ReloadServiceZones swaps in the active zones from the database.
+func ReloadServiceZones(ctx context.Context) error {
+	zones, err := queryServiceZones(ctx, false)
+	if err != nil {
+		return err
+	}
+	setServiceZones(zones)
+	return nil
+}
+
+// LoadServiceZones seeds service_zones from SERVICE_ZONES on first boot, carrying over any
+// launch mode overrides, then loads it. If the DB can't be read the env zones are served.
+func LoadServiceZones() {
+	ctx := context.Background()
+	var count int
+	if err := db.Pool.QueryRow(ctx, `SELECT COUNT(*) FROM service_zones`).Scan(&count); err == nil && count == 0 {
+		for _, z := range parseServiceZonesEnv() {
+			_, err := db.Pool.Exec(ctx,
+				`INSERT INTO service_zones (name, lat, lng, radius, "launchMode", "sortOrder")
+				 VALUES ($1, $2, $3, $4, COALESCE((SELECT mode FROM zone_launch_modes WHERE zone=$1), $5), $6)
+				 ON CONFLICT (name) DO NOTHING`, z.Name, z.Lat, z.Lng, z.Radius, z.LaunchMode, z.SortOrder)
+			if err != nil {
+				utils.Logger.Error("Failed to seed service zone", zap.String("zone", z.Name), zap.Error(err))
+			}
+		}
+		utils.Logger.Info("Seeded service zones from SERVICE_ZONES")
+	}
+
+	if err := ReloadServiceZones(ctx); err != nil {
+		utils.Logger.Error("Failed to load service zones, using SERVICE_ZONES", zap.Error(err))
+		setServiceZones(parseServiceZonesEnv())
+	}
+}
+
+// StartServiceZoneWorker reloads zones whenever any instance changes them, and on an interval
+// (ZONE_RELOAD_INTERVAL_SECONDS, default 300) to pick up direct DB edits or a missed message.
+func StartServiceZoneWorker(ctx context.Context) {
+	interval := 5 * time.Minute
+	if val, err := strconv.Atoi(os.Getenv("ZONE_RELOAD_INTERVAL_SECONDS")); err == nil && val >= 10 {
+		interval = time.Duration(val) * time.Second
+	}
+
+	go func() {
+		pubsub := stores.SubscribeToServiceZoneChanges(ctx)
+		defer pubsub.Close()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		ch := pubsub.Channel()
+		for {
+			select {
+			case <-ch:
+			case <-ticker.C:
+			case <-ctx.Done():
+				utils.Logger.Info("Service Zone Worker shutting down...")
+				return
+			}
+			if err := ReloadServiceZones(context.Background()); err != nil {
+				utils.Logger.Error("Failed to reload service zones", zap.Error(err))
+			}
+		}
+	}()
+}
+
+// serviceZonesChanged reloads this instance right away and tells the others to follow.
+func serviceZonesChanged(ctx context.Context) {
+	if err := ReloadServiceZones(ctx); err != nil {
+		utils.Logger.Error("Failed to reload service zones", zap.Error(err))
+	}
+	if err := stores.PublishServiceZonesChanged(ctx); err != nil {
+		utils.Logger.Warn("Failed to broadcast service zone change", zap.Error(err))
+	}
+}
+
+// syncZoneGeofence mirrors the zone to an Ola Maps geofence when OLA_GEOFENCE_PROJECT_ID is set,
+// so it shows up alongside the project's other geofences. Booking checks stay local.
+func syncZoneGeofence(ctx context.Context, zone *models.ServiceZone) {
+	projectID := os.Getenv("OLA_GEOFENCE_PROJECT_ID")
+	if projectID == "" {
+		return
+	}
+	olaClient := utils.NewOlaMapsClient().WithContext(ctx)
+
+	if !zone.IsActive {
+		if zone.GeofenceID != nil {
+			if err := olaClient.DeleteGeofence(*zone.GeofenceID); err != nil {
+				utils.Logger.Warn("Failed to delete zone geofence", zap.String("zone", zone.Name), zap.Error(err))
+				return
+			}
+			db.Pool.Exec(ctx, `UPDATE service_zones SET "geofenceId"=NULL WHERE name=$1`, zone.Name)
+		}
+		return
+	}
+
+	req := utils.GeofenceCreateRequest{
+		Name:      "RideWave zone: " + zone.Name,
+		Type:      "circle",
+		Radius:    zone.Radius * 1000, // meters
+		Status:    "active",
+		ProjectId: projectID,
+	}
+	if len(zone.Polygon) >= 3 {
+		req.Type, req.Radius = "polygon", 0
+		for _, p := range zone.Polygon {
+			req.Coordinates = append(req.Coordinates, []float64{p[0], p[1]})
+		}
+	} else {
+		req.Coordinates = [][]float64{{zone.Lat, zone.Lng}}
+	}
+
+	var resp *utils.GeofenceResponse
+	var err error
+	if zone.GeofenceID != nil {
+		resp, err = olaClient.UpdateGeofence(*zone.GeofenceID, req)
+	} else {
+		resp, err = olaClient.CreateGeofence(req)
+	}
+	if err != nil {
+		utils.Logger.Warn("Failed to sync zone geofence", zap.String("zone", zone.Name), zap.Error(err))
+		return
+	}
+	if zone.GeofenceID == nil && resp.GeofenceId != "" {
+		db.Pool.Exec(ctx, `UPDATE service_zones SET "geofenceId"=$2 WHERE name=$1`, zone.Name, resp.GeofenceId)
+	}
+}
+
+// ══════════════════════════════════════════════════
+// Admin: Service Zone CRUD
+// ══════════════════════════════════════════════════
+
+// PUT /api/v1/admin/zone
+func AdminUpsertZone(c *gin.Context) {
+	var body struct {
+		Name       string       `json:"name" binding:"required"`
+		Lat        float64      `json:"lat"`
+		Lng        float64      `json:"lng"`
+		Radius     float64      `json:"radius"`  // KM, for circle zones
+		Polygon    [][2]float64 `json:"polygon"` // [[lat, lng], ...], at least 3 points
+		LaunchMode string       `json:"launchMode"`
+		SortOrder  int          `json:"sortOrder"`
+		IsActive   *bool        `json:"isActive"`
+
+		// Pricing overrides; leave out to use the deployment's
+		Currency     *string  `json:"currency"`
+		FareRounding *string  `json:"fareRounding"`
+		FareRoundTo  *float64 `json:"fareRoundTo"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid request", err)
+		return
+	}
+	body.Name = strings.TrimSpace(body.Name)
+	if body.Name == "" {
+		utils.RespondError(c, http.StatusBadRequest, "Name is required", nil)
+		return
+	}
+	if body.LaunchMode == "" {
+		body.LaunchMode = zoneModeLive
+	}
+	if body.LaunchMode != zoneModeLive && body.LaunchMode != zoneModeBeta {
+		utils.RespondError(c, http.StatusBadRequest, "launchMode must be 'live' or 'beta'", nil)
+		return
+	}
+	if len(body.Polygon) > 0 && len(body.Polygon) < 3 {
+		utils.RespondError(c, http.StatusBadRequest, "A polygon needs at least 3 points", nil)
+		return
+	}
+	if len(body.Polygon) == 0 && body.Radius <= 0 {
+		utils.RespondError(c, http.StatusBadRequest, "Either a radius or a polygon is required", nil)
+		return
+	}
+	if body.Currency != nil {
+		code := strings.ToUpper(strings.TrimSpace(*body.Currency))
+		if len(code) != 3 {
+			utils.RespondError(c, http.StatusBadRequest, "currency must be a 3-letter ISO 4217 code", nil)
+			return
+		}
+		body.Currency = &code
+	}
+	if body.FareRounding != nil && !fares.ValidRounding(*body.FareRounding) {
+		utils.RespondError(c, http.StatusBadRequest, "fareRounding must be 'up', 'nearest' or 'down'", nil)
+		return
+	}
+	if body.FareRoundTo != nil && *body.FareRoundTo <= 0 {
+		utils.RespondError(c, http.StatusBadRequest, "fareRoundTo must be more than 0", nil)
+		return
+	}
+
+	var polygon []byte
+	if len(body.Polygon) > 0 {
+		// The center defaults to the polygon's vertex average
+		if body.Lat == 0 && body.Lng == 0 {
+			for _, p := range body.Polygon {
+				body.Lat += p[0] / float64(len(body.Polygon))
+				body.Lng += p[1] / float64(len(body.Polygon))
+			}
+		}
+		polygon, _ = json.Marshal(body.Polygon)
+	}
+	isActive := body.IsActive == nil || *body.IsActive
+
+	ctx := adminContext(c)
+	var zone models.ServiceZone
+	err := scanServiceZone(db.Pool.QueryRow(ctx,
+		`INSERT INTO service_zones (name, lat, lng, radius, polygon, "launchMode", "sortOrder", "isActive",
+		   currency, "fareRounding", "fareRoundTo")
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		 ON CONFLICT (name) DO UPDATE SET lat=EXCLUDED.lat, lng=EXCLUDED.lng, radius=EXCLUDED.radius,
+		   polygon=EXCLUDED.polygon, "launchMode"=EXCLUDED."launchMode", "sortOrder"=EXCLUDED."sortOrder",
+		   "isActive"=EXCLUDED."isActive", currency=EXCLUDED.currency, "fareRounding"=EXCLUDED."fareRounding",
+		   "fareRoundTo"=EXCLUDED."fareRoundTo", "updatedAt"=NOW()
+		 RETURNING `+serviceZoneSelectCols,
+		body.Name, body.Lat, body.Lng, body.Radius, polygon, body.LaunchMode, body.SortOrder, isActive,
+		body.Currency, body.FareRounding, body.FareRoundTo), &zone)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to save zone", err)
+		return
+	}
+
+	serviceZonesChanged(ctx)
+	utils.SafeGo(func() { syncZoneGeofence(context.Background(), &zone) })
+	utils.RespondSuccess(c, http.StatusOK, "Zone saved", gin.H{"zone": zone})
+}
+
+// DELETE /api/v1/admin/zone/:name
+// Zones are deactivated rather than deleted so allowlists and vehicle/tenant zone lists keep resolving.
+func AdminDeactivateZone(c *gin.Context) {
+	ctx := adminContext(c)
+	var zone models.ServiceZone
+	err := scanServiceZone(db.Pool.QueryRow(ctx,
+		`UPDATE service_zones SET "isActive"=FALSE, "updatedAt"=NOW() WHERE LOWER(name)=LOWER($1)
+		 RETURNING `+serviceZoneSelectCols, c.Param("name")), &zone)
+	if errors.Is(err, pgx.ErrNoRows) {
+		utils.RespondError(c, http.StatusNotFound, "Zone not found", nil)
+		return
+	}
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to deactivate zone", err)
+		return
+	}
+
+	serviceZonesChanged(ctx)
+	utils.SafeGo(func() { syncZoneGeofence(context.Background(), &zone) })
+	utils.RespondSuccess(c, http.StatusOK, "Zone deactivated", gin.H{"zone": zone.Name})
+}
+
+// POST /api/v1/admin/zones/reload
+func AdminReloadZones(c *gin.Context) {
+	serviceZonesChanged(adminContext(c))
+	utils.RespondSuccess(c, http.StatusOK, "Service zones reloaded", gin.H{"active": len(activeServiceZones())})
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"ridewave/utils"
+)
+
+// ══════════════════════════════════════════════════
+// App Sessions — refresh & logout
+// ══════════════════════════════════════════════════
+//
+// Access tokens last minutes; the apps swap their refresh token for a new pair before then. Each
+// refresh token works once. A refresh re-checks the account, so a suspension that happened while
+// the app was idle ends the session instead of renewing it.
+
+// refreshBody is the body of both refresh endpoints.
+type refreshBody struct {
+	RefreshToken string `json:"refreshToken" binding:"required"`
+}
+
+// respondRefreshed sends a rotated token pair, or ends the account's sessions if blockedMsg is set.
+func respondRefreshed(c *gin.Context, role, id string, tokens utils.TokenPair, blockedMsg string) {
+	if blockedMsg != "" {
+		utils.RevokeSessionsAsync(role, id)
+		utils.RespondError(c, http.StatusForbidden, blockedMsg, nil)
+		return
+	}
+	utils.RespondSuccess(c, http.StatusOK, "Token refreshed", gin.H{
+		"accessToken":  tokens.AccessToken,
+		"refreshToken": tokens.RefreshToken,
+		"expiresIn":    tokens.ExpiresIn,
+	})
+}
+
+// rotateRefreshToken binds the body and rotates its refresh token, responding itself on failure.
+func rotateRefreshToken(c *gin.Context, role string) (string, utils.TokenPair, bool) {
+	var body refreshBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "refreshToken is required", err)
+		return "", utils.TokenPair{}, false
+	}
+	id, tokens, err := utils.RefreshSession(c.Request.Context(), role, body.RefreshToken)
+	if errors.Is(err, utils.ErrInvalidRefreshToken) {
+		utils.RespondErrorCode(c, http.StatusUnauthorized, utils.CodeSessionEnded, "Your session has ended. Please log in again.", nil)
+		return "", utils.TokenPair{}, false
+	}
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to refresh session", err)
+		return "", utils.TokenPair{}, false
+	}
+	return id, tokens, true
+}
+
+// POST /api/v1/user/auth/refresh — {refreshToken}
+func UserRefreshToken(c *gin.Context) {
+	id, tokens, ok := rotateRefreshToken(c, utils.SessionUser)
+	if !ok {
+		return
+	}
+	user, err := repos.Users.GetByID(c.Request.Context(), id)
+	if err != nil {
+		utils.RespondError(c, http.StatusUnauthorized, "User not found", err)
+		return
+	}
+	var blocked string
+	switch user.Status {
+	case "suspended":
+		blocked = "Your account has been suspended. Contact support."
+	case "inactive":
+		blocked = "Your account has been deactivated. Contact support."
+	case "deleted":
+		blocked = "This account has been deleted"
+	}
+	respondRefreshed(c, utils.SessionUser, id, tokens, blocked)
+}
+
+// POST /api/v1/driver/auth/refresh — {refreshToken}
+func DriverRefreshToken(c *gin.Context) {
+	id, tokens, ok := rotateRefreshToken(c, utils.SessionDriver)
+	if !ok {
+		return
+	}
+	driver, err := repos.Drivers.GetByID(c.Request.Context(), id)
+	if err != nil {
+		utils.RespondError(c, http.StatusUnauthorized, "Driver not found", err)
+		return
+	}
+	var blocked string
+	switch driver.Status {
+	case "suspended":
+		blocked = "Your account has been suspended. Contact support."
+	case "rejected":
+		blocked = "Your registration was rejected. Contact support."
+	case "pending":
+		blocked = "Your registration is pending admin verification."
+	case "deleted":
+		blocked = "This account has been deleted"
+	}
+	respondRefreshed(c, utils.SessionDriver, id, tokens, blocked)
+}
+
+// endCurrentSession logs out the session behind the request's access token. Tokens from before
+// sessions existed can't be told apart, so for those every session of the account is ended.
+func endCurrentSession(c *gin.Context, role, id string) {
+	ctx := c.Request.Context()
+	var err error
+	if sid := c.GetString("sessionId"); sid != "" {
+		err = utils.EndSession(ctx, role, id, sid)
+	} else {
+		err = utils.RevokeSessions(ctx, role, id)
+	}
+	if err != nil {
+		utils.Logger.Warn("Failed to end session on logout", zap.String("id", id), zap.Error(err))
+	}
+}
@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"ridewave/db"
+	"ridewave/models"
+	"ridewave/stores"
+	"ridewave/utils"
+)
+
+// ══════════════════════════════════════════════════
+// Trip Sharing — public, expiring live-tracking links
+// ══════════════════════════════════════════════════
+
+const (
+	shareTokenScope = "ride_track"
+
+	// trackingAvgSpeedKmph is the assumed city speed used for the public ETA.
+	trackingAvgSpeedKmph = 20.0
+)
+
+// shareLinkTTL is how long a share link stays valid (SHARE_LINK_TTL_HOURS, default 6).
+func shareLinkTTL() time.Duration {
+	if val, err := strconv.Atoi(os.Getenv("SHARE_LINK_TTL_HOURS")); err == nil && val > 0 {
+		return time.Duration(val) * time.Hour
+	}
+	return 6 * time.Hour
+}
+
+// shareLinkSecret signs share tokens; kept separate from login tokens when RIDE_SHARE_SECRET is set.
+func shareLinkSecret() []byte {
+	if secret := os.Getenv("RIDE_SHARE_SECRET"); secret != "" {
+		return []byte(secret)
+	}
+	return []byte(os.Getenv("ACCESS_TOKEN_SECRET"))
+}
+
+// parseShareToken validates a share token and returns the ride it grants access to.
+func parseShareToken(tokenString string) (string, error) {
+	token, err := jwt.Parse(tokenString, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("unexpected signing method")
+		}
+		return shareLinkSecret(), nil
+	})
+	if err != nil || !token.Valid {
+		return "", errors.New("invalid or expired link")
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || claims["scope"] != shareTokenScope {
+		return "", errors.New("invalid or expired link")
+	}
+	rideID, _ := claims["rideId"].(string)
+	if rideID == "" {
+		return "", errors.New("invalid or expired link")
+	}
+	return rideID, nil
+}
+
+// POST /api/v1/user/ride/:id/share
+func ShareRide(c *gin.Context) {
+	rideID := c.Param("id")
+	user := c.MustGet("user").(*models.User)
+
+	var status string
+	err := db.Pool.QueryRow(c.Request.Context(),
+		`SELECT status FROM rides WHERE id=$1 AND "userId"=$2`, rideID, user.ID).Scan(&status)
+	if err != nil {
+		utils.RespondErrorCode(c, http.StatusNotFound, utils.CodeRideNotFound, "Ride not found", err)
+		return
+	}
+	if status == "Completed" || status == "Cancelled" {
+		utils.RespondError(c, http.StatusBadRequest, "Only active rides can be shared", nil)
+		return
+	}
+
+	expiresAt := time.Now().Add(shareLinkTTL())
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"rideId": rideID,
+		"scope":  shareTokenScope,
+		"exp":    expiresAt.Unix(),
+	})
+	tokenString, err := token.SignedString(shareLinkSecret())
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to create share link", err)
+		return
+	}
+
+	// SHARE_LINK_BASE_URL points at the web tracking page, e.g. https://ridewave.in/track
+	url := "/api/v1/public/track/" + tokenString
+	if base := os.Getenv("SHARE_LINK_BASE_URL"); base != "" {
+		url = base + "/" + tokenString
+	}
+	utils.RespondSuccess(c, http.StatusOK, "Share link created", gin.H{
+		"token":     tokenString,
+		"url":       url,
+		"expiresAt": expiresAt,
+	})
+}
+
+// GET /api/v1/public/track/:token — no auth; the signed token is the credential
+func TrackSharedRide(c *gin.Context) {
+	rideID, err := parseShareToken(c.Param("token"))
+	if err != nil {
+		utils.RespondError(c, http.StatusUnauthorized, "This tracking link is invalid or has expired", nil)
+		return
+	}
+	// The link is opened without a login, so the ride can belong to any tenant
+	ctx := db.WithoutTenant(c.Request.Context())
+
+	var status, originName, destName, vehicleType string
+	var driverID *string
+	var originLat, originLng, destLat, destLng *float64
+	err = db.Pool.QueryRow(ctx,
+		`SELECT status, "currentLocationName", "destinationLocationName", "vehicleType", "driverId",
+		 "originLat", "originLng", "destinationLat", "destinationLng"
+		 FROM rides WHERE id=$1`, rideID).
+		Scan(&status, &originName, &destName, &vehicleType, &driverID, &originLat, &originLng, &destLat, &destLng)
+	if err != nil {
+		utils.RespondErrorCode(c, http.StatusNotFound, utils.CodeRideNotFound, "Ride not found", err)
+		return
+	}
+
+	resp := gin.H{
+		"status":          status,
+		"originName":      originName,
+		"destinationName": destName,
+		"vehicleType":     vehicleType,
+	}
+
+	// Once the trip is over the link stops revealing anything live
+	if status == "Completed" || status == "Cancelled" || driverID == nil {
+		utils.RespondSuccess(c, http.StatusOK, "Ride tracking", resp)
+		return
+	}
+
+	var driverName, registration string
+	var vehicleColor *string
+	db.Pool.QueryRow(ctx,
+		`SELECT name, registration_number, vehicle_color FROM driver WHERE id=$1`, *driverID).
+		Scan(&driverName, &registration, &vehicleColor)
+	resp["driver"] = gin.H{
+		"name":                driverName,
+		"registration_number": registration,
+		"vehicle_color":       vehicleColor,
+	}
+
+	loc, err := stores.GetDriverLocation(ctx, *driverID)
+	if err != nil {
+		utils.RespondSuccess(c, http.StatusOK, "Ride tracking", resp)
+		return
+	}
+	resp["driverLocation"] = gin.H{"lat": loc.Latitude, "lng": loc.Longitude}
+
+	// Before pickup the ETA is to the rider; once in progress it's to the drop-off
+	targetLat, targetLng := originLat, originLng
+	if status == "InProgress" {
+		targetLat, targetLng = destLat, destLng
+	}
+	if targetLat != nil && targetLng != nil {
+		km := utils.CalculateDistance(loc.Latitude, loc.Longitude, *targetLat, *targetLng)
+		resp["etaMinutes"] = int(math.Ceil(km / trackingAvgSpeedKmph * 60))
+	}
+	utils.RespondSuccess(c, http.StatusOK, "Ride tracking", resp)
+}
+
+// RegisterPublicRoutes mounts unauthenticated endpoints.
+func RegisterPublicRoutes(r gin.IRouter) {
+	publicGroup := r.Group("/public")
+	{
+		publicGroup.GET("/track/:token", TrackSharedRide)
+		publicGroup.GET("/regions", GetRegions)
+		publicGroup.GET("/branding", GetBranding)
+		publicGroup.GET("/vehicle-type-icon/:id", GetVehicleTypeIcon)
+		publicGroup.GET("/fare-policy", GetFarePolicy)
+	}
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"ridewave/middleware"
+	"ridewave/utils"
+)
+
+// TestSharedTrackingLinkNeedsNoAPIKey opens a tracking link the way a browser does, without
+// x-api-key, on a server that has API_KEY set.
+func TestSharedTrackingLinkNeedsNoAPIKey(t *testing.T) {
+	t.Setenv("API_KEY", "app-key")
+
+	r := gin.New()
+	r.Use(middleware.APIKeyAuth())
+	api := r.Group(utils.APIPrefix(utils.APIv1))
+	RegisterPublicRoutes(api)
+	api.GET("/user/profile", func(c *gin.Context) { utils.RespondSuccess(c, http.StatusOK, "ok", nil) })
+
+	get := func(target string) testResponse {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		resp := testResponse{Status: rec.Code}
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decoding response %q: %v", rec.Body.String(), err)
+		}
+		return resp
+	}
+
+	// The token is bogus, so the handler itself must be the one turning the link away
+	resp := get("/api/v1/public/track/not-a-token")
+	if resp.Message != "This tracking link is invalid or has expired" {
+		t.Fatalf("tracking link got %d %q, want the handler's invalid-link reply", resp.Status, resp.Message)
+	}
+
+	resp = get("/api/v1/user/profile")
+	if resp.Status != http.StatusUnauthorized || resp.Message != "Invalid API Key" {
+		t.Fatalf("non-public route got %d %q, want 401 Invalid API Key", resp.Status, resp.Message)
+	}
+}
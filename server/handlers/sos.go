@@ -0,0 +1,273 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"ridewave/db"
+	"ridewave/models"
+	"ridewave/stores"
+	"ridewave/utils"
+)
+
+// ══════════════════════════════════════════════════
+// SOS Escalation — page on-call admins until someone acknowledges
+// ══════════════════════════════════════════════════
+//
+// A new alert is escalated straight away: on-call admins get a push, an email and an SMS, and
+// the admin live feed gets an "sos" event with the driver's last known position. Alerts nobody
+// has acknowledged are escalated again every SOS_REESCALATE_MINUTES, up to SOS_MAX_ESCALATIONS times.
+// Status goes active → acknowledged → resolved.
+
+const (
+	sosActive       = "active"
+	sosAcknowledged = "acknowledged"
+	sosResolved     = "resolved"
+)
+
+func sosReescalateMinutes() int {
+	if v, err := strconv.Atoi(os.Getenv("SOS_REESCALATE_MINUTES")); err == nil && v > 0 {
+		return v
+	}
+	return 3
+}
+
+func sosMaxEscalations() int {
+	if v, err := strconv.Atoi(os.Getenv("SOS_MAX_ESCALATIONS")); err == nil && v > 0 {
+		return v
+	}
+	return 5
+}
+
+// sosResponder is an admin who gets paged for SOS alerts.
+type sosResponder struct {
+	Email       string
+	PhoneNumber *string
+	FCMToken    *string
+}
+
+// sosResponders returns the on-call admins. With nobody on call, every active support admin and
+// superadmin is paged instead so an alert is never dropped.
+func sosResponders(ctx context.Context) []sosResponder {
+	rows, err := db.Pool.Query(ctx,
+		`SELECT email, "phoneNumber", "fcmToken" FROM admin_accounts
+		 WHERE "isActive" AND role IN ('support','superadmin')
+		   AND ("isOnCall" OR NOT EXISTS (SELECT 1 FROM admin_accounts WHERE "isOnCall" AND "isActive"))`)
+	if err != nil {
+		utils.Logger.Error("Failed to load SOS responders", zap.Error(err))
+		return nil
+	}
+	defer rows.Close()
+
+	var responders []sosResponder
+	for rows.Next() {
+		var r sosResponder
+		if rows.Scan(&r.Email, &r.PhoneNumber, &r.FCMToken) == nil {
+			responders = append(responders, r)
+		}
+	}
+	return responders
+}
+
+// escalateSOSAlert pages the responders about an alert that is still unacknowledged. The update that
+// bumps the escalation count doubles as a claim, so only one instance escalates each round.
+func escalateSOSAlert(alertID string) {
+	ctx := db.Unscoped()
+
+	var rideID, userID, driverID string
+	var lat, lng float64
+	var count int
+	err := db.Pool.QueryRow(ctx,
+		`UPDATE sos_alerts SET "escalationCount"="escalationCount"+1, "lastEscalatedAt"=NOW()
+		 WHERE id=$1 AND status=$2
+		   AND ("lastEscalatedAt" IS NULL OR "lastEscalatedAt" <= NOW() - make_interval(mins => $3))
+		 RETURNING COALESCE("rideId", ''), "userId", COALESCE("driverId", ''), COALESCE(lat, 0), COALESCE(lng, 0), "escalationCount"`,
+		alertID, sosActive, sosReescalateMinutes()).Scan(&rideID, &userID, &driverID, &lat, &lng, &count)
+	if err != nil {
+		return
+	}
+
+	// The driver's position may have moved since the last round
+	var driverLocation map[string]any
+	if driverID != "" {
+		if loc, err := stores.GetDriverLocation(ctx, driverID); err == nil {
+			db.Pool.Exec(ctx,
+				`UPDATE sos_alerts SET "driverLat"=$2, "driverLng"=$3, "driverLocationAt"=NOW() WHERE id=$1`,
+				alertID, loc.Latitude, loc.Longitude)
+			driverLocation = map[string]any{"lat": loc.Latitude, "lng": loc.Longitude}
+		}
+	}
+
+	var userName, userPhone, driverName, driverPhone string
+	db.Pool.QueryRow(ctx, `SELECT COALESCE(name, ''), phone_number FROM "user" WHERE id=$1`, userID).Scan(&userName, &userPhone)
+	if driverID != "" {
+		db.Pool.QueryRow(ctx, `SELECT name, phone_number FROM driver WHERE id=$1`, driverID).Scan(&driverName, &driverPhone)
+	}
+
+	title := "🚨 SOS alert"
+	if count > 1 {
+		title = fmt.Sprintf("🚨 SOS alert — unacknowledged (%d)", count)
+	}
+	message := fmt.Sprintf("%s (%s) triggered SOS at %.5f,%.5f.", userName, userPhone, lat, lng)
+	if driverName != "" {
+		message += fmt.Sprintf(" Driver: %s (%s).", driverName, driverPhone)
+	}
+	if driverLocation != nil {
+		message += fmt.Sprintf(" Driver last seen at %.5f,%.5f.", driverLocation["lat"], driverLocation["lng"])
+	}
+
+	responders := sosResponders(ctx)
+	var tokens, emails []string
+	for _, r := range responders {
+		emails = append(emails, r.Email)
+		if r.FCMToken != nil && *r.FCMToken != "" {
+			tokens = append(tokens, *r.FCMToken)
+		}
+		if r.PhoneNumber != nil && *r.PhoneNumber != "" {
+			if err := utils.SendSMS(*r.PhoneNumber, title+": "+message); err != nil {
+				utils.Logger.Warn("Failed to send SOS SMS", zap.String("admin", r.Email), zap.Error(err))
+			}
+		}
+	}
+	if err := utils.SendPushToMultiple(tokens, title, message, utils.FCMData{"type": "sos", "alertId": alertID, "rideId": rideID}); err != nil {
+		utils.Logger.Warn("Failed to push SOS alert", zap.String("alertId", alertID), zap.Error(err))
+	}
+	if len(emails) > 0 {
+		if err := utils.SendEmail(utils.Recipient{}, emails, title, "<p>"+message+"</p><p>Alert ID: "+alertID+"</p>"); err != nil {
+			utils.Logger.Warn("Failed to email SOS alert", zap.String("alertId", alertID), zap.Error(err))
+		}
+	}
+
+	publishAdminOps("sos", map[string]any{
+		"alertId":         alertID,
+		"rideId":          rideID,
+		"userId":          userID,
+		"lat":             lat,
+		"lng":             lng,
+		"driverId":        driverID,
+		"driverLocation":  driverLocation,
+		"escalationCount": count,
+	})
+	utils.Logger.Warn("SOS alert escalated", zap.String("alertId", alertID), zap.Int("escalation", count), zap.Int("responders", len(responders)))
+}
+
+// StartSOSEscalationWorker re-pages responders about alerts nobody has acknowledged yet.
+func StartSOSEscalationWorker(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(30 * time.Second)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				escalatePendingSOSAlerts()
+			case <-ctx.Done():
+				utils.Logger.Info("SOS Escalation Worker shutting down...")
+				return
+			}
+		}
+	}()
+}
+
+func escalatePendingSOSAlerts() {
+	rows, err := db.Pool.Query(context.Background(),
+		`SELECT id FROM sos_alerts
+		 WHERE status=$1 AND "escalationCount" < $2
+		   AND ("lastEscalatedAt" IS NULL OR "lastEscalatedAt" <= NOW() - make_interval(mins => $3))`,
+		sosActive, sosMaxEscalations(), sosReescalateMinutes())
+	if err != nil {
+		utils.Logger.Error("Failed to load unacknowledged SOS alerts", zap.Error(err))
+		return
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if rows.Scan(&id) == nil {
+			ids = append(ids, id)
+		}
+	}
+	rows.Close()
+
+	for _, id := range ids {
+		escalateSOSAlert(id)
+	}
+}
+
+// ══════════════════════════════════════════════════
+// Admin: SOS Response
+// ══════════════════════════════════════════════════
+
+// PUT /api/v1/admin/sos/:id/acknowledge — stop escalation; the responder is recorded
+func AdminAcknowledgeSOSAlert(c *gin.Context) {
+	admin := c.MustGet("admin").(*models.AdminAccount)
+	var acknowledgedAt time.Time
+	err := db.Pool.QueryRow(adminContext(c),
+		`UPDATE sos_alerts SET status=$2, "acknowledgedAt"=NOW(), "acknowledgedBy"=$3
+		 WHERE id=$1 AND status=$4 RETURNING "acknowledgedAt"`,
+		c.Param("id"), sosAcknowledged, admin.Email, sosActive).Scan(&acknowledgedAt)
+	if err != nil {
+		utils.RespondError(c, http.StatusConflict, "SOS alert not found or already acknowledged", err)
+		return
+	}
+
+	publishAdminOps("sosUpdate", map[string]any{"alertId": c.Param("id"), "status": sosAcknowledged, "by": admin.Email})
+	utils.RespondSuccess(c, http.StatusOK, "SOS alert acknowledged", gin.H{"acknowledgedAt": acknowledgedAt, "acknowledgedBy": admin.Email})
+}
+
+// PUT /api/v1/admin/me/on-call — {onCall: true, phoneNumber: "+91…", fcmToken: "…"}
+// Phone number and FCM token are where SOS pages go; omit them to keep the saved ones.
+func AdminSetOnCall(c *gin.Context) {
+	admin := c.MustGet("admin").(*models.AdminAccount)
+	var body struct {
+		OnCall      bool    `json:"onCall"`
+		PhoneNumber *string `json:"phoneNumber"`
+		FCMToken    *string `json:"fcmToken"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid request", err)
+		return
+	}
+	if body.PhoneNumber != nil {
+		*body.PhoneNumber = strings.TrimSpace(*body.PhoneNumber)
+	}
+
+	var phone, token *string
+	err := db.Pool.QueryRow(adminContext(c),
+		`UPDATE admin_accounts SET "isOnCall"=$2, "phoneNumber"=COALESCE($3, "phoneNumber"), "fcmToken"=COALESCE($4, "fcmToken"),
+		 "updatedAt"=NOW() WHERE id=$1 RETURNING "phoneNumber", "fcmToken"`,
+		admin.ID, body.OnCall, body.PhoneNumber, body.FCMToken).Scan(&phone, &token)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to update on-call status", err)
+		return
+	}
+	utils.RespondSuccess(c, http.StatusOK, "On-call status updated", gin.H{
+		"onCall":      body.OnCall,
+		"phoneNumber": phone,
+		"hasFcmToken": token != nil && *token != "",
+	})
+}
+
+// GET /api/v1/admin/on-call — who gets paged for SOS alerts right now
+func AdminGetOnCall(c *gin.Context) {
+	responders := []gin.H{}
+	for _, r := range sosResponders(adminContext(c)) {
+		responders = append(responders, gin.H{
+			"email":       r.Email,
+			"phoneNumber": r.PhoneNumber,
+			"hasFcmToken": r.FCMToken != nil && *r.FCMToken != "",
+		})
+	}
+	var onCall bool
+	db.Pool.QueryRow(adminContext(c), `SELECT EXISTS(SELECT 1 FROM admin_accounts WHERE "isOnCall" AND "isActive")`).Scan(&onCall)
+	utils.RespondSuccess(c, http.StatusOK, "On-call responders", gin.H{
+		"responders": responders,
+		"fallback":   !onCall, // nobody on call: every support admin is paged
+	})
+}
@@ -0,0 +1,267 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+	"ridewave/db"
+	"ridewave/events"
+	"ridewave/models"
+	"ridewave/rides/statemachine"
+	"ridewave/stores"
+	"ridewave/utils"
+)
+
+// ══════════════════════════════════════════════════
+// Stuck Ride Monitor — auto-complete forgotten InProgress rides
+// ══════════════════════════════════════════════════
+
+const rideAtDestinationKeyPrefix = "rides:atdest:"
+const ridePromptedKeyPrefix = "rides:completeprompt:"
+
+// stuckRideConfig holds the tunables for the monitor, all overridable via ENV.
+type stuckRideConfig struct {
+	DwellTime    time.Duration // how long the driver must sit at the drop-off
+	RadiusMeters float64       // geofence radius around the destination
+	Action       string        // "complete" (auto-complete) or "prompt" (push the driver)
+	MaxOverrun   time.Duration // overrun beyond the ETA that gets flagged as an anomaly
+}
+
+func loadStuckRideConfig() stuckRideConfig {
+	cfg := stuckRideConfig{
+		DwellTime:    5 * time.Minute,
+		RadiusMeters: 150,
+		Action:       "complete",
+		MaxOverrun:   60 * time.Minute,
+	}
+	if val, err := strconv.Atoi(os.Getenv("STUCK_RIDE_DWELL_MINUTES")); err == nil && val > 0 {
+		cfg.DwellTime = time.Duration(val) * time.Minute
+	}
+	if val, err := strconv.ParseFloat(os.Getenv("STUCK_RIDE_RADIUS_METERS"), 64); err == nil && val > 0 {
+		cfg.RadiusMeters = val
+	}
+	if action := os.Getenv("STUCK_RIDE_ACTION"); action == "prompt" || action == "complete" {
+		cfg.Action = action
+	}
+	if val, err := strconv.Atoi(os.Getenv("STUCK_RIDE_MAX_OVERRUN_MINUTES")); err == nil && val > 0 {
+		cfg.MaxOverrun = time.Duration(val) * time.Minute
+	}
+	return cfg
+}
+
+// StartStuckRideWorker scans InProgress rides every minute and resolves the ones
+// whose driver is parked at the destination but never tapped Complete.
+func StartStuckRideWorker(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				checkStuckRides(loadStuckRideConfig())
+			case <-ctx.Done():
+				utils.Logger.Info("Stuck Ride Worker shutting down...")
+				return
+			}
+		}
+	}()
+}
+
+type inProgressRide struct {
+	ID                string
+	UserID            string
+	DriverID          string
+	Charge            float64
+	Currency          string
+	Distance          string
+	DestinationLat    float64
+	DestinationLng    float64
+	EstimatedDuration int
+	StartedAt         time.Time
+}
+
+func checkStuckRides(cfg stuckRideConfig) {
+	rows, err := db.Pool.Query(db.Unscoped(),
+		`SELECT id, "userId", "driverId", charge, currency, distance, "destinationLat", "destinationLng",
+		 COALESCE("estimatedDuration", 0), COALESCE("startedAt", "updatedAt")
+		 FROM rides WHERE status='InProgress' AND "driverId" IS NOT NULL
+		 AND "destinationLat" IS NOT NULL AND "destinationLng" IS NOT NULL`)
+	if err != nil {
+		utils.Logger.Error("Failed to query in-progress rides", zap.Error(err))
+		return
+	}
+
+	var rides []inProgressRide
+	for rows.Next() {
+		var r inProgressRide
+		if err := rows.Scan(&r.ID, &r.UserID, &r.DriverID, &r.Charge, &r.Currency, &r.Distance, &r.DestinationLat, &r.DestinationLng,
+			&r.EstimatedDuration, &r.StartedAt); err == nil {
+			rides = append(rides, r)
+		}
+	}
+	rows.Close()
+
+	ctx := db.Unscoped()
+	for _, r := range rides {
+		atDestKey := rideAtDestinationKeyPrefix + r.ID
+		overrun := time.Since(r.StartedAt) - time.Duration(r.EstimatedDuration)*time.Second
+
+		loc, err := stores.GetDriverLocation(context.Background(), r.DriverID)
+		if err != nil {
+			// No live location — nothing to confirm against, but still flag extreme overruns
+			if overrun > cfg.MaxOverrun {
+				flagRideAnomaly(r.ID, "overrun_no_location",
+					fmt.Sprintf("InProgress %d mins past ETA with no live driver location", int(overrun.Minutes())))
+			}
+			continue
+		}
+
+		distMeters := utils.CalculateDistance(loc.Latitude, loc.Longitude, r.DestinationLat, r.DestinationLng) * 1000
+		if distMeters > cfg.RadiusMeters {
+			// Driver left (or never reached) the drop-off geofence — reset the dwell timer
+			db.RedisClient.Del(ctx, atDestKey)
+			if overrun > cfg.MaxOverrun {
+				flagRideAnomaly(r.ID, "overrun_off_route",
+					fmt.Sprintf("InProgress %d mins past ETA, driver %.0fm from destination", int(overrun.Minutes()), distMeters))
+			}
+			continue
+		}
+
+		// Driver is inside the geofence: remember when they first arrived
+		db.RedisClient.SetNX(ctx, atDestKey, time.Now().Unix(), 24*time.Hour)
+		firstSeen, err := db.RedisClient.Get(ctx, atDestKey).Int64()
+		if err != nil || time.Since(time.Unix(firstSeen, 0)) < cfg.DwellTime {
+			continue
+		}
+
+		if cfg.Action == "prompt" {
+			promptDriverToComplete(r)
+			continue
+		}
+		autoCompleteRide(r)
+		db.RedisClient.Del(ctx, atDestKey)
+	}
+}
+
+// autoCompleteRide marks a stuck ride Completed on the driver's behalf and applies the usual side effects.
+func autoCompleteRide(r inProgressRide) {
+	_, err := statemachine.Transition(db.Unscoped(), statemachine.Change{
+		RideID: r.ID, To: statemachine.Completed, ActorType: events.ActorSystem, Data: map[string]any{"autoCompleted": true},
+	}, func(ctx context.Context, tx pgx.Tx, from string) error {
+		_, err := tx.Exec(ctx,
+			`UPDATE rides SET status='Completed', "completedAt"=NOW(), "autoCompleted"=TRUE, "updatedAt"=NOW()
+			 WHERE id=$1`, r.ID)
+		if err != nil {
+			return err
+		}
+		if err := settleRideCompletion(ctx, tx, r.ID, r.DriverID, r.UserID, r.Charge, r.Distance); err != nil {
+			return err
+		}
+		notifications := rideStatusNotifications("user", r.UserID, r.ID, "Ride Completed ✅",
+			fmt.Sprintf("You have reached your destination. Total fare: %s", formatMoney(r.Currency, r.Charge)), utils.FCMData{
+				"type":   "ride_status",
+				"rideId": r.ID,
+				"status": "Completed",
+			})
+		notifications = append(notifications, outboxPush("driver", r.DriverID, r.ID, "Ride auto-completed",
+			"We completed your ride automatically since you've reached the drop-off.", utils.FCMData{
+				"type":   "ride_auto_completed",
+				"rideId": r.ID,
+			}))
+		return queueNotifications(ctx, tx, notifications...)
+	})
+	if errors.Is(err, statemachine.ErrInvalidTransition) {
+		return // Driver completed or cancelled it in the meantime
+	}
+	if err != nil {
+		utils.Logger.Error("Failed to auto-complete ride", zap.String("rideId", r.ID), zap.Error(err))
+		return
+	}
+	kickNotificationOutbox()
+
+	applyRideCompletion(r.ID, r.DriverID)
+	flagRideAnomaly(r.ID, "auto_completed", "Driver stationary at destination; ride auto-completed")
+	utils.Logger.Info("Ride auto-completed", zap.String("rideId", r.ID), zap.String("driverId", r.DriverID))
+}
+
+// promptDriverToComplete nudges the driver once per ride to tap Complete.
+func promptDriverToComplete(r inProgressRide) {
+	ok, err := db.RedisClient.SetNX(context.Background(), ridePromptedKeyPrefix+r.ID, 1, 24*time.Hour).Result()
+	if err != nil || !ok {
+		return
+	}
+
+	sendNotifications(context.Background(), outboxPush("driver", r.DriverID, r.ID, "Did you forget to complete the ride?",
+		"You've been at the drop-off for a while. Tap Complete to finish the trip.", utils.FCMData{
+			"type":   "ride_complete_prompt",
+			"rideId": r.ID,
+		}))
+}
+
+// flagRideAnomaly records an anomaly for admin review (one open entry per ride and type).
+func flagRideAnomaly(rideID, anomalyType, details string) {
+	_, err := db.Pool.Exec(context.Background(),
+		`INSERT INTO ride_anomalies (id, "rideId", type, details, status, "createdAt")
+		 VALUES (gen_random_uuid()::text, $1, $2, $3, 'open', NOW())
+		 ON CONFLICT ("rideId", type) DO NOTHING`, rideID, anomalyType, details)
+	if err != nil {
+		utils.Logger.Error("Failed to flag ride anomaly", zap.String("rideId", rideID), zap.Error(err))
+	}
+}
+
+// ══════════════════════════════════════════════════
+// Admin: Ride Anomaly Review
+// ══════════════════════════════════════════════════
+
+// GET /api/v1/admin/ride-anomalies?status=open
+func AdminGetRideAnomalies(c *gin.Context) {
+	statusFilter := c.DefaultQuery("status", "open")
+
+	rows, err := db.Pool.Query(adminContext(c),
+		`SELECT id, "rideId", type, COALESCE(details, ''), status, "resolvedAt", "createdAt"
+		 FROM ride_anomalies WHERE status=$1 ORDER BY "createdAt" DESC`, statusFilter)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to fetch ride anomalies", err)
+		return
+	}
+	defer rows.Close()
+
+	var anomalies []models.RideAnomaly
+	for rows.Next() {
+		var a models.RideAnomaly
+		rows.Scan(&a.ID, &a.RideID, &a.Type, &a.Details, &a.Status, &a.ResolvedAt, &a.CreatedAt)
+		anomalies = append(anomalies, a)
+	}
+	if anomalies == nil {
+		anomalies = []models.RideAnomaly{}
+	}
+	utils.RespondSuccess(c, http.StatusOK, "Ride anomalies", gin.H{
+		"anomalies": anomalies,
+		"count":     len(anomalies),
+	})
+}
+
+// PUT /api/v1/admin/ride-anomaly/:id/resolve
+func AdminResolveRideAnomaly(c *gin.Context) {
+	id := c.Param("id")
+	tag, err := db.Pool.Exec(adminContext(c),
+		`UPDATE ride_anomalies SET status='resolved', "resolvedAt"=NOW() WHERE id=$1`, id)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to resolve anomaly", err)
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		utils.RespondError(c, http.StatusNotFound, "Ride anomaly not found", nil)
+		return
+	}
+	utils.RespondSuccess(c, http.StatusOK, "Ride anomaly resolved", nil)
+}
@@ -0,0 +1,300 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"ridewave/db"
+	"ridewave/middleware"
+	"ridewave/models"
+	"ridewave/utils"
+)
+
+// ══════════════════════════════════════════════════
+// White-label Tenants — branded operators sharing one deployment
+// ══════════════════════════════════════════════════
+
+var tenantIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{1,31}$`)
+
+const tenantSelectCols = `id, name, domains, branding, zones, "isActive", "createdAt", "updatedAt"`
+
+func scanTenant(scanner interface{ Scan(dest ...any) error }, t *models.Tenant) error {
+	return scanner.Scan(&t.ID, &t.Name, &t.Domains, &t.Branding, &t.Zones, &t.IsActive, &t.CreatedAt, &t.UpdatedAt)
+}
+
+// requestTenant is the tenant a request was resolved to (see middleware.TenantResolver).
+func requestTenant(ctx context.Context) string {
+	if tenantID := db.TenantFrom(ctx); tenantID != "" {
+		return tenantID
+	}
+	return db.DefaultTenant
+}
+
+// adminContext is the request's context for admin queries, which see every tenant's rows.
+func adminContext(c *gin.Context) context.Context {
+	return db.WithoutTenant(c.Request.Context())
+}
+
+// adminTenantContext scopes an admin query to ?tenant= (default tenant when omitted).
+func adminTenantContext(c *gin.Context) context.Context {
+	return db.WithTenant(c.Request.Context(), c.DefaultQuery("tenant", db.DefaultTenant))
+}
+
+// tenantServesZone reports whether the request's tenant operates in a service zone.
+func tenantServesZone(ctx context.Context, zone string) bool {
+	var zones []string
+	err := db.Pool.QueryRow(ctx,
+		`SELECT zones FROM tenants WHERE id=$1`, requestTenant(ctx)).Scan(&zones)
+	if err != nil || len(zones) == 0 {
+		return true
+	}
+	for _, z := range zones {
+		if strings.EqualFold(z, zone) {
+			return true
+		}
+	}
+	return false
+}
+
+// newTenantAPIKey returns a fresh key; only its hash is stored.
+func newTenantAPIKey() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return "rwt_" + hex.EncodeToString(buf), nil
+}
+
+// validateTenantZones rejects zone names that aren't active service zones.
+func validateTenantZones(zones []string) error {
+	for _, z := range zones {
+		if findServiceZone(z) == nil {
+			return errors.New("Unknown service zone: " + z)
+		}
+	}
+	return nil
+}
+
+// domainsTaken reports whether another tenant already serves one of the domains.
+func domainsTaken(ctx context.Context, tenantID string, domains []string) bool {
+	var taken bool
+	db.Pool.QueryRow(ctx,
+		`SELECT EXISTS(SELECT 1 FROM tenants WHERE id<>$1 AND domains && $2)`, tenantID, domains).Scan(&taken)
+	return taken
+}
+
+func normalizeDomains(domains []string) []string {
+	out := []string{}
+	for _, d := range domains {
+		if d = strings.ToLower(strings.TrimSpace(d)); d != "" {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+// GET /api/v1/public/branding — the app name, colours and support contacts for the caller's tenant
+func GetBranding(c *gin.Context) {
+	var t models.Tenant
+	err := scanTenant(db.Pool.QueryRow(c.Request.Context(),
+		`SELECT `+tenantSelectCols+` FROM tenants WHERE id=$1`, requestTenant(c.Request.Context())), &t)
+	if err != nil {
+		utils.RespondError(c, http.StatusNotFound, "Tenant not found", err)
+		return
+	}
+	if t.Branding == nil {
+		t.Branding = map[string]string{}
+	}
+	utils.RespondSuccess(c, http.StatusOK, "Branding", gin.H{
+		"tenantId": t.ID,
+		"name":     t.Name,
+		"branding": t.Branding,
+	})
+}
+
+// ══════════════════════════════════════════════════
+// Admin: Tenant Management
+// ══════════════════════════════════════════════════
+
+// GET /api/v1/admin/tenants
+func AdminGetTenants(c *gin.Context) {
+	rows, err := db.Pool.Query(adminContext(c),
+		`SELECT `+tenantSelectCols+` FROM tenants ORDER BY "createdAt" ASC`)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to fetch tenants", err)
+		return
+	}
+	defer rows.Close()
+
+	tenants := []models.Tenant{}
+	for rows.Next() {
+		var t models.Tenant
+		if err := scanTenant(rows, &t); err == nil {
+			tenants = append(tenants, t)
+		}
+	}
+	utils.RespondSuccess(c, http.StatusOK, "Tenants", gin.H{"tenants": tenants})
+}
+
+// POST /api/v1/admin/tenants — the API key is only returned here and on rotation
+func AdminCreateTenant(c *gin.Context) {
+	var body struct {
+		ID       string            `json:"id" binding:"required"` // slug, e.g. "cityride"
+		Name     string            `json:"name" binding:"required"`
+		Domains  []string          `json:"domains"`
+		Branding map[string]string `json:"branding"`
+		Zones    []string          `json:"zones"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid request", err)
+		return
+	}
+	body.ID = strings.ToLower(strings.TrimSpace(body.ID))
+	if !tenantIDPattern.MatchString(body.ID) {
+		utils.RespondError(c, http.StatusBadRequest, "Tenant ID must be 2-32 lowercase letters, digits or dashes", nil)
+		return
+	}
+	if err := validateTenantZones(body.Zones); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+	body.Domains = normalizeDomains(body.Domains)
+	if domainsTaken(adminContext(c), body.ID, body.Domains) {
+		utils.RespondError(c, http.StatusConflict, "Domain is already used by another tenant", nil)
+		return
+	}
+	if body.Branding == nil {
+		body.Branding = map[string]string{}
+	}
+	if body.Zones == nil {
+		body.Zones = []string{}
+	}
+
+	apiKey, err := newTenantAPIKey()
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to create tenant", err)
+		return
+	}
+
+	ctx := adminContext(c)
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to create tenant", err)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	var t models.Tenant
+	err = scanTenant(tx.QueryRow(ctx,
+		`INSERT INTO tenants (id, name, "apiKeyHash", domains, branding, zones) VALUES ($1, $2, $3, $4, $5, $6)
+		 ON CONFLICT (id) DO NOTHING RETURNING `+tenantSelectCols,
+		body.ID, body.Name, middleware.TenantKeyHash(apiKey), body.Domains, body.Branding, body.Zones), &t)
+	if errors.Is(err, pgx.ErrNoRows) {
+		utils.RespondError(c, http.StatusConflict, "A tenant with this ID already exists", nil)
+		return
+	}
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to create tenant", err)
+		return
+	}
+
+	// Start from the default fleet and fares; they can be tuned with /admin/vehicle-type?tenant=
+	_, err = tx.Exec(ctx,
+		`INSERT INTO vehicle_types (name, "baseFare", "perKmRate", "perMinRate", icon, "isActive", "allowedZones", "availableFrom", "availableUntil",
+		 "iconAssetId", capacity, description, "etaBlurb", "isElectric", "emissionFactor", "tenantId")
+		 SELECT name, "baseFare", "perKmRate", "perMinRate", icon, "isActive", "allowedZones", "availableFrom", "availableUntil",
+		 "iconAssetId", capacity, description, "etaBlurb", "isElectric", "emissionFactor", $1
+		 FROM vehicle_types WHERE "tenantId"=$2`, t.ID, db.DefaultTenant)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to copy vehicle types", err)
+		return
+	}
+	if err := tx.Commit(ctx); err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to create tenant", err)
+		return
+	}
+
+	middleware.InvalidateTenants()
+	invalidateVehicleTypes(ctx)
+	utils.RespondSuccess(c, http.StatusCreated, "Tenant created. Store the API key now: it can't be shown again.", gin.H{
+		"tenant": t,
+		"apiKey": apiKey,
+	})
+}
+
+// PUT /api/v1/admin/tenant/:id — rename, rebrand, change domains/zones or suspend
+func AdminUpdateTenant(c *gin.Context) {
+	tenantID := c.Param("id")
+	var body struct {
+		Name     *string            `json:"name"`
+		Domains  []string           `json:"domains"`
+		Branding *map[string]string `json:"branding"`
+		Zones    []string           `json:"zones"`
+		IsActive *bool              `json:"isActive"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid request", err)
+		return
+	}
+	if tenantID == db.DefaultTenant && body.IsActive != nil && !*body.IsActive {
+		utils.RespondError(c, http.StatusBadRequest, "The default tenant can't be deactivated", nil)
+		return
+	}
+	if err := validateTenantZones(body.Zones); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	var domains []string
+	if body.Domains != nil {
+		domains = normalizeDomains(body.Domains)
+		if domainsTaken(adminContext(c), tenantID, domains) {
+			utils.RespondError(c, http.StatusConflict, "Domain is already used by another tenant", nil)
+			return
+		}
+	}
+
+	var t models.Tenant
+	err := scanTenant(db.Pool.QueryRow(adminContext(c),
+		`UPDATE tenants SET name=COALESCE($1, name), domains=COALESCE($2, domains), branding=COALESCE($3, branding),
+		 zones=COALESCE($4, zones), "isActive"=COALESCE($5, "isActive"), "updatedAt"=NOW()
+		 WHERE id=$6 RETURNING `+tenantSelectCols,
+		body.Name, domains, body.Branding, body.Zones, body.IsActive, tenantID), &t)
+	if err != nil {
+		utils.RespondError(c, http.StatusNotFound, "Tenant not found", err)
+		return
+	}
+
+	middleware.InvalidateTenants()
+	utils.RespondSuccess(c, http.StatusOK, "Tenant updated", gin.H{"tenant": t})
+}
+
+// POST /api/v1/admin/tenant/:id/rotate-key — the old key stops working immediately
+func AdminRotateTenantKey(c *gin.Context) {
+	apiKey, err := newTenantAPIKey()
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to rotate key", err)
+		return
+	}
+
+	tag, err := db.Pool.Exec(adminContext(c),
+		`UPDATE tenants SET "apiKeyHash"=$1, "updatedAt"=NOW() WHERE id=$2`, middleware.TenantKeyHash(apiKey), c.Param("id"))
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to rotate key", err)
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		utils.RespondError(c, http.StatusNotFound, "Tenant not found", nil)
+		return
+	}
+
+	middleware.InvalidateTenants()
+	utils.RespondSuccess(c, http.StatusOK, "API key rotated. Store it now: it can't be shown again.", gin.H{"apiKey": apiKey})
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"ridewave/events"
+	"ridewave/models"
+	"ridewave/stores"
+	"ridewave/utils"
+)
+
+// ══════════════════════════════════════════════════
+// Tips — riders tip the driver after a completed ride
+// ══════════════════════════════════════════════════
+
+// tipWindow is how long after completion a ride can be tipped (TIP_WINDOW_HOURS, default 24).
+func tipWindow() time.Duration {
+	if val, err := strconv.Atoi(os.Getenv("TIP_WINDOW_HOURS")); err == nil && val > 0 {
+		return time.Duration(val) * time.Hour
+	}
+	return 24 * time.Hour
+}
+
+// maxTipAmount caps a single tip (TIP_MAX_AMOUNT, default 500).
+func maxTipAmount() float64 {
+	if val, err := strconv.ParseFloat(os.Getenv("TIP_MAX_AMOUNT"), 64); err == nil && val > 0 {
+		return val
+	}
+	return 500
+}
+
+// POST /api/v1/user/ride/:id/tip
+func TipDriver(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+	rideID := c.Param("id")
+
+	var body struct {
+		Amount float64 `json:"amount" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid request", err)
+		return
+	}
+	amount := math.Round(body.Amount*100) / 100
+	if amount <= 0 || amount > maxTipAmount() {
+		utils.RespondError(c, http.StatusBadRequest, "Tip must be between 0 and "+formatMoney("", maxTipAmount()), nil)
+		return
+	}
+
+	driverID, currency, err := stores.AddRideTip(c.Request.Context(), user.ID, rideID, amount, tipWindow())
+	if errors.Is(err, stores.ErrTipNotAllowed) {
+		utils.RespondError(c, http.StatusConflict,
+			"This ride can't be tipped: it must be your completed ride, not tipped yet and finished recently", nil)
+		return
+	}
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to add tip", err)
+		return
+	}
+
+	publishRideEvent(rideID, events.RideTipped, events.ActorUser, user.ID, map[string]any{"amount": amount})
+
+	tipper := "Your rider"
+	if user.Name != nil && *user.Name != "" {
+		tipper = *user.Name
+	}
+	sendNotifications(c.Request.Context(), outboxPush("driver", driverID, rideID, "You got a tip! 🎉",
+		fmt.Sprintf("%s tipped you %s for your ride.", tipper, formatMoney(currency, amount)), utils.FCMData{
+			"type":   "ride_tip",
+			"rideId": rideID,
+			"amount": strconv.FormatFloat(amount, 'f', 2, 64),
+		}))
+
+	utils.RespondSuccess(c, http.StatusOK, "Thanks for tipping your driver", gin.H{"rideId": rideID, "tip": amount})
+}
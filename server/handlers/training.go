@@ -0,0 +1,312 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"ridewave/db"
+	"ridewave/models"
+	"ridewave/utils"
+)
+
+// ══════════════════════════════════════════════════
+// Driver Training — onboarding modules and the quiz gating approval
+// ══════════════════════════════════════════════════
+
+const defaultTrainingPassScore = 80
+
+const trainingModuleSelectCols = `id, title, content, "videoUrl", questions, "passScore", "sortOrder", "isActive", "createdAt", "updatedAt"`
+
+func scanTrainingModule(scanner interface{ Scan(dest ...any) error }, m *models.TrainingModule) error {
+	var questions []byte
+	if err := scanner.Scan(&m.ID, &m.Title, &m.Content, &m.VideoURL, &questions, &m.PassScore, &m.SortOrder,
+		&m.IsActive, &m.CreatedAt, &m.UpdatedAt); err != nil {
+		return err
+	}
+	return json.Unmarshal(questions, &m.Questions)
+}
+
+// driverTrainingProgress lists every active module with the driver's best score and whether
+// they have passed it. A pass stands even if the threshold is raised later.
+func driverTrainingProgress(ctx context.Context, driverID string) ([]models.TrainingProgress, error) {
+	rows, err := db.Pool.Query(ctx,
+		`SELECT m.id, m.title, m."passScore", COALESCE(MAX(a.score), 0), COUNT(a.id),
+		 COALESCE(BOOL_OR(a.passed), FALSE), MIN(a."createdAt") FILTER (WHERE a.passed)
+		 FROM training_modules m
+		 LEFT JOIN driver_training_attempts a ON a."moduleId"=m.id AND a."driverId"=$1
+		 WHERE m."isActive"
+		 GROUP BY m.id ORDER BY m."sortOrder", m."createdAt"`, driverID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	progress := []models.TrainingProgress{}
+	for rows.Next() {
+		var p models.TrainingProgress
+		if err := rows.Scan(&p.ModuleID, &p.Title, &p.PassScore, &p.BestScore, &p.Attempts, &p.Passed, &p.PassedAt); err != nil {
+			return nil, err
+		}
+		progress = append(progress, p)
+	}
+	return progress, rows.Err()
+}
+
+// outstandingTraining returns the titles of active modules the driver has not passed yet.
+func outstandingTraining(ctx context.Context, driverID string) ([]string, error) {
+	progress, err := driverTrainingProgress(ctx, driverID)
+	if err != nil {
+		return nil, err
+	}
+	outstanding := []string{}
+	for _, p := range progress {
+		if !p.Passed {
+			outstanding = append(outstanding, p.Title)
+		}
+	}
+	return outstanding, nil
+}
+
+// trainingSummary is the admin driver detail's view of a driver's training.
+func trainingSummary(ctx context.Context, driverID string) gin.H {
+	progress, err := driverTrainingProgress(ctx, driverID)
+	if err != nil {
+		return gin.H{"modules": []models.TrainingProgress{}, "complete": false}
+	}
+	complete := true
+	for _, p := range progress {
+		complete = complete && p.Passed
+	}
+	return gin.H{"modules": progress, "complete": complete}
+}
+
+// ══════════════════════════════════════════════════
+// Driver: Onboarding Training (onboarding token)
+// ══════════════════════════════════════════════════
+
+// GET /api/v1/driver/training — active modules in order, without the answers, plus progress
+func GetTrainingModules(c *gin.Context) {
+	driverID := c.GetString("driverId")
+	rows, err := db.Pool.Query(c.Request.Context(),
+		`SELECT `+trainingModuleSelectCols+` FROM training_modules WHERE "isActive" ORDER BY "sortOrder", "createdAt"`)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to fetch training modules", err)
+		return
+	}
+	defer rows.Close()
+
+	type quizQuestion struct {
+		Question string   `json:"question"`
+		Options  []string `json:"options"`
+	}
+	modules := []gin.H{}
+	for rows.Next() {
+		var m models.TrainingModule
+		if err := scanTrainingModule(rows, &m); err != nil {
+			continue
+		}
+		questions := make([]quizQuestion, len(m.Questions))
+		for i, q := range m.Questions {
+			questions[i] = quizQuestion{Question: q.Question, Options: q.Options}
+		}
+		modules = append(modules, gin.H{
+			"id":        m.ID,
+			"title":     m.Title,
+			"content":   m.Content,
+			"videoUrl":  m.VideoURL,
+			"passScore": m.PassScore,
+			"questions": questions,
+		})
+	}
+
+	progress, err := driverTrainingProgress(c.Request.Context(), driverID)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to fetch training progress", err)
+		return
+	}
+	complete := true
+	for _, p := range progress {
+		complete = complete && p.Passed
+	}
+
+	utils.RespondSuccess(c, http.StatusOK, "Training modules", gin.H{
+		"modules":  modules,
+		"progress": progress,
+		"complete": complete,
+	})
+}
+
+// POST /api/v1/driver/training/:id/quiz — answers are option indexes, one per question in order.
+// Every attempt is kept; the response gives the score but not which answers were wrong.
+func SubmitTrainingQuiz(c *gin.Context) {
+	driverID := c.GetString("driverId")
+	var body struct {
+		Answers []int `json:"answers"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid request", err)
+		return
+	}
+
+	var m models.TrainingModule
+	err := scanTrainingModule(db.Pool.QueryRow(c.Request.Context(),
+		`SELECT `+trainingModuleSelectCols+` FROM training_modules WHERE id=$1 AND "isActive"`, c.Param("id")), &m)
+	if err == pgx.ErrNoRows {
+		utils.RespondError(c, http.StatusNotFound, "Training module not found", nil)
+		return
+	}
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to fetch training module", err)
+		return
+	}
+	if len(body.Answers) != len(m.Questions) {
+		utils.RespondError(c, http.StatusBadRequest,
+			fmt.Sprintf("Expected %d answers, got %d", len(m.Questions), len(body.Answers)), nil)
+		return
+	}
+
+	// A module without questions is read-only and passes on submission
+	correct, score := 0, 100
+	for i, q := range m.Questions {
+		if body.Answers[i] == q.AnswerIndex {
+			correct++
+		}
+	}
+	if len(m.Questions) > 0 {
+		score = correct * 100 / len(m.Questions)
+	}
+	passed := score >= m.PassScore
+
+	answers, _ := json.Marshal(body.Answers)
+	_, err = db.Pool.Exec(c.Request.Context(),
+		`INSERT INTO driver_training_attempts ("driverId", "moduleId", score, passed, answers) VALUES ($1, $2, $3, $4, $5)`,
+		driverID, m.ID, score, passed, answers)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to record quiz result", err)
+		return
+	}
+
+	message := "Quiz passed"
+	if !passed {
+		message = "Quiz not passed — review the module and try again"
+	}
+	utils.RespondSuccess(c, http.StatusOK, message, gin.H{
+		"moduleId":  m.ID,
+		"score":     score,
+		"correct":   correct,
+		"total":     len(m.Questions),
+		"passScore": m.PassScore,
+		"passed":    passed,
+	})
+}
+
+// ══════════════════════════════════════════════════
+// Admin: Training Modules
+// ══════════════════════════════════════════════════
+
+// GET /api/v1/admin/training-modules — every module (including inactive) with answers
+func AdminGetTrainingModules(c *gin.Context) {
+	rows, err := db.Pool.Query(adminContext(c),
+		`SELECT `+trainingModuleSelectCols+` FROM training_modules ORDER BY "isActive" DESC, "sortOrder", "createdAt"`)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to fetch training modules", err)
+		return
+	}
+	defer rows.Close()
+
+	modules := []models.TrainingModule{}
+	for rows.Next() {
+		var m models.TrainingModule
+		if err := scanTrainingModule(rows, &m); err == nil {
+			modules = append(modules, m)
+		}
+	}
+	utils.RespondSuccess(c, http.StatusOK, "Training modules", modules)
+}
+
+// PUT /api/v1/admin/training-module — create (no id) or update a module and its pass threshold
+func AdminUpsertTrainingModule(c *gin.Context) {
+	var body struct {
+		ID        string                    `json:"id"`
+		Title     string                    `json:"title" binding:"required"`
+		Content   string                    `json:"content"`
+		VideoURL  string                    `json:"videoUrl"`
+		Questions []models.TrainingQuestion `json:"questions"`
+		PassScore int                       `json:"passScore"` // percent, default 80
+		SortOrder int                       `json:"sortOrder"`
+		IsActive  *bool                     `json:"isActive"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid request", err)
+		return
+	}
+	if body.PassScore == 0 {
+		body.PassScore = defaultTrainingPassScore
+	}
+	if body.PassScore < 1 || body.PassScore > 100 {
+		utils.RespondError(c, http.StatusBadRequest, "passScore must be between 1 and 100", nil)
+		return
+	}
+	if body.Questions == nil {
+		body.Questions = []models.TrainingQuestion{}
+	}
+	for i, q := range body.Questions {
+		if strings.TrimSpace(q.Question) == "" || len(q.Options) < 2 {
+			utils.RespondError(c, http.StatusBadRequest, fmt.Sprintf("Question %d needs text and at least two options", i+1), nil)
+			return
+		}
+		if q.AnswerIndex < 0 || q.AnswerIndex >= len(q.Options) {
+			utils.RespondError(c, http.StatusBadRequest, fmt.Sprintf("Question %d has an answerIndex outside its options", i+1), nil)
+			return
+		}
+	}
+	isActive := true
+	if body.IsActive != nil {
+		isActive = *body.IsActive
+	}
+
+	questions, _ := json.Marshal(body.Questions)
+	var m models.TrainingModule
+	var err error
+	if body.ID == "" {
+		err = scanTrainingModule(db.Pool.QueryRow(adminContext(c),
+			`INSERT INTO training_modules (title, content, "videoUrl", questions, "passScore", "sortOrder", "isActive")
+			 VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING `+trainingModuleSelectCols,
+			body.Title, body.Content, body.VideoURL, questions, body.PassScore, body.SortOrder, isActive), &m)
+	} else {
+		err = scanTrainingModule(db.Pool.QueryRow(adminContext(c),
+			`UPDATE training_modules SET title=$2, content=$3, "videoUrl"=$4, questions=$5, "passScore"=$6,
+			 "sortOrder"=$7, "isActive"=$8, "updatedAt"=NOW()
+			 WHERE id=$1 RETURNING `+trainingModuleSelectCols,
+			body.ID, body.Title, body.Content, body.VideoURL, questions, body.PassScore, body.SortOrder, isActive), &m)
+	}
+	if err == pgx.ErrNoRows {
+		utils.RespondError(c, http.StatusNotFound, "Training module not found", nil)
+		return
+	}
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to save training module", err)
+		return
+	}
+	utils.RespondSuccess(c, http.StatusOK, "Training module saved", m)
+}
+
+// DELETE /api/v1/admin/training-module/:id — soft delete (deactivate); it no longer gates approval
+func AdminDeleteTrainingModule(c *gin.Context) {
+	tag, err := db.Pool.Exec(adminContext(c),
+		`UPDATE training_modules SET "isActive"=FALSE, "updatedAt"=NOW() WHERE id=$1`, c.Param("id"))
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to deactivate training module", err)
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		utils.RespondError(c, http.StatusNotFound, "Training module not found", nil)
+		return
+	}
+	utils.RespondSuccess(c, http.StatusOK, "Training module deactivated", nil)
+}
@@ -1,7 +1,6 @@
 package handlers
 
 import (
-	"context"
 	"fmt"
 	"io"
 	"math/rand"
@@ -10,8 +9,9 @@ import (
 	"strconv"
 	"time"
 
-	"ridewave/db"
+	"ridewave/middleware"
 	"ridewave/models"
+	"ridewave/repository"
 	"ridewave/utils"
 
 	"github.com/gin-gonic/gin"
@@ -19,18 +19,22 @@ import (
 )
 
 // RegisterUserRoutes defines all user-facing API endpoints
-func RegisterUserRoutes(r *gin.Engine, authMiddleware gin.HandlerFunc) {
-	userGroup := r.Group("/api/v1/user")
+func RegisterUserRoutes(r gin.IRouter, authMiddleware gin.HandlerFunc) {
+	userGroup := r.Group("/user")
 	{
 		// Auth
-		userGroup.POST("/auth/login", UserLogin)
-		userGroup.POST("/auth/verify", UserVerify)
+		userGroup.POST("/auth/login", middleware.RateLimitRoute("login", 5), UserLogin)
+		userGroup.POST("/auth/verify", middleware.RateLimitRoute("verify", 10), UserVerify)
+		userGroup.POST("/auth/refresh", middleware.RateLimitRoute("refresh", 30), UserRefreshToken)
 		userGroup.POST("/auth/logout", authMiddleware, UserLogout)
 
 		// Profile & Settings
 		userGroup.GET("/me", authMiddleware, GetLoggedInUserData)
 		userGroup.PUT("/profile", authMiddleware, UpdateUserProfile)
 		userGroup.PUT("/notification-token", authMiddleware, UpdateUserNotificationToken)
+		userGroup.PUT("/preferred-language", authMiddleware, UpdatePreferredLanguage)
+		userGroup.DELETE("/account", authMiddleware, RequestUserAccountDeletion)
+		userGroup.POST("/account/cancel-deletion", authMiddleware, CancelUserAccountDeletion)
 
 		// Vehicle types (for ride booking — user picks Car, Auto, Bike etc.)
 		userGroup.GET("/vehicle-types", authMiddleware, GetVehicleTypes)
@@ -41,18 +45,47 @@ func RegisterUserRoutes(r *gin.Engine, authMiddleware gin.HandlerFunc) {
 		userGroup.GET("/places/reverse-geocode", authMiddleware, ReverseGeocode)
 		userGroup.GET("/places/details", authMiddleware, GetPlaceDetails)
 		userGroup.GET("/places/nearby", authMiddleware, NearbySearch)
-		userGroup.POST("/ride/estimate", authMiddleware, GetRideEstimate)
+		userGroup.GET("/places/saved", authMiddleware, GetSavedPlaces)
+		userGroup.POST("/places/saved", authMiddleware, CreateSavedPlace)
+		userGroup.PUT("/places/saved/:id", authMiddleware, UpdateSavedPlace)
+		userGroup.DELETE("/places/saved/:id", authMiddleware, DeleteSavedPlace)
+		userGroup.POST("/ride/estimate", authMiddleware, middleware.RateLimitRoute("ride-estimate", 30), GetRideEstimate)
 		userGroup.POST("/ride/distance-matrix", authMiddleware, GetDistanceMatrix)
-
-		userGroup.POST("/ride/create", authMiddleware, CreateRide)
+		userGroup.POST("/promo/validate", authMiddleware, ValidatePromoCode)
+		userGroup.GET("/referral", authMiddleware, GetMyReferral)
+		userGroup.POST("/referral/apply", authMiddleware, ApplyReferralCode)
+
+		userGroup.POST("/ride/create", authMiddleware, middleware.RateLimitRoute("ride-create", 10), middleware.Idempotency(), CreateRide)
+		userGroup.POST("/ride/bid", authMiddleware, middleware.RateLimitRoute("ride-create", 10), middleware.Idempotency(), CreateFareOffer)
+		userGroup.GET("/ride/bid/:id", authMiddleware, GetFareOffer)
+		userGroup.POST("/ride/bid/:id/choose", authMiddleware, middleware.Idempotency(), ChooseFareBid)
+		userGroup.POST("/ride/bid/:id/cancel", authMiddleware, CancelFareOffer)
 		userGroup.POST("/ride/cancel", authMiddleware, CancelRide)
+		userGroup.POST("/ride/:id/rebook", authMiddleware, RebookRide)
+		userGroup.POST("/ride/arrive-by", authMiddleware, CreateArriveByRide)
+		userGroup.POST("/ride/schedule", authMiddleware, ScheduleRide)
+		userGroup.POST("/ride/scheduled/:id/cancel", authMiddleware, CancelScheduledRide)
+		userGroup.GET("/rides/scheduled", authMiddleware, GetScheduledRides)
 		userGroup.GET("/ride/:id", authMiddleware, GetRideDetails)
+		userGroup.GET("/ride/:id/timeline", authMiddleware, GetRideTimeline)
+		userGroup.GET("/ride/:id/invoice", authMiddleware, GetRideInvoice)
+		userGroup.POST("/ride/:id/dispute", authMiddleware, CreateRideDispute)
+		userGroup.GET("/ride/:id/dispute", authMiddleware, GetRideDispute)
 		userGroup.GET("/ride/:id/driver-location", authMiddleware, GetDriverLocation)
+		userGroup.POST("/ride/:id/share", authMiddleware, ShareRide)
 		userGroup.GET("/rides", authMiddleware, GetUserRides)
+		userGroup.GET("/carbon", authMiddleware, GetUserCarbonSummary)
 		userGroup.GET("/payment/:rideId", authMiddleware, GetPaymentReceipt)
-		userGroup.POST("/payment/verify-direct", authMiddleware, VerifyDirectPayment)
+		userGroup.POST("/payment/verify-direct", authMiddleware, middleware.Idempotency(), VerifyDirectPayment)
+		userGroup.GET("/rating-config", authMiddleware, GetRiderRatingConfig)
 		userGroup.POST("/rate-driver", authMiddleware, RateDriver)
+		userGroup.GET("/driver/:id/reviews", authMiddleware, GetDriverReviews)
+		userGroup.POST("/ride/:id/tip", authMiddleware, middleware.Idempotency(), TipDriver)
 		userGroup.POST("/sos", authMiddleware, TriggerSOS)
+		userGroup.GET("/communication-preferences", authMiddleware, GetCommunicationPreferences)
+		userGroup.PUT("/communication-preferences", authMiddleware, UpdateCommunicationPreference)
+		userGroup.GET("/notification-preferences", authMiddleware, GetUserNotificationPreferences)
+		userGroup.PUT("/notification-preferences", authMiddleware, UpdateUserNotificationPreferences)
 
 		// Ola Maps Advanced Features
 		userGroup.POST("/ola/geofence", authMiddleware, CreateGeofence)
@@ -70,22 +103,24 @@ func RegisterUserRoutes(r *gin.Engine, authMiddleware gin.HandlerFunc) {
 // User Authentication
 // ══════════════════════════════════════════════════
 
-// User select columns — consistent across all queries
-const userSelectCols = `id, name, phone_number, email, "notificationToken", ratings, "totalRides", status, "createdAt", "updatedAt"`
-
-func scanUser(scanner interface{ Scan(dest ...any) error }, u *models.User) error {
-	return scanner.Scan(&u.ID, &u.Name, &u.PhoneNumber, &u.Email, &u.NotificationToken, &u.Ratings, &u.TotalRides, &u.Status, &u.CreatedAt, &u.UpdatedAt)
-}
-
 // POST /api/v1/user/auth/login
 func UserLogin(c *gin.Context) {
 	var body struct {
 		PhoneNumber string `json:"phone_number" binding:"required"`
+		Country     string `json:"country"` // for numbers without a calling code; default region otherwise
 	}
 	if err := c.ShouldBindJSON(&body); err != nil {
 		utils.RespondError(c, http.StatusBadRequest, "Invalid request", err)
 		return
 	}
+	phone, ok := requestPhone(c, body.PhoneNumber, body.Country)
+	if !ok {
+		return
+	}
+	body.PhoneNumber = phone
+	if redirectToHomeRegion(c, body.PhoneNumber) {
+		return
+	}
 
 	if err := utils.SendTwilioOTP(body.PhoneNumber); err != nil {
 		utils.RespondError(c, http.StatusInternalServerError, "Failed to send OTP", err)
@@ -99,6 +134,7 @@ func UserLogin(c *gin.Context) {
 func UserVerify(c *gin.Context) {
 	var body struct {
 		PhoneNumber string `json:"phone_number" binding:"required"`
+		Country     string `json:"country"`
 		OTP         string `json:"otp" binding:"required"`
 		Name        string `json:"name"`
 		Email       string `json:"email"`
@@ -107,26 +143,34 @@ func UserVerify(c *gin.Context) {
 		utils.RespondError(c, http.StatusBadRequest, "Invalid request", err)
 		return
 	}
+	phone, ok := requestPhone(c, body.PhoneNumber, body.Country)
+	if !ok {
+		return
+	}
+	body.PhoneNumber = phone
 
 	if err := utils.VerifyTwilioOTP(body.PhoneNumber, body.OTP); err != nil {
-		utils.RespondError(c, http.StatusBadRequest, "Invalid OTP", err)
+		utils.RespondErrorCode(c, http.StatusBadRequest, utils.CodeOTPInvalid, "Invalid OTP", err)
 		return
 	}
 
-	var user models.User
-	row := db.Pool.QueryRow(context.Background(),
-		`SELECT `+userSelectCols+` FROM "user" WHERE phone_number=$1`, body.PhoneNumber)
-	if err := scanUser(row, &user); err == nil {
+	user, err := repos.Users.GetByPhone(c.Request.Context(), body.PhoneNumber)
+	if err == nil {
 		// Check if user is blocked
 		if user.Status == "suspended" {
-			utils.RespondError(c, http.StatusForbidden, "Your account has been suspended. Contact support.", nil)
+			utils.RespondErrorCode(c, http.StatusForbidden, utils.CodeAccountSuspended, "Your account has been suspended. Contact support.", nil)
 			return
 		}
 		if user.Status == "inactive" {
-			utils.RespondError(c, http.StatusForbidden, "Your account has been deactivated. Contact support.", nil)
+			utils.RespondErrorCode(c, http.StatusForbidden, utils.CodeAccountDeactivated, "Your account has been deactivated. Contact support.", nil)
 			return
 		}
-		utils.SendToken(c, &user, user.ID)
+		recordDeviceFingerprint(c, noteEntityUser, user.ID)
+		utils.SendToken(c, user, user.ID)
+		return
+	}
+	if err != repository.ErrNotFound {
+		utils.RespondError(c, http.StatusInternalServerError, "Database error", err)
 		return
 	}
 
@@ -136,24 +180,21 @@ func UserVerify(c *gin.Context) {
 		return
 	}
 
-	row = db.Pool.QueryRow(context.Background(),
-		`INSERT INTO "user" (id, name, email, phone_number, ratings, "totalRides", status, "createdAt", "updatedAt") 
-		VALUES (gen_random_uuid()::text, $1, $2, $3, 0, 0, 'active', NOW(), NOW()) 
-		RETURNING `+userSelectCols,
-		body.Name, body.Email, body.PhoneNumber)
-	if err := scanUser(row, &user); err != nil {
+	user, err = repos.Users.Create(c.Request.Context(), body.Name, body.Email, body.PhoneNumber)
+	if err != nil {
 		utils.RespondError(c, http.StatusInternalServerError, "Failed to create user", err)
 		return
 	}
 
-	utils.SendToken(c, &user, user.ID)
+	recordDeviceFingerprint(c, noteEntityUser, user.ID)
+	utils.SendToken(c, user, user.ID)
 }
 
 // POST /api/v1/user/auth/logout
 func UserLogout(c *gin.Context) {
 	user := c.MustGet("user").(*models.User)
-	db.Pool.Exec(context.Background(),
-		`UPDATE "user" SET "notificationToken"=NULL, "updatedAt"=NOW() WHERE id=$1`, user.ID)
+	repos.Users.SetNotificationToken(c.Request.Context(), user.ID, "")
+	endCurrentSession(c, utils.SessionUser, user.ID)
 	utils.RespondSuccess(c, http.StatusOK, "Logged out successfully", nil)
 }
 
@@ -174,15 +215,12 @@ func SendingOtpToEmail(c *gin.Context) {
 	}
 
 	if body.Email == "" {
-		var user models.User
-		row := db.Pool.QueryRow(context.Background(),
-			`UPDATE "user" SET name=$1, "updatedAt"=NOW() WHERE id=$2 RETURNING `+userSelectCols,
-			body.Name, body.UserID)
-		if err := scanUser(row, &user); err != nil {
+		user, err := repos.Users.UpdateProfile(c.Request.Context(), body.UserID, body.Name, "")
+		if err != nil {
 			utils.RespondError(c, http.StatusInternalServerError, "Database error", err)
 			return
 		}
-		utils.SendToken(c, &user, user.ID)
+		utils.SendToken(c, user, user.ID)
 		return
 	}
 
@@ -200,7 +238,7 @@ func SendingOtpToEmail(c *gin.Context) {
 	tokenStr, _ := token.SignedString([]byte(os.Getenv("EMAIL_ACTIVATION_SECRET")))
 
 	emailBody := fmt.Sprintf(`<p>Hi %s,</p><p>Your Ridewave verification code is <strong>%s</strong>. This code expires in 5 minutes.</p><p>If you didn't request this, please ignore this email.</p><p>Thanks,<br>Ridewave Team</p>`, body.Name, otp)
-	if err := utils.SendEmail([]string{body.Email}, "Verify your email address!", emailBody); err != nil {
+	if err := utils.SendEmail(utils.Recipient{}, []string{body.Email}, "Verify your email address!", emailBody); err != nil {
 		utils.RespondError(c, http.StatusBadRequest, "Failed to send email", err)
 		return
 	}
@@ -222,13 +260,13 @@ func VerifyingEmail(c *gin.Context) {
 		return []byte(os.Getenv("EMAIL_ACTIVATION_SECRET")), nil
 	})
 	if err != nil || !token.Valid {
-		utils.RespondError(c, http.StatusBadRequest, "Your OTP is expired!", err)
+		utils.RespondErrorCode(c, http.StatusBadRequest, utils.CodeOTPExpired, "Your OTP is expired!", err)
 		return
 	}
 
 	claims := token.Claims.(jwt.MapClaims)
 	if claims["otp"].(string) != body.OTP {
-		utils.RespondError(c, http.StatusBadRequest, "OTP is not correct or expired!", nil)
+		utils.RespondErrorCode(c, http.StatusBadRequest, utils.CodeOTPInvalid, "OTP is not correct or expired!", nil)
 		return
 	}
 
@@ -237,15 +275,12 @@ func VerifyingEmail(c *gin.Context) {
 	email := userMap["email"].(string)
 	userID := userMap["userId"].(string)
 
-	var user models.User
-	row := db.Pool.QueryRow(context.Background(),
-		`UPDATE "user" SET name=$1, email=$2, "updatedAt"=NOW() WHERE id=$3 RETURNING `+userSelectCols,
-		name, email, userID)
-	if err = scanUser(row, &user); err != nil {
+	user, err := repos.Users.UpdateProfile(c.Request.Context(), userID, name, email)
+	if err != nil {
 		utils.RespondError(c, http.StatusBadRequest, "Failed to update profile", err)
 		return
 	}
-	utils.SendToken(c, &user, user.ID)
+	utils.SendToken(c, user, user.ID)
 }
 
 // ══════════════════════════════════════════════════
@@ -270,12 +305,8 @@ func UpdateUserProfile(c *gin.Context) {
 		return
 	}
 
-	var updated models.User
-	row := db.Pool.QueryRow(context.Background(),
-		`UPDATE "user" SET name=COALESCE(NULLIF($1,''), name), email=COALESCE(NULLIF($2,''), email), "updatedAt"=NOW() WHERE id=$3 
-		RETURNING `+userSelectCols,
-		body.Name, body.Email, user.ID)
-	if err := scanUser(row, &updated); err != nil {
+	updated, err := repos.Users.UpdateProfile(c.Request.Context(), user.ID, body.Name, body.Email)
+	if err != nil {
 		utils.RespondError(c, http.StatusInternalServerError, "Failed to update profile", err)
 		return
 	}
@@ -293,11 +324,8 @@ func UpdateUserNotificationToken(c *gin.Context) {
 		return
 	}
 
-	var updated models.User
-	row := db.Pool.QueryRow(context.Background(),
-		`UPDATE "user" SET "notificationToken"=$1, "updatedAt"=NOW() WHERE id=$2 RETURNING `+userSelectCols,
-		body.NotificationToken, user.ID)
-	if err := scanUser(row, &updated); err != nil {
+	updated, err := repos.Users.SetNotificationToken(c.Request.Context(), user.ID, body.NotificationToken)
+	if err != nil {
 		utils.RespondError(c, http.StatusInternalServerError, "Database error", err)
 		return
 	}
@@ -308,72 +336,72 @@ func UpdateUserNotificationToken(c *gin.Context) {
 // User Ride Operations
 // ══════════════════════════════════════════════════
 
-// GET /api/v1/user/rides
+// GET /api/v1/user/rides?page=1&limit=20&status=Completed&from=2026-01-01&to=2026-01-31
+// Also pages by ?cursor=. The summary covers every ride matching the filters, not just this page.
 func GetUserRides(c *gin.Context) {
 	user := c.MustGet("user").(*models.User)
-
-	rows, err := db.Pool.Query(context.Background(),
-		`SELECT r.id, r."userId", r."driverId", r.charge, r."currentLocationName", r."destinationLocationName", 
-		 r.distance, r.status, r.rating, COALESCE(r."vehicleType",''), COALESCE(r."paymentMode",''), 
-		 COALESCE(r."paymentStatus",'Pending'), COALESCE(r.tips, 0), r."createdAt", r."updatedAt",
-		 COALESCE(d.id,''), COALESCE(d.name,''), COALESCE(d.phone_number,''), COALESCE(d.vehicle_type,''),
-		 COALESCE(d.vehicle_color,''), COALESCE(d.registration_number,''), COALESCE(d.ratings,0),
-		 COALESCE(d."profileImage",'')
-		FROM rides r 
-		LEFT JOIN driver d ON r."driverId"=d.id 
-		WHERE r."userId"=$1 ORDER BY r."createdAt" DESC`, user.ID)
+	pg, err := utils.ParsePagination(c)
+	if err != nil {
+		utils.RespondErrorCode(c, http.StatusBadRequest, utils.CodeInvalidCursor, "Invalid cursor", err)
+		return
+	}
+	history, sum, err := repos.Rides.UserHistory(c.Request.Context(), user.ID, rideHistoryFilter(c), pg)
 	if err != nil {
 		utils.RespondError(c, http.StatusInternalServerError, "Internal server error", err)
 		return
 	}
-	defer rows.Close()
 
 	type RideWithDriver struct {
-		ID                      string   `json:"id"`
-		UserID                  string   `json:"userId"`
-		DriverID                *string  `json:"driverId"`
-		Charge                  float64  `json:"charge"`
-		CurrentLocationName     string   `json:"currentLocationName"`
-		DestinationLocationName string   `json:"destinationLocationName"`
-		Distance                string   `json:"distance"`
-		Status                  string   `json:"status"`
-		Rating                  *float64 `json:"rating"`
-		VehicleType             string   `json:"vehicleType"`
-		PaymentMode             string   `json:"paymentMode"`
-		PaymentStatus           string   `json:"paymentStatus"`
-		Tips                    float64  `json:"tips"`
-		CreatedAt               string   `json:"createdAt"`
-		UpdatedAt               string   `json:"updatedAt"`
-		Driver                  *gin.H   `json:"driver,omitempty"`
-	}
-
-	var rides []RideWithDriver
-	for rows.Next() {
-		var r RideWithDriver
-		var dID, dName, dPhone, dVehicle, dColor, dRegNo, dImage string
-		var dRating float64
-		rows.Scan(&r.ID, &r.UserID, &r.DriverID, &r.Charge, &r.CurrentLocationName, &r.DestinationLocationName,
-			&r.Distance, &r.Status, &r.Rating, &r.VehicleType, &r.PaymentMode, &r.PaymentStatus, &r.Tips,
-			&r.CreatedAt, &r.UpdatedAt,
-			&dID, &dName, &dPhone, &dVehicle, &dColor, &dRegNo, &dRating, &dImage)
-		if dID != "" {
+		ID                      string    `json:"id"`
+		UserID                  string    `json:"userId"`
+		DriverID                *string   `json:"driverId"`
+		Charge                  float64   `json:"charge"`
+		CurrentLocationName     string    `json:"currentLocationName"`
+		DestinationLocationName string    `json:"destinationLocationName"`
+		Distance                string    `json:"distance"`
+		Status                  string    `json:"status"`
+		Rating                  *float64  `json:"rating"`
+		VehicleType             string    `json:"vehicleType"`
+		PaymentMode             string    `json:"paymentMode"`
+		PaymentStatus           string    `json:"paymentStatus"`
+		Tips                    float64   `json:"tips"`
+		CreatedAt               time.Time `json:"createdAt"`
+		UpdatedAt               time.Time `json:"updatedAt"`
+		Driver                  *gin.H    `json:"driver,omitempty"`
+	}
+
+	rides := make([]RideWithDriver, 0, len(history))
+	for _, h := range history {
+		r := RideWithDriver{
+			ID: h.ID, UserID: h.UserID, DriverID: h.DriverID, Charge: h.Charge,
+			CurrentLocationName: h.CurrentLocationName, DestinationLocationName: h.DestinationLocationName,
+			Distance: h.Distance, Status: h.Status, Rating: h.Rating, VehicleType: h.VehicleType,
+			PaymentMode: h.PaymentMode, PaymentStatus: h.PaymentStatus, Tips: h.Tips,
+			CreatedAt: h.CreatedAt, UpdatedAt: h.UpdatedAt,
+		}
+		if d := h.Driver; d != nil {
+			vehicleColor := ""
+			if d.VehicleColor != nil {
+				vehicleColor = *d.VehicleColor
+			}
 			r.Driver = &gin.H{
-				"id":                 dID,
-				"name":               dName,
-				"phoneNumber":        dPhone,
-				"vehicleType":        dVehicle,
-				"vehicleColor":       dColor,
-				"registrationNumber": dRegNo,
-				"ratings":            dRating,
-				"profileImage":       dImage,
+				"id":                 d.ID,
+				"name":               d.Name,
+				"phoneNumber":        d.PhoneNumber,
+				"vehicleType":        d.VehicleType,
+				"vehicleColor":       vehicleColor,
+				"registrationNumber": d.RegistrationNumber,
+				"ratings":            d.Ratings,
+				"profileImage":       d.ProfileImage,
 			}
 		}
 		rides = append(rides, r)
 	}
-	if rides == nil {
-		rides = []RideWithDriver{}
-	}
-	utils.RespondSuccess(c, http.StatusOK, "Rides retrieved", gin.H{"rides": rides})
+
+	rides, resp := utils.Paginate(pg, rides, sum.Rides, func(r RideWithDriver) (time.Time, string) { return r.CreatedAt, r.ID })
+	resp["rides"] = rides
+	resp["summary"] = gin.H{"rides": sum.Rides, "completedRides": sum.Completed, "totalSpent": sum.Amount}
+	utils.RespondSuccess(c, http.StatusOK, "Rides retrieved", resp)
 }
 
 // ══════════════════════════════════════════════════
@@ -386,31 +414,24 @@ func GetDriverLocation(c *gin.Context) {
 	user := c.MustGet("user").(*models.User)
 
 	// Verify this ride belongs to the user
-	var driverID *string
-	err := db.Pool.QueryRow(context.Background(),
-		`SELECT "driverId" FROM rides WHERE id=$1 AND "userId"=$2`, rideID, user.ID).Scan(&driverID)
-	if err != nil || driverID == nil {
+	ride, err := repos.Rides.GetForUser(c.Request.Context(), rideID, user.ID)
+	if err != nil || ride.DriverID == nil {
 		utils.RespondError(c, http.StatusNotFound, "Ride or driver not found", err)
 		return
 	}
 
 	// Get driver's live location
-	var lat, lng float64
-	var heading *float64
-	var updatedAt time.Time
-	err = db.Pool.QueryRow(context.Background(),
-		`SELECT lat, lng, heading, "updatedAt" FROM driver_location WHERE "driverId"=$1`, *driverID).
-		Scan(&lat, &lng, &heading, &updatedAt)
+	loc, err := repos.Drivers.Location(c.Request.Context(), *ride.DriverID)
 	if err != nil {
 		utils.RespondError(c, http.StatusNotFound, "Driver location not available", err)
 		return
 	}
 
 	utils.RespondSuccess(c, http.StatusOK, "Driver location", gin.H{
-		"lat":       lat,
-		"lng":       lng,
-		"heading":   heading,
-		"updatedAt": updatedAt,
+		"lat":       loc.Lat,
+		"lng":       loc.Lng,
+		"heading":   loc.Heading,
+		"updatedAt": loc.UpdatedAt,
 	})
 }
 
@@ -420,24 +441,24 @@ func GetPaymentReceipt(c *gin.Context) {
 	user := c.MustGet("user").(*models.User)
 
 	// Verify ride belongs to user
-	var rideExists bool
-	db.Pool.QueryRow(context.Background(),
-		`SELECT EXISTS(SELECT 1 FROM rides WHERE id=$1 AND "userId"=$2)`, rideID, user.ID).Scan(&rideExists)
-	if !rideExists {
-		utils.RespondError(c, http.StatusNotFound, "Ride not found", nil)
+	if owned, _ := repos.Rides.BelongsToUser(c.Request.Context(), rideID, user.ID); !owned {
+		utils.RespondErrorCode(c, http.StatusNotFound, utils.CodeRideNotFound, "Ride not found", nil)
 		return
 	}
 
-	var payment models.Payment
-	err := db.Pool.QueryRow(context.Background(),
-		`SELECT id, "rideId", amount, mode, status, "createdAt" FROM payments WHERE "rideId"=$1`, rideID).
-		Scan(&payment.ID, &payment.RideID, &payment.Amount, &payment.Mode, &payment.Status, &payment.CreatedAt)
+	payment, err := repos.Payments.ForRide(c.Request.Context(), rideID)
 	if err != nil {
 		utils.RespondError(c, http.StatusNotFound, "Payment not found", err)
 		return
 	}
 
-	utils.RespondSuccess(c, http.StatusOK, "Payment receipt", gin.H{"payment": payment})
+	refunds, refunded := listRideRefunds(c.Request.Context(), rideID)
+	utils.RespondSuccess(c, http.StatusOK, "Payment receipt", gin.H{
+		"payment":        payment,
+		"refunds":        refunds,
+		"refundedAmount": refunded,
+		"netAmount":      payment.Amount - refunded,
+	})
 }
 
 // POST /api/v1/user/payment/verify-direct
@@ -454,34 +475,24 @@ func VerifyDirectPayment(c *gin.Context) {
 	}
 
 	// 1. Validate Ride
-	var rideStatus string
-	var driverID *string
-	err := db.Pool.QueryRow(context.Background(),
-		`SELECT status, "driverId" FROM rides WHERE id=$1`, body.RideID).Scan(&rideStatus, &driverID)
-	if err != nil {
-		utils.RespondError(c, http.StatusNotFound, "Ride not found", err)
+	if _, _, err := repos.Rides.Status(c.Request.Context(), body.RideID); err != nil {
+		utils.RespondErrorCode(c, http.StatusNotFound, utils.CodeRideNotFound, "Ride not found", err)
 		return
 	}
 
-	// 2. Record Payment
-	_, err = db.Pool.Exec(context.Background(),
-		`INSERT INTO payments ("rideId", amount, mode, status, "createdAt") VALUES ($1, $2, $3, 'success', NOW())`,
-		body.RideID, body.Amount, body.Mode)
+	// 2. Record Payment and mark the ride paid (idempotent — a ride's fare is never stored twice)
+	recorded, err := recordRidePayment(body.RideID, body.Amount, body.Mode)
 	if err != nil {
 		utils.RespondError(c, http.StatusInternalServerError, "Failed to record payment", err)
 		return
 	}
-
-	// 3. Update Ride Status & Payment Info
-	_, err = db.Pool.Exec(context.Background(),
-		`UPDATE rides SET "paymentStatus"='Paid', "paymentMode"=$1, "updatedAt"=NOW() WHERE id=$2`,
-		body.Mode, body.RideID)
-
-	if err != nil {
-		utils.RespondError(c, http.StatusInternalServerError, "Failed to update ride payment status", err)
+	if !recorded {
+		utils.RespondSuccess(c, http.StatusOK, "Payment already recorded", nil)
 		return
 	}
 
+	go notifyPaymentStatus(body.RideID, "Paid", body.Mode, body.Amount)
+
 	utils.RespondSuccess(c, http.StatusOK, "Payment recorded successfully", nil)
 }
 
@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"ridewave/models"
+	"ridewave/repository"
+	"ridewave/repository/mock"
+	"ridewave/utils"
+)
+
+// seedUserRides gives rider u1 a completed ride with driver d1, then a cancelled one an hour
+// later, and gives another rider a completed ride.
+func seedUserRides(r repository.Repos) *models.User {
+	user := &models.User{ID: "u1", Name: strPtr("Meera")}
+	r.Users.(*mock.Users).ByID[user.ID] = user
+
+	t0 := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	driver := &models.Driver{ID: "d1", Name: "Ravi", VehicleType: "Auto", VehicleColor: strPtr("Yellow")}
+	rides := r.Rides.(*mock.Rides).ByID
+	rides["r1"] = &models.Ride{ID: "r1", UserID: "u1", DriverID: strPtr("d1"), Driver: driver, Status: "Completed",
+		Charge: 120, Tips: 10, CreatedAt: t0}
+	rides["r2"] = &models.Ride{ID: "r2", UserID: "u1", Status: "Cancelled", Charge: 80, CreatedAt: t0.Add(time.Hour)}
+	rides["r3"] = &models.Ride{ID: "r3", UserID: "u2", Status: "Completed", Charge: 300, CreatedAt: t0}
+	return user
+}
+
+type userRidesData struct {
+	Rides []struct {
+		ID     string         `json:"id"`
+		Status string         `json:"status"`
+		Driver map[string]any `json:"driver"`
+	} `json:"rides"`
+	Total      int `json:"total"`
+	TotalPages int `json:"totalPages"`
+	Summary    struct {
+		Rides          int     `json:"rides"`
+		CompletedRides int     `json:"completedRides"`
+		TotalSpent     float64 `json:"totalSpent"`
+	} `json:"summary"`
+}
+
+func TestGetUserRidesPagesNewestFirstWithFullSummary(t *testing.T) {
+	r := useMockRepos(t)
+	user := seedUserRides(r)
+
+	resp := serve(t, GetUserRides, http.MethodGet, "/api/v1/user/rides?limit=1", nil, user)
+	if resp.Status != http.StatusOK {
+		t.Fatalf("status = %d, want 200 (%s)", resp.Status, resp.Message)
+	}
+	var data userRidesData
+	resp.decode(t, &data)
+
+	if len(data.Rides) != 1 || data.Rides[0].ID != "r2" {
+		t.Fatalf("rides = %+v, want only the newest ride r2", data.Rides)
+	}
+	if data.Rides[0].Driver != nil {
+		t.Errorf("unassigned ride has driver %v", data.Rides[0].Driver)
+	}
+	if data.Total != 2 || data.TotalPages != 2 {
+		t.Errorf("total = %d over %d pages, want 2 over 2", data.Total, data.TotalPages)
+	}
+	// The summary covers every matching ride, not just this page
+	if s := data.Summary; s.Rides != 2 || s.CompletedRides != 1 || s.TotalSpent != 130 {
+		t.Errorf("summary = %+v, want 2 rides, 1 completed, 130 spent", s)
+	}
+}
+
+func TestGetUserRidesFiltersByStatusAndIncludesDriver(t *testing.T) {
+	r := useMockRepos(t)
+	user := seedUserRides(r)
+
+	resp := serve(t, GetUserRides, http.MethodGet, "/api/v1/user/rides?status=Completed", nil, user)
+	var data userRidesData
+	resp.decode(t, &data)
+
+	if len(data.Rides) != 1 || data.Rides[0].ID != "r1" {
+		t.Fatalf("rides = %+v, want the rider's completed ride r1", data.Rides)
+	}
+	driver := data.Rides[0].Driver
+	if driver["id"] != "d1" || driver["name"] != "Ravi" || driver["vehicleColor"] != "Yellow" {
+		t.Errorf("driver = %v, want d1 Ravi with a yellow vehicle", driver)
+	}
+}
+
+func TestGetUserRidesRejectsBadCursor(t *testing.T) {
+	r := useMockRepos(t)
+	user := seedUserRides(r)
+
+	resp := serve(t, GetUserRides, http.MethodGet, "/api/v1/user/rides?cursor=not-a-cursor", nil, user)
+	if resp.Status != http.StatusBadRequest || resp.Code != utils.CodeInvalidCursor {
+		t.Errorf("got %d %s, want 400 %s", resp.Status, resp.Code, utils.CodeInvalidCursor)
+	}
+}
+
+func TestGetDriverLocation(t *testing.T) {
+	r := useMockRepos(t)
+	user := seedUserRides(r)
+	updatedAt := time.Date(2026, 3, 2, 9, 5, 0, 0, time.UTC)
+	r.Drivers.(*mock.Drivers).Locations["d1"] = repository.DriverLocation{Lat: 12.97, Lng: 77.59, Heading: floatPtr(90), UpdatedAt: updatedAt}
+
+	resp := serve(t, GetDriverLocation, http.MethodGet, "/api/v1/user/ride/r1/driver-location", nil, user,
+		gin.Param{Key: "id", Value: "r1"})
+	if resp.Status != http.StatusOK {
+		t.Fatalf("status = %d, want 200 (%s)", resp.Status, resp.Message)
+	}
+	var loc struct {
+		Lat, Lng  float64
+		Heading   *float64
+		UpdatedAt time.Time
+	}
+	resp.decode(t, &loc)
+	if loc.Lat != 12.97 || loc.Lng != 77.59 || loc.Heading == nil || *loc.Heading != 90 || !loc.UpdatedAt.Equal(updatedAt) {
+		t.Errorf("location = %+v", loc)
+	}
+}
+
+func TestGetDriverLocationNotFound(t *testing.T) {
+	r := useMockRepos(t)
+	user := seedUserRides(r)
+
+	for name, rideID := range map[string]string{
+		"no driver assigned": "r2",
+		"another rider's":    "r3",
+		"missing ride":       "nope",
+	} {
+		resp := serve(t, GetDriverLocation, http.MethodGet, "/api/v1/user/ride/"+rideID+"/driver-location", nil, user,
+			gin.Param{Key: "id", Value: rideID})
+		if resp.Status != http.StatusNotFound {
+			t.Errorf("%s: status = %d, want 404", name, resp.Status)
+		}
+	}
+
+	// An assigned driver who hasn't sent a location yet
+	resp := serve(t, GetDriverLocation, http.MethodGet, "/api/v1/user/ride/r1/driver-location", nil, user,
+		gin.Param{Key: "id", Value: "r1"})
+	if resp.Status != http.StatusNotFound || resp.Message != "Driver location not available" {
+		t.Errorf("got %d %q, want 404 for the missing location", resp.Status, resp.Message)
+	}
+}
+
+func TestGetPaymentReceiptHidesOtherRidersRides(t *testing.T) {
+	r := useMockRepos(t)
+	user := seedUserRides(r)
+	r.Payments.(*mock.Payments).ByRide["r3"] = []models.Payment{{ID: "p3", RideID: "r3", Amount: 300, Status: "paid"}}
+
+	resp := serve(t, GetPaymentReceipt, http.MethodGet, "/api/v1/user/payment/r3", nil, user,
+		gin.Param{Key: "rideId", Value: "r3"})
+	if resp.Status != http.StatusNotFound || resp.Code != utils.CodeRideNotFound {
+		t.Errorf("got %d %s, want 404 %s", resp.Status, resp.Code, utils.CodeRideNotFound)
+	}
+}
+
+func TestUpdateUserProfile(t *testing.T) {
+	r := useMockRepos(t)
+	user := seedUserRides(r)
+
+	resp := serve(t, UpdateUserProfile, http.MethodPut, "/api/v1/user/profile",
+		map[string]string{"email": "meera@example.com"}, user)
+	if resp.Status != http.StatusOK {
+		t.Fatalf("status = %d, want 200 (%s)", resp.Status, resp.Message)
+	}
+	var data struct {
+		User models.User `json:"user"`
+	}
+	resp.decode(t, &data)
+	if data.User.Email == nil || *data.User.Email != "meera@example.com" {
+		t.Errorf("email = %v, want meera@example.com", data.User.Email)
+	}
+	// A field left out of the request is kept
+	if data.User.Name == nil || *data.User.Name != "Meera" {
+		t.Errorf("name = %v, want Meera kept", data.User.Name)
+	}
+}
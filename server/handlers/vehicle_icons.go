@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"ridewave/db"
+	"ridewave/utils"
+)
+
+// ══════════════════════════════════════════════════
+// Vehicle Type Icons — uploaded assets served to client apps
+// ══════════════════════════════════════════════════
+
+// vehicleIconPath is where an icon is served; vehicle types return it as iconUrl.
+const vehicleIconPath = "/api/v1/public/vehicle-type-icon/"
+
+const maxVehicleIconBytes = 256 * 1024
+
+// vehicleIconTypes are the accepted image formats (sniffed, not taken from the upload). SVG is
+// left out: it can carry script.
+var vehicleIconTypes = map[string]bool{"image/png": true, "image/jpeg": true, "image/webp": true, "image/gif": true}
+
+// deleteOrphanIcon removes an icon no vehicle type uses any more.
+func deleteOrphanIcon(ctx context.Context, assetID *string) {
+	if assetID == nil {
+		return
+	}
+	db.Pool.Exec(ctx,
+		`DELETE FROM vehicle_type_icons WHERE id=$1 AND NOT EXISTS (SELECT 1 FROM vehicle_types WHERE "iconAssetId"=$1)`, *assetID)
+}
+
+// POST /api/v1/admin/vehicle-type/:id/icon — multipart "icon" (PNG, JPEG, WebP or GIF, max 256KB)
+func AdminUploadVehicleTypeIcon(c *gin.Context) {
+	file, _, err := c.Request.FormFile("icon")
+	if err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "icon file is required", err)
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxVehicleIconBytes+1))
+	if err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Failed to read icon", err)
+		return
+	}
+	if len(data) > maxVehicleIconBytes {
+		utils.RespondError(c, http.StatusRequestEntityTooLarge, "Icon must be 256KB or smaller", nil)
+		return
+	}
+	contentType := http.DetectContentType(data)
+	if !vehicleIconTypes[contentType] {
+		utils.RespondError(c, http.StatusUnsupportedMediaType, "Icon must be a PNG, JPEG, WebP or GIF image", nil)
+		return
+	}
+
+	ctx := adminContext(c)
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to save icon", err)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	var previous *string
+	if err := tx.QueryRow(ctx,
+		`SELECT "iconAssetId" FROM vehicle_types WHERE id=$1 FOR UPDATE`, c.Param("id")).Scan(&previous); err != nil {
+		utils.RespondError(c, http.StatusNotFound, "Vehicle type not found", err)
+		return
+	}
+
+	// Every upload gets a new ID, so clients can cache an icon URL forever
+	var assetID string
+	err = tx.QueryRow(ctx,
+		`INSERT INTO vehicle_type_icons ("contentType", data) VALUES ($1, $2) RETURNING id`, contentType, data).Scan(&assetID)
+	if err == nil {
+		_, err = tx.Exec(ctx,
+			`UPDATE vehicle_types SET "iconAssetId"=$1, "updatedAt"=NOW() WHERE id=$2`, assetID, c.Param("id"))
+	}
+	if err == nil {
+		err = tx.Commit(ctx)
+	}
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to save icon", err)
+		return
+	}
+
+	invalidateVehicleTypes(ctx)
+	deleteOrphanIcon(ctx, previous)
+	utils.RespondSuccess(c, http.StatusOK, "Icon uploaded", gin.H{"iconUrl": vehicleIconPath + assetID})
+}
+
+// DELETE /api/v1/admin/vehicle-type/:id/icon — back to the app's built-in icon for `icon`
+func AdminDeleteVehicleTypeIcon(c *gin.Context) {
+	ctx := adminContext(c)
+	var previous *string
+	err := db.Pool.QueryRow(ctx,
+		`UPDATE vehicle_types v SET "iconAssetId"=NULL, "updatedAt"=NOW()
+		 FROM (SELECT id, "iconAssetId" FROM vehicle_types WHERE id=$1 FOR UPDATE) old
+		 WHERE v.id=old.id RETURNING old."iconAssetId"`, c.Param("id")).Scan(&previous)
+	if err != nil {
+		utils.RespondError(c, http.StatusNotFound, "Vehicle type not found", err)
+		return
+	}
+
+	invalidateVehicleTypes(ctx)
+	deleteOrphanIcon(ctx, previous)
+	utils.RespondSuccess(c, http.StatusOK, "Icon removed", nil)
+}
+
+// GET /api/v1/public/vehicle-type-icon/:id
+func GetVehicleTypeIcon(c *gin.Context) {
+	var contentType string
+	var data []byte
+	err := db.Pool.QueryRow(c.Request.Context(),
+		`SELECT "contentType", data FROM vehicle_type_icons WHERE id=$1`, c.Param("id")).Scan(&contentType, &data)
+	if err != nil {
+		utils.RespondError(c, http.StatusNotFound, "Icon not found", nil)
+		return
+	}
+	c.Header("Cache-Control", "public, max-age=31536000, immutable")
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Data(http.StatusOK, contentType, data)
+}
@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"ridewave/db"
+	"ridewave/models"
+	"ridewave/stores"
+	"ridewave/utils"
+)
+
+// ══════════════════════════════════════════════════
+// Vehicle Type Availability — zone & time-window rules
+// ══════════════════════════════════════════════════
+
+const vehicleTypeSelectCols = `id, name, "baseFare", "perKmRate", "perMinRate", COALESCE(icon, ''), "isActive", "createdAt", "updatedAt",
+	COALESCE("allowedZones", '{}'), "availableFrom", "availableUntil", "iconAssetId", capacity, description, "etaBlurb",
+	"isElectric", "emissionFactor"`
+
+// scanVehicleType scans vehicleTypeSelectCols, then any extra columns selected after them into extra.
+func scanVehicleType(scanner interface{ Scan(dest ...any) error }, vt *models.VehicleTypeConfig, extra ...any) error {
+	dest := []any{&vt.ID, &vt.Name, &vt.BaseFare, &vt.PerKmRate, &vt.PerMinRate, &vt.Icon, &vt.IsActive,
+		&vt.CreatedAt, &vt.UpdatedAt, &vt.AllowedZones, &vt.AvailableFrom, &vt.AvailableUntil,
+		&vt.IconAssetID, &vt.Capacity, &vt.Description, &vt.ETABlurb, &vt.IsElectric, &vt.EmissionFactor}
+	err := scanner.Scan(append(dest, extra...)...)
+	if err == nil && vt.IconAssetID != nil {
+		vt.IconURL = vehicleIconPath + *vt.IconAssetID
+	}
+	return err
+}
+
+// vehicleTypesConfig is the config cache entry holding each tenant's vehicle types.
+const vehicleTypesConfig = "vehicle_types"
+
+// cachedVehicleType is a vehicle type as kept in the config cache, with the commission its fares are priced at.
+type cachedVehicleType struct {
+	models.VehicleTypeConfig
+	CommissionPercent *float64 `json:"commissionPercent"`
+}
+
+// loadVehicleTypes returns the caller's tenant's vehicle types, active or not, cheapest first.
+// They come from the config cache; every admin change to a vehicle type invalidates it.
+func loadVehicleTypes(ctx context.Context) ([]cachedVehicleType, error) {
+	scope := db.TenantFrom(ctx)
+	if scope == "" {
+		scope = "*" // unscoped callers see every tenant's types, as they would in the table
+	}
+	return stores.CachedConfig(ctx, vehicleTypesConfig, scope, func(ctx context.Context) ([]cachedVehicleType, error) {
+		rows, err := db.Pool.Query(ctx,
+			`SELECT `+vehicleTypeSelectCols+`, "commissionPercent" FROM vehicle_types ORDER BY "baseFare" ASC`)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		types := []cachedVehicleType{}
+		for rows.Next() {
+			var vt cachedVehicleType
+			if err := scanVehicleType(rows, &vt.VehicleTypeConfig, &vt.CommissionPercent); err != nil {
+				return nil, err
+			}
+			types = append(types, vt)
+		}
+		return types, rows.Err()
+	})
+}
+
+// findVehicleType looks up one of the caller's vehicle types by name, or nil if there's none.
+func findVehicleType(ctx context.Context, name string) *cachedVehicleType {
+	types, err := loadVehicleTypes(ctx)
+	if err != nil {
+		return nil
+	}
+	for i := range types {
+		if types[i].Name == name {
+			return &types[i]
+		}
+	}
+	return nil
+}
+
+// invalidateVehicleTypes drops the cached vehicle types after an admin change.
+func invalidateVehicleTypes(ctx context.Context) {
+	if err := stores.InvalidateConfig(ctx, vehicleTypesConfig); err != nil {
+		utils.Logger.Warn("Failed to invalidate cached vehicle types", zap.Error(err))
+	}
+}
+
+// serviceLocation is the timezone vehicle time windows are evaluated in (SERVICE_TIMEZONE, default Asia/Kolkata).
+func serviceLocation() *time.Location {
+	name := os.Getenv("SERVICE_TIMEZONE")
+	if name == "" {
+		name = "Asia/Kolkata"
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// zoneForPoint returns the name of the service zone containing the point, or "" if none does.
+func zoneForPoint(lat, lng float64) string {
+	for _, zone := range activeServiceZones() {
+		if zoneContains(zone, lat, lng) {
+			return zone.Name
+		}
+	}
+	return ""
+}
+
+// applyVehicleAvailability evaluates a vehicle type's rules and sets Available/UnavailableReason.
+// An empty zone skips the zone check (e.g. the client didn't send a location).
+func applyVehicleAvailability(vt *models.VehicleTypeConfig, zone string, now time.Time) {
+	vt.Available = true
+	vt.UnavailableReason = ""
+
+	if zone != "" && len(vt.AllowedZones) > 0 {
+		allowed := false
+		for _, z := range vt.AllowedZones {
+			if strings.EqualFold(z, zone) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			vt.Available = false
+			vt.UnavailableReason = fmt.Sprintf("%s is not available in %s", vt.Name, zone)
+			return
+		}
+	}
+
+	if vt.AvailableFrom != nil && vt.AvailableUntil != nil {
+		from, errFrom := utils.ParseClock(*vt.AvailableFrom)
+		until, errUntil := utils.ParseClock(*vt.AvailableUntil)
+		if errFrom != nil || errUntil != nil {
+			return // Misconfigured window — fail open rather than hide the vehicle
+		}
+
+		local := now.In(serviceLocation())
+		minute := local.Hour()*60 + local.Minute()
+		if !utils.InClockWindow(minute, from, until) {
+			vt.Available = false
+			vt.UnavailableReason = fmt.Sprintf("%s is available between %s and %s", vt.Name, *vt.AvailableFrom, *vt.AvailableUntil)
+		}
+	}
+}
+
+// checkVehicleAvailability reports whether a vehicle type can be booked at the pickup point right now.
+// Types missing from the table fall back to default pricing and are always allowed.
+func checkVehicleAvailability(ctx context.Context, vehicleType string, pickupLat, pickupLng float64) (bool, string) {
+	cached := findVehicleType(ctx, vehicleType)
+	if cached == nil {
+		return true, ""
+	}
+	vt := cached.VehicleTypeConfig
+	if !vt.IsActive {
+		return false, fmt.Sprintf("%s is currently unavailable", vt.Name)
+	}
+
+	applyVehicleAvailability(&vt, zoneForPoint(pickupLat, pickupLng), time.Now())
+	return vt.Available, vt.UnavailableReason
+}
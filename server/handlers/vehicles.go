@@ -0,0 +1,356 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"ridewave/db"
+	"ridewave/models"
+	"ridewave/stores"
+	"ridewave/utils"
+)
+
+// ══════════════════════════════════════════════════
+// Driver Vehicles — several per driver, one active
+// ══════════════════════════════════════════════════
+//
+// Every vehicle carries its own documents and is approved by support on its own. A driver drives
+// one vehicle at a time; switching copies it onto the driver row (a trigger, see
+// db.ChangeDriverVehicles), which is what dispatch and the ride screens read.
+
+// Vehicle review states.
+const (
+	vehiclePending  = "pending"
+	vehicleApproved = "approved"
+	vehicleRejected = "rejected"
+)
+
+type vehicle struct {
+	ID                 string     `json:"id"`
+	DriverID           string     `json:"driverId"`
+	VehicleType        string     `json:"vehicleType"`
+	RegistrationNumber string     `json:"registrationNumber"`
+	RegistrationDate   string     `json:"registrationDate"`
+	Color              *string    `json:"color"`
+	RCBook             *string    `json:"rcBook"`
+	InsuranceDoc       *string    `json:"insuranceDoc"`
+	PermitDoc          *string    `json:"permitDoc"`
+	Status             string     `json:"status"` // pending | approved | rejected
+	RejectionReason    *string    `json:"rejectionReason"`
+	ReviewedBy         *string    `json:"reviewedBy"`
+	ReviewedAt         *time.Time `json:"reviewedAt"`
+	IsActive           bool       `json:"isActive"`
+	CreatedAt          time.Time  `json:"createdAt"`
+	UpdatedAt          time.Time  `json:"updatedAt"`
+}
+
+const vehicleSelectCols = `v.id, v."driverId", v."vehicleType", v."registrationNumber", v."registrationDate", v.color,
+	v."rcBook", v."insuranceDoc", v."permitDoc", v.status, v."rejectionReason", v."reviewedBy", v."reviewedAt",
+	v."isActive", v."createdAt", v."updatedAt"`
+
+// scanVehicle reads vehicleSelectCols, followed by any extra columns into extra.
+func scanVehicle(scanner interface{ Scan(dest ...any) error }, v *vehicle, extra ...any) error {
+	return scanner.Scan(append([]any{&v.ID, &v.DriverID, &v.VehicleType, &v.RegistrationNumber, &v.RegistrationDate,
+		&v.Color, &v.RCBook, &v.InsuranceDoc, &v.PermitDoc, &v.Status, &v.RejectionReason, &v.ReviewedBy, &v.ReviewedAt,
+		&v.IsActive, &v.CreatedAt, &v.UpdatedAt}, extra...)...)
+}
+
+// nullIfBlank turns an empty optional field into NULL.
+func nullIfBlank(s string) *string {
+	if s = strings.TrimSpace(s); s == "" {
+		return nil
+	}
+	return &s
+}
+
+// driverOnTrip reports whether the driver has a ride between acceptance and drop-off.
+func driverOnTrip(ctx context.Context, driverID string) (bool, error) {
+	var onTrip bool
+	err := db.Pool.QueryRow(ctx,
+		`SELECT EXISTS(SELECT 1 FROM rides WHERE "driverId"=$1 AND status IN ('Accepted','Arriving','InProgress'))`,
+		driverID).Scan(&onTrip)
+	return onTrip, err
+}
+
+// GET /api/v1/driver/vehicles
+func GetDriverVehicles(c *gin.Context) {
+	driver := c.MustGet("driver").(*models.Driver)
+
+	rows, err := db.Pool.Query(c.Request.Context(),
+		`SELECT `+vehicleSelectCols+` FROM vehicles v WHERE v."driverId"=$1
+		 ORDER BY v."isActive" DESC, v."createdAt" DESC`, driver.ID)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to fetch vehicles", err)
+		return
+	}
+	defer rows.Close()
+
+	vehicles := []vehicle{}
+	for rows.Next() {
+		var v vehicle
+		if err := scanVehicle(rows, &v); err != nil {
+			continue
+		}
+		vehicles = append(vehicles, v)
+	}
+	utils.RespondSuccess(c, http.StatusOK, "Vehicles", gin.H{"vehicles": vehicles})
+}
+
+// POST /api/v1/driver/vehicles — register another vehicle; it can be driven once support approves it
+func AddDriverVehicle(c *gin.Context) {
+	driver := c.MustGet("driver").(*models.Driver)
+	var body struct {
+		VehicleType        string `json:"vehicleType" binding:"required"`
+		RegistrationNumber string `json:"registrationNumber" binding:"required"`
+		RegistrationDate   string `json:"registrationDate"`
+		Color              string `json:"color"`
+		RCBook             string `json:"rcBook" binding:"required"`
+		InsuranceDoc       string `json:"insuranceDoc"`
+		PermitDoc          string `json:"permitDoc"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "vehicleType, registrationNumber and rcBook are required", err)
+		return
+	}
+	if vt := findVehicleType(c.Request.Context(), body.VehicleType); vt == nil || !vt.IsActive {
+		utils.RespondError(c, http.StatusBadRequest, "Unknown vehicle type "+body.VehicleType, nil)
+		return
+	}
+
+	var v vehicle
+	err := scanVehicle(db.Pool.QueryRow(c.Request.Context(),
+		`INSERT INTO vehicles AS v ("driverId", "vehicleType", "registrationNumber", "registrationDate", color, "rcBook", "insuranceDoc", "permitDoc")
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		 RETURNING `+vehicleSelectCols,
+		driver.ID, body.VehicleType, strings.TrimSpace(body.RegistrationNumber), strings.TrimSpace(body.RegistrationDate),
+		nullIfBlank(body.Color), body.RCBook, nullIfBlank(body.InsuranceDoc), nullIfBlank(body.PermitDoc)), &v)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		utils.RespondErrorCode(c, http.StatusConflict, utils.CodeRegistrationTaken, "This registration number is already registered", nil)
+		return
+	}
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to add vehicle", err)
+		return
+	}
+	utils.RespondSuccess(c, http.StatusCreated, "Vehicle submitted for verification", gin.H{"vehicle": v})
+}
+
+// PUT /api/v1/driver/vehicles/:id/documents — replace the documents of a vehicle that's pending or
+// was rejected, sending it back for review. Approved vehicles are renewed through support.
+func UpdateDriverVehicleDocuments(c *gin.Context) {
+	driver := c.MustGet("driver").(*models.Driver)
+	var body struct {
+		RCBook       *string `json:"rcBook"`
+		InsuranceDoc *string `json:"insuranceDoc"`
+		PermitDoc    *string `json:"permitDoc"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid request", err)
+		return
+	}
+
+	var v vehicle
+	err := scanVehicle(db.Pool.QueryRow(c.Request.Context(),
+		`UPDATE vehicles v SET "rcBook"=COALESCE($3, "rcBook"), "insuranceDoc"=COALESCE($4, "insuranceDoc"),
+		 "permitDoc"=COALESCE($5, "permitDoc"), status='pending', "rejectionReason"=NULL, "updatedAt"=NOW()
+		 WHERE id=$1 AND "driverId"=$2 AND status <> 'approved'
+		 RETURNING `+vehicleSelectCols,
+		c.Param("id"), driver.ID, body.RCBook, body.InsuranceDoc, body.PermitDoc), &v)
+	if errors.Is(err, pgx.ErrNoRows) {
+		utils.RespondError(c, http.StatusNotFound, "No pending or rejected vehicle with this ID", nil)
+		return
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		utils.RespondErrorCode(c, http.StatusConflict, utils.CodeRegistrationTaken, "This registration number is already registered", nil)
+		return
+	}
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to update vehicle", err)
+		return
+	}
+	utils.RespondSuccess(c, http.StatusOK, "Documents submitted for verification", gin.H{"vehicle": v})
+}
+
+// DELETE /api/v1/driver/vehicles/:id — remove a vehicle that isn't the active one
+func DeleteDriverVehicle(c *gin.Context) {
+	driver := c.MustGet("driver").(*models.Driver)
+
+	tag, err := db.Pool.Exec(c.Request.Context(),
+		`DELETE FROM vehicles WHERE id=$1 AND "driverId"=$2 AND NOT "isActive"`, c.Param("id"), driver.ID)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to remove vehicle", err)
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		utils.RespondError(c, http.StatusNotFound, "Vehicle not found, or it's your active vehicle", nil)
+		return
+	}
+	utils.RespondSuccess(c, http.StatusOK, "Vehicle removed", nil)
+}
+
+// PUT /api/v1/driver/vehicle/active — {vehicleId}; switch to another approved vehicle between trips
+func SetActiveDriverVehicle(c *gin.Context) {
+	driver := c.MustGet("driver").(*models.Driver)
+	var body struct {
+		VehicleID string `json:"vehicleId" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "vehicleId is required", err)
+		return
+	}
+
+	onTrip, err := driverOnTrip(c.Request.Context(), driver.ID)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to switch vehicle", err)
+		return
+	}
+	if onTrip {
+		utils.RespondErrorCode(c, http.StatusConflict, utils.CodeDriverOnTrip, "Finish your current ride before switching vehicles", nil)
+		return
+	}
+
+	var v vehicle
+	err = pgx.BeginFunc(c.Request.Context(), db.Pool, func(tx pgx.Tx) error {
+		var status string
+		err := tx.QueryRow(c.Request.Context(),
+			`SELECT status FROM vehicles WHERE id=$1 AND "driverId"=$2 FOR UPDATE`, body.VehicleID, driver.ID).Scan(&status)
+		if err != nil {
+			return err
+		}
+		if status != vehicleApproved {
+			return errVehicleNotApproved
+		}
+		if _, err := tx.Exec(c.Request.Context(),
+			`UPDATE vehicles SET "isActive"=FALSE, "updatedAt"=NOW() WHERE "driverId"=$1 AND "isActive" AND id <> $2`,
+			driver.ID, body.VehicleID); err != nil {
+			return err
+		}
+		return scanVehicle(tx.QueryRow(c.Request.Context(),
+			`UPDATE vehicles v SET "isActive"=TRUE, "updatedAt"=NOW() WHERE id=$1 RETURNING `+vehicleSelectCols,
+			body.VehicleID), &v)
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		utils.RespondError(c, http.StatusNotFound, "Vehicle not found", nil)
+		return
+	}
+	if errors.Is(err, errVehicleNotApproved) {
+		utils.RespondErrorCode(c, http.StatusConflict, utils.CodeVehicleNotApproved, "This vehicle hasn't been approved yet", nil)
+		return
+	}
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to switch vehicle", err)
+		return
+	}
+	utils.RespondSuccess(c, http.StatusOK, "Active vehicle changed", gin.H{"vehicle": v})
+}
+
+var errVehicleNotApproved = errors.New("vehicle not approved")
+
+// ══════════════════════════════════════════════════
+// Admin: Vehicle Verification
+// ══════════════════════════════════════════════════
+
+// GET /api/v1/admin/vehicles?status=pending&driverId= — the review queue, oldest first
+func AdminGetVehicles(c *gin.Context) {
+	status := c.DefaultQuery("status", vehiclePending)
+	driverID := c.Query("driverId")
+
+	rows, err := db.Pool.Query(adminContext(c),
+		`SELECT `+vehicleSelectCols+`, d.name, d.phone_number, d.status
+		 FROM vehicles v JOIN driver d ON d.id=v."driverId"
+		 WHERE ($1='all' OR v.status=$1) AND ($2='' OR v."driverId"=$2)
+		 ORDER BY v."createdAt" ASC LIMIT 200`, status, driverID)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to fetch vehicles", err)
+		return
+	}
+	defer rows.Close()
+
+	type adminVehicle struct {
+		vehicle
+		DriverName   string `json:"driverName"`
+		DriverPhone  string `json:"driverPhone"`
+		DriverStatus string `json:"driverStatus"`
+	}
+	vehicles := []adminVehicle{}
+	for rows.Next() {
+		var v adminVehicle
+		if err := scanVehicle(rows, &v.vehicle, &v.DriverName, &v.DriverPhone, &v.DriverStatus); err != nil {
+			continue
+		}
+		vehicles = append(vehicles, v)
+	}
+	utils.RespondSuccess(c, http.StatusOK, "Vehicles", gin.H{"vehicles": vehicles, "count": len(vehicles)})
+}
+
+// PUT /api/v1/admin/vehicle/:id/status — {status: approved|rejected, reason}. Rejecting the
+// vehicle a driver is using takes them offline until they switch to an approved one.
+func AdminReviewVehicle(c *gin.Context) {
+	admin := c.MustGet("admin").(*models.AdminAccount)
+	var body struct {
+		Status string `json:"status" binding:"required"`
+		Reason string `json:"reason"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid request", err)
+		return
+	}
+	if body.Status != vehicleApproved && body.Status != vehicleRejected {
+		utils.RespondError(c, http.StatusBadRequest, "status must be approved or rejected", nil)
+		return
+	}
+	if body.Status == vehicleRejected && strings.TrimSpace(body.Reason) == "" {
+		utils.RespondError(c, http.StatusBadRequest, "A reason is required when rejecting a vehicle", nil)
+		return
+	}
+
+	var v vehicle
+	err := scanVehicle(db.Pool.QueryRow(adminContext(c),
+		`UPDATE vehicles v SET status=$2, "rejectionReason"=$3, "reviewedBy"=$4, "reviewedAt"=NOW(), "updatedAt"=NOW()
+		 WHERE id=$1 RETURNING `+vehicleSelectCols,
+		c.Param("id"), body.Status, nullIfBlank(body.Reason), admin.ID), &v)
+	if errors.Is(err, pgx.ErrNoRows) {
+		utils.RespondError(c, http.StatusNotFound, "Vehicle not found", nil)
+		return
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		utils.RespondErrorCode(c, http.StatusConflict, utils.CodeRegistrationTaken, "Another driver's vehicle with this registration number is already on file", nil)
+		return
+	}
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to review vehicle", err)
+		return
+	}
+
+	title, msg := "Vehicle approved ✅", v.RegistrationNumber+" is approved. You can switch to it from the Vehicles screen."
+	if v.Status == vehicleRejected {
+		title, msg = "Vehicle not approved", v.RegistrationNumber+" wasn't approved: "+body.Reason
+		if v.IsActive {
+			db.Pool.Exec(adminContext(c), `UPDATE driver SET "isOnline"=FALSE, "updatedAt"=NOW() WHERE id=$1`, v.DriverID)
+			stores.RemoveDriver(adminContext(c), v.DriverID)
+		}
+	}
+	sendNotifications(c.Request.Context(), outboxPush("driver", v.DriverID, "", title, msg, utils.FCMData{
+		"type":      "vehicle_review",
+		"vehicleId": v.ID,
+		"status":    v.Status,
+	}))
+	utils.RespondSuccess(c, http.StatusOK, "Vehicle "+v.Status, gin.H{"vehicle": v})
+}
+
+// approveRegistrationVehicle approves the vehicle a driver signed up with when the driver
+// themselves is approved, so a new driver doesn't need two reviews.
+func approveRegistrationVehicle(ctx context.Context, driverID, adminID string) {
+	db.Pool.Exec(ctx,
+		`UPDATE vehicles SET status='approved', "reviewedBy"=$2, "reviewedAt"=NOW(), "updatedAt"=NOW()
+		 WHERE "driverId"=$1 AND "isActive" AND status='pending'`, driverID, adminID)
+}
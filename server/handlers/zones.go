@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"ridewave/db"
+	"ridewave/models"
+	"ridewave/utils"
+)
+
+// ══════════════════════════════════════════════════
+// Zone Soft Launch — beta cities open to allowlisted riders only
+// ══════════════════════════════════════════════════
+
+const (
+	zoneModeLive = "live"
+	zoneModeBeta = "beta"
+)
+
+// checkZoneAccess reports whether the rider may book from the pickup point.
+// Points outside every zone are left to the existing service-area checks.
+func checkZoneAccess(ctx context.Context, user *models.User, lat, lng float64) (bool, string) {
+	zone := findServiceZone(zoneForPoint(lat, lng))
+	if zone != nil && !tenantServesZone(ctx, zone.Name) {
+		return false, fmt.Sprintf("We don't operate in %s yet.", zone.Name)
+	}
+	if zone == nil || zone.LaunchMode != zoneModeBeta {
+		return true, ""
+	}
+
+	var allowlisted bool
+	db.Pool.QueryRow(ctx,
+		`SELECT EXISTS(SELECT 1 FROM zone_allowlist WHERE zone=$1 AND phone_number=$2)`,
+		zone.Name, user.PhoneNumber).Scan(&allowlisted)
+	if !allowlisted {
+		return false, fmt.Sprintf("RideWave is launching soon in %s. Booking is currently limited to beta testers.", zone.Name)
+	}
+	return true, ""
+}
+
+// ══════════════════════════════════════════════════
+// Admin: Zone Launch Management
+// ══════════════════════════════════════════════════
+
+// GET /api/v1/admin/zones
+func AdminGetZones(c *gin.Context) {
+	type ZoneStatus struct {
+		models.ServiceZone
+		AllowlistCount int `json:"allowlistCount"`
+	}
+
+	all, err := queryServiceZones(adminContext(c), true)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to fetch zones", err)
+		return
+	}
+	zones := []ZoneStatus{}
+	for _, z := range all {
+		zs := ZoneStatus{ServiceZone: z}
+		db.Pool.QueryRow(adminContext(c),
+			`SELECT COUNT(*) FROM zone_allowlist WHERE zone=$1`, z.Name).Scan(&zs.AllowlistCount)
+		zones = append(zones, zs)
+	}
+	utils.RespondSuccess(c, http.StatusOK, "Service zones", gin.H{"zones": zones})
+}
+
+// PUT /api/v1/admin/zone/:name/launch-mode
+func AdminSetZoneLaunchMode(c *gin.Context) {
+	zone := findServiceZone(c.Param("name"))
+	if zone == nil {
+		utils.RespondError(c, http.StatusNotFound, "Zone not found", nil)
+		return
+	}
+
+	var body struct {
+		Mode string `json:"mode" binding:"required"` // "live" or "beta"
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid request", err)
+		return
+	}
+	if body.Mode != zoneModeLive && body.Mode != zoneModeBeta {
+		utils.RespondError(c, http.StatusBadRequest, "Mode must be 'live' or 'beta'", nil)
+		return
+	}
+
+	_, err := db.Pool.Exec(adminContext(c),
+		`UPDATE service_zones SET "launchMode"=$2, "updatedAt"=NOW() WHERE name=$1`, zone.Name, body.Mode)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to update launch mode", err)
+		return
+	}
+	serviceZonesChanged(adminContext(c))
+	utils.RespondSuccess(c, http.StatusOK, "Launch mode updated", gin.H{"zone": zone.Name, "mode": body.Mode})
+}
+
+// GET /api/v1/admin/zone/:name/allowlist
+func AdminGetZoneAllowlist(c *gin.Context) {
+	zone := findServiceZone(c.Param("name"))
+	if zone == nil {
+		utils.RespondError(c, http.StatusNotFound, "Zone not found", nil)
+		return
+	}
+
+	rows, err := db.Pool.Query(adminContext(c),
+		`SELECT phone_number, COALESCE(note, ''), "createdAt" FROM zone_allowlist WHERE zone=$1 ORDER BY "createdAt" DESC`, zone.Name)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to fetch allowlist", err)
+		return
+	}
+	defer rows.Close()
+
+	type Tester struct {
+		PhoneNumber string    `json:"phone_number"`
+		Note        string    `json:"note"`
+		CreatedAt   time.Time `json:"createdAt"`
+	}
+	var testers []Tester
+	for rows.Next() {
+		var t Tester
+		rows.Scan(&t.PhoneNumber, &t.Note, &t.CreatedAt)
+		testers = append(testers, t)
+	}
+	if testers == nil {
+		testers = []Tester{}
+	}
+	utils.RespondSuccess(c, http.StatusOK, "Zone allowlist", gin.H{"zone": zone.Name, "testers": testers})
+}
+
+// POST /api/v1/admin/zone/:name/allowlist
+func AdminAddZoneAllowlist(c *gin.Context) {
+	zone := findServiceZone(c.Param("name"))
+	if zone == nil {
+		utils.RespondError(c, http.StatusNotFound, "Zone not found", nil)
+		return
+	}
+
+	var body struct {
+		PhoneNumbers []string `json:"phone_numbers" binding:"required"`
+		Note         string   `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid request", err)
+		return
+	}
+
+	added := 0
+	for _, raw := range body.PhoneNumbers {
+		if strings.TrimSpace(raw) == "" {
+			continue
+		}
+		phone, ok := requestPhone(c, raw, "")
+		if !ok {
+			return
+		}
+		tag, err := db.Pool.Exec(adminContext(c),
+			`INSERT INTO zone_allowlist (zone, phone_number, note) VALUES ($1, $2, NULLIF($3, ''))
+			 ON CONFLICT (zone, phone_number) DO NOTHING`, zone.Name, phone, body.Note)
+		if err != nil {
+			utils.RespondError(c, http.StatusInternalServerError, "Failed to update allowlist", err)
+			return
+		}
+		added += int(tag.RowsAffected())
+	}
+	utils.RespondSuccess(c, http.StatusOK, "Allowlist updated", gin.H{"zone": zone.Name, "added": added})
+}
+
+// DELETE /api/v1/admin/zone/:name/allowlist/:phone
+func AdminRemoveZoneAllowlist(c *gin.Context) {
+	zone := findServiceZone(c.Param("name"))
+	if zone == nil {
+		utils.RespondError(c, http.StatusNotFound, "Zone not found", nil)
+		return
+	}
+
+	phone, err := utils.NormalizePhone(c.Param("phone"), "")
+	if err != nil {
+		phone = c.Param("phone")
+	}
+	db.Pool.Exec(adminContext(c),
+		`DELETE FROM zone_allowlist WHERE zone=$1 AND phone_number=$2`, zone.Name, phone)
+	utils.RespondSuccess(c, http.StatusOK, "Removed from allowlist", nil)
+}
@@ -0,0 +1,231 @@
+// Package i18n translates user- and driver-facing text. Catalogs map the English text, as the
+// code writes it, to each language's translation, so nothing has to change at the call sites and
+// anything without a translation is sent in English.
+//
+// Text built with fmt (e.g. "Total fare: %s") is matched against catalog keys that keep the
+// verbs; the translation places the values with the same verbs, or with %[n]s when the word order
+// differs.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultLanguage is the language the code is written in.
+const DefaultLanguage = "en"
+
+// ProfileLanguageKey is the gin context key holding the caller's saved language.
+const ProfileLanguageKey = "profileLanguage"
+
+//go:embed locales/*.json
+var embedded embed.FS
+
+// verbRe matches a fmt verb, with an optional explicit argument index.
+var verbRe = regexp.MustCompile(`%(\[\d+\])?[-+# 0]*\d*(\.\d+)?[a-zA-Z%]`)
+
+type template struct {
+	re  *regexp.Regexp
+	out string
+}
+
+type catalog struct {
+	exact     map[string]string
+	templates []template
+}
+
+// catalogs is filled by Load at startup and only read afterwards.
+var catalogs = map[string]*catalog{}
+
+// Load reads the built-in catalogs, then any <lang>.json in I18N_DIR, whose entries override or
+// add to them (a new language needs no rebuild). Call it before serving.
+func Load() error {
+	files, err := embedded.ReadDir("locales")
+	if err != nil {
+		return err
+	}
+	loaded := map[string]map[string]string{}
+	for _, f := range files {
+		raw, err := embedded.ReadFile("locales/" + f.Name())
+		if err != nil {
+			return err
+		}
+		if err := mergeCatalog(loaded, f.Name(), raw); err != nil {
+			return err
+		}
+	}
+	if dir := os.Getenv("I18N_DIR"); dir != "" {
+		paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+		if err != nil {
+			return err
+		}
+		for _, path := range paths {
+			raw, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			if err := mergeCatalog(loaded, filepath.Base(path), raw); err != nil {
+				return err
+			}
+		}
+	}
+
+	built := make(map[string]*catalog, len(loaded))
+	for lang, entries := range loaded {
+		built[lang] = compile(entries)
+	}
+	catalogs = built
+	return nil
+}
+
+func mergeCatalog(into map[string]map[string]string, name string, raw []byte) error {
+	var entries map[string]string
+	if err := json.Unmarshal(raw, &entries); err != nil {
+		return fmt.Errorf("i18n: %s: %w", name, err)
+	}
+	lang := strings.ToLower(strings.TrimSuffix(name, ".json"))
+	if into[lang] == nil {
+		into[lang] = map[string]string{}
+	}
+	for k, v := range entries {
+		into[lang][k] = v
+	}
+	return nil
+}
+
+// compile splits a catalog into exact entries and fmt templates, longest template first so the
+// most specific one wins.
+func compile(entries map[string]string) *catalog {
+	c := &catalog{exact: map[string]string{}}
+	var keys []string
+	for k, v := range entries {
+		if v == "" {
+			continue
+		}
+		if verbRe.MatchString(strings.ReplaceAll(k, "%%", "")) {
+			keys = append(keys, k)
+		} else {
+			c.exact[k] = v
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return len(keys[i]) > len(keys[j]) })
+	for _, k := range keys {
+		var pattern strings.Builder
+		pattern.WriteString("^")
+		last := 0
+		for _, loc := range verbRe.FindAllStringIndex(k, -1) {
+			pattern.WriteString(regexp.QuoteMeta(k[last:loc[0]]))
+			if k[loc[0]:loc[1]] == "%%" {
+				pattern.WriteString("%")
+			} else {
+				pattern.WriteString("(.+?)")
+			}
+			last = loc[1]
+		}
+		pattern.WriteString(regexp.QuoteMeta(k[last:]) + "$")
+		c.templates = append(c.templates, template{re: regexp.MustCompile(pattern.String()), out: entries[k]})
+	}
+	return c
+}
+
+// Supported reports whether there's a catalog for lang (English always is).
+func Supported(lang string) bool {
+	_, ok := catalogs[lang]
+	return ok || lang == DefaultLanguage
+}
+
+// Languages lists the languages with a catalog, English first.
+func Languages() []string {
+	langs := []string{DefaultLanguage}
+	for lang := range catalogs {
+		if lang != DefaultLanguage {
+			langs = append(langs, lang)
+		}
+	}
+	sort.Strings(langs[1:])
+	return langs
+}
+
+// Resolve picks the language to answer in: the saved profile language if it's supported, else
+// the best supported language in the Accept-Language header, else English.
+func Resolve(acceptLanguage, profile string) string {
+	if lang := baseLanguage(profile); lang != "" && Supported(lang) {
+		return lang
+	}
+	type choice struct {
+		lang string
+		q    float64
+	}
+	var choices []choice
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+		if lang := baseLanguage(tag); lang != "" && q > 0 && Supported(lang) {
+			choices = append(choices, choice{lang, q})
+		}
+	}
+	sort.SliceStable(choices, func(i, j int) bool { return choices[i].q > choices[j].q })
+	if len(choices) > 0 {
+		return choices[0].lang
+	}
+	return DefaultLanguage
+}
+
+// baseLanguage reduces a language tag to its lowercase primary subtag ("hi-IN" → "hi").
+func baseLanguage(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	base, _, _ := strings.Cut(strings.ReplaceAll(tag, "_", "-"), "-")
+	if base == "*" {
+		return ""
+	}
+	return base
+}
+
+// T translates msg into lang, or returns it unchanged when there's no translation.
+func T(lang, msg string) string {
+	c := catalogs[lang]
+	if c == nil || msg == "" {
+		return msg
+	}
+	if out, ok := c.exact[msg]; ok {
+		return out
+	}
+	for _, t := range c.templates {
+		if m := t.re.FindStringSubmatch(msg); m != nil {
+			return fill(t.out, m[1:])
+		}
+	}
+	return msg
+}
+
+// fill substitutes the matched values for the translation's verbs, in order or by %[n] index.
+func fill(out string, args []string) string {
+	next := 0
+	return verbRe.ReplaceAllStringFunc(out, func(verb string) string {
+		if verb == "%%" {
+			return "%"
+		}
+		i := next
+		if m := verbRe.FindStringSubmatch(verb); m[1] != "" {
+			n, _ := strconv.Atoi(strings.Trim(m[1], "[]"))
+			i = n - 1
+		}
+		next = i + 1
+		if i < 0 || i >= len(args) {
+			return verb
+		}
+		return args[i]
+	})
+}
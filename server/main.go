@@ -13,10 +13,17 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
+	"ridewave/app"
+	"ridewave/chaos"
+	"ridewave/config"
 	"ridewave/db"
+	"ridewave/diag"
 	"ridewave/handlers"
+	"ridewave/i18n"
 	"ridewave/middleware"
 	"ridewave/socket"
+	"ridewave/stores"
+	"ridewave/telemetry"
 	"ridewave/utils"
 )
 
@@ -33,20 +40,80 @@ func main() {
 	utils.InitLogger()
 	utils.Logger.Info("Starting RideWave Server...")
 
+	// Message catalogs for localized responses and notifications
+	if err := i18n.Load(); err != nil {
+		log.Fatalf("Failed to load message catalogs: %v", err)
+	}
+
+	// Fault injection hooks (staging only; faults stay off until an admin switches them on)
+	if chaos.Allowed() {
+		chaos.Install()
+		utils.Logger.Warn("CHAOS_ENABLED is set: fault injection hooks are installed")
+	}
+
+	// Distributed tracing (off unless OTEL_EXPORTER_OTLP_ENDPOINT is set)
+	shutdownTracing, err := telemetry.Init(context.Background())
+	if err != nil {
+		log.Fatalf("Failed to set up tracing: %v", err)
+	}
+	if telemetry.Enabled() {
+		telemetry.Install()
+		utils.Logger.Info("OpenTelemetry tracing enabled")
+	}
+
+	// `--check` validates configuration and dependencies, prints a readiness report and
+	// exits non-zero if anything required is broken
+	if len(os.Args) > 1 && os.Args[1] == "--check" {
+		os.Exit(diag.Run(os.Stdout))
+	}
+
 	// Connect to DB
 	db.Connect()
 	defer db.Close()
 
+	// `migrate` applies migrations and exits; `migrate --dry-run` only reports pending schema
+	// changes with their locks and estimated duration (MIGRATION_PHASE=contract includes contract steps)
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if len(os.Args) > 2 && os.Args[2] == "--dry-run" {
+			db.DryRunMigrations(os.Stdout)
+			return
+		}
+		db.Migrate()
+		return
+	}
+
 	// Auto-migrate tables
 	db.Migrate()
 	db.InitRedis()
 
-	// Context for background services (cancellation)
-	bgCtx, bgCancel := context.WithCancel(context.Background())
+	// Handlers reach users, drivers, rides and payments through the container's repositories
+	app.Instance = app.New(config.Envs, db.Pool, db.RedisClient)
+	handlers.UseRepositories(app.Instance.Repos)
+	handlers.EnsureBootstrapAdmin()
+	handlers.LoadServiceZones()
+	handlers.BackfillRideEarnings()
+	handlers.NormalizeStoredPhones()
+
+	// Context for background services (cancellation); they work across every tenant
+	bgCtx, bgCancel := context.WithCancel(db.Unscoped())
 	defer bgCancel()
 
 	// Start Phase 2 background services
 	utils.StartRetentionWorker(bgCtx)
+	utils.StartWriteRetryWorker(bgCtx)
+	handlers.StartServiceZoneWorker(bgCtx)
+	handlers.StartScheduledRideWorker(bgCtx)
+	handlers.StartStuckRideWorker(bgCtx)
+	handlers.StartBidExpiryWorker(bgCtx)
+	handlers.StartETAWorker(bgCtx)
+	handlers.StartRedisAuditWorker(bgCtx)
+	handlers.StartDuplicateScanWorker(bgCtx)
+	handlers.StartBackupWorker(bgCtx)
+	handlers.StartAccountDeletionWorker(bgCtx)
+	handlers.StartSOSEscalationWorker(bgCtx)
+	handlers.StartDriverMetricsWorker(bgCtx)
+	handlers.StartNotificationOutboxWorker(bgCtx)
+	handlers.StartAnalyticsStatsWorker(bgCtx)
 
 	// Use release mode in production
 	if os.Getenv("GIN_MODE") == "release" || os.Getenv("NODE_ENV") == "production" {
@@ -59,11 +126,27 @@ func main() {
 	r := gin.Default()
 	r.SetTrustedProxies(nil)
 
+	// Load balancer probes, registered ahead of the middleware so they need no API key and
+	// aren't rate limited. Live: the process is up. Ready: it can serve traffic (503 while
+	// Postgres or Redis is unreachable, or once shutdown has started)
+	r.GET("/health/live", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"success": true, "status": "alive"})
+	})
+	r.GET("/health/ready", func(c *gin.Context) {
+		report := diag.Readiness(c.Request.Context())
+		status := http.StatusOK
+		if !report.Ready {
+			status = http.StatusServiceUnavailable
+		}
+		c.JSON(status, report)
+	})
+
 	// CORS middleware
 	r.Use(func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
 		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, x-api-key")
+		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, x-api-key, X-Device-Id, X-Region, Idempotency-Key")
+		c.Header("Access-Control-Expose-Headers", "X-RateLimit-Limit, X-RateLimit-Remaining, X-RateLimit-Reset, Retry-After, Idempotent-Replayed")
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
 			return
@@ -73,14 +156,26 @@ func main() {
 
 	// Security Middleware
 	r.Use(middleware.RequestID())
+	r.Use(telemetry.Middleware())
 	r.Use(middleware.SecureHeaders())
 	r.Use(middleware.RateLimit())
 	r.Use(middleware.TimeoutMiddleware())
 	r.Use(middleware.MaxBodySize(10 * 1024 * 1024)) // 10MB limit
 
+	// White-label tenant from API key or domain (scopes the request's queries)
+	r.Use(middleware.TenantResolver())
+
+	// Gateway callbacks authenticate with their own signature, not the app's API key
+	for _, version := range utils.APIVersions {
+		handlers.RegisterWebhookRoutes(r.Group(utils.APIPrefix(version), middleware.APIVersion(version)))
+	}
+
 	// API Key Authentication (Global)
 	r.Use(middleware.APIKeyAuth())
 
+	// Turn away requests addressed to another data-residency region
+	r.Use(middleware.RegionGuard())
+
 	// Health Check
 	r.GET("/health", func(c *gin.Context) {
 		dbStatus := "connected"
@@ -118,15 +213,30 @@ func main() {
 				"uptime":    uptimeStr,
 				"startedAt": serverStartTime.Format(time.RFC3339),
 			},
+			"region":   db.Region(),
 			"database": gin.H{"status": dbStatus, "latency": dbLatency},
 			"redis":    gin.H{"status": redisStatus, "latency": redisLatency},
+			// Failed audit writes waiting to be replayed (non-zero "dead" needs a look)
+			"writeRetry": utils.WriteRetryBacklog(context.Background()),
+			// Last Redis key/memory audit (nil until the first run)
+			"redisAudit": handlers.RedisAuditSummary(context.Background()),
+			// This instance's nearby driver cache hits and misses (NEARBY_DRIVER_CACHE_MS)
+			"nearbyDriverCache": stores.NearbyDriverCacheStats(),
 		})
 	})
 
-	// Load Routes (Modular registration with middleware injection)
-	handlers.RegisterUserRoutes(r, middleware.IsAuthenticated())
-	handlers.RegisterDriverRoutes(r, middleware.IsAuthenticatedDriver())
-	handlers.RegisterAdminRoutes(r, middleware.IsAdmin())
+	// Load Routes (Modular registration with middleware injection), once per API version.
+	// Versions share handlers; the version only changes request and response shapes.
+	for _, version := range utils.APIVersions {
+		api := r.Group(utils.APIPrefix(version), middleware.APIVersion(version))
+		handlers.RegisterUserRoutes(api, middleware.IsAuthenticated())
+		handlers.RegisterDriverRoutes(api, middleware.IsAuthenticatedDriver())
+		handlers.RegisterAdminRoutes(api, middleware.IsAdmin())
+		handlers.RegisterFleetRoutes(api, middleware.IsFleetOwner())
+		handlers.RegisterPublicRoutes(api)
+	}
+	// Region-to-region calls are server to server and stay on v1
+	handlers.RegisterInternalRoutes(r.Group(utils.APIPrefix(utils.APIv1)), middleware.FederationAuth())
 
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -157,19 +267,31 @@ func main() {
 	<-quit
 	log.Println("Shutting down server...")
 
-	// 1. Cancel background workers
+	// 1. Fail readiness and give load balancers time to stop routing here
+	diag.StartDraining()
+	if delay := diag.DrainDelay(); delay > 0 {
+		log.Printf("Draining for %s...", delay)
+		time.Sleep(delay)
+	}
+
+	// 2. Cancel background workers
 	bgCancel()
 
-	// 2. Shutdown HTTP server (stop accepting new requests)
+	// 3. Shutdown HTTP server (stop accepting new requests)
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 	if err := srv.Shutdown(ctx); err != nil {
 		log.Fatalf("Server forced to shutdown: %v", err)
 	}
 
-	// 3. Wait for tracked background tasks (SafeGo) to complete
+	// 4. Wait for tracked background tasks (SafeGo) to complete
 	log.Println("Waiting for background tasks to drain...")
 	utils.WaitForBackgroundTasks(5 * time.Second)
 
+	// 5. Flush spans still waiting for the exporter
+	tctx, tcancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer tcancel()
+	shutdownTracing(tctx)
+
 	log.Println("Server exiting")
 }
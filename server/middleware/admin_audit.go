@@ -0,0 +1,253 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"ridewave/db"
+	"ridewave/models"
+	"ridewave/utils"
+)
+
+// auditBodyMaxBytes caps how much of a request body is kept in the audit log.
+const auditBodyMaxBytes = 64 << 10
+
+// auditEntity is the table behind an admin route's target, e.g. /promo-code/:id → promo_codes.id.
+type auditEntity struct {
+	table, key string
+}
+
+// auditEntities maps the path segment before a route's first parameter to the row it changes,
+// so the row can be snapshotted before and after the request.
+var auditEntities = map[string]auditEntity{
+	"user":             {`"user"`, "id"},
+	"driver":           {"driver", "id"},
+	"account":          {"admin_accounts", "id"},
+	"tenant":           {"tenants", "id"},
+	"account-deletion": {"account_deletions", "id"},
+	"ride-anomaly":     {"ride_anomalies", "id"},
+	"duplicate":        {"duplicate_clusters", "id"},
+	"note":             {"admin_notes", "id"},
+	"zone":             {"service_zones", "name"},
+	"rating-tag":       {"rating_tags", "id"},
+	"training-module":  {"training_modules", "id"},
+	"review":           {"ride_reviews", `"rideId"`},
+	"ride":             {"rides", "id"},
+	"refund":           {"refunds", "id"},
+	"dispute":          {"ride_disputes", "id"},
+	"vehicle-type":     {"vehicle_types", "id"},
+	"sos":              {"sos_alerts", "id"},
+	"promo-code":       {"promo_codes", "id"},
+	"notification":     {"notifications_outbox", "id"},
+	"incentive":        {"incentives", "id"},
+	"vehicle":          {"vehicles", "id"},
+}
+
+// auditChange is one field's value before and after the request.
+type auditChange struct {
+	From any `json:"from"`
+	To   any `json:"to"`
+}
+
+// auditTarget works out which entity an admin route acts on from its pattern, e.g.
+// /admin/driver/:id/status → ("driver", <id>). /me routes target the admin's own account.
+func auditTarget(c *gin.Context, admin *models.AdminAccount) (string, string) {
+	segments := strings.Split(strings.TrimPrefix(utils.RoutePath(c), "/admin/"), "/")
+	if segments[0] == "me" {
+		return "account", admin.ID
+	}
+	for i, seg := range segments {
+		if strings.HasPrefix(seg, ":") && i > 0 {
+			return segments[i-1], c.Param(seg[1:])
+		}
+	}
+	return segments[0], ""
+}
+
+// isSecretField reports whether a JSON key holds a credential that must never be logged.
+func isSecretField(key string) bool {
+	key = strings.ToLower(key)
+	for _, s := range []string{"password", "secret", "token", "apikey", "hash", "otp"} {
+		if strings.Contains(key, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactSecrets blanks credential fields anywhere in a decoded JSON value.
+func redactSecrets(v any) any {
+	switch t := v.(type) {
+	case map[string]any:
+		for k, val := range t {
+			if isSecretField(k) {
+				t[k] = "[redacted]"
+			} else {
+				t[k] = redactSecrets(val)
+			}
+		}
+	case []any:
+		for i := range t {
+			t[i] = redactSecrets(t[i])
+		}
+	}
+	return v
+}
+
+// auditSnapshot returns the entity's row as JSON, or nil if it doesn't exist (yet, or any more).
+func auditSnapshot(ctx context.Context, entity auditEntity, id string) map[string]any {
+	var row map[string]any
+	if err := db.Pool.QueryRow(ctx,
+		`SELECT to_jsonb(t) FROM `+entity.table+` t WHERE `+entity.key+`=$1`, id).Scan(&row); err != nil {
+		return nil
+	}
+	redactSecrets(row)
+	return row
+}
+
+// auditDiff lists the fields that changed between two snapshots, ignoring bookkeeping timestamps.
+func auditDiff(before, after map[string]any) map[string]auditChange {
+	changes := map[string]auditChange{}
+	for k, from := range before {
+		if to := after[k]; !reflect.DeepEqual(from, to) {
+			changes[k] = auditChange{From: from, To: to}
+		}
+	}
+	for k, to := range after {
+		if _, seen := before[k]; !seen {
+			changes[k] = auditChange{To: to}
+		}
+	}
+	delete(changes, "updatedAt")
+	return changes
+}
+
+const auditReadKey = "auditRead"
+
+// AuditRead marks a read-only admin route whose response exposes someone's live personal data,
+// so AdminAudit logs who looked. Place it on the route.
+func AuditRead() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(auditReadKey, true)
+		c.Next()
+	}
+}
+
+// AdminAudit records every mutating admin request — who, which route, the target entity, the
+// request body and a field-level diff of the target row — in admin_audit_logs. Credentials are
+// redacted from both. Reads are only logged on routes marked with AuditRead. The log is written
+// after the response, off the request path. Place it after IsAdmin.
+func AdminAudit() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, _ := c.Get("admin")
+		admin, ok := value.(*models.AdminAccount)
+		if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead || c.Request.Method == http.MethodOptions {
+			c.Next()
+			if ok && c.GetBool(auditReadKey) {
+				ctx := db.WithoutTenant(context.WithoutCancel(c.Request.Context()))
+				entityType, entityID := auditTarget(c, admin)
+				status, method, path, route, ip := c.Writer.Status(), c.Request.Method, c.Request.URL.Path, c.FullPath(), c.ClientIP()
+				requestID := c.GetString("RequestID")
+				utils.SafeGo(func() {
+					writeAdminAudit(ctx, admin, method, path, route, entityType, entityID, nil, nil, status, ip, requestID)
+				})
+			}
+			return
+		}
+		if !ok {
+			c.Next()
+			return
+		}
+
+		var request any
+		if strings.HasPrefix(c.ContentType(), "application/json") && c.Request.Body != nil {
+			raw, err := io.ReadAll(c.Request.Body)
+			if err == nil {
+				c.Request.Body = io.NopCloser(bytes.NewReader(raw))
+				if len(raw) <= auditBodyMaxBytes && json.Unmarshal(raw, &request) == nil {
+					request = redactSecrets(request)
+				}
+			}
+		}
+
+		ctx := db.WithoutTenant(context.WithoutCancel(c.Request.Context()))
+		entityType, entityID := auditTarget(c, admin)
+		entity, tracked := auditEntities[entityType]
+		var before map[string]any
+		if tracked && entityID != "" {
+			before = auditSnapshot(ctx, entity, entityID)
+		}
+
+		c.Next()
+
+		status := c.Writer.Status()
+		method, path, route, ip := c.Request.Method, c.Request.URL.Path, c.FullPath(), c.ClientIP()
+		requestID := c.GetString("RequestID")
+		utils.SafeGo(func() {
+			var changes map[string]auditChange
+			if tracked && entityID != "" && status < http.StatusBadRequest {
+				changes = auditDiff(before, auditSnapshot(ctx, entity, entityID))
+			}
+			writeAdminAudit(ctx, admin, method, path, route, entityType, entityID, request, changes, status, ip, requestID)
+		})
+	}
+}
+
+const adminAuditWrite = "admin_audit_log"
+
+func init() {
+	utils.RegisterRetryableWrite(adminAuditWrite, func(ctx context.Context, payload json.RawMessage) error {
+		var rec adminAuditRecord
+		if err := json.Unmarshal(payload, &rec); err != nil {
+			return err
+		}
+		return insertAdminAudit(db.WithoutTenant(ctx), rec)
+	})
+}
+
+// adminAuditRecord is one admin_audit_logs row, kept as JSON when it has to wait for a retry.
+type adminAuditRecord struct {
+	AdminID    string                 `json:"adminId"`
+	AdminEmail string                 `json:"adminEmail"`
+	Method     string                 `json:"method"`
+	Path       string                 `json:"path"`
+	Route      string                 `json:"route"`
+	EntityType string                 `json:"entityType"`
+	EntityID   string                 `json:"entityId"`
+	Request    any                    `json:"request"`
+	Changes    map[string]auditChange `json:"changes"`
+	Status     int                    `json:"status"`
+	IP         string                 `json:"ip"`
+	RequestID  string                 `json:"requestId"`
+}
+
+func writeAdminAudit(ctx context.Context, admin *models.AdminAccount, method, path, route, entityType, entityID string,
+	request any, changes map[string]auditChange, status int, ip, requestID string) {
+	rec := adminAuditRecord{
+		AdminID: admin.ID, AdminEmail: admin.Email, Method: method, Path: path, Route: route,
+		EntityType: entityType, EntityID: entityID, Request: request, Changes: changes,
+		Status: status, IP: ip, RequestID: requestID,
+	}
+	if err := insertAdminAudit(ctx, rec); err != nil {
+		// An admin action must not go unrecorded because of a DB blip
+		utils.Logger.Error("Failed to write admin audit log, queued for retry", zap.String("route", route), zap.String("admin", admin.Email), zap.Error(err))
+		utils.QueueFailedWrite(adminAuditWrite, rec, err)
+	}
+}
+
+func insertAdminAudit(ctx context.Context, rec adminAuditRecord) error {
+	_, err := db.Pool.Exec(ctx,
+		`INSERT INTO admin_audit_logs ("adminId", "adminEmail", method, path, route, "entityType", "entityId",
+		 request, changes, status, ip, "requestId")
+		 VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), $8, $9, $10, $11, NULLIF($12, ''))`,
+		rec.AdminID, rec.AdminEmail, rec.Method, rec.Path, rec.Route, rec.EntityType, rec.EntityID,
+		rec.Request, rec.Changes, rec.Status, rec.IP, rec.RequestID)
+	return err
+}
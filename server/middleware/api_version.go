@@ -0,0 +1,17 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"ridewave/utils"
+)
+
+// APIVersion prepares requests for a version's route group. v2 clients send every body field in
+// camelCase, including the few v1 takes in snake_case.
+func APIVersion(version int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if version >= utils.APIv2 {
+			utils.CamelCaseRequestBody(c)
+		}
+		c.Next()
+	}
+}
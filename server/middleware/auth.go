@@ -1,7 +1,6 @@
 package middleware
 
 import (
-	"context"
 	"net/http"
 	"os"
 	"strings"
@@ -9,6 +8,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 	"ridewave/db"
+	"ridewave/i18n"
 	"ridewave/models"
 	"ridewave/utils"
 )
@@ -17,13 +17,13 @@ func IsAuthenticated() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			utils.RespondError(c, http.StatusUnauthorized, "Please log in to access this content", nil)
+			utils.RespondErrorCode(c, http.StatusUnauthorized, utils.CodeAuthRequired, "Please log in to access this content", nil)
 			c.Abort()
 			return
 		}
 		parts := strings.Split(authHeader, " ")
 		if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
-			utils.RespondError(c, http.StatusUnauthorized, "Invalid authorization format. Use: Bearer <token>", nil)
+			utils.RespondErrorCode(c, http.StatusUnauthorized, utils.CodeAuthRequired, "Invalid authorization format. Use: Bearer <token>", nil)
 			c.Abort()
 			return
 		}
@@ -33,47 +33,71 @@ func IsAuthenticated() gin.HandlerFunc {
 			return []byte(os.Getenv("ACCESS_TOKEN_SECRET")), nil
 		})
 		if err != nil || !token.Valid {
-			utils.RespondError(c, http.StatusUnauthorized, "Invalid or expired token", err)
+			utils.RespondErrorCode(c, http.StatusUnauthorized, utils.CodeTokenInvalid, "Invalid or expired token", err)
 			c.Abort()
 			return
 		}
 
 		claims, ok := token.Claims.(jwt.MapClaims)
 		if !ok {
-			utils.RespondError(c, http.StatusUnauthorized, "Invalid token claims", nil)
+			utils.RespondErrorCode(c, http.StatusUnauthorized, utils.CodeTokenInvalid, "Invalid token claims", nil)
+			c.Abort()
+			return
+		}
+		if scope, _ := claims["scope"].(string); scope != "" {
+			utils.RespondErrorCode(c, http.StatusUnauthorized, utils.CodeTokenInvalid, "This token can only be used for "+scope, nil)
 			c.Abort()
 			return
 		}
 		id, ok := claims["id"].(string)
 		if !ok || id == "" {
-			utils.RespondError(c, http.StatusUnauthorized, "Invalid token payload", nil)
+			utils.RespondErrorCode(c, http.StatusUnauthorized, utils.CodeTokenInvalid, "Invalid token payload", nil)
+			c.Abort()
+			return
+		}
+
+		// Logged out, or logged out everywhere (suspension) after this token was issued
+		if utils.TokenRevoked(c.Request.Context(), utils.SessionUser, claims) {
+			utils.RespondErrorCode(c, http.StatusUnauthorized, utils.CodeSessionEnded, "Your session has ended. Please log in again.", nil)
 			c.Abort()
 			return
 		}
 
+		// Scoped to the request's tenant, so a token from another brand finds no user
 		var user models.User
-		err = db.Pool.QueryRow(context.Background(),
-			`SELECT id, name, phone_number, email, "notificationToken", ratings, "totalRides", status, "createdAt", "updatedAt" FROM "user" WHERE id=$1`, id).
-			Scan(&user.ID, &user.Name, &user.PhoneNumber, &user.Email, &user.NotificationToken, &user.Ratings, &user.TotalRides, &user.Status, &user.CreatedAt, &user.UpdatedAt)
+		var language string
+		err = db.Pool.QueryRow(c.Request.Context(),
+			`SELECT id, name, phone_number, email, "notificationToken", ratings, "totalRides", status, "createdAt", "updatedAt", COALESCE("preferredLanguage", '') FROM "user" WHERE id=$1`, id).
+			Scan(&user.ID, &user.Name, &user.PhoneNumber, &user.Email, &user.NotificationToken, &user.Ratings, &user.TotalRides, &user.Status, &user.CreatedAt, &user.UpdatedAt, &language)
 		if err != nil {
 			utils.RespondError(c, http.StatusUnauthorized, "User not found", err)
 			c.Abort()
 			return
 		}
+		c.Set(i18n.ProfileLanguageKey, language)
 
 		// Block suspended/inactive users
 		if user.Status == "suspended" {
-			utils.RespondError(c, http.StatusForbidden, "Your account has been suspended. Contact support.", nil)
+			utils.RespondErrorCode(c, http.StatusForbidden, utils.CodeAccountSuspended, "Your account has been suspended. Contact support.", nil)
 			c.Abort()
 			return
 		}
 		if user.Status == "inactive" {
-			utils.RespondError(c, http.StatusForbidden, "Your account has been deactivated. Contact support.", nil)
+			utils.RespondErrorCode(c, http.StatusForbidden, utils.CodeAccountDeactivated, "Your account has been deactivated. Contact support.", nil)
+			c.Abort()
+			return
+		}
+		if user.Status == "deleted" {
+			utils.RespondErrorCode(c, http.StatusUnauthorized, utils.CodeAccountDeleted, "This account has been deleted", nil)
 			c.Abort()
 			return
 		}
 
 		c.Set("user", &user)
+		c.Set("sessionId", claims["sid"])
+		if !enforceRateLimit(c, "identity:"+callerIdentity(c), identityRateLimit()) {
+			return
+		}
 		c.Next()
 	}
 }
@@ -82,13 +106,13 @@ func IsAuthenticatedDriver() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			utils.RespondError(c, http.StatusUnauthorized, "Please log in to access this content", nil)
+			utils.RespondErrorCode(c, http.StatusUnauthorized, utils.CodeAuthRequired, "Please log in to access this content", nil)
 			c.Abort()
 			return
 		}
 		parts := strings.Split(authHeader, " ")
 		if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
-			utils.RespondError(c, http.StatusUnauthorized, "Invalid authorization format. Use: Bearer <token>", nil)
+			utils.RespondErrorCode(c, http.StatusUnauthorized, utils.CodeAuthRequired, "Invalid authorization format. Use: Bearer <token>", nil)
 			c.Abort()
 			return
 		}
@@ -98,68 +122,257 @@ func IsAuthenticatedDriver() gin.HandlerFunc {
 			return []byte(os.Getenv("ACCESS_TOKEN_SECRET")), nil
 		})
 		if err != nil || !token.Valid {
-			utils.RespondError(c, http.StatusUnauthorized, "Invalid or expired token", err)
+			utils.RespondErrorCode(c, http.StatusUnauthorized, utils.CodeTokenInvalid, "Invalid or expired token", err)
 			c.Abort()
 			return
 		}
 
 		claims, ok := token.Claims.(jwt.MapClaims)
 		if !ok {
-			utils.RespondError(c, http.StatusUnauthorized, "Invalid token claims", nil)
+			utils.RespondErrorCode(c, http.StatusUnauthorized, utils.CodeTokenInvalid, "Invalid token claims", nil)
+			c.Abort()
+			return
+		}
+		if scope, _ := claims["scope"].(string); scope != "" {
+			utils.RespondErrorCode(c, http.StatusUnauthorized, utils.CodeTokenInvalid, "This token can only be used for "+scope, nil)
 			c.Abort()
 			return
 		}
 		id, ok := claims["id"].(string)
 		if !ok || id == "" {
-			utils.RespondError(c, http.StatusUnauthorized, "Invalid token payload", nil)
+			utils.RespondErrorCode(c, http.StatusUnauthorized, utils.CodeTokenInvalid, "Invalid token payload", nil)
+			c.Abort()
+			return
+		}
+
+		// Logged out, or logged out everywhere (suspension) after this token was issued
+		if utils.TokenRevoked(c.Request.Context(), utils.SessionDriver, claims) {
+			utils.RespondErrorCode(c, http.StatusUnauthorized, utils.CodeSessionEnded, "Your session has ended. Please log in again.", nil)
 			c.Abort()
 			return
 		}
 
 		var driver models.Driver
-		err = db.Pool.QueryRow(context.Background(),
-			`SELECT id, name, country, phone_number, email, vehicle_type, registration_number, registration_date, driving_license, vehicle_color, rate, "notificationToken", ratings, "totalEarning", "totalRides", "totalDistance", "pendingRides", "cancelRides", status, "createdAt", "updatedAt", COALESCE("rcBook", ''), COALESCE("profileImage", '') FROM driver WHERE id=$1`, id).
-			Scan(&driver.ID, &driver.Name, &driver.Country, &driver.PhoneNumber, &driver.Email, &driver.VehicleType, &driver.RegistrationNumber, &driver.RegistrationDate, &driver.DrivingLicense, &driver.VehicleColor, &driver.Rate, &driver.NotificationToken, &driver.Ratings, &driver.TotalEarning, &driver.TotalRides, &driver.TotalDistance, &driver.PendingRides, &driver.CancelRides, &driver.Status, &driver.CreatedAt, &driver.UpdatedAt, &driver.RCBook, &driver.ProfileImage)
+		var language string
+		err = db.Pool.QueryRow(c.Request.Context(),
+			`SELECT id, name, country, phone_number, email, vehicle_type, registration_number, registration_date, driving_license, vehicle_color, rate, "notificationToken", ratings, "totalEarning", "totalRides", "totalDistance", "pendingRides", "cancelRides", status, "createdAt", "updatedAt", COALESCE("rcBook", ''), COALESCE("profileImage", ''), COALESCE("preferredLanguage", '') FROM driver WHERE id=$1`, id).
+			Scan(&driver.ID, &driver.Name, &driver.Country, &driver.PhoneNumber, &driver.Email, &driver.VehicleType, &driver.RegistrationNumber, &driver.RegistrationDate, &driver.DrivingLicense, &driver.VehicleColor, &driver.Rate, &driver.NotificationToken, &driver.Ratings, &driver.TotalEarning, &driver.TotalRides, &driver.TotalDistance, &driver.PendingRides, &driver.CancelRides, &driver.Status, &driver.CreatedAt, &driver.UpdatedAt, &driver.RCBook, &driver.ProfileImage, &language)
 		if err != nil {
 			utils.RespondError(c, http.StatusUnauthorized, "Driver not found", err)
 			c.Abort()
 			return
 		}
+		c.Set(i18n.ProfileLanguageKey, language)
 
 		// Block suspended/rejected drivers
 		if driver.Status == "suspended" {
-			utils.RespondError(c, http.StatusForbidden, "Your account has been suspended. Contact support.", nil)
+			utils.RespondErrorCode(c, http.StatusForbidden, utils.CodeAccountSuspended, "Your account has been suspended. Contact support.", nil)
 			c.Abort()
 			return
 		}
 		if driver.Status == "rejected" {
-			utils.RespondError(c, http.StatusForbidden, "Your registration was rejected. Contact support.", nil)
+			utils.RespondErrorCode(c, http.StatusForbidden, utils.CodeAccountRejected, "Your registration was rejected. Contact support.", nil)
+			c.Abort()
+			return
+		}
+		if driver.Status == "deleted" {
+			utils.RespondErrorCode(c, http.StatusUnauthorized, utils.CodeAccountDeleted, "This account has been deleted", nil)
 			c.Abort()
 			return
 		}
 
 		c.Set("driver", &driver)
+		c.Set("sessionId", claims["sid"])
+		if !enforceRateLimit(c, "identity:"+callerIdentity(c), identityRateLimit()) {
+			return
+		}
 		c.Next()
 	}
 }
 
-// IsAdmin validates admin access via x-admin-secret header
+// IsOnboardingDriver validates the onboarding token issued to pending drivers at login and sets
+// "driverId". It stops working once the driver is approved or rejected.
+func IsOnboardingDriver() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		parts := strings.Split(c.GetHeader("Authorization"), " ")
+		if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
+			utils.RespondErrorCode(c, http.StatusUnauthorized, utils.CodeAuthRequired, "Please log in to access this content", nil)
+			c.Abort()
+			return
+		}
+
+		token, err := jwt.Parse(parts[1], func(t *jwt.Token) (interface{}, error) {
+			return []byte(os.Getenv("ACCESS_TOKEN_SECRET")), nil
+		})
+		if err != nil || !token.Valid {
+			utils.RespondErrorCode(c, http.StatusUnauthorized, utils.CodeTokenInvalid, "Invalid or expired token", err)
+			c.Abort()
+			return
+		}
+		claims, _ := token.Claims.(jwt.MapClaims)
+		id, _ := claims["id"].(string)
+		if scope, _ := claims["scope"].(string); scope != "onboarding" || id == "" {
+			utils.RespondErrorCode(c, http.StatusUnauthorized, utils.CodeTokenInvalid, "Invalid token payload", nil)
+			c.Abort()
+			return
+		}
+
+		var status string
+		if err := db.Pool.QueryRow(c.Request.Context(), `SELECT status FROM driver WHERE id=$1`, id).Scan(&status); err != nil {
+			utils.RespondError(c, http.StatusUnauthorized, "Driver not found", err)
+			c.Abort()
+			return
+		}
+		if status != "pending" {
+			utils.RespondError(c, http.StatusForbidden, "Onboarding is only open while your registration is pending", nil)
+			c.Abort()
+			return
+		}
+
+		c.Set("driverId", id)
+		c.Next()
+	}
+}
+
+// IsFleetOwner validates a fleet owner's token from /api/v1/fleet/auth/verify and sets "fleet".
+// Suspending the fleet locks its owner out immediately.
+func IsFleetOwner() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		parts := strings.Split(c.GetHeader("Authorization"), " ")
+		if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
+			utils.RespondErrorCode(c, http.StatusUnauthorized, utils.CodeAuthRequired, "Please log in to access this content", nil)
+			c.Abort()
+			return
+		}
+
+		token, err := jwt.Parse(parts[1], func(t *jwt.Token) (interface{}, error) {
+			return []byte(os.Getenv("ACCESS_TOKEN_SECRET")), nil
+		})
+		if err != nil || !token.Valid {
+			utils.RespondErrorCode(c, http.StatusUnauthorized, utils.CodeTokenInvalid, "Invalid or expired token", err)
+			c.Abort()
+			return
+		}
+		claims, _ := token.Claims.(jwt.MapClaims)
+		id, _ := claims["id"].(string)
+		if scope, _ := claims["scope"].(string); scope != "fleet" || id == "" {
+			utils.RespondErrorCode(c, http.StatusUnauthorized, utils.CodeTokenInvalid, "Invalid token payload", nil)
+			c.Abort()
+			return
+		}
+
+		var fleet models.Fleet
+		err = db.Pool.QueryRow(c.Request.Context(),
+			`SELECT id, name, "ownerName", "phoneNumber", email, "commissionPercent", status, "totalEarned", "createdAt", "updatedAt"
+			 FROM fleets WHERE id=$1`, id).
+			Scan(&fleet.ID, &fleet.Name, &fleet.OwnerName, &fleet.PhoneNumber, &fleet.Email, &fleet.CommissionPercent,
+				&fleet.Status, &fleet.TotalEarned, &fleet.CreatedAt, &fleet.UpdatedAt)
+		if err != nil {
+			utils.RespondError(c, http.StatusUnauthorized, "Fleet not found", err)
+			c.Abort()
+			return
+		}
+		if fleet.Status != "active" {
+			utils.RespondErrorCode(c, http.StatusForbidden, utils.CodeAccountSuspended, "Your fleet account has been suspended. Contact support.", nil)
+			c.Abort()
+			return
+		}
+
+		c.Set("fleet", &fleet)
+		if !enforceRateLimit(c, "identity:"+callerIdentity(c), identityRateLimit()) {
+			return
+		}
+		c.Next()
+	}
+}
+
+// Admin roles. Superadmins can do everything; support and finance are limited to their own areas.
+const (
+	RoleSuperadmin = "superadmin"
+	RoleSupport    = "support"
+	RoleFinance    = "finance"
+)
+
+// IsAdmin validates an admin JWT issued by /api/v1/admin/auth/login
 func IsAdmin() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		adminSecret := os.Getenv("ADMIN_SECRET")
-		if adminSecret == "" {
-			utils.RespondError(c, http.StatusInternalServerError, "Admin access not configured", nil)
+		authHeader := c.GetHeader("Authorization")
+		if authHeader == "" {
+			utils.RespondErrorCode(c, http.StatusUnauthorized, utils.CodeAuthRequired, "Please log in to access this content", nil)
+			c.Abort()
+			return
+		}
+		parts := strings.Split(authHeader, " ")
+		if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
+			utils.RespondErrorCode(c, http.StatusUnauthorized, utils.CodeAuthRequired, "Invalid authorization format. Use: Bearer <token>", nil)
 			c.Abort()
 			return
 		}
 
-		headerSecret := c.GetHeader("x-admin-secret")
-		if headerSecret == "" || headerSecret != adminSecret {
+		token, err := jwt.Parse(parts[1], func(t *jwt.Token) (interface{}, error) {
+			return utils.AdminTokenSecret(), nil
+		}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+		if err != nil || !token.Valid {
+			utils.RespondErrorCode(c, http.StatusUnauthorized, utils.CodeTokenInvalid, "Invalid or expired token", err)
+			c.Abort()
+			return
+		}
+
+		claims, ok := token.Claims.(jwt.MapClaims)
+		if !ok || claims["scope"] != "admin" {
 			utils.RespondError(c, http.StatusForbidden, "Forbidden: Invalid admin credentials", nil)
 			c.Abort()
 			return
 		}
+		id, ok := claims["id"].(string)
+		if !ok || id == "" {
+			utils.RespondErrorCode(c, http.StatusUnauthorized, utils.CodeTokenInvalid, "Invalid token payload", nil)
+			c.Abort()
+			return
+		}
+
+		var admin models.AdminAccount
+		err = db.Pool.QueryRow(c.Request.Context(),
+			`SELECT id, email, name, role, "isActive", "lastLoginAt", "createdAt", "updatedAt" FROM admin_accounts WHERE id=$1`, id).
+			Scan(&admin.ID, &admin.Email, &admin.Name, &admin.Role, &admin.IsActive, &admin.LastLoginAt, &admin.CreatedAt, &admin.UpdatedAt)
+		if err != nil {
+			utils.RespondError(c, http.StatusUnauthorized, "Admin not found", err)
+			c.Abort()
+			return
+		}
+		if !admin.IsActive {
+			utils.RespondError(c, http.StatusForbidden, "Your admin account has been disabled", nil)
+			c.Abort()
+			return
+		}
 
+		c.Set("admin", &admin)
 		c.Next()
 	}
 }
+
+// HasAdminRole reports whether the signed-in admin holds one of roles. Superadmins always do.
+func HasAdminRole(c *gin.Context, roles ...string) bool {
+	admin := c.MustGet("admin").(*models.AdminAccount)
+	if admin.Role == RoleSuperadmin {
+		return true
+	}
+	for _, role := range roles {
+		if admin.Role == role {
+			return true
+		}
+	}
+	return false
+}
+
+// RequireAdminRole restricts a route to the given roles. Must run after IsAdmin; superadmins always pass.
+func RequireAdminRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if HasAdminRole(c, roles...) {
+			c.Next()
+			return
+		}
+		utils.RespondError(c, http.StatusForbidden, "Your admin role doesn't have access to this resource", nil)
+		c.Abort()
+	}
+}
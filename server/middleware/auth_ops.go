@@ -3,6 +3,7 @@ package middleware
 import (
 	"net/http"
 	"os"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -11,10 +12,12 @@ import (
 
 // APIKeyAuth validates the x-api-key header against the server's API_KEY env var.
 // If API_KEY is not set, all requests pass through (dev mode).
+// A white-label tenant's own key (matched by TenantResolver) is accepted too.
+// /api/v<N>/public/* is exempt: those links are opened in a browser, which never sends the key.
 func APIKeyAuth() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		serverKey := os.Getenv("API_KEY")
-		if serverKey == "" {
+		if serverKey == "" || c.GetBool("tenantApiKey") || strings.HasPrefix(utils.RoutePath(c), "/public/") {
 			c.Next()
 			return
 		}
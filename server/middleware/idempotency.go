@@ -0,0 +1,137 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"ridewave/db"
+	"ridewave/utils"
+)
+
+const (
+	idempotencyHeader    = "Idempotency-Key"
+	idempotencyKeyMaxLen = 128
+	idempotencyTTL       = 24 * time.Hour
+)
+
+// idempotentResponse is what's kept in Redis per key. Status 0 means the first request is still running.
+type idempotentResponse struct {
+	Status      int    `json:"status"`
+	BodyHash    string `json:"bodyHash"`
+	ContentType string `json:"contentType,omitempty"`
+	Body        []byte `json:"body,omitempty"`
+}
+
+// idempotencyRecorder copies the response body so it can be replayed.
+type idempotencyRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *idempotencyRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *idempotencyRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Idempotency replays the stored response when a client retries a request with the same
+// Idempotency-Key header, instead of running the handler again. Keys are scoped to the caller
+// and route and kept for 24h. Reusing a key with a different body is rejected, as is a retry
+// that arrives while the first request is still running. Requests without the header, and
+// all requests while Redis is down, pass straight through. Place it after the auth middleware.
+func Idempotency() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(idempotencyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > idempotencyKeyMaxLen {
+			utils.RespondError(c, http.StatusBadRequest, "Idempotency-Key must be at most 128 characters", nil)
+			c.Abort()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			utils.RespondError(c, http.StatusBadRequest, "Invalid request body", err)
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		sum := sha256.Sum256(body)
+		bodyHash := hex.EncodeToString(sum[:])
+
+		redisKey := "idempotency:" + callerIdentity(c) + ":" + c.FullPath() + ":" + key
+		ctx := c.Request.Context()
+		marker, _ := json.Marshal(idempotentResponse{BodyHash: bodyHash})
+		claimed, err := db.RedisClient.SetNX(ctx, redisKey, marker, idempotencyTTL).Result()
+		if err != nil {
+			utils.Logger.Warn("Idempotency check skipped, Redis unavailable", zap.Error(err))
+			c.Next()
+			return
+		}
+
+		if !claimed {
+			var stored idempotentResponse
+			raw, err := db.RedisClient.Get(ctx, redisKey).Bytes()
+			if err != nil || json.Unmarshal(raw, &stored) != nil {
+				utils.RespondErrorCode(c, http.StatusConflict, utils.CodeIdempotencyPending, "A request with this Idempotency-Key is still being processed", nil)
+				c.Abort()
+				return
+			}
+			if stored.BodyHash != bodyHash {
+				utils.RespondErrorCode(c, http.StatusUnprocessableEntity, utils.CodeIdempotencyKeyReused, "Idempotency-Key was already used with a different request body", nil)
+				c.Abort()
+				return
+			}
+			if stored.Status == 0 {
+				utils.RespondErrorCode(c, http.StatusConflict, utils.CodeIdempotencyPending, "A request with this Idempotency-Key is still being processed", nil)
+				c.Abort()
+				return
+			}
+			c.Header("Idempotent-Replayed", "true")
+			c.Data(stored.Status, stored.ContentType, stored.Body)
+			c.Abort()
+			return
+		}
+
+		// Server errors and panics aren't remembered, so the client can retry with the same key
+		// rather than being told it is still being processed until the marker expires
+		remembered := false
+		defer func() {
+			if !remembered {
+				db.RedisClient.Del(context.WithoutCancel(ctx), redisKey)
+			}
+		}()
+
+		recorder := &idempotencyRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		status := recorder.Status()
+		if status >= http.StatusInternalServerError {
+			return
+		}
+		stored, _ := json.Marshal(idempotentResponse{
+			Status:      status,
+			BodyHash:    bodyHash,
+			ContentType: recorder.Header().Get("Content-Type"),
+			Body:        recorder.body.Bytes(),
+		})
+		db.RedisClient.Set(context.WithoutCancel(ctx), redisKey, stored, idempotencyTTL)
+		remembered = true
+	}
+}
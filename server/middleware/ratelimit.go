@@ -2,78 +2,245 @@ package middleware
 
 import (
 	"context"
+	"math"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
 	"golang.org/x/time/rate"
+	"ridewave/db"
+	"ridewave/models"
 	"ridewave/utils"
 )
 
-// IP-based Rate Limiter with automatic cleanup
-type IPRateLimiter struct {
-	ips map[string]*rate.Limiter
-	mu  *sync.RWMutex
-	r   rate.Limit
-	b   int
+// RateLimitRule is a token bucket: Burst requests at once, refilled at Rate per second.
+type RateLimitRule struct {
+	Rate  float64
+	Burst int
 }
 
-func NewIPRateLimiter(r rate.Limit, b int) *IPRateLimiter {
-	i := &IPRateLimiter{
-		ips: make(map[string]*rate.Limiter),
-		mu:  &sync.RWMutex{},
-		r:   r,
-		b:   b,
-	}
+// PerMinute allows n requests a minute, all of which may come at once.
+func PerMinute(n int) RateLimitRule {
+	return RateLimitRule{Rate: float64(n) / 60, Burst: n}
+}
+
+type rateLimitResult struct {
+	Allowed    bool
+	Remaining  int
+	RetryAfter time.Duration // until the next token, when not allowed
+	Reset      time.Duration // until the bucket is full again
+}
+
+// tokenBucketScript refills and takes one token atomically, on Redis' clock so every instance agrees.
+// Returns {allowed, remaining, retryAfterMs, resetMs}.
+var tokenBucketScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1]) or burst
+local ts = tonumber(state[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate / 1000)
+
+local allowed = 0
+local retry = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	retry = math.ceil((1 - tokens) * 1000 / rate)
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst * 1000 / rate) + 1000)
+return {allowed, math.floor(tokens), retry, math.ceil((burst - tokens) * 1000 / rate)}
+`)
 
-	// Periodic cleanup to prevent memory leak
+// fallbackLimiters keep limiting on this instance alone while Redis is unreachable.
+var fallbackLimiters = struct {
+	sync.Mutex
+	buckets map[string]*fallbackBucket
+}{buckets: map[string]*fallbackBucket{}}
+
+type fallbackBucket struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+func init() {
+	// Forget buckets that have been idle long enough to be full again
 	go func() {
 		for {
 			time.Sleep(10 * time.Minute)
-			i.mu.Lock()
-			i.ips = make(map[string]*rate.Limiter)
-			i.mu.Unlock()
+			fallbackLimiters.Lock()
+			for key, b := range fallbackLimiters.buckets {
+				if time.Since(b.lastSeen) > 10*time.Minute {
+					delete(fallbackLimiters.buckets, key)
+				}
+			}
+			fallbackLimiters.Unlock()
 		}
 	}()
+}
+
+func allowFallback(key string, rule RateLimitRule) rateLimitResult {
+	fallbackLimiters.Lock()
+	defer fallbackLimiters.Unlock()
+	b, ok := fallbackLimiters.buckets[key]
+	if !ok {
+		b = &fallbackBucket{limiter: rate.NewLimiter(rate.Limit(rule.Rate), rule.Burst)}
+		fallbackLimiters.buckets[key] = b
+	}
+	b.lastSeen = time.Now()
 
-	return i
+	res := rateLimitResult{Allowed: b.limiter.Allow()}
+	tokens := b.limiter.Tokens()
+	res.Remaining = int(math.Max(0, math.Floor(tokens)))
+	if !res.Allowed {
+		res.RetryAfter = time.Duration((1 - tokens) / rule.Rate * float64(time.Second))
+	}
+	res.Reset = time.Duration((float64(rule.Burst) - tokens) / rule.Rate * float64(time.Second))
+	return res
 }
 
-func (i *IPRateLimiter) GetLimiter(ip string) *rate.Limiter {
-	i.mu.Lock()
-	defer i.mu.Unlock()
+func allowRequest(ctx context.Context, key string, rule RateLimitRule) rateLimitResult {
+	vals, err := tokenBucketScript.Run(ctx, db.RedisClient, []string{"ratelimit:" + key}, rule.Rate, rule.Burst).Int64Slice()
+	if err != nil || len(vals) != 4 {
+		utils.Logger.Warn("Rate limiter falling back to local buckets", zap.String("key", key), zap.Error(err))
+		return allowFallback(key, rule)
+	}
+	return rateLimitResult{
+		Allowed:    vals[0] == 1,
+		Remaining:  int(vals[1]),
+		RetryAfter: time.Duration(vals[2]) * time.Millisecond,
+		Reset:      time.Duration(vals[3]) * time.Millisecond,
+	}
+}
 
-	limiter, exists := i.ips[ip]
-	if !exists {
-		limiter = rate.NewLimiter(i.r, i.b)
-		i.ips[ip] = limiter
+// enforceRateLimit takes a token for key and answers 429 when none is left. The X-RateLimit-*
+// headers describe the tightest limit the request went through.
+func enforceRateLimit(c *gin.Context, key string, rule RateLimitRule) bool {
+	res := allowRequest(c.Request.Context(), key, rule)
+
+	if prev, ok := c.Get("rateLimitRemaining"); !ok || res.Remaining <= prev.(int) {
+		c.Set("rateLimitRemaining", res.Remaining)
+		c.Header("X-RateLimit-Limit", strconv.Itoa(rule.Burst))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		c.Header("X-RateLimit-Reset", strconv.Itoa(int(math.Ceil(res.Reset.Seconds()))))
+	}
+	if res.Allowed {
+		return true
+	}
+	c.Header("Retry-After", strconv.Itoa(int(math.Max(1, math.Ceil(res.RetryAfter.Seconds())))))
+	utils.RespondError(c, http.StatusTooManyRequests, "Too many requests. Please slow down.", nil)
+	c.Abort()
+	return false
+}
+
+// callerIdentity is the authenticated user, driver, admin or fleet owner, or the client IP before auth.
+func callerIdentity(c *gin.Context) string {
+	if u, ok := c.Get("user"); ok {
+		if user, ok := u.(*models.User); ok {
+			return "user:" + user.ID
+		}
+	}
+	if d, ok := c.Get("driver"); ok {
+		if driver, ok := d.(*models.Driver); ok {
+			return "driver:" + driver.ID
+		}
+	}
+	if a, ok := c.Get("admin"); ok {
+		if admin, ok := a.(*models.AdminAccount); ok {
+			return "admin:" + admin.ID
+		}
+	}
+	if f, ok := c.Get("fleet"); ok {
+		if fleet, ok := f.(*models.Fleet); ok {
+			return "fleet:" + fleet.ID
+		}
 	}
+	return "ip:" + c.ClientIP()
+}
 
-	return limiter
+// globalRateLimit is the per-IP budget for every request (RATE_LIMIT_RPS, default 5/s, RATE_LIMIT_BURST, default 10).
+func globalRateLimit() RateLimitRule {
+	rule := RateLimitRule{Rate: 5, Burst: 10}
+	if val, err := strconv.ParseFloat(os.Getenv("RATE_LIMIT_RPS"), 64); err == nil && val > 0 {
+		rule.Rate = val
+	}
+	if val, err := strconv.Atoi(os.Getenv("RATE_LIMIT_BURST")); err == nil && val > 0 {
+		rule.Burst = val
+	}
+	return rule
 }
 
-var limiter = NewIPRateLimiter(5, 10) // 5 req/sec, burst of 10
+// identityRateLimit is the per-account budget once logged in (RATE_LIMIT_IDENTITY_PER_MINUTE, default 300),
+// so one account can't get around the IP limit by spreading over many addresses.
+func identityRateLimit() RateLimitRule {
+	if val, err := strconv.Atoi(os.Getenv("RATE_LIMIT_IDENTITY_PER_MINUTE")); err == nil && val > 0 {
+		return PerMinute(val)
+	}
+	return PerMinute(300)
+}
 
-// RateLimit enforces per-IP rate limiting
+// RateLimit enforces the per-IP limit, shared across instances through Redis
 func RateLimit() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		ip := c.ClientIP()
-		l := limiter.GetLimiter(ip)
-		if !l.Allow() {
-			utils.RespondError(c, http.StatusTooManyRequests, "Too many requests. Please slow down.", nil)
-			c.Abort()
-			return
+		if enforceRateLimit(c, "ip:"+c.ClientIP(), globalRateLimit()) {
+			c.Next()
+		}
+	}
+}
+
+// RateLimitRoute limits one route per caller: the authenticated account when it runs after the auth
+// middleware, else the client IP. RATE_LIMIT_<NAME>_PER_MINUTE overrides perMinute (name upper-cased,
+// dashes as underscores).
+func RateLimitRoute(name string, perMinute int) gin.HandlerFunc {
+	env := "RATE_LIMIT_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_PER_MINUTE"
+	if val, err := strconv.Atoi(os.Getenv(env)); err == nil && val > 0 {
+		perMinute = val
+	}
+	rule := PerMinute(perMinute)
+	return func(c *gin.Context) {
+		if enforceRateLimit(c, "route:"+name+":"+callerIdentity(c), rule) {
+			c.Next()
 		}
-		c.Next()
 	}
 }
 
-// TimeoutMiddleware prevents long-hanging requests (10s max)
+// statementTimeout caps a single query run for a request (DB_STATEMENT_TIMEOUT_MS, default 8000),
+// below the request timeout so a slow query fails cleanly instead of outliving its request.
+func statementTimeout() time.Duration {
+	if val, err := strconv.Atoi(os.Getenv("DB_STATEMENT_TIMEOUT_MS")); err == nil && val > 0 {
+		return time.Duration(val) * time.Millisecond
+	}
+	return 8 * time.Second
+}
+
+// streamingRoutes stream large responses, so they are exempt from the request timeout;
+// they still stop when the client goes away.
+var streamingRoutes = map[string]bool{
+	"/admin/export/:entity": true,
+}
+
+// TimeoutMiddleware prevents long-hanging requests (10s max). Handlers pass c.Request.Context()
+// to their queries, so a timed out or abandoned request also cancels what it was running.
 func TimeoutMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		if streamingRoutes[utils.RoutePath(c)] {
+			c.Next()
+			return
+		}
 		ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
 		defer cancel()
+		ctx = db.WithStatementTimeout(ctx, statementTimeout())
 
 		c.Request = c.Request.WithContext(ctx)
 
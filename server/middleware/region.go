@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"ridewave/db"
+	"ridewave/utils"
+)
+
+// RegionGuard turns away requests addressed to another region (X-Region header) with
+// 421 Misdirected Request and that region's endpoint, so data never lands outside its home region.
+func RegionGuard() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requested := strings.ToLower(strings.TrimSpace(c.GetHeader("X-Region")))
+		if requested == "" || requested == db.Region() {
+			c.Next()
+			return
+		}
+
+		endpoint, ok := utils.RegionEndpoints()[requested]
+		if !ok {
+			utils.RespondError(c, http.StatusBadRequest, "Unknown region: "+requested, nil)
+			c.Abort()
+			return
+		}
+		c.JSON(http.StatusMisdirectedRequest, gin.H{
+			"success":  false,
+			"code":     utils.CodeWrongRegion,
+			"message":  "This request belongs to another region",
+			"region":   requested,
+			"endpoint": endpoint,
+		})
+		c.Abort()
+	}
+}
+
+// FederationAuth protects region-to-region endpoints with the shared REGION_FEDERATION_SECRET.
+func FederationAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		secret := os.Getenv("REGION_FEDERATION_SECRET")
+		if secret == "" || c.GetHeader("X-Federation-Secret") != secret {
+			utils.RespondError(c, http.StatusForbidden, "Forbidden", nil)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
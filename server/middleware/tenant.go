@@ -0,0 +1,116 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"ridewave/db"
+	"ridewave/utils"
+)
+
+const tenantCacheTTL = 30 * time.Second
+
+type tenantEntry struct {
+	id       string // "" when nothing matched
+	active   bool
+	cachedAt time.Time
+}
+
+var (
+	tenantCacheMu sync.Mutex
+	tenantCache   = map[string]tenantEntry{}
+)
+
+// TenantKeyHash is how tenant API keys are stored: only the SHA-256 is kept.
+func TenantKeyHash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// InvalidateTenants drops cached lookups after a tenant's keys, domains or status change.
+func InvalidateTenants() {
+	tenantCacheMu.Lock()
+	tenantCache = map[string]tenantEntry{}
+	tenantCacheMu.Unlock()
+}
+
+// lookupTenant resolves one cache key ("key:<hash>" or "host:<domain>") with a short-lived cache.
+func lookupTenant(ctx context.Context, cacheKey, query, arg string) (tenantEntry, error) {
+	tenantCacheMu.Lock()
+	entry, ok := tenantCache[cacheKey]
+	tenantCacheMu.Unlock()
+	if ok && time.Since(entry.cachedAt) < tenantCacheTTL {
+		return entry, nil
+	}
+
+	entry = tenantEntry{cachedAt: time.Now()}
+	err := db.Pool.QueryRow(ctx, query, arg).Scan(&entry.id, &entry.active)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return entry, err
+	}
+
+	tenantCacheMu.Lock()
+	tenantCache[cacheKey] = entry
+	tenantCacheMu.Unlock()
+	return entry, nil
+}
+
+// TenantResolver works out which white-label tenant a request is for: a tenant API key
+// (x-api-key) wins, then the request's domain, then the default tenant. Every query the
+// request runs with c.Request.Context() is then limited to that tenant's rows.
+func TenantResolver() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tenant := tenantEntry{id: db.DefaultTenant, active: true}
+		byKey := false
+
+		if key := c.GetHeader("x-api-key"); key != "" {
+			entry, err := lookupTenant(c.Request.Context(), "key:"+TenantKeyHash(key),
+				`SELECT id, "isActive" FROM tenants WHERE "apiKeyHash"=$1`, TenantKeyHash(key))
+			if err != nil {
+				utils.RespondError(c, http.StatusServiceUnavailable, "Service temporarily unavailable", err)
+				c.Abort()
+				return
+			}
+			if entry.id != "" {
+				tenant, byKey = entry, true
+			}
+		}
+
+		if !byKey {
+			host := strings.ToLower(c.Request.Host)
+			if h, _, err := net.SplitHostPort(host); err == nil {
+				host = h
+			}
+			entry, err := lookupTenant(c.Request.Context(), "host:"+host,
+				`SELECT id, "isActive" FROM tenants WHERE $1=ANY(domains)`, host)
+			if err != nil {
+				utils.RespondError(c, http.StatusServiceUnavailable, "Service temporarily unavailable", err)
+				c.Abort()
+				return
+			}
+			if entry.id != "" {
+				tenant = entry
+			}
+		}
+
+		if !tenant.active {
+			utils.RespondErrorCode(c, http.StatusForbidden, utils.CodeTenantInactive, "This service is no longer available", nil)
+			c.Abort()
+			return
+		}
+
+		c.Set("tenantId", tenant.id)
+		c.Set("tenantApiKey", byKey)
+		c.Request = c.Request.WithContext(db.WithTenant(c.Request.Context(), tenant.id))
+		c.Next()
+	}
+}
@@ -48,6 +48,7 @@ type Ride struct {
 	UserID                  string      `json:"userId"`
 	DriverID                *string     `json:"driverId"`
 	Charge                  float64     `json:"charge"`
+	Currency                string      `json:"currency"`
 	CurrentLocationName     string      `json:"currentLocationName"`
 	DestinationLocationName string      `json:"destinationLocationName"`
 	Distance                string      `json:"distance"`
@@ -65,9 +66,13 @@ type Ride struct {
 	PaymentMode             string      `json:"paymentMode"`
 	PaymentStatus           string      `json:"paymentStatus"`
 	Tips                    float64     `json:"tips"`
+	WaitingMinutes          int         `json:"waitingMinutes"`
+	WaitingCharge           float64     `json:"waitingCharge"`
+	CancellationFee         float64     `json:"cancellationFee"`
 	CancelReason            string      `json:"cancelReason"`
 	OTP                     string      `json:"otp"`
 	AcceptedAt              *time.Time  `json:"acceptedAt,omitempty"`
+	ArrivedAt               *time.Time  `json:"arrivedAt,omitempty"`
 	StartedAt               *time.Time  `json:"startedAt,omitempty"`
 	CompletedAt             *time.Time  `json:"completedAt,omitempty"`
 	CancelledAt             *time.Time  `json:"cancelledAt,omitempty"`
@@ -77,15 +82,137 @@ type Ride struct {
 	User                    interface{} `json:"user,omitempty"`
 }
 
+type ScheduledRide struct {
+	ID              string     `json:"id"`
+	UserID          string     `json:"userId"`
+	RouteID         string     `json:"routeId"`
+	VehicleType     string     `json:"vehicleType"`
+	OriginName      string     `json:"originName"`
+	DestinationName string     `json:"destinationName"`
+	OriginLat       float64    `json:"originLat"`
+	OriginLng       float64    `json:"originLng"`
+	DestinationLat  float64    `json:"destinationLat"`
+	DestinationLng  float64    `json:"destinationLng"`
+	Fare            float64    `json:"fare"`
+	Distance        int        `json:"distance"`
+	Duration        int        `json:"duration"`
+	PaymentMode     string     `json:"paymentMode"`
+	PickupAt        time.Time  `json:"pickupAt"`
+	ArriveBy        *time.Time `json:"arriveBy,omitempty"`
+	DispatchAt      time.Time  `json:"dispatchAt"`
+	Status          string     `json:"status"`
+	RideID          *string    `json:"rideId"`
+	CreatedAt       time.Time  `json:"createdAt"`
+	UpdatedAt       time.Time  `json:"updatedAt"`
+}
+
+type RideAnomaly struct {
+	ID         string     `json:"id"`
+	RideID     string     `json:"rideId"`
+	Type       string     `json:"type"`
+	Details    string     `json:"details"`
+	Status     string     `json:"status"`
+	ResolvedAt *time.Time `json:"resolvedAt,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+type Wallet struct {
+	ID              string    `json:"id"`
+	DriverID        string    `json:"driverId"`
+	Balance         float64   `json:"balance"`
+	TotalEarned     float64   `json:"totalEarned"`
+	TotalCommission float64   `json:"totalCommission"`
+	TotalPaidOut    float64   `json:"totalPaidOut"`
+	CreatedAt       time.Time `json:"createdAt"`
+	UpdatedAt       time.Time `json:"updatedAt"`
+}
+
+type WalletTransaction struct {
+	ID              string    `json:"id"`
+	WalletID        string    `json:"walletId"`
+	DriverID        string    `json:"driverId"`
+	RideID          *string   `json:"rideId"`
+	Type            string    `json:"type"` // ride_earning | payout
+	Amount          float64   `json:"amount"`
+	Commission      float64   `json:"commission"`
+	FleetID         *string   `json:"fleetId"`
+	FleetCommission float64   `json:"fleetCommission"` // the fleet owner's cut, taken before Amount
+	BalanceAfter    float64   `json:"balanceAfter"`
+	Reference       string    `json:"reference"`
+	CreatedAt       time.Time `json:"createdAt"`
+}
+
+type Fleet struct {
+	ID                string    `json:"id"`
+	Name              string    `json:"name"`
+	OwnerName         string    `json:"ownerName"`
+	PhoneNumber       string    `json:"phoneNumber"`
+	Email             string    `json:"email"`
+	CommissionPercent float64   `json:"commissionPercent"` // of the driver's earning after platform commission
+	Status            string    `json:"status"`            // active | suspended
+	TotalEarned       float64   `json:"totalEarned"`
+	CreatedAt         time.Time `json:"createdAt"`
+	UpdatedAt         time.Time `json:"updatedAt"`
+}
+
 type Payment struct {
 	ID        string    `json:"id"`
 	RideID    string    `json:"rideId"`
 	Amount    float64   `json:"amount"`
+	AmountDue float64   `json:"amountDue"` // still owed, e.g. after a dispute raised the fare
+	Currency  string    `json:"currency"`
 	Mode      string    `json:"mode"`
 	Status    string    `json:"status"`
 	CreatedAt time.Time `json:"createdAt"`
 }
 
+type Refund struct {
+	ID          string     `json:"id"`
+	RideID      string     `json:"rideId"`
+	PaymentID   string     `json:"paymentId"`
+	Amount      float64    `json:"amount"`
+	Reason      string     `json:"reason"`
+	Status      string     `json:"status"` // pending | processed | failed
+	Reference   string     `json:"reference"`
+	ProcessedAt *time.Time `json:"processedAt,omitempty"`
+	CreatedAt   time.Time  `json:"createdAt"`
+}
+
+type AdminAccount struct {
+	ID           string     `json:"id"`
+	Email        string     `json:"email"`
+	Name         string     `json:"name"`
+	PasswordHash string     `json:"-"`
+	Role         string     `json:"role"` // superadmin | support | finance
+	IsActive     bool       `json:"isActive"`
+	LastLoginAt  *time.Time `json:"lastLoginAt,omitempty"`
+	CreatedAt    time.Time  `json:"createdAt"`
+	UpdatedAt    time.Time  `json:"updatedAt"`
+}
+
+type AdminNote struct {
+	ID         string    `json:"id"`
+	EntityType string    `json:"entityType"` // user | driver | ride
+	EntityID   string    `json:"entityId"`
+	Note       string    `json:"note"`
+	Tags       []string  `json:"tags"`
+	Author     string    `json:"author"`
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+type DuplicateCluster struct {
+	ID         string     `json:"id"`
+	EntityType string     `json:"entityType"` // user | driver
+	Signal     string     `json:"signal"`     // driving_license | registration_number | payout_upi | email | device
+	Value      string     `json:"value"`
+	EntityIDs  []string   `json:"entityIds"`
+	Status     string     `json:"status"` // open | merged | suspended | dismissed
+	KeptID     *string    `json:"keptId,omitempty"`
+	DetectedAt time.Time  `json:"detectedAt"`
+	ResolvedAt *time.Time `json:"resolvedAt,omitempty"`
+}
+
 type VehicleTypeConfig struct {
 	ID         string    `json:"id"`
 	Name       string    `json:"name"`
@@ -96,6 +223,24 @@ type VehicleTypeConfig struct {
 	IsActive   bool      `json:"isActive"`
 	CreatedAt  time.Time `json:"createdAt"`
 	UpdatedAt  time.Time `json:"updatedAt"`
+
+	// Availability rules — empty zones means everywhere, nil window means all day ("HH:MM", may wrap midnight)
+	AllowedZones      []string `json:"allowedZones"`
+	AvailableFrom     *string  `json:"availableFrom"`
+	AvailableUntil    *string  `json:"availableUntil"`
+	Available         bool     `json:"available"`
+	UnavailableReason string   `json:"unavailableReason,omitempty"`
+
+	// Display metadata so client UIs are server-driven; IconURL is set when an icon was uploaded
+	IconAssetID *string `json:"-"`
+	IconURL     string  `json:"iconUrl,omitempty"`
+	Capacity    *int    `json:"capacity"`
+	Description string  `json:"description"`
+	ETABlurb    string  `json:"etaBlurb"`
+
+	// Carbon accounting — EmissionFactor is g CO2 per km; nil falls back to the EV/non-EV default
+	IsElectric     bool     `json:"isElectric"`
+	EmissionFactor *float64 `json:"emissionFactor"`
 }
 
 type SOSAlert struct {
@@ -120,14 +265,116 @@ type PromoCode struct {
 	UsedCount     int        `json:"usedCount"`
 	ExpiresAt     *time.Time `json:"expiresAt,omitempty"`
 	IsActive      bool       `json:"isActive"`
+	UserID        *string    `json:"userId,omitempty"` // set for codes only one rider may use
+	Rules         PromoRules `json:"rules"`
 	CreatedAt     time.Time  `json:"createdAt"`
 }
 
+// PromoRules narrows who can use a promo code, on what and when. Empty rules don't restrict.
+type PromoRules struct {
+	FirstRideOnly bool     `json:"firstRideOnly"`
+	VehicleTypes  []string `json:"vehicleTypes"`
+	Zones         []string `json:"zones"`
+	Segments      []string `json:"segments"`     // rider segments, see promos.Segments
+	Days          []int    `json:"days"`         // days of the week, 0 = Sunday
+	TimeFrom      *string  `json:"timeFrom"`     // "HH:MM" in the service timezone
+	TimeUntil     *string  `json:"timeUntil"`    // may wrap midnight
+	PerUserLimit  int      `json:"perUserLimit"` // redemptions per rider; 0 = no limit
+}
+
+type RatingConfig struct {
+	ID             string    `json:"id"`
+	Audience       string    `json:"audience"`    // "rider" (rates driver) or "driver" (rates rider)
+	VehicleType    string    `json:"vehicleType"` // "" = default for all types
+	MandatoryBelow float64   `json:"mandatoryBelow"`
+	RequireComment bool      `json:"requireComment"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+}
+
+type RatingTag struct {
+	ID          string            `json:"id"`
+	Audience    string            `json:"audience"`
+	VehicleType string            `json:"vehicleType"`
+	Key         string            `json:"key"`
+	Labels      map[string]string `json:"labels"` // lang → text
+	MinRating   float64           `json:"minRating"`
+	MaxRating   float64           `json:"maxRating"`
+	SortOrder   int               `json:"sortOrder"`
+	IsActive    bool              `json:"isActive"`
+	CreatedAt   time.Time         `json:"createdAt"`
+}
+
+type MarketingConsent struct {
+	UserID    string    `json:"userId"`
+	Channel   string    `json:"channel"` // sms | whatsapp | email
+	Status    string    `json:"status"`  // opted_in | opted_out
+	Source    string    `json:"source"`  // app, admin, sms_stop, dnd_registry, ...
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// NotificationPreferences is which optional messages a rider or driver wants, and how ride
+// updates reach them. Transactional messages (OTPs, payments, safety) ignore them.
+type NotificationPreferences struct {
+	Promotions    bool       `json:"promotions"`
+	RideUpdates   string     `json:"rideUpdates"` // push | sms
+	EmailReceipts bool       `json:"emailReceipts"`
+	UpdatedAt     *time.Time `json:"updatedAt"`
+}
+
+type SavedPlace struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"userId"`
+	Label     string    `json:"label"` // home | work | any custom label
+	Name      string    `json:"name"`
+	Address   string    `json:"address"`
+	Lat       float64   `json:"lat"`
+	Lng       float64   `json:"lng"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// DriverDiagnostics is the latest device health snapshot reported by the driver app.
+type DriverDiagnostics struct {
+	DriverID     string    `json:"driverId"`
+	BatteryLevel *int      `json:"batteryLevel"` // percent
+	IsCharging   *bool     `json:"isCharging"`
+	GPSAccuracy  *float64  `json:"gpsAccuracy"` // metres
+	NetworkType  string    `json:"networkType"` // wifi | 5g | 4g | 3g | 2g | none | unknown
+	AppVersion   string    `json:"appVersion"`
+	Platform     string    `json:"platform"`
+	OSVersion    string    `json:"osVersion"`
+	ReportedAt   time.Time `json:"reportedAt"`
+}
+
+// Tenant is a white-label operator sharing the platform. Branding holds the strings its apps
+// display (appName, logoUrl, primaryColor, supportPhone, ...); Zones limits it to some service zones.
+type Tenant struct {
+	ID        string            `json:"id"`
+	Name      string            `json:"name"`
+	Domains   []string          `json:"domains"`
+	Branding  map[string]string `json:"branding"`
+	Zones     []string          `json:"zones"` // empty = every zone
+	IsActive  bool              `json:"isActive"`
+	CreatedAt time.Time         `json:"createdAt"`
+	UpdatedAt time.Time         `json:"updatedAt"`
+}
+
 type ServiceZone struct {
-	Name   string  `json:"name"`
-	Lat    float64 `json:"lat"`
-	Lng    float64 `json:"lng"`
-	Radius float64 `json:"radius"`
+	Name       string       `json:"name"`
+	Lat        float64      `json:"lat"`
+	Lng        float64      `json:"lng"`
+	Radius     float64      `json:"radius"`            // KM around lat/lng, when there is no polygon
+	Polygon    [][2]float64 `json:"polygon,omitempty"` // [lat, lng] boundary; wins over the radius
+	LaunchMode string       `json:"launchMode"`        // "live" or "beta" (allowlisted riders only)
+	GeofenceID *string      `json:"geofenceId"`        // Ola Maps geofence mirroring the zone
+	SortOrder  int          `json:"sortOrder"`         // overlapping zones match in this order
+	IsActive   bool         `json:"isActive"`
+	UpdatedAt  time.Time    `json:"updatedAt"`
+
+	// Pricing overrides for rides picked up in the zone; nil uses the deployment's
+	Currency     *string  `json:"currency"`     // ISO 4217
+	FareRounding *string  `json:"fareRounding"` // up | nearest | down
+	FareRoundTo  *float64 `json:"fareRoundTo"`
 }
 type APILog struct {
 	ID              string      `json:"id"`
@@ -140,3 +387,34 @@ type APILog struct {
 	DurationMs      int         `json:"durationMs"`
 	CreatedAt       time.Time   `json:"createdAt"`
 }
+
+// TrainingModule is one onboarding lesson a new driver must pass before approval.
+type TrainingModule struct {
+	ID        string             `json:"id"`
+	Title     string             `json:"title"`
+	Content   string             `json:"content"` // markdown
+	VideoURL  string             `json:"videoUrl"`
+	Questions []TrainingQuestion `json:"questions"`
+	PassScore int                `json:"passScore"` // percent of questions answered correctly
+	SortOrder int                `json:"sortOrder"`
+	IsActive  bool               `json:"isActive"`
+	CreatedAt time.Time          `json:"createdAt"`
+	UpdatedAt time.Time          `json:"updatedAt"`
+}
+
+type TrainingQuestion struct {
+	Question    string   `json:"question"`
+	Options     []string `json:"options"`
+	AnswerIndex int      `json:"answerIndex"` // hidden from drivers
+}
+
+// TrainingProgress is a driver's standing on one module.
+type TrainingProgress struct {
+	ModuleID  string     `json:"moduleId"`
+	Title     string     `json:"title"`
+	PassScore int        `json:"passScore"`
+	BestScore int        `json:"bestScore"`
+	Attempts  int        `json:"attempts"`
+	Passed    bool       `json:"passed"`
+	PassedAt  *time.Time `json:"passedAt"`
+}
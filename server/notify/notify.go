@@ -0,0 +1,165 @@
+// Package notify delivers a message to one rider or driver over whichever channel reaches them.
+// Each event has an ordered list of channels; Deliver tries them in turn, moving on when a channel
+// can't reach the recipient or its send fails, so a ride update that matters still arrives when a
+// phone has no push token or FCM is down. Channels are Senders, registered by name.
+package notify
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+	"ridewave/utils"
+)
+
+// Events whose messages fall back to SMS by default: the rider must not miss that a driver is
+// coming (the message carries the trip OTP) or is waiting outside.
+const (
+	EventRideAccepted  = "ride_accepted"
+	EventDriverArrived = "driver_arrived"
+)
+
+// ErrUnreachable means a channel can't reach the recipient at all, e.g. there's no push token or
+// the channel isn't configured. Deliver moves on without counting it as a failed send.
+var ErrUnreachable = errors.New("notify: channel can't reach the recipient")
+
+// Message is one notification, with every address the channels might use.
+type Message struct {
+	Event     string
+	Recipient utils.Recipient
+	Title     string
+	Body      string
+	Data      utils.FCMData
+	Token     string // push token, "" if none
+	Phone     string
+}
+
+// Sender sends messages on one channel.
+type Sender interface {
+	Channel() string
+	// Send returns ErrUnreachable when the channel has no way to reach m's recipient.
+	Send(ctx context.Context, m Message) error
+}
+
+var (
+	mu           sync.RWMutex
+	senders      = map[string]Sender{}
+	routes       = map[string][]string{}
+	defaultRoute = []string{utils.ChannelPush}
+
+	// NOTIFICATION_ROUTES is read on first lookup rather than in init, which runs before main
+	// loads .env
+	envRoutesOnce sync.Once
+)
+
+func init() {
+	Register(pushSender{})
+	Register(smsSender{})
+	Route(EventRideAccepted, utils.ChannelPush, utils.ChannelSMS)
+	Route(EventDriverArrived, utils.ChannelPush, utils.ChannelSMS)
+}
+
+// Register adds a channel, replacing any sender already registered for it.
+func Register(s Sender) {
+	mu.Lock()
+	defer mu.Unlock()
+	senders[s.Channel()] = s
+}
+
+// Route sets the channels an event's messages try, in order. Events without a route use push only.
+func Route(event string, channels ...string) {
+	mu.Lock()
+	defer mu.Unlock()
+	routes[event] = channels
+}
+
+// Channels returns the channels an event's messages try, in order.
+func Channels(event string) []string {
+	envRoutesOnce.Do(func() { loadRoutes(os.Getenv("NOTIFICATION_ROUTES")) })
+	mu.RLock()
+	defer mu.RUnlock()
+	if channels, ok := routes[event]; ok {
+		return channels
+	}
+	return defaultRoute
+}
+
+// loadRoutes applies NOTIFICATION_ROUTES, e.g. "ride_accepted=sms,push;ride_cancelled=push,sms",
+// over the defaults.
+func loadRoutes(s string) {
+	for _, entry := range strings.Split(s, ";") {
+		event, list, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok || event == "" {
+			continue
+		}
+		var channels []string
+		for _, ch := range strings.Split(list, ",") {
+			if ch = strings.TrimSpace(ch); ch != "" {
+				channels = append(channels, ch)
+			}
+		}
+		if len(channels) > 0 {
+			Route(event, channels...)
+		}
+	}
+}
+
+// Deliver sends m on the first of channels that takes it and returns that channel. If none can
+// reach the recipient it returns ErrUnreachable; if sends failed it returns the last failure, for
+// the caller to retry later.
+func Deliver(ctx context.Context, m Message, channels []string) (string, error) {
+	var failed error
+	for _, channel := range channels {
+		mu.RLock()
+		s, ok := senders[channel]
+		mu.RUnlock()
+		if !ok {
+			continue
+		}
+		err := s.Send(ctx, m)
+		if err == nil {
+			return channel, nil
+		}
+		if !errors.Is(err, ErrUnreachable) {
+			utils.Logger.Warn("Notification channel failed",
+				zap.String("event", m.Event), zap.String("channel", channel), zap.Error(err))
+			failed = err
+		}
+	}
+	if failed != nil {
+		return "", failed
+	}
+	return "", ErrUnreachable
+}
+
+// pushSender sends through FCM, honouring the recipient's notification preferences.
+type pushSender struct{}
+
+func (pushSender) Channel() string { return utils.ChannelPush }
+
+func (pushSender) Send(ctx context.Context, m Message) error {
+	if m.Token == "" || !utils.FCMConfigured() {
+		return ErrUnreachable
+	}
+	err := utils.SendPushNotification(m.Recipient, m.Token, m.Title, m.Body, m.Data)
+	if errors.Is(err, utils.ErrNotificationMuted) {
+		return ErrUnreachable
+	}
+	return err
+}
+
+// smsSender sends a text through Twilio. It doesn't check preferences: a route only lists SMS for
+// events that matter enough to reach the recipient somehow, or for a recipient who asked for it.
+type smsSender struct{}
+
+func (smsSender) Channel() string { return utils.ChannelSMS }
+
+func (smsSender) Send(ctx context.Context, m Message) error {
+	if m.Phone == "" || !utils.SMSConfigured() {
+		return ErrUnreachable
+	}
+	return utils.SendSMS(m.Phone, m.Title+": "+m.Body)
+}
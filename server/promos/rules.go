@@ -0,0 +1,135 @@
+// Package promos decides whether a promo code's targeting rules let a rider use it on a booking.
+// It only judges facts it's given; looking up the rider's history and the booking's zone is the
+// caller's job.
+package promos
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"ridewave/models"
+	"ridewave/utils"
+)
+
+// Rider segments, from the rider's completed rides.
+const (
+	SegmentNew      = "new"      // no completed rides yet
+	SegmentActive   = "active"   // rode in the last 30 days
+	SegmentFrequent = "frequent" // rode at least frequentRides times in the last 30 days
+	SegmentLapsed   = "lapsed"   // has ridden, but not in the last 30 days
+)
+
+// Segments lists every segment a rule may name.
+var Segments = []string{SegmentNew, SegmentActive, SegmentFrequent, SegmentLapsed}
+
+// RecentWindow is how far back a rider counts as active.
+const RecentWindow = 30 * 24 * time.Hour
+
+const frequentRides = 8
+
+// Rider is what the rules need to know about the rider.
+type Rider struct {
+	Rides          int // rides booked that weren't cancelled, whatever their status
+	CompletedRides int
+	RecentRides    int // completed within RecentWindow
+	Uses           int // rides booked with this code that weren't cancelled
+}
+
+// Booking is the ride the code would apply to. Zone is "" outside every service zone, and
+// VehicleType is "" when the caller doesn't know it yet. At should be in the service timezone.
+type Booking struct {
+	VehicleType string
+	Zone        string
+	At          time.Time
+}
+
+// Rejection is a rider-facing reason the rules refuse the code.
+type Rejection string
+
+func (r Rejection) Error() string { return string(r) }
+
+// NeedsRider reports whether checking the rules needs the rider's history, so callers can skip
+// loading it for codes that don't target riders.
+func NeedsRider(rules models.PromoRules) bool {
+	return rules.FirstRideOnly || len(rules.Segments) > 0 || rules.PerUserLimit > 0
+}
+
+// RiderSegments returns the segments the rider is in.
+func RiderSegments(r Rider) []string {
+	switch {
+	case r.CompletedRides == 0:
+		return []string{SegmentNew}
+	case r.RecentRides >= frequentRides:
+		return []string{SegmentActive, SegmentFrequent}
+	case r.RecentRides > 0:
+		return []string{SegmentActive}
+	}
+	return []string{SegmentLapsed}
+}
+
+// Check applies the rules to a rider and booking, returning the first one that refuses the code.
+func Check(rules models.PromoRules, rider Rider, b Booking) error {
+	if rules.FirstRideOnly && rider.Rides > 0 {
+		return Rejection("This promo code is only valid on your first ride")
+	}
+	if rules.PerUserLimit > 0 && rider.Uses >= rules.PerUserLimit {
+		return Rejection("You've already used this promo code the maximum number of times")
+	}
+	if len(rules.Segments) > 0 && !slices.ContainsFunc(RiderSegments(rider), func(s string) bool {
+		return slices.Contains(rules.Segments, s)
+	}) {
+		return Rejection("This promo code isn't available on your account")
+	}
+	if len(rules.VehicleTypes) > 0 && !containsFold(rules.VehicleTypes, b.VehicleType) {
+		return Rejection(fmt.Sprintf("This promo code is only valid on %s rides", strings.Join(rules.VehicleTypes, ", ")))
+	}
+	if len(rules.Zones) > 0 && !containsFold(rules.Zones, b.Zone) {
+		return Rejection(fmt.Sprintf("This promo code is only valid in %s", strings.Join(rules.Zones, ", ")))
+	}
+	if len(rules.Days) > 0 && !slices.Contains(rules.Days, int(b.At.Weekday())) {
+		return Rejection("This promo code isn't valid today")
+	}
+	if rules.TimeFrom != nil && rules.TimeUntil != nil {
+		from, errFrom := utils.ParseClock(*rules.TimeFrom)
+		until, errUntil := utils.ParseClock(*rules.TimeUntil)
+		if errFrom == nil && errUntil == nil && !utils.InClockWindow(b.At.Hour()*60+b.At.Minute(), from, until) {
+			return Rejection(fmt.Sprintf("This promo code is valid between %s and %s", *rules.TimeFrom, *rules.TimeUntil))
+		}
+	}
+	return nil
+}
+
+// Validate checks rules an admin submitted, returning what's wrong with them.
+func Validate(rules models.PromoRules) error {
+	for _, s := range rules.Segments {
+		if !slices.Contains(Segments, s) {
+			return fmt.Errorf("unknown segment %q, expected one of %s", s, strings.Join(Segments, ", "))
+		}
+	}
+	for _, d := range rules.Days {
+		if d < 0 || d > 6 {
+			return fmt.Errorf("invalid day %d, expected 0 (Sunday) to 6 (Saturday)", d)
+		}
+	}
+	if (rules.TimeFrom == nil) != (rules.TimeUntil == nil) {
+		return fmt.Errorf("timeFrom and timeUntil must be set together")
+	}
+	if rules.TimeFrom != nil {
+		if _, err := utils.ParseClock(*rules.TimeFrom); err != nil {
+			return err
+		}
+		if _, err := utils.ParseClock(*rules.TimeUntil); err != nil {
+			return err
+		}
+	}
+	if rules.PerUserLimit < 0 {
+		return fmt.Errorf("perUserLimit can't be negative")
+	}
+	return nil
+}
+
+func containsFold(list []string, s string) bool {
+	return s != "" && slices.ContainsFunc(list, func(v string) bool { return strings.EqualFold(v, s) })
+}
@@ -0,0 +1,153 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"ridewave/db"
+	"ridewave/models"
+)
+
+// DriverSelectCols is built per query because the UPI column is mid-rename (see db.ChangeDriverUpiID)
+func DriverSelectCols() string {
+	return `id, name, country, phone_number, email, vehicle_type, registration_number, registration_date, driving_license, vehicle_color, rate, "notificationToken", ratings, "totalEarning", "totalRides", "totalDistance", "pendingRides", "cancelRides", status, "isOnline", "createdAt", "updatedAt", COALESCE("rcBook", ''), COALESCE("profileImage", ''), ` + db.CompatColumn(db.ChangeDriverUpiID, `"upiId"`, "upi_id")
+}
+
+func ScanDriver(scanner interface{ Scan(dest ...any) error }, d *models.Driver) error {
+	return scanner.Scan(&d.ID, &d.Name, &d.Country, &d.PhoneNumber, &d.Email, &d.VehicleType, &d.RegistrationNumber, &d.RegistrationDate, &d.DrivingLicense, &d.VehicleColor, &d.Rate, &d.NotificationToken, &d.Ratings, &d.TotalEarning, &d.TotalRides, &d.TotalDistance, &d.PendingRides, &d.CancelRides, &d.Status, &d.IsOnline, &d.CreatedAt, &d.UpdatedAt, &d.RCBook, &d.ProfileImage, &d.UpiID)
+}
+
+type pgDriverRepo struct {
+	pool *pgxpool.Pool
+}
+
+func (r *pgDriverRepo) one(ctx context.Context, query string, args ...any) (*models.Driver, error) {
+	var d models.Driver
+	if err := ScanDriver(r.pool.QueryRow(ctx, query, args...), &d); err != nil {
+		return nil, notFound(err)
+	}
+	return &d, nil
+}
+
+func (r *pgDriverRepo) GetByID(ctx context.Context, id string) (*models.Driver, error) {
+	return r.one(ctx, `SELECT `+DriverSelectCols()+` FROM driver WHERE id=$1`, id)
+}
+
+func (r *pgDriverRepo) GetByPhone(ctx context.Context, phone string) (*models.Driver, error) {
+	return r.one(ctx, `SELECT `+DriverSelectCols()+` FROM driver WHERE phone_number=$1`, phone)
+}
+
+func (r *pgDriverRepo) ListByIDs(ctx context.Context, ids []string) ([]models.Driver, error) {
+	rows, err := r.pool.Query(ctx, `SELECT `+DriverSelectCols()+` FROM driver WHERE id=ANY($1)`, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	drivers := []models.Driver{}
+	for rows.Next() {
+		var d models.Driver
+		if err := ScanDriver(rows, &d); err != nil {
+			return nil, err
+		}
+		drivers = append(drivers, d)
+	}
+	return drivers, rows.Err()
+}
+
+func (r *pgDriverRepo) Create(ctx context.Context, reg DriverRegistration) (*models.Driver, error) {
+	// The registration vehicle becomes the driver's active one (see db.ChangeDriverVehicles)
+	return r.one(ctx,
+		`WITH d AS (
+			INSERT INTO driver (id, name, country, phone_number, email, vehicle_type, registration_number, registration_date, driving_license, vehicle_color, rate, ratings, "totalEarning", "totalRides", "totalDistance", "pendingRides", "cancelRides", status, "isOnline", "createdAt", "updatedAt", "rcBook", "profileImage", "upiId")
+			VALUES (gen_random_uuid()::text, $1,$2,$3,$4,$5,$6,NOW(),$7,$8,$9, 0,0,0,0,0,0,'pending',FALSE,NOW(),NOW(), $10, $11, $12)
+			RETURNING *
+		), v AS (
+			INSERT INTO vehicles ("driverId", "vehicleType", "registrationNumber", "registrationDate", color, "rcBook", "isActive")
+			SELECT id, vehicle_type, registration_number, registration_date, vehicle_color, "rcBook", TRUE FROM d
+		)
+		SELECT `+DriverSelectCols()+` FROM d`,
+		reg.Name, reg.Country, reg.PhoneNumber, reg.Email, reg.VehicleType,
+		reg.RegistrationNumber, reg.DrivingLicense, reg.VehicleColor, reg.Rate, reg.RCBook, reg.ProfileImage, reg.UpiID)
+}
+
+func (r *pgDriverRepo) SetStatus(ctx context.Context, id, status string) (*models.Driver, error) {
+	return r.one(ctx,
+		`UPDATE driver SET status=$1, "updatedAt"=NOW() WHERE id=$2 RETURNING `+DriverSelectCols(), status, id)
+}
+
+func (r *pgDriverRepo) SetOnline(ctx context.Context, id string, online bool) (*models.Driver, error) {
+	return r.one(ctx,
+		`UPDATE driver SET "isOnline"=$1, "updatedAt"=NOW() WHERE id=$2 RETURNING `+DriverSelectCols(), online, id)
+}
+
+func (r *pgDriverRepo) SetNotificationToken(ctx context.Context, id, token string) (*models.Driver, error) {
+	return r.one(ctx,
+		`UPDATE driver SET "notificationToken"=$1, "updatedAt"=NOW() WHERE id=$2 RETURNING `+DriverSelectCols(), token, id)
+}
+
+func (r *pgDriverRepo) Logout(ctx context.Context, id string) error {
+	_, err := r.pool.Exec(ctx,
+		`UPDATE driver SET "notificationToken"=NULL, status='inactive', "updatedAt"=NOW() WHERE id=$1`, id)
+	return err
+}
+
+func (r *pgDriverRepo) NotificationToken(ctx context.Context, id string) (string, error) {
+	var token *string
+	if err := r.pool.QueryRow(ctx, `SELECT "notificationToken" FROM driver WHERE id=$1`, id).Scan(&token); err != nil {
+		return "", notFound(err)
+	}
+	if token == nil {
+		return "", nil
+	}
+	return *token, nil
+}
+
+func (r *pgDriverRepo) Location(ctx context.Context, id string) (*DriverLocation, error) {
+	var loc DriverLocation
+	err := r.pool.QueryRow(ctx,
+		`SELECT lat, lng, heading, "updatedAt" FROM driver_location WHERE "driverId"=$1`, id).
+		Scan(&loc.Lat, &loc.Lng, &loc.Heading, &loc.UpdatedAt)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &loc, nil
+}
+
+func (r *pgDriverRepo) DispatchCandidates(ctx context.Context, ids []string, vehicleType, rideID string) ([]DispatchCandidate, error) {
+	rows, err := r.pool.Query(ctx,
+		`SELECT id, "notificationToken", COALESCE(languages, '{}'), (SELECT score FROM driver_metrics WHERE "driverId"=driver.id) FROM driver
+		 WHERE id=ANY($1) AND "isOnline"=TRUE AND status='active' AND "vehicle_type"=$2 AND "notificationToken" IS NOT NULL AND "notificationToken" != ''
+		 AND id NOT IN (SELECT "driverId" FROM ride_declines WHERE "rideId"=$3)
+		 AND "tenantId"=(SELECT "tenantId" FROM rides WHERE id=$3)`,
+		ids, vehicleType, rideID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var candidates []DispatchCandidate
+	for rows.Next() {
+		var c DispatchCandidate
+		if err := rows.Scan(&c.ID, &c.NotificationToken, &c.Languages, &c.Score); err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, c)
+	}
+	return candidates, rows.Err()
+}
+
+func (r *pgDriverRepo) AddRating(ctx context.Context, id string, rating float64) error {
+	_, err := r.pool.Exec(ctx,
+		`UPDATE driver SET ratings = (ratings * "totalRides" + $1) / ("totalRides" + 1), "updatedAt"=NOW() WHERE id=$2`,
+		rating, id)
+	return err
+}
+
+func (r *pgDriverRepo) AddCompletedRide(ctx context.Context, tx pgx.Tx, id string, fare, distanceKm float64) error {
+	_, err := tx.Exec(ctx,
+		`UPDATE driver SET "totalEarning"="totalEarning"+$1, "totalRides"="totalRides"+1, "totalDistance"="totalDistance"+$2, "updatedAt"=NOW() WHERE id=$3`,
+		fare, distanceKm, id)
+	return err
+}
@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"context"
+	"time"
+)
+
+// EarningsTotals sums ride_earnings rows. Net is what the driver keeps: the fare less platform
+// and fleet commission, plus tips and incentives.
+type EarningsTotals struct {
+	Rides           int     `json:"rides"`
+	GrossFare       float64 `json:"grossFare"`
+	Commission      float64 `json:"commission"`
+	FleetCommission float64 `json:"fleetCommission"`
+	Tips            float64 `json:"tips"`
+	Incentives      float64 `json:"incentives"`
+	Net             float64 `json:"net"`
+}
+
+// PeriodEarnings is a driver's earnings over one day or week, by when the rides completed.
+type PeriodEarnings struct {
+	Start time.Time
+	EarningsTotals
+}
+
+const earningsTotalsCols = `COUNT(*), COALESCE(SUM("grossFare"), 0), COALESCE(SUM(commission), 0), COALESCE(SUM("fleetCommission"), 0),
+	COALESCE(SUM(tip), 0), COALESCE(SUM(incentives), 0), COALESCE(SUM(net), 0)`
+
+// dest returns the scan destinations matching earningsTotalsCols.
+func (t *EarningsTotals) dest() []any {
+	return []any{&t.Rides, &t.GrossFare, &t.Commission, &t.FleetCommission, &t.Tips, &t.Incentives, &t.Net}
+}
+
+func (r *pgDriverRepo) Earnings(ctx context.Context, id string) (EarningsTotals, error) {
+	var totals EarningsTotals
+	err := r.pool.QueryRow(ctx,
+		`SELECT `+earningsTotalsCols+` FROM ride_earnings WHERE "driverId"=$1`, id).Scan(totals.dest()...)
+	return totals, err
+}
+
+func (r *pgDriverRepo) EarningsByDay(ctx context.Context, id string, days int) ([]PeriodEarnings, error) {
+	return r.earningsBy(ctx, `DATE("completedAt")`, id, time.Now().AddDate(0, 0, -days))
+}
+
+func (r *pgDriverRepo) EarningsByWeek(ctx context.Context, id string, weeks int) ([]PeriodEarnings, error) {
+	return r.earningsBy(ctx, `DATE_TRUNC('week', "completedAt")`, id, time.Now().AddDate(0, 0, -7*weeks))
+}
+
+// earningsBy groups the driver's earnings since a time by period, newest first.
+func (r *pgDriverRepo) earningsBy(ctx context.Context, period, id string, since time.Time) ([]PeriodEarnings, error) {
+	rows, err := r.pool.Query(ctx,
+		`SELECT `+period+` AS period, `+earningsTotalsCols+`
+		FROM ride_earnings
+		WHERE "driverId"=$1 AND "completedAt" >= $2
+		GROUP BY `+period+` ORDER BY period DESC`, id, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var periods []PeriodEarnings
+	for rows.Next() {
+		var p PeriodEarnings
+		if err := rows.Scan(append([]any{&p.Start}, p.EarningsTotals.dest()...)...); err != nil {
+			return nil, err
+		}
+		periods = append(periods, p)
+	}
+	return periods, rows.Err()
+}
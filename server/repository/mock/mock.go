@@ -0,0 +1,688 @@
+// Package mock holds in-memory implementations of the repository interfaces, for exercising
+// handlers without Postgres. Seed the exported maps directly; all methods are safe for
+// concurrent use.
+package mock
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"ridewave/models"
+	"ridewave/repository"
+	"ridewave/rides/fares"
+	"ridewave/utils"
+)
+
+var idSeq atomic.Int64
+
+func newID(prefix string) string {
+	return fmt.Sprintf("%s-%d", prefix, idSeq.Add(1))
+}
+
+// New returns an empty set of in-memory repositories.
+func New() repository.Repos {
+	rides := NewRides()
+	payments := NewPayments()
+	payments.Rides = rides
+	return repository.Repos{
+		Users:    NewUsers(),
+		Drivers:  NewDrivers(),
+		Rides:    rides,
+		Payments: payments,
+	}
+}
+
+// ══════════════════════════════════════════════════
+// Users
+// ══════════════════════════════════════════════════
+
+type Users struct {
+	mu        sync.Mutex
+	ByID      map[string]*models.User
+	Languages map[string]string // preferred language by user ID
+}
+
+var _ repository.UserRepo = (*Users)(nil)
+
+func NewUsers() *Users {
+	return &Users{ByID: map[string]*models.User{}, Languages: map[string]string{}}
+}
+
+func (r *Users) GetByID(ctx context.Context, id string) (*models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if u, ok := r.ByID[id]; ok {
+		out := *u
+		return &out, nil
+	}
+	return nil, repository.ErrNotFound
+}
+
+func (r *Users) GetByPhone(ctx context.Context, phone string) (*models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, u := range r.ByID {
+		if u.PhoneNumber == phone {
+			out := *u
+			return &out, nil
+		}
+	}
+	return nil, repository.ErrNotFound
+}
+
+func (r *Users) Create(ctx context.Context, name, email, phone string) (*models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	u := &models.User{ID: newID("user"), Name: &name, Email: &email, PhoneNumber: phone, Status: "active", CreatedAt: now, UpdatedAt: now}
+	r.ByID[u.ID] = u
+	out := *u
+	return &out, nil
+}
+
+func (r *Users) update(id string, apply func(u *models.User)) (*models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, ok := r.ByID[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	apply(u)
+	u.UpdatedAt = time.Now()
+	out := *u
+	return &out, nil
+}
+
+func (r *Users) UpdateProfile(ctx context.Context, id, name, email string) (*models.User, error) {
+	return r.update(id, func(u *models.User) {
+		if name != "" {
+			u.Name = &name
+		}
+		if email != "" {
+			u.Email = &email
+		}
+	})
+}
+
+func (r *Users) SetNotificationToken(ctx context.Context, id, token string) (*models.User, error) {
+	return r.update(id, func(u *models.User) {
+		u.NotificationToken = nil
+		if token != "" {
+			u.NotificationToken = &token
+		}
+	})
+}
+
+func (r *Users) PreferredLanguage(ctx context.Context, id string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.ByID[id]; !ok {
+		return "", repository.ErrNotFound
+	}
+	return r.Languages[id], nil
+}
+
+func (r *Users) AddRating(ctx context.Context, id string, rating float64) error {
+	_, err := r.update(id, func(u *models.User) {
+		u.Ratings = (u.Ratings*u.TotalRides + rating) / (u.TotalRides + 1)
+	})
+	return err
+}
+
+func (r *Users) AddCompletedRide(ctx context.Context, tx pgx.Tx, id string) error {
+	_, err := r.update(id, func(u *models.User) { u.TotalRides++ })
+	return err
+}
+
+// ══════════════════════════════════════════════════
+// Drivers
+// ══════════════════════════════════════════════════
+
+type Drivers struct {
+	mu        sync.Mutex
+	ByID      map[string]*models.Driver
+	Locations map[string]repository.DriverLocation   // by driver ID
+	Earned    map[string][]repository.PeriodEarnings // one entry per day, by driver ID
+}
+
+var _ repository.DriverRepo = (*Drivers)(nil)
+
+func NewDrivers() *Drivers {
+	return &Drivers{
+		ByID:      map[string]*models.Driver{},
+		Locations: map[string]repository.DriverLocation{},
+		Earned:    map[string][]repository.PeriodEarnings{},
+	}
+}
+
+func (r *Drivers) GetByID(ctx context.Context, id string) (*models.Driver, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if d, ok := r.ByID[id]; ok {
+		out := *d
+		return &out, nil
+	}
+	return nil, repository.ErrNotFound
+}
+
+func (r *Drivers) GetByPhone(ctx context.Context, phone string) (*models.Driver, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, d := range r.ByID {
+		if d.PhoneNumber == phone {
+			out := *d
+			return &out, nil
+		}
+	}
+	return nil, repository.ErrNotFound
+}
+
+func (r *Drivers) ListByIDs(ctx context.Context, ids []string) ([]models.Driver, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	drivers := []models.Driver{}
+	for _, id := range ids {
+		if d, ok := r.ByID[id]; ok {
+			drivers = append(drivers, *d)
+		}
+	}
+	return drivers, nil
+}
+
+func (r *Drivers) Create(ctx context.Context, reg repository.DriverRegistration) (*models.Driver, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	d := &models.Driver{
+		ID: newID("driver"), Name: reg.Name, Country: reg.Country, PhoneNumber: reg.PhoneNumber, Email: reg.Email,
+		VehicleType: reg.VehicleType, RegistrationNumber: reg.RegistrationNumber, RegistrationDate: now.Format(time.RFC3339),
+		DrivingLicense: reg.DrivingLicense, VehicleColor: &reg.VehicleColor, Rate: reg.Rate, RCBook: reg.RCBook,
+		ProfileImage: reg.ProfileImage, UpiID: &reg.UpiID, Status: "pending", CreatedAt: now, UpdatedAt: now,
+	}
+	r.ByID[d.ID] = d
+	out := *d
+	return &out, nil
+}
+
+func (r *Drivers) update(id string, apply func(d *models.Driver)) (*models.Driver, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	d, ok := r.ByID[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	apply(d)
+	d.UpdatedAt = time.Now()
+	out := *d
+	return &out, nil
+}
+
+func (r *Drivers) SetStatus(ctx context.Context, id, status string) (*models.Driver, error) {
+	return r.update(id, func(d *models.Driver) { d.Status = status })
+}
+
+func (r *Drivers) SetOnline(ctx context.Context, id string, online bool) (*models.Driver, error) {
+	return r.update(id, func(d *models.Driver) { d.IsOnline = online })
+}
+
+func (r *Drivers) SetNotificationToken(ctx context.Context, id, token string) (*models.Driver, error) {
+	return r.update(id, func(d *models.Driver) { d.NotificationToken = &token })
+}
+
+func (r *Drivers) Logout(ctx context.Context, id string) error {
+	_, err := r.update(id, func(d *models.Driver) {
+		d.NotificationToken = nil
+		d.Status = "inactive"
+	})
+	if err == repository.ErrNotFound {
+		return nil
+	}
+	return err
+}
+
+func (r *Drivers) NotificationToken(ctx context.Context, id string) (string, error) {
+	d, err := r.GetByID(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	if d.NotificationToken == nil {
+		return "", nil
+	}
+	return *d.NotificationToken, nil
+}
+
+func (r *Drivers) Location(ctx context.Context, id string) (*repository.DriverLocation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if loc, ok := r.Locations[id]; ok {
+		return &loc, nil
+	}
+	return nil, repository.ErrNotFound
+}
+
+// DispatchCandidates doesn't know about declines, tenants or metrics scores.
+func (r *Drivers) DispatchCandidates(ctx context.Context, ids []string, vehicleType, rideID string) ([]repository.DispatchCandidate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var candidates []repository.DispatchCandidate
+	for _, id := range ids {
+		d, ok := r.ByID[id]
+		if !ok || !d.IsOnline || d.Status != "active" || d.VehicleType != vehicleType || d.NotificationToken == nil || *d.NotificationToken == "" {
+			continue
+		}
+		candidates = append(candidates, repository.DispatchCandidate{ID: d.ID, NotificationToken: *d.NotificationToken})
+	}
+	return candidates, nil
+}
+
+func (r *Drivers) AddRating(ctx context.Context, id string, rating float64) error {
+	_, err := r.update(id, func(d *models.Driver) {
+		d.Ratings = (d.Ratings*d.TotalRides + rating) / (d.TotalRides + 1)
+	})
+	return err
+}
+
+func (r *Drivers) AddCompletedRide(ctx context.Context, tx pgx.Tx, id string, fare, distanceKm float64) error {
+	_, err := r.update(id, func(d *models.Driver) {
+		d.TotalEarning += fare
+		d.TotalRides++
+		d.TotalDistance += distanceKm
+	})
+	return err
+}
+
+func addEarnings(sum *repository.EarningsTotals, t repository.EarningsTotals) {
+	sum.Rides += t.Rides
+	sum.GrossFare += t.GrossFare
+	sum.Commission += t.Commission
+	sum.FleetCommission += t.FleetCommission
+	sum.Tips += t.Tips
+	sum.Incentives += t.Incentives
+	sum.Net += t.Net
+}
+
+func (r *Drivers) Earnings(ctx context.Context, id string) (repository.EarningsTotals, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var sum repository.EarningsTotals
+	for _, day := range r.Earned[id] {
+		addEarnings(&sum, day.EarningsTotals)
+	}
+	return sum, nil
+}
+
+// earningsBy sums the driver's days since a time into periods starting where start puts them.
+func (r *Drivers) earningsBy(id string, since time.Time, start func(day time.Time) time.Time) []repository.PeriodEarnings {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	byStart := map[time.Time]*repository.PeriodEarnings{}
+	var periods []*repository.PeriodEarnings
+	for _, day := range r.Earned[id] {
+		if day.Start.Before(since) {
+			continue
+		}
+		s := start(day.Start)
+		p, ok := byStart[s]
+		if !ok {
+			p = &repository.PeriodEarnings{Start: s}
+			byStart[s] = p
+			periods = append(periods, p)
+		}
+		addEarnings(&p.EarningsTotals, day.EarningsTotals)
+	}
+	sort.Slice(periods, func(i, j int) bool { return periods[i].Start.After(periods[j].Start) })
+	out := make([]repository.PeriodEarnings, 0, len(periods))
+	for _, p := range periods {
+		out = append(out, *p)
+	}
+	return out
+}
+
+func (r *Drivers) EarningsByDay(ctx context.Context, id string, days int) ([]repository.PeriodEarnings, error) {
+	return r.earningsBy(id, time.Now().AddDate(0, 0, -days), func(day time.Time) time.Time { return day }), nil
+}
+
+func (r *Drivers) EarningsByWeek(ctx context.Context, id string, weeks int) ([]repository.PeriodEarnings, error) {
+	// Weeks start on Monday, as Postgres's DATE_TRUNC('week') has them
+	monday := func(day time.Time) time.Time { return day.AddDate(0, 0, -(int(day.Weekday())+6)%7) }
+	return r.earningsBy(id, time.Now().AddDate(0, 0, -7*weeks), monday), nil
+}
+
+// ══════════════════════════════════════════════════
+// Rides
+// ══════════════════════════════════════════════════
+
+type Rides struct {
+	mu   sync.Mutex
+	ByID map[string]*models.Ride
+}
+
+var _ repository.RideRepo = (*Rides)(nil)
+
+func NewRides() *Rides {
+	return &Rides{ByID: map[string]*models.Ride{}}
+}
+
+func (r *Rides) get(rideID string) (*models.Ride, error) {
+	if ride, ok := r.ByID[rideID]; ok {
+		return ride, nil
+	}
+	return nil, repository.ErrNotFound
+}
+
+func (r *Rides) Status(ctx context.Context, rideID string) (string, *string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ride, err := r.get(rideID)
+	if err != nil {
+		return "", nil, err
+	}
+	return ride.Status, ride.DriverID, nil
+}
+
+func (r *Rides) BelongsToUser(ctx context.Context, rideID, userID string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ride, err := r.get(rideID)
+	return err == nil && ride.UserID == userID, nil
+}
+
+func (r *Rides) Charge(ctx context.Context, rideID string) (float64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ride, err := r.get(rideID)
+	if err != nil {
+		return 0, err
+	}
+	return ride.Charge, nil
+}
+
+func (r *Rides) MarkPaid(ctx context.Context, rideID, mode string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if ride, err := r.get(rideID); err == nil {
+		ride.PaymentStatus, ride.PaymentMode, ride.UpdatedAt = "Paid", mode, time.Now()
+	}
+	return nil
+}
+
+func (r *Rides) MarkPaymentFailed(ctx context.Context, rideID string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ride, err := r.get(rideID)
+	if err != nil || ride.PaymentStatus == "Paid" {
+		return false, nil
+	}
+	ride.PaymentStatus, ride.UpdatedAt = "Failed", time.Now()
+	return true, nil
+}
+
+func (r *Rides) Create(ctx context.Context, ride repository.NewRide) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	originLat, originLng, destLat, destLng := ride.OriginLat, ride.OriginLng, ride.DestinationLat, ride.DestinationLng
+	created := &models.Ride{
+		ID: newID("ride"), UserID: ride.UserID, Charge: ride.Fare, Currency: ride.Currency,
+		CurrentLocationName: ride.OriginName, DestinationLocationName: ride.DestinationName,
+		Distance: fmt.Sprintf("%d", ride.Distance), RouteID: ride.RouteID, EstimatedDuration: ride.Duration,
+		EstimatedDistance: ride.Distance, VehicleType: ride.VehicleType,
+		OriginLat: &originLat, OriginLng: &originLng, DestinationLat: &destLat, DestinationLng: &destLng,
+		PaymentMode: ride.PaymentMode, Status: "Requested", CreatedAt: now, UpdatedAt: now,
+	}
+	r.ByID[created.ID] = created
+	return created.ID, nil
+}
+
+func (r *Rides) CreateTx(ctx context.Context, tx pgx.Tx, ride repository.NewRide) (string, error) {
+	return r.Create(ctx, ride)
+}
+
+// find returns a copy of the ride if it exists and matches.
+func (r *Rides) find(rideID string, match func(ride *models.Ride) bool) (*models.Ride, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ride, err := r.get(rideID)
+	if err != nil || !match(ride) {
+		return nil, repository.ErrNotFound
+	}
+	out := *ride
+	return &out, nil
+}
+
+func (r *Rides) GetForUser(ctx context.Context, rideID, userID string) (*models.Ride, error) {
+	return r.find(rideID, func(ride *models.Ride) bool { return ride.UserID == userID })
+}
+
+func (r *Rides) GetForDriver(ctx context.Context, rideID, driverID string) (*models.Ride, error) {
+	return r.find(rideID, func(ride *models.Ride) bool { return ride.DriverID != nil && *ride.DriverID == driverID })
+}
+
+func (r *Rides) Details(ctx context.Context, rideID string) (*models.Ride, error) {
+	return r.find(rideID, anyRide)
+}
+
+func (r *Rides) IncomingForDriver(ctx context.Context, driverID string) (*models.Ride, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var latest *models.Ride
+	for _, ride := range r.ByID {
+		if ride.Status == "Requested" && ride.DriverID != nil && *ride.DriverID == driverID &&
+			(latest == nil || ride.CreatedAt.After(latest.CreatedAt)) {
+			latest = ride
+		}
+	}
+	if latest == nil {
+		return nil, repository.ErrNotFound
+	}
+	out := *latest
+	return &out, nil
+}
+
+func (r *Rides) StartOTP(ctx context.Context, rideID, driverID string) (string, *string, error) {
+	ride, err := r.GetForDriver(ctx, rideID, driverID)
+	if err != nil {
+		return "", nil, err
+	}
+	if ride.OTP == "" {
+		return ride.Status, nil, nil
+	}
+	return ride.Status, &ride.OTP, nil
+}
+
+func (r *Rides) VehicleType(ctx context.Context, rideID string) (string, error) {
+	ride, err := r.Details(ctx, rideID)
+	if err != nil {
+		return "", err
+	}
+	return ride.VehicleType, nil
+}
+
+// history filters, sums and pages rides newest first. Cursor pages always start from the newest
+// ride, since the cursor's position isn't exported.
+func (r *Rides) history(owned func(ride *models.Ride) bool, f repository.RideHistoryFilter, pg utils.Pagination,
+	amount func(ride *models.Ride) float64) ([]models.Ride, repository.RideHistorySummary) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var sum repository.RideHistorySummary
+	rides := []models.Ride{}
+	for _, ride := range r.ByID {
+		if !owned(ride) || (len(f.Statuses) > 0 && !slices.Contains(f.Statuses, ride.Status)) ||
+			(f.From != nil && ride.CreatedAt.Before(*f.From)) || (f.To != nil && !ride.CreatedAt.Before(*f.To)) {
+			continue
+		}
+		sum.Rides++
+		if ride.Status == "Completed" {
+			sum.Completed++
+			sum.Amount += amount(ride)
+		}
+		rides = append(rides, *ride)
+	}
+	sort.Slice(rides, func(i, j int) bool {
+		if !rides[i].CreatedAt.Equal(rides[j].CreatedAt) {
+			return rides[i].CreatedAt.After(rides[j].CreatedAt)
+		}
+		return rides[i].ID > rides[j].ID
+	})
+	limit := pg.Limit
+	if pg.UseCursor {
+		limit++ // the lookahead row utils.Paginate trims
+	}
+	start := min(pg.Offset, len(rides))
+	return rides[start:min(start+limit, len(rides))], sum
+}
+
+func (r *Rides) UserHistory(ctx context.Context, userID string, f repository.RideHistoryFilter, pg utils.Pagination) ([]models.Ride, repository.RideHistorySummary, error) {
+	rides, sum := r.history(func(ride *models.Ride) bool { return ride.UserID == userID }, f, pg,
+		func(ride *models.Ride) float64 { return ride.Charge + ride.Tips })
+	return rides, sum, nil
+}
+
+// DriverHistory counts the fare and tips as earned, with no commission taken.
+func (r *Rides) DriverHistory(ctx context.Context, driverID string, f repository.RideHistoryFilter, pg utils.Pagination) ([]models.Ride, repository.RideHistorySummary, error) {
+	rides, sum := r.history(func(ride *models.Ride) bool { return ride.DriverID != nil && *ride.DriverID == driverID }, f, pg,
+		func(ride *models.Ride) float64 { return ride.Charge + ride.Tips })
+	return rides, sum, nil
+}
+
+func (r *Rides) update(rideID string, match func(ride *models.Ride) bool, apply func(ride *models.Ride)) (*models.Ride, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ride, err := r.get(rideID)
+	if err != nil || !match(ride) {
+		return nil, repository.ErrNotFound
+	}
+	apply(ride)
+	ride.UpdatedAt = time.Now()
+	out := *ride
+	return &out, nil
+}
+
+func anyRide(*models.Ride) bool { return true }
+
+func (r *Rides) RateDriver(ctx context.Context, rideID string, rating float64, tags []string, comment string) error {
+	_, err := r.update(rideID, anyRide, func(ride *models.Ride) { ride.Rating = &rating })
+	return err
+}
+
+// RateUser only checks the ride is the rider's; models.Ride doesn't carry the driver's rating.
+func (r *Rides) RateUser(ctx context.Context, rideID, userID string, rating float64, tags []string, comment string) error {
+	_, err := r.update(rideID, func(ride *models.Ride) bool { return ride.UserID == userID }, func(*models.Ride) {})
+	return err
+}
+
+func (r *Rides) SetDriverStatus(ctx context.Context, tx pgx.Tx, rideID, driverID, status, otp string) (*models.Ride, error) {
+	return r.update(rideID, func(ride *models.Ride) bool { return ride.DriverID != nil && *ride.DriverID == driverID },
+		func(ride *models.Ride) {
+			now := time.Now()
+			ride.Status = status
+			if otp != "" {
+				ride.OTP = otp
+			}
+			switch status {
+			case "Accepted":
+				ride.AcceptedAt = &now
+			case "Completed":
+				ride.CompletedAt = &now
+			case "Cancelled":
+				ride.CancelledAt = &now
+			}
+		})
+}
+
+func (r *Rides) Start(ctx context.Context, tx pgx.Tx, rideID, driverID string) (*models.Ride, error) {
+	return r.update(rideID, func(ride *models.Ride) bool { return ride.DriverID != nil && *ride.DriverID == driverID },
+		func(ride *models.Ride) {
+			now := time.Now()
+			ride.Status, ride.StartedAt = "InProgress", &now
+		})
+}
+
+func (r *Rides) PickupTimes(ctx context.Context, tx pgx.Tx, rideID string) (*time.Time, *time.Time, time.Time, error) {
+	ride, err := r.Details(ctx, rideID)
+	if err != nil {
+		return nil, nil, time.Time{}, err
+	}
+	return ride.AcceptedAt, ride.ArrivedAt, time.Now(), nil
+}
+
+func (r *Rides) CancelByUser(ctx context.Context, tx pgx.Tx, rideID, userID, reason string, fee fares.Cancellation) (*string, error) {
+	ride, err := r.update(rideID, func(ride *models.Ride) bool { return ride.UserID == userID }, func(ride *models.Ride) {
+		now := time.Now()
+		ride.Status, ride.CancelReason, ride.CancelledAt = "Cancelled", reason, &now
+		ride.CancellationFee, ride.WaitingMinutes, ride.WaitingCharge = fee.Fee, fee.Waiting.Minutes, fee.Waiting.Charge
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ride.DriverID, nil
+}
+
+// ══════════════════════════════════════════════════
+// Payments
+// ══════════════════════════════════════════════════
+
+type Payments struct {
+	mu     sync.Mutex
+	ByRide map[string][]models.Payment
+	Rides  *Rides // when set, Record marks the ride paid here
+}
+
+var _ repository.PaymentRepo = (*Payments)(nil)
+
+func NewPayments() *Payments {
+	return &Payments{ByRide: map[string][]models.Payment{}}
+}
+
+func (r *Payments) Record(ctx context.Context, rideID string, amount float64, mode string) (bool, error) {
+	recorded := r.record(rideID, amount, mode)
+	if recorded && r.Rides != nil {
+		r.Rides.MarkPaid(ctx, rideID, mode)
+	}
+	return recorded, nil
+}
+
+func (r *Payments) record(rideID string, amount float64, mode string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	payments := r.ByRide[rideID]
+	for _, p := range payments {
+		if p.Status == "paid" {
+			return false
+		}
+	}
+	for i, p := range payments {
+		if strings.EqualFold(p.Mode, mode) {
+			payments[i].Amount, payments[i].Status = amount, "paid"
+			return true
+		}
+	}
+	r.ByRide[rideID] = append(payments, models.Payment{
+		ID: newID("payment"), RideID: rideID, Amount: amount, Mode: mode, Status: "paid", CreatedAt: time.Now(),
+	})
+	return true
+}
+
+func (r *Payments) ForRide(ctx context.Context, rideID string) (*models.Payment, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	payments := r.ByRide[rideID]
+	if len(payments) == 0 {
+		return nil, repository.ErrNotFound
+	}
+	best := payments[0]
+	for _, p := range payments {
+		if p.Status == "paid" {
+			best = p
+			break
+		}
+	}
+	return &best, nil
+}
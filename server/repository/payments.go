@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"ridewave/db"
+	"ridewave/models"
+)
+
+type pgPaymentRepo struct {
+	pool *pgxpool.Pool
+}
+
+// Record writes nothing if the ride already has a paid entry, unless that entry has an amount
+// due (a dispute raised the fare), which the payment then settles; a pending entry for the same
+// mode is upgraded in place. The ride is marked paid in the same transaction, so a recorded
+// payment can't leave its ride showing unpaid.
+func (r *pgPaymentRepo) Record(ctx context.Context, rideID string, amount float64, mode string) (bool, error) {
+	var recorded bool
+	err := db.RunTx(ctx, r.pool, pgx.TxOptions{}, func(tx pgx.Tx) error {
+		var alreadyPaid bool
+		err := tx.QueryRow(ctx,
+			`SELECT EXISTS(SELECT 1 FROM payments WHERE "rideId"=$1 AND status='paid')`, rideID).Scan(&alreadyPaid)
+		if err != nil {
+			return err
+		}
+		if alreadyPaid {
+			tag, err := tx.Exec(ctx,
+				`UPDATE payments SET amount=amount+LEAST($2, "amountDue"), "amountDue"=GREATEST("amountDue"-$2, 0)
+				 WHERE "rideId"=$1 AND status='paid' AND "amountDue" > 0`, rideID, amount)
+			if err != nil {
+				return err
+			}
+			recorded = tag.RowsAffected() > 0
+			// Otherwise only repair a ride whose payment was recorded without it
+			_, err = tx.Exec(ctx,
+				`UPDATE rides SET "paymentStatus"='Paid', "updatedAt"=NOW() WHERE id=$1 AND "paymentStatus" IS DISTINCT FROM 'Paid'
+				 AND NOT EXISTS (SELECT 1 FROM payments WHERE "rideId"=$1 AND "amountDue" > 0)`, rideID)
+			return err
+		}
+
+		tag, err := tx.Exec(ctx,
+			`INSERT INTO payments (id, "rideId", amount, mode, status, "createdAt", currency)
+			VALUES (gen_random_uuid()::text, $1, $2, $3, 'paid', NOW(), (SELECT currency FROM rides WHERE id=$1))
+			ON CONFLICT ("rideId", (LOWER(mode))) DO UPDATE SET amount=EXCLUDED.amount, status='paid'
+			WHERE payments.status<>'paid'`,
+			rideID, amount, mode)
+		if err != nil {
+			return err
+		}
+		recorded = tag.RowsAffected() > 0
+		_, err = tx.Exec(ctx,
+			`UPDATE rides SET "paymentStatus"='Paid', "paymentMode"=$1, "updatedAt"=NOW() WHERE id=$2`, mode, rideID)
+		return err
+	})
+	if err != nil {
+		// A concurrent confirmation in another mode won the race (idx_payments_ride_paid)
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return false, nil
+		}
+		return false, err
+	}
+	return recorded, nil
+}
+
+func (r *pgPaymentRepo) ForRide(ctx context.Context, rideID string) (*models.Payment, error) {
+	var p models.Payment
+	err := r.pool.QueryRow(ctx,
+		`SELECT id, "rideId", amount, "amountDue", currency, mode, status, "createdAt" FROM payments WHERE "rideId"=$1
+		 ORDER BY (status='paid') DESC LIMIT 1`, rideID).
+		Scan(&p.ID, &p.RideID, &p.Amount, &p.AmountDue, &p.Currency, &p.Mode, &p.Status, &p.CreatedAt)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &p, nil
+}
@@ -0,0 +1,210 @@
+// Package repository is the data-access layer for users, drivers, rides and payments. Handlers
+// depend on the interfaces here rather than on db.Pool, so the Postgres implementations can be
+// swapped for the in-memory ones in repository/mock.
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"ridewave/models"
+	"ridewave/rides/fares"
+	"ridewave/utils"
+)
+
+// ErrNotFound is returned when the row being read or updated does not exist.
+var ErrNotFound = errors.New("not found")
+
+// UserRepo reads and updates rider accounts.
+type UserRepo interface {
+	GetByID(ctx context.Context, id string) (*models.User, error)
+	GetByPhone(ctx context.Context, phone string) (*models.User, error)
+	Create(ctx context.Context, name, email, phone string) (*models.User, error)
+	// UpdateProfile changes name and email; an empty value keeps the current one.
+	UpdateProfile(ctx context.Context, id, name, email string) (*models.User, error)
+	// SetNotificationToken stores the push token; an empty token clears it (logout).
+	SetNotificationToken(ctx context.Context, id, token string) (*models.User, error)
+	PreferredLanguage(ctx context.Context, id string) (string, error)
+	// AddRating folds one more rating from a driver into the rider's average.
+	AddRating(ctx context.Context, id string, rating float64) error
+	// AddCompletedRide counts a completed ride, on the transaction that completes it.
+	AddCompletedRide(ctx context.Context, tx pgx.Tx, id string) error
+}
+
+// DriverRegistration is what a new driver submits to sign up.
+type DriverRegistration struct {
+	Name               string
+	Country            string
+	PhoneNumber        string
+	Email              string
+	VehicleType        string
+	RegistrationNumber string
+	DrivingLicense     string
+	VehicleColor       string
+	Rate               string
+	RCBook             string
+	ProfileImage       string
+	UpiID              string
+}
+
+// DriverRepo reads and updates driver accounts.
+type DriverRepo interface {
+	GetByID(ctx context.Context, id string) (*models.Driver, error)
+	GetByPhone(ctx context.Context, phone string) (*models.Driver, error)
+	ListByIDs(ctx context.Context, ids []string) ([]models.Driver, error)
+	// Create registers a driver as pending admin verification.
+	Create(ctx context.Context, reg DriverRegistration) (*models.Driver, error)
+	SetStatus(ctx context.Context, id, status string) (*models.Driver, error)
+	SetOnline(ctx context.Context, id string, online bool) (*models.Driver, error)
+	SetNotificationToken(ctx context.Context, id, token string) (*models.Driver, error)
+	// Logout clears the push token and marks the driver inactive.
+	Logout(ctx context.Context, id string) error
+	NotificationToken(ctx context.Context, id string) (string, error)
+	// Location is the driver's last position saved to Postgres.
+	Location(ctx context.Context, id string) (*DriverLocation, error)
+	// DispatchCandidates narrows nearby drivers to those a ride can be offered to: online, active,
+	// of its vehicle type and tenant, reachable by push and not having declined it.
+	DispatchCandidates(ctx context.Context, ids []string, vehicleType, rideID string) ([]DispatchCandidate, error)
+	// AddRating folds one more rating from a rider into the driver's average.
+	AddRating(ctx context.Context, id string, rating float64) error
+	// AddCompletedRide adds a completed ride to the driver's lifetime totals, on the transaction
+	// that completes it.
+	AddCompletedRide(ctx context.Context, tx pgx.Tx, id string, fare, distanceKm float64) error
+	// Earnings totals the driver's ride earnings. EarningsByDay and EarningsByWeek split the last
+	// days or weeks of them by period, newest first, leaving out periods without rides.
+	Earnings(ctx context.Context, id string) (EarningsTotals, error)
+	EarningsByDay(ctx context.Context, id string, days int) ([]PeriodEarnings, error)
+	EarningsByWeek(ctx context.Context, id string, weeks int) ([]PeriodEarnings, error)
+}
+
+// DriverLocation is a driver's last saved position.
+type DriverLocation struct {
+	Lat       float64
+	Lng       float64
+	Heading   *float64
+	UpdatedAt time.Time
+}
+
+// DispatchCandidate is a driver a new ride can be pushed to.
+type DispatchCandidate struct {
+	ID                string
+	NotificationToken string
+	Languages         []string
+	Score             *float64 // driver_metrics score; nil before the first nightly run
+}
+
+// RideRepo reads and updates rides. Status changes are made through rides/statemachine, which
+// passes its transaction to the methods that take a tx.
+type RideRepo interface {
+	// Status returns the ride's status and assigned driver, if any.
+	Status(ctx context.Context, rideID string) (string, *string, error)
+	BelongsToUser(ctx context.Context, rideID, userID string) (bool, error)
+	Charge(ctx context.Context, rideID string) (float64, error)
+	MarkPaid(ctx context.Context, rideID, mode string) error
+	// MarkPaymentFailed flags an unpaid ride's payment as failed; false if it was already paid.
+	MarkPaymentFailed(ctx context.Context, rideID string) (bool, error)
+
+	// Create inserts a ride request and returns its ID; CreateTx does so on the caller's transaction.
+	Create(ctx context.Context, ride NewRide) (string, error)
+	CreateTx(ctx context.Context, tx pgx.Tx, ride NewRide) (string, error)
+	// GetForUser returns the rider's ride with its places, coordinates and assigned driver.
+	GetForUser(ctx context.Context, rideID, userID string) (*models.Ride, error)
+	// GetForDriver returns a ride assigned to the driver, with the rider.
+	GetForDriver(ctx context.Context, rideID, driverID string) (*models.Ride, error)
+	// Details returns the ride with the rider and, once assigned, the driver.
+	Details(ctx context.Context, rideID string) (*models.Ride, error)
+	// IncomingForDriver returns the latest ride offered to the driver and not yet accepted.
+	IncomingForDriver(ctx context.Context, driverID string) (*models.Ride, error)
+	// StartOTP returns the status of a ride assigned to the driver and the OTP that starts it.
+	StartOTP(ctx context.Context, rideID, driverID string) (string, *string, error)
+	VehicleType(ctx context.Context, rideID string) (string, error)
+	// UserHistory pages through a rider's rides, newest first, each with its driver once assigned;
+	// the summary covers every ride the filter matches. Amount is what the rider spent.
+	UserHistory(ctx context.Context, userID string, f RideHistoryFilter, pg utils.Pagination) ([]models.Ride, RideHistorySummary, error)
+	// DriverHistory pages through a driver's rides with their riders. Amount is what the driver earned.
+	DriverHistory(ctx context.Context, driverID string, f RideHistoryFilter, pg utils.Pagination) ([]models.Ride, RideHistorySummary, error)
+	// RateDriver saves the rider's rating of the ride; RateUser the driver's rating of the rider.
+	RateDriver(ctx context.Context, rideID string, rating float64, tags []string, comment string) error
+	RateUser(ctx context.Context, rideID, userID string, rating float64, tags []string, comment string) error
+
+	// SetDriverStatus moves a ride assigned to the driver to status (Accepted, Completed or
+	// Cancelled), stamping its lifecycle time and any new trip OTP. The ride comes back with its rider.
+	SetDriverStatus(ctx context.Context, tx pgx.Tx, rideID, driverID, status, otp string) (*models.Ride, error)
+	// Start puts a ride assigned to the driver in progress.
+	Start(ctx context.Context, tx pgx.Tx, rideID, driverID string) (*models.Ride, error)
+	// PickupTimes returns when the ride was accepted and the driver arrived, and the transaction's time.
+	PickupTimes(ctx context.Context, tx pgx.Tx, rideID string) (*time.Time, *time.Time, time.Time, error)
+	// CancelByUser cancels the rider's ride, charging fee, and returns the driver it was assigned to.
+	CancelByUser(ctx context.Context, tx pgx.Tx, rideID, userID, reason string, fee fares.Cancellation) (*string, error)
+}
+
+// NewRide is a ride request priced from a planned route.
+type NewRide struct {
+	UserID          string
+	RouteID         string
+	VehicleType     string
+	PaymentMode     string
+	Currency        string
+	Fare            float64
+	Distance        int // meters
+	Duration        int // seconds
+	OriginName      string
+	DestinationName string
+	OriginLat       float64
+	OriginLng       float64
+	DestinationLat  float64
+	DestinationLng  float64
+	Steps           []utils.RouteStep // turn-by-turn, kept for driver navigation
+}
+
+// RideHistoryFilter narrows a ride history; every set field must match.
+type RideHistoryFilter struct {
+	Statuses []string
+	From     *time.Time // created at or after
+	To       *time.Time // created before
+}
+
+// RideHistorySummary totals every ride a history filter matches.
+type RideHistorySummary struct {
+	Rides     int
+	Completed int
+	Amount    float64
+}
+
+// PaymentRepo stores ride fares.
+type PaymentRepo interface {
+	// Record stores the fare for a ride exactly once and marks the ride paid, together; false if
+	// the fare had already been recorded.
+	Record(ctx context.Context, rideID string, amount float64, mode string) (bool, error)
+	// ForRide returns the ride's payment, preferring a paid entry over a pending one.
+	ForRide(ctx context.Context, rideID string) (*models.Payment, error)
+}
+
+// Repos is the set of repositories the handlers use.
+type Repos struct {
+	Users    UserRepo
+	Drivers  DriverRepo
+	Rides    RideRepo
+	Payments PaymentRepo
+}
+
+// NewPostgres returns the repositories backed by pool.
+func NewPostgres(pool *pgxpool.Pool) Repos {
+	return Repos{
+		Users:    &pgUserRepo{pool: pool},
+		Drivers:  &pgDriverRepo{pool: pool},
+		Rides:    &pgRideRepo{pool: pool},
+		Payments: &pgPaymentRepo{pool: pool},
+	}
+}
+
+// notFound maps pgx's "no rows" onto ErrNotFound.
+func notFound(err error) error {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNotFound
+	}
+	return err
+}
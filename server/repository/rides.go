@@ -0,0 +1,390 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"ridewave/db"
+	"ridewave/models"
+	"ridewave/rides/fares"
+	"ridewave/utils"
+)
+
+type pgRideRepo struct {
+	pool *pgxpool.Pool
+}
+
+func (r *pgRideRepo) Status(ctx context.Context, rideID string) (string, *string, error) {
+	var status string
+	var driverID *string
+	err := r.pool.QueryRow(ctx,
+		`SELECT status, "driverId" FROM rides WHERE id=$1`, rideID).Scan(&status, &driverID)
+	return status, driverID, notFound(err)
+}
+
+func (r *pgRideRepo) BelongsToUser(ctx context.Context, rideID, userID string) (bool, error) {
+	var exists bool
+	err := r.pool.QueryRow(ctx,
+		`SELECT EXISTS(SELECT 1 FROM rides WHERE id=$1 AND "userId"=$2)`, rideID, userID).Scan(&exists)
+	return exists, err
+}
+
+func (r *pgRideRepo) Charge(ctx context.Context, rideID string) (float64, error) {
+	var charge float64
+	err := r.pool.QueryRow(ctx, `SELECT charge FROM rides WHERE id=$1`, rideID).Scan(&charge)
+	return charge, notFound(err)
+}
+
+func (r *pgRideRepo) MarkPaid(ctx context.Context, rideID, mode string) error {
+	_, err := r.pool.Exec(ctx,
+		`UPDATE rides SET "paymentStatus"='Paid', "paymentMode"=$1, "updatedAt"=NOW() WHERE id=$2`,
+		mode, rideID)
+	return err
+}
+
+func (r *pgRideRepo) MarkPaymentFailed(ctx context.Context, rideID string) (bool, error) {
+	tag, err := r.pool.Exec(ctx,
+		`UPDATE rides SET "paymentStatus"='Failed', "updatedAt"=NOW()
+		 WHERE id=$1 AND COALESCE("paymentStatus", 'Pending') <> 'Paid'`, rideID)
+	return tag.RowsAffected() > 0, err
+}
+
+const insertRideSQL = `INSERT INTO rides (
+			id, "userId", "driverId", charge, "currentLocationName", "destinationLocationName",
+			distance, polyline, "routeId", "estimatedDuration", "estimatedDistance", "vehicleType",
+			"originLat", "originLng", "destinationLat", "destinationLng", "paymentMode",
+			status, "createdAt", "updatedAt", "tenantId", currency, "routeSteps"
+		) VALUES (
+			gen_random_uuid()::text, $1, NULL, $2, $3, $4,
+			$5, NULL, $6, $7, $8, $9,
+			$10, $11, $12, $13, NULLIF($14, ''),
+			'Requested', NOW(), NOW(), (SELECT "tenantId" FROM "user" WHERE id=$1), $15, $16
+		) RETURNING id`
+
+func insertRideArgs(ride NewRide) []any {
+	// Kept with the ride for driver navigation; the planned route expires from Redis
+	var steps []byte
+	if len(ride.Steps) > 0 {
+		steps, _ = json.Marshal(ride.Steps)
+	}
+	return []any{
+		ride.UserID, ride.Fare, ride.OriginName, ride.DestinationName,
+		fmt.Sprintf("%d", ride.Distance), ride.RouteID, ride.Duration, ride.Distance, ride.VehicleType,
+		ride.OriginLat, ride.OriginLng, ride.DestinationLat, ride.DestinationLng, ride.PaymentMode, ride.Currency, steps,
+	}
+}
+
+func (r *pgRideRepo) Create(ctx context.Context, ride NewRide) (string, error) {
+	var rideID string
+	err := r.pool.QueryRow(ctx, insertRideSQL, insertRideArgs(ride)...).Scan(&rideID)
+	return rideID, err
+}
+
+func (r *pgRideRepo) CreateTx(ctx context.Context, tx pgx.Tx, ride NewRide) (string, error) {
+	var rideID string
+	err := tx.QueryRow(ctx, insertRideSQL, insertRideArgs(ride)...).Scan(&rideID)
+	return rideID, err
+}
+
+func (r *pgRideRepo) GetForUser(ctx context.Context, rideID, userID string) (*models.Ride, error) {
+	var ride models.Ride
+	err := r.pool.QueryRow(ctx,
+		`SELECT id, "userId", "driverId", "currentLocationName", "destinationLocationName", COALESCE("vehicleType", ''),
+		 COALESCE("paymentMode", ''), "originLat", "originLng", "destinationLat", "destinationLng"
+		 FROM rides WHERE id=$1 AND "userId"=$2`, rideID, userID).
+		Scan(&ride.ID, &ride.UserID, &ride.DriverID, &ride.CurrentLocationName, &ride.DestinationLocationName, &ride.VehicleType,
+			&ride.PaymentMode, &ride.OriginLat, &ride.OriginLng, &ride.DestinationLat, &ride.DestinationLng)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &ride, nil
+}
+
+func (r *pgRideRepo) GetForDriver(ctx context.Context, rideID, driverID string) (*models.Ride, error) {
+	var ride models.Ride
+	var user models.User
+	err := r.pool.QueryRow(ctx,
+		`SELECT r.id, r."userId", r."driverId", r.charge, r."currentLocationName", r."destinationLocationName",
+		r.distance, COALESCE(r.polyline, ''), COALESCE(r."estimatedDuration", 0), COALESCE(r."estimatedDistance", 0),
+		COALESCE(r."vehicleType", ''), r.status, r.rating, COALESCE(r."paymentMode", ''), COALESCE(r."paymentStatus", 'Pending'),
+		r."originLat", r."originLng", r."destinationLat", r."destinationLng",
+		r."createdAt", r."updatedAt",
+		u.id, u.name, u.phone_number, u.ratings
+		FROM rides r
+		JOIN "user" u ON r."userId"=u.id
+		WHERE r.id=$1 AND r."driverId"=$2`, rideID, driverID).
+		Scan(&ride.ID, &ride.UserID, &ride.DriverID, &ride.Charge, &ride.CurrentLocationName, &ride.DestinationLocationName,
+			&ride.Distance, &ride.Polyline, &ride.EstimatedDuration, &ride.EstimatedDistance,
+			&ride.VehicleType, &ride.Status, &ride.Rating, &ride.PaymentMode, &ride.PaymentStatus,
+			&ride.OriginLat, &ride.OriginLng, &ride.DestinationLat, &ride.DestinationLng,
+			&ride.CreatedAt, &ride.UpdatedAt,
+			&user.ID, &user.Name, &user.PhoneNumber, &user.Ratings)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	ride.User = &user
+	return &ride, nil
+}
+
+func (r *pgRideRepo) Details(ctx context.Context, rideID string) (*models.Ride, error) {
+	var ride models.Ride
+	var driver models.Driver
+	var user models.User
+	err := r.pool.QueryRow(ctx,
+		`SELECT
+			r.id, r."userId", r."driverId", r.charge, r.currency, r."currentLocationName", r."destinationLocationName",
+			r.distance, r.status, COALESCE(r."paymentMode", ''), COALESCE(r."paymentStatus", 'Pending'),
+			COALESCE(r.otp, ''), COALESCE(r.polyline, ''), COALESCE(r."routeId", ''),
+			r."originLat", r."originLng", r."destinationLat", r."destinationLng",
+			r."arrivedAt", r."waitingMinutes", r."waitingCharge", r."cancellationFee", r."createdAt",
+			COALESCE(d.id, ''), COALESCE(d.name, ''), COALESCE(d.phone_number, ''), COALESCE(d.vehicle_type, ''),
+			COALESCE(d.vehicle_color, ''), COALESCE(d.registration_number, ''), COALESCE(d.ratings, 0), COALESCE(d."totalRides", 0),
+			COALESCE(d."totalDistance", 0), COALESCE(d."profileImage", ''), `+db.CompatColumn(db.ChangeDriverUpiID, `d."upiId"`, "d.upi_id")+`,
+			u.id, u.name, u.phone_number, u.ratings
+		FROM rides r
+		LEFT JOIN driver d ON r."driverId" = d.id
+		JOIN "user" u ON r."userId" = u.id
+		WHERE r.id=$1`, rideID).
+		Scan(
+			&ride.ID, &ride.UserID, &ride.DriverID, &ride.Charge, &ride.Currency, &ride.CurrentLocationName, &ride.DestinationLocationName,
+			&ride.Distance, &ride.Status, &ride.PaymentMode, &ride.PaymentStatus,
+			&ride.OTP, &ride.Polyline, &ride.RouteID,
+			&ride.OriginLat, &ride.OriginLng, &ride.DestinationLat, &ride.DestinationLng,
+			&ride.ArrivedAt, &ride.WaitingMinutes, &ride.WaitingCharge, &ride.CancellationFee, &ride.CreatedAt,
+			&driver.ID, &driver.Name, &driver.PhoneNumber, &driver.VehicleType,
+			&driver.VehicleColor, &driver.RegistrationNumber, &driver.Ratings, &driver.TotalRides,
+			&driver.TotalDistance, &driver.ProfileImage, &driver.UpiID,
+			&user.ID, &user.Name, &user.PhoneNumber, &user.Ratings,
+		)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	if driver.ID != "" {
+		ride.Driver = &driver
+	}
+	ride.User = &user
+	return &ride, nil
+}
+
+func (r *pgRideRepo) IncomingForDriver(ctx context.Context, driverID string) (*models.Ride, error) {
+	var ride models.Ride
+	var user models.User
+	err := r.pool.QueryRow(ctx,
+		`SELECT r.id, r."userId", r."driverId", r.charge, r."currentLocationName", r."destinationLocationName",
+		r.distance, COALESCE(r.polyline, ''), COALESCE(r."estimatedDuration", 0), COALESCE(r."estimatedDistance", 0),
+		COALESCE(r."vehicleType", ''), r.status, r."originLat", r."originLng", r."destinationLat", r."destinationLng",
+		r."createdAt", r."updatedAt",
+		u.id, u.name, u.phone_number, u.ratings
+		FROM rides r
+		JOIN "user" u ON r."userId"=u.id
+		WHERE r."driverId"=$1 AND r.status='Requested'
+		ORDER BY r."createdAt" DESC LIMIT 1`, driverID).
+		Scan(&ride.ID, &ride.UserID, &ride.DriverID, &ride.Charge, &ride.CurrentLocationName, &ride.DestinationLocationName,
+			&ride.Distance, &ride.Polyline, &ride.EstimatedDuration, &ride.EstimatedDistance,
+			&ride.VehicleType, &ride.Status, &ride.OriginLat, &ride.OriginLng, &ride.DestinationLat, &ride.DestinationLng,
+			&ride.CreatedAt, &ride.UpdatedAt,
+			&user.ID, &user.Name, &user.PhoneNumber, &user.Ratings)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	ride.User = &user
+	return &ride, nil
+}
+
+func (r *pgRideRepo) StartOTP(ctx context.Context, rideID, driverID string) (string, *string, error) {
+	var status string
+	var otp *string
+	err := r.pool.QueryRow(ctx,
+		`SELECT status, otp FROM rides WHERE id=$1 AND "driverId"=$2`, rideID, driverID).Scan(&status, &otp)
+	return status, otp, notFound(err)
+}
+
+func (r *pgRideRepo) VehicleType(ctx context.Context, rideID string) (string, error) {
+	var vehicleType string
+	err := r.pool.QueryRow(ctx, `SELECT COALESCE("vehicleType", '') FROM rides WHERE id=$1`, rideID).Scan(&vehicleType)
+	return vehicleType, notFound(err)
+}
+
+// historyConds turns a history filter into query conditions, after the owner's ($1).
+func historyConds(ownerCol, ownerID string, f RideHistoryFilter) ([]string, []any) {
+	conds, args := []string{ownerCol + "=$1"}, []any{ownerID}
+	if len(f.Statuses) > 0 {
+		args = append(args, f.Statuses)
+		conds = append(conds, "r.status=ANY($"+strconv.Itoa(len(args))+")")
+	}
+	if f.From != nil {
+		args = append(args, *f.From)
+		conds = append(conds, `r."createdAt" >= $`+strconv.Itoa(len(args)))
+	}
+	if f.To != nil {
+		args = append(args, *f.To)
+		conds = append(conds, `r."createdAt" < $`+strconv.Itoa(len(args)))
+	}
+	return conds, args
+}
+
+func (r *pgRideRepo) UserHistory(ctx context.Context, userID string, f RideHistoryFilter, pg utils.Pagination) ([]models.Ride, RideHistorySummary, error) {
+	conds, args := historyConds(`r."userId"`, userID, f)
+
+	var sum RideHistorySummary
+	err := r.pool.QueryRow(ctx,
+		`SELECT COUNT(*), COUNT(*) FILTER (WHERE r.status='Completed'),
+		 COALESCE(SUM(r.charge + COALESCE(r.tips, 0)) FILTER (WHERE r.status='Completed'), 0)
+		 FROM rides r`+utils.WhereClause(conds), args...).Scan(&sum.Rides, &sum.Completed, &sum.Amount)
+	if err != nil {
+		return nil, sum, err
+	}
+
+	conds, args = pg.Keyset(conds, args, `r."createdAt"`, "r.id")
+	tail, args := pg.Tail(args, `r."createdAt"`, "r.id")
+	rows, err := r.pool.Query(ctx,
+		`SELECT r.id, r."userId", r."driverId", r.charge, r."currentLocationName", r."destinationLocationName",
+		 r.distance, r.status, r.rating, COALESCE(r."vehicleType",''), COALESCE(r."paymentMode",''),
+		 COALESCE(r."paymentStatus",'Pending'), COALESCE(r.tips, 0), r."createdAt", r."updatedAt",
+		 COALESCE(d.id,''), COALESCE(d.name,''), COALESCE(d.phone_number,''), COALESCE(d.vehicle_type,''),
+		 COALESCE(d.vehicle_color,''), COALESCE(d.registration_number,''), COALESCE(d.ratings,0),
+		 COALESCE(d."profileImage",'')
+		FROM rides r
+		LEFT JOIN driver d ON r."driverId"=d.id`+utils.WhereClause(conds)+tail, args...)
+	if err != nil {
+		return nil, sum, err
+	}
+	defer rows.Close()
+
+	rides := []models.Ride{}
+	for rows.Next() {
+		var ride models.Ride
+		var d models.Driver
+		if err := rows.Scan(&ride.ID, &ride.UserID, &ride.DriverID, &ride.Charge, &ride.CurrentLocationName, &ride.DestinationLocationName,
+			&ride.Distance, &ride.Status, &ride.Rating, &ride.VehicleType, &ride.PaymentMode, &ride.PaymentStatus, &ride.Tips,
+			&ride.CreatedAt, &ride.UpdatedAt,
+			&d.ID, &d.Name, &d.PhoneNumber, &d.VehicleType, &d.VehicleColor, &d.RegistrationNumber, &d.Ratings, &d.ProfileImage); err != nil {
+			return nil, sum, err
+		}
+		if d.ID != "" {
+			ride.Driver = &d
+		}
+		rides = append(rides, ride)
+	}
+	return rides, sum, rows.Err()
+}
+
+func (r *pgRideRepo) DriverHistory(ctx context.Context, driverID string, f RideHistoryFilter, pg utils.Pagination) ([]models.Ride, RideHistorySummary, error) {
+	conds, args := historyConds(`r."driverId"`, driverID, f)
+
+	// The amount is what reached the driver (ride_earnings.net: after commission, with tips and incentives)
+	var sum RideHistorySummary
+	err := r.pool.QueryRow(ctx,
+		`SELECT COUNT(*), COUNT(*) FILTER (WHERE r.status='Completed'), COALESCE(SUM(e.net), 0)
+		 FROM rides r LEFT JOIN ride_earnings e ON e."rideId"=r.id`+utils.WhereClause(conds), args...).Scan(&sum.Rides, &sum.Completed, &sum.Amount)
+	if err != nil {
+		return nil, sum, err
+	}
+
+	conds, args = pg.Keyset(conds, args, `r."createdAt"`, "r.id")
+	tail, args := pg.Tail(args, `r."createdAt"`, "r.id")
+	rows, err := r.pool.Query(ctx,
+		`SELECT r.id, r."userId", r."driverId", r.charge, r."currentLocationName", r."destinationLocationName",
+		 r.distance, r.status, r.rating, COALESCE(r."vehicleType",''), COALESCE(r."paymentMode",''),
+		 COALESCE(r."paymentStatus",'Pending'), COALESCE(r.tips, 0), r."createdAt", r."updatedAt",
+		 u.id, u.name, u.phone_number, u.ratings
+		FROM rides r
+		JOIN "user" u ON r."userId"=u.id`+utils.WhereClause(conds)+tail, args...)
+	if err != nil {
+		return nil, sum, err
+	}
+	defer rows.Close()
+
+	rides := []models.Ride{}
+	for rows.Next() {
+		var ride models.Ride
+		var u models.User
+		if err := rows.Scan(&ride.ID, &ride.UserID, &ride.DriverID, &ride.Charge, &ride.CurrentLocationName, &ride.DestinationLocationName,
+			&ride.Distance, &ride.Status, &ride.Rating, &ride.VehicleType, &ride.PaymentMode, &ride.PaymentStatus, &ride.Tips,
+			&ride.CreatedAt, &ride.UpdatedAt,
+			&u.ID, &u.Name, &u.PhoneNumber, &u.Ratings); err != nil {
+			return nil, sum, err
+		}
+		ride.User = &u
+		rides = append(rides, ride)
+	}
+	return rides, sum, rows.Err()
+}
+
+func (r *pgRideRepo) RateDriver(ctx context.Context, rideID string, rating float64, tags []string, comment string) error {
+	_, err := r.pool.Exec(ctx,
+		`UPDATE rides SET rating=$1, "ratingTags"=$2, "ratingComment"=NULLIF($3, '') WHERE id=$4`,
+		rating, tags, comment, rideID)
+	return err
+}
+
+func (r *pgRideRepo) RateUser(ctx context.Context, rideID, userID string, rating float64, tags []string, comment string) error {
+	_, err := r.pool.Exec(ctx,
+		`UPDATE rides SET "userRating"=$1, "userRatingTags"=$2, "userRatingComment"=NULLIF($3, '') WHERE id=$4 AND "userId"=$5`,
+		rating, tags, comment, rideID, userID)
+	return err
+}
+
+// statusTimestamps is the lifecycle column a driver's status change stamps.
+var statusTimestamps = map[string]string{
+	"Accepted":  `,"acceptedAt"=NOW()`,
+	"Completed": `,"completedAt"=NOW()`,
+	"Cancelled": `,"cancelledAt"=NOW()`,
+}
+
+func (r *pgRideRepo) SetDriverStatus(ctx context.Context, tx pgx.Tx, rideID, driverID, status, otp string) (*models.Ride, error) {
+	var ride models.Ride
+	err := tx.QueryRow(ctx,
+		`UPDATE rides SET status=$1, otp=COALESCE(NULLIF($4, ''), otp), "updatedAt"=NOW()`+statusTimestamps[status]+`
+			WHERE id=$2 AND "driverId"=$3
+			RETURNING id, "userId", "driverId", charge, currency, "currentLocationName", "destinationLocationName", distance, status, rating, "createdAt", "updatedAt"`,
+		status, rideID, driverID, otp).
+		Scan(&ride.ID, &ride.UserID, &ride.DriverID, &ride.Charge, &ride.Currency, &ride.CurrentLocationName, &ride.DestinationLocationName, &ride.Distance, &ride.Status, &ride.Rating, &ride.CreatedAt, &ride.UpdatedAt)
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	var user models.User
+	tx.QueryRow(ctx,
+		`SELECT id, name, phone_number, ratings FROM "user" WHERE id=$1`, ride.UserID).
+		Scan(&user.ID, &user.Name, &user.PhoneNumber, &user.Ratings)
+	ride.User = &user
+	return &ride, nil
+}
+
+func (r *pgRideRepo) Start(ctx context.Context, tx pgx.Tx, rideID, driverID string) (*models.Ride, error) {
+	var ride models.Ride
+	err := tx.QueryRow(ctx,
+		`UPDATE rides SET status='InProgress', "startedAt"=NOW(), "updatedAt"=NOW()
+			WHERE id=$1 AND "driverId"=$2
+			RETURNING id, "userId", "driverId", charge, "currentLocationName", "destinationLocationName", distance, status, rating, "createdAt", "updatedAt"`,
+		rideID, driverID).
+		Scan(&ride.ID, &ride.UserID, &ride.DriverID, &ride.Charge, &ride.CurrentLocationName, &ride.DestinationLocationName, &ride.Distance, &ride.Status, &ride.Rating, &ride.CreatedAt, &ride.UpdatedAt)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &ride, nil
+}
+
+func (r *pgRideRepo) PickupTimes(ctx context.Context, tx pgx.Tx, rideID string) (*time.Time, *time.Time, time.Time, error) {
+	var acceptedAt, arrivedAt *time.Time
+	var now time.Time
+	err := tx.QueryRow(ctx, `SELECT "acceptedAt", "arrivedAt", NOW() FROM rides WHERE id=$1`, rideID).
+		Scan(&acceptedAt, &arrivedAt, &now)
+	return acceptedAt, arrivedAt, now, notFound(err)
+}
+
+func (r *pgRideRepo) CancelByUser(ctx context.Context, tx pgx.Tx, rideID, userID, reason string, fee fares.Cancellation) (*string, error) {
+	var driverID *string
+	err := tx.QueryRow(ctx,
+		`UPDATE rides SET status='Cancelled', "cancelReason"=$1, "cancelledAt"=NOW(), "updatedAt"=NOW(),
+			 "cancellationFee"=$4, "waitingMinutes"=$5, "waitingCharge"=$6
+			 WHERE id=$2 AND "userId"=$3 RETURNING "driverId"`,
+		reason, rideID, userID, fee.Fee, fee.Waiting.Minutes, fee.Waiting.Charge).Scan(&driverID)
+	return driverID, notFound(err)
+}
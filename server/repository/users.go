@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"ridewave/models"
+)
+
+// UserSelectCols is the user column list ScanUser reads, consistent across all queries.
+const UserSelectCols = `id, name, phone_number, email, "notificationToken", ratings, "totalRides", status, "createdAt", "updatedAt"`
+
+func ScanUser(scanner interface{ Scan(dest ...any) error }, u *models.User) error {
+	return scanner.Scan(&u.ID, &u.Name, &u.PhoneNumber, &u.Email, &u.NotificationToken, &u.Ratings, &u.TotalRides, &u.Status, &u.CreatedAt, &u.UpdatedAt)
+}
+
+type pgUserRepo struct {
+	pool *pgxpool.Pool
+}
+
+func (r *pgUserRepo) one(ctx context.Context, query string, args ...any) (*models.User, error) {
+	var u models.User
+	if err := ScanUser(r.pool.QueryRow(ctx, query, args...), &u); err != nil {
+		return nil, notFound(err)
+	}
+	return &u, nil
+}
+
+func (r *pgUserRepo) GetByID(ctx context.Context, id string) (*models.User, error) {
+	return r.one(ctx, `SELECT `+UserSelectCols+` FROM "user" WHERE id=$1`, id)
+}
+
+func (r *pgUserRepo) GetByPhone(ctx context.Context, phone string) (*models.User, error) {
+	return r.one(ctx, `SELECT `+UserSelectCols+` FROM "user" WHERE phone_number=$1`, phone)
+}
+
+func (r *pgUserRepo) Create(ctx context.Context, name, email, phone string) (*models.User, error) {
+	return r.one(ctx,
+		`INSERT INTO "user" (id, name, email, phone_number, ratings, "totalRides", status, "createdAt", "updatedAt")
+		VALUES (gen_random_uuid()::text, $1, $2, $3, 0, 0, 'active', NOW(), NOW())
+		RETURNING `+UserSelectCols,
+		name, email, phone)
+}
+
+func (r *pgUserRepo) UpdateProfile(ctx context.Context, id, name, email string) (*models.User, error) {
+	return r.one(ctx,
+		`UPDATE "user" SET name=COALESCE(NULLIF($1,''), name), email=COALESCE(NULLIF($2,''), email), "updatedAt"=NOW() WHERE id=$3
+		RETURNING `+UserSelectCols,
+		name, email, id)
+}
+
+func (r *pgUserRepo) SetNotificationToken(ctx context.Context, id, token string) (*models.User, error) {
+	return r.one(ctx,
+		`UPDATE "user" SET "notificationToken"=NULLIF($1,''), "updatedAt"=NOW() WHERE id=$2 RETURNING `+UserSelectCols,
+		token, id)
+}
+
+func (r *pgUserRepo) PreferredLanguage(ctx context.Context, id string) (string, error) {
+	var lang string
+	err := r.pool.QueryRow(ctx, `SELECT COALESCE("preferredLanguage", '') FROM "user" WHERE id=$1`, id).Scan(&lang)
+	return lang, notFound(err)
+}
+
+func (r *pgUserRepo) AddRating(ctx context.Context, id string, rating float64) error {
+	_, err := r.pool.Exec(ctx,
+		`UPDATE "user" SET ratings = (ratings * "totalRides" + $1) / ("totalRides" + 1), "updatedAt"=NOW() WHERE id=$2`,
+		rating, id)
+	return err
+}
+
+func (r *pgUserRepo) AddCompletedRide(ctx context.Context, tx pgx.Tx, id string) error {
+	_, err := tx.Exec(ctx,
+		`UPDATE "user" SET "totalRides"="totalRides"+1, "updatedAt"=NOW() WHERE id=$1`, id)
+	return err
+}
@@ -0,0 +1,123 @@
+package fares
+
+import (
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Rounding modes for fares.
+const (
+	RoundUp      = "up"
+	RoundNearest = "nearest"
+	RoundDown    = "down"
+)
+
+// Currency is what fares are charged in and how they're rounded. The deployment sets the default
+// and a service zone can override any part of it, so one deployment can serve cities in
+// different countries.
+type Currency struct {
+	Code     string  `json:"code"`     // ISO 4217, stored on rides and payments
+	Symbol   string  `json:"symbol"`   // put in front of amounts in notifications
+	Rounding string  `json:"rounding"` // up | nearest | down
+	RoundTo  float64 `json:"roundTo"`  // fares are rounded to a multiple of this, e.g. 1, 5 or 0.05
+}
+
+// currencySymbols covers the currencies a deployment is likely to run in; others are shown by code.
+var currencySymbols = map[string]string{
+	"INR": "₹",
+	"USD": "$",
+	"EUR": "€",
+	"GBP": "£",
+	"BDT": "৳",
+	"LKR": "Rs ",
+	"NPR": "Rs ",
+	"PKR": "Rs ",
+	"SGD": "S$",
+	"AED": "AED ",
+}
+
+// SymbolFor is the symbol shown in front of amounts in the given currency.
+func SymbolFor(code string) string {
+	if s, ok := currencySymbols[code]; ok {
+		return s
+	}
+	return code + " "
+}
+
+// ValidRounding reports whether mode is one of RoundUp, RoundNearest or RoundDown.
+func ValidRounding(mode string) bool {
+	return mode == RoundUp || mode == RoundNearest || mode == RoundDown
+}
+
+// LoadCurrency reads the deployment's currency: FARE_CURRENCY (default INR), FARE_CURRENCY_SYMBOL
+// (default the currency's usual symbol), FARE_ROUNDING (default up) and FARE_ROUND_TO (default 1).
+// Malformed values keep their defaults.
+func LoadCurrency() Currency {
+	c := Currency{Code: "INR", Rounding: RoundUp, RoundTo: 1}
+	if code := strings.ToUpper(strings.TrimSpace(os.Getenv("FARE_CURRENCY"))); len(code) == 3 {
+		c.Code = code
+	}
+	c.Symbol = SymbolFor(c.Code)
+	if symbol := os.Getenv("FARE_CURRENCY_SYMBOL"); symbol != "" {
+		c.Symbol = symbol
+	}
+	if mode := strings.ToLower(os.Getenv("FARE_ROUNDING")); ValidRounding(mode) {
+		c.Rounding = mode
+	}
+	if val, err := strconv.ParseFloat(os.Getenv("FARE_ROUND_TO"), 64); err == nil && val > 0 {
+		c.RoundTo = val
+	}
+	return c
+}
+
+// WithOverrides applies a zone's settings; nil keeps the current value. A different currency
+// takes that currency's symbol.
+func (c Currency) WithOverrides(code, rounding *string, roundTo *float64) Currency {
+	if code != nil && *code != "" && *code != c.Code {
+		c.Code = *code
+		c.Symbol = SymbolFor(c.Code)
+	}
+	if rounding != nil && ValidRounding(*rounding) {
+		c.Rounding = *rounding
+	}
+	if roundTo != nil && *roundTo > 0 {
+		c.RoundTo = *roundTo
+	}
+	return c
+}
+
+// ForCode is c for amounts already charged in code, e.g. a stored ride's; an empty code is c's own.
+func (c Currency) ForCode(code string) Currency {
+	return c.WithOverrides(&code, nil, nil)
+}
+
+// Round rounds a fare to a multiple of RoundTo in the Rounding direction.
+func (c Currency) Round(fare float64) float64 {
+	step := c.RoundTo
+	if step <= 0 {
+		step = 1
+	}
+	// Trim float noise first, so 120.0000001 doesn't round up to 121
+	units := math.Round(fare/step*1e6) / 1e6
+	switch c.Rounding {
+	case RoundNearest:
+		units = math.Round(units)
+	case RoundDown:
+		units = math.Floor(units)
+	default:
+		units = math.Ceil(units)
+	}
+	return round2(units * step)
+}
+
+// Format shows an amount with the currency symbol, without decimals when it's whole: "₹120",
+// "$12.50".
+func (c Currency) Format(amount float64) string {
+	amount = round2(amount)
+	if amount == math.Trunc(amount) {
+		return c.Symbol + strconv.FormatFloat(amount, 'f', 0, 64)
+	}
+	return c.Symbol + strconv.FormatFloat(amount, 'f', 2, 64)
+}
@@ -0,0 +1,122 @@
+// Package fares prices what happens to a ride after it's booked: the rider keeping the driver
+// waiting at the pickup, and the rider cancelling once a driver has accepted. The policy comes
+// from ENV, so it can be tuned without a release, and is published to the apps as is. It also
+// holds the currency fares are charged in and how they're rounded (currency.go).
+package fares
+
+import (
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CancellationTier is the fee for a rider cancelling at least AfterMinutes after the driver accepted.
+type CancellationTier struct {
+	AfterMinutes int     `json:"afterMinutes"`
+	Fee          float64 `json:"fee"`
+}
+
+// Policy is the waiting and cancellation pricing.
+type Policy struct {
+	WaitingFreeMinutes     int                `json:"waitingFreeMinutes"`     // waiting this long at the pickup is free
+	WaitingChargePerMinute float64            `json:"waitingChargePerMinute"` // each started minute after that
+	CancellationTiers      []CancellationTier `json:"cancellationTiers"`      // ascending by AfterMinutes
+}
+
+// defaultCancellationTiers lets a rider change their mind for two minutes after acceptance.
+var defaultCancellationTiers = []CancellationTier{{0, 0}, {2, 25}, {5, 50}}
+
+// LoadPolicy reads the policy: WAITING_FREE_MINUTES (default 3), WAITING_CHARGE_PER_MINUTE
+// (default 2) and CANCELLATION_FEE_TIERS, a list of minutes:fee pairs (default "0:0,2:25,5:50").
+// Malformed values keep their defaults.
+func LoadPolicy() Policy {
+	p := Policy{WaitingFreeMinutes: 3, WaitingChargePerMinute: 2, CancellationTiers: defaultCancellationTiers}
+	if val, err := strconv.Atoi(os.Getenv("WAITING_FREE_MINUTES")); err == nil && val >= 0 {
+		p.WaitingFreeMinutes = val
+	}
+	if val, err := strconv.ParseFloat(os.Getenv("WAITING_CHARGE_PER_MINUTE"), 64); err == nil && val >= 0 {
+		p.WaitingChargePerMinute = val
+	}
+	if tiers, ok := parseTiers(os.Getenv("CANCELLATION_FEE_TIERS")); ok {
+		p.CancellationTiers = tiers
+	}
+	return p
+}
+
+func parseTiers(s string) ([]CancellationTier, bool) {
+	if strings.TrimSpace(s) == "" {
+		return nil, false
+	}
+	var tiers []CancellationTier
+	for _, part := range strings.Split(s, ",") {
+		minutes, fee, ok := strings.Cut(strings.TrimSpace(part), ":")
+		if !ok {
+			return nil, false
+		}
+		m, err1 := strconv.Atoi(minutes)
+		f, err2 := strconv.ParseFloat(fee, 64)
+		if err1 != nil || err2 != nil || m < 0 || f < 0 {
+			return nil, false
+		}
+		tiers = append(tiers, CancellationTier{AfterMinutes: m, Fee: f})
+	}
+	sort.Slice(tiers, func(i, j int) bool { return tiers[i].AfterMinutes < tiers[j].AfterMinutes })
+	return tiers, true
+}
+
+// Waiting is the rider's wait at the pickup: every started minute, and what the ones past the
+// free allowance cost.
+type Waiting struct {
+	Minutes int     `json:"minutes"`
+	Charge  float64 `json:"charge"`
+}
+
+// Waiting prices a wait from the driver's arrival until the given time.
+func (p Policy) Waiting(arrivedAt, until time.Time) Waiting {
+	minutes := int(math.Ceil(until.Sub(arrivedAt).Minutes()))
+	if minutes < 0 {
+		minutes = 0
+	}
+	w := Waiting{Minutes: minutes}
+	if billable := minutes - p.WaitingFreeMinutes; billable > 0 {
+		w.Charge = round2(float64(billable) * p.WaitingChargePerMinute)
+	}
+	return w
+}
+
+// Cancellation is what a rider owes for cancelling: the tier's fee plus any wait already run up.
+type Cancellation struct {
+	Fee     float64 `json:"fee"`
+	Waiting Waiting `json:"waiting"`
+}
+
+// Total is the whole amount the rider owes.
+func (c Cancellation) Total() float64 {
+	return round2(c.Fee + c.Waiting.Charge)
+}
+
+// Cancellation prices a rider cancelling at the given time. acceptedAt is nil for a ride no driver
+// had taken yet, which costs nothing; arrivedAt is nil until the driver reached the pickup.
+func (p Policy) Cancellation(acceptedAt, arrivedAt *time.Time, at time.Time) Cancellation {
+	var c Cancellation
+	if acceptedAt == nil {
+		return c
+	}
+	elapsed := int(at.Sub(*acceptedAt).Minutes())
+	for _, t := range p.CancellationTiers {
+		if elapsed >= t.AfterMinutes {
+			c.Fee = t.Fee
+		}
+	}
+	if arrivedAt != nil {
+		c.Waiting = p.Waiting(*arrivedAt, at)
+	}
+	return c
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
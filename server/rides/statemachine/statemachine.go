@@ -0,0 +1,185 @@
+// Package statemachine is the single place a ride's status changes. It knows which transitions
+// are legal, serialises changes to one ride with a Redis lock (so a rider cancelling and a driver
+// accepting at the same moment can't both win), re-checks the status under a row lock, and
+// publishes the matching ride event once the change is committed.
+package statemachine
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"ridewave/db"
+	"ridewave/events"
+	"ridewave/utils"
+)
+
+// Ride statuses.
+const (
+	Requested  = "Requested"
+	Accepted   = "Accepted"
+	Arriving   = "Arriving"
+	InProgress = "InProgress"
+	Completed  = "Completed"
+	Cancelled  = "Cancelled"
+)
+
+// transitions lists where each status may go next. Completed and Cancelled are final.
+var transitions = map[string][]string{
+	Requested:  {Accepted, Cancelled},
+	Accepted:   {Arriving, InProgress, Cancelled},
+	Arriving:   {InProgress, Cancelled},
+	InProgress: {Completed, Cancelled},
+}
+
+// eventTypes is the ride event published when a ride enters a status.
+var eventTypes = map[string]string{
+	Accepted:   events.RideAccepted,
+	Arriving:   events.DriverArrived,
+	InProgress: events.RideStarted,
+	Completed:  events.RideCompleted,
+	Cancelled:  events.RideCancelled,
+}
+
+// LockKeyPrefix is the Redis key family of the per-ride locks (rides:lock:<rideId>).
+const LockKeyPrefix = "rides:lock:"
+
+const (
+	lockTTL  = 10 * time.Second // outlives any transition; a crashed holder can't block the ride for long
+	lockWait = 3 * time.Second  // how long a second caller waits before giving up
+	lockPoll = 50 * time.Millisecond
+)
+
+var (
+	// ErrRideNotFound is returned when the ride doesn't exist.
+	ErrRideNotFound = errors.New("ride not found")
+	// ErrInvalidTransition is returned when the ride can't move from its current status to the requested one.
+	ErrInvalidTransition = errors.New("invalid ride status transition")
+	// ErrRideBusy is returned when another change to the same ride holds the lock for too long.
+	ErrRideBusy = errors.New("ride is being updated, try again")
+)
+
+// TransitionError is the ErrInvalidTransition returned by Transition, naming the two statuses.
+type TransitionError struct {
+	From, To string
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("ride can't move from %s to %s", e.From, e.To)
+}
+
+// Is makes errors.Is(err, ErrInvalidTransition) match.
+func (e *TransitionError) Is(target error) bool { return target == ErrInvalidTransition }
+
+// CanTransition reports whether a ride may move from one status to another.
+func CanTransition(from, to string) bool {
+	return slices.Contains(transitions[from], to)
+}
+
+// Change describes one status change.
+type Change struct {
+	RideID string
+	To     string
+	// From optionally narrows the statuses the change is allowed from, e.g. a rider may only
+	// cancel before the trip starts. It can't widen what CanTransition allows.
+	From      []string
+	ActorType string // events.ActorUser, ActorDriver or ActorSystem
+	ActorID   string
+	Data      map[string]any // extra event data; "from" is added
+}
+
+// Transition moves a ride to ch.To. apply runs inside the transaction with the ride row locked
+// and the transition already checked; it must set the status (with its timestamps and whatever
+// else the caller needs) and may queue notifications on tx. apply runs again if the transaction
+// deadlocks, so it must only write through tx. The ride event is published after commit.
+// Transition returns the status the ride was in.
+func Transition(ctx context.Context, ch Change, apply func(ctx context.Context, tx pgx.Tx, from string) error) (string, error) {
+	release, err := lockRide(ctx, ch.RideID)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	var from string
+	err = db.WithTx(ctx, func(tx pgx.Tx) error {
+		err := tx.QueryRow(ctx, `SELECT status FROM rides WHERE id=$1 FOR UPDATE`, ch.RideID).Scan(&from)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrRideNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if !CanTransition(from, ch.To) || (len(ch.From) > 0 && !slices.Contains(ch.From, from)) {
+			return &TransitionError{From: from, To: ch.To}
+		}
+
+		if err := apply(ctx, tx, from); err != nil {
+			return err
+		}
+		var status string
+		if err := tx.QueryRow(ctx, `SELECT status FROM rides WHERE id=$1`, ch.RideID).Scan(&status); err != nil {
+			return err
+		}
+		if status != ch.To {
+			return fmt.Errorf("statemachine: apply left ride %s in %s, want %s", ch.RideID, status, ch.To)
+		}
+		return nil
+	})
+	if err != nil {
+		return from, err
+	}
+
+	data := map[string]any{"from": from}
+	for k, v := range ch.Data {
+		data[k] = v
+	}
+	events.Publish(events.Event{Type: eventTypes[ch.To], RideID: ch.RideID, ActorType: ch.ActorType, ActorID: ch.ActorID, Data: data})
+	return from, nil
+}
+
+// releaseLockScript deletes the lock only if it's still ours, so a holder that overran the TTL
+// can't release someone else's lock.
+var releaseLockScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// lockRide takes the ride's lock, waiting up to lockWait. If Redis is down the change goes ahead
+// unlocked; the row lock still keeps it correct, only less polite to the loser.
+func lockRide(ctx context.Context, rideID string) (func(), error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	token := hex.EncodeToString(b)
+	key := LockKeyPrefix + rideID
+
+	deadline := time.Now().Add(lockWait)
+	for {
+		ok, err := db.RedisClient.SetNX(ctx, key, token, lockTTL).Result()
+		if err != nil {
+			utils.Logger.Warn("Ride lock unavailable, continuing without it", zap.String("rideId", rideID), zap.Error(err))
+			return func() {}, nil
+		}
+		if ok {
+			return func() { releaseLockScript.Run(context.Background(), db.RedisClient, []string{key}, token) }, nil
+		}
+		if time.Now().After(deadline) {
+			return nil, ErrRideBusy
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(lockPoll):
+		}
+	}
+}
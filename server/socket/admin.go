@@ -0,0 +1,145 @@
+package socket
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	socketio "github.com/zishang520/socket.io/v2/socket"
+	"go.uber.org/zap"
+	"ridewave/db"
+	"ridewave/stores"
+	"ridewave/utils"
+)
+
+// onlineDriversInterval is how often the online driver count is re-checked while admins are watching.
+const onlineDriversInterval = 10 * time.Second
+
+// authenticateAdmin accepts an admin JWT from /api/v1/admin/auth/login, or ADMIN_SOCKET_SECRET when
+// set, for wallboards that have no admin login.
+func authenticateAdmin(token string) (string, error) {
+	if token == "" {
+		return "", errors.New("authentication token required")
+	}
+	if secret := os.Getenv("ADMIN_SOCKET_SECRET"); secret != "" &&
+		subtle.ConstantTimeCompare([]byte(token), []byte(secret)) == 1 {
+		return "secret", nil
+	}
+
+	parsed, err := jwt.Parse(token, func(t *jwt.Token) (interface{}, error) {
+		return utils.AdminTokenSecret(), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil || !parsed.Valid {
+		return "", errors.New("invalid or expired token")
+	}
+	claims, ok := parsed.Claims.(jwt.MapClaims)
+	if !ok || claims["scope"] != "admin" {
+		return "", errors.New("not an admin token")
+	}
+	id, _ := claims["id"].(string)
+	var email string
+	var active bool
+	if err := db.Pool.QueryRow(context.Background(),
+		`SELECT email, "isActive" FROM admin_accounts WHERE id=$1`, id).Scan(&email, &active); err != nil {
+		return "", errors.New("admin not found")
+	}
+	if !active {
+		return "", errors.New("admin account is disabled")
+	}
+	return email, nil
+}
+
+// adminAuthMiddleware guards the /admin namespace; app tokens are never accepted here.
+func adminAuthMiddleware(s *socketio.Socket, next func(*socketio.ExtendedError)) {
+	token, _ := handshakeToken(s.Handshake())
+	admin, err := authenticateAdmin(token)
+	if err != nil {
+		next(socketio.NewExtendedError("unauthorized", map[string]any{"message": err.Error()}))
+		return
+	}
+	s.SetData(admin)
+	next(nil)
+}
+
+// liveOpsCounts are the headline numbers on the operations dashboard.
+func liveOpsCounts(ctx context.Context) map[string]any {
+	var onlineDrivers, requestedRides, ongoingRides, activeSOS int
+	db.Pool.QueryRow(ctx, `SELECT COUNT(*) FROM driver WHERE "isOnline"=true`).Scan(&onlineDrivers)
+	db.Pool.QueryRow(ctx, `SELECT COUNT(*) FROM rides WHERE status='Requested'`).Scan(&requestedRides)
+	db.Pool.QueryRow(ctx, `SELECT COUNT(*) FROM rides WHERE status IN ('Accepted','Arriving','InProgress')`).Scan(&ongoingRides)
+	db.Pool.QueryRow(ctx, `SELECT COUNT(*) FROM sos_alerts WHERE status='active'`).Scan(&activeSOS)
+	return map[string]any{
+		"onlineDrivers":  onlineDrivers,
+		"requestedRides": requestedRides,
+		"ongoingRides":   ongoingRides,
+		"activeSOS":      activeSOS,
+		"at":             time.Now(),
+	}
+}
+
+// initAdminNamespace serves the live operations feed on /admin: a snapshot on connect, then
+// rideRequested, rideStatus, sos and onlineDrivers events as they happen.
+func initAdminNamespace(io *socketio.Server) {
+	ns := io.Of("/admin", nil)
+	ns.Use(adminAuthMiddleware)
+
+	var watching atomic.Int64
+	ns.On("connection", func(clients ...any) {
+		socket := clients[0].(*socketio.Socket)
+		admin, _ := socket.Data().(string)
+		watching.Add(1)
+		utils.Logger.Info("Admin connected to live feed", zap.String("socketID", string(socket.Id())), zap.String("admin", admin))
+
+		socket.Emit("snapshot", liveOpsCounts(db.Unscoped()))
+		socket.On("disconnect", func(...any) {
+			watching.Add(-1)
+		})
+	})
+
+	// Relay ride and SOS events published by any instance
+	go func() {
+		ctx := context.Background()
+		pubsub := stores.SubscribeToAdminOps(ctx)
+		defer pubsub.Close()
+
+		for msg := range pubsub.Channel() {
+			var event stores.AdminOpsEvent
+			if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+				utils.Logger.Error("Error unmarshalling admin ops event", zap.Error(err))
+				continue
+			}
+			payload := map[string]any{"at": event.At}
+			for k, v := range event.Payload {
+				payload[k] = v
+			}
+			ns.Emit(event.Event, payload)
+		}
+	}()
+
+	// Push the online driver count when it changes, only while someone is watching
+	go func() {
+		ticker := time.NewTicker(onlineDriversInterval)
+		defer ticker.Stop()
+
+		last := -1
+		for range ticker.C {
+			if watching.Load() == 0 {
+				last = -1
+				continue
+			}
+			var count int
+			if err := db.Pool.QueryRow(db.Unscoped(), `SELECT COUNT(*) FROM driver WHERE "isOnline"=true`).Scan(&count); err != nil {
+				continue
+			}
+			if count != last {
+				last = count
+				ns.Emit("onlineDrivers", map[string]any{"count": count, "at": time.Now()})
+			}
+		}
+	}()
+}
@@ -0,0 +1,129 @@
+package socket
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+	socketio "github.com/zishang520/socket.io/v2/socket"
+	"ridewave/db"
+	"ridewave/utils"
+)
+
+const (
+	roleUser   = "user"
+	roleDriver = "driver"
+)
+
+// identity is the authenticated user or driver a socket is bound to for its lifetime.
+type identity struct {
+	Role string
+	ID   string
+}
+
+// handshakeToken reads the JWT from the handshake: auth.token (socket.io-client v4),
+// then an "Authorization: Bearer" header, then the ?token= query for older clients.
+func handshakeToken(h *socketio.Handshake) (token, role string) {
+	if auth, ok := h.Auth.(map[string]any); ok {
+		token, _ = auth["token"].(string)
+		role, _ = auth["role"].(string)
+	}
+	if token == "" {
+		for _, v := range h.Headers["authorization"] {
+			if parts := strings.SplitN(v, " ", 2); len(parts) == 2 && strings.EqualFold(parts[0], "bearer") {
+				token = parts[1]
+			}
+		}
+	}
+	if token == "" && len(h.Query["token"]) > 0 {
+		token = h.Query["token"][0]
+	}
+	if role == "" && len(h.Query["role"]) > 0 {
+		role = h.Query["role"][0]
+	}
+	token = strings.TrimPrefix(token, "Bearer ")
+	return token, role
+}
+
+// authenticate validates an app JWT and resolves whether it belongs to a user or a driver.
+// role is an optional hint from the client; without it both tables are checked.
+func authenticate(tokenStr, role string) (*identity, error) {
+	if tokenStr == "" {
+		return nil, errors.New("authentication token required")
+	}
+	token, err := jwt.Parse(tokenStr, func(t *jwt.Token) (interface{}, error) {
+		return []byte(os.Getenv("ACCESS_TOKEN_SECRET")), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil || !token.Valid {
+		return nil, errors.New("invalid or expired token")
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, errors.New("invalid token claims")
+	}
+	// Share links and admin sessions are scoped tokens, not app logins
+	if scope, _ := claims["scope"].(string); scope != "" {
+		return nil, errors.New("token not valid for realtime connections")
+	}
+	id, _ := claims["id"].(string)
+	if id == "" {
+		return nil, errors.New("invalid token payload")
+	}
+
+	// Session tokens carry their role; older ones rely on the client's hint
+	if tokenRole, _ := claims["role"].(string); tokenRole != "" {
+		role = tokenRole
+	}
+	candidates := []string{roleDriver, roleUser}
+	if role == roleDriver || role == roleUser {
+		candidates = []string{role}
+	}
+	for _, r := range candidates {
+		table := "driver"
+		if r == roleUser {
+			table = `"user"`
+		}
+		var status string
+		if err := db.Pool.QueryRow(db.Unscoped(), `SELECT status FROM `+table+` WHERE id=$1`, id).Scan(&status); err != nil {
+			continue
+		}
+		// Same account states the HTTP auth middleware blocks
+		if status == "suspended" || (r == roleUser && status == "inactive") || (r == roleDriver && status == "rejected") {
+			return nil, errors.New("account is not active")
+		}
+		if utils.TokenRevoked(context.Background(), r, claims) {
+			return nil, errors.New("session has ended")
+		}
+		return &identity{Role: r, ID: id}, nil
+	}
+	return nil, errors.New("account not found")
+}
+
+// authMiddleware rejects handshakes without a valid JWT and binds the socket to its identity.
+func authMiddleware(s *socketio.Socket, next func(*socketio.ExtendedError)) {
+	token, role := handshakeToken(s.Handshake())
+	ident, err := authenticate(token, role)
+	if err != nil {
+		next(socketio.NewExtendedError("unauthorized", map[string]any{"message": err.Error()}))
+		return
+	}
+	s.SetData(ident)
+	next(nil)
+}
+
+// socketIdentity returns the identity bound to the socket at handshake time.
+func socketIdentity(s *socketio.Socket) *identity {
+	ident, _ := s.Data().(*identity)
+	return ident
+}
+
+// ridesWith reports whether the driver currently has an active ride with the user.
+func ridesWith(driverID, userID string) bool {
+	var ok bool
+	db.Pool.QueryRow(db.Unscoped(),
+		`SELECT EXISTS(SELECT 1 FROM rides WHERE "driverId"=$1 AND "userId"=$2 AND status IN ('Accepted','Arriving','InProgress'))`,
+		driverID, userID).Scan(&ok)
+	return ok
+}
@@ -7,6 +7,7 @@ import (
 	"ridewave/utils"
 
 	socketio "github.com/zishang520/socket.io/v2/socket"
+	"github.com/redis/go-redis/v9"
 	"github.com/zishang520/engine.io/v2/types"
 	"go.uber.org/zap"
 
@@ -24,9 +25,37 @@ func InitSocketIO() *socketio.Server {
 
 	io := socketio.NewServer(nil, opts)
 
+	// Every connection must present a valid app JWT; the socket is bound to that user/driver
+	io.Use(authMiddleware)
+
 	io.On("connection", func(clients ...any) {
 		socket := clients[0].(*socketio.Socket)
-		utils.Logger.Info("A user connected", zap.String("socketID", string(socket.Id())))
+		ident := socketIdentity(socket)
+		if ident == nil {
+			socket.Disconnect(true)
+			return
+		}
+		utils.Logger.Info("A user connected", zap.String("socketID", string(socket.Id())), zap.String("role", ident.Role), zap.String("id", ident.ID))
+
+		// Join the identity's own room for targeted dispatch and updates
+		if ident.Role == roleDriver {
+			socket.Join(socketio.Room("driver:" + ident.ID))
+			if err := stores.BindDriverSocket(context.Background(), string(socket.Id()), ident.ID); err != nil {
+				utils.Logger.Error("Error binding driver socket", zap.Error(err))
+			}
+		} else {
+			socket.Join(socketio.Room(ident.ID))
+		}
+
+		// reject tells the client an event was dropped because its IDs don't match the socket's identity
+		reject := func(event string) {
+			utils.Logger.Warn("Rejected socket event with mismatched identity",
+				zap.String("event", event), zap.String("socketID", string(socket.Id())), zap.String("id", ident.ID))
+			socket.Emit("unauthorized", map[string]any{"event": event, "message": "Event does not match the authenticated account"})
+		}
+
+		// riderId caches the rider last verified as this driver's passenger
+		var riderId string
 
 		// locationUpdate — driver sends their GPS position
 		socket.On("locationUpdate", func(args ...any) {
@@ -38,29 +67,28 @@ func InitSocketIO() *socketio.Server {
 				return
 			}
 
-			role, _ := data["role"].(string)
 			driverId, _ := data["driverId"].(string)
+			if ident.Role != roleDriver || (driverId != "" && driverId != ident.ID) {
+				reject("locationUpdate")
+				return
+			}
+			driverId = ident.ID
+			data["driverId"] = driverId
 
-			if role == "driver" && driverId != "" {
-				lat, _ := data["latitude"].(float64)
-				lon, _ := data["longitude"].(float64)
-
-				// Update via Redis Store
-				err := stores.UpdateDriverLocation(driverId, lat, lon, string(socket.Id()))
-				if err != nil {
-					utils.Logger.Error("Error updating driver location", zap.Error(err))
-				}
+			lat, _ := data["latitude"].(float64)
+			lon, _ := data["longitude"].(float64)
 
-				// Join driver to their own room for targeted dispatch
-				socket.Join(socketio.Room("driver:" + driverId))
+			// Update via Redis Store
+			err := stores.UpdateDriverLocation(context.Background(), driverId, lat, lon, string(socket.Id()))
+			if err != nil {
+				utils.Logger.Error("Error updating driver location", zap.Error(err))
+			}
 
-				// If driver is in a ride, broadcast to the user
-				userId, _ := data["userId"].(string)
-				if userId != "" {
-					io.To(socketio.Room(userId)).Emit("rideUpdate", data)
-				}
-				
-				// utils.Logger.Debug("Updated driver location", zap.String("driverId", driverId))
+			// If driver is in a ride, broadcast to that ride's rider only
+			userId, _ := data["userId"].(string)
+			if userId != "" && (userId == riderId || ridesWith(ident.ID, userId)) {
+				riderId = userId
+				io.To(socketio.Room(userId)).Emit("rideUpdate", data)
 			}
 		})
 
@@ -70,10 +98,12 @@ func InitSocketIO() *socketio.Server {
 			data, ok := args[0].(map[string]any)
 			if !ok { return }
 			userId, _ := data["userId"].(string)
-			if userId != "" {
-				socket.Join(socketio.Room(userId))
-				utils.Logger.Info("User joined room", zap.String("userId", userId))
+			if ident.Role != roleUser || userId != ident.ID {
+				reject("joinUserRoom")
+				return
 			}
+			socket.Join(socketio.Room(userId))
+			utils.Logger.Info("User joined room", zap.String("userId", userId))
 		})
 		
 		// startRide - Driver/User signals ride start
@@ -92,45 +122,66 @@ func InitSocketIO() *socketio.Server {
 				return
 			}
 
-			role, _ := data["role"].(string)
 			userId, _ := data["userId"].(string)
+			if ident.Role != roleUser || (userId != "" && userId != ident.ID) {
+				reject("requestRide")
+				return
+			}
+			userId = ident.ID
 
-			if role == "user" {
-				lat, _ := data["latitude"].(float64)
-				lon, _ := data["longitude"].(float64)
+			lat, _ := data["latitude"].(float64)
+			lon, _ := data["longitude"].(float64)
 
-				utils.Logger.Info("Ride requested by user", zap.String("userId", userId))
+			utils.Logger.Info("Ride requested by user", zap.String("userId", userId))
 
-				// Find nearby drivers using Redis
-				drivers, err := stores.GetNearbyDrivers(lat, lon, 5.0)
-				if err != nil {
-					utils.Logger.Error("Error finding nearby drivers", zap.Error(err))
-				}
+			// Find nearby drivers using Redis
+			drivers, err := stores.GetNearbyDrivers(context.Background(), lat, lon, 5.0)
+			if err != nil {
+				utils.Logger.Error("Error finding nearby drivers", zap.Error(err))
+			}
 
-				// Map to response format
-				var nearby []map[string]any
-				for _, d := range drivers {
-					nearby = append(nearby, map[string]any{
-						"id":        d.DriverID,
-						"latitude":  d.Latitude,
-						"longitude": d.Longitude,
-						"socketId":  d.SocketID,
-					})
-				}
+			// Time each driver to the pickup so the map can show "3 mins away"
+			points := make([]utils.ETAPoint, len(drivers))
+			for i, d := range drivers {
+				points[i] = utils.ETAPoint{ID: d.DriverID, Lat: d.Latitude, Lng: d.Longitude}
+			}
+			etas := utils.NearbyDriverETAs(context.Background(), lat, lon, points)
 
-				socket.Emit("nearbyDrivers", map[string]any{
-					"drivers": nearby,
+			// Map to response format
+			var nearby []map[string]any
+			for _, d := range drivers {
+				eta := etas[d.DriverID]
+				nearby = append(nearby, map[string]any{
+					"id":        d.DriverID,
+					"latitude":  d.Latitude,
+					"longitude": d.Longitude,
+					"socketId":  d.SocketID,
+					"etaSeconds":        eta.Seconds,
+					"etaDistanceMeters": eta.Meters,
+					"etaText":           eta.Text,
 				})
 			}
+
+			socket.Emit("nearbyDrivers", map[string]any{
+				"drivers": nearby,
+			})
 		})
 
-		// disconnect — remove driver from active list
+		// disconnect — remove driver from active list right away instead of waiting for the location TTL
 		socket.On("disconnect", func(args ...any) {
 			utils.Logger.Info("User disconnected", zap.String("socketID", string(socket.Id())))
-			// In Redis architecture, determining WHICH driver disconnected by socketID is harder 
-			// without a reverse index (SocketID -> DriverID).
-			// For now, we rely on TTL or we could add a map/store method for this.
-			// Ideally: When connecting, map SocketID -> DriverID in Redis.
+			if ident.Role != roleDriver {
+				return
+			}
+			driverId, err := stores.ReleaseDriverSocket(context.Background(), string(socket.Id()))
+			if err == redis.Nil {
+				return
+			}
+			if err != nil {
+				utils.Logger.Error("Error releasing driver socket", zap.String("socketID", string(socket.Id())), zap.Error(err))
+				return
+			}
+			utils.Logger.Info("Driver went offline for dispatch", zap.String("driverId", driverId))
 		})
 	})
 
@@ -149,7 +200,7 @@ func InitSocketIO() *socketio.Server {
 			}
 
 			// Find nearby drivers
-			drivers, err := stores.GetNearbyDrivers(event.PickupLat, event.PickupLon, 5.0) // 5km radius
+			drivers, err := stores.GetNearbyDrivers(ctx, event.PickupLat, event.PickupLon, 5.0) // 5km radius
 			if err != nil {
 				utils.Logger.Error("Error finding drivers for dispatch", zap.Error(err))
 				continue
@@ -157,7 +208,17 @@ func InitSocketIO() *socketio.Server {
 
 			utils.Logger.Info("Dispatching ride", zap.String("rideId", event.RideID), zap.Int("driverCount", len(drivers)))
 
+			// Drivers whose destination mode or preferred zone the trip doesn't suit aren't offered it
+			skip := map[string]bool{}
+			for _, id := range event.SkipDrivers {
+				skip[id] = true
+			}
+			event.SkipDrivers = nil
+
 			for _, d := range drivers {
+				if skip[d.DriverID] {
+					continue
+				}
 				// Emit to specific driver socket
 				// Note: io.To(socketId) works if using default adapter or redis adapter
 				// Since we are using redis adapter implicitly via go-socket.io redis store (if configured) or just local
@@ -174,6 +235,91 @@ func InitSocketIO() *socketio.Server {
 		}
 	}()
 
+	// Subscribe to payment status changes so both apps flip to "Paid" without polling
+	go func() {
+		ctx := context.Background()
+		pubsub := stores.SubscribeToPaymentUpdates(ctx)
+		defer pubsub.Close()
+
+		for msg := range pubsub.Channel() {
+			var event stores.PaymentUpdateEvent
+			if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+				utils.Logger.Error("Error unmarshalling payment update", zap.Error(err))
+				continue
+			}
+
+			io.To(socketio.Room(event.UserID)).Emit("paymentUpdate", event)
+			if event.DriverID != "" {
+				io.To(socketio.Room("driver:" + event.DriverID)).Emit("paymentUpdate", event)
+			}
+		}
+	}()
+
+	// Subscribe to ride bidding updates: new requests and outcomes for drivers, incoming bids for riders
+	go func() {
+		ctx := context.Background()
+		pubsub := stores.SubscribeToBidEvents(ctx)
+		defer pubsub.Close()
+
+		for msg := range pubsub.Channel() {
+			var event stores.BidEvent
+			if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+				utils.Logger.Error("Error unmarshalling bid event", zap.Error(err))
+				continue
+			}
+
+			payload := map[string]any{"offerId": event.OfferID}
+			for k, v := range event.Payload {
+				payload[k] = v
+			}
+			if event.UserID != "" {
+				io.To(socketio.Room(event.UserID)).Emit(event.Event, payload)
+			}
+			for _, driverID := range event.DriverIDs {
+				io.To(socketio.Room("driver:" + driverID)).Emit(event.Event, payload)
+			}
+		}
+	}()
+
+	// Subscribe to live ETA updates for riders with an active ride
+	go func() {
+		ctx := context.Background()
+		pubsub := stores.SubscribeToETAUpdates(ctx)
+		defer pubsub.Close()
+
+		for msg := range pubsub.Channel() {
+			var event stores.ETAUpdateEvent
+			if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+				utils.Logger.Error("Error unmarshalling ETA update", zap.Error(err))
+				continue
+			}
+			io.To(socketio.Room(event.UserID)).Emit("etaUpdate", event)
+		}
+	}()
+
+	// Subscribe to outbox notifications (ride status and the like) for riders' and drivers' open apps
+	go func() {
+		ctx := context.Background()
+		pubsub := stores.SubscribeToNotifications(ctx)
+		defer pubsub.Close()
+
+		for msg := range pubsub.Channel() {
+			var event stores.NotificationEvent
+			if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+				utils.Logger.Error("Error unmarshalling notification", zap.Error(err))
+				continue
+			}
+			room := event.RecipientID
+			if event.RecipientType == roleDriver {
+				room = "driver:" + event.RecipientID
+			}
+			io.To(socketio.Room(room)).Emit(event.Event, event.Payload)
+		}
+	}()
+
+	// Live operations feed for the admin panel, on its own namespace with admin-only auth
+	initAdminNamespace(io)
+
 	return io
 }
 
@@ -0,0 +1,35 @@
+package stores
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"ridewave/db"
+)
+
+// AdminOpsChannel carries live operations events to admin dashboard sockets on every instance.
+const AdminOpsChannel = "admin_ops"
+
+// AdminOpsEvent is one update for the live admin dashboard, emitted as Event on the /admin namespace.
+type AdminOpsEvent struct {
+	Event   string         `json:"event"` // rideRequested | rideStatus | sos
+	Payload map[string]any `json:"payload"`
+	At      time.Time      `json:"at"`
+}
+
+func PublishAdminOpsEvent(ctx context.Context, event AdminOpsEvent) error {
+	if event.At.IsZero() {
+		event.At = time.Now()
+	}
+	val, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return db.RedisClient.Publish(ctx, AdminOpsChannel, val).Err()
+}
+
+func SubscribeToAdminOps(ctx context.Context) *redis.PubSub {
+	return db.RedisClient.Subscribe(ctx, AdminOpsChannel)
+}
@@ -0,0 +1,32 @@
+package stores
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/redis/go-redis/v9"
+	"ridewave/db"
+)
+
+const BidEventChannel = "bid_events"
+
+// BidEvent is a ride bidding update relayed to the rider's and drivers' sockets.
+type BidEvent struct {
+	Event     string         `json:"event"` // bidRequest | bidReceived | bidWon | bidClosed | bidExpired
+	OfferID   string         `json:"offerId"`
+	UserID    string         `json:"userId,omitempty"`
+	DriverIDs []string       `json:"driverIds,omitempty"`
+	Payload   map[string]any `json:"payload"`
+}
+
+func PublishBidEvent(ctx context.Context, event BidEvent) error {
+	val, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return db.RedisClient.Publish(ctx, BidEventChannel, val).Err()
+}
+
+func SubscribeToBidEvents(ctx context.Context) *redis.PubSub {
+	return db.RedisClient.Subscribe(ctx, BidEventChannel)
+}
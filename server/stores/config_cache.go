@@ -0,0 +1,54 @@
+package stores
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"strconv"
+	"time"
+
+	"ridewave/db"
+)
+
+// Config cache — read-mostly settings (vehicle types, zones, caps) kept in Redis so hot paths
+// like fare estimates don't query Postgres each time. Each config is one hash with a field per
+// scope (usually the tenant), so a change drops every scope with a single DEL.
+const configCacheKeyPrefix = "config:"
+
+// ConfigCacheTTL bounds how stale a config can get if an invalidation is missed
+// (CONFIG_CACHE_TTL_SECONDS, default 300).
+func ConfigCacheTTL() time.Duration {
+	if val, err := strconv.Atoi(os.Getenv("CONFIG_CACHE_TTL_SECONDS")); err == nil && val > 0 {
+		return time.Duration(val) * time.Second
+	}
+	return 5 * time.Minute
+}
+
+// CachedConfig returns the named config for scope, calling load and caching its result on a miss.
+// Redis errors fall through to load, so a cache outage only costs the database round trip.
+func CachedConfig[T any](ctx context.Context, name, scope string, load func(context.Context) (T, error)) (T, error) {
+	key := configCacheKeyPrefix + name
+	if raw, err := db.RedisClient.HGet(ctx, key, scope).Bytes(); err == nil {
+		var v T
+		if json.Unmarshal(raw, &v) == nil {
+			return v, nil
+		}
+	}
+
+	v, err := load(ctx)
+	if err != nil {
+		return v, err
+	}
+	if raw, err := json.Marshal(v); err == nil {
+		pipe := db.RedisClient.TxPipeline()
+		pipe.HSet(ctx, key, scope, raw)
+		pipe.Expire(ctx, key, ConfigCacheTTL())
+		pipe.Exec(ctx)
+	}
+	return v, nil
+}
+
+// InvalidateConfig drops every scope of the named config; the next read reloads it.
+func InvalidateConfig(ctx context.Context, name string) error {
+	return db.RedisClient.Del(ctx, configCacheKeyPrefix+name).Err()
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"ridewave/db"
+	"ridewave/utils"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -17,9 +18,10 @@ type DriverLocation struct {
 }
 
 const (
-	DriverGeoKey        = "drivers:geo"
-	DriverDataKeyPrefix = "drivers:data:"
-	RouteCacheKeyPrefix = "routes:cache:"
+	DriverGeoKey          = "drivers:geo"
+	DriverDataKeyPrefix   = "drivers:data:"
+	RouteCacheKeyPrefix   = "routes:cache:"
+	SocketDriverKeyPrefix = "sockets:driver:"
 )
 
 type CachedRoute struct {
@@ -27,6 +29,7 @@ type CachedRoute struct {
 	Distance          int     `json:"distance"`
 	Duration          int     `json:"duration"`
 	Fare              float64 `json:"fare"`
+	Currency          string  `json:"currency,omitempty"` // ISO 4217; empty on routes cached before currencies
 	VehicleType       string  `json:"vehicleType"`
 	OriginName        string  `json:"originName"`
 	DestinationName   string  `json:"destinationName"`
@@ -34,10 +37,18 @@ type CachedRoute struct {
 	OriginLng         float64 `json:"originLng"`
 	DestinationLat    float64 `json:"destinationLat"`
 	DestinationLng    float64 `json:"destinationLng"`
+	Stops             []RouteStop `json:"stops,omitempty"`
+	Steps             []utils.RouteStep `json:"steps,omitempty"` // turn-by-turn, every leg in order
 }
 
-func StorePlannedRoute(routeID string, route CachedRoute) error {
-	ctx := context.Background()
+// RouteStop is an intermediate stop on a multi-stop ride, in visiting order.
+type RouteStop struct {
+	Name string  `json:"name"`
+	Lat  float64 `json:"lat"`
+	Lng  float64 `json:"lng"`
+}
+
+func StorePlannedRoute(ctx context.Context, routeID string, route CachedRoute) error {
 	val, err := json.Marshal(route)
 	if err != nil {
 		return err
@@ -46,8 +57,7 @@ func StorePlannedRoute(routeID string, route CachedRoute) error {
 	return db.RedisClient.Set(ctx, RouteCacheKeyPrefix+routeID, val, 15*time.Minute).Err()
 }
 
-func GetPlannedRoute(routeID string) (*CachedRoute, error) {
-	ctx := context.Background()
+func GetPlannedRoute(ctx context.Context, routeID string) (*CachedRoute, error) {
 	val, err := db.RedisClient.Get(ctx, RouteCacheKeyPrefix+routeID).Result()
 	if err != nil {
 		return nil, err
@@ -60,9 +70,7 @@ func GetPlannedRoute(routeID string) (*CachedRoute, error) {
 	return &route, nil
 }
 
-func UpdateDriverLocation(driverID string, lat, lon float64, socketID string) error {
-	ctx := context.Background()
-
+func UpdateDriverLocation(ctx context.Context, driverID string, lat, lon float64, socketID string) error {
 	// Add to Geo index
 	err := db.RedisClient.GeoAdd(ctx, DriverGeoKey, &redis.GeoLocation{
 		Name:      driverID,
@@ -82,19 +90,77 @@ func UpdateDriverLocation(driverID string, lat, lon float64, socketID string) er
 	}
 	val, _ := json.Marshal(data)
 
+	// Keep the breadcrumb trail of an in-progress ride for route exports
+	appendRideTrackPoint(ctx, driverID, lat, lon)
+
 	// Set with TTL (e.g., 1 hour to auto-expire stale sessions)
-	return db.RedisClient.Set(ctx, DriverDataKeyPrefix+driverID, val, time.Hour).Err()
+	if err := db.RedisClient.Set(ctx, DriverDataKeyPrefix+driverID, val, time.Hour).Err(); err != nil {
+		return err
+	}
+	for _, fn := range locationListeners {
+		fn(ctx, driverID, lat, lon)
+	}
+	return nil
+}
+
+var locationListeners []func(ctx context.Context, driverID string, lat, lon float64)
+
+// OnDriverLocation registers fn to run after every stored location update, whether it came over
+// REST or the socket. fn runs on the caller's goroutine, so it must be quick. Register from init.
+func OnDriverLocation(fn func(ctx context.Context, driverID string, lat, lon float64)) {
+	locationListeners = append(locationListeners, fn)
 }
 
-func RemoveDriver(driverID string) error {
-	ctx := context.Background()
+// GetDriverLocation returns the last known position of a driver from Redis.
+func GetDriverLocation(ctx context.Context, driverID string) (*DriverLocation, error) {
+	val, err := db.RedisClient.Get(ctx, DriverDataKeyPrefix+driverID).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	var d DriverLocation
+	if err := json.Unmarshal([]byte(val), &d); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+func RemoveDriver(ctx context.Context, driverID string) error {
 	db.RedisClient.ZRem(ctx, DriverGeoKey, driverID)
 	return db.RedisClient.Del(ctx, DriverDataKeyPrefix+driverID).Err()
 }
 
-func GetNearbyDrivers(lat, lon, radiusKm float64) ([]DriverLocation, error) {
-	ctx := context.Background()
+// BindDriverSocket records which driver owns a socket so the driver can be dropped
+// from the geo index as soon as that socket disconnects.
+func BindDriverSocket(ctx context.Context, socketID, driverID string) error {
+	return db.RedisClient.Set(ctx, SocketDriverKeyPrefix+socketID, driverID, 24*time.Hour).Err()
+}
+
+// ReleaseDriverSocket removes the socket mapping and takes its driver offline for dispatch,
+// unless the driver has already reconnected on a different socket.
+func ReleaseDriverSocket(ctx context.Context, socketID string) (string, error) {
+	driverID, err := db.RedisClient.GetDel(ctx, SocketDriverKeyPrefix+socketID).Result()
+	if err != nil {
+		return "", err
+	}
+
+	if loc, err := GetDriverLocation(ctx, driverID); err == nil && loc.SocketID != "" && loc.SocketID != socketID {
+		return driverID, nil
+	}
+	return driverID, RemoveDriver(ctx, driverID)
+}
+
+// GetNearbyDrivers returns drivers within radiusKm of a point, nearest first. With
+// NEARBY_DRIVER_CACHE_MS set, lookups in the same pickup cell share one Redis query (nearby_cache.go).
+func GetNearbyDrivers(ctx context.Context, lat, lon, radiusKm float64) ([]DriverLocation, error) {
+	if ttl := nearbyCacheTTL(); ttl > 0 {
+		return cachedNearbyDrivers(ctx, lat, lon, radiusKm, ttl)
+	}
+	return queryNearbyDrivers(ctx, lat, lon, radiusKm)
+}
 
+// queryNearbyDrivers runs the geo search and fetches every driver's metadata in one MGET.
+func queryNearbyDrivers(ctx context.Context, lat, lon, radiusKm float64) ([]DriverLocation, error) {
 	// Find drivers within radius
 	locs, err := db.RedisClient.GeoRadius(ctx, DriverGeoKey, lon, lat, &redis.GeoRadiusQuery{
 		Radius:      radiusKm,
@@ -104,21 +170,34 @@ func GetNearbyDrivers(lat, lon, radiusKm float64) ([]DriverLocation, error) {
 		Sort:        "ASC",
 	}).Result()
 
+	if err != nil {
+		return nil, err
+	}
+	if len(locs) == 0 {
+		return nil, nil
+	}
+
+	// Fetch metadata (socket ID); a driver whose data expired is skipped
+	keys := make([]string, len(locs))
+	for i, loc := range locs {
+		keys[i] = DriverDataKeyPrefix + loc.Name
+	}
+	vals, err := db.RedisClient.MGet(ctx, keys...).Result()
 	if err != nil {
 		return nil, err
 	}
 
 	var drivers []DriverLocation
-	for _, loc := range locs {
-		// Fetch metadata (socket ID)
-		val, err := db.RedisClient.Get(ctx, DriverDataKeyPrefix+loc.Name).Result()
-		if err == nil {
-			var d DriverLocation
-			if json.Unmarshal([]byte(val), &d) == nil {
-				d.Latitude = loc.Latitude
-				d.Longitude = loc.Longitude
-				drivers = append(drivers, d)
-			}
+	for i, loc := range locs {
+		val, ok := vals[i].(string)
+		if !ok {
+			continue
+		}
+		var d DriverLocation
+		if json.Unmarshal([]byte(val), &d) == nil {
+			d.Latitude = loc.Latitude
+			d.Longitude = loc.Longitude
+			drivers = append(drivers, d)
 		}
 	}
 	return drivers, nil
@@ -135,6 +214,7 @@ type RideRequestEvent struct {
 	Fare        float64 `json:"fare"`
 	Distance    int     `json:"distance"`
 	Duration    int     `json:"duration"`
+	SkipDrivers []string `json:"skipDrivers,omitempty"` // nearby drivers the trip doesn't suit; not sent to drivers
 }
 
 func PublishRideRequest(ctx context.Context, event RideRequestEvent) error {
@@ -148,3 +228,27 @@ func PublishRideRequest(ctx context.Context, event RideRequestEvent) error {
 func SubscribeToRideRequests(ctx context.Context) *redis.PubSub {
 	return db.RedisClient.Subscribe(ctx, RideRequestChannel)
 }
+
+const PaymentUpdateChannel = "payment_updates"
+
+type PaymentUpdateEvent struct {
+	RideID   string  `json:"rideId"`
+	UserID   string  `json:"userId"`
+	DriverID string  `json:"driverId"`
+	Status   string  `json:"status"` // Paid | Failed
+	Mode     string  `json:"mode"`
+	Amount   float64 `json:"amount"`
+	Currency string  `json:"currency"`
+}
+
+func PublishPaymentUpdate(ctx context.Context, event PaymentUpdateEvent) error {
+	val, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return db.RedisClient.Publish(ctx, PaymentUpdateChannel, val).Err()
+}
+
+func SubscribeToPaymentUpdates(ctx context.Context) *redis.PubSub {
+	return db.RedisClient.Subscribe(ctx, PaymentUpdateChannel)
+}
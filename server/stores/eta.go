@@ -0,0 +1,37 @@
+package stores
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"ridewave/db"
+)
+
+const ETAUpdateChannel = "eta_updates"
+
+// ETAUpdateEvent is a fresh arrival estimate for the rider of an active ride.
+type ETAUpdateEvent struct {
+	RideID                string    `json:"rideId"`
+	UserID                string    `json:"userId"`
+	Status                string    `json:"status"`                         // Accepted | InProgress
+	PickupETASeconds      *int      `json:"pickupEtaSeconds,omitempty"`     // Accepted only
+	PickupDistanceMeters  *int      `json:"pickupDistanceMeters,omitempty"` // Accepted only
+	DropoffETASeconds     int       `json:"dropoffEtaSeconds"`              // from now, including the pickup leg
+	DropoffDistanceMeters int       `json:"dropoffDistanceMeters"`          // still to travel, pickup leg included
+	Source                string    `json:"source"`                         // matrix | haversine
+	UpdatedAt             time.Time `json:"updatedAt"`
+}
+
+func PublishETAUpdate(ctx context.Context, event ETAUpdateEvent) error {
+	val, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return db.RedisClient.Publish(ctx, ETAUpdateChannel, val).Err()
+}
+
+func SubscribeToETAUpdates(ctx context.Context) *redis.PubSub {
+	return db.RedisClient.Subscribe(ctx, ETAUpdateChannel)
+}
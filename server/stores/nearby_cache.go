@@ -0,0 +1,141 @@
+package stores
+
+import (
+	"context"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"ridewave/utils"
+)
+
+// Nearby driver cache — in-process, per pickup cell. In a busy pickup area (an airport, a station
+// at rush hour) many riders open the map within seconds of each other and ask Redis the same
+// question. With NEARBY_DRIVER_CACHE_MS set, the first lookup in a geohash cell searches around the
+// cell's centre, wide enough to cover any pickup in the cell, and the following ones filter that
+// result to their own pickup and radius until it expires. Positions can be that much older than
+// Redis, so keep the TTL to a few seconds. Each instance has its own cache.
+
+const (
+	nearbyCachePrecision  = 6   // geohash characters; a cell is at most about 1.2 km × 0.6 km
+	nearbyCacheMarginKm   = 0.7 // centre to corner of a cell, added to the cached search radius
+	nearbyCacheMaxEntries = 10000
+)
+
+// nearbyCacheTTL is how long a cell's drivers are reused (NEARBY_DRIVER_CACHE_MS, default 0 = off).
+func nearbyCacheTTL() time.Duration {
+	if val, err := strconv.Atoi(os.Getenv("NEARBY_DRIVER_CACHE_MS")); err == nil && val > 0 {
+		return time.Duration(val) * time.Millisecond
+	}
+	return 0
+}
+
+type nearbyCacheEntry struct {
+	drivers []DriverLocation // around the cell centre, radius + nearbyCacheMarginKm
+	expires time.Time
+}
+
+var (
+	nearbyCacheMu     sync.Mutex
+	nearbyCache       = map[string]nearbyCacheEntry{}
+	nearbyCacheHits   atomic.Int64
+	nearbyCacheMisses atomic.Int64
+)
+
+// cachedNearbyDrivers answers from the pickup cell's cached search, running it on a miss.
+func cachedNearbyDrivers(ctx context.Context, lat, lon, radiusKm float64, ttl time.Duration) ([]DriverLocation, error) {
+	cell := utils.Geohash(lat, lon, nearbyCachePrecision)
+	key := cell + ":" + strconv.FormatFloat(radiusKm, 'f', -1, 64)
+
+	nearbyCacheMu.Lock()
+	entry, ok := nearbyCache[key]
+	nearbyCacheMu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		nearbyCacheHits.Add(1)
+		return driversWithin(entry.drivers, lat, lon, radiusKm), nil
+	}
+	nearbyCacheMisses.Add(1)
+
+	centerLat, centerLon := utils.GeohashCenter(cell)
+	drivers, err := queryNearbyDrivers(ctx, centerLat, centerLon, radiusKm+nearbyCacheMarginKm)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	nearbyCacheMu.Lock()
+	if len(nearbyCache) >= nearbyCacheMaxEntries {
+		for k, e := range nearbyCache {
+			if now.After(e.expires) {
+				delete(nearbyCache, k)
+			}
+		}
+	}
+	if len(nearbyCache) < nearbyCacheMaxEntries {
+		nearbyCache[key] = nearbyCacheEntry{drivers: drivers, expires: now.Add(ttl)}
+	}
+	nearbyCacheMu.Unlock()
+
+	return driversWithin(drivers, lat, lon, radiusKm), nil
+}
+
+// driversWithin returns a new slice of the drivers within radiusKm of a point, nearest first.
+func driversWithin(drivers []DriverLocation, lat, lon, radiusKm float64) []DriverLocation {
+	type near struct {
+		d    DriverLocation
+		dist float64
+	}
+	var within []near
+	for _, d := range drivers {
+		if dist := utils.CalculateDistance(lat, lon, d.Latitude, d.Longitude); dist <= radiusKm {
+			within = append(within, near{d, dist})
+		}
+	}
+	slices.SortStableFunc(within, func(a, b near) int {
+		switch {
+		case a.dist < b.dist:
+			return -1
+		case a.dist > b.dist:
+			return 1
+		}
+		return 0
+	})
+	var out []DriverLocation
+	for _, n := range within {
+		out = append(out, n.d)
+	}
+	return out
+}
+
+// NearbyCacheStats is this instance's nearby driver cache activity since start.
+type NearbyCacheStats struct {
+	Enabled bool     `json:"enabled"`
+	TTLMs   int64    `json:"ttlMs"`
+	Entries int      `json:"entries"`
+	Hits    int64    `json:"hits"`
+	Misses  int64    `json:"misses"`
+	HitRate *float64 `json:"hitRate"` // hits / lookups, nil before the first lookup
+}
+
+// NearbyDriverCacheStats reports the cache's hits and misses for /health.
+func NearbyDriverCacheStats() NearbyCacheStats {
+	ttl := nearbyCacheTTL()
+	nearbyCacheMu.Lock()
+	entries := len(nearbyCache)
+	nearbyCacheMu.Unlock()
+	stats := NearbyCacheStats{
+		Enabled: ttl > 0,
+		TTLMs:   ttl.Milliseconds(),
+		Entries: entries,
+		Hits:    nearbyCacheHits.Load(),
+		Misses:  nearbyCacheMisses.Load(),
+	}
+	if lookups := stats.Hits + stats.Misses; lookups > 0 {
+		rate := float64(stats.Hits) / float64(lookups)
+		stats.HitRate = &rate
+	}
+	return stats
+}
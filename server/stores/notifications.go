@@ -0,0 +1,32 @@
+package stores
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/redis/go-redis/v9"
+	"ridewave/db"
+)
+
+// NotificationChannel carries outbox socket notifications to whichever instance holds the recipient's socket.
+const NotificationChannel = "notifications"
+
+// NotificationEvent is one socket event for a rider or driver.
+type NotificationEvent struct {
+	Event         string            `json:"event"`
+	RecipientType string            `json:"recipientType"` // user | driver
+	RecipientID   string            `json:"recipientId"`
+	Payload       map[string]string `json:"payload"`
+}
+
+func PublishNotification(ctx context.Context, event NotificationEvent) error {
+	val, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return db.RedisClient.Publish(ctx, NotificationChannel, val).Err()
+}
+
+func SubscribeToNotifications(ctx context.Context) *redis.PubSub {
+	return db.RedisClient.Subscribe(ctx, NotificationChannel)
+}
@@ -0,0 +1,57 @@
+package stores
+
+import (
+	"context"
+	"encoding/json"
+	"ridewave/db"
+	"time"
+)
+
+// TrackPoint is one GPS fix recorded while a ride is in progress.
+type TrackPoint struct {
+	Lat  float64   `json:"lat"`
+	Lng  float64   `json:"lng"`
+	Time time.Time `json:"t"`
+}
+
+const (
+	DriverActiveRideKeyPrefix = "drivers:active_ride:"
+	RideTrackKeyPrefix        = "rides:track:"
+)
+
+// StartRideTrack marks the ride the driver is currently carrying so their location updates are recorded against it.
+func StartRideTrack(ctx context.Context, driverID, rideID string) error {
+	return db.RedisClient.Set(ctx, DriverActiveRideKeyPrefix+driverID, rideID, 12*time.Hour).Err()
+}
+
+// StopRideTrack stops recording the driver's location against their current ride.
+func StopRideTrack(ctx context.Context, driverID string) error {
+	return db.RedisClient.Del(ctx, DriverActiveRideKeyPrefix+driverID).Err()
+}
+
+// appendRideTrackPoint records a location fix if the driver has a ride in progress.
+func appendRideTrackPoint(ctx context.Context, driverID string, lat, lon float64) {
+	rideID, err := db.RedisClient.Get(ctx, DriverActiveRideKeyPrefix+driverID).Result()
+	if err != nil || rideID == "" {
+		return
+	}
+	val, _ := json.Marshal(TrackPoint{Lat: lat, Lng: lon, Time: time.Now()})
+	db.RedisClient.RPush(ctx, RideTrackKeyPrefix+rideID, val)
+	db.RedisClient.Expire(ctx, RideTrackKeyPrefix+rideID, 24*time.Hour)
+}
+
+// GetRideTrack returns the recorded points of a ride still held in Redis, oldest first.
+func GetRideTrack(ctx context.Context, rideID string) ([]TrackPoint, error) {
+	vals, err := db.RedisClient.LRange(ctx, RideTrackKeyPrefix+rideID, 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	points := make([]TrackPoint, 0, len(vals))
+	for _, v := range vals {
+		var p TrackPoint
+		if json.Unmarshal([]byte(v), &p) == nil {
+			points = append(points, p)
+		}
+	}
+	return points, nil
+}
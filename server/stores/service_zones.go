@@ -0,0 +1,19 @@
+package stores
+
+import (
+	"context"
+
+	"github.com/redis/go-redis/v9"
+	"ridewave/db"
+)
+
+// ServiceZonesChannel tells every instance to reload service zones from the database.
+const ServiceZonesChannel = "service_zones_reload"
+
+func PublishServiceZonesChanged(ctx context.Context) error {
+	return db.RedisClient.Publish(ctx, ServiceZonesChannel, "reload").Err()
+}
+
+func SubscribeToServiceZoneChanges(ctx context.Context) *redis.PubSub {
+	return db.RedisClient.Subscribe(ctx, ServiceZonesChannel)
+}
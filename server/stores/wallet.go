@@ -0,0 +1,357 @@
+package stores
+
+import (
+	"context"
+	"errors"
+	"math"
+	"ridewave/db"
+	"ridewave/models"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	WalletTxRideEarning = "ride_earning"
+	WalletTxTip         = "tip"
+	WalletTxPayout      = "payout"
+
+	WalletTxFareAdjustment = "fare_adjustment"
+	WalletTxIncentive      = "incentive"
+)
+
+var ErrInsufficientBalance = errors.New("insufficient wallet balance")
+
+// ErrAlreadyCredited means another transaction credited the ride's earning first.
+var ErrAlreadyCredited = errors.New("ride earning already credited")
+
+// ErrAlreadyAdjusted means the ride's earning was already corrected once.
+var ErrAlreadyAdjusted = errors.New("ride earning already adjusted")
+
+// ErrTipNotAllowed means the ride isn't the rider's, isn't completed, was already tipped
+// or finished too long ago.
+var ErrTipNotAllowed = errors.New("ride can't be tipped")
+
+const walletSelectCols = `id, "driverId", balance, "totalEarned", "totalCommission", "totalPaidOut", "createdAt", "updatedAt"`
+
+const walletTxSelectCols = `id, "walletId", "driverId", "rideId", type, amount, commission, "fleetId", "fleetCommission", "balanceAfter", COALESCE(reference, ''), "createdAt"`
+
+func scanWallet(scanner interface{ Scan(dest ...any) error }, w *models.Wallet) error {
+	return scanner.Scan(&w.ID, &w.DriverID, &w.Balance, &w.TotalEarned, &w.TotalCommission, &w.TotalPaidOut, &w.CreatedAt, &w.UpdatedAt)
+}
+
+func scanWalletTx(scanner interface{ Scan(dest ...any) error }, t *models.WalletTransaction) error {
+	return scanner.Scan(&t.ID, &t.WalletID, &t.DriverID, &t.RideID, &t.Type, &t.Amount, &t.Commission, &t.FleetID, &t.FleetCommission,
+		&t.BalanceAfter, &t.Reference, &t.CreatedAt)
+}
+
+// GetOrCreateWallet returns the driver's wallet, opening an empty one on first access.
+func GetOrCreateWallet(ctx context.Context, driverID string) (*models.Wallet, error) {
+	_, err := db.Pool.Exec(ctx,
+		`INSERT INTO wallets ("driverId") VALUES ($1) ON CONFLICT ("driverId") DO NOTHING`, driverID)
+	if err != nil {
+		return nil, err
+	}
+
+	var w models.Wallet
+	err = scanWallet(db.Pool.QueryRow(ctx, `SELECT `+walletSelectCols+` FROM wallets WHERE "driverId"=$1`, driverID), &w)
+	if err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+// CreditRideEarning credits the driver's net earning (fare minus commission) for a completed ride,
+// on tx, the transaction settling the ride. A driver in an active fleet gives the fleet its
+// commissionPercent of that net, recorded on the same ledger entry, and the split is kept in
+// ride_earnings. A ride is only ever credited once: a ride that already was is left alone, and one
+// credited concurrently returns ErrAlreadyCredited so the caller rolls the whole settlement back.
+func CreditRideEarning(ctx context.Context, tx pgx.Tx, driverID, rideID string, fare, commission float64) error {
+	var credited bool
+	err := tx.QueryRow(ctx,
+		`SELECT EXISTS(SELECT 1 FROM wallet_transactions WHERE "rideId"=$1 AND type=$2)`, rideID, WalletTxRideEarning).Scan(&credited)
+	if err != nil || credited {
+		return err
+	}
+	_, err = tx.Exec(ctx, `INSERT INTO wallets ("driverId") VALUES ($1) ON CONFLICT ("driverId") DO NOTHING`, driverID)
+	if err != nil {
+		return err
+	}
+
+	net := fare - commission
+	var fleetID *string
+	var fleetCut, fleetPercent float64
+	err = tx.QueryRow(ctx,
+		`SELECT f.id, f."commissionPercent" FROM driver d JOIN fleets f ON f.id=d."fleetId" AND f.status='active' WHERE d.id=$1`,
+		driverID).Scan(&fleetID, &fleetPercent)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return err
+	}
+	if fleetID != nil {
+		fleetCut = math.Round(net*fleetPercent) / 100
+		net -= fleetCut
+	}
+
+	var walletID string
+	var balance float64
+	err = tx.QueryRow(ctx,
+		`UPDATE wallets SET balance=balance+$1, "totalEarned"="totalEarned"+$1, "totalCommission"="totalCommission"+$2, "updatedAt"=NOW()
+		 WHERE "driverId"=$3 RETURNING id, balance`, net, commission, driverID).Scan(&walletID, &balance)
+	if err != nil {
+		return err
+	}
+
+	tag, err := tx.Exec(ctx,
+		`INSERT INTO wallet_transactions ("walletId", "driverId", "rideId", type, amount, commission, "fleetId", "fleetCommission", "balanceAfter")
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		 ON CONFLICT ("rideId", type) WHERE "rideId" IS NOT NULL DO NOTHING`,
+		walletID, driverID, rideID, WalletTxRideEarning, net, commission, fleetID, fleetCut, balance)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrAlreadyCredited
+	}
+	_, err = tx.Exec(ctx,
+		`INSERT INTO ride_earnings ("rideId", "driverId", "fleetId", "grossFare", commission, "fleetCommission", tip, "completedAt")
+		 SELECT id, $2, $3, $4, $5, $6, COALESCE(tips, 0), COALESCE("completedAt", NOW()) FROM rides WHERE id=$1
+		 ON CONFLICT ("rideId") DO NOTHING`,
+		rideID, driverID, fleetID, fare, commission, fleetCut)
+	if err != nil {
+		return err
+	}
+	if fleetID != nil {
+		_, err = tx.Exec(ctx,
+			`UPDATE fleets SET "totalEarned"="totalEarned"+$1, "updatedAt"=NOW() WHERE id=$2`, fleetCut, *fleetID)
+	}
+	return err
+}
+
+// AddRideTip records the rider's tip on a ride completed within window and credits it in full
+// to the driver's wallet; tips carry no platform or fleet commission. Returns the driver's ID and
+// the currency the ride was charged in.
+func AddRideTip(ctx context.Context, userID, rideID string, amount float64, window time.Duration) (string, string, error) {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return "", "", err
+	}
+	defer tx.Rollback(ctx)
+
+	var driverID, currency string
+	err = tx.QueryRow(ctx,
+		`UPDATE rides SET tips=$3, "updatedAt"=NOW()
+		 WHERE id=$1 AND "userId"=$2 AND status='Completed' AND "driverId" IS NOT NULL AND COALESCE(tips, 0)=0
+		 AND "completedAt" >= NOW() - make_interval(secs => $4)
+		 RETURNING "driverId", currency`, rideID, userID, amount, window.Seconds()).Scan(&driverID, &currency)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", "", ErrTipNotAllowed
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	wallet, err := GetOrCreateWallet(ctx, driverID)
+	if err != nil {
+		return "", "", err
+	}
+	var balance float64
+	err = tx.QueryRow(ctx,
+		`UPDATE wallets SET balance=balance+$1, "totalEarned"="totalEarned"+$1, "updatedAt"=NOW()
+		 WHERE id=$2 RETURNING balance`, amount, wallet.ID).Scan(&balance)
+	if err != nil {
+		return "", "", err
+	}
+	_, err = tx.Exec(ctx,
+		`INSERT INTO wallet_transactions ("walletId", "driverId", "rideId", type, amount, "balanceAfter")
+		 VALUES ($1, $2, $3, $4, $5, $6)`, wallet.ID, driverID, rideID, WalletTxTip, amount, balance)
+	if err != nil {
+		return "", "", err
+	}
+	_, err = tx.Exec(ctx,
+		`UPDATE driver SET "totalEarning"="totalEarning"+$1, "updatedAt"=NOW() WHERE id=$2`, amount, driverID)
+	if err != nil {
+		return "", "", err
+	}
+	_, err = tx.Exec(ctx,
+		`UPDATE ride_earnings SET tip=$2, "updatedAt"=NOW() WHERE "rideId"=$1`, rideID, amount)
+	if err != nil {
+		return "", "", err
+	}
+	return driverID, currency, tx.Commit(ctx)
+}
+
+// AdjustRideEarning corrects the driver's earning on a ride whose fare changed after completion,
+// on tx, the transaction changing the fare. fareDelta and commissionDelta are the changes to the
+// fare and the platform's commission; a fleet takes the same share of the net change as it took
+// of the original credit. The driver's balance may go negative. A ride is adjusted at most once:
+// a second adjustment returns ErrAlreadyAdjusted so the caller rolls the fare change back.
+func AdjustRideEarning(ctx context.Context, tx pgx.Tx, driverID, rideID string, fareDelta, commissionDelta float64, reference string) error {
+	_, err := tx.Exec(ctx, `INSERT INTO wallets ("driverId") VALUES ($1) ON CONFLICT ("driverId") DO NOTHING`, driverID)
+	if err != nil {
+		return err
+	}
+
+	net := fareDelta - commissionDelta
+	var fleetID *string
+	var creditedNet, creditedFleet float64
+	err = tx.QueryRow(ctx,
+		`SELECT "fleetId", amount, "fleetCommission" FROM wallet_transactions WHERE "rideId"=$1 AND type=$2`,
+		rideID, WalletTxRideEarning).Scan(&fleetID, &creditedNet, &creditedFleet)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return err
+	}
+	var fleetCut float64
+	if fleetID != nil && creditedNet+creditedFleet > 0 {
+		fleetCut = math.Round(net*creditedFleet/(creditedNet+creditedFleet)*100) / 100
+		net -= fleetCut
+	}
+
+	var walletID string
+	var balance float64
+	err = tx.QueryRow(ctx,
+		`UPDATE wallets SET balance=balance+$1, "totalEarned"="totalEarned"+$1, "totalCommission"="totalCommission"+$2, "updatedAt"=NOW()
+		 WHERE "driverId"=$3 RETURNING id, balance`, net, commissionDelta, driverID).Scan(&walletID, &balance)
+	if err != nil {
+		return err
+	}
+
+	tag, err := tx.Exec(ctx,
+		`INSERT INTO wallet_transactions ("walletId", "driverId", "rideId", type, amount, commission, "fleetId", "fleetCommission", "balanceAfter", reference)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NULLIF($10, ''))
+		 ON CONFLICT ("rideId", type) WHERE "rideId" IS NOT NULL DO NOTHING`,
+		walletID, driverID, rideID, WalletTxFareAdjustment, net, commissionDelta, fleetID, fleetCut, balance, reference)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrAlreadyAdjusted
+	}
+	_, err = tx.Exec(ctx,
+		`UPDATE ride_earnings SET "grossFare"="grossFare"+$2, commission=commission+$3, "fleetCommission"="fleetCommission"+$4, "updatedAt"=NOW()
+		 WHERE "rideId"=$1`, rideID, fareDelta, commissionDelta, fleetCut)
+	if err != nil {
+		return err
+	}
+	if fleetID != nil && fleetCut != 0 {
+		_, err = tx.Exec(ctx,
+			`UPDATE fleets SET "totalEarned"="totalEarned"+$1, "updatedAt"=NOW() WHERE id=$2`, fleetCut, *fleetID)
+		if err != nil {
+			return err
+		}
+	}
+	_, err = tx.Exec(ctx,
+		`UPDATE driver SET "totalEarning"="totalEarning"+$1, "updatedAt"=NOW() WHERE id=$2`, fareDelta, driverID)
+	return err
+}
+
+// CreditIncentiveBonus pays a driver's achieved incentive into their wallet and adds it to the
+// earnings of the ride that reached the target. Each incentive pays a driver once; it returns
+// false if this one was already paid or hasn't been achieved.
+func CreditIncentiveBonus(ctx context.Context, driverID, incentiveID, rideID string, amount float64) (bool, error) {
+	wallet, err := GetOrCreateWallet(ctx, driverID)
+	if err != nil {
+		return false, err
+	}
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx,
+		`UPDATE driver_incentive_progress SET "rewardedAt"=NOW(), "rewardRideId"=$3, "updatedAt"=NOW()
+		 WHERE "incentiveId"=$1 AND "driverId"=$2 AND "achievedAt" IS NOT NULL AND "rewardedAt" IS NULL`,
+		incentiveID, driverID, rideID)
+	if err != nil {
+		return false, err
+	}
+	if tag.RowsAffected() == 0 {
+		return false, nil
+	}
+
+	var balance float64
+	err = tx.QueryRow(ctx,
+		`UPDATE wallets SET balance=balance+$1, "totalEarned"="totalEarned"+$1, "updatedAt"=NOW()
+		 WHERE id=$2 RETURNING balance`, amount, wallet.ID).Scan(&balance)
+	if err != nil {
+		return false, err
+	}
+	_, err = tx.Exec(ctx,
+		`INSERT INTO wallet_transactions ("walletId", "driverId", type, amount, "balanceAfter", reference)
+		 VALUES ($1, $2, $3, $4, $5, $6)`, wallet.ID, driverID, WalletTxIncentive, amount, balance, "incentive:"+incentiveID)
+	if err != nil {
+		return false, err
+	}
+	_, err = tx.Exec(ctx,
+		`UPDATE ride_earnings SET incentives=incentives+$2, "updatedAt"=NOW() WHERE "rideId"=$1`, rideID, amount)
+	if err != nil {
+		return false, err
+	}
+	_, err = tx.Exec(ctx,
+		`UPDATE driver SET "totalEarning"="totalEarning"+$1, "updatedAt"=NOW() WHERE id=$2`, amount, driverID)
+	if err != nil {
+		return false, err
+	}
+	return true, tx.Commit(ctx)
+}
+
+// RecordPayout debits a payout from the driver's wallet and records it in the ledger.
+func RecordPayout(ctx context.Context, driverID string, amount float64, reference string) (*models.WalletTransaction, error) {
+	wallet, err := GetOrCreateWallet(ctx, driverID)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	var balance float64
+	err = tx.QueryRow(ctx,
+		`UPDATE wallets SET balance=balance-$1, "totalPaidOut"="totalPaidOut"+$1, "updatedAt"=NOW()
+		 WHERE id=$2 AND balance >= $1 RETURNING balance`, amount, wallet.ID).Scan(&balance)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrInsufficientBalance
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var t models.WalletTransaction
+	err = scanWalletTx(tx.QueryRow(ctx,
+		`INSERT INTO wallet_transactions ("walletId", "driverId", type, amount, "balanceAfter", reference)
+		 VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''))
+		 RETURNING `+walletTxSelectCols,
+		wallet.ID, driverID, WalletTxPayout, -amount, balance, reference), &t)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// ListWalletTransactions returns the driver's ledger, newest first.
+func ListWalletTransactions(ctx context.Context, driverID string, limit, offset int) ([]models.WalletTransaction, error) {
+	rows, err := db.Pool.Query(ctx,
+		`SELECT `+walletTxSelectCols+` FROM wallet_transactions WHERE "driverId"=$1
+		 ORDER BY "createdAt" DESC LIMIT $2 OFFSET $3`, driverID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	txns := []models.WalletTransaction{}
+	for rows.Next() {
+		var t models.WalletTransaction
+		if err := scanWalletTx(rows, &t); err != nil {
+			return nil, err
+		}
+		txns = append(txns, t)
+	}
+	return txns, rows.Err()
+}
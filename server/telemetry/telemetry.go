@@ -0,0 +1,248 @@
+// Package telemetry exports OpenTelemetry traces. A request's span carries its RequestID and
+// parents the Postgres, Redis and outbound API spans it causes. Tracing stays off (and costs
+// nothing) unless an OTLP endpoint is configured.
+package telemetry
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const defaultServiceName = "ridewave-server"
+
+var (
+	tracer  = otel.Tracer("ridewave")
+	enabled bool
+)
+
+// Enabled reports whether Init configured an exporter.
+func Enabled() bool {
+	return enabled
+}
+
+// Init configures the OTLP/HTTP exporter from the standard OTEL_* variables
+// (OTEL_EXPORTER_OTLP_ENDPOINT or OTEL_EXPORTER_OTLP_TRACES_ENDPOINT, OTEL_EXPORTER_OTLP_HEADERS,
+// OTEL_SERVICE_NAME, OTEL_TRACES_SAMPLER...). The returned func flushes pending spans on shutdown.
+func Init(ctx context.Context) (func(context.Context) error, error) {
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" && os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res, err := resource.New(ctx,
+		resource.WithAttributes(attribute.String("service.name", defaultServiceName)),
+		resource.WithFromEnv(),
+		resource.WithHost(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	enabled = true
+	return provider.Shutdown, nil
+}
+
+// Install wraps the default HTTP transport so every outbound client gets a span.
+func Install() {
+	http.DefaultTransport = Transport{Base: http.DefaultTransport}
+}
+
+// ══════════════════════════════════════════════════
+// Requests
+// ══════════════════════════════════════════════════
+
+// Middleware starts the server span for a request, continuing the caller's trace when it sends
+// a traceparent header. Register it after middleware.RequestID.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		ctx := otel.GetTextMapPropagator().Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+		ctx, span := tracer.Start(ctx, c.Request.Method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", c.Request.Method),
+				attribute.String("http.route", route),
+				attribute.String("request.id", c.GetString("RequestID")),
+			))
+		defer span.End()
+
+		if sc := span.SpanContext(); sc.IsSampled() {
+			c.Header("X-Trace-ID", sc.TraceID().String())
+		}
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(attribute.Int("http.response.status_code", status))
+		if tenantID := c.GetString("tenantId"); tenantID != "" {
+			span.SetAttributes(attribute.String("tenant.id", tenantID))
+		}
+		if status >= 500 {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	}
+}
+
+// ══════════════════════════════════════════════════
+// Hooks
+// ══════════════════════════════════════════════════
+
+// childSpan starts a client span only inside an existing trace, so background workers'
+// polling doesn't flood the exporter with one-query traces.
+func childSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span, bool) {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx, nil, false
+	}
+	ctx, span := tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+	return ctx, span, true
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// PgTracer is a pgx query tracer that records each query as a span. Next, if set, is traced too
+// (pgx takes a single tracer).
+type PgTracer struct {
+	Next pgx.QueryTracer
+}
+
+type pgSpanKey struct{}
+
+func (t PgTracer) TraceQueryStart(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	operation, _, _ := strings.Cut(strings.TrimSpace(data.SQL), " ")
+	if spanCtx, span, ok := childSpan(ctx, "postgres "+strings.ToUpper(operation),
+		attribute.String("db.system", "postgresql"),
+		attribute.String("db.statement", data.SQL),
+	); ok {
+		ctx = context.WithValue(spanCtx, pgSpanKey{}, span)
+	}
+	if t.Next != nil {
+		ctx = t.Next.TraceQueryStart(ctx, conn, data)
+	}
+	return ctx
+}
+
+func (t PgTracer) TraceQueryEnd(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryEndData) {
+	if t.Next != nil {
+		t.Next.TraceQueryEnd(ctx, conn, data)
+	}
+	if span, ok := ctx.Value(pgSpanKey{}).(trace.Span); ok {
+		span.SetAttributes(attribute.Int64("db.rows_affected", data.CommandTag.RowsAffected()))
+		endSpan(span, data.Err)
+	}
+}
+
+// RedisHook is a go-redis hook that records commands and pipelines as spans. Arguments are
+// left out: keys and values can hold phone numbers and tokens.
+type RedisHook struct{}
+
+func (RedisHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (RedisHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		ctx, span, ok := childSpan(ctx, "redis "+cmd.Name(),
+			attribute.String("db.system", "redis"),
+			attribute.String("db.operation", cmd.Name()),
+		)
+		err := next(ctx, cmd)
+		if ok {
+			endSpan(span, ignoreNil(err))
+		}
+		return err
+	}
+}
+
+func (RedisHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		ctx, span, ok := childSpan(ctx, "redis pipeline",
+			attribute.String("db.system", "redis"),
+			attribute.Int("db.redis.commands", len(cmds)),
+		)
+		err := next(ctx, cmds)
+		if ok {
+			endSpan(span, ignoreNil(err))
+		}
+		return err
+	}
+}
+
+// ignoreNil drops redis.Nil: a missing key is a normal cache miss, not a failure.
+func ignoreNil(err error) error {
+	if err == redis.Nil {
+		return nil
+	}
+	return err
+}
+
+// Transport wraps an http.RoundTripper and records outbound API calls (maps, SMS, push, payments).
+// Push sends have no request to hang off, so outbound calls may start their own trace. Only the
+// host and path are recorded; Ola Maps puts its API key in the query string.
+type Transport struct {
+	Base http.RoundTripper
+}
+
+// providers names the outbound APIs the server talks to.
+var providers = map[string]string{
+	"api.olamaps.io":        "OlaMaps",
+	"fcm.googleapis.com":    "FCM",
+	"oauth2.googleapis.com": "GoogleOAuth",
+	"verify.twilio.com":     "Twilio",
+}
+
+func (t Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	provider := providers[req.URL.Hostname()]
+	if provider == "" {
+		provider = req.URL.Hostname()
+	}
+	ctx, span := tracer.Start(req.Context(), provider+" "+req.Method+" "+req.URL.Path,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("http.request.method", req.Method),
+			attribute.String("server.address", req.URL.Hostname()),
+			attribute.String("url.path", req.URL.Path),
+			attribute.String("peer.service", provider),
+		))
+	defer span.End()
+
+	resp, err := t.Base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+	if resp.StatusCode >= 400 {
+		span.SetStatus(codes.Error, resp.Status)
+	}
+	return resp, nil
+}
@@ -0,0 +1,212 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// API versions are mounted side by side under /api/v<N> and share their handlers. The version
+// only changes the shape of what goes in and out: v1 keeps the original envelope, v2 writes
+// {data, meta} or {error, meta} with camelCase keys throughout.
+const (
+	APIv1 = 1
+	APIv2 = 2
+)
+
+// APIVersions are the versions main mounts, oldest first.
+var APIVersions = []int{APIv1, APIv2}
+
+// APIPrefix is the path every route of a version lives under, e.g. /api/v2.
+func APIPrefix(version int) string {
+	return "/api/v" + strconv.Itoa(version)
+}
+
+// APIVersion is the version the request was addressed to, read from its path so that middleware
+// answering before the route's group (rate limits, API keys) replies in the same shape. Paths
+// outside /api/v<N> are v1.
+func APIVersion(c *gin.Context) int {
+	rest, ok := strings.CutPrefix(c.Request.URL.Path, "/api/v")
+	if !ok {
+		return APIv1
+	}
+	end := strings.IndexByte(rest, '/')
+	if end < 0 {
+		end = len(rest)
+	}
+	if v, err := strconv.Atoi(rest[:end]); err == nil && v > APIv1 {
+		return v
+	}
+	return APIv1
+}
+
+// RoutePath is the request's route pattern without its version prefix, e.g. /driver/ride/:id,
+// for code that branches on the route and must work the same under every version.
+func RoutePath(c *gin.Context) string {
+	p := c.FullPath()
+	if rest, ok := strings.CutPrefix(p, "/api/"); ok {
+		if i := strings.IndexByte(rest, '/'); i >= 0 {
+			return rest[i:]
+		}
+	}
+	return p
+}
+
+// Page is a list response built by Paginate: its paging fields plus whatever the handler adds.
+// v2 moves the paging fields into meta.pagination.
+type Page map[string]interface{}
+
+// pageFields are the keys Paginate sets.
+var pageFields = []string{"total", "page", "limit", "totalPages", "hasMore", "nextCursor"}
+
+// split separates a page's paging fields from its data.
+func (p Page) split() (map[string]interface{}, map[string]interface{}) {
+	data, pagination := map[string]interface{}{}, map[string]interface{}{}
+	for k, v := range p {
+		data[k] = v
+	}
+	for _, k := range pageFields {
+		if v, ok := data[k]; ok {
+			pagination[k] = v
+			delete(data, k)
+		}
+	}
+	return data, pagination
+}
+
+// v2Meta is what every v2 response carries besides its data or error.
+type v2Meta struct {
+	RequestID  string                 `json:"requestId,omitempty"`
+	Message    string                 `json:"message,omitempty"`
+	Pagination map[string]interface{} `json:"pagination,omitempty"`
+}
+
+type v2Error struct {
+	Code    string      `json:"code"`
+	Message string      `json:"message"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+func respondV2Success(c *gin.Context, status int, message string, data interface{}) {
+	meta := v2Meta{RequestID: c.GetString("RequestID"), Message: message}
+	if page, ok := data.(Page); ok {
+		data, meta.Pagination = page.split()
+	}
+	c.JSON(status, gin.H{"data": camelCaseJSON(data), "meta": meta})
+}
+
+func respondV2Error(c *gin.Context, status int, code, message string, details interface{}) {
+	c.JSON(status, gin.H{
+		"error": v2Error{Code: code, Message: message, Details: camelCaseJSON(details)},
+		"meta":  v2Meta{RequestID: c.GetString("RequestID")},
+	})
+}
+
+// camelCaseJSON re-encodes v as generic JSON with every snake_case object key in camelCase, so
+// v2 stays consistent where v1 leaks model tags (phone_number) or provider fields (place_id).
+func camelCaseJSON(v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var generic interface{}
+	if dec.Decode(&generic) != nil {
+		return v
+	}
+	return camelCaseKeys(generic)
+}
+
+func camelCaseKeys(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(t))
+		for k, val := range t {
+			out[snakeToCamel(k)] = camelCaseKeys(val)
+		}
+		return out
+	case []interface{}:
+		for i := range t {
+			t[i] = camelCaseKeys(t[i])
+		}
+		return t
+	}
+	return v
+}
+
+// snakeToCamel turns phone_number into phoneNumber. Keys that aren't lowercase snake_case, such
+// as IDs and dates used as map keys, are returned unchanged.
+func snakeToCamel(s string) string {
+	if !strings.Contains(s, "_") || strings.HasPrefix(s, "_") || strings.HasSuffix(s, "_") {
+		return s
+	}
+	var b strings.Builder
+	upper := false
+	for _, r := range s {
+		switch {
+		case r == '_':
+			upper = true
+		case r >= 'a' && r <= 'z' || r >= '0' && r <= '9':
+			if upper && r >= 'a' && r <= 'z' {
+				r -= 'a' - 'A'
+			}
+			b.WriteRune(r)
+			upper = false
+		default:
+			return s
+		}
+	}
+	return b.String()
+}
+
+// legacyRequestFields are the request body fields v1 takes in snake_case. v2 clients send them
+// in camelCase and CamelCaseRequestBody renames them before the handler binds.
+var legacyRequestFields = []string{
+	"phone_number", "phone_numbers", "round_trip", "vehicle_type", "vehicle_color",
+	"registration_number", "registration_date", "driving_license", "rc_book", "profile_image",
+}
+
+// CamelCaseRequestBody renames the camelCase form of legacyRequestFields in a JSON object body
+// to the snake_case the shared handlers bind. Other bodies pass through untouched.
+func CamelCaseRequestBody(c *gin.Context) {
+	if c.Request.Body == nil || !strings.HasPrefix(c.ContentType(), gin.MIMEJSON) {
+		return
+	}
+	raw, err := io.ReadAll(c.Request.Body)
+	c.Request.Body.Close()
+	c.Request.Body = io.NopCloser(bytes.NewReader(raw))
+	if err != nil {
+		return
+	}
+	var body map[string]json.RawMessage
+	if json.Unmarshal(raw, &body) != nil {
+		return
+	}
+	renamed := false
+	for _, field := range legacyRequestFields {
+		camel := snakeToCamel(field)
+		if v, ok := body[camel]; ok {
+			if _, taken := body[field]; !taken {
+				body[field] = v
+			}
+			delete(body, camel)
+			renamed = true
+		}
+	}
+	if !renamed {
+		return
+	}
+	if raw, err = json.Marshal(body); err == nil {
+		c.Request.Body = io.NopCloser(bytes.NewReader(raw))
+		c.Request.ContentLength = int64(len(raw))
+		c.Request.Header.Set("Content-Length", strconv.Itoa(len(raw)))
+	}
+}
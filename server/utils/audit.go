@@ -5,29 +5,50 @@ import (
 	"encoding/json"
 	"ridewave/db"
 	"ridewave/models"
+	"time"
 
 	"go.uber.org/zap"
 )
 
+const externalAPILogWrite = "external_api_log"
+
+func init() {
+	RegisterRetryableWrite(externalAPILogWrite, func(ctx context.Context, payload json.RawMessage) error {
+		var log models.APILog
+		if err := json.Unmarshal(payload, &log); err != nil {
+			return err
+		}
+		return insertExternalAPILog(ctx, log)
+	})
+}
+
 // LogExternalAPI records a request/response pair from an external provider (like Ola Maps)
 // for auditing and to keep the main rides table lightweight.
 func LogExternalAPI(log models.APILog) {
+	if log.CreatedAt.IsZero() {
+		log.CreatedAt = time.Now()
+	}
 	// Background execution using SafeGo to track for graceful shutdown
 	SafeGo(func() {
-		reqJSON, _ := json.Marshal(log.RequestPayload)
-		respJSON, _ := json.Marshal(log.ResponsePayload)
-
-		_, err := db.Pool.Exec(context.Background(),
-			`INSERT INTO external_api_logs (
-				id, provider, endpoint, "requestId", "requestPayload", "responsePayload", "statusCode", "durationMs", "createdAt"
-			) VALUES (
-				gen_random_uuid()::text, $1, $2, $3, $4, $5, $6, $7, NOW()
-			)`,
-			log.Provider, log.Endpoint, log.RequestID, reqJSON, respJSON, log.StatusCode, log.DurationMs,
-		)
-
-		if err != nil {
-			Logger.Error("Failed to log external API call", zap.Error(err))
+		if err := insertExternalAPILog(context.Background(), log); err != nil {
+			// Keep the record for the retry worker rather than losing it to a DB blip
+			Logger.Error("Failed to log external API call, queued for retry", zap.Error(err))
+			QueueFailedWrite(externalAPILogWrite, log, err)
 		}
 	})
 }
+
+func insertExternalAPILog(ctx context.Context, log models.APILog) error {
+	reqJSON, _ := json.Marshal(log.RequestPayload)
+	respJSON, _ := json.Marshal(log.ResponsePayload)
+
+	_, err := db.Pool.Exec(ctx,
+		`INSERT INTO external_api_logs (
+			id, provider, endpoint, "requestId", "requestPayload", "responsePayload", "statusCode", "durationMs", "createdAt"
+		) VALUES (
+			gen_random_uuid()::text, $1, $2, $3, $4, $5, $6, $7, $8
+		)`,
+		log.Provider, log.Endpoint, log.RequestID, reqJSON, respJSON, log.StatusCode, log.DurationMs, log.CreatedAt,
+	)
+	return err
+}
@@ -2,7 +2,9 @@ package utils
 
 import (
 	"context"
+	"os"
 	"ridewave/db"
+	"strconv"
 	"time"
 
 	"go.uber.org/zap"
@@ -43,4 +45,43 @@ func runCleanup() {
 
 	rowsAffected := result.RowsAffected()
 	Logger.Info("Audit Log Cleanup Completed", zap.Int64("deletedRows", rowsAffected))
+
+	// Driver location traces: LOCATION_HISTORY_RETENTION_DAYS, default 90
+	days := 90
+	if v, err := strconv.Atoi(os.Getenv("LOCATION_HISTORY_RETENTION_DAYS")); err == nil && v > 0 {
+		days = v
+	}
+	result, err = db.Pool.Exec(context.Background(),
+		`DELETE FROM driver_location_history WHERE "recordedAt" < $1`, time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		Logger.Error("Location History Cleanup Failed", zap.Error(err))
+		return
+	}
+	Logger.Info("Location History Cleanup Completed", zap.Int64("deletedRows", result.RowsAffected()))
+
+	// Delivered notifications: NOTIFICATION_OUTBOX_RETENTION_DAYS, default 7. Dead ones stay for review.
+	days = 7
+	if v, err := strconv.Atoi(os.Getenv("NOTIFICATION_OUTBOX_RETENTION_DAYS")); err == nil && v > 0 {
+		days = v
+	}
+	result, err = db.Pool.Exec(context.Background(),
+		`DELETE FROM notifications_outbox WHERE status IN ('sent', 'skipped') AND "createdAt" < $1`, time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		Logger.Error("Notifications Outbox Cleanup Failed", zap.Error(err))
+		return
+	}
+	Logger.Info("Notifications Outbox Cleanup Completed", zap.Int64("deletedRows", result.RowsAffected()))
+
+	// Booking funnel estimates: RIDE_ESTIMATE_RETENTION_DAYS, default 180
+	days = 180
+	if v, err := strconv.Atoi(os.Getenv("RIDE_ESTIMATE_RETENTION_DAYS")); err == nil && v > 0 {
+		days = v
+	}
+	result, err = db.Pool.Exec(context.Background(),
+		`DELETE FROM ride_estimates WHERE "createdAt" < $1`, time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		Logger.Error("Ride Estimates Cleanup Failed", zap.Error(err))
+		return
+	}
+	Logger.Info("Ride Estimates Cleanup Completed", zap.Int64("deletedRows", result.RowsAffected()))
 }
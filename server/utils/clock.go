@@ -0,0 +1,23 @@
+package utils
+
+import (
+	"fmt"
+	"time"
+)
+
+// ParseClock parses an "HH:MM" time of day into minutes past midnight.
+func ParseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// InClockWindow reports whether minute falls in [from, until), which may wrap midnight (22:00–06:00).
+func InClockWindow(minute, from, until int) bool {
+	if from <= until {
+		return minute >= from && minute < until
+	}
+	return minute >= from || minute < until
+}
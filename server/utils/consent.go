@@ -0,0 +1,116 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"ridewave/db"
+
+	"go.uber.org/zap"
+)
+
+const (
+	ChannelSMS      = "sms"
+	ChannelWhatsApp = "whatsapp"
+	ChannelEmail    = "email"
+
+	ConsentOptedIn  = "opted_in"
+	ConsentOptedOut = "opted_out"
+)
+
+var ErrNoMarketingConsent = errors.New("user has not consented to promotional messages on this channel")
+
+// IsMarketingChannel reports whether channel is one we track consent for.
+func IsMarketingChannel(channel string) bool {
+	return channel == ChannelSMS || channel == ChannelWhatsApp || channel == ChannelEmail
+}
+
+// RecordConsent sets the user's current consent for a channel and appends the change to the compliance log.
+func RecordConsent(userID, channel, status, source, ipAddress string) error {
+	ctx := context.Background()
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx,
+		`INSERT INTO marketing_consent ("userId", channel, status, source, "updatedAt")
+		 VALUES ($1, $2, $3, $4, NOW())
+		 ON CONFLICT ("userId", channel) DO UPDATE SET status=EXCLUDED.status, source=EXCLUDED.source, "updatedAt"=NOW()`,
+		userID, channel, status, source)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx,
+		`INSERT INTO marketing_consent_log ("userId", channel, status, source, "ipAddress")
+		 VALUES ($1, $2, $3, $4, NULLIF($5, ''))`,
+		userID, channel, status, source, ipAddress)
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// HasMarketingConsent is true only for an explicit opt-in; no record means no consent.
+func HasMarketingConsent(userID, channel string) bool {
+	var status string
+	err := db.Pool.QueryRow(context.Background(),
+		`SELECT status FROM marketing_consent WHERE "userId"=$1 AND channel=$2`, userID, channel).Scan(&status)
+	return err == nil && status == ConsentOptedIn
+}
+
+// SendPromotional delivers a marketing message on one channel, refusing if the user hasn't opted in
+// or has turned promotions off. Transactional messages (OTPs, ride updates) must not go through here.
+func SendPromotional(userID, channel, to, subject, body string) error {
+	if !HasMarketingConsent(userID, channel) {
+		Logger.Info("Promotional message suppressed (no consent)", zap.String("userId", userID), zap.String("channel", channel))
+		return ErrNoMarketingConsent
+	}
+	recipient := Recipient{Type: "user", ID: userID, Category: NotifyPromotions}
+	if !NotificationAllowed(context.Background(), recipient, channel) {
+		return ErrNotificationMuted
+	}
+
+	switch channel {
+	case ChannelEmail:
+		return SendEmail(recipient, []string{to}, subject, body)
+	case ChannelSMS:
+		return sendTwilioMessage(os.Getenv("TWILIO_SMS_FROM"), to, body)
+	case ChannelWhatsApp:
+		return sendTwilioMessage("whatsapp:"+os.Getenv("TWILIO_WHATSAPP_FROM"), "whatsapp:"+to, body)
+	}
+	return fmt.Errorf("unknown channel %q", channel)
+}
+
+// sendTwilioMessage sends a plain message via the Twilio Messaging API
+func sendTwilioMessage(from, to, body string) error {
+	accountSid := os.Getenv("TWILIO_ACCOUNT_SID")
+	authToken := os.Getenv("TWILIO_AUTH_TOKEN")
+
+	if accountSid == "" || authToken == "" || from == "" {
+		return fmt.Errorf("twilio messaging not configured")
+	}
+
+	endpoint := fmt.Sprintf("https://api.twilio.com/2010-04-01/Accounts/%s/Messages.json", accountSid)
+	data := url.Values{"From": {from}, "To": {to}, "Body": {body}}
+
+	req, _ := http.NewRequest("POST", endpoint, bytes.NewBufferString(data.Encode()))
+	req.SetBasicAuth(accountSid, authToken)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("twilio error: %s", resp.Status)
+	}
+	return nil
+}
@@ -1,12 +1,30 @@
 package utils
 
 import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 	"net/smtp"
 	"os"
 )
 
-func SendEmail(to []string, subject, body string) error {
+// EmailAttachment is a file sent along with an email.
+type EmailAttachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// SendEmail sends an HTML email to the given addresses. An email in a category the recipient has
+// turned off returns ErrNotificationMuted.
+func SendEmail(recipient Recipient, to []string, subject, body string, attachments ...EmailAttachment) error {
+	if !NotificationAllowed(context.Background(), recipient, ChannelEmail) {
+		return ErrNotificationMuted
+	}
+
 	from := os.Getenv("SMTP_USER")
 	password := os.Getenv("SMTP_PASS")
 	host := os.Getenv("SMTP_HOST")
@@ -16,12 +34,16 @@ func SendEmail(to []string, subject, body string) error {
 
 	// Basic email headers
 	headers := "MIME-Version: 1.0\r\n" +
-		"Content-Type: text/html; charset=UTF-8\r\n" +
 		fmt.Sprintf("From: RideWave <%s>\r\n", from) +
 		fmt.Sprintf("To: %s\r\n", to[0]) +
-		fmt.Sprintf("Subject: %s\r\n\r\n", subject)
+		fmt.Sprintf("Subject: %s\r\n", subject)
 
-	msg := []byte(headers + body)
+	var msg []byte
+	if len(attachments) == 0 {
+		msg = []byte(headers + "Content-Type: text/html; charset=UTF-8\r\n\r\n" + body)
+	} else {
+		msg = append([]byte(headers), multipartBody(body, attachments)...)
+	}
 
 	addr := fmt.Sprintf("%s:%s", host, port)
 	
@@ -30,3 +52,26 @@ func SendEmail(to []string, subject, body string) error {
 	}
 	return nil
 }
+
+// multipartBody wraps the HTML body and attachments in a multipart/mixed message.
+func multipartBody(html string, attachments []EmailAttachment) []byte {
+	b := make([]byte, 12)
+	rand.Read(b)
+	boundary := "ridewave-" + hex.EncodeToString(b)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "Content-Type: multipart/mixed; boundary=%q\r\n\r\n", boundary)
+	fmt.Fprintf(&buf, "--%s\r\nContent-Type: text/html; charset=UTF-8\r\n\r\n%s\r\n", boundary, html)
+	for _, a := range attachments {
+		fmt.Fprintf(&buf, "--%s\r\nContent-Type: %s\r\nContent-Transfer-Encoding: base64\r\n", boundary, a.ContentType)
+		fmt.Fprintf(&buf, "Content-Disposition: attachment; filename=%q\r\n\r\n", a.Filename)
+		encoded := base64.StdEncoding.EncodeToString(a.Data)
+		for len(encoded) > 76 {
+			buf.WriteString(encoded[:76] + "\r\n")
+			encoded = encoded[76:]
+		}
+		buf.WriteString(encoded + "\r\n")
+	}
+	fmt.Fprintf(&buf, "--%s--\r\n", boundary)
+	return buf.Bytes()
+}
@@ -0,0 +1,128 @@
+package utils
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// Machine-readable error codes sent as "code" in every error response, so clients can branch on
+// them instead of parsing messages. Messages may be reworded at any time; codes are stable.
+// Errors without a specific code get the generic one for their HTTP status.
+const (
+	// Generic, by HTTP status
+	CodeBadRequest         = "BAD_REQUEST"
+	CodeValidationFailed   = "VALIDATION_FAILED" // details.fields names each bad field and the rule it broke
+	CodeUnauthorized       = "UNAUTHORIZED"
+	CodeForbidden          = "FORBIDDEN"
+	CodeNotFound           = "NOT_FOUND"
+	CodeConflict           = "CONFLICT"
+	CodeGone               = "GONE"
+	CodePayloadTooLarge    = "PAYLOAD_TOO_LARGE"
+	CodeWrongRegion        = "WRONG_REGION"
+	CodeUnprocessable      = "UNPROCESSABLE"
+	CodeRateLimited        = "RATE_LIMITED"
+	CodeInternal           = "INTERNAL_ERROR"
+	CodeUpstream           = "UPSTREAM_ERROR"
+	CodeServiceUnavailable = "SERVICE_UNAVAILABLE"
+	CodeTimeout            = "TIMEOUT"
+
+	// Authentication and accounts
+	CodeAuthRequired       = "AUTH_REQUIRED"
+	CodeTokenInvalid       = "TOKEN_INVALID"
+	CodeSessionEnded       = "SESSION_ENDED"
+	CodeAccountSuspended   = "ACCOUNT_SUSPENDED"
+	CodeAccountDeactivated = "ACCOUNT_DEACTIVATED"
+	CodeAccountDeleted     = "ACCOUNT_DELETED"
+	CodeAccountPending     = "ACCOUNT_PENDING_APPROVAL"
+	CodeAccountRejected    = "ACCOUNT_REJECTED"
+	CodeTenantInactive     = "TENANT_INACTIVE"
+	CodeOTPInvalid         = "OTP_INVALID"
+	CodeOTPExpired         = "OTP_EXPIRED"
+	CodeOTPAttempts        = "OTP_ATTEMPTS_EXCEEDED"
+	CodePhoneInvalid       = "PHONE_INVALID"
+
+	// Booking and rides
+	CodeRouteExpired       = "ROUTE_EXPIRED" // the routeId's estimate is gone; get a fresh estimate
+	CodeRouteChanged       = "ROUTE_CHANGED"
+	CodeZoneNotServed      = "ZONE_NOT_SERVED"
+	CodeVehicleUnavailable = "VEHICLE_TYPE_UNAVAILABLE"
+	CodeNoDrivers          = "NO_DRIVERS_AVAILABLE"
+	CodePromoInvalid       = "PROMO_INVALID"
+	CodeRideNotFound       = "RIDE_NOT_FOUND"
+	CodeRideExpired        = "RIDE_EXPIRED" // the ride or fare request is no longer open
+	CodeRideTaken          = "RIDE_TAKEN"
+	CodeRideTransition     = "RIDE_INVALID_TRANSITION" // details: from, to
+	CodeRideBusy           = "RIDE_BUSY"
+
+	// Drivers
+	CodeDriverOffline       = "DRIVER_OFFLINE"
+	CodeDriverOnTrip        = "DRIVER_ON_TRIP"
+	CodeVehicleNotApproved  = "VEHICLE_NOT_APPROVED"
+	CodeRegistrationTaken   = "REGISTRATION_NUMBER_TAKEN"
+	CodeInsufficientBalance = "INSUFFICIENT_BALANCE"
+
+	// Requests
+	CodeInvalidCursor        = "INVALID_CURSOR"
+	CodeIdempotencyPending   = "IDEMPOTENCY_IN_PROGRESS"
+	CodeIdempotencyKeyReused = "IDEMPOTENCY_KEY_REUSED"
+)
+
+// statusCodes is the generic code of each HTTP status.
+var statusCodes = map[int]string{
+	http.StatusBadRequest:            CodeBadRequest,
+	http.StatusUnauthorized:          CodeUnauthorized,
+	http.StatusForbidden:             CodeForbidden,
+	http.StatusNotFound:              CodeNotFound,
+	http.StatusConflict:              CodeConflict,
+	http.StatusGone:                  CodeGone,
+	http.StatusRequestEntityTooLarge: CodePayloadTooLarge,
+	http.StatusMisdirectedRequest:    CodeWrongRegion,
+	http.StatusUnprocessableEntity:   CodeUnprocessable,
+	http.StatusTooManyRequests:       CodeRateLimited,
+	http.StatusInternalServerError:   CodeInternal,
+	http.StatusBadGateway:            CodeUpstream,
+	http.StatusServiceUnavailable:    CodeServiceUnavailable,
+	http.StatusGatewayTimeout:        CodeTimeout,
+}
+
+// codeForStatus is the generic code of an HTTP status.
+func codeForStatus(status int) string {
+	if code, ok := statusCodes[status]; ok {
+		return code
+	}
+	if status >= 500 {
+		return CodeInternal
+	}
+	return CodeBadRequest
+}
+
+// Binding errors name fields as clients send them (the json tag), not as Go spells them.
+func init() {
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(func(f reflect.StructField) string {
+			name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if name == "" || name == "-" {
+				return f.Name
+			}
+			return name
+		})
+	}
+}
+
+// validationDetails turns a binding failure into {"fields": {"rideId": "required"}}.
+func validationDetails(err error) (map[string]any, bool) {
+	var verrs validator.ValidationErrors
+	if !errors.As(err, &verrs) {
+		return nil, false
+	}
+	fields := map[string]string{}
+	for _, fe := range verrs {
+		fields[fe.Field()] = fe.Tag()
+	}
+	return map[string]any{"fields": fields}, true
+}
@@ -0,0 +1,172 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"time"
+)
+
+// PhotoMetadata is what ReadJPEGExif could find in a photo's EXIF block.
+type PhotoMetadata struct {
+	Lat, Lng *float64
+	TakenAt  *time.Time // camera local time, read as UTC (EXIF has no zone)
+}
+
+var errNoExif = errors.New("no EXIF data")
+
+// ReadJPEGExif extracts the GPS position and capture time from a JPEG's EXIF block.
+// It only reads the handful of tags it needs; anything malformed returns an error.
+func ReadJPEGExif(data []byte) (*PhotoMetadata, error) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, errors.New("not a JPEG")
+	}
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			return nil, errNoExif
+		}
+		marker := data[i+1]
+		size := int(binary.BigEndian.Uint16(data[i+2:]))
+		if marker == 0xDA || size < 2 || i+2+size > len(data) {
+			return nil, errNoExif // start of scan: no more metadata segments
+		}
+		segment := data[i+4 : i+2+size]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return parseTIFF(segment[6:])
+		}
+		i += 2 + size
+	}
+	return nil, errNoExif
+}
+
+type tiffReader struct {
+	data  []byte
+	order binary.ByteOrder
+}
+
+type ifdEntry struct {
+	tag, kind uint16
+	count     uint32
+	value     []byte // the 4-byte value field: inline data or an offset
+}
+
+func parseTIFF(data []byte) (*PhotoMetadata, error) {
+	if len(data) < 8 {
+		return nil, errNoExif
+	}
+	r := &tiffReader{data: data}
+	switch string(data[:2]) {
+	case "II":
+		r.order = binary.LittleEndian
+	case "MM":
+		r.order = binary.BigEndian
+	default:
+		return nil, errors.New("bad TIFF header")
+	}
+
+	ifd0, err := r.entries(r.order.Uint32(data[4:]))
+	if err != nil {
+		return nil, err
+	}
+	meta := &PhotoMetadata{}
+	for _, e := range ifd0 {
+		switch e.tag {
+		case 0x8825: // GPS IFD
+			if gps, err := r.entries(r.order.Uint32(e.value)); err == nil {
+				meta.Lat, meta.Lng = r.gpsPosition(gps)
+			}
+		case 0x8769: // Exif IFD
+			if exif, err := r.entries(r.order.Uint32(e.value)); err == nil {
+				for _, x := range exif {
+					if x.tag == 0x9003 { // DateTimeOriginal
+						if t, err := time.Parse("2006:01:02 15:04:05", r.ascii(x)); err == nil {
+							meta.TakenAt = &t
+						}
+					}
+				}
+			}
+		}
+	}
+	return meta, nil
+}
+
+func (r *tiffReader) entries(offset uint32) ([]ifdEntry, error) {
+	if int(offset)+2 > len(r.data) {
+		return nil, errNoExif
+	}
+	n := int(r.order.Uint16(r.data[offset:]))
+	start := int(offset) + 2
+	if start+n*12 > len(r.data) {
+		return nil, errNoExif
+	}
+	entries := make([]ifdEntry, n)
+	for i := range entries {
+		b := r.data[start+i*12:]
+		entries[i] = ifdEntry{tag: r.order.Uint16(b), kind: r.order.Uint16(b[2:]), count: r.order.Uint32(b[4:]), value: b[8:12]}
+	}
+	return entries, nil
+}
+
+// bytes returns an entry's payload, following the offset when it doesn't fit inline.
+func (r *tiffReader) bytes(e ifdEntry, size int) []byte {
+	if size <= 4 {
+		return e.value[:size]
+	}
+	off := int(r.order.Uint32(e.value))
+	if off+size > len(r.data) {
+		return nil
+	}
+	return r.data[off : off+size]
+}
+
+func (r *tiffReader) ascii(e ifdEntry) string {
+	return string(bytes.TrimRight(r.bytes(e, int(e.count)), "\x00 "))
+}
+
+// degrees reads three RATIONALs (degrees, minutes, seconds) as decimal degrees.
+func (r *tiffReader) degrees(e ifdEntry) (float64, bool) {
+	if e.kind != 5 || e.count != 3 {
+		return 0, false
+	}
+	b := r.bytes(e, 24)
+	if b == nil {
+		return 0, false
+	}
+	var parts [3]float64
+	for i := range parts {
+		num, den := r.order.Uint32(b[i*8:]), r.order.Uint32(b[i*8+4:])
+		if den == 0 {
+			return 0, false
+		}
+		parts[i] = float64(num) / float64(den)
+	}
+	return parts[0] + parts[1]/60 + parts[2]/3600, true
+}
+
+func (r *tiffReader) gpsPosition(gps []ifdEntry) (*float64, *float64) {
+	var lat, lng float64
+	var latRef, lngRef string
+	var hasLat, hasLng bool
+	for _, e := range gps {
+		switch e.tag {
+		case 1:
+			latRef = r.ascii(e)
+		case 2:
+			lat, hasLat = r.degrees(e)
+		case 3:
+			lngRef = r.ascii(e)
+		case 4:
+			lng, hasLng = r.degrees(e)
+		}
+	}
+	if !hasLat || !hasLng {
+		return nil, nil
+	}
+	if latRef == "S" {
+		lat = -lat
+	}
+	if lngRef == "W" {
+		lng = -lng
+	}
+	return &lat, &lng
+}
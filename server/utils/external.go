@@ -63,4 +63,13 @@ func VerifyTwilioOTP(phoneNumber, code string) error {
 		return fmt.Errorf("twilio verification failed: %s", resp.Status)
 	}
 	return nil
-}
\ No newline at end of file
+}
+// SMSConfigured reports whether SendSMS has a Twilio account and sender to use.
+func SMSConfigured() bool {
+	return os.Getenv("TWILIO_ACCOUNT_SID") != "" && os.Getenv("TWILIO_AUTH_TOKEN") != "" && os.Getenv("TWILIO_SMS_FROM") != ""
+}
+
+// SendSMS sends a transactional text message from TWILIO_SMS_FROM
+func SendSMS(to, body string) error {
+	return sendTwilioMessage(os.Getenv("TWILIO_SMS_FROM"), to, body)
+}
@@ -2,114 +2,357 @@ package utils
 
 import (
 	"bytes"
+	"context"
+	"crypto/rsa"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
 	"os"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/golang-jwt/jwt/v5"
 	"go.uber.org/zap"
+	"ridewave/db"
 )
 
-// FCM HTTP API (Legacy) — simple and dependency-free
-// Uses the server key from Firebase Console > Project Settings > Cloud Messaging
+// FCM HTTP v1 API — dependency-free, authenticated with a Firebase service account.
+// Set FCM_SERVICE_ACCOUNT_JSON to the key file's contents, or FCM_SERVICE_ACCOUNT_FILE
+// (falling back to GOOGLE_APPLICATION_CREDENTIALS) to its path. FCM_PROJECT_ID overrides the
+// key's project_id.
 
-type FCMNotification struct {
+const (
+	fcmScope         = "https://www.googleapis.com/auth/firebase.messaging"
+	fcmSendTimeout   = 10 * time.Second
+	fcmMaxConcurrent = 20 // parallel sends per multicast; v1 has no batch endpoint
+)
+
+type FCMData map[string]string
+
+type fcmNotification struct {
 	Title string `json:"title"`
 	Body  string `json:"body"`
-	Sound string `json:"sound,omitempty"`
 }
 
-type FCMData map[string]string
-
-type FCMMessage struct {
-	To           string           `json:"to,omitempty"`
-	Notification *FCMNotification `json:"notification,omitempty"`
+// fcmMessage is a v1 message to one device, with high priority and the default sound on both platforms.
+type fcmMessage struct {
+	Token        string           `json:"token,omitempty"`
+	Topic        string           `json:"topic,omitempty"`
+	Notification *fcmNotification `json:"notification,omitempty"`
 	Data         FCMData          `json:"data,omitempty"`
-	Priority     string           `json:"priority,omitempty"`
+	Android      any              `json:"android,omitempty"`
+	APNS         any              `json:"apns,omitempty"`
 }
 
-type FCMMulticastMessage struct {
-	RegistrationIDs []string         `json:"registration_ids"`
-	Notification    *FCMNotification `json:"notification,omitempty"`
-	Data            FCMData          `json:"data,omitempty"`
-	Priority        string           `json:"priority,omitempty"`
+func newFCMMessage(token, title, body string, data FCMData) fcmMessage {
+	return fcmMessage{
+		Token:        token,
+		Notification: &fcmNotification{Title: title, Body: body},
+		Data:         data,
+		Android:      map[string]any{"priority": "HIGH", "notification": map[string]any{"sound": "default"}},
+		APNS: map[string]any{
+			"headers": map[string]string{"apns-priority": "10"},
+			"payload": map[string]any{"aps": map[string]any{"sound": "default"}},
+		},
+	}
 }
 
-// SendPushNotification sends a push notification to a single device token
-func SendPushNotification(token string, title, body string, data FCMData) error {
-	serverKey := os.Getenv("FCM_SERVER_KEY")
-	if serverKey == "" {
-		Logger.Warn("FCM_SERVER_KEY not set, skipping push notification")
-		return nil
-	}
-	if token == "" {
-		return nil
-	}
+type fcmServiceAccount struct {
+	ProjectID   string `json:"project_id"`
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+}
 
-	msg := FCMMessage{
-		To: token,
-		Notification: &FCMNotification{
-			Title: title,
-			Body:  body,
-			Sound: "default",
-		},
-		Data:     data,
-		Priority: "high",
-	}
+type fcmClient struct {
+	account fcmServiceAccount
+	key     *rsa.PrivateKey
 
-	return sendFCM(serverKey, msg)
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
 }
 
-// SendPushToMultiple sends push notifications to multiple device tokens (max 1000)
-func SendPushToMultiple(tokens []string, title, body string, data FCMData) error {
-	serverKey := os.Getenv("FCM_SERVER_KEY")
-	if serverKey == "" {
-		Logger.Warn("FCM_SERVER_KEY not set, skipping push notifications")
-		return nil
+var (
+	fcmOnce    sync.Once
+	fcm        *fcmClient
+	fcmLoadErr error
+)
+
+// FCMConfigured reports whether a service account is set for push notifications.
+func FCMConfigured() bool {
+	return os.Getenv("FCM_SERVICE_ACCOUNT_JSON") != "" || os.Getenv("FCM_SERVICE_ACCOUNT_FILE") != "" ||
+		os.Getenv("GOOGLE_APPLICATION_CREDENTIALS") != ""
+}
+
+// fcmFromEnv loads the service account once. Returns nil when none is configured.
+func fcmFromEnv() (*fcmClient, error) {
+	fcmOnce.Do(func() {
+		if !FCMConfigured() {
+			return
+		}
+		raw := []byte(os.Getenv("FCM_SERVICE_ACCOUNT_JSON"))
+		if len(raw) == 0 {
+			path := os.Getenv("FCM_SERVICE_ACCOUNT_FILE")
+			if path == "" {
+				path = os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+			}
+			if raw, fcmLoadErr = os.ReadFile(path); fcmLoadErr != nil {
+				return
+			}
+		}
+
+		var account fcmServiceAccount
+		if fcmLoadErr = json.Unmarshal(raw, &account); fcmLoadErr != nil {
+			fcmLoadErr = fmt.Errorf("invalid FCM service account: %w", fcmLoadErr)
+			return
+		}
+		if projectID := os.Getenv("FCM_PROJECT_ID"); projectID != "" {
+			account.ProjectID = projectID
+		}
+		if account.TokenURI == "" {
+			account.TokenURI = "https://oauth2.googleapis.com/token"
+		}
+		if account.ProjectID == "" || account.ClientEmail == "" {
+			fcmLoadErr = errors.New("FCM service account is missing project_id or client_email")
+			return
+		}
+		key, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(account.PrivateKey))
+		if err != nil {
+			fcmLoadErr = fmt.Errorf("invalid FCM service account private key: %w", err)
+			return
+		}
+		fcm = &fcmClient{account: account, key: key}
+	})
+	return fcm, fcmLoadErr
+}
+
+// accessToken returns a cached OAuth token, exchanging a signed assertion for a new one shortly
+// before the old one expires.
+func (f *fcmClient) accessToken(ctx context.Context) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.token != "" && time.Now().Before(f.expiry.Add(-time.Minute)) {
+		return f.token, nil
 	}
-	if len(tokens) == 0 {
-		return nil
+
+	now := time.Now()
+	assertion, err := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":   f.account.ClientEmail,
+		"scope": fcmScope,
+		"aud":   f.account.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	}).SignedString(f.key)
+	if err != nil {
+		return "", err
 	}
 
-	msg := FCMMulticastMessage{
-		RegistrationIDs: tokens,
-		Notification: &FCMNotification{
-			Title: title,
-			Body:  body,
-			Sound: "default",
-		},
-		Data:     data,
-		Priority: "high",
+	form := url.Values{"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"}, "assertion": {assertion}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, f.account.TokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("FCM token exchange failed: %s: %s", resp.Status, body)
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
 	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", err
+	}
+	f.token, f.expiry = token.AccessToken, now.Add(time.Duration(token.ExpiresIn)*time.Second)
+	return f.token, nil
+}
+
+// fcmSendError is a rejected send. ErrorCode is FCM's code (UNREGISTERED, INVALID_ARGUMENT,
+// QUOTA_EXCEEDED...), Status the HTTP status.
+type fcmSendError struct {
+	Status    int
+	ErrorCode string
+	Message   string
+}
+
+func (e *fcmSendError) Error() string {
+	return fmt.Sprintf("FCM error %d %s: %s", e.Status, e.ErrorCode, e.Message)
+}
 
-	return sendFCM(serverKey, msg)
+// staleToken reports whether the device token itself was rejected (app uninstalled, token
+// rotated or malformed), as opposed to a problem with the message or the service.
+func (e *fcmSendError) staleToken() bool {
+	return e.ErrorCode == "UNREGISTERED" ||
+		(e.ErrorCode == "INVALID_ARGUMENT" && strings.Contains(strings.ToLower(e.Message), "registration token"))
 }
 
-func sendFCM(serverKey string, payload interface{}) error {
-	jsonData, err := json.Marshal(payload)
+func (f *fcmClient) send(ctx context.Context, msg fcmMessage, validateOnly bool) error {
+	token, err := f.accessToken(ctx)
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(map[string]any{"message": msg, "validate_only": validateOnly})
 	if err != nil {
 		return err
 	}
 
-	req, err := http.NewRequest("POST", "https://fcm.googleapis.com/fcm/send", bytes.NewBuffer(jsonData))
+	endpoint := "https://fcm.googleapis.com/v1/projects/" + url.PathEscape(f.account.ProjectID) + "/messages:send"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
 	if err != nil {
 		return err
 	}
-	req.Header.Set("Authorization", "key="+serverKey)
+	req.Header.Set("Authorization", "Bearer "+token)
 	req.Header.Set("Content-Type", "application/json")
 
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		Logger.Error("FCM request failed", zap.Error(err))
 		return err
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode < 400 {
+		return nil
+	}
 
-	if resp.StatusCode >= 400 {
-		Logger.Error("FCM error", zap.Int("status", resp.StatusCode))
-		return fmt.Errorf("FCM error: %s", resp.Status)
+	var body struct {
+		Error struct {
+			Message string `json:"message"`
+			Status  string `json:"status"`
+			Details []struct {
+				Type      string `json:"@type"`
+				ErrorCode string `json:"errorCode"`
+			} `json:"details"`
+		} `json:"error"`
+	}
+	json.NewDecoder(io.LimitReader(resp.Body, 64*1024)).Decode(&body)
+	sendErr := &fcmSendError{Status: resp.StatusCode, ErrorCode: body.Error.Status, Message: body.Error.Message}
+	for _, d := range body.Error.Details {
+		if strings.HasSuffix(d.Type, "google.firebase.fcm.v1.FcmError") && d.ErrorCode != "" {
+			sendErr.ErrorCode = d.ErrorCode
+		}
+	}
+	return sendErr
+}
+
+// clearStaleToken removes a device token FCM no longer accepts, so it isn't pushed to again.
+func clearStaleToken(token string) {
+	ctx := db.Unscoped()
+	db.Pool.Exec(ctx, `UPDATE "user" SET "notificationToken"=NULL, "updatedAt"=NOW() WHERE "notificationToken"=$1`, token)
+	db.Pool.Exec(ctx, `UPDATE driver SET "notificationToken"=NULL, "updatedAt"=NOW() WHERE "notificationToken"=$1`, token)
+}
+
+// pushTo sends to one device, dropping the token from the database if FCM reports it stale.
+func (f *fcmClient) pushTo(token, title, body string, data FCMData) error {
+	ctx, cancel := context.WithTimeout(context.Background(), fcmSendTimeout)
+	defer cancel()
+
+	err := f.send(ctx, newFCMMessage(token, title, body, data), false)
+	var sendErr *fcmSendError
+	if errors.As(err, &sendErr) && sendErr.staleToken() {
+		Logger.Info("FCM token no longer valid, removing it", zap.String("errorCode", sendErr.ErrorCode))
+		clearStaleToken(token)
+		return nil
+	}
+	return err
+}
+
+// SendPushNotification sends a push notification to a single device token. A push in a category
+// the recipient has turned off, or reroutes to SMS, returns ErrNotificationMuted.
+func SendPushNotification(to Recipient, token string, title, body string, data FCMData) error {
+	if token == "" {
+		return nil
+	}
+	if !NotificationAllowed(context.Background(), to, ChannelPush) {
+		return ErrNotificationMuted
+	}
+	f, err := fcmFromEnv()
+	if f == nil {
+		if err != nil {
+			Logger.Error("FCM is misconfigured, skipping push notification", zap.Error(err))
+			return err
+		}
+		Logger.Warn("FCM service account not set, skipping push notification")
+		return nil
+	}
+
+	if err := f.pushTo(token, title, body, data); err != nil {
+		Logger.Error("FCM send failed", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// SendPushToMultiple sends the same notification to many device tokens, fcmMaxConcurrent at a time.
+// Returns an error if any send failed for a reason other than a stale token.
+func SendPushToMultiple(tokens []string, title, body string, data FCMData) error {
+	if len(tokens) == 0 {
+		return nil
 	}
+	f, err := fcmFromEnv()
+	if f == nil {
+		if err != nil {
+			Logger.Error("FCM is misconfigured, skipping push notifications", zap.Error(err))
+			return err
+		}
+		Logger.Warn("FCM service account not set, skipping push notifications")
+		return nil
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		failed   int
+		firstErr error
+	)
+	sem := make(chan struct{}, fcmMaxConcurrent)
+	for _, token := range tokens {
+		if token == "" {
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(token string) {
+			defer func() { <-sem; wg.Done() }()
+			if err := f.pushTo(token, title, body, data); err != nil {
+				mu.Lock()
+				failed++
+				if firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
+			}
+		}(token)
+	}
+	wg.Wait()
 
-	Logger.Info("FCM notification sent", zap.Int("status", resp.StatusCode))
+	if failed > 0 {
+		Logger.Error("FCM multicast had failures", zap.Int("failed", failed), zap.Int("total", len(tokens)), zap.Error(firstErr))
+		return fmt.Errorf("%d of %d FCM sends failed: %w", failed, len(tokens), firstErr)
+	}
+	Logger.Info("FCM multicast sent", zap.Int("total", len(tokens)))
 	return nil
 }
+
+// CheckFCM validates the service account and sends a validate-only message to a topic: nothing
+// is delivered. Used by `server --check`.
+func CheckFCM(ctx context.Context) error {
+	f, err := fcmFromEnv()
+	if err != nil {
+		return err
+	}
+	if f == nil {
+		return errors.New("no FCM service account configured (FCM_SERVICE_ACCOUNT_JSON or FCM_SERVICE_ACCOUNT_FILE)")
+	}
+	return f.send(ctx, fcmMessage{Topic: "ridewave-readiness-check", Data: FCMData{"type": "check"}}, true)
+}
@@ -32,3 +32,100 @@ func CalculateDistance(lat1, lon1, lat2, lon2 float64) float64 {
 
 	return earthRadius * c
 }
+
+// DecodePolyline decodes a Google/Ola encoded polyline (precision 5) into [lat, lng] pairs.
+func DecodePolyline(encoded string) [][2]float64 {
+	var points [][2]float64
+	var lat, lng int
+	for i := 0; i < len(encoded); {
+		for _, coord := range []*int{&lat, &lng} {
+			result, shift := 0, 0
+			for i < len(encoded) {
+				b := int(encoded[i]) - 63
+				i++
+				result |= (b & 0x1f) << shift
+				shift += 5
+				if b < 0x20 {
+					break
+				}
+			}
+			if result&1 != 0 {
+				*coord += ^(result >> 1)
+			} else {
+				*coord += result >> 1
+			}
+		}
+		points = append(points, [2]float64{float64(lat) / 1e5, float64(lng) / 1e5})
+	}
+	return points
+}
+
+// PointInPolygon reports whether the point lies inside the [lat, lng] polygon (ray casting).
+// Edges are treated as straight lines in degrees, which is accurate enough at city scale.
+func PointInPolygon(lat, lng float64, polygon [][2]float64) bool {
+	inside := false
+	for i, j := 0, len(polygon)-1; i < len(polygon); j, i = i, i+1 {
+		yi, xi := polygon[i][0], polygon[i][1]
+		yj, xj := polygon[j][0], polygon[j][1]
+		if (yi > lat) != (yj > lat) && lng < (xj-xi)*(lat-yi)/(yj-yi)+xi {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// PathLength returns the length of a [lat, lng] path in KM.
+func PathLength(path [][2]float64) float64 {
+	total := 0.0
+	for i := 1; i < len(path); i++ {
+		total += CalculateDistance(path[i-1][0], path[i-1][1], path[i][0], path[i][1])
+	}
+	return total
+}
+
+// DistanceToPath returns how far the point is from the nearest segment of the path, in KM.
+// Segments are flattened around the point, which is accurate enough at city scale.
+func DistanceToPath(lat, lng float64, path [][2]float64) float64 {
+	if len(path) == 0 {
+		return math.Inf(1)
+	}
+	if len(path) == 1 {
+		return CalculateDistance(lat, lng, path[0][0], path[0][1])
+	}
+
+	const kmPerDegree = 111.32
+	scale := math.Cos(lat * math.Pi / 180)
+	project := func(p [2]float64) (float64, float64) {
+		return (p[1] - lng) * scale * kmPerDegree, (p[0] - lat) * kmPerDegree
+	}
+
+	best := math.Inf(1)
+	for i := 1; i < len(path); i++ {
+		ax, ay := project(path[i-1])
+		bx, by := project(path[i])
+		dx, dy := bx-ax, by-ay
+		t := 0.0
+		if lenSq := dx*dx + dy*dy; lenSq > 0 {
+			t = math.Max(0, math.Min(1, -(ax*dx+ay*dy)/lenSq))
+		}
+		best = math.Min(best, math.Hypot(ax+t*dx, ay+t*dy))
+	}
+	return best
+}
+
+// Bearing returns the initial compass bearing from the first point to the second, in degrees [0, 360).
+func Bearing(lat1, lon1, lat2, lon2 float64) float64 {
+	lat1Rad := lat1 * (math.Pi / 180.0)
+	lat2Rad := lat2 * (math.Pi / 180.0)
+	dLon := (lon2 - lon1) * (math.Pi / 180.0)
+
+	y := math.Sin(dLon) * math.Cos(lat2Rad)
+	x := math.Cos(lat1Rad)*math.Sin(lat2Rad) - math.Sin(lat1Rad)*math.Cos(lat2Rad)*math.Cos(dLon)
+	return math.Mod(math.Atan2(y, x)*(180.0/math.Pi)+360, 360)
+}
+
+// BearingDiff is the smallest angle between two bearings, in degrees [0, 180].
+func BearingDiff(a, b float64) float64 {
+	d := math.Mod(math.Abs(a-b), 360)
+	return math.Min(d, 360-d)
+}
@@ -0,0 +1,224 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"ridewave/db"
+)
+
+// ══════════════════════════════════════════════════
+// Nearby Driver ETAs — "3 mins away" for the map and vehicle picker
+// ══════════════════════════════════════════════════
+//
+// Drivers are timed to the pickup with one Distance Matrix call for everyone who isn't cached.
+// Results are cached per geohash cell pair (driver cell → pickup cell, ~150 m each) for 30s, so
+// riders around the same corner and drivers idling in the same street share one lookup.
+
+// NearbyETAKeyPrefix is the Redis hash family of cached ETAs, one hash per pickup cell.
+const NearbyETAKeyPrefix = "eta:nearby:"
+
+const (
+	nearbyETACellPrecision = 7 // geohash characters; a cell is about 150 m × 150 m
+	nearbyETATTL           = 30 * time.Second
+	matrixMaxOrigins       = 10 // keeps the matrix URL and billing per call small
+	fallbackRoadFactor     = 1.3
+)
+
+// ETAPoint is a driver to time to the pickup.
+type ETAPoint struct {
+	ID       string
+	Lat, Lng float64
+}
+
+// DriverETA is a driver's drive to the pickup.
+type DriverETA struct {
+	Seconds int    `json:"etaSeconds"`
+	Meters  int    `json:"etaDistanceMeters"`
+	Text    string `json:"etaText"`
+	Source  string `json:"etaSource"` // matrix | haversine
+}
+
+// nearbyETAMatrixDrivers caps how many drivers (nearest first) are timed on the road network;
+// the rest get a straight-line estimate (NEARBY_ETA_MATRIX_DRIVERS, default 10).
+func nearbyETAMatrixDrivers() int {
+	if val, err := strconv.Atoi(os.Getenv("NEARBY_ETA_MATRIX_DRIVERS")); err == nil && val >= 0 {
+		return val
+	}
+	return 10
+}
+
+// StraightLineETA approximates a road trip from the great-circle distance at the fallback speed
+// (ETA_FALLBACK_SPEED_KMH, default 25).
+func StraightLineETA(lat1, lng1, lat2, lng2 float64) (seconds, meters int) {
+	speed := 25.0
+	if val, err := strconv.ParseFloat(os.Getenv("ETA_FALLBACK_SPEED_KMH"), 64); err == nil && val > 0 {
+		speed = val
+	}
+	km := CalculateDistance(lat1, lng1, lat2, lng2) * fallbackRoadFactor
+	return int(math.Round(km / speed * 3600)), int(math.Round(km * 1000))
+}
+
+// ETAText renders seconds the way the apps show it: "1 min", "7 mins".
+func ETAText(seconds int) string {
+	mins := max(int(math.Ceil(float64(seconds)/60)), 1)
+	if mins == 1 {
+		return "1 min"
+	}
+	return fmt.Sprintf("%d mins", mins)
+}
+
+// NearbyDriverETAs times each driver to the pickup, keyed by driver ID. drivers should be sorted
+// nearest first. Every driver gets an ETA: when the matrix can't be reached the straight line is used.
+func NearbyDriverETAs(ctx context.Context, pickupLat, pickupLng float64, drivers []ETAPoint) map[string]DriverETA {
+	etas := make(map[string]DriverETA, len(drivers))
+	if len(drivers) == 0 {
+		return etas
+	}
+	key := NearbyETAKeyPrefix + Geohash(pickupLat, pickupLng, nearbyETACellPrecision)
+
+	// Cells of the drivers worth a road-network lookup, nearest first
+	cellOf := make(map[string]string, len(drivers))
+	var cells []string
+	for _, d := range drivers {
+		cell := Geohash(d.Lat, d.Lng, nearbyETACellPrecision)
+		cellOf[d.ID] = cell
+		if !slices.Contains(cells, cell) && len(cells) < nearbyETAMatrixDrivers() {
+			cells = append(cells, cell)
+		}
+	}
+
+	routed := map[string][2]int{}
+	if len(cells) > 0 {
+		cached, err := db.RedisClient.HMGet(ctx, key, cells...).Result()
+		var misses []string
+		for i, cell := range cells {
+			if err == nil {
+				if val, ok := cached[i].(string); ok {
+					var seconds, meters int
+					if _, err := fmt.Sscanf(val, "%d,%d", &seconds, &meters); err == nil {
+						routed[cell] = [2]int{seconds, meters}
+						continue
+					}
+				}
+			}
+			misses = append(misses, cell)
+		}
+		if len(misses) > 0 {
+			fresh := matrixFromCells(ctx, misses, pickupLat, pickupLng)
+			if len(fresh) > 0 {
+				fields := make([]any, 0, len(fresh)*2)
+				for cell, v := range fresh {
+					routed[cell] = v
+					fields = append(fields, cell, fmt.Sprintf("%d,%d", v[0], v[1]))
+				}
+				pipe := db.RedisClient.Pipeline()
+				pipe.HSet(ctx, key, fields...)
+				pipe.ExpireNX(ctx, key, nearbyETATTL)
+				pipe.Exec(ctx)
+			}
+		}
+	}
+
+	for _, d := range drivers {
+		if v, ok := routed[cellOf[d.ID]]; ok {
+			etas[d.ID] = DriverETA{Seconds: v[0], Meters: v[1], Text: ETAText(v[0]), Source: "matrix"}
+			continue
+		}
+		seconds, meters := StraightLineETA(d.Lat, d.Lng, pickupLat, pickupLng)
+		etas[d.ID] = DriverETA{Seconds: seconds, Meters: meters, Text: ETAText(seconds), Source: "haversine"}
+	}
+	return etas
+}
+
+// matrixFromCells routes from each cell's centre to the pickup, matrixMaxOrigins cells per call.
+func matrixFromCells(ctx context.Context, cells []string, pickupLat, pickupLng float64) map[string][2]int {
+	routed := map[string][2]int{}
+	if os.Getenv("OLA_MAPS_API_KEY") == "" {
+		return routed
+	}
+	client := NewOlaMapsClient().WithContext(ctx)
+	pickup := fmt.Sprintf("%f,%f", pickupLat, pickupLng)
+	for start := 0; start < len(cells); start += matrixMaxOrigins {
+		batch := cells[start:min(start+matrixMaxOrigins, len(cells))]
+		origins := make([]string, len(batch))
+		for i, cell := range batch {
+			lat, lng := GeohashCenter(cell)
+			origins[i] = fmt.Sprintf("%f,%f", lat, lng)
+		}
+		matrix, err := client.GetDistanceMatrix(origins, []string{pickup})
+		if err != nil {
+			Logger.Warn("Nearby driver ETA lookup failed", zap.Error(err))
+			return routed
+		}
+		for i, cell := range batch {
+			if i >= len(matrix.Rows) || len(matrix.Rows[i].Elements) == 0 {
+				continue
+			}
+			el := matrix.Rows[i].Elements[0]
+			if el.Status != "" && !strings.EqualFold(el.Status, "OK") {
+				continue
+			}
+			routed[cell] = [2]int{el.Duration.Value, el.Distance.Value}
+		}
+	}
+	return routed
+}
+
+const geohashAlphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// Geohash encodes a point as a geohash of the given length.
+func Geohash(lat, lng float64, precision int) string {
+	latRange, lngRange := [2]float64{-90, 90}, [2]float64{-180, 180}
+	var hash strings.Builder
+	bit, ch, even := 0, 0, true
+	for hash.Len() < precision {
+		rng, val := &latRange, lat
+		if even {
+			rng, val = &lngRange, lng
+		}
+		mid := (rng[0] + rng[1]) / 2
+		if val >= mid {
+			ch |= 1 << (4 - bit)
+			rng[0] = mid
+		} else {
+			rng[1] = mid
+		}
+		even = !even
+		if bit++; bit == 5 {
+			hash.WriteByte(geohashAlphabet[ch])
+			bit, ch = 0, 0
+		}
+	}
+	return hash.String()
+}
+
+// GeohashCenter decodes a geohash to the centre of its cell.
+func GeohashCenter(hash string) (lat, lng float64) {
+	latRange, lngRange := [2]float64{-90, 90}, [2]float64{-180, 180}
+	even := true
+	for i := 0; i < len(hash); i++ {
+		ch := strings.IndexByte(geohashAlphabet, hash[i])
+		for bit := 4; bit >= 0; bit-- {
+			rng := &latRange
+			if even {
+				rng = &lngRange
+			}
+			mid := (rng[0] + rng[1]) / 2
+			if ch&(1<<bit) != 0 {
+				rng[0] = mid
+			} else {
+				rng[1] = mid
+			}
+			even = !even
+		}
+	}
+	return (latRange[0] + latRange[1]) / 2, (lngRange[0] + lngRange[1]) / 2
+}
@@ -0,0 +1,83 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"ridewave/db"
+	"ridewave/models"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+const ChannelPush = "push"
+
+// Notification categories a rider or driver can turn off or reroute. A message with no
+// category is transactional and always sent.
+const (
+	NotifyPromotions  = "promotions"
+	NotifyRideUpdates = "ride_updates"
+	NotifyReceipts    = "receipts"
+)
+
+// ErrNotificationMuted means the recipient has turned off this kind of message on this channel.
+var ErrNotificationMuted = errors.New("recipient has turned off these notifications")
+
+// Recipient is who a message is for and what kind it is, so a send can honour their
+// notification preferences. The zero Recipient is a transactional message, sent regardless.
+type Recipient struct {
+	Type     string // user | driver
+	ID       string
+	Category string // Notify* or "" for transactional
+}
+
+// DefaultNotificationPreferences is what a rider or driver gets until they change anything.
+func DefaultNotificationPreferences() models.NotificationPreferences {
+	return models.NotificationPreferences{Promotions: true, RideUpdates: ChannelPush, EmailReceipts: true}
+}
+
+// GetNotificationPreferences returns the recipient's preferences, or the defaults if they've never set any.
+func GetNotificationPreferences(ctx context.Context, recipientType, recipientID string) (models.NotificationPreferences, error) {
+	p := DefaultNotificationPreferences()
+	err := db.Pool.QueryRow(ctx,
+		`SELECT promotions, "rideUpdates", "emailReceipts", "updatedAt" FROM notification_preferences
+		 WHERE "recipientType"=$1 AND "recipientId"=$2`, recipientType, recipientID).
+		Scan(&p.Promotions, &p.RideUpdates, &p.EmailReceipts, &p.UpdatedAt)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return p, err
+	}
+	return p, nil
+}
+
+// SaveNotificationPreferences replaces the recipient's preferences.
+func SaveNotificationPreferences(ctx context.Context, recipientType, recipientID string, p models.NotificationPreferences) error {
+	_, err := db.Pool.Exec(ctx,
+		`INSERT INTO notification_preferences ("recipientType", "recipientId", promotions, "rideUpdates", "emailReceipts", "updatedAt")
+		 VALUES ($1, $2, $3, $4, $5, NOW())
+		 ON CONFLICT ("recipientType", "recipientId") DO UPDATE SET promotions=EXCLUDED.promotions,
+		 "rideUpdates"=EXCLUDED."rideUpdates", "emailReceipts"=EXCLUDED."emailReceipts", "updatedAt"=NOW()`,
+		recipientType, recipientID, p.Promotions, p.RideUpdates, p.EmailReceipts)
+	return err
+}
+
+// NotificationAllowed reports whether the recipient wants their message's category on channel.
+// If their preferences can't be read the message goes out, as it would have before they existed.
+func NotificationAllowed(ctx context.Context, r Recipient, channel string) bool {
+	if r.ID == "" || r.Category == "" {
+		return true
+	}
+	p, err := GetNotificationPreferences(ctx, r.Type, r.ID)
+	if err != nil {
+		Logger.Warn("Notification preferences unavailable, sending anyway", zap.String("recipientId", r.ID), zap.Error(err))
+		return true
+	}
+	switch r.Category {
+	case NotifyPromotions:
+		return p.Promotions
+	case NotifyRideUpdates:
+		return channel == p.RideUpdates
+	case NotifyReceipts:
+		return channel != ChannelEmail || p.EmailReceipts
+	}
+	return true
+}
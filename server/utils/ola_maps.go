@@ -2,14 +2,19 @@ package utils
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"html"
 	"io"
+	"math"
 	"mime/multipart"
 	"net/http"
 	"net/url"
 	"os"
+	"regexp"
 	"strconv"
+	"strings"
 	"time"
 
 	"ridewave/models"
@@ -17,13 +22,20 @@ import (
 
 type OlaMapsClient struct {
 	ApiKey string
+	ctx    context.Context
 }
 
 type OlaDirectionsResponse struct {
 	Routes []struct {
 		Legs []struct {
 			Steps []struct {
-				Geometry string `json:"geometry"`
+				Geometry      string      `json:"geometry"`
+				Instructions  string      `json:"instructions"`
+				Maneuver      string      `json:"maneuver"`
+				Distance      olaNumber   `json:"distance"`
+				Duration      olaNumber   `json:"duration"`
+				StartLocation olaLocation `json:"start_location"`
+				EndLocation   olaLocation `json:"end_location"`
 			} `json:"steps"`
 			Distance struct {
 				Value int `json:"value"`
@@ -39,27 +51,131 @@ type OlaDirectionsResponse struct {
 	Status string `json:"status"`
 }
 
+// olaNumber is a distance or duration, which Ola Maps sends either as a bare number or as
+// {"value": n}.
+type olaNumber int
+
+func (n *olaNumber) UnmarshalJSON(b []byte) error {
+	var v float64
+	if json.Unmarshal(b, &v) == nil {
+		*n = olaNumber(math.Round(v))
+		return nil
+	}
+	var obj struct {
+		Value float64 `json:"value"`
+	}
+	if err := json.Unmarshal(b, &obj); err != nil {
+		return err
+	}
+	*n = olaNumber(math.Round(obj.Value))
+	return nil
+}
+
+type olaLocation struct {
+	Lat float64 `json:"lat"`
+	Lng float64 `json:"lng"`
+}
+
+// RouteStep is one manoeuvre of a planned route.
+type RouteStep struct {
+	Leg         int     `json:"leg"`         // 0 is pickup to the first stop, or to the destination without stops
+	Instruction string  `json:"instruction"` // plain text, e.g. "Turn right onto MG Road"
+	Maneuver    string  `json:"maneuver"`    // e.g. turn-right, roundabout-left; empty when Ola gives none
+	Distance    int     `json:"distance"`    // meters
+	Duration    int     `json:"duration"`    // seconds
+	StartLat    float64 `json:"startLat"`
+	StartLng    float64 `json:"startLng"`
+	EndLat      float64 `json:"endLat"`
+	EndLng      float64 `json:"endLng"`
+}
+
+// DirectionsRoute is the first route Ola Maps suggests. Distance and duration are totals across
+// every leg.
+type DirectionsRoute struct {
+	RouteID  string // Ola's X-Request-Id, which the audit log is keyed by
+	Polyline string
+	Distance int // meters
+	Duration int // seconds
+	Steps    []RouteStep
+}
+
+var htmlTagPattern = regexp.MustCompile(`<[^>]*>`)
+
+// plainInstruction drops the markup Ola puts in instructions ("Turn <b>right</b>").
+func plainInstruction(s string) string {
+	return strings.Join(strings.Fields(html.UnescapeString(htmlTagPattern.ReplaceAllString(s, ""))), " ")
+}
+
 func NewOlaMapsClient() *OlaMapsClient {
 	return &OlaMapsClient{
 		ApiKey: os.Getenv("OLA_MAPS_API_KEY"),
 	}
 }
 
+// WithContext ties the client's calls to ctx, so a request's trace covers its Ola Maps calls.
+func (c *OlaMapsClient) WithContext(ctx context.Context) *OlaMapsClient {
+	return &OlaMapsClient{ApiKey: c.ApiKey, ctx: ctx}
+}
+
+func (c *OlaMapsClient) context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
+func (c *OlaMapsClient) get(url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(c.context(), http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return http.DefaultClient.Do(req)
+}
+
+func (c *OlaMapsClient) post(url, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(c.context(), http.MethodPost, url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	return http.DefaultClient.Do(req)
+}
+
 func (c *OlaMapsClient) GetDirections(origin, destination string) (string, int, int, string, error) {
 	return c.GetDirectionsWithMode(origin, destination, "driving")
 }
 
 func (c *OlaMapsClient) GetDirectionsWithMode(origin, destination, mode string) (string, int, int, string, error) {
+	return c.GetDirectionsWithWaypoints(origin, destination, nil, mode)
+}
+
+// GetDirectionsWithWaypoints routes through the "lat,lng" waypoints in order. Distance and
+// duration are totals across every leg.
+func (c *OlaMapsClient) GetDirectionsWithWaypoints(origin, destination string, waypoints []string, mode string) (string, int, int, string, error) {
+	route, err := c.GetRoute(origin, destination, waypoints, mode)
+	if err != nil {
+		return "", 0, 0, "", err
+	}
+	return route.Polyline, route.Distance, route.Duration, route.RouteID, nil
+}
+
+// GetRoute is GetDirectionsWithWaypoints with the route's turn-by-turn steps.
+func (c *OlaMapsClient) GetRoute(origin, destination string, waypoints []string, mode string) (*DirectionsRoute, error) {
 	if c.ApiKey == "" {
-		return "", 0, 0, "", fmt.Errorf("OLA_MAPS_API_KEY is not set")
+		return nil, fmt.Errorf("OLA_MAPS_API_KEY is not set")
 	}
 
 	start := time.Now()
 	url := fmt.Sprintf("https://api.olamaps.io/routing/v1/directions?origin=%s&destination=%s&mode=%s&api_key=%s", origin, destination, mode, c.ApiKey)
+	requestPayload := map[string]string{"origin": origin, "destination": destination, "mode": mode}
+	if len(waypoints) > 0 {
+		url += "&waypoints=" + strings.Join(waypoints, "|")
+		requestPayload["waypoints"] = strings.Join(waypoints, "|")
+	}
 
-	resp, err := http.Get(url)
+	resp, err := c.get(url)
 	if err != nil {
-		return "", 0, 0, "", err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
@@ -74,17 +190,17 @@ func (c *OlaMapsClient) GetDirectionsWithMode(origin, destination, mode string)
 			Provider:        "OlaMaps",
 			Endpoint:        "/routing/v1/directions",
 			RequestID:       &routeID,
-			RequestPayload:  map[string]string{"origin": origin, "destination": destination, "mode": mode},
+			RequestPayload:  requestPayload,
 			ResponsePayload: string(bodyBytes),
 			StatusCode:      resp.StatusCode,
 			DurationMs:      int(duration.Milliseconds()),
 		})
-		return "", 0, 0, "", fmt.Errorf("ola maps api error: %s - %s", resp.Status, string(bodyBytes))
+		return nil, fmt.Errorf("ola maps api error: %s - %s", resp.Status, string(bodyBytes))
 	}
 
 	var result OlaDirectionsResponse
 	if err := json.Unmarshal(bodyBytes, &result); err != nil {
-		return "", 0, 0, "", err
+		return nil, err
 	}
 
 	// AUDIT LOGGING: Store the full response payload (including massive polyline) in the audit table
@@ -92,25 +208,40 @@ func (c *OlaMapsClient) GetDirectionsWithMode(origin, destination, mode string)
 		Provider:        "OlaMaps",
 		Endpoint:        "/routing/v1/directions",
 		RequestID:       &routeID,
-		RequestPayload:  map[string]string{"origin": origin, "destination": destination, "mode": mode},
+		RequestPayload:  requestPayload,
 		ResponsePayload: result,
 		StatusCode:      200,
 		DurationMs:      int(duration.Milliseconds()),
 	})
 
 	if result.Status != "OK" || len(result.Routes) == 0 {
-		return "", 0, 0, "", fmt.Errorf("no routes found or api error: %s", result.Status)
+		return nil, fmt.Errorf("no routes found or api error: %s", result.Status)
 	}
 
 	Route := result.Routes[0]
 	if len(Route.Legs) > 0 {
-		distance := Route.Legs[0].Distance.Value
-		duration := Route.Legs[0].Duration.Value
-		polyline := Route.OverviewPolyline.Points
-		return polyline, distance, duration, routeID, nil
+		route := &DirectionsRoute{RouteID: routeID, Polyline: Route.OverviewPolyline.Points}
+		for i, leg := range Route.Legs {
+			route.Distance += leg.Distance.Value
+			route.Duration += leg.Duration.Value
+			for _, step := range leg.Steps {
+				route.Steps = append(route.Steps, RouteStep{
+					Leg:         i,
+					Instruction: plainInstruction(step.Instructions),
+					Maneuver:    step.Maneuver,
+					Distance:    int(step.Distance),
+					Duration:    int(step.Duration),
+					StartLat:    step.StartLocation.Lat,
+					StartLng:    step.StartLocation.Lng,
+					EndLat:      step.EndLocation.Lat,
+					EndLng:      step.EndLocation.Lng,
+				})
+			}
+		}
+		return route, nil
 	}
 
-	return "", 0, 0, "", fmt.Errorf("no legs found in route")
+	return nil, fmt.Errorf("no legs found in route")
 }
 
 type OlaPlacesResponse struct {
@@ -135,7 +266,7 @@ func (c *OlaMapsClient) Autocomplete(input string) ([]map[string]string, error)
 	encodedInput := url.QueryEscape(input)
 	url := fmt.Sprintf("https://api.olamaps.io/places/v1/autocomplete?input=%s&api_key=%s", encodedInput, c.ApiKey)
 
-	resp, err := http.Get(url)
+	resp, err := c.get(url)
 	if err != nil {
 		return nil, err
 	}
@@ -181,7 +312,7 @@ func (c *OlaMapsClient) Geocode(address string) (float64, float64, error) {
 
 	url := fmt.Sprintf("https://api.olamaps.io/places/v1/geocode?address=%s&api_key=%s", address, c.ApiKey)
 
-	resp, err := http.Get(url)
+	resp, err := c.get(url)
 	if err != nil {
 		return 0, 0, err
 	}
@@ -217,7 +348,7 @@ func (c *OlaMapsClient) SnapToRoad(points string) (float64, float64, error) {
 
 	url := fmt.Sprintf("https://api.olamaps.io/routing/v1/snapToRoad?points=%s&api_key=%s", points, c.ApiKey)
 
-	resp, err := http.Get(url)
+	resp, err := c.get(url)
 	if err != nil {
 		return 0, 0, err
 	}
@@ -236,6 +367,41 @@ func (c *OlaMapsClient) SnapToRoad(points string) (float64, float64, error) {
 	return snap.Lat, snap.Lng, nil
 }
 
+// SnapPathToRoad snaps a trace of lat,lng pairs in one call. The result has one point per input,
+// in the same order; it errors if the API doesn't match every point.
+func (c *OlaMapsClient) SnapPathToRoad(points [][2]float64) ([][2]float64, error) {
+	if c.ApiKey == "" {
+		return nil, fmt.Errorf("OLA_MAPS_API_KEY is not set")
+	}
+
+	parts := make([]string, len(points))
+	for i, p := range points {
+		parts[i] = fmt.Sprintf("%f,%f", p[0], p[1])
+	}
+	path := url.QueryEscape(strings.Join(parts, "|"))
+	endpoint := fmt.Sprintf("https://api.olamaps.io/routing/v1/snapToRoad?points=%s&enhancePath=false&api_key=%s", path, c.ApiKey)
+
+	resp, err := c.get(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result OlaSnapResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	if result.Status != "SUCCESS" || len(result.SnappedPoints) != len(points) {
+		return nil, fmt.Errorf("snap api error or partial match: %s (%d of %d points)", result.Status, len(result.SnappedPoints), len(points))
+	}
+
+	snapped := make([][2]float64, len(points))
+	for i, p := range result.SnappedPoints {
+		snapped[i] = [2]float64{p.Location.Lat, p.Location.Lng}
+	}
+	return snapped, nil
+}
+
 type OlaNearbyResponse struct {
 	Predictions []struct {
 		Description string   `json:"description"`
@@ -253,7 +419,7 @@ func (c *OlaMapsClient) NearbySearch(lat, lng float64, types string, radius int)
 
 	url := fmt.Sprintf("https://api.olamaps.io/places/v1/nearbysearch?location=%f,%f&types=%s&radius=%d&api_key=%s", lat, lng, types, radius, c.ApiKey)
 
-	resp, err := http.Get(url)
+	resp, err := c.get(url)
 	if err != nil {
 		return nil, err
 	}
@@ -306,7 +472,7 @@ func (c *OlaMapsClient) ReverseGeocode(lat, lng float64) (string, error) {
 
 	url := fmt.Sprintf("https://api.olamaps.io/places/v1/reverse-geocode?latlng=%f,%f&api_key=%s", lat, lng, c.ApiKey)
 
-	resp, err := http.Get(url)
+	resp, err := c.get(url)
 	if err != nil {
 		return "", err
 	}
@@ -355,7 +521,7 @@ func (c *OlaMapsClient) GetPlaceDetails(placeID string) (map[string]interface{},
 
 	url := fmt.Sprintf("https://api.olamaps.io/places/v1/details?place_id=%s&api_key=%s", placeID, c.ApiKey)
 
-	resp, err := http.Get(url)
+	resp, err := c.get(url)
 	if err != nil {
 		return nil, err
 	}
@@ -433,7 +599,7 @@ func (c *OlaMapsClient) GetDistanceMatrix(origins []string, destinations []strin
 
 	url := fmt.Sprintf("https://api.olamaps.io/routing/v1/distanceMatrix?origins=%s&destinations=%s&api_key=%s", originsStr, destinationsStr, c.ApiKey)
 
-	resp, err := http.Get(url)
+	resp, err := c.get(url)
 	if err != nil {
 		return nil, err
 	}
@@ -489,7 +655,7 @@ func (c *OlaMapsClient) CreateGeofence(req GeofenceCreateRequest) (*GeofenceResp
 	}
 
 	url := fmt.Sprintf("https://api.olamaps.io/places/v1/geofence?api_key=%s", c.ApiKey)
-	resp, err := http.Post(url, "application/json", bytes.NewBuffer(body))
+	resp, err := c.post(url, "application/json", bytes.NewBuffer(body))
 	if err != nil {
 		return nil, err
 	}
@@ -518,7 +684,7 @@ func (c *OlaMapsClient) UpdateGeofence(id string, req GeofenceCreateRequest) (*G
 	}
 
 	url := fmt.Sprintf("https://api.olamaps.io/places/v1/geofence/%s?api_key=%s", id, c.ApiKey)
-	reqHttp, err := http.NewRequest(http.MethodPut, url, bytes.NewBuffer(body))
+	reqHttp, err := http.NewRequestWithContext(c.context(), http.MethodPut, url, bytes.NewBuffer(body))
 	if err != nil {
 		return nil, err
 	}
@@ -559,7 +725,7 @@ func (c *OlaMapsClient) GetGeofence(id string) (*GeofenceDetails, error) {
 	}
 
 	url := fmt.Sprintf("https://api.olamaps.io/places/v1/geofence/%s?api_key=%s", id, c.ApiKey)
-	resp, err := http.Get(url)
+	resp, err := c.get(url)
 	if err != nil {
 		return nil, err
 	}
@@ -582,7 +748,7 @@ func (c *OlaMapsClient) DeleteGeofence(id string) error {
 	}
 
 	url := fmt.Sprintf("https://api.olamaps.io/places/v1/geofence/%s?api_key=%s", id, c.ApiKey)
-	reqHttp, err := http.NewRequest(http.MethodDelete, url, nil)
+	reqHttp, err := http.NewRequestWithContext(c.context(), http.MethodDelete, url, nil)
 	if err != nil {
 		return err
 	}
@@ -612,7 +778,7 @@ func (c *OlaMapsClient) ListGeofences(projectId string, page, size int) (*Geofen
 	}
 
 	url := fmt.Sprintf("https://api.olamaps.io/places/v1/geofences?projectId=%s&page=%d&size=%d&api_key=%s", projectId, page, size, c.ApiKey)
-	resp, err := http.Get(url)
+	resp, err := c.get(url)
 	if err != nil {
 		return nil, err
 	}
@@ -641,7 +807,7 @@ func (c *OlaMapsClient) GetGeofenceStatus(id string, lat, lng float64) (*Geofenc
 	}
 
 	url := fmt.Sprintf("https://api.olamaps.io/places/v1/geofence/status?geofenceId=%s&coordinates=%f,%f&api_key=%s", id, lat, lng, c.ApiKey)
-	resp, err := http.Get(url)
+	resp, err := c.get(url)
 	if err != nil {
 		return nil, err
 	}
@@ -703,7 +869,7 @@ func (c *OlaMapsClient) RouteOptimizer(locations string, source, destination str
 
 	reqUrl := "https://api.olamaps.io/routing/v1/routeOptimizer?" + params.Encode()
 
-	resp, err := http.Post(reqUrl, "application/json", bytes.NewBuffer([]byte("{}")))
+	resp, err := c.post(reqUrl, "application/json", bytes.NewBuffer([]byte("{}")))
 	if err != nil {
 		return nil, err
 	}
@@ -764,7 +930,7 @@ func (c *OlaMapsClient) FleetPlanner(strategy string, inputJSON []byte) (*FleetP
 	writer.Close()
 
 	reqUrl := fmt.Sprintf("https://api.olamaps.io/routing/v1/fleetPlanner?strategy=%s&api_key=%s", strategy, c.ApiKey)
-	req, err := http.NewRequest("POST", reqUrl, body)
+	req, err := http.NewRequestWithContext(c.context(), "POST", reqUrl, body)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,130 @@
+package utils
+
+import (
+	"encoding/base64"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Pagination holds the paging parameters of an admin list request.
+//
+// By default lists use page/limit (OFFSET) pagination. Passing ?cursor= switches to keyset
+// pagination on (createdAt, id): an empty cursor returns the first page, and each response
+// carries the nextCursor to send back. Keyset pages skip the COUNT(*) and don't slow down on
+// deep pages.
+type Pagination struct {
+	Page      int
+	Limit     int
+	Offset    int
+	UseCursor bool
+
+	afterAt time.Time
+	afterID string
+}
+
+// ParsePagination reads page/limit or cursor/limit from the query string (limit defaults to 20, max 100).
+func ParsePagination(c *gin.Context) (Pagination, error) {
+	p := Pagination{}
+	p.Page, _ = strconv.Atoi(c.DefaultQuery("page", "1"))
+	p.Limit, _ = strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if p.Page < 1 {
+		p.Page = 1
+	}
+	if p.Limit < 1 || p.Limit > 100 {
+		p.Limit = 20
+	}
+	p.Offset = (p.Page - 1) * p.Limit
+
+	cursor, ok := c.GetQuery("cursor")
+	if !ok {
+		return p, nil
+	}
+	p.UseCursor = true
+	p.Page, p.Offset = 0, 0
+	if cursor == "" {
+		return p, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return p, ErrInvalidCursor
+	}
+	at, id, found := strings.Cut(string(raw), "|")
+	if !found || id == "" {
+		return p, ErrInvalidCursor
+	}
+	if p.afterAt, err = time.Parse(time.RFC3339Nano, at); err != nil {
+		return p, ErrInvalidCursor
+	}
+	p.afterID = id
+	return p, nil
+}
+
+// EncodeCursor builds the opaque cursor pointing just past the given row.
+func EncodeCursor(createdAt time.Time, id string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(createdAt.Format(time.RFC3339Nano) + "|" + id))
+}
+
+// Keyset appends the "rows after the cursor" condition when paging by cursor.
+func (p Pagination) Keyset(conds []string, args []interface{}, createdCol, idCol string) ([]string, []interface{}) {
+	if !p.UseCursor || p.afterID == "" {
+		return conds, args
+	}
+	n := len(args)
+	conds = append(conds, "("+createdCol+", "+idCol+") < ($"+strconv.Itoa(n+1)+", $"+strconv.Itoa(n+2)+")")
+	return conds, append(args, p.afterAt, p.afterID)
+}
+
+// Tail returns the ORDER BY / LIMIT / OFFSET suffix using the placeholders after args.
+// Cursor pages fetch one extra row so Paginate can tell whether another page exists.
+func (p Pagination) Tail(args []interface{}, createdCol, idCol string) (string, []interface{}) {
+	n := len(args)
+	tail := " ORDER BY " + createdCol + " DESC, " + idCol + " DESC LIMIT $" + strconv.Itoa(n+1)
+	if p.UseCursor {
+		return tail, append(args, p.Limit+1)
+	}
+	return tail + " OFFSET $" + strconv.Itoa(n+2), append(args, p.Limit, p.Offset)
+}
+
+// WhereClause joins conditions with AND, or returns "" when there are none.
+func WhereClause(conds []string) string {
+	if len(conds) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(conds, " AND ")
+}
+
+// Paginate trims the lookahead row from a cursor page and returns the response metadata, for the
+// handler to add its items to. total is only reported in offset mode.
+func Paginate[T any](p Pagination, items []T, total int, key func(T) (time.Time, string)) ([]T, Page) {
+	if !p.UseCursor {
+		return items, Page{
+			"total":      total,
+			"page":       p.Page,
+			"limit":      p.Limit,
+			"totalPages": int(math.Ceil(float64(total) / float64(p.Limit))),
+		}
+	}
+
+	hasMore := len(items) > p.Limit
+	if hasMore {
+		items = items[:p.Limit]
+	}
+	var nextCursor string
+	if hasMore {
+		at, id := key(items[len(items)-1])
+		nextCursor = EncodeCursor(at, id)
+	}
+	return items, Page{
+		"limit":      p.Limit,
+		"hasMore":    hasMore,
+		"nextCursor": nextCursor,
+	}
+}
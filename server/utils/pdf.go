@@ -0,0 +1,112 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// TextPDF lays out lines of monospaced text on A4 pages, starting a new page when one fills up.
+// It covers statements and reports without pulling in a PDF library: standard Courier fonts,
+// no images, and characters outside printable ASCII are replaced with '?'.
+type TextPDF struct {
+	pages [][]pdfLine
+	y     float64
+}
+
+type pdfLine struct {
+	text string
+	size float64
+	bold bool
+	y    float64
+}
+
+const (
+	pdfPageWidth  = 595.0 // A4 in points
+	pdfPageHeight = 842.0
+	pdfMargin     = 40.0
+)
+
+func NewTextPDF() *TextPDF {
+	return &TextPDF{}
+}
+
+// Line adds one line of text at the given font size.
+func (p *TextPDF) Line(text string, size float64, bold bool) {
+	height := size * 1.4
+	if len(p.pages) == 0 || p.y-height < pdfMargin {
+		p.pages = append(p.pages, nil)
+		p.y = pdfPageHeight - pdfMargin
+	}
+	p.y -= height
+	last := len(p.pages) - 1
+	p.pages[last] = append(p.pages[last], pdfLine{text: text, size: size, bold: bold, y: p.y})
+}
+
+// Space adds vertical blank space.
+func (p *TextPDF) Space(points float64) {
+	p.y -= points
+}
+
+// Bytes renders the document.
+func (p *TextPDF) Bytes() []byte {
+	if len(p.pages) == 0 {
+		p.Line("", 10, false)
+	}
+
+	var buf bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	buf.WriteString("%PDF-1.4\n")
+	// 1 catalog, 2 page tree, 3-4 fonts, then a page and its content stream per page
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	var kids []string
+	for i := range p.pages {
+		kids = append(kids, fmt.Sprintf("%d 0 R", 5+i*2))
+	}
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(p.pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Courier-Bold /Encoding /WinAnsiEncoding >>")
+
+	for i, lines := range p.pages {
+		var content bytes.Buffer
+		for _, l := range lines {
+			font := "F1"
+			if l.bold {
+				font = "F2"
+			}
+			fmt.Fprintf(&content, "BT /%s %.1f Tf %.1f %.1f Td (%s) Tj ET\n", font, l.size, pdfMargin, l.y, pdfEscape(l.text))
+		}
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			pdfPageWidth, pdfPageHeight, 6+i*2))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String()))
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return buf.Bytes()
+}
+
+func pdfEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 32 || r > 126:
+			b.WriteByte('?')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,110 @@
+package utils
+
+import (
+	"errors"
+	"os"
+	"strings"
+)
+
+// ErrInvalidPhone means a phone number can't be turned into a valid E.164 number.
+var ErrInvalidPhone = errors.New("invalid phone number")
+
+// phoneRegion is a country's calling code and the length of its national numbers.
+type phoneRegion struct {
+	CallingCode    string
+	NationalDigits int
+}
+
+// phoneRegions covers the countries we operate in or see riders from, by ISO 3166 alpha-2 code.
+// Numbers with a + prefix from anywhere else are accepted on E.164's rules alone.
+var phoneRegions = map[string]phoneRegion{
+	"IN": {"91", 10},
+	"AE": {"971", 9},
+	"SA": {"966", 9},
+	"LK": {"94", 9},
+	"NP": {"977", 10},
+	"BD": {"880", 10},
+	"PK": {"92", 10},
+	"SG": {"65", 8},
+	"GB": {"44", 10},
+	"US": {"1", 10},
+	"CA": {"1", 10},
+	"AU": {"61", 9},
+}
+
+// countryNames maps the country names drivers register with onto their ISO codes.
+var countryNames = map[string]string{
+	"india": "IN", "united arab emirates": "AE", "uae": "AE", "saudi arabia": "SA", "sri lanka": "LK",
+	"nepal": "NP", "bangladesh": "BD", "pakistan": "PK", "singapore": "SG", "united kingdom": "GB", "uk": "GB",
+	"united states": "US", "usa": "US", "canada": "CA", "australia": "AU",
+}
+
+// DefaultPhoneRegion is the country assumed for numbers typed without a calling code
+// (DEFAULT_PHONE_REGION, default IN).
+func DefaultPhoneRegion() string {
+	if region := strings.ToUpper(strings.TrimSpace(os.Getenv("DEFAULT_PHONE_REGION"))); phoneRegions[region].CallingCode != "" {
+		return region
+	}
+	return "IN"
+}
+
+// PhoneRegionFor resolves a country given as an ISO code or a name ("IN", "India") to the region
+// used for national numbers, falling back to DefaultPhoneRegion.
+func PhoneRegionFor(country string) string {
+	country = strings.TrimSpace(country)
+	if _, ok := phoneRegions[strings.ToUpper(country)]; ok {
+		return strings.ToUpper(country)
+	}
+	if code, ok := countryNames[strings.ToLower(country)]; ok {
+		return code
+	}
+	return DefaultPhoneRegion()
+}
+
+// NormalizePhone turns a phone number as typed ("+91 98765 43210", "098765-43210", "9876543210")
+// into E.164 ("+919876543210"). Numbers without a calling code are read as national numbers of
+// country (an ISO code or name, "" for the default region).
+func NormalizePhone(raw, country string) (string, error) {
+	s := strings.TrimSpace(raw)
+	international := strings.HasPrefix(s, "+")
+	var digits strings.Builder
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			digits.WriteRune(r)
+		case r == '+' || r == ' ' || r == '-' || r == '.' || r == '(' || r == ')':
+		default:
+			return "", ErrInvalidPhone
+		}
+	}
+	d := digits.String()
+	if strings.Count(s, "+") > 1 || (strings.Contains(s, "+") && !international) {
+		return "", ErrInvalidPhone
+	}
+	if !international && strings.HasPrefix(d, "00") {
+		international, d = true, d[2:]
+	}
+
+	if !international {
+		region := phoneRegions[PhoneRegionFor(country)]
+		switch national := strings.TrimLeft(d, "0"); {
+		case len(national) == region.NationalDigits:
+			d = region.CallingCode + national
+		case len(d) == len(region.CallingCode)+region.NationalDigits && strings.HasPrefix(d, region.CallingCode):
+			// Typed with the calling code but no +
+		default:
+			return "", ErrInvalidPhone
+		}
+	}
+
+	if len(d) < 8 || len(d) > 15 || d[0] == '0' {
+		return "", ErrInvalidPhone
+	}
+	// Calling codes are prefix-free, so a known one at the front is the number's country
+	for _, region := range phoneRegions {
+		if strings.HasPrefix(d, region.CallingCode) && len(d) != len(region.CallingCode)+region.NationalDigits {
+			return "", ErrInvalidPhone
+		}
+	}
+	return "+" + d, nil
+}
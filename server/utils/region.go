@@ -0,0 +1,38 @@
+package utils
+
+import (
+	"os"
+	"strings"
+)
+
+// parseRegionMap parses "in=value1,ae=value2" into a map keyed by region code.
+func parseRegionMap(raw string) map[string]string {
+	out := map[string]string{}
+	for _, pair := range strings.Split(raw, ",") {
+		region, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || region == "" || value == "" {
+			continue
+		}
+		out[strings.ToLower(strings.TrimSpace(region))] = strings.TrimRight(strings.TrimSpace(value), "/")
+	}
+	return out
+}
+
+// RegionEndpoints maps each region to its API base URL (REGION_ENDPOINTS="in=https://in.api.ridewave.app,ae=https://ae.api.ridewave.app").
+func RegionEndpoints() map[string]string {
+	return parseRegionMap(os.Getenv("REGION_ENDPOINTS"))
+}
+
+// RegionForPhone returns the region owning a phone number by its country prefix
+// (REGION_PHONE_PREFIXES="in=+91,ae=+971"), or "" if no rule matches.
+func RegionForPhone(phone string) string {
+	best, bestLen := "", 0
+	for region, prefixes := range parseRegionMap(os.Getenv("REGION_PHONE_PREFIXES")) {
+		for _, prefix := range strings.Split(prefixes, "|") {
+			if prefix != "" && strings.HasPrefix(phone, prefix) && len(prefix) > bestLen {
+				best, bestLen = region, len(prefix)
+			}
+		}
+	}
+	return best
+}
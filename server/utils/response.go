@@ -3,6 +3,7 @@ package utils
 import (
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
+	"ridewave/i18n"
 )
 
 // Standard Response Structure
@@ -10,27 +11,61 @@ type APIResponse struct {
 	Success bool        `json:"success"`
 	Message string      `json:"message,omitempty"`
 	Data    interface{} `json:"data,omitempty"`
+	Code    string      `json:"code,omitempty"`    // errors only; see error_codes.go
+	Details interface{} `json:"details,omitempty"` // errors only, when there's more to say than the code
 }
 
-// SuccessResponse sends a standard success response
+// SuccessResponse sends a standard success response (the v2 envelope on /api/v2)
 func RespondSuccess(c *gin.Context, code int, message string, data interface{}) {
+	if APIVersion(c) >= APIv2 {
+		respondV2Success(c, code, localize(c, message), data)
+		return
+	}
 	c.JSON(code, APIResponse{
 		Success: true,
-		Message: message,
+		Message: localize(c, message),
 		Data:    data,
 	})
 }
 
-// ErrorResponse sends a standard error response
+// ErrorResponse sends a standard error response. The code is the generic one for the status,
+// or VALIDATION_FAILED with the offending fields when err is a binding failure.
 func RespondError(c *gin.Context, code int, message string, err error) {
+	if details, ok := validationDetails(err); ok {
+		RespondErrorDetails(c, code, CodeValidationFailed, message, details, err)
+		return
+	}
+	RespondErrorDetails(c, code, codeForStatus(code), message, nil, err)
+}
+
+// RespondErrorCode sends an error response with a specific error code.
+func RespondErrorCode(c *gin.Context, status int, code, message string, err error) {
+	RespondErrorDetails(c, status, code, message, nil, err)
+}
+
+// RespondErrorDetails sends an error response with a specific error code and a details object.
+func RespondErrorDetails(c *gin.Context, status int, code, message string, details interface{}, err error) {
 	if err != nil {
 		// Log the internal error for debugging (if needed) but don't expose it raw unless strictly necessary
 		// For now, we just log it if we have a logger, or rely on caller to log.
 		// Let's assume the message passed is safe for the user.
 		Logger.Error(message, zap.Error(err))
 	}
-	c.JSON(code, APIResponse{
+	if APIVersion(c) >= APIv2 {
+		respondV2Error(c, status, code, localize(c, message), details)
+		return
+	}
+	c.JSON(status, APIResponse{
 		Success: false,
-		Message: message,
+		Message: localize(c, message),
+		Code:    code,
+		Details: details,
 	})
 }
+
+// localize translates a message into the caller's language: their saved one, else Accept-Language.
+func localize(c *gin.Context, message string) string {
+	lang := i18n.Resolve(c.GetHeader("Accept-Language"), c.GetString(i18n.ProfileLanguageKey))
+	c.Header("Content-Language", lang)
+	return i18n.T(lang, message)
+}
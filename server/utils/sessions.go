@@ -0,0 +1,241 @@
+package utils
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"ridewave/db"
+)
+
+// ══════════════════════════════════════════════════
+// App Sessions — short-lived access tokens, rotating refresh tokens, revocation
+// ══════════════════════════════════════════════════
+//
+// A login opens a session in Redis. The access token names the session (sid) and expires in
+// minutes; the refresh token is the session ID plus a secret that's replaced on every refresh,
+// so a stolen one stops working as soon as either copy is used. Deleting the session (logout)
+// kills its access tokens at once. Suspension deletes every session of the account and records
+// a cutoff, which also catches tokens issued before sessions existed.
+
+// Session roles: the app a session belongs to.
+const (
+	SessionUser   = "user"
+	SessionDriver = "driver"
+)
+
+const (
+	sessionKeyPrefix      = "session:"  // session:<sid> → {role, id, hash}
+	sessionIndexKeyPrefix = "sessions:" // sessions:<role>:<id> → set of sids
+	revokedKeyPrefix      = "revoked:"  // revoked:<role>:<id> → unix time; tokens issued before it are dead
+	legacyTokenTTL        = 30 * 24 * time.Hour
+)
+
+// ErrInvalidRefreshToken is returned for a refresh token that's unknown, expired, revoked or already used.
+var ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+
+// AccessTokenTTL is how long an access token lasts (ACCESS_TOKEN_TTL_MINUTES, default 15).
+func AccessTokenTTL() time.Duration {
+	if val, err := strconv.Atoi(os.Getenv("ACCESS_TOKEN_TTL_MINUTES")); err == nil && val > 0 {
+		return time.Duration(val) * time.Minute
+	}
+	return 15 * time.Minute
+}
+
+// RefreshTokenTTL is how long a session lasts without being refreshed (REFRESH_TOKEN_TTL_DAYS, default 30).
+func RefreshTokenTTL() time.Duration {
+	if val, err := strconv.Atoi(os.Getenv("REFRESH_TOKEN_TTL_DAYS")); err == nil && val > 0 {
+		return time.Duration(val) * 24 * time.Hour
+	}
+	return 30 * 24 * time.Hour
+}
+
+// TokenPair is what a login or refresh hands back to the app.
+type TokenPair struct {
+	AccessToken  string `json:"accessToken"`
+	RefreshToken string `json:"refreshToken"`
+	ExpiresIn    int    `json:"expiresIn"` // access token lifetime, seconds
+}
+
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func accessToken(role, id, sid string) (string, error) {
+	now := time.Now()
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"id":   id,
+		"role": role,
+		"sid":  sid,
+		"iat":  now.Unix(),
+		"exp":  now.Add(AccessTokenTTL()).Unix(),
+	}).SignedString([]byte(os.Getenv("ACCESS_TOKEN_SECRET")))
+}
+
+// IssueSession opens a session for a user or driver who has just logged in.
+func IssueSession(ctx context.Context, role, id string) (TokenPair, error) {
+	sid, err := randomToken(16)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	secret, err := randomToken(32)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	access, err := accessToken(role, id, sid)
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	ttl := RefreshTokenTTL()
+	indexKey := sessionIndexKeyPrefix + role + ":" + id
+	pipe := db.RedisClient.TxPipeline()
+	pipe.HSet(ctx, sessionKeyPrefix+sid, "role", role, "id", id, "hash", hashSecret(secret))
+	pipe.Expire(ctx, sessionKeyPrefix+sid, ttl)
+	pipe.SAdd(ctx, indexKey, sid)
+	pipe.Expire(ctx, indexKey, ttl)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return TokenPair{}, err
+	}
+	return TokenPair{AccessToken: access, RefreshToken: sid + "." + secret, ExpiresIn: int(AccessTokenTTL().Seconds())}, nil
+}
+
+// rotateRefreshScript swaps the session's secret if the presented one matches. A mismatch means
+// an old refresh token was replayed, so the session is ended: one of the two holders is a thief.
+var rotateRefreshScript = redis.NewScript(`
+local hash = redis.call('HGET', KEYS[1], 'hash')
+if not hash then return 0 end
+if hash ~= ARGV[1] then
+	redis.call('DEL', KEYS[1])
+	return -1
+end
+redis.call('HSET', KEYS[1], 'hash', ARGV[2])
+redis.call('PEXPIRE', KEYS[1], ARGV[3])
+return 1
+`)
+
+// RefreshSession exchanges a refresh token for a new pair, returning the session's account ID.
+func RefreshSession(ctx context.Context, role, refreshToken string) (string, TokenPair, error) {
+	sid, secret, ok := strings.Cut(refreshToken, ".")
+	if !ok || sid == "" || secret == "" {
+		return "", TokenPair{}, ErrInvalidRefreshToken
+	}
+	fields, err := db.RedisClient.HMGet(ctx, sessionKeyPrefix+sid, "role", "id").Result()
+	if err != nil {
+		return "", TokenPair{}, err
+	}
+	sessionRole, _ := fields[0].(string)
+	id, _ := fields[1].(string)
+	if sessionRole != role || id == "" {
+		return "", TokenPair{}, ErrInvalidRefreshToken
+	}
+
+	newSecret, err := randomToken(32)
+	if err != nil {
+		return "", TokenPair{}, err
+	}
+	ttl := RefreshTokenTTL()
+	res, err := rotateRefreshScript.Run(ctx, db.RedisClient, []string{sessionKeyPrefix + sid},
+		hashSecret(secret), hashSecret(newSecret), ttl.Milliseconds()).Int()
+	if err != nil {
+		return "", TokenPair{}, err
+	}
+	if res != 1 {
+		if res == -1 {
+			Logger.Warn("Refresh token reused, session ended", zap.String("role", role), zap.String("id", id))
+		}
+		return "", TokenPair{}, ErrInvalidRefreshToken
+	}
+	db.RedisClient.Expire(ctx, sessionIndexKeyPrefix+role+":"+id, ttl)
+
+	access, err := accessToken(role, id, sid)
+	if err != nil {
+		return "", TokenPair{}, err
+	}
+	return id, TokenPair{AccessToken: access, RefreshToken: sid + "." + newSecret, ExpiresIn: int(AccessTokenTTL().Seconds())}, nil
+}
+
+// EndSession logs one session out: its refresh token and every access token issued for it stop working.
+func EndSession(ctx context.Context, role, id, sid string) error {
+	pipe := db.RedisClient.TxPipeline()
+	pipe.Del(ctx, sessionKeyPrefix+sid)
+	pipe.SRem(ctx, sessionIndexKeyPrefix+role+":"+id, sid)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// RevokeSessions logs an account out everywhere, e.g. when it's suspended. Tokens from before
+// sessions existed are caught by the cutoff.
+func RevokeSessions(ctx context.Context, role, id string) error {
+	indexKey := sessionIndexKeyPrefix + role + ":" + id
+	sids, err := db.RedisClient.SMembers(ctx, indexKey).Result()
+	if err != nil {
+		return err
+	}
+	pipe := db.RedisClient.TxPipeline()
+	for _, sid := range sids {
+		pipe.Del(ctx, sessionKeyPrefix+sid)
+	}
+	pipe.Del(ctx, indexKey)
+	pipe.Set(ctx, revokedKeyPrefix+role+":"+id, time.Now().Unix(), max(legacyTokenTTL, AccessTokenTTL()))
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// RevokeSessionsAsync is RevokeSessions for handlers that shouldn't fail on it; errors are logged.
+func RevokeSessionsAsync(role, id string) {
+	SafeGo(func() {
+		if err := RevokeSessions(context.Background(), role, id); err != nil {
+			Logger.Error("Failed to revoke sessions", zap.String("role", role), zap.String("id", id), zap.Error(err))
+		}
+	})
+}
+
+// TokenRevoked reports whether a valid access token for role has since been revoked: its session
+// ended, or the account was logged out everywhere after it was issued. If Redis can't be reached
+// the token is allowed; the account status check still applies.
+func TokenRevoked(ctx context.Context, role string, claims jwt.MapClaims) bool {
+	id, _ := claims["id"].(string)
+	if tokenRole, _ := claims["role"].(string); tokenRole != "" && tokenRole != role {
+		return true
+	}
+
+	pipe := db.RedisClient.Pipeline()
+	cutoff := pipe.Get(ctx, revokedKeyPrefix+role+":"+id)
+	var session *redis.IntCmd
+	if sid, _ := claims["sid"].(string); sid != "" {
+		session = pipe.Exists(ctx, sessionKeyPrefix+sid)
+	}
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		Logger.Warn("Token revocation check failed", zap.Error(err))
+		return false
+	}
+
+	if session != nil && session.Val() == 0 {
+		return true
+	}
+	if revokedAt, err := cutoff.Int64(); err == nil {
+		issuedAt, _ := claims["iat"].(float64) // tokens from before sessions have no iat
+		return int64(issuedAt) < revokedAt
+	}
+	return false
+}
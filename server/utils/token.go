@@ -10,13 +10,13 @@ import (
 	"ridewave/models"
 )
 
-// SendToken generates a JWT and sends the authenticated response
+// SendToken opens an app session and sends the authenticated response with its token pair
 func SendToken(c *gin.Context, entity interface{}, id string) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"id":  id,
-		"exp": time.Now().Add(30 * 24 * time.Hour).Unix(),
-	})
-	tokenString, err := token.SignedString([]byte(os.Getenv("ACCESS_TOKEN_SECRET")))
+	role := SessionUser
+	if _, ok := entity.(*models.Driver); ok {
+		role = SessionDriver
+	}
+	tokens, err := IssueSession(c.Request.Context(), role, id)
 	if err != nil {
 		RespondError(c, http.StatusInternalServerError, "Failed to generate token", err)
 		return
@@ -25,13 +25,67 @@ func SendToken(c *gin.Context, entity interface{}, id string) {
 	switch v := entity.(type) {
 	case *models.User:
 		RespondSuccess(c, http.StatusOK, "Authentication successful", gin.H{
-			"accessToken": tokenString,
-			"user":        v,
+			"accessToken":  tokens.AccessToken,
+			"refreshToken": tokens.RefreshToken,
+			"expiresIn":    tokens.ExpiresIn,
+			"user":         v,
 		})
 	case *models.Driver:
 		RespondSuccess(c, http.StatusOK, "Authentication successful", gin.H{
-			"accessToken": tokenString,
-			"driver":      v,
+			"accessToken":  tokens.AccessToken,
+			"refreshToken": tokens.RefreshToken,
+			"expiresIn":    tokens.ExpiresIn,
+			"driver":       v,
 		})
 	}
 }
+
+// AdminTokenTTL is how long an admin session lasts before re-login is required.
+const AdminTokenTTL = 12 * time.Hour
+
+// AdminTokenSecret signs admin JWTs; ADMIN_JWT_SECRET keeps them separate from app tokens when set.
+func AdminTokenSecret() []byte {
+	if secret := os.Getenv("ADMIN_JWT_SECRET"); secret != "" {
+		return []byte(secret)
+	}
+	return []byte(os.Getenv("ACCESS_TOKEN_SECRET"))
+}
+
+// GenerateAdminToken issues a short-lived admin JWT. The role is looked up on every request, not trusted from the token.
+func GenerateAdminToken(adminID string) (string, time.Time, error) {
+	expiresAt := time.Now().Add(AdminTokenTTL)
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"id":    adminID,
+		"scope": "admin",
+		"exp":   expiresAt.Unix(),
+	})
+	tokenString, err := token.SignedString(AdminTokenSecret())
+	return tokenString, expiresAt, err
+}
+
+// OnboardingTokenTTL bounds how long a pending driver can keep working through training before logging in again.
+const OnboardingTokenTTL = 7 * 24 * time.Hour
+
+// GenerateOnboardingToken issues a driver JWT scoped to onboarding (training) routes only; the
+// regular driver middleware rejects it.
+func GenerateOnboardingToken(driverID string) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"id":    driverID,
+		"scope": "onboarding",
+		"exp":   time.Now().Add(OnboardingTokenTTL).Unix(),
+	})
+	return token.SignedString([]byte(os.Getenv("ACCESS_TOKEN_SECRET")))
+}
+
+// FleetTokenTTL is how long a fleet owner's dashboard session lasts.
+const FleetTokenTTL = 30 * 24 * time.Hour
+
+// GenerateFleetToken issues a JWT scoped to the fleet-owner routes; rider and driver middleware reject it.
+func GenerateFleetToken(fleetID string) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"id":    fleetID,
+		"scope": "fleet",
+		"exp":   time.Now().Add(FleetTokenTTL).Unix(),
+	})
+	return token.SignedString([]byte(os.Getenv("ACCESS_TOKEN_SECRET")))
+}
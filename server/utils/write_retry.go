@@ -0,0 +1,255 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"ridewave/db"
+)
+
+// ══════════════════════════════════════════════════
+// Write Retry Queue — failed background audit writes are kept and replayed, never dropped
+// ══════════════════════════════════════════════════
+
+const (
+	writeRetryKey         = "retry:writes"
+	writeRetryDeadKey     = "retry:writes:dead"
+	writeRetryInterval    = 10 * time.Second
+	writeRetryBaseDelay   = 5 * time.Second
+	writeRetryMaxDelay    = 10 * time.Minute
+	writeRetryMaxAttempts = 50 // ~8 hours at the capped delay, then parked in the dead list
+	writeRetrySpoolFile   = "write-retry.jsonl"
+)
+
+// pendingWrite is one failed write waiting to be replayed.
+type pendingWrite struct {
+	Kind          string          `json:"kind"`
+	Payload       json.RawMessage `json:"payload"`
+	Attempts      int             `json:"attempts"`
+	FirstFailedAt time.Time       `json:"firstFailedAt"`
+	NextAttemptAt time.Time       `json:"nextAttemptAt"`
+	LastError     string          `json:"lastError,omitempty"`
+}
+
+var (
+	retryWritersMu sync.RWMutex
+	retryWriters   = map[string]func(ctx context.Context, payload json.RawMessage) error{}
+	spoolMu        sync.Mutex
+)
+
+// RegisterRetryableWrite names a background write that QueueFailedWrite can replay later.
+func RegisterRetryableWrite(kind string, write func(ctx context.Context, payload json.RawMessage) error) {
+	retryWritersMu.Lock()
+	retryWriters[kind] = write
+	retryWritersMu.Unlock()
+}
+
+// writeRetryDir is where failed writes are spooled while Redis is unreachable too
+// (WRITE_RETRY_DIR, default a directory under the OS temp dir).
+func writeRetryDir() string {
+	if dir := os.Getenv("WRITE_RETRY_DIR"); dir != "" {
+		return dir
+	}
+	return filepath.Join(os.TempDir(), "ridewave-retry")
+}
+
+// writeRetryDelay backs off exponentially from writeRetryBaseDelay up to writeRetryMaxDelay.
+func writeRetryDelay(attempts int) time.Duration {
+	delay := writeRetryBaseDelay
+	for i := 1; i < attempts && delay < writeRetryMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > writeRetryMaxDelay {
+		return writeRetryMaxDelay
+	}
+	return delay
+}
+
+// QueueFailedWrite keeps a write that failed so the retry worker can replay it: in Redis, or on
+// local disk if Redis is unreachable as well.
+func QueueFailedWrite(kind string, payload any, cause error) {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		Logger.Error("Failed to encode write for retry", zap.String("kind", kind), zap.Error(err))
+		return
+	}
+	now := time.Now()
+	w := pendingWrite{Kind: kind, Payload: raw, Attempts: 1, FirstFailedAt: now, NextAttemptAt: now.Add(writeRetryDelay(1))}
+	if cause != nil {
+		w.LastError = cause.Error()
+	}
+	enqueueWrite(w)
+}
+
+func enqueueWrite(w pendingWrite) {
+	line, _ := json.Marshal(w)
+	if db.RedisClient != nil {
+		if err := db.RedisClient.RPush(context.Background(), writeRetryKey, line).Err(); err == nil {
+			return
+		}
+	}
+	if err := spoolWrite(line); err != nil {
+		Logger.Error("Failed to spool write for retry: record lost", zap.String("kind", w.Kind), zap.ByteString("write", line), zap.Error(err))
+	}
+}
+
+func spoolWrite(line []byte) error {
+	spoolMu.Lock()
+	defer spoolMu.Unlock()
+
+	dir := writeRetryDir()
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, writeRetrySpoolFile), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
+
+// drainSpool moves writes spooled to disk into the Redis queue once Redis is back.
+func drainSpool(ctx context.Context) {
+	spoolMu.Lock()
+	defer spoolMu.Unlock()
+
+	path := filepath.Join(writeRetryDir(), writeRetrySpoolFile)
+	data, err := os.ReadFile(path)
+	if err != nil || len(data) == 0 {
+		return
+	}
+	lines := []any{}
+	for _, line := range bytes.Split(bytes.TrimSpace(data), []byte("\n")) {
+		if len(line) > 0 {
+			lines = append(lines, string(line))
+		}
+	}
+	if len(lines) > 0 {
+		if err := db.RedisClient.RPush(ctx, writeRetryKey, lines...).Err(); err != nil {
+			return
+		}
+	}
+	os.Remove(path)
+}
+
+func spooledWrites() int {
+	spoolMu.Lock()
+	defer spoolMu.Unlock()
+
+	f, err := os.Open(filepath.Join(writeRetryDir(), writeRetrySpoolFile))
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+	count := 0
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) > 0 {
+			count++
+		}
+	}
+	return count
+}
+
+// WriteRetryBacklog reports failed writes waiting for a retry (queued in Redis or spooled to
+// disk) and those parked after writeRetryMaxAttempts.
+func WriteRetryBacklog(ctx context.Context) map[string]int64 {
+	backlog := map[string]int64{"queued": 0, "spooled": int64(spooledWrites()), "dead": 0}
+	if db.RedisClient != nil {
+		backlog["queued"], _ = db.RedisClient.LLen(ctx, writeRetryKey).Result()
+		backlog["dead"], _ = db.RedisClient.LLen(ctx, writeRetryDeadKey).Result()
+	}
+	return backlog
+}
+
+// StartWriteRetryWorker replays failed writes with exponential backoff.
+func StartWriteRetryWorker(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(writeRetryInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				retryFailedWrites(ctx)
+			case <-ctx.Done():
+				Logger.Info("Write Retry Worker shutting down...")
+				return
+			}
+		}
+	}()
+}
+
+func retryFailedWrites(ctx context.Context) {
+	if db.RedisClient == nil {
+		return
+	}
+	drainSpool(ctx)
+
+	// Only look at what's queued now; writes requeued below wait for the next tick
+	pending, err := db.RedisClient.LLen(ctx, writeRetryKey).Result()
+	if err != nil || pending == 0 {
+		return
+	}
+
+	replayed, failed := 0, 0
+	for i := int64(0); i < pending; i++ {
+		raw, err := db.RedisClient.LPop(ctx, writeRetryKey).Bytes()
+		if err == redis.Nil {
+			break
+		}
+		if err != nil {
+			return
+		}
+
+		var w pendingWrite
+		if err := json.Unmarshal(raw, &w); err != nil {
+			db.RedisClient.RPush(ctx, writeRetryDeadKey, raw)
+			continue
+		}
+		if time.Now().Before(w.NextAttemptAt) {
+			enqueueWrite(w)
+			continue
+		}
+
+		retryWritersMu.RLock()
+		write := retryWriters[w.Kind]
+		retryWritersMu.RUnlock()
+		if write == nil {
+			Logger.Error("No writer registered for queued write", zap.String("kind", w.Kind))
+			db.RedisClient.RPush(ctx, writeRetryDeadKey, raw)
+			continue
+		}
+
+		if err := write(ctx, w.Payload); err != nil {
+			failed++
+			w.Attempts++
+			w.LastError = err.Error()
+			w.NextAttemptAt = time.Now().Add(writeRetryDelay(w.Attempts))
+			if w.Attempts >= writeRetryMaxAttempts {
+				Logger.Error("Giving up on queued write: parked in the dead list", zap.String("kind", w.Kind), zap.Int("attempts", w.Attempts), zap.Error(err))
+				line, _ := json.Marshal(w)
+				db.RedisClient.RPush(ctx, writeRetryDeadKey, line)
+				continue
+			}
+			enqueueWrite(w)
+			continue
+		}
+		replayed++
+	}
+
+	if replayed > 0 || failed > 0 {
+		Logger.Info("Replayed failed writes", zap.Int("replayed", replayed), zap.Int("stillFailing", failed))
+	}
+}